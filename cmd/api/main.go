@@ -4,7 +4,6 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -17,10 +16,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"prerender-shield/internal/acme"
 	"prerender-shield/internal/api/routes"
 	"prerender-shield/internal/auth"
 	"prerender-shield/internal/config"
 	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/integrity"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/monitoring"
 	"prerender-shield/internal/prerender"
@@ -29,19 +30,30 @@ import (
 	"prerender-shield/internal/scheduler"
 	"prerender-shield/internal/services"
 	sitehandler "prerender-shield/internal/site-handler"
+	siteruntime "prerender-shield/internal/site-runtime"
 	siteserver "prerender-shield/internal/site-server"
+	"prerender-shield/internal/utils"
 )
 
 func main() {
 	// 解析命令行参数
 	var configPath string
-	flag.StringVar(&configPath, "config", "", "Path to the YAML configuration file")
+	flag.StringVar(&configPath, "config", "", "Path to the configuration file (.yml, .yaml or .json)")
 	flag.Parse()
 
 	// 加载配置
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		logging.DefaultLogger.Fatal("Failed to load config: %v", err)
+	}
+
+	// 按配置设置日志格式、级别和日志文件
+	logging.DefaultLogger.Configure(newLoggingConfig(cfg))
+
+	// 审计日志持久化到数据目录，重启后从文件恢复
+	auditPath := filepath.Join(cfg.Dirs.DataDir, "logs", "audit.log")
+	if err := logging.DefaultLogger.EnableAuditPersistence(auditPath, cfg.Logging.Audit.RetentionDays); err != nil {
+		logging.DefaultLogger.Error("Failed to enable audit log persistence: %v", err)
 	}
 
 	// 获取配置管理器实例
@@ -49,21 +61,11 @@ func main() {
 
 	// 启动配置文件监控
 	if err := configManager.StartWatching(); err != nil {
-		log.Printf("Failed to start config watching: %v", err)
+		logging.DefaultLogger.Error("Failed to start config watching: %v", err)
 	} else {
-		log.Println("Config watching started")
+		logging.DefaultLogger.Info("Config watching started")
 	}
 
-	// 添加配置变化处理函数
-	configManager.AddConfigChangeHandler(func(newConfig *config.Config) {
-		logging.DefaultLogger.Info("Config updated, reloading services...")
-		// 记录配置变更审计日志
-		logging.DefaultLogger.LogAdminAction("system", "localhost", "config_update", "global_config", map[string]interface{}{"source": "config_file"}, "success", "Configuration updated from file")
-		// 这里可以添加需要重新加载的服务逻辑
-		// 例如：重新初始化防火墙规则、渲染预热引擎等
-		logging.DefaultLogger.Info("Services reloaded successfully")
-	})
-
 	// 6. 初始化各模块
 	// 1. Redis客户端初始化
 	// 构建完整的Redis URL，包括密码和数据库索引
@@ -76,7 +78,7 @@ func main() {
 	// 解析URL
 	parsedURL, err := url.Parse(redisURL)
 	if err != nil {
-		log.Fatalf("Failed to parse Redis URL: %v", err)
+		logging.DefaultLogger.Fatal("Failed to parse Redis URL: %v", err)
 	}
 
 	// 设置密码
@@ -94,7 +96,7 @@ func main() {
 
 	redisClient, err := redis.NewClient(finalRedisURL)
 	if err != nil {
-		log.Fatalf("Failed to initialize Redis client: %v", err)
+		logging.DefaultLogger.Fatal("Failed to initialize Redis client: %v", err)
 	}
 
 	// 0.1 初始化WAF仓库
@@ -121,12 +123,12 @@ func main() {
 	redisSubscriber := redis.NewSubscriber(redisClient.GetRawClient())
 	// 添加配置变更处理
 	redisSubscriber.AddHandler("site:update", func(channel, payload string) {
-		log.Printf("Received site update event: %s, payload: %s", channel, payload)
+		logging.DefaultLogger.Info("Received site update event: %s, payload: %s", channel, payload)
 		// 这里可以添加站点更新逻辑
 	})
 	// 启动订阅者
 	if err := redisSubscriber.Start(); err != nil {
-		log.Printf("Failed to start Redis subscriber: %v", err)
+		logging.DefaultLogger.Error("Failed to start Redis subscriber: %v", err)
 	}
 	defer redisSubscriber.Stop()
 
@@ -149,56 +151,39 @@ func main() {
 	// 6. 访问日志管理器
 	visitLogManager := logging.NewVisitLogManager(finalRedisURL)
 
-	// 6.1 GeoIP服务
-	geoIPService := services.NewGeoIPService("")
+	// 6.1 GeoIP服务，本地数据库可用时防火墙地区限制只查询本地数据库
+	geoIPService := services.NewGeoIPService(cfg.GeoIP.DatabasePath)
+	defer geoIPService.Close()
+	geoIPService.SetLimits(cfg.GeoIP.RateLimit, time.Duration(cfg.GeoIP.NegativeCacheTTL)*time.Second)
+	if database := geoIPService.Database(); database != nil {
+		firewallManager.SetGeoIPResolver(database)
+	}
+	crawlerLogManager.SetGeoIPResolver(geoIPService)
+	visitLogManager.SetGeoIPResolver(geoIPService)
 
 	// 6.2 日志处理器
 	logProcessor := services.NewLogProcessor(crawlerLogManager, visitLogManager, geoIPService, configManager, redisClient.GetRawClient())
 	logProcessor.Start()
 
-	// 7. 为每个站点创建并启动引擎
+	// 7. 为每个站点创建并启动引擎，停用的站点跳过
 	for _, site := range cfg.Sites {
-		// 将 config.PrerenderConfig 转换为 prerender.PrerenderConfig
-		prerenderConfig := prerender.PrerenderConfig{
-			Enabled:           site.Prerender.Enabled,
-			PoolSize:          site.Prerender.PoolSize,
-			MinPoolSize:       site.Prerender.MinPoolSize,
-			MaxPoolSize:       site.Prerender.MaxPoolSize,
-			Timeout:           site.Prerender.Timeout,
-			CacheTTL:          site.Prerender.CacheTTL,
-			CrawlerHeaders:    site.Prerender.CrawlerHeaders,
-			UseDefaultHeaders: site.Prerender.UseDefaultHeaders,
-			Preheat: prerender.PreheatConfig{
-				Enabled:  site.Prerender.Preheat.Enabled,
-				MaxDepth: site.Prerender.Preheat.MaxDepth,
-			},
+		if !site.IsEnabled() {
+			logging.DefaultLogger.Info("Site %s (ID: %s) is disabled, skipping engines", site.Name, site.ID)
+			continue
 		}
+		// 将站点配置转换为 prerender.PrerenderConfig
+		prerenderConfig := prerender.NewSitePrerenderConfig(site)
 
 		// 将引擎添加到管理器
 		// AddSite 方法会自动创建并启动引擎
 		if err := prerenderManager.AddSite(site.ID, prerenderConfig, redisClient); err != nil {
-			logging.DefaultLogger.Error("Failed to add site to prerender manager: %v", err)
-			log.Fatalf("Failed to add site to prerender manager: %v", err)
+			logging.DefaultLogger.Fatal("Failed to add site to prerender manager: %v", err)
 		}
 		logging.DefaultLogger.Info("Prerender engine started successfully for site %s (ID: %s)", site.Name, site.ID)
 
 		// 创建防火墙引擎
-		if err := firewallManager.AddSite(site.Name, firewall.Config{
-			RulesPath: site.Firewall.RulesPath,
-			ActionConfig: firewall.ActionConfig{
-				DefaultAction: site.Firewall.ActionConfig.DefaultAction,
-				BlockMessage:  site.Firewall.ActionConfig.BlockMessage,
-			},
-			StaticDir:           cfg.Dirs.StaticDir,
-			GeoIPConfig:         &site.Firewall.GeoIPConfig,
-			RateLimitConfig:     &site.Firewall.RateLimitConfig,
-			FileIntegrityConfig: &site.FileIntegrityConfig,
-			Blacklist:           site.Firewall.Blacklist,
-			Whitelist:           site.Firewall.Whitelist,
-			RedisClient:         redisClient.GetRawClient(),
-		}); err != nil {
-			logging.DefaultLogger.Error("Failed to initialize firewall engine for site %s: %v", site.Name, err)
-			log.Fatalf("Failed to initialize firewall engine for site %s: %v", site.Name, err)
+		if err := firewallManager.AddSite(site.Name, firewall.NewSiteConfig(site, cfg.Dirs.StaticDir, redisClient.GetRawClient())); err != nil {
+			logging.DefaultLogger.Fatal("Failed to initialize firewall engine for site %s: %v", site.Name, err)
 		}
 		logging.DefaultLogger.Info("Firewall engine initialized successfully for site %s", site.Name)
 	}
@@ -208,37 +193,81 @@ func main() {
 
 	// 5. 定时任务调度器初始化
 	schedulerInstance := scheduler.NewScheduler(prerenderManager, redisClient, cfg)
+	// ACME证书由调度器定期签发和续签
+	acmeManager := acme.NewManager(cfg.Dirs.CertsDir, cfg.ACME)
+	schedulerInstance.SetACMEManager(acmeManager)
+	// 启用网页防篡改的站点由调度器定期校验静态目录
+	integrityManager := integrity.NewManager(cfg.Dirs.StaticDir, cfg.Dirs.DataDir)
+	schedulerInstance.SetIntegrityManager(integrityManager)
 	schedulerInstance.Start()
 	defer schedulerInstance.Stop()
+	// 配置文件变化时重新注册站点的定时预热任务
+	configManager.AddConfigChangeHandler(schedulerInstance.UpdateConfig)
 
 	// 8. 初始化监控模块
 	monitor := monitoring.NewMonitor(monitoring.Config{
 		Enabled:           true,
 		PrometheusAddress: ":9090",
+		DataDir:           cfg.Dirs.DataDir,
+		StaticDir:         cfg.Dirs.StaticDir,
 	})
 	if err := monitor.Start(); err != nil {
-		logging.DefaultLogger.Error("Failed to start monitoring: %v", err)
-		log.Fatalf("Failed to start monitoring: %v", err)
+		logging.DefaultLogger.Fatal("Failed to start monitoring: %v", err)
 	}
 	logging.DefaultLogger.Info("Monitoring service started successfully")
 
 	// 9. 初始化站点服务器管理器
 	siteServerManager := siteserver.NewManager(monitor)
+	siteServerManager.SetRetryPolicy(cfg.Server.SiteBindRetries, time.Duration(cfg.Server.SiteBindRetryDelay)*time.Second)
+	siteServerManager.SetCertsDir(cfg.Dirs.CertsDir)
+	// ACME证书签发后运行中的站点立即加载新证书
+	acmeManager.SetOnIssued(func(site config.SiteConfig) {
+		if err := siteServerManager.ReloadTLS(site); err != nil {
+			logging.DefaultLogger.Error("Failed to reload certificate for site %s: %v", site.Name, err)
+		}
+	})
 
 	// 10. 初始化站点处理器
 	siteHandler := sitehandler.NewHandler(prerenderManager, wafRepo, redisClient, geoIPService)
+	siteHandler.SetACMEChallenges(acmeManager)
+	siteHandler.SetFirewallManager(firewallManager)
+	clientIPResolver, err := utils.NewClientIPResolver(cfg.Server.TrustedProxies)
+	if err != nil {
+		logging.DefaultLogger.Error("Invalid trusted proxies: %v", err)
+	}
+	siteHandler.SetClientIPResolver(clientIPResolver)
 
 	// 11. 为每个站点启动服务器
 	for _, site := range cfg.Sites {
+		if !site.IsEnabled() {
+			continue
+		}
 		// 创建站点处理器
 		siteHTTPHandler := siteHandler.CreateSiteHandler(site, crawlerLogManager, visitLogManager, monitor, cfg.Dirs.StaticDir)
-		// 启动站点服务器
-		siteServerManager.StartSiteServer(site, cfg.Server.Address, cfg.Dirs.StaticDir, crawlerLogManager, siteHTTPHandler)
-		log.Printf("站点服务器启动成功: %s (%s:%d)", site.Name, cfg.Server.Address, site.Port)
+		// 启动站点服务器，端口绑定失败时站点标记为error并在后台重试，不影响其他站点
+		if err := siteServerManager.StartSiteServer(site, cfg.Server.Address, cfg.Dirs.StaticDir, crawlerLogManager, siteHTTPHandler); err != nil {
+			logging.DefaultLogger.Error("Failed to start server for site %s: %v", site.Name, err)
+			continue
+		}
+		logging.DefaultLogger.Info("站点服务器启动成功: %s (%s:%d)", site.Name, cfg.Server.Address, site.Port)
 	}
 
+	// 12. 配置文件变化时按站点差异重载，配置变化的站点热更新，未变化的站点继续运行
+	siteReloader := siteruntime.NewReloader(siteruntime.New(siteServerManager, siteHandler, prerenderManager, firewallManager,
+		redisClient, crawlerLogManager, visitLogManager, monitor, cfg.Server.Address, cfg.Dirs.StaticDir), cfg)
+	configManager.AddConfigChangeHandler(func(newConfig *config.Config) {
+		logging.DefaultLogger.Info("Config updated, reloading sites...")
+		// 记录配置变更审计日志
+		logging.DefaultLogger.LogAdminAction("system", "localhost", "config_update", "global_config", map[string]interface{}{"source": "config_file"}, "success", "Configuration updated from file")
+		siteReloader.Reload(newConfig)
+	})
+
 	// 13. 初始化Gin路由
 	ginRouter := gin.Default()
+	// 审计日志中的管理员IP同样只采用可信代理转发的X-Forwarded-For
+	if err := ginRouter.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logging.DefaultLogger.Error("Failed to set trusted proxies: %v", err)
+	}
 
 	// 14. 初始化API路由器
 	apiRouter := routes.NewRouter(
@@ -246,6 +275,9 @@ func main() {
 		jwtManager,
 		configManager,
 		prerenderManager,
+		firewallManager,
+		acmeManager,
+		integrityManager,
 		redisClient,
 		schedulerInstance,
 		siteServerManager,
@@ -268,9 +300,9 @@ func main() {
 
 	// 16. 启动API服务器
 	go func() {
-		log.Printf("API server starting on %s:%d", cfg.Server.Address, cfg.Server.APIPort)
+		logging.DefaultLogger.Info("API server starting on %s:%d", cfg.Server.Address, cfg.Server.APIPort)
 		if err := apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start API server: %v", err)
+			logging.DefaultLogger.Fatal("Failed to start API server: %v", err)
 		}
 	}()
 
@@ -290,23 +322,23 @@ func main() {
 		webDir = filepath.Join(currentDir, "bin", "web")
 	}
 	cfg.Dirs.AdminStaticDir = webDir
-	log.Printf("Admin static dir: %s", cfg.Dirs.AdminStaticDir)
+	logging.DefaultLogger.Info("Admin static dir: %s", cfg.Dirs.AdminStaticDir)
 
 	// 检查目录是否存在
 	var actualStaticDir string
 	if _, err := os.Stat(cfg.Dirs.AdminStaticDir); os.IsNotExist(err) {
-		log.Printf("Admin static dir does not exist: %s", cfg.Dirs.AdminStaticDir)
+		logging.DefaultLogger.Warn("Admin static dir does not exist: %s", cfg.Dirs.AdminStaticDir)
 		actualStaticDir = cfg.Dirs.AdminStaticDir
 	} else {
-		log.Printf("Admin static dir exists: %s", cfg.Dirs.AdminStaticDir)
+		logging.DefaultLogger.Debug("Admin static dir exists: %s", cfg.Dirs.AdminStaticDir)
 		// 列出目录内容
 		files, _ := os.ReadDir(cfg.Dirs.AdminStaticDir)
-		log.Printf("Admin static dir contents: %v", files)
+		logging.DefaultLogger.Debug("Admin static dir contents: %v", files)
 
 		// 检查dist目录是否在web目录下
 		distDir := filepath.Join(cfg.Dirs.AdminStaticDir, "dist")
 		if _, err := os.Stat(distDir); err == nil {
-			log.Printf("Using dist directory for static files: %s", distDir)
+			logging.DefaultLogger.Info("Using dist directory for static files: %s", distDir)
 			actualStaticDir = distDir
 		} else {
 			// 直接使用web目录
@@ -331,7 +363,7 @@ func main() {
 		path := strings.Split(r.URL.Path, "#")[0]
 		filePath := filepath.Join(actualStaticDir, strings.TrimPrefix(path, "/"))
 
-		log.Printf("Static file request: %s -> %s", r.URL.Path, filePath)
+		logging.DefaultLogger.Debug("Static file request: %s -> %s", r.URL.Path, filePath)
 
 		// 检查是否为静态资源
 		if isStaticFile(filePath) {
@@ -391,38 +423,61 @@ func main() {
 	}
 
 	go func() {
-		log.Printf("Admin console server starting on %s:%d", cfg.Server.Address, cfg.Server.ConsolePort)
+		logging.DefaultLogger.Info("Admin console server starting on %s:%d", cfg.Server.Address, cfg.Server.ConsolePort)
 		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start admin console server: %v", err)
+			logging.DefaultLogger.Fatal("Failed to start admin console server: %v", err)
 		}
 	}()
 
 	// 18. 优雅关闭管理控制台服务器
 	defer func() {
 		if err := adminServer.Shutdown(context.Background()); err != nil {
-			log.Printf("Error shutting down admin console server: %v", err)
+			logging.DefaultLogger.Error("Error shutting down admin console server: %v", err)
 		}
 	}()
 
 	// 16. 处理信号，优雅关闭服务
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	log.Println("Server started successfully, waiting for signals...")
+	logging.DefaultLogger.Info("Server started successfully, waiting for signals...")
 	<-quit
 
-	log.Println("Shutting down server...")
+	logging.DefaultLogger.Info("Shutting down server...")
 
 	// 17. 关闭API服务器
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := apiServer.Shutdown(ctx); err != nil {
-		log.Fatalf("API server forced to shutdown: %v", err)
+		logging.DefaultLogger.Fatal("API server forced to shutdown: %v", err)
 	}
 
 	// 18. 关闭站点服务器
 	siteServerManager.StopAllServers()
 
-	log.Println("Server exited")
+	// 19. 停止防火墙引擎的后台清理协程
+	firewallManager.StopAll()
+
+	// 20. 站点服务器停止后不再产生新日志，将缓冲中的爬虫日志和访问日志写入Redis
+	crawlerLogManager.Close()
+	visitLogManager.Close()
+
+	logging.DefaultLogger.Info("Server exited")
+}
+
+// newLoggingConfig 根据应用配置创建运行日志配置，启用日志文件时写入data_dir/logs/prerender-shield.log
+func newLoggingConfig(cfg *config.Config) logging.Config {
+	loggingConfig := logging.Config{
+		Level:      cfg.Logging.Level,
+		Output:     "stdout",
+		Format:     cfg.Logging.Format,
+		Modules:    cfg.Logging.Modules,
+		MaxSizeMB:  cfg.Logging.File.MaxSizeMB,
+		MaxBackups: cfg.Logging.File.MaxBackups,
+	}
+	if cfg.Logging.File.Enabled {
+		loggingConfig.File = filepath.Join(cfg.Dirs.DataDir, "logs", "prerender-shield.log")
+	}
+	return loggingConfig
 }
 
 // responseRecorder 响应记录器，用于捕获状态码
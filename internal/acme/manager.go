@@ -0,0 +1,429 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	acmeclient "golang.org/x/crypto/acme"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/logging"
+)
+
+// ChallengePathPrefix HTTP-01验证请求的路径前缀
+const ChallengePathPrefix = "/.well-known/acme-challenge/"
+
+// 证书签发状态
+const (
+	StatePending     = "pending"     // 正在签发
+	StateValid       = "valid"       // 证书有效
+	StateError       = "error"       // 签发失败，稍后重试
+	StateUnavailable = "unavailable" // 站点域名无法通过HTTP-01验证
+)
+
+const (
+	// defaultRenewBefore 证书剩余有效期少于该时间时续签
+	defaultRenewBefore = 30 * 24 * time.Hour
+	// defaultRetryInterval 签发失败后的重试间隔，避免触发ACME服务的频率限制
+	defaultRetryInterval = 6 * time.Hour
+)
+
+// Status 站点证书签发状态
+type Status struct {
+	State       string    `json:"state"`
+	Error       string    `json:"error,omitempty"`
+	Domains     []string  `json:"domains,omitempty"`
+	NotAfter    time.Time `json:"not_after,omitempty"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+}
+
+// Manager ACME证书管理器，负责通过HTTP-01验证签发和续签站点证书
+type Manager struct {
+	certsDir      string
+	cfg           config.ACMEConfig
+	renewBefore   time.Duration
+	retryInterval time.Duration
+
+	mutex      sync.Mutex
+	challenges map[string]string // 验证令牌 -> 密钥授权
+	statuses   map[string]Status
+	inProgress map[string]bool
+	client     *acmeclient.Client
+
+	// issue 签发覆盖domains的证书，返回PEM格式的证书链和私钥，测试时可替换
+	issue func(ctx context.Context, domains []string) (certPEM, keyPEM []byte, err error)
+	// onIssued 证书签发或续签成功后调用，用于让站点服务器加载新证书
+	onIssued func(site config.SiteConfig)
+}
+
+// NewManager 创建ACME证书管理器，证书保存在certsDir/<站点ID>/下
+func NewManager(certsDir string, cfg config.ACMEConfig) *Manager {
+	if cfg.DirectoryURL == "" {
+		cfg.DirectoryURL = config.DefaultACMEDirectoryURL
+	}
+	renewBefore := defaultRenewBefore
+	if cfg.RenewBefore > 0 {
+		renewBefore = time.Duration(cfg.RenewBefore) * 24 * time.Hour
+	}
+
+	m := &Manager{
+		certsDir:      certsDir,
+		cfg:           cfg,
+		renewBefore:   renewBefore,
+		retryInterval: defaultRetryInterval,
+		challenges:    make(map[string]string),
+		statuses:      make(map[string]Status),
+		inProgress:    make(map[string]bool),
+	}
+	m.issue = m.obtainCertificate
+	return m
+}
+
+// SetOnIssued 设置证书签发成功后的回调
+func (m *Manager) SetOnIssued(onIssued func(site config.SiteConfig)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onIssued = onIssued
+}
+
+// GetChallenge 返回HTTP-01验证令牌对应的响应内容
+func (m *Manager) GetChallenge(token string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	keyAuth, exists := m.challenges[token]
+	return keyAuth, exists
+}
+
+// GetStatus 获取站点证书签发状态
+func (m *Manager) GetStatus(siteID string) (Status, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	status, exists := m.statuses[siteID]
+	return status, exists
+}
+
+// EnsureCertificate 确保启用ACME的站点拥有有效证书，证书不存在或即将过期时签发新证书
+// restrictLocalDomains为true时站点只能使用本机域名，无法通过验证，只记录为不可用。
+// 签发失败后在重试间隔内不再尝试
+func (m *Manager) EnsureCertificate(ctx context.Context, site config.SiteConfig, restrictLocalDomains bool) error {
+	if !site.TLS.ACME {
+		return nil
+	}
+	if restrictLocalDomains {
+		m.setStatus(site.ID, Status{State: StateUnavailable, Error: "ACME is unavailable while only local domains are allowed"})
+		return nil
+	}
+	domains := EligibleDomains(site.Domains)
+	if len(domains) == 0 {
+		m.setStatus(site.ID, Status{State: StateUnavailable, Error: "site has no publicly routable domains"})
+		return nil
+	}
+
+	if notAfter, err := m.certificateExpiry(site); err == nil && time.Until(notAfter) > m.renewBefore {
+		m.setStatus(site.ID, Status{State: StateValid, Domains: domains, NotAfter: notAfter})
+		return nil
+	}
+
+	m.mutex.Lock()
+	previous := m.statuses[site.ID]
+	if m.inProgress[site.ID] || previous.State == StateError && time.Since(previous.LastAttempt) < m.retryInterval {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.inProgress[site.ID] = true
+	m.statuses[site.ID] = Status{State: StatePending, Domains: domains, NotAfter: previous.NotAfter, LastAttempt: time.Now()}
+	m.mutex.Unlock()
+
+	defer func() {
+		m.mutex.Lock()
+		delete(m.inProgress, site.ID)
+		m.mutex.Unlock()
+	}()
+
+	notAfter, err := m.issueAndSave(ctx, site, domains)
+	if err != nil {
+		m.setStatus(site.ID, Status{State: StateError, Error: err.Error(), Domains: domains, NotAfter: previous.NotAfter, LastAttempt: time.Now()})
+		logging.DefaultLogger.LogAdminAction(
+			"system",
+			"",
+			"acme_certificate",
+			"site",
+			map[string]interface{}{
+				"site_id": site.ID,
+				"domains": domains,
+				"error":   err.Error(),
+			},
+			"failed",
+			"ACME certificate request failed",
+		)
+		return err
+	}
+
+	m.setStatus(site.ID, Status{State: StateValid, Domains: domains, NotAfter: notAfter, LastAttempt: time.Now()})
+	logging.DefaultLogger.LogAdminAction(
+		"system",
+		"",
+		"acme_certificate",
+		"site",
+		map[string]interface{}{
+			"site_id":   site.ID,
+			"domains":   domains,
+			"not_after": notAfter,
+		},
+		"success",
+		"ACME certificate issued",
+	)
+
+	m.mutex.Lock()
+	onIssued := m.onIssued
+	m.mutex.Unlock()
+	if onIssued != nil {
+		onIssued(site)
+	}
+	return nil
+}
+
+// issueAndSave 签发证书并保存到站点证书目录，返回证书过期时间
+func (m *Manager) issueAndSave(ctx context.Context, site config.SiteConfig, domains []string) (time.Time, error) {
+	certPEM, keyPEM, err := m.issue(ctx, domains)
+	if err != nil {
+		return time.Time{}, err
+	}
+	leaf, err := parseLeaf(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	certFile, keyFile := site.CertificateFiles()
+	if err := os.MkdirAll(filepath.Join(m.certsDir, site.ID), 0700); err != nil {
+		return time.Time{}, err
+	}
+	// 先写私钥再写证书，站点服务器以证书文件是否存在判断是否已签发
+	if err := writeFileAtomic(filepath.Join(m.certsDir, keyFile), keyPEM, 0600); err != nil {
+		return time.Time{}, err
+	}
+	if err := writeFileAtomic(filepath.Join(m.certsDir, certFile), certPEM, 0644); err != nil {
+		return time.Time{}, err
+	}
+	return leaf.NotAfter, nil
+}
+
+// certificateExpiry 读取站点当前证书的过期时间
+func (m *Manager) certificateExpiry(site config.SiteConfig) (time.Time, error) {
+	certFile, _ := site.CertificateFiles()
+	certPEM, err := os.ReadFile(filepath.Join(m.certsDir, certFile))
+	if err != nil {
+		return time.Time{}, err
+	}
+	leaf, err := parseLeaf(certPEM)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return leaf.NotAfter, nil
+}
+
+func (m *Manager) setStatus(siteID string, status Status) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.statuses[siteID] = status
+}
+
+func (m *Manager) setChallenge(token, keyAuth string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.challenges[token] = keyAuth
+}
+
+func (m *Manager) deleteChallenge(token string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.challenges, token)
+}
+
+// EligibleDomains 返回可以通过HTTP-01验证的域名
+// IP地址、本机域名和通配符域名（HTTP-01不支持）会被排除
+func EligibleDomains(domains []string) []string {
+	eligible := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if net.ParseIP(domain) != nil || config.IsLocalDomain(domain) ||
+			strings.HasPrefix(domain, "*.") || !strings.Contains(domain, ".") ||
+			strings.HasSuffix(domain, ".localhost") || strings.HasSuffix(domain, ".local") {
+			continue
+		}
+		eligible = append(eligible, domain)
+	}
+	return eligible
+}
+
+// obtainCertificate 通过ACME服务完成HTTP-01验证并签发证书
+func (m *Manager) obtainCertificate(ctx context.Context, domains []string) (certPEM, keyPEM []byte, err error) {
+	client, err := m.accountClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acmeclient.DomainIDs(domains...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create order: %v", err)
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := m.authorize(ctx, client, authzURL); err != nil {
+			return nil, nil, err
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, nil, fmt.Errorf("order not ready: %v", err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}, certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to finalize order: %v", err)
+	}
+
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// authorize 完成单个域名的HTTP-01验证，验证期间由站点处理器返回密钥授权
+func (m *Manager) authorize(ctx context.Context, client *acmeclient.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %v", err)
+	}
+	if authz.Status == acmeclient.StatusValid {
+		return nil
+	}
+
+	var challenge *acmeclient.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no http-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	keyAuth, err := client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	m.setChallenge(challenge.Token, keyAuth)
+	defer m.deleteChallenge(challenge.Token)
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept challenge for %s: %v", authz.Identifier.Value, err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("authorization failed for %s: %v", authz.Identifier.Value, err)
+	}
+	return nil
+}
+
+// accountClient 返回已注册账户的ACME客户端，账户私钥保存在certsDir/acme/account.key
+func (m *Manager) accountClient(ctx context.Context) (*acmeclient.Client, error) {
+	m.mutex.Lock()
+	client := m.client
+	m.mutex.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	key, err := m.loadAccountKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ACME account key: %v", err)
+	}
+	client = &acmeclient.Client{Key: key, DirectoryURL: m.cfg.DirectoryURL}
+
+	account := &acmeclient.Account{}
+	if m.cfg.Email != "" {
+		account.Contact = []string{"mailto:" + m.cfg.Email}
+	}
+	if _, err := client.Register(ctx, account, acmeclient.AcceptTOS); err != nil && !errors.Is(err, acmeclient.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register ACME account: %v", err)
+	}
+
+	m.mutex.Lock()
+	m.client = client
+	m.mutex.Unlock()
+	return client, nil
+}
+
+// loadAccountKey 读取ACME账户私钥，不存在时生成新私钥
+func (m *Manager) loadAccountKey() (*ecdsa.PrivateKey, error) {
+	keyPath := filepath.Join(m.certsDir, "acme", "account.key")
+	if data, err := os.ReadFile(keyPath); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid account key %s", keyPath)
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// parseLeaf 解析PEM证书链中的第一张证书
+func parseLeaf(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid certificate PEM")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免站点服务器读到写了一半的证书
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
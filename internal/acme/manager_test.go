@@ -0,0 +1,159 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"prerender-shield/internal/config"
+)
+
+// selfSigned 生成覆盖domains的自签名证书，模拟ACME服务签发
+func selfSigned(t *testing.T, domains []string, validFor time.Duration) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: domains[0]},
+		DNSNames:     domains,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// TestEnsureCertificate 测试签发证书后保存文件并通知站点服务器，有效期充足时不重复签发
+func TestEnsureCertificate(t *testing.T) {
+	certsDir := t.TempDir()
+	manager := NewManager(certsDir, config.ACMEConfig{})
+
+	issued := 0
+	manager.issue = func(ctx context.Context, domains []string) ([]byte, []byte, error) {
+		issued++
+		certPEM, keyPEM := selfSigned(t, domains, 90*24*time.Hour)
+		return certPEM, keyPEM, nil
+	}
+	var reloaded []string
+	manager.SetOnIssued(func(site config.SiteConfig) {
+		reloaded = append(reloaded, site.ID)
+	})
+
+	site := config.SiteConfig{
+		ID:      "site1",
+		Domains: []string{"example.com", "www.example.com", "127.0.0.1"},
+		TLS:     config.SiteTLSConfig{Enabled: true, ACME: true},
+	}
+	if err := manager.EnsureCertificate(context.Background(), site, false); err != nil {
+		t.Fatalf("failed to issue certificate: %v", err)
+	}
+	for _, name := range []string{config.SiteCertFileName, config.SiteKeyFileName} {
+		if _, err := os.Stat(filepath.Join(certsDir, "site1", name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+	status, _ := manager.GetStatus("site1")
+	if status.State != StateValid || !reflect.DeepEqual(status.Domains, []string{"example.com", "www.example.com"}) {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if !reflect.DeepEqual(reloaded, []string{"site1"}) {
+		t.Errorf("expected site server to reload certificate, got %v", reloaded)
+	}
+
+	// 有效期充足时不重新签发
+	if err := manager.EnsureCertificate(context.Background(), site, false); err != nil || issued != 1 {
+		t.Errorf("expected certificate to be reused, issued %d times, err %v", issued, err)
+	}
+
+	// 即将过期时续签
+	expiringCert, _ := selfSigned(t, []string{"example.com"}, 10*24*time.Hour)
+	os.WriteFile(filepath.Join(certsDir, "site1", config.SiteCertFileName), expiringCert, 0644)
+	if err := manager.EnsureCertificate(context.Background(), site, false); err != nil || issued != 2 {
+		t.Errorf("expected certificate expiring soon to be renewed, issued %d times, err %v", issued, err)
+	}
+	if status, _ := manager.GetStatus("site1"); time.Until(status.NotAfter) < 80*24*time.Hour {
+		t.Errorf("expected renewed certificate expiry, got %v", status.NotAfter)
+	}
+}
+
+// TestEnsureCertificateFailure 测试签发失败记录错误状态，重试间隔内不再尝试
+func TestEnsureCertificateFailure(t *testing.T) {
+	manager := NewManager(t.TempDir(), config.ACMEConfig{})
+	attempts := 0
+	manager.issue = func(ctx context.Context, domains []string) ([]byte, []byte, error) {
+		attempts++
+		return nil, nil, errors.New("challenge failed")
+	}
+
+	site := config.SiteConfig{ID: "site1", Domains: []string{"example.com"}, TLS: config.SiteTLSConfig{ACME: true}}
+	if err := manager.EnsureCertificate(context.Background(), site, false); err == nil {
+		t.Fatal("expected issuance error")
+	}
+	if status, _ := manager.GetStatus("site1"); status.State != StateError || status.Error != "challenge failed" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	manager.EnsureCertificate(context.Background(), site, false)
+	if attempts != 1 {
+		t.Errorf("expected no retry within the retry interval, got %d attempts", attempts)
+	}
+
+	manager.retryInterval = 0
+	manager.EnsureCertificate(context.Background(), site, false)
+	if attempts != 2 {
+		t.Errorf("expected retry after the retry interval, got %d attempts", attempts)
+	}
+}
+
+// TestEnsureCertificateUnavailable 测试只允许本机域名或站点没有公网域名时不发起签发
+func TestEnsureCertificateUnavailable(t *testing.T) {
+	manager := NewManager(t.TempDir(), config.ACMEConfig{})
+	manager.issue = func(ctx context.Context, domains []string) ([]byte, []byte, error) {
+		t.Error("unexpected issuance")
+		return nil, nil, errors.New("unexpected")
+	}
+
+	public := config.SiteConfig{ID: "public", Domains: []string{"example.com"}, TLS: config.SiteTLSConfig{ACME: true}}
+	if err := manager.EnsureCertificate(context.Background(), public, true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if status, _ := manager.GetStatus("public"); status.State != StateUnavailable {
+		t.Errorf("expected unavailable with restrict_local_domains, got %+v", status)
+	}
+
+	local := config.SiteConfig{ID: "local", Domains: []string{"localhost", "127.0.0.1", "*.example.com"}, TLS: config.SiteTLSConfig{ACME: true}}
+	manager.EnsureCertificate(context.Background(), local, false)
+	if status, _ := manager.GetStatus("local"); status.State != StateUnavailable {
+		t.Errorf("expected unavailable without public domains, got %+v", status)
+	}
+}
+
+// TestEligibleDomains 测试筛选可以通过HTTP-01验证的域名
+func TestEligibleDomains(t *testing.T) {
+	got := EligibleDomains([]string{"Example.com", "*.example.com", "localhost", "127.0.0.1", "::1", "intranet", "app.localhost", "printer.local", "api.example.org"})
+	want := []string{"example.com", "api.example.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EligibleDomains() = %v, want %v", got, want)
+	}
+}
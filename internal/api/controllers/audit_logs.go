@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/logging"
+)
+
+// auditLogCSVHeader 审计日志CSV导出的列
+var auditLogCSVHeader = []string{"time", "level", "eventType", "user", "ip", "action", "resource", "result", "message", "details"}
+
+// auditFilter 从查询参数解析审计日志过滤条件，startTime和endTime为RFC3339格式，为空时不限
+func auditFilter(ctx *gin.Context) (logging.AuditFilter, error) {
+	filter := logging.AuditFilter{
+		Action:   ctx.Query("action"),
+		Resource: ctx.Query("resource"),
+		Actor:    ctx.Query("actor"),
+		Result:   ctx.Query("result"),
+		Keyword:  ctx.Query("keyword"),
+	}
+	var err error
+	if value := ctx.Query("startTime"); value != "" {
+		if filter.Start, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("invalid startTime: %s", value)
+		}
+	}
+	if value := ctx.Query("endTime"); value != "" {
+		if filter.End, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("invalid endTime: %s", value)
+		}
+	}
+	return filter, nil
+}
+
+// GetAuditLogs 查询审计日志，支持按操作类型、资源、操作用户、结果、时间范围过滤和按消息搜索，最新的在前
+func (c *SystemController) GetAuditLogs(ctx *gin.Context) {
+	filter, err := auditFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "20"))
+
+	logs, total := logging.DefaultLogger.GetAuditLogs(filter, page, pageSize)
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"items":    logs,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+		},
+	})
+}
+
+// ExportAuditLogs 按时间顺序导出满足条件的全部审计日志，format为csv（默认）或json，边读取边写入响应
+func (c *SystemController) ExportAuditLogs(ctx *gin.Context) {
+	filter, err := auditFilter(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	format := ctx.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid format, must be csv or json",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-logs-%s.%s", time.Now().Format("20060102-150405"), format)
+	if format == "json" {
+		ctx.Header("Content-Type", "application/json; charset=utf-8")
+	} else {
+		ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+
+	if format == "json" {
+		err = writeAuditLogsJSON(ctx.Writer, filter)
+	} else {
+		err = writeAuditLogsCSV(ctx.Writer, filter)
+	}
+	if err != nil {
+		logger.Error("Failed to export audit logs: %v", err)
+	}
+}
+
+// writeAuditLogsCSV 将审计日志写为CSV，details列为JSON
+func writeAuditLogsCSV(w io.Writer, filter logging.AuditFilter) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(auditLogCSVHeader); err != nil {
+		return err
+	}
+	err := logging.DefaultLogger.ExportAuditLogs(filter, func(entry logging.AuditLogEntry) error {
+		details := ""
+		if len(entry.Details) > 0 {
+			data, err := json.Marshal(entry.Details)
+			if err != nil {
+				return err
+			}
+			details = string(data)
+		}
+		return writer.Write([]string{
+			entry.Timestamp.Format(time.RFC3339),
+			entry.Level,
+			entry.EventType,
+			csvSafe(entry.User),
+			csvSafe(entry.IP),
+			entry.Action,
+			csvSafe(entry.Resource),
+			entry.Result,
+			csvSafe(entry.Message),
+			csvSafe(details),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeAuditLogsJSON 将审计日志逐条写为JSON数组
+func writeAuditLogsJSON(w io.Writer, filter logging.AuditFilter) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	separator := ""
+	err := logging.DefaultLogger.ExportAuditLogs(filter, func(entry logging.AuditLogEntry) error {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		separator = ","
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
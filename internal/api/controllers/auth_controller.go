@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -81,17 +84,106 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
+	// 获取令牌过期时间，便于前端在过期前主动刷新
+	expiresAt, err := c.jwtManager.GetTokenExpiry(token)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "Failed to generate token",
+		})
+		return
+	}
+
 	// 返回登录成功响应
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Login successful",
 		"data": gin.H{
-			"token":    token,
-			"username": user.Username,
+			"token":     token,
+			"username":  user.Username,
+			"expiresAt": expiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// RefreshToken 刷新令牌
+// 使用仍有效或刚过期（宽限期内）的令牌换取新令牌
+func (c *AuthController) RefreshToken(ctx *gin.Context) {
+	// 获取Authorization头
+	parts := strings.SplitN(ctx.GetHeader("Authorization"), " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    http.StatusUnauthorized,
+			"message": auth.ErrInvalidAuthFormat.Error(),
+		})
+		return
+	}
+
+	// 刷新令牌
+	token, err := c.jwtManager.RefreshToken(parts[1])
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{
+			"code":    http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	expiresAt, err := c.jwtManager.GetTokenExpiry(token)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "Failed to refresh token",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Token refreshed",
+		"data": gin.H{
+			"token":     token,
+			"expiresAt": expiresAt.Format(time.RFC3339),
 		},
 	})
 }
 
+// ChangePassword 修改当前登录用户的密码
+func (c *AuthController) ChangePassword(ctx *gin.Context) {
+	var req struct {
+		OldPassword string `json:"oldPassword" binding:"required"`
+		NewPassword string `json:"newPassword" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request"})
+		return
+	}
+
+	// 用户名由JWT中间件写入上下文
+	username := ctx.GetString("username")
+
+	if err := c.userManager.ChangePassword(username, req.OldPassword, req.NewPassword); err != nil {
+		// 旧密码错误返回403而不是401，避免前端将其视为登录失效
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, auth.ErrIncorrectPassword):
+			statusCode = http.StatusForbidden
+		case errors.Is(err, auth.ErrWeakPassword):
+			statusCode = http.StatusBadRequest
+		}
+		ctx.JSON(statusCode, gin.H{
+			"code":    statusCode,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Password changed successfully",
+	})
+}
+
 // Logout 用户退出登录
 func (c *AuthController) Logout(ctx *gin.Context) {
 	// 获取Authorization头
@@ -101,7 +193,7 @@ func (c *AuthController) Logout(ctx *gin.Context) {
 		// 撤销令牌
 		if err := c.jwtManager.RevokeToken(token); err != nil {
 			// 记录错误但仍返回成功，因为用户意图是退出
-			// logging.DefaultLogger.Warn("Failed to revoke token: %v", err)
+			// logger.Warn("Failed to revoke token: %v", err)
 		}
 	}
 
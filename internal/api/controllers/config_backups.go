@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	appConfig "prerender-shield/internal/config"
+	"prerender-shield/internal/logging"
+)
+
+// ListConfigBackups 列出保存配置前自动创建的配置快照，最新的在前
+func (c *SystemController) ListConfigBackups(ctx *gin.Context) {
+	backups, err := appConfig.GetInstance().ListConfigBackups()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to list config backups: " + err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    backups,
+	})
+}
+
+// RollbackConfig 将配置文件恢复为指定的快照，恢复后按配置文件变化的流程重新加载站点
+func (c *SystemController) RollbackConfig(ctx *gin.Context) {
+	var req struct {
+		ID string `json:"id" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	err := appConfig.GetInstance().RollbackConfig(req.ID)
+	result, message := "success", "Config rolled back to "+req.ID
+	if err != nil {
+		result, message = "failure", err.Error()
+	}
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), "config_rollback", "config",
+		map[string]interface{}{"backup_id": req.ID}, result, message)
+
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, gin.H{
+			"code":    200,
+			"message": message,
+		})
+	case errors.Is(err, appConfig.ErrInvalidConfigBackup), errors.Is(err, appConfig.ErrNoConfigFile):
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+	case errors.Is(err, appConfig.ErrConfigBackupNotFound):
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": err.Error(),
+		})
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to roll back config: " + err.Error(),
+		})
+	}
+}
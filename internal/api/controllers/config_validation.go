@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	appConfig "prerender-shield/internal/config"
+)
+
+// ValidateConfig 校验配置但不保存，请求体为完整配置（包含sites或server字段）或单个站点配置，
+// 返回所有校验错误及其字段路径，供管理界面在提交前提示
+func (c *SystemController) ValidateConfig(ctx *gin.Context) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	var fields map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(body, &fields)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	manager := appConfig.GetInstance()
+	_, hasSites := fields["sites"]
+	_, hasServer := fields["server"]
+	if hasSites || hasServer {
+		// 完整配置使用与配置文件相同的字段名和默认值
+		cfg, parseErr := appConfig.ParseConfig(body)
+		if parseErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "Invalid config: " + parseErr.Error(),
+			})
+			return
+		}
+		err = manager.ValidateConfig(cfg)
+	} else {
+		var site appConfig.SiteConfig
+		if parseErr := json.Unmarshal(body, &site); parseErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "Invalid site config: " + parseErr.Error(),
+			})
+			return
+		}
+		err = manager.ValidateSite(site)
+	}
+
+	validationErrors := appConfig.ValidationErrors{}
+	if err != nil && !errors.As(err, &validationErrors) {
+		validationErrors = appConfig.ValidationErrors{{Message: err.Error()}}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"valid":  len(validationErrors) == 0,
+			"errors": validationErrors,
+		},
+	})
+}
@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"encoding/csv"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -42,7 +46,7 @@ func (c *CrawlerController) GetCrawlerLogs(ctx *gin.Context) {
 	}
 
 	// 获取日志
-	logs, total, err := c.crawlerLogMgr.GetCrawlerLogs(site, startTime, endTime, page, pageSize)
+	logs, total, err := c.crawlerLogMgr.GetCrawlerLogs(site, startTime, endTime, crawlerLogFilter(ctx), page, pageSize)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    http.StatusInternalServerError,
@@ -55,17 +59,18 @@ func (c *CrawlerController) GetCrawlerLogs(ctx *gin.Context) {
 	var items []gin.H
 	for _, log := range logs {
 		items = append(items, gin.H{
-			"id":         log.ID,
-			"site":       log.Site,
-			"ip":         log.IP,
-			"time":       log.Time.Format(time.RFC3339),
-			"hitCache":   log.HitCache,
-			"route":      log.Route,
-			"ua":         log.UA,
-			"status":     log.Status,
-			"method":     log.Method,
-			"cacheTTL":   log.CacheTTL,
-			"renderTime": log.RenderTime,
+			"id":            log.ID,
+			"site":          log.Site,
+			"ip":            log.IP,
+			"time":          log.Time.Format(time.RFC3339),
+			"hitCache":      log.HitCache,
+			"route":         log.Route,
+			"ua":            log.UA,
+			"status":        log.Status,
+			"method":        log.Method,
+			"cacheTTL":      log.CacheTTL,
+			"renderTime":    log.RenderTime,
+			"normalizedUrl": log.NormalizedURL,
 		})
 	}
 
@@ -81,6 +86,82 @@ func (c *CrawlerController) GetCrawlerLogs(ctx *gin.Context) {
 	})
 }
 
+// crawlerLogFilter 从查询参数ua和hitCache解析爬虫日志过滤条件，hitCache为空或无效时不限
+func crawlerLogFilter(ctx *gin.Context) logging.CrawlerLogFilter {
+	filter := logging.CrawlerLogFilter{UA: ctx.Query("ua")}
+	if hitCache, err := strconv.ParseBool(ctx.Query("hitCache")); err == nil {
+		filter.HitCache = &hitCache
+	}
+	return filter
+}
+
+// crawlerLogCSVHeader 爬虫日志CSV导出的列
+var crawlerLogCSVHeader = []string{"time", "site", "ip", "ua", "route", "method", "status", "hitCache", "renderTime"}
+
+// ExportCrawlerLogs 导出时间范围内的全部爬虫日志为CSV文件
+func (c *CrawlerController) ExportCrawlerLogs(ctx *gin.Context) {
+	site := ctx.Query("site")
+	startTime, err := time.Parse(time.RFC3339, ctx.Query("startTime"))
+	if err != nil {
+		startTime = time.Now().Add(-24 * time.Hour)
+	}
+	endTime, err := time.Parse(time.RFC3339, ctx.Query("endTime"))
+	if err != nil {
+		endTime = time.Now()
+	}
+
+	logs, _, err := c.crawlerLogMgr.GetCrawlerLogs(site, startTime, endTime, crawlerLogFilter(ctx), 1, 0)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "Failed to get crawler logs",
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("crawler-logs-%s.csv", time.Now().Format("20060102-150405"))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Status(http.StatusOK)
+	if err := writeCrawlerLogsCSV(ctx.Writer, logs); err != nil {
+		logger.Error("Failed to export crawler logs: %v", err)
+	}
+}
+
+// writeCrawlerLogsCSV 将爬虫日志写为CSV
+func writeCrawlerLogsCSV(w io.Writer, logs []logging.CrawlerLog) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(crawlerLogCSVHeader); err != nil {
+		return err
+	}
+	for _, log := range logs {
+		record := []string{
+			log.Time.Format(time.RFC3339),
+			csvSafe(log.Site),
+			csvSafe(log.IP),
+			csvSafe(log.UA),
+			csvSafe(log.Route),
+			csvSafe(log.Method),
+			strconv.Itoa(log.Status),
+			strconv.FormatBool(log.HitCache),
+			strconv.FormatFloat(log.RenderTime, 'f', -1, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvSafe 防止User-Agent等由访客控制的内容在表格软件中被当作公式执行
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
 // GetCrawlerStats 获取爬虫统计数据
 func (c *CrawlerController) GetCrawlerStats(ctx *gin.Context) {
 	// 获取爬虫统计数据
@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"prerender-shield/internal/logging"
+)
+
+// TestWriteCrawlerLogsCSV 测试爬虫日志CSV的列顺序、转义和公式注入防护
+func TestWriteCrawlerLogsCSV(t *testing.T) {
+	logs := []logging.CrawlerLog{
+		{
+			Time:       time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC),
+			Site:       "site1",
+			IP:         "66.249.66.1",
+			UA:         "Mozilla/5.0 (compatible; Googlebot/2.1)",
+			Route:      "/products?a=1,2",
+			Method:     "GET",
+			Status:     200,
+			HitCache:   true,
+			RenderTime: 1.25,
+		},
+		{
+			Time:   time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+			Site:   "site1",
+			UA:     "=HYPERLINK(\"http://evil\")",
+			Route:  "/",
+			Method: "GET",
+			Status: 500,
+		},
+	}
+
+	var out strings.Builder
+	if err := writeCrawlerLogsCSV(&out, logs); err != nil {
+		t.Fatalf("writeCrawlerLogsCSV failed: %v", err)
+	}
+
+	want := "time,site,ip,ua,route,method,status,hitCache,renderTime\n" +
+		"2024-03-01T08:30:00Z,site1,66.249.66.1,Mozilla/5.0 (compatible; Googlebot/2.1),\"/products?a=1,2\",GET,200,true,1.25\n" +
+		"2024-03-01T09:00:00Z,site1,,\"'=HYPERLINK(\"\"http://evil\"\")\",/,GET,500,false,0\n"
+	if out.String() != want {
+		t.Errorf("unexpected csv:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/prerender"
+)
+
+// EnginesController 汇总各站点在渲染预热和防火墙引擎管理器中的状态
+type EnginesController struct {
+	configManager    *config.ConfigManager
+	prerenderManager *prerender.EngineManager
+	firewallManager  *firewall.EngineManager
+}
+
+// NewEnginesController 创建引擎状态控制器
+func NewEnginesController(configManager *config.ConfigManager, prerenderManager *prerender.EngineManager, firewallManager *firewall.EngineManager) *EnginesController {
+	return &EnginesController{
+		configManager:    configManager,
+		prerenderManager: prerenderManager,
+		firewallManager:  firewallManager,
+	}
+}
+
+// SiteEngineStatus 站点的引擎状态，引擎未运行时对应字段为nil
+// 渲染预热引擎按站点ID注册，防火墙引擎按站点名称注册
+type SiteEngineStatus struct {
+	SiteID          string                  `json:"site_id"`
+	SiteName        string                  `json:"site_name"`
+	Enabled         bool                    `json:"enabled"`
+	FirewallEnabled bool                    `json:"firewall_enabled"`
+	Prerender       *prerender.EngineHealth `json:"prerender"`
+	Firewall        *firewall.EngineHealth  `json:"firewall"`
+}
+
+// OrphanEngines 引擎管理器中没有对应站点配置的引擎键，通常由站点改名或删除后引擎未同步移除导致
+type OrphanEngines struct {
+	Prerender []string `json:"prerender"`
+	Firewall  []string `json:"firewall"`
+}
+
+// collectEngineStatuses 按站点配置查询两个引擎管理器，返回各站点的引擎状态和没有对应站点的引擎
+func collectEngineStatuses(sites []config.SiteConfig, prerenderManager *prerender.EngineManager, firewallManager *firewall.EngineManager) ([]SiteEngineStatus, OrphanEngines) {
+	statuses := make([]SiteEngineStatus, 0, len(sites))
+	siteIDs := make(map[string]bool, len(sites))
+	siteNames := make(map[string]bool, len(sites))
+	for _, site := range sites {
+		siteIDs[site.ID] = true
+		siteNames[site.Name] = true
+
+		status := SiteEngineStatus{
+			SiteID:          site.ID,
+			SiteName:        site.Name,
+			Enabled:         site.IsEnabled(),
+			FirewallEnabled: site.Firewall.Enabled,
+		}
+		if prerenderManager != nil {
+			if engine, exists := prerenderManager.GetEngine(site.ID); exists {
+				health := engine.Health()
+				status.Prerender = &health
+			}
+		}
+		if firewallManager != nil {
+			if engine, exists := firewallManager.GetEngine(site.Name); exists {
+				health := engine.Health()
+				status.Firewall = &health
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	orphans := OrphanEngines{Prerender: []string{}, Firewall: []string{}}
+	if prerenderManager != nil {
+		for _, key := range prerenderManager.ListSites() {
+			if !siteIDs[key] {
+				orphans.Prerender = append(orphans.Prerender, key)
+			}
+		}
+	}
+	if firewallManager != nil {
+		for _, key := range firewallManager.ListSites() {
+			if !siteNames[key] {
+				orphans.Firewall = append(orphans.Firewall, key)
+			}
+		}
+	}
+	sort.Strings(orphans.Prerender)
+	sort.Strings(orphans.Firewall)
+	return statuses, orphans
+}
+
+// ListEngines 返回各站点的渲染预热引擎和防火墙引擎是否在运行及其运行状态，
+// 以及引擎管理器中没有对应站点配置的引擎，用于排查站点ID与名称不一致导致的引擎缺失
+func (c *EnginesController) ListEngines(ctx *gin.Context) {
+	statuses, orphans := collectEngineStatuses(c.configManager.GetConfig().Sites, c.prerenderManager, c.firewallManager)
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"sites":   statuses,
+			"orphans": orphans,
+		},
+	})
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"testing"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/prerender"
+)
+
+// TestCollectEngineStatuses 测试按站点ID查询渲染预热引擎、按站点名称查询防火墙引擎，并列出没有对应站点的引擎
+func TestCollectEngineStatuses(t *testing.T) {
+	prerenderManager := prerender.NewEngineManager(t.TempDir())
+	defer prerenderManager.StopAll()
+	// 不配置浏览器池，避免启动浏览器
+	if err := prerenderManager.AddSite("site-1", prerender.PrerenderConfig{}, nil); err != nil {
+		t.Fatalf("failed to add prerender engine: %v", err)
+	}
+	if err := prerenderManager.AddSite("Site Two", prerender.PrerenderConfig{}, nil); err != nil {
+		t.Fatalf("failed to add prerender engine: %v", err)
+	}
+
+	firewallManager := firewall.NewEngineManager()
+	if err := firewallManager.AddSite("Site One", firewall.Config{}); err != nil {
+		t.Fatalf("failed to add firewall engine: %v", err)
+	}
+	if err := firewallManager.AddSite("Old Name", firewall.Config{}); err != nil {
+		t.Fatalf("failed to add firewall engine: %v", err)
+	}
+
+	sites := []config.SiteConfig{
+		{ID: "site-1", Name: "Site One", Firewall: config.FirewallConfig{Enabled: true}},
+		{ID: "site-2", Name: "Site Two"},
+	}
+	statuses, orphans := collectEngineStatuses(sites, prerenderManager, firewallManager)
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 site statuses, got %d", len(statuses))
+	}
+
+	first := statuses[0]
+	if first.SiteID != "site-1" || !first.Enabled || !first.FirewallEnabled {
+		t.Errorf("unexpected status for site-1: %+v", first)
+	}
+	if first.Prerender == nil || !first.Prerender.Running {
+		t.Errorf("expected running prerender engine for site-1, got %+v", first.Prerender)
+	}
+	if first.Firewall == nil || first.Firewall.Detectors == 0 {
+		t.Errorf("expected firewall engine for site-1, got %+v", first.Firewall)
+	}
+
+	// 引擎注册在错误的键下时，站点显示为未运行，引擎出现在孤立列表中
+	second := statuses[1]
+	if second.Prerender != nil || second.Firewall != nil {
+		t.Errorf("expected no engines for site-2, got %+v", second)
+	}
+	if len(orphans.Prerender) != 1 || orphans.Prerender[0] != "Site Two" {
+		t.Errorf("unexpected prerender orphans: %v", orphans.Prerender)
+	}
+	if len(orphans.Firewall) != 1 || orphans.Firewall[0] != "Old Name" {
+		t.Errorf("unexpected firewall orphans: %v", orphans.Firewall)
+	}
+}
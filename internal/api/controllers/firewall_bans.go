@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/logging"
+)
+
+// engineForSite 根据site查询参数（站点ID）返回站点正在运行的防火墙引擎
+func (c *FirewallController) engineForSite(ctx *gin.Context) (*firewall.Engine, bool) {
+	siteID := ctx.Query("site")
+	if siteID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Site ID is required",
+		})
+		return nil, false
+	}
+
+	for _, site := range c.configManager.GetConfig().Sites {
+		if site.ID != siteID {
+			continue
+		}
+		if c.firewallManager != nil {
+			if engine, exists := c.firewallManager.GetEngine(site.Name); exists {
+				return engine, true
+			}
+		}
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Firewall is not enabled for this site",
+		})
+		return nil, false
+	}
+
+	ctx.JSON(http.StatusNotFound, gin.H{
+		"code":    404,
+		"message": "Site not found",
+	})
+	return nil, false
+}
+
+// ListBans 返回站点因超过频率限制而被封禁的IP
+func (c *FirewallController) ListBans(ctx *gin.Context) {
+	engine, ok := c.engineForSite(ctx)
+	if !ok {
+		return
+	}
+
+	bans, err := engine.ListBans(ctx.Request.Context())
+	if err != nil {
+		logger.Error("Failed to list rate limit bans: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to list bans",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"bans":  bans,
+			"total": len(bans),
+		},
+	})
+}
+
+// LiftBan 解除IP在站点上的频率限制封禁，ip查询参数指定IP
+func (c *FirewallController) LiftBan(ctx *gin.Context) {
+	ip := ctx.Query("ip")
+	if net.ParseIP(ip) == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "A valid IP is required",
+		})
+		return
+	}
+	engine, ok := c.engineForSite(ctx)
+	if !ok {
+		return
+	}
+
+	details := map[string]interface{}{"site_id": ctx.Query("site"), "ip": ip}
+	lifted, err := engine.Unban(ctx.Request.Context(), ip)
+	if err != nil {
+		logger.Error("Failed to lift rate limit ban for %s: %v", ip, err)
+		logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), "lift_ban", "firewall_ban", details, "failure", err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to lift ban",
+		})
+		return
+	}
+	if lifted == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "IP is not banned",
+		})
+		return
+	}
+
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), "lift_ban", "firewall_ban", details, "success", "Rate limit ban lifted")
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Ban lifted",
+		"data": gin.H{
+			"ip":     ip,
+			"lifted": lifted,
+		},
+	})
+}
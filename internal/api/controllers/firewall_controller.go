@@ -5,19 +5,28 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/integrity"
 	"prerender-shield/internal/models"
 	"prerender-shield/internal/repository"
 )
 
 // FirewallController handles WAF configuration requests
 type FirewallController struct {
-	wafRepo *repository.WafRepository
+	wafRepo         *repository.WafRepository
+	configManager   *config.ConfigManager
+	firewallManager *firewall.EngineManager
+	integrity       *integrity.Manager
 }
 
 // NewFirewallController creates a new FirewallController
-func NewFirewallController(wafRepo *repository.WafRepository) *FirewallController {
+func NewFirewallController(wafRepo *repository.WafRepository, configManager *config.ConfigManager, firewallManager *firewall.EngineManager, integrityManager *integrity.Manager) *FirewallController {
 	return &FirewallController{
-		wafRepo: wafRepo,
+		wafRepo:         wafRepo,
+		configManager:   configManager,
+		firewallManager: firewallManager,
+		integrity:       integrityManager,
 	}
 }
 
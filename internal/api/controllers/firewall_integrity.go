@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/integrity"
+)
+
+// GetIntegrity 返回启用网页防篡改的站点的基线状态和文件篡改事件，事件按发现时间从新到旧分页
+// 查询参数：site（站点ID，为空时返回全部站点）、page、pageSize
+func (c *FirewallController) GetIntegrity(ctx *gin.Context) {
+	siteID := ctx.Query("site")
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	if c.integrity == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    http.StatusServiceUnavailable,
+			"message": "File integrity monitoring is not available",
+		})
+		return
+	}
+
+	found := siteID == ""
+	sites := []gin.H{}
+	events := []integrity.Event{}
+	for _, site := range c.configManager.GetConfig().Sites {
+		if siteID != "" && site.ID != siteID {
+			continue
+		}
+		found = true
+
+		item := gin.H{
+			"site":      site.ID,
+			"name":      site.Name,
+			"enabled":   site.FileIntegrityConfig.Enabled,
+			"algorithm": site.FileIntegrityConfig.HashAlgorithm,
+		}
+		if status, exists := c.integrity.GetStatus(site.ID); exists {
+			item["baseline"] = status
+		}
+		sites = append(sites, item)
+
+		siteEvents, err := c.integrity.Events(site.ID)
+		if err != nil {
+			logger.Error("Failed to get integrity events of site %s: %v", site.ID, err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    http.StatusInternalServerError,
+				"message": "Failed to get integrity events",
+			})
+			return
+		}
+		events = append(events, siteEvents...)
+	}
+	if !found {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Site not found",
+		})
+		return
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].DetectedAt.After(events[j].DetectedAt) })
+	total := len(events)
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"sites":    sites,
+			"items":    events[start:end],
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBlockedLogs 获取防火墙拦截日志，包含命中的检测器或规则及威胁详情
+// 查询参数与爬虫日志一致：site（站点ID，为空时查询全部站点）、startTime、endTime（RFC3339，默认最近24小时）、page、pageSize
+func (c *FirewallController) GetBlockedLogs(ctx *gin.Context) {
+	site := ctx.Query("site")
+	startTimeStr := ctx.DefaultQuery("startTime", time.Now().Add(-24*time.Hour).Format(time.RFC3339))
+	endTimeStr := ctx.DefaultQuery("endTime", time.Now().Format(time.RFC3339))
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(ctx.DefaultQuery("pageSize", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	// 解析时间
+	startTime, err := time.Parse(time.RFC3339, startTimeStr)
+	if err != nil {
+		startTime = time.Now().Add(-24 * time.Hour)
+	}
+	endTime, err := time.Parse(time.RFC3339, endTimeStr)
+	if err != nil {
+		endTime = time.Now()
+	}
+
+	if c.wafRepo == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    http.StatusServiceUnavailable,
+			"message": "Firewall logs are not available",
+		})
+		return
+	}
+
+	logs, total, err := c.wafRepo.GetBlockedLogs(site, startTime, endTime, page, pageSize)
+	if err != nil {
+		logger.Error("Failed to get firewall blocked logs: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "Failed to get firewall logs",
+		})
+		return
+	}
+
+	// 转换为前端需要的格式
+	items := make([]gin.H, 0, len(logs))
+	for _, log := range logs {
+		items = append(items, gin.H{
+			"id":         log.ID,
+			"incidentId": log.RequestID,
+			"site":       log.SiteID,
+			"ip":         log.IPAddress,
+			"time":       log.CreatedAt.Format(time.RFC3339),
+			"method":     log.Method,
+			"path":       log.RequestPath,
+			"ua":         log.UserAgent,
+			"status":     log.StatusCode,
+			"action":     log.Action,
+			"ruleId":     log.RuleID,
+			"threatType": log.ThreatType,
+			"reason":     log.Reason,
+			"threats":    log.Threats,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"items":    items,
+			"total":    total,
+			"page":     page,
+			"pageSize": pageSize,
+		},
+	})
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/firewall/types"
+	"prerender-shield/internal/logging"
+)
+
+// ruleManagerForSite 根据site查询参数（站点ID）返回站点的规则管理器。
+// 站点已加载防火墙引擎时使用引擎的规则管理器，否则直接读取站点的规则目录
+func (c *FirewallController) ruleManagerForSite(ctx *gin.Context) (*firewall.RuleManager, bool) {
+	siteID := ctx.Query("site")
+	if siteID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Site ID is required",
+		})
+		return nil, false
+	}
+
+	for _, site := range c.configManager.GetConfig().Sites {
+		if site.ID != siteID {
+			continue
+		}
+		if c.firewallManager != nil {
+			if engine, exists := c.firewallManager.GetEngine(site.Name); exists {
+				return engine.Rules(), true
+			}
+		}
+		ruleManager := firewall.NewRuleManager(site.Firewall.RulesPath)
+		if err := ruleManager.ReloadRules(); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "Failed to load firewall rules: " + err.Error(),
+			})
+			return nil, false
+		}
+		return ruleManager, true
+	}
+
+	ctx.JSON(http.StatusNotFound, gin.H{
+		"code":    404,
+		"message": "Site not found",
+	})
+	return nil, false
+}
+
+// respondRuleError 将规则操作的错误转换为对应的HTTP状态码
+func respondRuleError(ctx *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, firewall.ErrInvalidRule), errors.Is(err, firewall.ErrRulesPathNotConfigured):
+		status = http.StatusBadRequest
+	case errors.Is(err, firewall.ErrRuleNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, firewall.ErrRuleExists):
+		status = http.StatusConflict
+	}
+	ctx.JSON(status, gin.H{
+		"code":    status,
+		"message": err.Error(),
+	})
+}
+
+// applyRuleChange 规则文件修改后重新加载共用该规则目录的站点引擎，清空请求缓存并使检测器使用新规则
+func (c *FirewallController) applyRuleChange(ctx *gin.Context, ruleManager *firewall.RuleManager, action string, rule types.Rule) bool {
+	details := map[string]interface{}{"site_id": ctx.Query("site"), "rule_id": rule.ID, "category": rule.Category}
+	if c.firewallManager != nil {
+		if err := c.firewallManager.ReloadRules(ruleManager.RulesPath()); err != nil {
+			logger.Error("Failed to reload firewall rules: %v", err)
+			logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), action, "firewall_rule", details, "failure", err.Error())
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "Rule saved but failed to reload firewall engines: " + err.Error(),
+			})
+			return false
+		}
+	}
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), action, "firewall_rule", details, "success", "Firewall rules updated")
+	return true
+}
+
+// ListRules 返回站点的自定义防火墙规则，可按category过滤
+func (c *FirewallController) ListRules(ctx *gin.Context) {
+	ruleManager, ok := c.ruleManagerForSite(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"rules":      ruleManager.ListRules(ctx.Query("category")),
+			"categories": firewall.RuleCategories,
+		},
+	})
+}
+
+// AddRule 为站点添加自定义防火墙规则，保存前校验正则表达式
+func (c *FirewallController) AddRule(ctx *gin.Context) {
+	var rule types.Rule
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid request",
+		})
+		return
+	}
+	ruleManager, ok := c.ruleManagerForSite(ctx)
+	if !ok {
+		return
+	}
+
+	saved, err := ruleManager.AddRule(rule)
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	if !c.applyRuleChange(ctx, ruleManager, "firewall_rule_add", saved) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Rule added successfully",
+		"data":    saved,
+	})
+}
+
+// UpdateRule 更新站点的自定义防火墙规则
+func (c *FirewallController) UpdateRule(ctx *gin.Context) {
+	var rule types.Rule
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid request",
+		})
+		return
+	}
+	ruleManager, ok := c.ruleManagerForSite(ctx)
+	if !ok {
+		return
+	}
+
+	saved, err := ruleManager.UpdateRule(ctx.Param("ruleId"), rule)
+	if err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	if !c.applyRuleChange(ctx, ruleManager, "firewall_rule_update", saved) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Rule updated successfully",
+		"data":    saved,
+	})
+}
+
+// DeleteRule 删除站点的自定义防火墙规则
+func (c *FirewallController) DeleteRule(ctx *gin.Context) {
+	ruleManager, ok := c.ruleManagerForSite(ctx)
+	if !ok {
+		return
+	}
+
+	ruleID := ctx.Param("ruleId")
+	if err := ruleManager.DeleteRule(ruleID); err != nil {
+		respondRuleError(ctx, err)
+		return
+	}
+	if !c.applyRuleChange(ctx, ruleManager, "firewall_rule_delete", types.Rule{ID: ruleID}) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Rule deleted successfully",
+	})
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/logging"
+)
+
+// logger API模块的日志记录器，日志级别可以单独设置
+var logger = logging.Module("api")
+
+// GetLogLevel 返回默认日志级别和单独设置了级别的模块
+func (c *SystemController) GetLogLevel(ctx *gin.Context) {
+	level, modules := logging.DefaultLogger.Levels()
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"level":   level,
+			"modules": modules,
+		},
+	})
+}
+
+// SetLogLevel 在运行时修改日志级别，不需要重启，module为空时修改默认级别；修改不写入配置文件，重启后恢复为配置的级别
+func (c *SystemController) SetLogLevel(ctx *gin.Context) {
+	var req struct {
+		Module string `json:"module"`
+		Level  string `json:"level" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	if err := logging.DefaultLogger.SetLevel(req.Module, req.Level); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), "log_level_update", "logging",
+		map[string]interface{}{"module": req.Module, "level": req.Level}, "success", "Log level updated")
+
+	level, modules := logging.DefaultLogger.Levels()
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"level":   level,
+			"modules": modules,
+		},
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"prerender-shield/internal/config"
 	"prerender-shield/internal/monitoring"
 )
 
@@ -30,3 +31,28 @@ func (c *MonitoringController) GetStats(ctx *gin.Context) {
 		"data":    stats,
 	})
 }
+
+// GetSiteStats 获取单个站点的请求数、爬虫请求数、拦截数、延迟分位数和缓存命中率
+func (c *MonitoringController) GetSiteStats(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var site *config.SiteConfig
+	for _, s := range config.GetInstance().GetConfig().Sites {
+		if s.ID == id {
+			site = &s
+			break
+		}
+	}
+	if site == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Site not found",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    c.monitor.GetSiteStats(site.ID),
+	})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,29 +11,71 @@ import (
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/monitoring"
 	"prerender-shield/internal/repository"
+	siteserver "prerender-shield/internal/site-server"
 	"prerender-shield/internal/utils/country"
 )
 
+// defaultOverviewCacheTTL 概览数据默认缓存时间
+const defaultOverviewCacheTTL = 5 * time.Second
+
 // OverviewController 概览控制器
 type OverviewController struct {
 	cfg         *config.Config
 	monitor     *monitoring.Monitor
 	visitLogMgr *logging.VisitLogManager
 	wafRepo     *repository.WafRepository
+
+	// 概览数据缓存，多个管理员轮询仪表盘时共享同一次统计结果
+	cacheMutex sync.Mutex
+	cacheTTL   time.Duration
+	cachedData gin.H
+	cachedAt   time.Time
+	compute    func() gin.H // 计算概览数据，默认为buildOverview
 }
 
 // NewOverviewController 创建概览控制器实例
 func NewOverviewController(cfg *config.Config, monitor *monitoring.Monitor, visitLogMgr *logging.VisitLogManager, wafRepo *repository.WafRepository) *OverviewController {
-	return &OverviewController{
+	cacheTTL := defaultOverviewCacheTTL
+	if cfg != nil && cfg.Server.OverviewCacheTTL > 0 {
+		cacheTTL = time.Duration(cfg.Server.OverviewCacheTTL) * time.Second
+	}
+	c := &OverviewController{
 		cfg:         cfg,
 		monitor:     monitor,
 		visitLogMgr: visitLogMgr,
 		wafRepo:     wafRepo,
+		cacheTTL:    cacheTTL,
 	}
+	c.compute = c.buildOverview
+	return c
 }
 
 // GetOverview 获取概览信息
+// 缓存有效期内直接返回缓存结果，refresh=true时强制重新统计
 func (c *OverviewController) GetOverview(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    c.getOverviewData(ctx.Query("refresh") == "true"),
+	})
+}
+
+// getOverviewData 获取概览数据，计算期间持有锁，使并发请求等待并共享同一次计算结果
+func (c *OverviewController) getOverviewData(forceRefresh bool) gin.H {
+	c.cacheMutex.Lock()
+	defer c.cacheMutex.Unlock()
+
+	if !forceRefresh && c.cachedData != nil && time.Since(c.cachedAt) < c.cacheTTL {
+		return c.cachedData
+	}
+
+	c.cachedData = c.compute()
+	c.cachedAt = time.Now()
+	return c.cachedData
+}
+
+// buildOverview 统计概览数据
+func (c *OverviewController) buildOverview() gin.H {
 	// 计算总防火墙和渲染预热启用状态
 	firewallEnabled := false
 	prerenderEnabled := false
@@ -64,7 +107,7 @@ func (c *OverviewController) GetOverview(ctx *gin.Context) {
 
 	// 获取站点统计数据
 	activeSites := len(c.cfg.Sites)
-	sslCertificates := 0 // SSL功能已移除
+	sslCertificates := countValidCertificates(c.cfg.Sites, c.cfg.Dirs.CertsDir)
 
 	// 获取地理位置统计数据
 	geoStats, _ := c.visitLogMgr.GetVisitStats("", time.Now().Add(-24*time.Hour), time.Now())
@@ -72,26 +115,18 @@ func (c *OverviewController) GetOverview(ctx *gin.Context) {
 	// 获取PV/UV/IP统计数据
 	pv, uv, ip := c.visitLogMgr.GetAccessStats(time.Now(), time.Now())
 
-	// 获取流量趋势数据
+	// 获取流量趋势数据，没有访问统计时为空数组
+	// 爬虫和拦截请求没有按时段的统计，趋势中保持为0，只在总数中体现
 	trafficData := c.visitLogMgr.GetTrafficTrend(time.Now(), time.Now())
-	
-	// 简单的流量趋势补充：爬虫和拦截请求（按比例分配或者简单的平均，因为暂时没有小时级的爬虫/拦截统计）
-	// TODO: 实现小时级的爬虫和拦截统计
+
 	crawlerTotal := int64(stats["crawlerRequests"].(float64))
-	blockedTotal := blockedRequests
-	
-	// 将总数分配到各个时间段（平滑分配，仅作为展示）
-	// 注意：这是一个临时的展示策略，直到我们有真实的时间序列数据
-	if len(trafficData) > 0 {
-		avgCrawler := crawlerTotal / int64(len(trafficData))
-		avgBlocked := blockedTotal / int64(len(trafficData))
-		for i := range trafficData {
-			// 如果该时段有总请求，则显示爬虫和拦截（但不超过总请求）
-			// 这里仅仅是简单的模拟分布，真实数据需要 CrawlerLogManager 支持 GetTrafficTrend
-			trafficData[i].CrawlerRequests = avgCrawler
-			trafficData[i].BlockedRequests = avgBlocked
-		}
+
+	// 各站点的请求、延迟和缓存命中统计
+	siteStats := make([]gin.H, 0, len(c.cfg.Sites))
+	for _, site := range c.cfg.Sites {
+		siteStats = append(siteStats, gin.H{"name": site.Name, "stats": c.monitor.GetSiteStats(site.ID)})
 	}
+	blockedTotal := blockedRequests
 
 	// 处理Globe数据和国家数据
 	globeData := make([]gin.H, 0)
@@ -125,30 +160,38 @@ func (c *OverviewController) GetOverview(ctx *gin.Context) {
 		countryData = append(countryData, gin.H{"country": k, "count": v, "color": "#1890ff"})
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"code":    200,
-		"message": "success",
-		"data": gin.H{
-			"totalRequests":    totalRequests,
-			"crawlerRequests":  crawlerTotal,
-			"blockedRequests":  blockedTotal,
-			"cacheHitRate":     float64(int(stats["cacheHitRate"].(float64)*100)) / 100, // 保留两位小数
-			"activeBrowsers":   int(stats["activeBrowsers"].(float64)),
-			"activeSites":      activeSites,
-			"sslCertificates":  sslCertificates,
-			"firewallEnabled":  firewallEnabled,
-			"prerenderEnabled": prerenderEnabled,
-			"geoData": gin.H{
-				"countryData": countryData,
-				"mapData":     mapData,
-				"globeData":   globeData,
-			},
-			"trafficData": trafficData,
-			"accessStats": gin.H{
-				"pv": pv,
-				"uv": uv,
-				"ip": ip,
-			},
+	return gin.H{
+		"totalRequests":    totalRequests,
+		"crawlerRequests":  crawlerTotal,
+		"blockedRequests":  blockedTotal,
+		"cacheHitRate":     float64(int(stats["cacheHitRate"].(float64)*100)) / 100, // 保留两位小数
+		"activeBrowsers":   int(stats["activeBrowsers"].(float64)),
+		"activeSites":      activeSites,
+		"siteStats":        siteStats,
+		"sslCertificates":  sslCertificates,
+		"firewallEnabled":  firewallEnabled,
+		"prerenderEnabled": prerenderEnabled,
+		"geoData": gin.H{
+			"countryData": countryData,
+			"mapData":     mapData,
+			"globeData":   globeData,
 		},
-	})
+		"trafficData": trafficData,
+		"accessStats": gin.H{
+			"pv": pv,
+			"uv": uv,
+			"ip": ip,
+		},
+	}
+}
+
+// countValidCertificates 统计启用HTTPS且证书可以正常加载的站点数量，包括已签发的ACME证书
+func countValidCertificates(sites []config.SiteConfig, certsDir string) int {
+	count := 0
+	for _, site := range sites {
+		if info := siteserver.InspectCertificate(site, certsDir); info != nil && info.Error == "" {
+			count++
+		}
+	}
+	return count
 }
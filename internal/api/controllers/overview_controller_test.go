@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/config"
+)
+
+// TestOverviewCache 测试缓存有效期内的重复请求只触发一次统计，refresh=true时强制重新统计
+func TestOverviewCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c := NewOverviewController(&config.Config{}, nil, nil, nil)
+	computations := 0
+	c.compute = func() gin.H {
+		computations++
+		return gin.H{"activeSites": computations}
+	}
+
+	r := gin.New()
+	r.GET("/overview", c.GetOverview)
+	request := func(target string) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", target, nil)
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+
+	request("/overview")
+	request("/overview")
+	if computations != 1 {
+		t.Errorf("expected 1 computation for rapid polls, got %d", computations)
+	}
+
+	request("/overview?refresh=true")
+	if computations != 2 {
+		t.Errorf("expected forced refresh to recompute, got %d computations", computations)
+	}
+}
+
+// TestCountValidCertificates 测试只统计证书可以正常加载的HTTPS站点
+func TestCountValidCertificates(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+
+	sites := []config.SiteConfig{
+		{ID: "https", TLS: config.SiteTLSConfig{Enabled: true, CertPEM: certPEM, KeyPEM: keyPEM}},
+		{ID: "broken", TLS: config.SiteTLSConfig{Enabled: true, CertPEM: "invalid", KeyPEM: keyPEM}},
+		{ID: "acme-pending", TLS: config.SiteTLSConfig{ACME: true}},
+		{ID: "http"},
+	}
+	if count := countValidCertificates(sites, t.TempDir()); count != 1 {
+		t.Errorf("expected 1 valid certificate, got %d", count)
+	}
+}
@@ -11,12 +11,14 @@ import (
 	"prerender-shield/internal/config"
 	"prerender-shield/internal/prerender"
 	"prerender-shield/internal/redis"
+	"prerender-shield/internal/scheduler"
 )
 
 // PreheatController 预热控制器
 type PreheatController struct {
 	prerenderManager *prerender.EngineManager
 	redisClient      *redis.Client
+	scheduler        *scheduler.Scheduler
 	cfg              *config.Config
 }
 
@@ -24,11 +26,13 @@ type PreheatController struct {
 func NewPreheatController(
 	prerenderManager *prerender.EngineManager,
 	redisClient *redis.Client,
+	scheduler *scheduler.Scheduler,
 	cfg *config.Config,
 ) *PreheatController {
 	return &PreheatController{
 		prerenderManager: prerenderManager,
 		redisClient:      redisClient,
+		scheduler:        scheduler,
 		cfg:              cfg,
 	}
 }
@@ -251,27 +255,7 @@ func (c *PreheatController) TriggerPreheat(ctx *gin.Context) {
 	}
 
 	// 构建正确的baseURL和Domain
-	var baseURL, domain string
-	switch siteConfig.Mode {
-	case "proxy":
-		// 代理模式下，使用代理的目标URL
-		baseURL = siteConfig.Proxy.TargetURL
-		domain = siteConfig.Proxy.TargetURL
-	case "redirect":
-		// 重定向模式下，使用重定向的目标URL
-		baseURL = siteConfig.Redirect.TargetURL
-		domain = siteConfig.Redirect.TargetURL
-	default:
-		// 静态模式下，使用站点的域名和端口
-		if len(siteConfig.Domains) > 0 {
-			domain = fmt.Sprintf("%s:%d", siteConfig.Domains[0], siteConfig.Port)
-			baseURL = fmt.Sprintf("http://%s", domain)
-		} else {
-			// 默认使用localhost
-			domain = fmt.Sprintf("localhost:%d", siteConfig.Port)
-			baseURL = fmt.Sprintf("http://%s", domain)
-		}
-	}
+	baseURL, domain := siteConfig.PreheatTarget()
 
 	// 调用引擎的触发预热方法，传递正确的baseURL和Domain
 	_, err := engine.TriggerPreheatWithURL(baseURL, domain)
@@ -438,10 +422,20 @@ func (c *PreheatController) GetPreheatTaskStatus(ctx *gin.Context) {
 
 	if siteId == "" {
 		// 获取所有站点的任务状态
+		statuses := []gin.H{}
+		if c.cfg != nil {
+			for _, site := range c.cfg.Sites {
+				engine, exists := c.prerenderManager.GetEngine(site.ID)
+				if !exists {
+					continue
+				}
+				statuses = append(statuses, c.preheatTaskStatus(site.ID, engine))
+			}
+		}
 		ctx.JSON(http.StatusOK, gin.H{
 			"code":    200,
 			"message": "success",
-			"data":    []gin.H{},
+			"data":    statuses,
 		})
 		return
 	}
@@ -456,18 +450,71 @@ func (c *PreheatController) GetPreheatTaskStatus(ctx *gin.Context) {
 		return
 	}
 
-	// 获取预热状态
-	status := engine.GetPreheatStatus()
-
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "success",
-		"data": gin.H{
-			"siteId":    siteId,
-			"isRunning": status["isRunning"],
-			"scheduled": false,
-			"nextRun":   "",
-		},
+		"data":    c.preheatTaskStatus(siteId, engine),
+	})
+}
+
+// preheatTaskStatus 返回站点的预热任务进度和定时预热的下次执行时间
+func (c *PreheatController) preheatTaskStatus(siteId string, engine *prerender.Engine) gin.H {
+	// 获取预热任务进度
+	job := engine.GetPreheatStatus()
+
+	// 获取定时预热的下次执行时间
+	scheduled, nextRun := false, ""
+	if c.scheduler != nil {
+		if ok, next := c.scheduler.GetTaskStatus(siteId); ok {
+			scheduled, nextRun = true, next
+		}
+	}
+
+	return gin.H{
+		"siteId":    siteId,
+		"isRunning": job != nil && job.Status == prerender.PreheatJobRunning,
+		"job":       job,
+		"scheduled": scheduled,
+		"nextRun":   nextRun,
+	}
+}
+
+// CancelPreheat 取消站点正在运行的预热任务
+func (c *PreheatController) CancelPreheat(ctx *gin.Context) {
+	var req struct {
+		SiteId string `json:"siteId" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	// 获取站点的预渲染引擎
+	engine, exists := c.prerenderManager.GetEngine(req.SiteId)
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    http.StatusNotFound,
+			"message": fmt.Sprintf("Site with ID '%s' not found", req.SiteId),
+		})
+		return
+	}
+
+	if !engine.CancelPreheat() {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code":    http.StatusConflict,
+			"message": "没有正在运行的预热任务",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Preheat canceled successfully",
+		"data":    engine.GetPreheatStatus(),
 	})
 }
 
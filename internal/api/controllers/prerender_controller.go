@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/prerender"
+	"prerender-shield/internal/redis"
+)
+
+// PrerenderController 渲染引擎配置控制器
+type PrerenderController struct {
+	prerenderManager *prerender.EngineManager
+	configManager    *config.ConfigManager
+	redisClient      *redis.Client
+}
+
+// NewPrerenderController 创建渲染引擎配置控制器实例
+func NewPrerenderController(
+	prerenderManager *prerender.EngineManager,
+	configManager *config.ConfigManager,
+	redisClient *redis.Client,
+) *PrerenderController {
+	return &PrerenderController{
+		prerenderManager: prerenderManager,
+		configManager:    configManager,
+		redisClient:      redisClient,
+	}
+}
+
+// GetCrawlerHeaders 获取站点实际生效的爬虫协议头列表（默认+自定义，已去重）
+func (c *PrerenderController) GetCrawlerHeaders(ctx *gin.Context) {
+	siteID := ctx.Query("site")
+	if siteID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "site is required",
+		})
+		return
+	}
+
+	engine, exists := c.prerenderManager.GetEngine(siteID)
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    http.StatusNotFound,
+			"message": fmt.Sprintf("Prerender engine for site '%s' not found", siteID),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"site":    siteID,
+			"headers": engine.GetCrawlerHeaders(),
+			"default": engine.GetDefaultCrawlerHeaders(),
+			"custom":  engine.GetCustomCrawlerHeaders(),
+		},
+	})
+}
+
+// DetectCrawler 检测指定User-Agent是否会被站点识别为爬虫，用于排查预渲染未生效的问题
+func (c *PrerenderController) DetectCrawler(ctx *gin.Context) {
+	var req struct {
+		Site      string `json:"site" binding:"required"`
+		UserAgent string `json:"userAgent" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	engine, exists := c.prerenderManager.GetEngine(req.Site)
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    http.StatusNotFound,
+			"message": fmt.Sprintf("Prerender engine for site '%s' not found", req.Site),
+		})
+		return
+	}
+
+	matchedHeader, isCrawler := engine.MatchCrawlerHeader(req.UserAgent)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"site":          req.Site,
+			"userAgent":     req.UserAgent,
+			"isCrawler":     isCrawler,
+			"matchedHeader": matchedHeader,
+		},
+	})
+}
+
+// UpdateCrawlerHeaders 更新站点自定义爬虫协议头，同时更新引擎配置并持久化
+func (c *PrerenderController) UpdateCrawlerHeaders(ctx *gin.Context) {
+	siteID := ctx.Query("site")
+	if siteID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "site is required",
+		})
+		return
+	}
+
+	var req struct {
+		Headers []string `json:"headers"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	// 更新运行中的引擎配置，立即对爬虫识别生效
+	headers, err := c.prerenderManager.UpdateCrawlerHeaders(siteID, req.Headers)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    http.StatusNotFound,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	engine, _ := c.prerenderManager.GetEngine(siteID)
+	custom := engine.GetCustomCrawlerHeaders()
+
+	// 持久化到站点配置
+	currentConfig := c.configManager.GetConfig()
+	for i := range currentConfig.Sites {
+		if currentConfig.Sites[i].ID == siteID {
+			currentConfig.Sites[i].Prerender.CrawlerHeaders = custom
+			break
+		}
+	}
+	if err := c.configManager.SaveConfig(); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "Failed to save site configuration",
+		})
+		return
+	}
+
+	// 同步Redis中的预渲染配置
+	if c.redisClient != nil {
+		if err := c.redisClient.SetSiteStats(siteID+"_prerender", map[string]interface{}{
+			"crawler_headers": strings.Join(custom, "\n"),
+		}); err != nil {
+			logger.Error("Failed to save crawler headers to Redis: %v", err)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Crawler headers updated successfully",
+		"data": gin.H{
+			"site":    siteID,
+			"headers": headers,
+			"custom":  custom,
+		},
+	})
+}
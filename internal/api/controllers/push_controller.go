@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/logging"
 	"prerender-shield/internal/prerender/push"
 	"prerender-shield/internal/redis"
 )
@@ -100,7 +101,7 @@ func (c *PushController) GetPushStats(ctx *gin.Context) {
 			stats, err := c.pushManager.GetPushStats(site.ID)
 			if err != nil {
 				// 记录错误但不中断处理
-				fmt.Printf("Failed to get push stats for site %s: %v\n", site.ID, err)
+				logger.Error("Failed to get push stats for site %s: %v", site.ID, err)
 				continue
 			}
 
@@ -267,3 +268,65 @@ func (c *PushController) UpdatePushConfig(ctx *gin.Context) {
 		"message": "Push config updated successfully",
 	})
 }
+
+// TriggerPush 手动触发站点推送，dryRun=true时只返回本次将推送的URL，不实际提交
+func (c *PushController) TriggerPush(ctx *gin.Context) {
+	siteID := ctx.Query("siteId")
+	if siteID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "siteId is required",
+		})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(ctx.Query("dryRun"))
+	task, err := c.pushManager.TriggerPush(siteID, dryRun)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": fmt.Sprintf("触发推送失败: %v", err),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    task,
+	})
+}
+
+// MarkAllPushed 将站点所有URL标记为已推送，内容变化前不再推送这些URL
+func (c *PushController) MarkAllPushed(ctx *gin.Context) {
+	siteID := ctx.Query("siteId")
+	if siteID == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"message": "siteId is required",
+		})
+		return
+	}
+
+	marked, err := c.pushManager.MarkAllPushed(siteID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": fmt.Sprintf("标记已推送失败: %v", err),
+		})
+		return
+	}
+
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), "push_mark_all", "push", map[string]interface{}{
+		"site_id": siteID,
+		"marked":  marked,
+	}, "success", "All URLs marked as pushed")
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"marked": marked,
+		},
+	})
+}
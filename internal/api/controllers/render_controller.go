@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/prerender"
+)
+
+// RenderController 兼容prerender.io中间件的渲染API控制器
+// 中间件以 GET /render?url=https://example.com/page 请求渲染结果，按URL主机名匹配站点
+type RenderController struct {
+	prerenderManager *prerender.EngineManager
+	configManager    *config.ConfigManager
+	path             string
+}
+
+// NewRenderController 创建渲染API控制器实例，接口路径在启动时确定
+func NewRenderController(prerenderManager *prerender.EngineManager, configManager *config.ConfigManager) *RenderController {
+	path := "/render"
+	if cfg := configManager.GetConfig(); cfg != nil && cfg.RenderAPI.Path != "" {
+		path = "/" + strings.TrimLeft(cfg.RenderAPI.Path, "/")
+	}
+
+	return &RenderController{
+		prerenderManager: prerenderManager,
+		configManager:    configManager,
+		path:             path,
+	}
+}
+
+// Path 返回渲染API的接口路径
+func (c *RenderController) Path() string {
+	return c.path
+}
+
+// Enabled 返回是否启用渲染API
+func (c *RenderController) Enabled() bool {
+	cfg := c.configManager.GetConfig()
+	return cfg != nil && cfg.RenderAPI.Enabled
+}
+
+// Render 渲染指定URL并直接返回HTML
+func (c *RenderController) Render(ctx *gin.Context) {
+	cfg := c.configManager.GetConfig()
+	if cfg == nil || !cfg.RenderAPI.Enabled {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Render API is disabled",
+		})
+		return
+	}
+
+	// 配置了令牌时校验X-Prerender-Token请求头
+	if cfg.RenderAPI.Token != "" {
+		token := ctx.GetHeader("X-Prerender-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.RenderAPI.Token)) != 1 {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "Invalid prerender token",
+			})
+			return
+		}
+	}
+
+	rawURL := ctx.Query("url")
+	targetURL, err := url.Parse(rawURL)
+	if rawURL == "" || err != nil || (targetURL.Scheme != "http" && targetURL.Scheme != "https") || targetURL.Hostname() == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid url parameter, an absolute http(s) URL is required",
+		})
+		return
+	}
+
+	site := cfg.FindSiteByHost(targetURL.Host)
+	if site == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "No site configured for host " + targetURL.Hostname(),
+		})
+		return
+	}
+
+	var engine *prerender.Engine
+	if c.prerenderManager != nil {
+		engine, _ = c.prerenderManager.GetEngine(site.ID)
+	}
+	if engine == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    503,
+			"message": "Prerender engine not available for site " + site.Name,
+		})
+		return
+	}
+
+	resultWithCache, err := engine.Render(ctx.Request.Context(), targetURL.String(), prerender.RenderOptions{
+		Timeout:   site.Prerender.Timeout,
+		WaitUntil: "networkidle0",
+		Crawler:   engine.CrawlerFamily(ctx.GetHeader("User-Agent")),
+	})
+	// 页面不是HTML且开启了原样返回时，返回源站的原始内容和Content-Type
+	if err == nil && resultWithCache.Result.Error == prerender.RenderErrorNotHTML && resultWithCache.Result.Body != nil {
+		status := http.StatusOK
+		if resultWithCache.Result.StatusCode >= http.StatusBadRequest {
+			status = resultWithCache.Result.StatusCode
+		}
+		ctx.Data(status, resultWithCache.Result.ContentType, resultWithCache.Result.Body)
+		return
+	}
+	if err != nil || !resultWithCache.Result.Success {
+		message := "Prerender failed"
+		if err != nil {
+			message = err.Error()
+		} else if resultWithCache.Result.Error != "" {
+			message = resultWithCache.Result.Error
+		}
+		logger.Warn("Render API failed for %s: %s", targetURL.String(), message)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": message,
+		})
+		return
+	}
+
+	cacheStatus := "MISS"
+	if resultWithCache.HitCache {
+		cacheStatus = "HIT"
+	}
+	ctx.Header("X-Prerender-Cache", cacheStatus)
+	// 与prerender.io一致，源站错误状态码原样返回
+	status := http.StatusOK
+	if resultWithCache.Result.StatusCode >= http.StatusBadRequest {
+		status = resultWithCache.Result.StatusCode
+	}
+	ctx.Data(status, "text/html; charset=utf-8", []byte(resultWithCache.Result.HTML))
+}
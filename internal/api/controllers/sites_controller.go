@@ -1,37 +1,54 @@
 package controllers
 
 import (
-	"archive/zip"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"prerender-shield/internal/acme"
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/integrity"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/monitoring"
+	"prerender-shield/internal/prerender"
 	"prerender-shield/internal/redis"
+	"prerender-shield/internal/scanner"
 	sitehandler "prerender-shield/internal/site-handler"
+	siteruntime "prerender-shield/internal/site-runtime"
 	siteserver "prerender-shield/internal/site-server"
+	"prerender-shield/internal/utils"
 )
 
 // SitesController 站点管理控制器
 type SitesController struct {
-	configManager *config.ConfigManager
-	siteServerMgr *siteserver.Manager
-	siteHandler   *sitehandler.Handler
-	redisClient   *redis.Client
-	monitor       *monitoring.Monitor
-	crawlerLogMgr *logging.CrawlerLogManager
-	visitLogMgr   *logging.VisitLogManager
-	cfg           *config.Config
+	configManager    *config.ConfigManager
+	siteServerMgr    *siteserver.Manager
+	siteHandler      *sitehandler.Handler
+	prerenderManager *prerender.EngineManager
+	firewallManager  *firewall.EngineManager
+	acmeManager      *acme.Manager
+	integrityManager *integrity.Manager
+	redisClient      *redis.Client
+	monitor          *monitoring.Monitor
+	crawlerLogMgr    *logging.CrawlerLogManager
+	visitLogMgr      *logging.VisitLogManager
+	cfg              *config.Config
+	extractLimiter   *utils.ExtractionLimiter // 限制同时进行的解压任务，避免磁盘和CPU争用
+	uploadScanner    *scanner.Guard           // 上传文件和解压内容的病毒扫描，未启用时为nil
+	releaseMu        sync.Mutex               // 串行化站点目录的发布切换和回滚
+	usageCache       staticUsageCache         // 站点静态目录磁盘占用的短期缓存
+	runtime          *siteruntime.Runtime     // 启动、停止和热更新运行中的站点，与配置文件热重载共用
 }
 
 // NewSitesController 创建站点管理控制器实例
@@ -39,6 +56,10 @@ func NewSitesController(
 	configManager *config.ConfigManager,
 	siteServerMgr *siteserver.Manager,
 	siteHandler *sitehandler.Handler,
+	prerenderManager *prerender.EngineManager,
+	firewallManager *firewall.EngineManager,
+	acmeManager *acme.Manager,
+	integrityManager *integrity.Manager,
 	redisClient *redis.Client,
 	monitor *monitoring.Monitor,
 	crawlerLogMgr *logging.CrawlerLogManager,
@@ -46,29 +67,262 @@ func NewSitesController(
 	cfg *config.Config,
 ) *SitesController {
 	return &SitesController{
-		configManager: configManager,
-		siteServerMgr: siteServerMgr,
-		siteHandler:   siteHandler,
-		redisClient:   redisClient,
-		monitor:       monitor,
-		crawlerLogMgr: crawlerLogMgr,
-		visitLogMgr:   visitLogMgr,
-		cfg:           cfg,
+		configManager:    configManager,
+		siteServerMgr:    siteServerMgr,
+		siteHandler:      siteHandler,
+		prerenderManager: prerenderManager,
+		firewallManager:  firewallManager,
+		acmeManager:      acmeManager,
+		integrityManager: integrityManager,
+		redisClient:      redisClient,
+		monitor:          monitor,
+		crawlerLogMgr:    crawlerLogMgr,
+		visitLogMgr:      visitLogMgr,
+		cfg:              cfg,
+		extractLimiter:   utils.NewExtractionLimiter(cfg.Server.MaxConcurrentExtractions),
+		uploadScanner:    scanner.New(cfg.Server.UploadScan, filepath.Join(cfg.Dirs.DataDir, "quarantine")),
+		runtime: siteruntime.New(siteServerMgr, siteHandler, prerenderManager, firewallManager, redisClient,
+			crawlerLogMgr, visitLogMgr, monitor, cfg.Server.Address, cfg.Dirs.StaticDir),
 	}
 }
 
-// GetSites 获取站点列表
+// siteWithStatus 站点配置及其服务器运行状态
+type siteWithStatus struct {
+	config.SiteConfig
+	RuntimeStatus *siteserver.SiteStatus      `json:"runtime_status,omitempty"`
+	Certificate   *siteserver.CertificateInfo `json:"certificate,omitempty"` // 启用HTTPS的站点的证书信息
+	ACMEStatus    *acme.Status                `json:"acme_status,omitempty"` // 启用ACME的站点的证书签发状态
+	// 代理模式站点各后端的健康状态
+	UpstreamHealth []sitehandler.UpstreamStatus `json:"upstream_health,omitempty"`
+}
+
+// GetSites 获取站点列表，包含各站点服务器的运行状态
 func (c *SitesController) GetSites(ctx *gin.Context) {
 	// 从配置管理器获取当前配置
 	currentConfig := c.configManager.GetConfig()
+	statuses := c.siteServerMgr.ListSiteStatuses()
+
+	sites := make([]siteWithStatus, 0, len(currentConfig.Sites))
+	for _, site := range currentConfig.Sites {
+		item := siteWithStatus{
+			SiteConfig:  site,
+			Certificate: siteserver.InspectCertificate(site, c.cfg.Dirs.CertsDir),
+		}
+		if !site.IsEnabled() {
+			item.RuntimeStatus = &siteserver.SiteStatus{State: siteserver.SiteStateDisabled}
+		} else if status, exists := statuses[site.ID]; exists {
+			item.RuntimeStatus = &status
+		}
+		if c.acmeManager != nil && site.TLS.ACME {
+			if status, exists := c.acmeManager.GetStatus(site.ID); exists {
+				item.ACMEStatus = &status
+			}
+		}
+		sites = append(sites, item)
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "success",
-		"data":    currentConfig.Sites,
+		"data":    sites,
 	})
 }
 
-// GetSite 获取单个站点信息
+// RestartSite 重启站点服务器，用于端口冲突排除后手动重试
+func (c *SitesController) RestartSite(ctx *gin.Context) {
+	id := ctx.Param("id")
+	currentConfig := c.configManager.GetConfig()
+	var site *config.SiteConfig
+	for i := range currentConfig.Sites {
+		if currentConfig.Sites[i].ID == id {
+			site = &currentConfig.Sites[i]
+			break
+		}
+	}
+	if site == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Site not found",
+		})
+		return
+	}
+
+	if !site.IsEnabled() {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Site is disabled",
+		})
+		return
+	}
+
+	siteHandler := c.siteHandler.CreateSiteHandler(*site, c.crawlerLogMgr, c.visitLogMgr, c.monitor, c.cfg.Dirs.StaticDir)
+	if err := c.siteServerMgr.RestartSiteServer(*site, c.cfg.Server.Address, siteHandler); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to restart site server: " + err.Error(),
+		})
+		return
+	}
+
+	status, _ := c.siteServerMgr.GetSiteStatus(id)
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Site restarted successfully",
+		"data":    status,
+	})
+}
+
+// maxCertificateSize 上传的证书或私钥文件大小上限
+const maxCertificateSize = 1 << 20
+
+// UploadSiteTLS 上传或替换站点证书，校验私钥与证书匹配且证书覆盖站点的所有域名后为站点启用HTTPS
+// 支持JSON请求体（cert、key字段为PEM内容）或multipart表单（cert、key文件）
+func (c *SitesController) UploadSiteTLS(ctx *gin.Context) {
+	id := ctx.Param("id")
+	certPEM, keyPEM, err := readCertificateUpload(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	currentConfig := c.configManager.GetConfig()
+	var site *config.SiteConfig
+	for i := range currentConfig.Sites {
+		if currentConfig.Sites[i].ID == id {
+			site = &currentConfig.Sites[i]
+			break
+		}
+	}
+	if site == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Site not found",
+		})
+		return
+	}
+
+	info, err := siteserver.ValidateCertificate(certPEM, keyPEM, site.Domains)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 证书保存在证书目录下以站点ID命名的子目录中
+	certDir := filepath.Join(c.cfg.Dirs.CertsDir, site.ID)
+	if err := os.MkdirAll(certDir, 0700); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to create certificate directory",
+		})
+		return
+	}
+	if err := os.WriteFile(filepath.Join(certDir, config.SiteCertFileName), certPEM, 0644); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to save certificate",
+		})
+		return
+	}
+	if err := os.WriteFile(filepath.Join(certDir, config.SiteKeyFileName), keyPEM, 0600); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to save private key",
+		})
+		return
+	}
+
+	// 手动上传的证书替代ACME自动签发
+	oldSite := *site
+	site.TLS = config.SiteTLSConfig{
+		Enabled:  true,
+		CertFile: filepath.Join(site.ID, config.SiteCertFileName),
+		KeyFile:  filepath.Join(site.ID, config.SiteKeyFileName),
+	}
+	if err := c.configManager.SaveConfig(); err != nil {
+		*site = oldSite
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to save site configuration",
+		})
+		return
+	}
+
+	// 替换运行中站点的证书，端口不变时不需要重启服务器
+	if err := c.applySiteUpdate(oldSite, site); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to apply site configuration: " + err.Error(),
+		})
+		return
+	}
+
+	logging.DefaultLogger.LogAdminAction(
+		"admin",
+		ctx.ClientIP(),
+		"site_tls_upload",
+		"site",
+		map[string]interface{}{
+			"site_id":   site.ID,
+			"site_name": site.Name,
+			"not_after": info.NotAfter,
+		},
+		"success",
+		"Site certificate updated successfully",
+	)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Site certificate updated successfully",
+		"data":    info,
+	})
+}
+
+// readCertificateUpload 读取上传的证书和私钥PEM内容
+func readCertificateUpload(ctx *gin.Context) (certPEM, keyPEM []byte, err error) {
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		if certPEM, err = readUploadedFile(ctx, "cert"); err != nil {
+			return nil, nil, err
+		}
+		if keyPEM, err = readUploadedFile(ctx, "key"); err != nil {
+			return nil, nil, err
+		}
+		return certPEM, keyPEM, nil
+	}
+
+	var req struct {
+		Cert string `json:"cert"`
+		Key  string `json:"key"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Cert == "" || req.Key == "" {
+		return nil, nil, fmt.Errorf("certificate and private key are required")
+	}
+	return []byte(req.Cert), []byte(req.Key), nil
+}
+
+// readUploadedFile 读取multipart表单中的文件内容
+func readUploadedFile(ctx *gin.Context, field string) ([]byte, error) {
+	fileHeader, err := ctx.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("missing %s file", field)
+	}
+	if fileHeader.Size > maxCertificateSize {
+		return nil, fmt.Errorf("%s file is too large", field)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file", field)
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// GetSite 获取单个站点信息，代理模式站点包含各后端的健康状态
 func (c *SitesController) GetSite(ctx *gin.Context) {
 	id := ctx.Param("id")
 	// 从配置管理器获取当前配置
@@ -78,7 +332,10 @@ func (c *SitesController) GetSite(ctx *gin.Context) {
 			ctx.JSON(http.StatusOK, gin.H{
 				"code":    200,
 				"message": "success",
-				"data":    site,
+				"data": siteWithStatus{
+					SiteConfig:     site,
+					UpstreamHealth: c.siteHandler.UpstreamStatus(site.ID),
+				},
 			})
 			return
 		}
@@ -153,16 +410,20 @@ func (c *SitesController) AddSite(ctx *gin.Context) {
 		return
 	}
 
-	// 验证域名：只允许127.0.0.1或localhost
-	for _, domain := range site.Domains {
-		if domain != "127.0.0.1" && domain != "localhost" {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"code":    400,
-				"message": "Only 127.0.0.1 or localhost are allowed as domains",
-			})
-			return
-		}
+	// 未设置的渲染预热和防火墙配置项使用全局默认值
+	currentConfig := c.configManager.GetConfig()
+	site.ApplyDefaults(currentConfig.DefaultPrerenderConfig, currentConfig.DefaultFirewallConfig)
+
+	// 验证域名格式，并检查是否已被其他站点使用
+	domains, err := c.validateDomains(site.Domains, "")
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
 	}
+	site.Domains = domains
 
 	// 验证端口是否可用
 	if !isPortAvailable(site.Port) {
@@ -176,8 +437,7 @@ func (c *SitesController) AddSite(ctx *gin.Context) {
 	// 为新站点生成唯一ID
 	site.ID = uuid.New().String()
 
-	// 从配置管理器获取当前配置并更新
-	currentConfig := c.configManager.GetConfig()
+	// 更新当前配置
 	currentConfig.Sites = append(currentConfig.Sites, site)
 
 	// 保存配置到文件
@@ -189,11 +449,12 @@ func (c *SitesController) AddSite(ctx *gin.Context) {
 		return
 	}
 
-	// 启动新站点的服务器实例
-	siteHandler := c.siteHandler.CreateSiteHandler(site, c.crawlerLogMgr, c.visitLogMgr, c.monitor, c.cfg.Dirs.StaticDir)
-
-	// 启动站点服务器
-	c.siteServerMgr.StartSiteServer(site, c.cfg.Server.Address, c.cfg.Dirs.StaticDir, c.crawlerLogMgr, siteHandler)
+	// 启动新站点的引擎和服务器实例，停用的站点只保存配置
+	if site.IsEnabled() {
+		if err := c.runtime.Start(site); err != nil {
+			logger.Error("Failed to start server for site %s: %v", site.ID, err)
+		}
+	}
 
 	// 保存站点配置到Redis
 	if c.redisClient != nil {
@@ -205,7 +466,7 @@ func (c *SitesController) AddSite(ctx *gin.Context) {
 			"mode":   site.Mode,
 		}
 		if err := c.redisClient.SetSiteStats(site.ID, stats); err != nil {
-			logging.DefaultLogger.Warn("Failed to save site stats to Redis: %v", err)
+			logger.Warn("Failed to save site stats to Redis: %v", err)
 		}
 
 		// 保存预渲染配置（扁平化结构，不使用嵌套map）
@@ -225,22 +486,25 @@ func (c *SitesController) AddSite(ctx *gin.Context) {
 			"crawler_headers":     strings.Join(site.Prerender.CrawlerHeaders, "\n"),
 		}
 		if err := c.redisClient.SetSiteStats(site.ID+"_prerender", preheatConfig); err != nil {
-			logging.DefaultLogger.Warn("Failed to save prerender config to Redis: %v", err)
+			logger.Warn("Failed to save prerender config to Redis: %v", err)
 		}
 
 		// 保存推送配置
 		pushConfig := map[string]interface{}{
-			"enabled":           site.Prerender.Push.Enabled,
-			"baidu_api":         site.Prerender.Push.BaiduAPI,
-			"baidu_token":       site.Prerender.Push.BaiduToken,
-			"bing_api":          site.Prerender.Push.BingAPI,
-			"bing_token":        site.Prerender.Push.BingToken,
-			"baidu_daily_limit": site.Prerender.Push.BaiduDailyLimit,
-			"bing_daily_limit":  site.Prerender.Push.BingDailyLimit,
-			"push_domain":       site.Prerender.Push.PushDomain,
+			"enabled":            site.Prerender.Push.Enabled,
+			"baidu_api":          site.Prerender.Push.BaiduAPI,
+			"baidu_token":        site.Prerender.Push.BaiduToken,
+			"bing_api":           site.Prerender.Push.BingAPI,
+			"bing_token":         site.Prerender.Push.BingToken,
+			"baidu_daily_limit":  site.Prerender.Push.BaiduDailyLimit,
+			"bing_daily_limit":   site.Prerender.Push.BingDailyLimit,
+			"batch_size":         site.Prerender.Push.BatchSize,
+			"max_attempts":       site.Prerender.Push.MaxAttempts,
+			"google_daily_limit": site.Prerender.Push.GoogleDailyLimit,
+			"push_domain":        site.Prerender.Push.PushDomain,
 		}
 		if err := c.redisClient.SetSiteStats(site.ID+"_push", pushConfig); err != nil {
-			logging.DefaultLogger.Warn("Failed to save push config to Redis: %v", err)
+			logger.Warn("Failed to save push config to Redis: %v", err)
 		}
 
 		// 保存WAF配置
@@ -258,7 +522,7 @@ func (c *SitesController) AddSite(ctx *gin.Context) {
 			"whitelist":           strings.Join(site.Firewall.Whitelist, ","),
 		}
 		if err := c.redisClient.SetSiteStats(site.ID+"_waf", wafConfig); err != nil {
-			logging.DefaultLogger.Warn("Failed to save WAF config to Redis: %v", err)
+			logger.Warn("Failed to save WAF config to Redis: %v", err)
 		}
 	}
 
@@ -298,16 +562,16 @@ func (c *SitesController) UpdateSite(ctx *gin.Context) {
 		return
 	}
 
-	// 验证域名：只允许127.0.0.1或localhost
-	for _, domain := range siteUpdates.Domains {
-		if domain != "127.0.0.1" && domain != "localhost" {
-			ctx.JSON(http.StatusBadRequest, gin.H{
-				"code":    400,
-				"message": "Only 127.0.0.1 or localhost are allowed as domains",
-			})
-			return
-		}
+	// 验证域名格式，并检查是否已被其他站点使用
+	domains, err := c.validateDomains(siteUpdates.Domains, id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
 	}
+	siteUpdates.Domains = domains
 
 	// 从配置管理器获取当前配置
 	currentConfig := c.configManager.GetConfig()
@@ -343,6 +607,10 @@ func (c *SitesController) UpdateSite(ctx *gin.Context) {
 			currentConfig.Sites[i].Prerender = siteUpdates.Prerender
 			currentConfig.Sites[i].Routing = siteUpdates.Routing
 			currentConfig.Sites[i].FileIntegrityConfig = siteUpdates.FileIntegrityConfig
+			currentConfig.Sites[i].VisitLogScope = siteUpdates.VisitLogScope
+			currentConfig.Sites[i].RefererFilter = siteUpdates.RefererFilter
+			currentConfig.Sites[i].ProbePaths = siteUpdates.ProbePaths
+			currentConfig.Sites[i].ExcludePatterns = siteUpdates.ExcludePatterns
 
 			// 获取更新后的站点
 			updatedSite = &currentConfig.Sites[i]
@@ -368,17 +636,15 @@ func (c *SitesController) UpdateSite(ctx *gin.Context) {
 		return
 	}
 
-	// 停止旧的站点服务器
-	if _, exists := c.siteServerMgr.GetSiteServer(oldSite.ID); exists {
-		c.siteServerMgr.StopSiteServer(oldSite.ID)
+	// 热更新站点服务器和引擎，不中断正在处理的请求
+	if err := c.applySiteUpdate(*oldSite, updatedSite); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to apply site configuration: " + err.Error(),
+		})
+		return
 	}
 
-	// 启动新的站点服务器
-	siteHandler := c.siteHandler.CreateSiteHandler(*updatedSite, c.crawlerLogMgr, c.visitLogMgr, c.monitor, c.cfg.Dirs.StaticDir)
-
-	// 启动站点服务器
-	c.siteServerMgr.StartSiteServer(*updatedSite, c.cfg.Server.Address, c.cfg.Dirs.StaticDir, c.crawlerLogMgr, siteHandler)
-
 	// 保存站点配置到Redis
 	if c.redisClient != nil {
 		// 保存站点统计信息
@@ -389,7 +655,7 @@ func (c *SitesController) UpdateSite(ctx *gin.Context) {
 			"mode":   updatedSite.Mode,
 		}
 		if err := c.redisClient.SetSiteStats(updatedSite.ID, stats); err != nil {
-			logging.DefaultLogger.Warn("Failed to save site stats to Redis: %v", err)
+			logger.Warn("Failed to save site stats to Redis: %v", err)
 		}
 
 		// 保存预渲染配置（扁平化结构，不使用嵌套map）
@@ -409,24 +675,27 @@ func (c *SitesController) UpdateSite(ctx *gin.Context) {
 			"crawler_headers":     strings.Join(updatedSite.Prerender.CrawlerHeaders, "\n"),
 		}
 		if err := c.redisClient.SetSiteStats(updatedSite.ID+"_prerender", preheatConfig); err != nil {
-			logging.DefaultLogger.Error("Failed to save prerender config to Redis: %v", err)
+			logger.Error("Failed to save prerender config to Redis: %v", err)
 		} else {
-			logging.DefaultLogger.Info("Pre-render config saved to Redis successfully")
+			logger.Info("Pre-render config saved to Redis successfully")
 		}
 
 		// 保存推送配置
 		pushConfig := map[string]interface{}{
-			"enabled":           updatedSite.Prerender.Push.Enabled,
-			"baidu_api":         updatedSite.Prerender.Push.BaiduAPI,
-			"baidu_token":       updatedSite.Prerender.Push.BaiduToken,
-			"bing_api":          updatedSite.Prerender.Push.BingAPI,
-			"bing_token":        updatedSite.Prerender.Push.BingToken,
-			"baidu_daily_limit": updatedSite.Prerender.Push.BaiduDailyLimit,
-			"bing_daily_limit":  updatedSite.Prerender.Push.BingDailyLimit,
-			"push_domain":       updatedSite.Prerender.Push.PushDomain,
+			"enabled":            updatedSite.Prerender.Push.Enabled,
+			"baidu_api":          updatedSite.Prerender.Push.BaiduAPI,
+			"baidu_token":        updatedSite.Prerender.Push.BaiduToken,
+			"bing_api":           updatedSite.Prerender.Push.BingAPI,
+			"bing_token":         updatedSite.Prerender.Push.BingToken,
+			"baidu_daily_limit":  updatedSite.Prerender.Push.BaiduDailyLimit,
+			"bing_daily_limit":   updatedSite.Prerender.Push.BingDailyLimit,
+			"batch_size":         updatedSite.Prerender.Push.BatchSize,
+			"max_attempts":       updatedSite.Prerender.Push.MaxAttempts,
+			"google_daily_limit": updatedSite.Prerender.Push.GoogleDailyLimit,
+			"push_domain":        updatedSite.Prerender.Push.PushDomain,
 		}
 		if err := c.redisClient.SetSiteStats(updatedSite.ID+"_push", pushConfig); err != nil {
-			logging.DefaultLogger.Warn("Failed to save push config to Redis: %v", err)
+			logger.Warn("Failed to save push config to Redis: %v", err)
 		}
 
 		// 保存WAF配置
@@ -444,7 +713,7 @@ func (c *SitesController) UpdateSite(ctx *gin.Context) {
 			"whitelist":           strings.Join(updatedSite.Firewall.Whitelist, ","),
 		}
 		if err := c.redisClient.SetSiteStats(updatedSite.ID+"_waf", wafConfig); err != nil {
-			logging.DefaultLogger.Warn("Failed to save WAF config to Redis: %v", err)
+			logger.Warn("Failed to save WAF config to Redis: %v", err)
 		}
 	}
 
@@ -524,12 +793,14 @@ func (c *SitesController) UpdateSitePrerenderConfig(ctx *gin.Context) {
 		return
 	}
 
-	// 重启站点服务器
-	if _, exists := c.siteServerMgr.GetSiteServer(oldSite.ID); exists {
-		c.siteServerMgr.StopSiteServer(oldSite.ID)
+	// 热更新站点服务器和引擎
+	if err := c.applySiteUpdate(*oldSite, updatedSite); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to apply site configuration: " + err.Error(),
+		})
+		return
 	}
-	siteHandler := c.siteHandler.CreateSiteHandler(*updatedSite, c.crawlerLogMgr, c.visitLogMgr, c.monitor, c.cfg.Dirs.StaticDir)
-	c.siteServerMgr.StartSiteServer(*updatedSite, c.cfg.Server.Address, c.cfg.Dirs.StaticDir, c.crawlerLogMgr, siteHandler)
 
 	// 保存预渲染配置到Redis
 	if c.redisClient != nil {
@@ -549,7 +820,7 @@ func (c *SitesController) UpdateSitePrerenderConfig(ctx *gin.Context) {
 			"crawler_headers":     strings.Join(updatedSite.Prerender.CrawlerHeaders, "\n"),
 		}
 		if err := c.redisClient.SetSiteStats(updatedSite.ID+"_prerender", preheatConfig); err != nil {
-			logging.DefaultLogger.Error("Failed to save prerender config to Redis: %v", err)
+			logger.Error("Failed to save prerender config to Redis: %v", err)
 		}
 	}
 
@@ -601,25 +872,30 @@ func (c *SitesController) UpdateSitePushConfig(ctx *gin.Context) {
 		return
 	}
 
-	if _, exists := c.siteServerMgr.GetSiteServer(oldSite.ID); exists {
-		c.siteServerMgr.StopSiteServer(oldSite.ID)
+	if err := c.applySiteUpdate(*oldSite, updatedSite); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to apply site configuration: " + err.Error(),
+		})
+		return
 	}
-	siteHandler := c.siteHandler.CreateSiteHandler(*updatedSite, c.crawlerLogMgr, c.visitLogMgr, c.monitor, c.cfg.Dirs.StaticDir)
-	c.siteServerMgr.StartSiteServer(*updatedSite, c.cfg.Server.Address, c.cfg.Dirs.StaticDir, c.crawlerLogMgr, siteHandler)
 
 	if c.redisClient != nil {
 		pushConfig := map[string]interface{}{
-			"enabled":           updatedSite.Prerender.Push.Enabled,
-			"baidu_api":         updatedSite.Prerender.Push.BaiduAPI,
-			"baidu_token":       updatedSite.Prerender.Push.BaiduToken,
-			"bing_api":          updatedSite.Prerender.Push.BingAPI,
-			"bing_token":        updatedSite.Prerender.Push.BingToken,
-			"baidu_daily_limit": updatedSite.Prerender.Push.BaiduDailyLimit,
-			"bing_daily_limit":  updatedSite.Prerender.Push.BingDailyLimit,
-			"push_domain":       updatedSite.Prerender.Push.PushDomain,
+			"enabled":            updatedSite.Prerender.Push.Enabled,
+			"baidu_api":          updatedSite.Prerender.Push.BaiduAPI,
+			"baidu_token":        updatedSite.Prerender.Push.BaiduToken,
+			"bing_api":           updatedSite.Prerender.Push.BingAPI,
+			"bing_token":         updatedSite.Prerender.Push.BingToken,
+			"baidu_daily_limit":  updatedSite.Prerender.Push.BaiduDailyLimit,
+			"bing_daily_limit":   updatedSite.Prerender.Push.BingDailyLimit,
+			"batch_size":         updatedSite.Prerender.Push.BatchSize,
+			"max_attempts":       updatedSite.Prerender.Push.MaxAttempts,
+			"google_daily_limit": updatedSite.Prerender.Push.GoogleDailyLimit,
+			"push_domain":        updatedSite.Prerender.Push.PushDomain,
 		}
 		if err := c.redisClient.SetSiteStats(updatedSite.ID+"_push", pushConfig); err != nil {
-			logging.DefaultLogger.Warn("Failed to save push config to Redis: %v", err)
+			logger.Warn("Failed to save push config to Redis: %v", err)
 		}
 	}
 
@@ -671,11 +947,13 @@ func (c *SitesController) UpdateSiteFirewallConfig(ctx *gin.Context) {
 		return
 	}
 
-	if _, exists := c.siteServerMgr.GetSiteServer(oldSite.ID); exists {
-		c.siteServerMgr.StopSiteServer(oldSite.ID)
+	if err := c.applySiteUpdate(*oldSite, updatedSite); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to apply site configuration: " + err.Error(),
+		})
+		return
 	}
-	siteHandler := c.siteHandler.CreateSiteHandler(*updatedSite, c.crawlerLogMgr, c.visitLogMgr, c.monitor, c.cfg.Dirs.StaticDir)
-	c.siteServerMgr.StartSiteServer(*updatedSite, c.cfg.Server.Address, c.cfg.Dirs.StaticDir, c.crawlerLogMgr, siteHandler)
 
 	if c.redisClient != nil {
 		wafConfig := map[string]interface{}{
@@ -692,7 +970,7 @@ func (c *SitesController) UpdateSiteFirewallConfig(ctx *gin.Context) {
 			"whitelist":           strings.Join(updatedSite.Firewall.Whitelist, ","),
 		}
 		if err := c.redisClient.SetSiteStats(updatedSite.ID+"_waf", wafConfig); err != nil {
-			logging.DefaultLogger.Warn("Failed to save WAF config to Redis: %v", err)
+			logger.Warn("Failed to save WAF config to Redis: %v", err)
 		}
 	}
 
@@ -715,27 +993,32 @@ func (c *SitesController) DeleteSite(ctx *gin.Context) {
 		if site.ID == id {
 			// 停止站点服务器
 			c.siteServerMgr.StopSiteServer(site.ID)
+			c.siteHandler.RemoveSite(site.ID)
 
 			// 删除Redis中的站点数据
 			if c.redisClient != nil {
 				if err := c.redisClient.DeleteSiteData(site.ID); err != nil {
-					logging.DefaultLogger.Warn("Failed to delete site data from Redis for site %s: %v", site.Name, err)
+					logger.Warn("Failed to delete site data from Redis for site %s: %v", site.Name, err)
 				} else {
-					logging.DefaultLogger.Info("Deleted site data from Redis for site %s", site.Name)
+					logger.Info("Deleted site data from Redis for site %s", site.Name)
 				}
 			}
 
-			// 删除站点的静态资源目录
+			// 删除站点的静态资源目录，只读目录由外部提供，保留不删除
 			staticDir := filepath.Join(c.cfg.Dirs.StaticDir, site.ID)
-			if _, err := os.Stat(staticDir); err == nil {
+			if _, err := os.Stat(staticDir); err == nil && !site.StaticReadOnly {
 				// 目录存在，删除它
 				if err := os.RemoveAll(staticDir); err != nil {
-					log.Printf("Failed to delete static files for site %s: %v", site.Name, err)
+					logger.Error("Failed to delete static files for site %s: %v", site.Name, err)
 					// 继续执行，不中断删除流程
 				} else {
-					log.Printf("Deleted static files for site %s", site.Name)
+					logger.Info("Deleted static files for site %s", site.Name)
 				}
 			}
+			removeSiteVersionDirs(c.cfg.Dirs.StaticDir, site.ID)
+			if c.integrityManager != nil {
+				c.integrityManager.RemoveSite(site.ID)
+			}
 
 			// 从切片中删除站点
 			currentConfig.Sites = append(currentConfig.Sites[:i], currentConfig.Sites[i+1:]...)
@@ -809,7 +1092,10 @@ func (c *SitesController) GetStaticFiles(ctx *gin.Context) {
 	siteStaticDir := filepath.Join(c.cfg.Dirs.StaticDir, site.ID)
 
 	// 构建完整的文件路径
-	filePath := filepath.Join(siteStaticDir, path)
+	filePath, ok := resolveStaticPath(ctx, siteStaticDir, path)
+	if !ok {
+		return
+	}
 
 	// 检查文件路径是否存在
 	fileInfo, err := os.Stat(filePath)
@@ -885,6 +1171,9 @@ func (c *SitesController) GetStaticFiles(ctx *gin.Context) {
 
 // UploadStaticFile 上传静态资源文件
 func (c *SitesController) UploadStaticFile(ctx *gin.Context) {
+	if !c.requireWritableStatic(ctx) {
+		return
+	}
 	id := ctx.Param("id")
 	path := ctx.PostForm("path")
 
@@ -921,8 +1210,20 @@ func (c *SitesController) UploadStaticFile(ctx *gin.Context) {
 		return
 	}
 
+	// 只接受允许的文件类型，避免脚本或可执行文件被放入站点目录
+	if err := utils.CheckUploadExtension(file.Filename, c.cfg.Server.UploadAllowedExtensions, c.cfg.Server.UploadBlockedExtensions); err != nil {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"code":    415,
+			"message": fmt.Sprintf("Unsupported file type: %v", err),
+		})
+		return
+	}
+
 	// 构建完整的文件路径，包含文件名
-	filePath := filepath.Join(siteStaticDir, path, file.Filename)
+	filePath, ok := resolveStaticPath(ctx, siteStaticDir, filepath.Join(path, file.Filename))
+	if !ok {
+		return
+	}
 
 	// 确保目录存在
 	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
@@ -933,8 +1234,44 @@ func (c *SitesController) UploadStaticFile(ctx *gin.Context) {
 		return
 	}
 
-	// 保存文件
-	if err := ctx.SaveUploadedFile(file, filePath); err != nil {
+	// 先保存到与站点目录同级的临时目录，病毒扫描通过后再移入站点目录，未扫描的文件不会被访问
+	stagingDir := newStagingDir(c.cfg.Dirs.StaticDir, site.ID)
+	defer os.RemoveAll(stagingDir)
+	stagedPath := filepath.Join(stagingDir, filepath.Base(filePath))
+	if err := os.MkdirAll(stagingDir, 0755); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to create directory",
+		})
+		return
+	}
+	if err := ctx.SaveUploadedFile(file, stagedPath); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to save file",
+		})
+		return
+	}
+
+	findings, err := c.uploadScanner.ScanDir(ctx.Request.Context(), site.ID, stagingDir)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": fmt.Sprintf("Failed to scan file: %v", err),
+		})
+		return
+	}
+	if len(findings) > 0 {
+		logStaticAction(ctx, "static_quarantine", map[string]interface{}{"path": filepath.Join(path, file.Filename), "findings": findings}, "failure", "Uploaded file quarantined")
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    422,
+			"message": "File was flagged by the virus scan and has been quarantined",
+			"data":    findings,
+		})
+		return
+	}
+
+	if err := os.Rename(stagedPath, filePath); err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "Failed to save file",
@@ -942,6 +1279,7 @@ func (c *SitesController) UploadStaticFile(ctx *gin.Context) {
 		return
 	}
 
+	c.rebuildIntegrityBaseline(site.ID)
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "File uploaded successfully",
@@ -950,9 +1288,25 @@ func (c *SitesController) UploadStaticFile(ctx *gin.Context) {
 
 // ExtractFile 解压文件
 func (c *SitesController) ExtractFile(ctx *gin.Context) {
+	if !c.requireWritableStatic(ctx) {
+		return
+	}
 	id := ctx.Param("id")
 	fileName := ctx.PostForm("filename")
 	path := ctx.PostForm("path")
+	// 去掉归档条目开头的目录层数，如归档内容都在dist/下时传1
+	stripComponents := 0
+	if value := ctx.PostForm("strip_components"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "strip_components must be a non-negative integer",
+			})
+			return
+		}
+		stripComponents = n
+	}
 
 	// 从配置管理器获取当前配置
 	currentConfig := c.configManager.GetConfig()
@@ -984,7 +1338,10 @@ func (c *SitesController) ExtractFile(ctx *gin.Context) {
 	}
 
 	// 构建完整的文件路径
-	filePath := filepath.Join(siteStaticDir, cleanPath, fileName)
+	filePath, ok := resolveStaticPath(ctx, siteStaticDir, filepath.Join(cleanPath, fileName))
+	if !ok {
+		return
+	}
 
 	// 检查文件是否存在
 	_, err := os.Stat(filePath)
@@ -997,13 +1354,16 @@ func (c *SitesController) ExtractFile(ctx *gin.Context) {
 	}
 
 	// 构建解压目标目录
-	destDir := filepath.Join(siteStaticDir, cleanPath)
+	destDir, ok := resolveStaticPath(ctx, siteStaticDir, cleanPath)
+	if !ok {
+		return
+	}
 
 	// 根据文件扩展名选择解压方法
-	if !strings.HasSuffix(strings.ToLower(fileName), ".zip") {
+	if !utils.IsSupportedArchive(fileName) {
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
-			"message": "Only ZIP files are supported for extraction",
+			"message": "Only ZIP, tar.gz/tgz, tar and RAR files are supported for extraction",
 		})
 		return
 	}
@@ -1016,14 +1376,114 @@ func (c *SitesController) ExtractFile(ctx *gin.Context) {
 		})
 		return
 	}
-	// 解压ZIP文件
-	if err := ExtractZIP(filePath, destDir); err != nil {
+	// 获取解压名额，同时进行的解压任务达到上限时排队等待，客户端断开时放弃
+	queued := false
+	if err := c.extractLimiter.Acquire(ctx.Request.Context(), func() {
+		queued = true
+		logger.Info("Extraction of %s queued, %d running, %d queued", filePath, c.extractLimiter.Running(), c.extractLimiter.Queued())
+	}); err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"code":    503,
+			"message": fmt.Sprintf("Extraction canceled while queued: %v", err),
+		})
+		return
+	}
+	defer c.extractLimiter.Release()
+
+	// 解压到站点根目录视为整站发布：先解压到临时目录，校验通过后再切换，
+	// 避免访问者和爬虫看到解压了一半的站点；解压到子目录时直接写入，
+	// 启用病毒扫描时同样先解压到临时目录，扫描后再移入子目录
+	root, _ := utils.SecurePath(siteStaticDir, "")
+	deploy := destDir == root
+	extractDir := destDir
+	if deploy || c.uploadScanner != nil {
+		extractDir = newStagingDir(c.cfg.Dirs.StaticDir, site.ID)
+	}
+	staged := extractDir != destDir
+
+	// 解压归档文件，超过大小或文件数上限时停止并清理已写入的文件
+	stats, err := utils.ExtractArchiveWithOptions(filePath, extractDir, utils.ExtractOptions{
+		MaxBytes:        int64(c.cfg.Server.MaxExtractSizeMB) << 20,
+		MaxFiles:        c.cfg.Server.MaxExtractFiles,
+		StripComponents: stripComponents,
+		// 归档中的文件同样只接受允许的文件类型，不能借解压绕过上传检查
+		CheckExtensions:   true,
+		AllowedExtensions: c.cfg.Server.UploadAllowedExtensions,
+		BlockedExtensions: c.cfg.Server.UploadBlockedExtensions,
+	})
+	if err != nil && staged {
+		os.RemoveAll(extractDir)
+	}
+	if errors.Is(err, utils.ErrUploadExtensionNotAllowed) {
+		ctx.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"code":    415,
+			"message": fmt.Sprintf("Unsupported file type in archive: %v", err),
+		})
+		return
+	}
+	if errors.Is(err, utils.ErrArchiveTooLarge) || errors.Is(err, utils.ErrArchiveTooManyFiles) {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"code":    413,
+			"message": fmt.Sprintf("Failed to extract archive: %v", err),
+			"data":    stats,
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": fmt.Sprintf("Failed to extract archive: %v", err),
+		})
+		return
+	}
+
+	// 扫描解压出的文件，发现病毒的文件移到隔离目录，其余文件照常发布
+	findings, err := c.uploadScanner.ScanDir(ctx.Request.Context(), site.ID, extractDir)
+	if err != nil {
+		os.RemoveAll(extractDir)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": fmt.Sprintf("Failed to extract ZIP file: %v", err),
+			"message": fmt.Sprintf("Failed to scan extracted files: %v", err),
 		})
 		return
 	}
+	if len(findings) > 0 {
+		logStaticAction(ctx, "static_quarantine", map[string]interface{}{"archive": filepath.Join(cleanPath, fileName), "findings": findings}, "failure", "Extracted files quarantined")
+	}
+
+	if staged && !deploy {
+		if err := moveStagedFiles(extractDir, destDir); err != nil {
+			os.RemoveAll(extractDir)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": fmt.Sprintf("Failed to move extracted files: %v", err),
+			})
+			return
+		}
+	}
+
+	if deploy {
+		details := map[string]interface{}{"archive": filepath.Join(cleanPath, fileName)}
+		if info, err := os.Stat(filepath.Join(extractDir, "index.html")); err != nil || info.IsDir() {
+			os.RemoveAll(extractDir)
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "Archive must contain index.html at its root to be deployed",
+			})
+			return
+		}
+		if err := c.deployStaticRelease(site.ID, extractDir); err != nil {
+			os.RemoveAll(extractDir)
+			logStaticAction(ctx, "static_deploy", details, "failure", err.Error())
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": fmt.Sprintf("Failed to deploy release: %v", err),
+			})
+			return
+		}
+		c.invalidateSiteCache(site.ID)
+		logStaticAction(ctx, "static_deploy", details, "success", "Release deployed")
+	}
 
 	// 将提取的文件信息存储到Redis中
 	if c.redisClient != nil {
@@ -1048,15 +1508,15 @@ func (c *SitesController) ExtractFile(ctx *gin.Context) {
 		})
 
 		if walkErr != nil {
-			log.Printf("Failed to walk extracted files: %v", walkErr)
+			logger.Error("Failed to walk extracted files: %v", walkErr)
 		} else {
 			// 将收集到的URL存储到Redis中
 			for _, url := range htmlFiles {
 				if err := c.redisClient.AddURL(site.ID, url); err != nil {
-					log.Printf("Failed to add URL to Redis: %v", err)
+					logger.Error("Failed to add URL to Redis: %v", err)
 					continue
 				}
-				log.Printf("Added URL to Redis: %s", url)
+				logger.Debug("Added URL to Redis: %s", url)
 			}
 			// 更新站点统计信息
 			if len(htmlFiles) > 0 {
@@ -1065,18 +1525,158 @@ func (c *SitesController) ExtractFile(ctx *gin.Context) {
 					"url_count": len(htmlFiles),
 				}
 				if err := c.redisClient.SetSiteStats(site.ID, stats); err != nil {
-					log.Printf("Failed to update site stats: %v", err)
+					logger.Error("Failed to update site stats: %v", err)
 				}
 			}
 		}
 	}
 
+	c.rebuildIntegrityBaseline(site.ID)
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "File extracted successfully",
+		"data": gin.H{
+			"queued":   queued, // 是否曾排队等待解压名额
+			"files":    stats.Files,
+			"bytes":    stats.Bytes,
+			"deployed": deploy, // 是否作为整站发布切换了站点目录
+			// 病毒扫描隔离的文件
+			"quarantined": findings,
+		},
+	})
+}
+
+// applySiteUpdate 将站点配置变更应用到运行中的站点，不中断正在处理的请求，热更新失败时恢复旧配置
+func (c *SitesController) applySiteUpdate(oldSite config.SiteConfig, updatedSite *config.SiteConfig) error {
+	// 停用的站点没有运行中的服务器和引擎，启用时会按最新配置启动
+	if !updatedSite.IsEnabled() {
+		return nil
+	}
+
+	if err := c.runtime.Update(oldSite, *updatedSite); err != nil {
+		*updatedSite = oldSite
+		if saveErr := c.configManager.SaveConfig(); saveErr != nil {
+			logger.Error("Failed to restore configuration of site %s: %v", oldSite.ID, saveErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// EnableSite 启用站点，启动站点的引擎和服务器
+func (c *SitesController) EnableSite(ctx *gin.Context) {
+	c.setSiteEnabled(ctx, true)
+}
+
+// DisableSite 停用站点，停止站点的服务器和引擎，保留配置和静态资源
+func (c *SitesController) DisableSite(ctx *gin.Context) {
+	c.setSiteEnabled(ctx, false)
+}
+
+// setSiteEnabled 保存站点启用状态并启动或停止站点的服务器和引擎
+func (c *SitesController) setSiteEnabled(ctx *gin.Context, enabled bool) {
+	id := ctx.Param("id")
+	currentConfig := c.configManager.GetConfig()
+
+	var site *config.SiteConfig
+	for i := range currentConfig.Sites {
+		if currentConfig.Sites[i].ID == id {
+			site = &currentConfig.Sites[i]
+			break
+		}
+	}
+	if site == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Site not found",
+		})
+		return
+	}
+
+	action := "site_disable"
+	if enabled {
+		action = "site_enable"
+	}
+
+	if site.IsEnabled() != enabled {
+		site.SetEnabled(enabled)
+		if err := c.configManager.SaveConfig(); err != nil {
+			site.SetEnabled(!enabled)
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "Failed to save site configuration",
+			})
+			return
+		}
+
+		if enabled {
+			if err := c.runtime.Start(*site); err != nil {
+				// 站点已启用，服务器会在后台重试启动，也可以排除端口冲突后手动重启
+				ctx.JSON(http.StatusInternalServerError, gin.H{
+					"code":    500,
+					"message": "Site enabled but server failed to start: " + err.Error(),
+				})
+				return
+			}
+		} else {
+			c.runtime.Stop(*site)
+		}
+
+		logging.DefaultLogger.LogAdminAction(
+			"admin",
+			ctx.ClientIP(),
+			action,
+			"site",
+			map[string]interface{}{
+				"site_id":   site.ID,
+				"site_name": site.Name,
+			},
+			"success",
+			"Site status updated successfully",
+		)
+	}
+
+	status := siteserver.SiteStatus{State: siteserver.SiteStateDisabled}
+	if enabled {
+		status, _ = c.siteServerMgr.GetSiteStatus(id)
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Site status updated successfully",
+		"data": siteWithStatus{
+			SiteConfig:    *site,
+			RuntimeStatus: &status,
+		},
 	})
 }
 
+// validateDomains 校验并规范化站点域名，拒绝重复或已被其他站点绑定的域名
+// excludeSiteID为正在更新的站点ID，新增站点时为空
+func (c *SitesController) validateDomains(domains []string, excludeSiteID string) ([]string, error) {
+	cfg := c.configManager.GetConfig()
+	seen := make(map[string]bool, len(domains))
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		d, err := config.NormalizeDomain(domain)
+		if err != nil {
+			return nil, err
+		}
+		// 本地演示模式只允许127.0.0.1或localhost
+		if cfg.Server.RestrictLocalDomains && !config.IsLocalDomain(d) {
+			return nil, fmt.Errorf("Only 127.0.0.1 or localhost are allowed as domains")
+		}
+		if seen[d] {
+			return nil, fmt.Errorf("Domain %s is listed more than once", d)
+		}
+		if owner := cfg.FindSiteByDomain(d, excludeSiteID); owner != nil {
+			return nil, fmt.Errorf("Domain %s is already used by site %s", d, owner.Name)
+		}
+		seen[d] = true
+		normalized = append(normalized, d)
+	}
+	return normalized, nil
+}
+
 // 检查端口是否可用
 func isPortAvailable(port int) bool {
 	// 常用互联网端口列表，这些端口将被排除
@@ -1123,70 +1723,24 @@ func isPortAvailable(port int) bool {
 	return true
 }
 
-// ExtractZIP 解压ZIP文件，导出供测试使用
-func ExtractZIP(filePath, destDir string) error {
-	// 打开ZIP文件
-	reader, err := zip.OpenReader(filePath)
+// resolveStaticPath 将请求中的路径解析为站点静态目录下的路径，路径超出静态目录时返回400
+func resolveStaticPath(ctx *gin.Context, siteStaticDir, path string) (string, bool) {
+	filePath, err := utils.SecurePath(siteStaticDir, path)
 	if err != nil {
-		return err
-	}
-	defer reader.Close()
-
-	// 确保目标目录存在
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return err
-	}
-
-	// 遍历ZIP文件中的所有文件
-	for _, file := range reader.File {
-		// 构建目标文件路径
-		destFilePath := filepath.Join(destDir, file.Name)
-
-		// 检查文件是否是目录
-		if file.FileInfo().IsDir() {
-			// 创建目录
-			if err := os.MkdirAll(destFilePath, file.Mode()); err != nil {
-				return err
-			}
-			continue
-		}
-
-		// 确保父目录存在
-		if err := os.MkdirAll(filepath.Dir(destFilePath), 0755); err != nil {
-			return err
-		}
-
-		// 创建目标文件
-		destFile, err := os.OpenFile(destFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
-		if err != nil {
-			return err
-		}
-		// 不使用defer，而是立即关闭文件
-
-		// 获取ZIP文件中的文件
-		zipFile, err := file.Open()
-		if err != nil {
-			destFile.Close() // 确保文件关闭
-			return err
-		}
-
-		// 复制文件内容
-		if _, err := io.Copy(destFile, zipFile); err != nil {
-			zipFile.Close()
-			destFile.Close() // 确保文件关闭
-			return err
-		}
-
-		// 立即关闭文件，避免资源泄漏和文件锁定问题
-		zipFile.Close()
-		destFile.Close()
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid path",
+		})
+		return "", false
 	}
-
-	return nil
+	return filePath, true
 }
 
 // DeleteStaticFile 删除静态资源文件
 func (c *SitesController) DeleteStaticFile(ctx *gin.Context) {
+	if !c.requireWritableStatic(ctx) {
+		return
+	}
 	id := ctx.Param("id")
 	path := ctx.Query("path")
 
@@ -1214,7 +1768,10 @@ func (c *SitesController) DeleteStaticFile(ctx *gin.Context) {
 	siteStaticDir := filepath.Join(c.cfg.Dirs.StaticDir, site.ID)
 
 	// 构建完整的文件路径
-	filePath := filepath.Join(siteStaticDir, path)
+	filePath, ok := resolveStaticPath(ctx, siteStaticDir, path)
+	if !ok {
+		return
+	}
 
 	// 删除文件或目录
 	if err := os.RemoveAll(filePath); err != nil {
@@ -1225,6 +1782,7 @@ func (c *SitesController) DeleteStaticFile(ctx *gin.Context) {
 		return
 	}
 
+	c.rebuildIntegrityBaseline(site.ID)
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "File deleted successfully",
@@ -1233,6 +1791,9 @@ func (c *SitesController) DeleteStaticFile(ctx *gin.Context) {
 
 // BatchDeleteStaticFiles 批量删除静态资源文件
 func (c *SitesController) BatchDeleteStaticFiles(ctx *gin.Context) {
+	if !c.requireWritableStatic(ctx) {
+		return
+	}
 	id := ctx.Param("id")
 	var req struct {
 		Paths []string `json:"paths" binding:"required"`
@@ -1268,66 +1829,56 @@ func (c *SitesController) BatchDeleteStaticFiles(ctx *gin.Context) {
 	// 构建静态资源目录路径
 	siteStaticDir := filepath.Join(c.cfg.Dirs.StaticDir, site.ID)
 
-	var failedPaths []string
+	// 逐项返回删除结果，部分失败时调用方可以看到具体哪些路径失败及原因
+	root, _ := utils.SecurePath(siteStaticDir, "")
+	results := make([]gin.H, 0, len(req.Paths))
+	failedPaths := []string{}
 	var deletedCount int
 
 	for _, path := range req.Paths {
-		// 安全检查：防止路径遍历
-		// 使用 filepath.Clean 清理路径，并检查是否包含 ".."
-		cleanPath := filepath.Clean(path)
-		if strings.Contains(cleanPath, "..") {
-			failedPaths = append(failedPaths, fmt.Sprintf("%s (invalid path)", path))
-			continue
-		}
-		
-		// 构建完整的文件路径
-		// 确保 path 不以 / 开头，或者如果以 / 开头，Join 会正确处理（通常 Join 会把 / 当作根，所以最好去掉前导 /）
-		// 在这里，假设前端传递的是相对于站点静态目录的路径
-		relativePath := strings.TrimPrefix(cleanPath, "/")
-		filePath := filepath.Join(siteStaticDir, relativePath)
-
-		// 双重检查：确保最终路径在站点静态目录下
-		// 这一步很重要，防止通过构造特殊的 path 逃逸出目录
-		absFilePath, err := filepath.Abs(filePath)
-		if err != nil {
-			failedPaths = append(failedPaths, fmt.Sprintf("%s (path error)", path))
-			continue
+		// 安全检查：防止路径遍历，前端传递的是相对于站点静态目录的路径
+		filePath, err := utils.SecurePath(siteStaticDir, path)
+		if err == nil && filePath == root {
+			err = fmt.Errorf("cannot delete the site root directory")
 		}
-		absSiteDir, err := filepath.Abs(siteStaticDir)
-		if err != nil {
-			// 理论上不应该发生，除非配置有问题
-			ctx.JSON(http.StatusInternalServerError, gin.H{
-				"code":    500,
-				"message": "Server configuration error",
-			})
-			return
+		if err == nil {
+			// 文件不存在时RemoveAll返回nil，视为删除成功
+			err = os.RemoveAll(filePath)
 		}
 
-		if !strings.HasPrefix(absFilePath, absSiteDir) {
-			failedPaths = append(failedPaths, fmt.Sprintf("%s (forbidden)", path))
+		if err != nil {
+			failedPaths = append(failedPaths, path)
+			results = append(results, gin.H{"path": path, "success": false, "error": err.Error()})
 			continue
 		}
+		deletedCount++
+		results = append(results, gin.H{"path": path, "success": true})
+	}
 
-		// 删除文件或目录
-		if err := os.RemoveAll(filePath); err != nil {
-			// 如果文件不存在，也算成功（或者忽略）
-			if !os.IsNotExist(err) {
-				failedPaths = append(failedPaths, path)
-			}
-		} else {
-			deletedCount++
-		}
+	if deletedCount > 0 {
+		c.rebuildIntegrityBaseline(site.ID)
 	}
+	auditResult := "success"
+	if len(failedPaths) > 0 {
+		auditResult = "partial_failure"
+	}
+	logStaticAction(ctx, "static_batch_delete", map[string]interface{}{
+		"paths":   req.Paths,
+		"deleted": deletedCount,
+		"failed":  failedPaths,
+	}, auditResult, fmt.Sprintf("Deleted %d of %d paths", deletedCount, len(req.Paths)))
 
+	data := gin.H{
+		"deleted": deletedCount,
+		"failed":  failedPaths,
+		"results": results,
+	}
 	if len(failedPaths) > 0 {
 		// 如果有部分失败，返回 206 Partial Content
 		ctx.JSON(http.StatusPartialContent, gin.H{
 			"code":    206,
 			"message": "Some files failed to delete",
-			"data": gin.H{
-				"deleted": deletedCount,
-				"failed":  failedPaths,
-			},
+			"data":    data,
 		})
 		return
 	}
@@ -1335,8 +1886,6 @@ func (c *SitesController) BatchDeleteStaticFiles(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "Files deleted successfully",
-		"data": gin.H{
-			"deleted": deletedCount,
-		},
+		"data":    data,
 	})
 }
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/logging"
+	"prerender-shield/internal/utils"
+)
+
+// lookupSiteStaticDir 返回站点的静态资源目录，站点不存在时返回404
+func (c *SitesController) lookupSiteStaticDir(ctx *gin.Context) (string, bool) {
+	id := ctx.Param("id")
+	for _, site := range c.configManager.GetConfig().Sites {
+		if site.ID == id {
+			return filepath.Join(c.cfg.Dirs.StaticDir, site.ID), true
+		}
+	}
+	ctx.JSON(http.StatusNotFound, gin.H{
+		"code":    404,
+		"message": "Site not found",
+	})
+	return "", false
+}
+
+// requireWritableStatic 站点静态目录为只读时返回403，站点不存在时由调用方按原有逻辑处理
+func (c *SitesController) requireWritableStatic(ctx *gin.Context) bool {
+	id := ctx.Param("id")
+	for _, site := range c.configManager.GetConfig().Sites {
+		if site.ID == id && site.StaticReadOnly {
+			ctx.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "Static directory of this site is read-only",
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// resolveStaticSubPath 解析静态目录下的路径，路径为静态目录本身时返回400
+func resolveStaticSubPath(ctx *gin.Context, siteStaticDir, path string) (string, bool) {
+	filePath, ok := resolveStaticPath(ctx, siteStaticDir, path)
+	if !ok {
+		return "", false
+	}
+	if root, _ := utils.SecurePath(siteStaticDir, ""); filePath == root {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Path must not be the site root directory",
+		})
+		return "", false
+	}
+	return filePath, true
+}
+
+// logStaticAction 记录静态资源管理的审计日志
+func logStaticAction(ctx *gin.Context, action string, details map[string]interface{}, result, message string) {
+	details["site_id"] = ctx.Param("id")
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), action, "static_file", details, result, message)
+}
+
+// CreateStaticDir 在站点静态目录下创建空目录
+func (c *SitesController) CreateStaticDir(ctx *gin.Context) {
+	if !c.requireWritableStatic(ctx) {
+		return
+	}
+	var req struct {
+		Path string `json:"path" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	siteStaticDir, ok := c.lookupSiteStaticDir(ctx)
+	if !ok {
+		return
+	}
+	dirPath, ok := resolveStaticSubPath(ctx, siteStaticDir, req.Path)
+	if !ok {
+		return
+	}
+
+	if _, err := os.Stat(dirPath); err == nil {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": "Path already exists",
+		})
+		return
+	}
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		logStaticAction(ctx, "static_mkdir", map[string]interface{}{"path": req.Path}, "failure", err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to create directory",
+		})
+		return
+	}
+
+	logStaticAction(ctx, "static_mkdir", map[string]interface{}{"path": req.Path}, "success", "Directory created")
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Directory created successfully",
+	})
+}
+
+// RenameStaticFile 重命名或移动站点静态目录下的文件或目录，目标路径已存在时返回409
+func (c *SitesController) RenameStaticFile(ctx *gin.Context) {
+	if !c.requireWritableStatic(ctx) {
+		return
+	}
+	var req struct {
+		From string `json:"from" binding:"required"`
+		To   string `json:"to" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Invalid request",
+		})
+		return
+	}
+
+	siteStaticDir, ok := c.lookupSiteStaticDir(ctx)
+	if !ok {
+		return
+	}
+	fromPath, ok := resolveStaticSubPath(ctx, siteStaticDir, req.From)
+	if !ok {
+		return
+	}
+	toPath, ok := resolveStaticSubPath(ctx, siteStaticDir, req.To)
+	if !ok {
+		return
+	}
+
+	fromInfo, err := os.Lstat(fromPath)
+	if os.IsNotExist(err) {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "File not found",
+		})
+		return
+	}
+	// 重命名文件时目标文件名同样只接受允许的文件类型，不能借重命名绕过上传检查
+	if err == nil && !fromInfo.IsDir() {
+		if err := utils.CheckUploadExtension(toPath, c.cfg.Server.UploadAllowedExtensions, c.cfg.Server.UploadBlockedExtensions); err != nil {
+			ctx.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"code":    415,
+				"message": fmt.Sprintf("Unsupported file type: %v", err),
+			})
+			return
+		}
+	}
+	if _, err := os.Lstat(toPath); err == nil {
+		ctx.JSON(http.StatusConflict, gin.H{
+			"code":    409,
+			"message": "Target path already exists",
+		})
+		return
+	}
+	// 不能把目录移动到其自身之下
+	if strings.HasPrefix(toPath, fromPath+string(filepath.Separator)) {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Cannot move a directory into itself",
+		})
+		return
+	}
+
+	details := map[string]interface{}{"from": req.From, "to": req.To}
+	err = os.MkdirAll(filepath.Dir(toPath), 0755)
+	if err == nil {
+		err = os.Rename(fromPath, toPath)
+	}
+	if err != nil {
+		logStaticAction(ctx, "static_rename", details, "failure", err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "Failed to rename file",
+		})
+		return
+	}
+
+	c.rebuildIntegrityBaseline(ctx.Param("id"))
+	logStaticAction(ctx, "static_rename", details, "success", "File renamed")
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "File renamed successfully",
+	})
+}
+
+// DownloadStaticDir 将站点静态目录下的目录实时打包为ZIP下载，不跟随符号链接
+func (c *SitesController) DownloadStaticDir(ctx *gin.Context) {
+	path := ctx.Query("path")
+
+	siteStaticDir, ok := c.lookupSiteStaticDir(ctx)
+	if !ok {
+		return
+	}
+	dirPath, ok := resolveStaticPath(ctx, siteStaticDir, path)
+	if !ok {
+		return
+	}
+
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "Directory not found",
+		})
+		return
+	}
+	if !info.IsDir() {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "Path is not a directory",
+		})
+		return
+	}
+
+	name := filepath.Base(dirPath)
+	if root, _ := utils.SecurePath(siteStaticDir, ""); dirPath == root {
+		name = ctx.Param("id")
+	}
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".zip"))
+	ctx.Status(http.StatusOK)
+
+	// 响应头已发送，打包失败时只能中断响应并记录日志
+	details := map[string]interface{}{"path": path}
+	if err := writeDirZIP(ctx.Writer, dirPath); err != nil {
+		logger.Error("Failed to stream ZIP of %s: %v", dirPath, err)
+		logStaticAction(ctx, "static_download", details, "failure", err.Error())
+		return
+	}
+	logStaticAction(ctx, "static_download", details, "success", "Directory downloaded")
+}
+
+// writeDirZIP 将目录下的普通文件和子目录写入ZIP，条目名称相对于该目录
+func writeDirZIP(w io.Writer, dir string) error {
+	zipWriter := zip.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			_, err := zipWriter.Create(name + "/")
+			return err
+		}
+		// 跳过符号链接等非普通文件，避免打包静态目录之外的内容
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		header.Method = zip.Deflate
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(writer, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultStaticReleasesToKeep 未配置时每个站点保留的历史发布版本数量
+const defaultStaticReleasesToKeep = 3
+
+// 部署过程中与站点目录同级的临时目录和历史版本目录的名称后缀
+const (
+	stagingDirInfix = ".staging-"
+	releaseDirInfix = ".release-"
+)
+
+// newStagingDir 返回站点本次部署使用的临时解压目录，与站点目录位于同一文件系统以便直接改名
+func newStagingDir(staticDir, siteID string) string {
+	return filepath.Join(staticDir, fmt.Sprintf("%s%s%d", siteID, stagingDirInfix, time.Now().UnixNano()))
+}
+
+// siteVersionDirs 返回站点目录同级中名称为 {siteID}{infix}{时间戳} 的目录，按时间从新到旧排序
+func siteVersionDirs(staticDir, siteID, infix string) ([]string, error) {
+	entries, err := os.ReadDir(staticDir)
+	if err != nil {
+		return nil, err
+	}
+
+	type versionDir struct {
+		path  string
+		stamp int64
+	}
+	prefix := siteID + infix
+	var dirs []versionDir
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		stamp, err := strconv.ParseInt(strings.TrimPrefix(entry.Name(), prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, versionDir{path: filepath.Join(staticDir, entry.Name()), stamp: stamp})
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].stamp > dirs[j].stamp })
+
+	paths := make([]string, len(dirs))
+	for i, dir := range dirs {
+		paths[i] = dir.path
+	}
+	return paths, nil
+}
+
+// swapStaticRelease 将当前站点目录改名为历史版本，再把newDir改名为站点目录。
+// 两次改名都在同一目录内完成，站点目录只在两次改名之间短暂缺失；第二次改名失败时恢复原目录
+func swapStaticRelease(staticDir, siteID, newDir string) error {
+	liveDir := filepath.Join(staticDir, siteID)
+	releaseDir := filepath.Join(staticDir, fmt.Sprintf("%s%s%d", siteID, releaseDirInfix, time.Now().UnixNano()))
+
+	hasLive := true
+	if err := os.Rename(liveDir, releaseDir); err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to move current release aside: %v", err)
+		}
+		hasLive = false
+	}
+	if err := os.Rename(newDir, liveDir); err != nil {
+		if hasLive {
+			os.Rename(releaseDir, liveDir)
+		}
+		return fmt.Errorf("failed to move new release into place: %v", err)
+	}
+	return nil
+}
+
+// pruneStaticReleases 删除超出保留数量的最旧历史版本
+func pruneStaticReleases(staticDir, siteID string, keep int) {
+	releases, err := siteVersionDirs(staticDir, siteID, releaseDirInfix)
+	if err != nil || len(releases) <= keep {
+		return
+	}
+	for _, release := range releases[keep:] {
+		if err := os.RemoveAll(release); err != nil {
+			logger.Warn("Failed to remove old release %s: %v", release, err)
+		}
+	}
+}
+
+// removeSiteVersionDirs 删除站点的所有历史版本和残留的临时解压目录
+func removeSiteVersionDirs(staticDir, siteID string) {
+	for _, infix := range []string{releaseDirInfix, stagingDirInfix} {
+		dirs, _ := siteVersionDirs(staticDir, siteID, infix)
+		for _, dir := range dirs {
+			if err := os.RemoveAll(dir); err != nil {
+				logger.Warn("Failed to remove %s: %v", dir, err)
+			}
+		}
+	}
+}
+
+// moveStagedFiles 将临时目录中的文件逐个移入目标目录，覆盖同名文件，完成后删除临时目录
+func moveStagedFiles(stagingDir, destDir string) error {
+	err := filepath.Walk(stagingDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(stagingDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return os.Rename(path, target)
+	})
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(stagingDir)
+}
+
+// staticReleasesToKeep 返回配置的历史版本保留数量
+func (c *SitesController) staticReleasesToKeep() int {
+	if c.cfg.Server.StaticReleasesToKeep > 0 {
+		return c.cfg.Server.StaticReleasesToKeep
+	}
+	return defaultStaticReleasesToKeep
+}
+
+// deployStaticRelease 将解压完成的临时目录切换为站点目录，旧目录保留为历史版本用于回滚
+func (c *SitesController) deployStaticRelease(siteID, stagingDir string) error {
+	c.releaseMu.Lock()
+	defer c.releaseMu.Unlock()
+
+	if err := swapStaticRelease(c.cfg.Dirs.StaticDir, siteID, stagingDir); err != nil {
+		return err
+	}
+	pruneStaticReleases(c.cfg.Dirs.StaticDir, siteID, c.staticReleasesToKeep())
+	return nil
+}
+
+// invalidateSiteCache 站点内容切换后清除渲染缓存，避免爬虫继续拿到旧的HTML
+func (c *SitesController) invalidateSiteCache(siteID string) int64 {
+	if c.redisClient == nil {
+		return 0
+	}
+	cleared, err := c.redisClient.ClearCache(siteID)
+	if err != nil {
+		logger.Error("Failed to clear prerender cache of site %s: %v", siteID, err)
+	}
+	return cleared
+}
+
+// rebuildIntegrityBaseline 站点静态文件通过管理接口变更后重建防篡改基线，避免合法发布被记为篡改
+func (c *SitesController) rebuildIntegrityBaseline(siteID string) {
+	if c.integrityManager == nil {
+		return
+	}
+	for _, site := range c.configManager.GetConfig().Sites {
+		if site.ID == siteID {
+			c.integrityManager.Rebuild(site)
+			return
+		}
+	}
+}
+
+// RollbackStatic 将站点静态目录切换回上一个发布版本，当前目录保留为历史版本，再次回滚即可撤销
+func (c *SitesController) RollbackStatic(ctx *gin.Context) {
+	if !c.requireWritableStatic(ctx) {
+		return
+	}
+	siteID := ctx.Param("id")
+	if _, ok := c.lookupSiteStaticDir(ctx); !ok {
+		return
+	}
+
+	c.releaseMu.Lock()
+	releases, err := siteVersionDirs(c.cfg.Dirs.StaticDir, siteID, releaseDirInfix)
+	if err == nil && len(releases) > 0 {
+		err = swapStaticRelease(c.cfg.Dirs.StaticDir, siteID, releases[0])
+	}
+	c.releaseMu.Unlock()
+
+	if err == nil && len(releases) == 0 {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "No previous release to roll back to",
+		})
+		return
+	}
+	details := map[string]interface{}{}
+	if err != nil {
+		logStaticAction(ctx, "static_rollback", details, "failure", err.Error())
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": fmt.Sprintf("Failed to roll back: %v", err),
+		})
+		return
+	}
+
+	details["release"] = filepath.Base(releases[0])
+	cleared := c.invalidateSiteCache(siteID)
+	c.rebuildIntegrityBaseline(siteID)
+	logStaticAction(ctx, "static_rollback", details, "success", "Rolled back to previous release")
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "Rolled back to previous release",
+		"data": gin.H{
+			"release":       details["release"],
+			"cleared_cache": cleared,
+		},
+	})
+}
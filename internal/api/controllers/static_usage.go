@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// staticUsageTTL 站点磁盘占用结果的缓存时间，避免频繁查询时反复遍历目录
+	staticUsageTTL = 30 * time.Second
+	// staticUsageLargestFiles 返回的最大文件数量
+	staticUsageLargestFiles = 10
+)
+
+// staticFileSize 静态文件及其大小
+type staticFileSize struct {
+	Path string `json:"path"` // 相对站点静态目录的路径，以/开头
+	Size int64  `json:"size"`
+}
+
+// staticUsage 站点静态目录的磁盘占用
+type staticUsage struct {
+	TotalBytes   int64            `json:"total_bytes"`
+	FileCount    int              `json:"file_count"`
+	LargestFiles []staticFileSize `json:"largest_files"` // 按大小从大到小排序
+	ComputedAt   time.Time        `json:"computed_at"`
+}
+
+// staticUsageCache 按站点ID缓存的磁盘占用结果
+type staticUsageCache struct {
+	mutex sync.Mutex
+	sites map[string]staticUsage
+}
+
+// get 返回未过期的缓存结果
+func (c *staticUsageCache) get(siteID string) (staticUsage, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	usage, exists := c.sites[siteID]
+	if !exists || time.Since(usage.ComputedAt) > staticUsageTTL {
+		return staticUsage{}, false
+	}
+	return usage, true
+}
+
+// set 缓存站点的磁盘占用结果
+func (c *staticUsageCache) set(siteID string, usage staticUsage) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.sites == nil {
+		c.sites = make(map[string]staticUsage)
+	}
+	c.sites[siteID] = usage
+}
+
+// computeStaticUsage 遍历目录统计文件总大小和数量，并返回最大的top个文件；目录不存在时返回空结果
+func computeStaticUsage(root string, top int) (staticUsage, error) {
+	usage := staticUsage{LargestFiles: []staticFileSize{}}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		usage.TotalBytes += info.Size()
+		usage.FileCount++
+
+		// 只保留最大的top个文件，避免文件很多时占用大量内存
+		if len(usage.LargestFiles) == top && info.Size() <= usage.LargestFiles[top-1].Size {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		usage.LargestFiles = append(usage.LargestFiles, staticFileSize{Path: "/" + filepath.ToSlash(rel), Size: info.Size()})
+		sort.SliceStable(usage.LargestFiles, func(i, j int) bool { return usage.LargestFiles[i].Size > usage.LargestFiles[j].Size })
+		if len(usage.LargestFiles) > top {
+			usage.LargestFiles = usage.LargestFiles[:top]
+		}
+		return nil
+	})
+	usage.ComputedAt = time.Now()
+	return usage, err
+}
+
+// GetStaticUsage 返回站点静态目录的磁盘占用，包括总大小、文件数和最大的文件，结果缓存staticUsageTTL
+func (c *SitesController) GetStaticUsage(ctx *gin.Context) {
+	siteStaticDir, ok := c.lookupSiteStaticDir(ctx)
+	if !ok {
+		return
+	}
+
+	siteID := ctx.Param("id")
+	usage, cached := c.usageCache.get(siteID)
+	if !cached {
+		var err error
+		usage, err = computeStaticUsage(siteStaticDir, staticUsageLargestFiles)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "Failed to calculate disk usage: " + err.Error(),
+			})
+			return
+		}
+		c.usageCache.set(siteID, usage)
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    usage,
+	})
+}
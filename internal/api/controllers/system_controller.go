@@ -4,6 +4,7 @@ import (
 	"net/http"
 	appConfig "prerender-shield/internal/config"
 	"prerender-shield/internal/redis"
+	siteserver "prerender-shield/internal/site-server"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,13 +12,15 @@ import (
 
 // SystemController 系统控制器
 type SystemController struct {
-	redisClient *redis.Client
+	redisClient   *redis.Client
+	siteServerMgr *siteserver.Manager
 }
 
 // NewSystemController 创建系统控制器实例
-func NewSystemController(redisClient *redis.Client) *SystemController {
+func NewSystemController(redisClient *redis.Client, siteServerMgr *siteserver.Manager) *SystemController {
 	return &SystemController{
-		redisClient: redisClient,
+		redisClient:   redisClient,
+		siteServerMgr: siteServerMgr,
 	}
 }
 
@@ -36,14 +39,37 @@ func (c *SystemController) Health(ctx *gin.Context) {
 		}
 	}
 
+	// 任一站点服务器启动失败时整体状态为degraded
+	siteStatuses := map[string]siteserver.SiteStatus{}
+	if c.siteServerMgr != nil {
+		siteStatuses = c.siteServerMgr.ListSiteStatuses()
+		for _, siteStatus := range siteStatuses {
+			if siteStatus.State == siteserver.SiteStateError {
+				status = "degraded"
+			}
+		}
+	}
+
+	// 即将过期或无法加载的站点证书
+	expiringCertificates := map[string]*siteserver.CertificateInfo{}
+	cfg := appConfig.GetInstance().GetConfig()
+	for _, site := range cfg.Sites {
+		if info := siteserver.InspectCertificate(site, cfg.Dirs.CertsDir); info != nil && (info.ExpiringSoon || info.Error != "") {
+			expiringCertificates[site.ID] = info
+		}
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "success",
 		"data": gin.H{
-			"status":       status,
-			"service":      "prerender-shield",
-			"redis_status": redisStatus,
-			"timestamp":    time.Now().Unix(),
+			"status":                status,
+			"service":               "prerender-shield",
+			"redis_status":          redisStatus,
+			"sites":                 siteStatuses,
+			"expiring_certificates": expiringCertificates,
+			"ssl_certificates":      countValidCertificates(cfg.Sites, cfg.Dirs.CertsDir),
+			"timestamp":             time.Now().Unix(),
 		},
 	})
 }
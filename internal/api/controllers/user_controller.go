@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/auth"
+	"prerender-shield/internal/logging"
+)
+
+// UserController 用户管理控制器
+type UserController struct {
+	userManager *auth.UserManager
+}
+
+// NewUserController 创建用户管理控制器实例
+func NewUserController(userManager *auth.UserManager) *UserController {
+	return &UserController{
+		userManager: userManager,
+	}
+}
+
+// ListUsers 获取用户列表
+func (c *UserController) ListUsers(ctx *gin.Context) {
+	users, err := c.userManager.ListUsers()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"code":    http.StatusInternalServerError,
+			"message": "Failed to list users: " + err.Error(),
+		})
+		return
+	}
+
+	// 不返回密码哈希
+	items := make([]gin.H, 0, len(users))
+	for _, user := range users {
+		items = append(items, gin.H{
+			"id":       user.ID,
+			"username": user.Username,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    items,
+	})
+}
+
+// CreateUser 添加用户
+func (c *UserController) CreateUser(ctx *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "message": "Invalid request"})
+		return
+	}
+
+	user, err := c.userManager.AddUser(req.Username, req.Password)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, auth.ErrUserExists):
+			statusCode = http.StatusConflict
+		case errors.Is(err, auth.ErrWeakPassword):
+			statusCode = http.StatusBadRequest
+		}
+		ctx.JSON(statusCode, gin.H{
+			"code":    statusCode,
+			"message": "Failed to create user: " + err.Error(),
+		})
+		return
+	}
+
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), "create_user", "user", map[string]interface{}{
+		"user_id":  user.ID,
+		"username": user.Username,
+	}, "success", "")
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "User created successfully",
+		"data": gin.H{
+			"id":       user.ID,
+			"username": user.Username,
+		},
+	})
+}
+
+// DeleteUser 删除用户
+func (c *UserController) DeleteUser(ctx *gin.Context) {
+	userID := ctx.Param("id")
+
+	if err := c.userManager.DeleteUser(userID); err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, auth.ErrUserNotFound):
+			statusCode = http.StatusNotFound
+		case errors.Is(err, auth.ErrLastUser):
+			statusCode = http.StatusConflict
+		}
+		ctx.JSON(statusCode, gin.H{
+			"code":    statusCode,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	logging.DefaultLogger.LogAdminAction(ctx.GetString("username"), ctx.ClientIP(), "delete_user", "user", map[string]interface{}{
+		"user_id": userID,
+	}, "success", "")
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "User deleted successfully",
+	})
+}
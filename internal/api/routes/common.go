@@ -1,15 +1,13 @@
 package routes
 
 import (
-	"archive/zip"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/utils"
 )
 
 // 添加安全头中间件
@@ -102,64 +100,7 @@ func isPortAvailable(port int) bool {
 	return true
 }
 
-// ExtractZIP 解压ZIP文件，导出供测试使用
+// ExtractZIP 解压上传的归档文件，按扩展名支持ZIP、tar.gz/tgz、tar和RAR，导出供测试使用
 func ExtractZIP(filePath, destDir string) error {
-	// 打开ZIP文件
-	reader, err := zip.OpenReader(filePath)
-	if err != nil {
-		return err
-	}
-	defer reader.Close()
-
-	// 确保目标目录存在
-	if err := os.MkdirAll(destDir, 0755); err != nil {
-		return err
-	}
-
-	// 遍历ZIP文件中的所有文件
-	for _, file := range reader.File {
-		// 构建目标文件路径
-		destFilePath := filepath.Join(destDir, file.Name)
-
-		// 检查文件是否是目录
-		if file.FileInfo().IsDir() {
-			// 创建目录
-			if err := os.MkdirAll(destFilePath, file.Mode()); err != nil {
-				return err
-			}
-			continue
-		}
-
-		// 确保父目录存在
-		if err := os.MkdirAll(filepath.Dir(destFilePath), 0755); err != nil {
-			return err
-		}
-
-		// 创建目标文件
-		destFile, err := os.OpenFile(destFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
-		if err != nil {
-			return err
-		}
-		// 不使用defer，而是立即关闭文件
-
-		// 获取ZIP文件中的文件
-		zipFile, err := file.Open()
-		if err != nil {
-			destFile.Close() // 确保文件关闭
-			return err
-		}
-
-		// 复制文件内容
-		if _, err := io.Copy(destFile, zipFile); err != nil {
-			zipFile.Close()
-			destFile.Close() // 确保文件关闭
-			return err
-		}
-
-		// 立即关闭文件，避免资源泄漏和文件锁定问题
-		zipFile.Close()
-		destFile.Close()
-	}
-
-	return nil
+	return utils.ExtractArchive(filePath, destDir)
 }
@@ -1,9 +1,12 @@
 package routes
 
 import (
+	"prerender-shield/internal/acme"
 	"prerender-shield/internal/api/controllers"
 	"prerender-shield/internal/auth"
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/integrity"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/monitoring"
 	"prerender-shield/internal/prerender"
@@ -22,10 +25,14 @@ type Controllers struct {
 	MonitoringController *controllers.MonitoringController
 	FirewallController   *controllers.FirewallController
 	CrawlerController    *controllers.CrawlerController
+	EnginesController    *controllers.EnginesController
 	PreheatController    *controllers.PreheatController
+	PrerenderController  *controllers.PrerenderController
 	PushController       *controllers.PushController
+	RenderController     *controllers.RenderController
 	SitesController      *controllers.SitesController
 	SystemController     *controllers.SystemController
+	UserController       *controllers.UserController
 }
 
 // SetupControllers 创建并配置所有控制器实例
@@ -34,6 +41,9 @@ func SetupControllers(
 	jwtManager *auth.JWTManager,
 	configManager *config.ConfigManager,
 	prerenderManager *prerender.EngineManager,
+	firewallManager *firewall.EngineManager,
+	acmeManager *acme.Manager,
+	integrityManager *integrity.Manager,
 	redisClient *redis.Client,
 	scheduler *scheduler.Scheduler,
 	siteServerMgr *siteserver.Manager,
@@ -52,11 +62,15 @@ func SetupControllers(
 		AuthController:       controllers.NewAuthController(userManager, jwtManager),
 		OverviewController:   controllers.NewOverviewController(cfg, monitor, visitLogMgr, wafRepo),
 		MonitoringController: controllers.NewMonitoringController(monitor),
-		FirewallController:   controllers.NewFirewallController(wafRepo),
+		FirewallController:   controllers.NewFirewallController(wafRepo, configManager, firewallManager, integrityManager),
 		CrawlerController:    controllers.NewCrawlerController(crawlerLogMgr),
-		PreheatController:    controllers.NewPreheatController(prerenderManager, redisClient, cfg),
+		EnginesController:    controllers.NewEnginesController(configManager, prerenderManager, firewallManager),
+		PreheatController:    controllers.NewPreheatController(prerenderManager, redisClient, scheduler, cfg),
+		PrerenderController:  controllers.NewPrerenderController(prerenderManager, configManager, redisClient),
 		PushController:       controllers.NewPushController(pushManager, redisClient, cfg),
-		SitesController:      controllers.NewSitesController(configManager, siteServerMgr, siteHandler, redisClient, monitor, crawlerLogMgr, visitLogMgr, cfg),
-		SystemController:     controllers.NewSystemController(redisClient),
+		RenderController:     controllers.NewRenderController(prerenderManager, configManager),
+		SitesController:      controllers.NewSitesController(configManager, siteServerMgr, siteHandler, prerenderManager, firewallManager, acmeManager, integrityManager, redisClient, monitor, crawlerLogMgr, visitLogMgr, cfg),
+		SystemController:     controllers.NewSystemController(redisClient, siteServerMgr),
+		UserController:       controllers.NewUserController(userManager),
 	}
 }
\ No newline at end of file
@@ -8,6 +8,11 @@ import (
 
 // RegisterAllRoutes 注册所有API路由
 func RegisterAllRoutes(ginRouter *gin.Engine, controllers *Controllers, jwtManager *auth.JWTManager) {
+	// 兼容prerender.io中间件的渲染API - 不需要JWT验证，由独立的访问令牌保护
+	if controllers.RenderController != nil && controllers.RenderController.Enabled() {
+		ginRouter.GET(controllers.RenderController.Path(), controllers.RenderController.Render)
+	}
+
 	// 注册API路由
 	apiGroup := ginRouter.Group("/api/v1")
 	{
@@ -22,45 +27,98 @@ func RegisterAllRoutes(ginRouter *gin.Engine, controllers *Controllers, jwtManag
 
 			// 用户退出登录
 			authGroup.POST("/logout", controllers.AuthController.Logout)
+
+			// 刷新令牌，允许使用刚过期的令牌，因此不经过JWT中间件
+			authGroup.POST("/refresh", controllers.AuthController.RefreshToken)
 		}
 
-		// 系统相关API - 不需要JWT验证
+		// 健康检查API - 不需要JWT验证
 		apiGroup.GET("/health", controllers.SystemController.Health)
-		apiGroup.GET("/version", controllers.SystemController.Version)
 
 		// 需要JWT验证的API组
 		protectedGroup := apiGroup.Group("")
 		protectedGroup.Use(auth.JWTAuthMiddleware(jwtManager))
 		{
+			// 版本信息API
+			protectedGroup.GET("/version", controllers.SystemController.Version)
+
 			// 系统配置API
 			protectedGroup.GET("/system/config", controllers.SystemController.GetSystemConfig)
 			protectedGroup.POST("/system/config", controllers.SystemController.UpdateSystemConfig)
 
+			// 配置快照API，保存配置前自动创建快照，可回滚到任一快照
+			protectedGroup.GET("/config/backups", controllers.SystemController.ListConfigBackups)
+			protectedGroup.POST("/config/rollback", controllers.SystemController.RollbackConfig)
+
+			// 配置校验API，只校验不保存
+			protectedGroup.POST("/config/validate", controllers.SystemController.ValidateConfig)
+
+			// 运行日志级别API，修改后立即生效，不需要重启
+			protectedGroup.GET("/logs/level", controllers.SystemController.GetLogLevel)
+			protectedGroup.PUT("/logs/level", controllers.SystemController.SetLogLevel)
+
+			// 审计日志API，支持过滤和导出为CSV或JSON
+			protectedGroup.GET("/logs/audit", controllers.SystemController.GetAuditLogs)
+			protectedGroup.GET("/logs/export", controllers.SystemController.ExportAuditLogs)
+
+			// 修改密码
+			protectedGroup.POST("/auth/change-password", controllers.AuthController.ChangePassword)
+
+			// 用户管理API
+			protectedGroup.GET("/users", controllers.UserController.ListUsers)
+			protectedGroup.POST("/users", controllers.UserController.CreateUser)
+			protectedGroup.DELETE("/users/:id", controllers.UserController.DeleteUser)
+
 			// 概览API
 			protectedGroup.GET("/overview", controllers.OverviewController.GetOverview)
 
 			// 监控API
 			protectedGroup.GET("/monitoring/stats", controllers.MonitoringController.GetStats)
+			protectedGroup.GET("/monitoring/sites/:id", controllers.MonitoringController.GetSiteStats)
+
+			// 引擎状态API，汇总各站点的渲染预热和防火墙引擎
+			protectedGroup.GET("/engines", controllers.EnginesController.ListEngines)
 
 			// 访问日志API
 			protectedGroup.GET("/logs", controllers.FirewallController.GetAccessLogs)
 			protectedGroup.GET("/firewall/attacks", controllers.FirewallController.GetAttackLogs)
+			protectedGroup.GET("/firewall/logs", controllers.FirewallController.GetBlockedLogs)
 			protectedGroup.POST("/firewall/whitelist", controllers.FirewallController.AddToWhitelist)
 			protectedGroup.POST("/firewall/blacklist", controllers.FirewallController.AddToBlacklist)
 
+			// 防火墙自定义规则API，通过site查询参数指定站点
+			protectedGroup.GET("/firewall/rules", controllers.FirewallController.ListRules)
+			protectedGroup.POST("/firewall/rules", controllers.FirewallController.AddRule)
+			protectedGroup.PUT("/firewall/rules/:ruleId", controllers.FirewallController.UpdateRule)
+			protectedGroup.DELETE("/firewall/rules/:ruleId", controllers.FirewallController.DeleteRule)
+
+			// 频率限制封禁API，通过site查询参数指定站点
+			protectedGroup.GET("/firewall/bans", controllers.FirewallController.ListBans)
+			protectedGroup.DELETE("/firewall/bans", controllers.FirewallController.LiftBan)
+
+			// 网页防篡改API，返回各站点的基线状态和文件篡改事件
+			protectedGroup.GET("/firewall/integrity", controllers.FirewallController.GetIntegrity)
+
 			// 爬虫日志API
 			protectedGroup.GET("/crawler/logs", controllers.CrawlerController.GetCrawlerLogs)
+			protectedGroup.GET("/crawler/logs/export", controllers.CrawlerController.ExportCrawlerLogs)
 			protectedGroup.GET("/crawler/stats", controllers.CrawlerController.GetCrawlerStats)
 
 			// 预热API
 			protectedGroup.GET("/preheat/sites", controllers.PreheatController.GetPreheatSites)
 			protectedGroup.GET("/preheat/stats", controllers.PreheatController.GetPreheatStats)
 			protectedGroup.POST("/preheat/trigger", controllers.PreheatController.TriggerPreheat)
+			protectedGroup.POST("/preheat/cancel", controllers.PreheatController.CancelPreheat)
 			protectedGroup.GET("/preheat/urls", controllers.PreheatController.GetPreheatUrls)
 			protectedGroup.GET("/preheat/task/status", controllers.PreheatController.GetPreheatTaskStatus)
 			protectedGroup.GET("/preheat/crawler-headers", controllers.PreheatController.GetCrawlerHeaders)
 			protectedGroup.POST("/preheat/clear-cache", controllers.PreheatController.ClearCache)
 
+			// 渲染引擎配置API
+			protectedGroup.GET("/prerender/crawler-headers", controllers.PrerenderController.GetCrawlerHeaders)
+			protectedGroup.PUT("/prerender/crawler-headers", controllers.PrerenderController.UpdateCrawlerHeaders)
+			protectedGroup.POST("/prerender/detect", controllers.PrerenderController.DetectCrawler)
+
 			// 推送API
 			protectedGroup.GET("/push/sites", controllers.PushController.GetSites)
 			protectedGroup.GET("/push/stats", controllers.PushController.GetPushStats)
@@ -68,6 +126,8 @@ func RegisterAllRoutes(ginRouter *gin.Engine, controllers *Controllers, jwtManag
 			protectedGroup.GET("/push/trend", controllers.PushController.GetPushTrend)
 			protectedGroup.GET("/push/config", controllers.PushController.GetPushConfig)
 			protectedGroup.POST("/push/config", controllers.PushController.UpdatePushConfig)
+			protectedGroup.POST("/push/trigger", controllers.PushController.TriggerPush)
+			protectedGroup.POST("/push/mark-pushed", controllers.PushController.MarkAllPushed)
 
 			// 站点管理API
 			sitesGroup := protectedGroup.Group("/sites")
@@ -99,6 +159,16 @@ func RegisterAllRoutes(ginRouter *gin.Engine, controllers *Controllers, jwtManag
 				// 删除站点
 				sitesGroup.DELETE("/:id", controllers.SitesController.DeleteSite)
 
+				// 重启站点服务器
+				sitesGroup.POST("/:id/restart", controllers.SitesController.RestartSite)
+
+				// 上传或替换站点证书
+				sitesGroup.POST("/:id/tls", controllers.SitesController.UploadSiteTLS)
+
+				// 启用和停用站点
+				sitesGroup.POST("/:id/enable", controllers.SitesController.EnableSite)
+				sitesGroup.POST("/:id/disable", controllers.SitesController.DisableSite)
+
 				// 静态资源管理API
 				// 获取站点的静态资源文件列表
 				sitesGroup.GET("/:id/static", controllers.SitesController.GetStaticFiles)
@@ -114,6 +184,19 @@ func RegisterAllRoutes(ginRouter *gin.Engine, controllers *Controllers, jwtManag
 				
 				// 批量删除静态资源文件
 				sitesGroup.POST("/:id/static/batch-delete", controllers.SitesController.BatchDeleteStaticFiles)
+
+				// 创建目录
+				sitesGroup.POST("/:id/static/mkdir", controllers.SitesController.CreateStaticDir)
+
+				// 重命名或移动文件
+				sitesGroup.POST("/:id/static/rename", controllers.SitesController.RenameStaticFile)
+
+				// 打包下载目录
+				sitesGroup.GET("/:id/static/download", controllers.SitesController.DownloadStaticDir)
+				sitesGroup.POST("/:id/static/rollback", controllers.SitesController.RollbackStatic)
+
+				// 查询静态目录磁盘占用
+				sitesGroup.GET("/:id/static/usage", controllers.SitesController.GetStaticUsage)
 	
 		}
 		}
@@ -3,8 +3,11 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 
+	"prerender-shield/internal/acme"
 	"prerender-shield/internal/auth"
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/integrity"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/middleware"
 	"prerender-shield/internal/monitoring"
@@ -22,6 +25,9 @@ type Router struct {
 	jwtManager       *auth.JWTManager
 	configManager    *config.ConfigManager
 	prerenderManager *prerender.EngineManager
+	firewallManager  *firewall.EngineManager
+	acmeManager      *acme.Manager
+	integrityManager *integrity.Manager
 	redisClient      *redis.Client
 	scheduler        *scheduler.Scheduler
 	siteServerMgr    *siteserver.Manager
@@ -39,6 +45,9 @@ func NewRouter(
 	jwtManager *auth.JWTManager,
 	configManager *config.ConfigManager,
 	prerenderManager *prerender.EngineManager,
+	firewallManager *firewall.EngineManager,
+	acmeManager *acme.Manager,
+	integrityManager *integrity.Manager,
 	redisClient *redis.Client,
 	scheduler *scheduler.Scheduler,
 	siteServerMgr *siteserver.Manager,
@@ -54,6 +63,9 @@ func NewRouter(
 		jwtManager:       jwtManager,
 		configManager:    configManager,
 		prerenderManager: prerenderManager,
+		firewallManager:  firewallManager,
+		acmeManager:      acmeManager,
+		integrityManager: integrityManager,
 		redisClient:      redisClient,
 		scheduler:        scheduler,
 		siteServerMgr:    siteServerMgr,
@@ -84,6 +96,9 @@ func (r *Router) RegisterRoutes(ginRouter *gin.Engine) {
 		r.jwtManager,
 		r.configManager,
 		r.prerenderManager,
+		r.firewallManager,
+		r.acmeManager,
+		r.integrityManager,
 		r.redisClient,
 		r.scheduler,
 		r.siteServerMgr,
@@ -17,12 +17,18 @@ var (
 	ErrNoAuthHeader      = errors.New("authorization header is required")
 	ErrInvalidAuthFormat = errors.New("invalid authorization format")
 	ErrSessionExpired    = errors.New("session has expired or been revoked")
+	ErrRefreshExpired    = errors.New("token is past the refresh grace period")
 )
 
+// DefaultRefreshGracePeriod 默认的令牌刷新宽限期，令牌过期后在此时间内仍可刷新
+const DefaultRefreshGracePeriod = 30 * time.Minute
+
 // JWTConfig JWT配置
 type JWTConfig struct {
 	SecretKey  string        `yaml:"secret_key"`
 	ExpireTime time.Duration `yaml:"expire_time"`
+	// RefreshGracePeriod 令牌过期后仍允许刷新的时间，为0时使用默认值
+	RefreshGracePeriod time.Duration `yaml:"refresh_grace_period"`
 }
 
 // Claims JWT声明
@@ -77,8 +83,9 @@ func (m *JWTManager) GenerateToken(userID, username string) (string, error) {
 	}
 
 	// 如果Redis客户端可用，保存会话到Redis
+	// 会话保留到刷新宽限期结束，使刚过期的令牌仍能刷新
 	if m.redisClient != nil {
-		err := m.redisClient.SaveSession(sessionID, userID, m.config.ExpireTime)
+		err := m.redisClient.SaveSession(sessionID, userID, m.config.ExpireTime+m.refreshGracePeriod())
 		if err != nil {
 			return "", err
 		}
@@ -90,13 +97,7 @@ func (m *JWTManager) GenerateToken(userID, username string) (string, error) {
 // ValidateToken 验证JWT令牌
 func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	// 解析令牌
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// 验证签名方法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return []byte(m.config.SecretKey), nil
-	})
+	token, err := m.parseToken(tokenString)
 
 	if err != nil {
 		// 检查是否是过期错误
@@ -118,19 +119,103 @@ func (m *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 	}
 
 	// 如果Redis客户端可用，检查会话是否存在（实现服务端注销和会话管理）
+	if err := m.checkSession(claims.SessionID); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// RefreshToken 刷新JWT令牌
+// 令牌有效或过期未超过宽限期时签发新令牌，并撤销旧令牌的会话
+func (m *JWTManager) RefreshToken(oldToken string) (string, error) {
+	// 解析令牌，跳过过期校验，过期时间在下面按宽限期单独判断
+	token, err := m.parseToken(oldToken, jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return "", ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.ExpiresAt == nil {
+		return "", ErrInvalidToken
+	}
+
+	// 超过宽限期的令牌不允许刷新，需要重新登录
+	if time.Since(claims.ExpiresAt.Time) > m.refreshGracePeriod() {
+		return "", ErrRefreshExpired
+	}
+
+	// 已注销的会话不允许刷新
+	if err := m.checkSession(claims.SessionID); err != nil {
+		return "", err
+	}
+
+	// 签发新令牌
+	newToken, err := m.GenerateToken(claims.UserID, claims.Username)
+	if err != nil {
+		return "", err
+	}
+
+	// 撤销旧会话，旧令牌不能再次使用或刷新
 	if m.redisClient != nil {
-		exists, err := m.redisClient.CheckSessionExists(claims.SessionID)
-		if err != nil {
-			// 如果Redis出错，暂时允许通过（降级策略），或者返回错误
-			// 这里选择安全策略：如果无法验证会话，则认为无效
-			return nil, fmt.Errorf("failed to verify session: %v", err)
+		if err := m.redisClient.DeleteSession(claims.SessionID); err != nil {
+			return "", fmt.Errorf("failed to revoke old session: %v", err)
 		}
-		if !exists {
-			return nil, ErrSessionExpired
+	}
+
+	return newToken, nil
+}
+
+// GetTokenExpiry 获取令牌的过期时间，仅校验签名
+func (m *JWTManager) GetTokenExpiry(tokenString string) (time.Time, error) {
+	token, err := m.parseToken(tokenString, jwt.WithoutClaimsValidation())
+	if err != nil || !token.Valid {
+		return time.Time{}, ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*Claims)
+	if !ok || claims.ExpiresAt == nil {
+		return time.Time{}, ErrInvalidToken
+	}
+
+	return claims.ExpiresAt.Time, nil
+}
+
+// parseToken 解析令牌并验证签名方法
+func (m *JWTManager) parseToken(tokenString string, options ...jwt.ParserOption) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		// 验证签名方法
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
 		}
+		return []byte(m.config.SecretKey), nil
+	}, options...)
+}
+
+// checkSession 检查会话是否存在，Redis不可用时跳过
+func (m *JWTManager) checkSession(sessionID string) error {
+	if m.redisClient == nil {
+		return nil
 	}
 
-	return claims, nil
+	exists, err := m.redisClient.CheckSessionExists(sessionID)
+	if err != nil {
+		// 如果Redis出错，暂时允许通过（降级策略），或者返回错误
+		// 这里选择安全策略：如果无法验证会话，则认为无效
+		return fmt.Errorf("failed to verify session: %v", err)
+	}
+	if !exists {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// refreshGracePeriod 获取令牌刷新宽限期
+func (m *JWTManager) refreshGracePeriod() time.Duration {
+	if m.config.RefreshGracePeriod > 0 {
+		return m.config.RefreshGracePeriod
+	}
+	return DefaultRefreshGracePeriod
 }
 
 // RevokeToken 撤销令牌（注销）
@@ -139,7 +224,7 @@ func (m *JWTManager) RevokeToken(tokenString string) error {
 	if err != nil {
 		return err
 	}
-	
+
 	if m.redisClient != nil {
 		return m.redisClient.DeleteSession(claims.SessionID)
 	}
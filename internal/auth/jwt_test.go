@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRefreshToken 测试刷新有效令牌
+func TestRefreshToken(t *testing.T) {
+	manager := NewJWTManager(&JWTConfig{SecretKey: "test-secret", ExpireTime: time.Hour}, nil)
+
+	token, err := manager.GenerateToken("user-1", "admin")
+	require.NoError(t, err)
+
+	newToken, err := manager.RefreshToken(token)
+	require.NoError(t, err)
+
+	claims, err := manager.ValidateToken(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "admin", claims.Username)
+
+	expiresAt, err := manager.GetTokenExpiry(newToken)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+}
+
+// TestRefreshTokenGracePeriod 测试过期令牌在宽限期内可刷新，超过宽限期被拒绝
+func TestRefreshTokenGracePeriod(t *testing.T) {
+	// 签发一个1分钟前已过期的令牌
+	expiredManager := NewJWTManager(&JWTConfig{SecretKey: "test-secret", ExpireTime: -time.Minute, RefreshGracePeriod: 10 * time.Minute}, nil)
+	recentlyExpired, err := expiredManager.GenerateToken("user-1", "admin")
+	require.NoError(t, err)
+
+	_, err = expiredManager.ValidateToken(recentlyExpired)
+	assert.Equal(t, ErrExpiredToken, err)
+
+	manager := NewJWTManager(&JWTConfig{SecretKey: "test-secret", ExpireTime: time.Hour, RefreshGracePeriod: 10 * time.Minute}, nil)
+	newToken, err := manager.RefreshToken(recentlyExpired)
+	require.NoError(t, err)
+	_, err = manager.ValidateToken(newToken)
+	assert.NoError(t, err)
+
+	// 签发一个1小时前已过期的令牌，超过10分钟宽限期
+	staleManager := NewJWTManager(&JWTConfig{SecretKey: "test-secret", ExpireTime: -time.Hour}, nil)
+	stale, err := staleManager.GenerateToken("user-1", "admin")
+	require.NoError(t, err)
+
+	_, err = manager.RefreshToken(stale)
+	assert.Equal(t, ErrRefreshExpired, err)
+}
+
+// TestRefreshTokenRejectsInvalidToken 测试签名错误的令牌不能刷新
+func TestRefreshTokenRejectsInvalidToken(t *testing.T) {
+	other := NewJWTManager(&JWTConfig{SecretKey: "other-secret", ExpireTime: time.Hour}, nil)
+	token, err := other.GenerateToken("user-1", "admin")
+	require.NoError(t, err)
+
+	manager := NewJWTManager(&JWTConfig{SecretKey: "test-secret", ExpireTime: time.Hour}, nil)
+	_, err = manager.RefreshToken(token)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	_, err = manager.RefreshToken("not-a-token")
+	assert.Equal(t, ErrInvalidToken, err)
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"errors"
+	"fmt"
 
 	"prerender-shield/internal/redis"
 
@@ -14,8 +15,15 @@ var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrInvalidCredentials = errors.New("invalid username or password")
 	ErrUserExists         = errors.New("user already exists")
+	ErrLastUser           = errors.New("cannot delete the last remaining user")
+	ErrNoUserStore        = errors.New("user store is not available")
+	ErrIncorrectPassword  = errors.New("old password is incorrect")
+	ErrWeakPassword       = fmt.Errorf("new password must be at least %d characters", MinPasswordLength)
 )
 
+// MinPasswordLength 密码最小长度
+const MinPasswordLength = 8
+
 // User 用户信息
 type User struct {
 	ID       string `json:"id"`
@@ -36,9 +44,9 @@ func NewUserManager(_ string, redisClient *redis.Client) *UserManager {
 	}
 }
 
-// CreateUser 创建用户
+// CreateUser 首次运行时创建初始用户，之后的用户通过AddUser添加
 func (m *UserManager) CreateUser(username, password string) (*User, error) {
-	// 检查是否已经有用户存在（只允许创建一个用户）
+	// 检查是否已经有用户存在（仅用于首次初始化）
 	if m.redisClient != nil {
 		userIDs, err := m.redisClient.GetAllUsers()
 		if err == nil && len(userIDs) > 0 {
@@ -46,6 +54,77 @@ func (m *UserManager) CreateUser(username, password string) (*User, error) {
 		}
 	}
 
+	return m.saveNewUser(username, password)
+}
+
+// AddUser 由已登录用户添加新用户，用于首次初始化之后的用户管理
+func (m *UserManager) AddUser(username, password string) (*User, error) {
+	if m.redisClient == nil {
+		return nil, ErrNoUserStore
+	}
+
+	if len(password) < MinPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	// 用户名不能重复
+	if _, err := m.GetUserByUsername(username); err == nil {
+		return nil, ErrUserExists
+	}
+
+	return m.saveNewUser(username, password)
+}
+
+// ListUsers 获取所有用户
+func (m *UserManager) ListUsers() ([]*User, error) {
+	if m.redisClient == nil {
+		return nil, ErrNoUserStore
+	}
+
+	userIDs, err := m.redisClient.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*User, 0, len(userIDs))
+	for _, userID := range userIDs {
+		userData, err := m.redisClient.GetUser(userID)
+		if err != nil || len(userData) == 0 {
+			continue
+		}
+		users = append(users, &User{
+			ID:       userData["id"],
+			Username: userData["username"],
+			Password: userData["password"],
+		})
+	}
+
+	return users, nil
+}
+
+// DeleteUser 删除用户并撤销其所有会话，不允许删除最后一个用户
+func (m *UserManager) DeleteUser(userID string) error {
+	if m.redisClient == nil {
+		return ErrNoUserStore
+	}
+
+	// 检查用户数量和删除在Redis中原子执行，并发删除不会删除掉最后两个用户
+	err := m.redisClient.DeleteUserIfNotLast(userID)
+	switch {
+	case errors.Is(err, redis.ErrUserNotExist):
+		return ErrUserNotFound
+	case errors.Is(err, redis.ErrLastUserRemaining):
+		return ErrLastUser
+	case err != nil:
+		return err
+	}
+
+	// 撤销该用户的所有会话，已签发的令牌立即失效
+	return m.redisClient.DeleteUserSessions(userID)
+}
+
+// saveNewUser 加密密码并保存新用户
+func (m *UserManager) saveNewUser(username, password string) (*User, error) {
 	// 生成用户ID
 	userID := uuid.New().String()
 
@@ -118,6 +197,33 @@ func (m *UserManager) AuthenticateUser(username, password string) (*User, error)
 	return user, nil
 }
 
+// ChangePassword 修改用户密码
+// 旧密码错误返回ErrIncorrectPassword，新密码不符合要求返回ErrWeakPassword
+func (m *UserManager) ChangePassword(username, oldPassword, newPassword string) error {
+	// 验证新密码
+	if len(newPassword) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+
+	// 使用与登录相同的方式验证旧密码
+	user, err := m.AuthenticateUser(username, oldPassword)
+	if err != nil {
+		return ErrIncorrectPassword
+	}
+
+	// 加密新密码
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	// 保存新密码
+	if m.redisClient == nil {
+		return ErrNoUserStore
+	}
+	return m.redisClient.SaveUser(user.ID, user.Username, string(hashedPassword))
+}
+
 // IsFirstRun 检查是否是首次运行（没有用户）
 func (m *UserManager) IsFirstRun() bool {
 	// 直接检查Redis中是否存在用户数据
@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestChangePasswordValidation 测试修改密码时区分新密码不合规和旧密码错误
+func TestChangePasswordValidation(t *testing.T) {
+	manager := NewUserManager("", nil)
+
+	err := manager.ChangePassword("admin", "old-password", "short")
+	assert.Equal(t, ErrWeakPassword, err)
+
+	// 没有用户存储时无法验证旧密码
+	err = manager.ChangePassword("admin", "old-password", "new-password-123")
+	assert.Equal(t, ErrIncorrectPassword, err)
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"prerender-shield/internal/logging"
+)
+
+const (
+	// configBackupsDir 配置快照目录，位于数据目录下
+	configBackupsDir = "config-backups"
+	// configBackupPrefix 配置快照ID的前缀，ID为前缀加保存时间的Unix纳秒数
+	configBackupPrefix = "config-"
+	// defaultConfigBackupsToKeep 未配置时保留的配置快照数量
+	defaultConfigBackupsToKeep = 10
+)
+
+var (
+	// ErrInvalidConfigBackup 快照ID格式错误或快照内容不是有效的配置
+	ErrInvalidConfigBackup = errors.New("invalid config backup")
+	// ErrConfigBackupNotFound 快照不存在
+	ErrConfigBackupNotFound = errors.New("config backup not found")
+	// ErrNoConfigFile 没有配置文件，无法回滚
+	ErrNoConfigFile = errors.New("no config file to restore")
+)
+
+// configBackupIDPattern 快照ID只允许前缀加数字，防止路径穿越
+var configBackupIDPattern = regexp.MustCompile(`^` + configBackupPrefix + `[0-9]+$`)
+
+// ConfigBackup 保存配置前自动创建的配置文件快照
+type ConfigBackup struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	format    string    // 快照的配置格式，与创建快照时配置文件的格式相同
+}
+
+// backupDirLocked 返回快照目录，未配置数据目录时返回空字符串，调用方需持有cm.mutex
+func (cm *ConfigManager) backupDirLocked() string {
+	if cm.config == nil || cm.config.Dirs.DataDir == "" {
+		return ""
+	}
+	return filepath.Join(cm.config.Dirs.DataDir, configBackupsDir)
+}
+
+// backupsToKeepLocked 返回保留的快照数量，调用方需持有cm.mutex
+func (cm *ConfigManager) backupsToKeepLocked() int {
+	if cm.config != nil && cm.config.Server.ConfigBackupsToKeep > 0 {
+		return cm.config.Server.ConfigBackupsToKeep
+	}
+	return defaultConfigBackupsToKeep
+}
+
+// snapshotConfigLocked 将当前配置文件保存为带时间戳的快照并清理超出数量的旧快照，快照扩展名与配置文件格式一致，
+// 配置文件不存在或与最新快照相同时不创建，调用方需持有cm.mutex
+func (cm *ConfigManager) snapshotConfigLocked() error {
+	dir := cm.backupDirLocked()
+	if dir == "" || cm.configPath == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(cm.configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	backups, err := listConfigBackups(dir)
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 {
+		latest, err := os.ReadFile(backupPath(dir, backups[0].ID, backups[0].format))
+		if err == nil && bytes.Equal(latest, content) {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	id := configBackupPrefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := os.WriteFile(backupPath(dir, id, configFormat(cm.configPath)), content, 0600); err != nil {
+		return err
+	}
+
+	// 新快照排在最前，保留数量减一即为需要保留的旧快照
+	keep := cm.backupsToKeepLocked() - 1
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backupPath(dir, backups[i].ID, backups[i].format)); err != nil && !os.IsNotExist(err) {
+			logging.DefaultLogger.Warn("Failed to remove old config backup %s: %v", backups[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// ListConfigBackups 返回所有配置快照，最新的在前
+func (cm *ConfigManager) ListConfigBackups() ([]ConfigBackup, error) {
+	cm.mutex.RLock()
+	dir := cm.backupDirLocked()
+	cm.mutex.RUnlock()
+
+	if dir == "" {
+		return []ConfigBackup{}, nil
+	}
+	return listConfigBackups(dir)
+}
+
+// RollbackConfig 用快照替换配置文件并按配置文件变化的流程重新加载，
+// 快照必须能解析且通过校验，格式与当前配置文件不同时转换为当前格式；替换前保存当前配置文件的快照，回滚本身也可以撤销
+func (cm *ConfigManager) RollbackConfig(id string) error {
+	if !configBackupIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: %s", ErrInvalidConfigBackup, id)
+	}
+
+	cm.mutex.Lock()
+	dir := cm.backupDirLocked()
+	if cm.configPath == "" || dir == "" {
+		cm.mutex.Unlock()
+		return ErrNoConfigFile
+	}
+
+	content, format, err := readConfigBackup(dir, id)
+	if err != nil {
+		cm.mutex.Unlock()
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrConfigBackupNotFound, id)
+		}
+		return err
+	}
+
+	cfg, err := parseConfig(content, format)
+	if err != nil {
+		cm.mutex.Unlock()
+		return fmt.Errorf("%w: %v", ErrInvalidConfigBackup, err)
+	}
+	if err := cm.ValidateConfig(cfg); err != nil {
+		cm.mutex.Unlock()
+		return fmt.Errorf("%w: %v", ErrInvalidConfigBackup, err)
+	}
+	if target := configFormat(cm.configPath); target != format {
+		if content, err = encodeConfig(cfg, target); err != nil {
+			cm.mutex.Unlock()
+			return err
+		}
+	}
+
+	if err := cm.snapshotConfigLocked(); err != nil {
+		logging.DefaultLogger.Warn("Failed to snapshot config before rollback: %v", err)
+	}
+	if err := writeConfigFile(cm.configPath, content); err != nil {
+		cm.mutex.Unlock()
+		return err
+	}
+	cm.mutex.Unlock()
+
+	// 与配置文件被修改时相同：重新加载配置并通知配置变化处理函数
+	cm.reloadConfig()
+
+	// Redis中的站点配置在启动时优先于配置文件，需要同步为回滚后的站点
+	if cm.redisClient != nil {
+		if err := cm.SaveSitesToRedis(); err != nil {
+			logging.DefaultLogger.Error("Failed to save rolled back sites to Redis: %v", err)
+		}
+	}
+
+	logging.DefaultLogger.Info("Config rolled back to backup %s", id)
+	return nil
+}
+
+// listConfigBackups 读取快照目录，最新的在前，目录不存在时返回空列表
+func listConfigBackups(dir string) ([]ConfigBackup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ConfigBackup{}, nil
+		}
+		return nil, err
+	}
+
+	backups := make([]ConfigBackup, 0, len(entries))
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		format := configFormat(entry.Name())
+		id := strings.TrimSuffix(entry.Name(), ext)
+		if entry.IsDir() || ext != formatExt(format) || !configBackupIDPattern.MatchString(id) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.TrimPrefix(id, configBackupPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, ConfigBackup{ID: id, CreatedAt: time.Unix(0, nanos), Size: info.Size(), format: format})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// backupPath 返回指定格式的快照文件路径
+func backupPath(dir, id, format string) string {
+	return filepath.Join(dir, id+formatExt(format))
+}
+
+// readConfigBackup 读取快照内容，返回快照的配置格式，快照不存在时返回os.ErrNotExist
+func readConfigBackup(dir, id string) ([]byte, string, error) {
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		content, err := os.ReadFile(backupPath(dir, id, format))
+		if err == nil || !os.IsNotExist(err) {
+			return content, format, err
+		}
+	}
+	return nil, "", os.ErrNotExist
+}
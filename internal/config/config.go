@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/redis"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -77,23 +80,35 @@ type DirsConfig struct {
 //   Port: 站点监听的端口号
 //   Mode: 站点运行模式，可选值：proxy(代理模式), static(静态资源模式), redirect(重定向模式)
 //   Proxy: 代理配置，当Mode为proxy时使用
+//   Static: 静态资源配置，当Mode为static时使用
 //   Redirect: 重定向配置，当Mode为redirect时使用
 //   Firewall: 防火墙配置，站点级别的安全防护设置
 //   Prerender: 渲染预热配置，用于SEO优化
 //   Routing: 路由配置，用于自定义请求路由
 //   FileIntegrityConfig: 网页防篡改配置，用于保护静态资源完整性
+//   VisitLogScope: 访问日志记录范围，可选值：all(全部), humans(仅普通用户), crawlers(仅爬虫), none(不记录)
+//   RefererFilter: 访问日志来源过滤配置，用于排除垃圾来源对访问统计的干扰
+//   ProbePaths: 健康检查探测路径，直接返回200，不经过WAF、爬虫检测和访问日志
+//   ExcludePatterns: 排除的URL规则，匹配的URL不被爬虫记录、不预热渲染、不推送到搜索引擎
+//   TLS: HTTPS配置，启用后站点端口使用TLS提供服务
 
 type SiteConfig struct {
 	// 站点基本信息
 	ID      string   `yaml:"id" json:"id"` // 站点唯一ID
 	Name    string   `yaml:"name" json:"name"`
 	Domains []string `yaml:"domains" json:"domains"` // 支持多个域名解析到同一个站点
+	// 是否启用站点，未设置时视为启用；停用的站点不启动服务器和引擎，但保留配置和静态资源
+	Enabled *bool `yaml:"enabled,omitempty" json:"enabled,omitempty"`
 	// 站点端口配置，支持一个站点一个端口
 	Port int `yaml:"port" json:"port"`
 	// 站点模式：proxy(代理已有应用), static(静态资源站), redirect(重定向)
 	Mode string `yaml:"mode" json:"mode"`
 	// 代理配置
 	Proxy ProxyConfig `yaml:"proxy" json:"proxy"`
+	// 静态资源配置
+	Static StaticConfig `yaml:"static" json:"static"`
+	// 静态资源目录只读，文件由外部进程或挂载卷提供，管理接口不允许上传、解压、删除、移动和创建目录
+	StaticReadOnly bool `yaml:"static_read_only" json:"static_read_only"`
 	// 重定向配置
 	Redirect RedirectConfig `yaml:"redirect" json:"redirect"`
 	// 防火墙配置
@@ -104,6 +119,135 @@ type SiteConfig struct {
 	Routing RoutingConfig `yaml:"routing" json:"routing"`
 	// 网页防篡改配置
 	FileIntegrityConfig FileIntegrityConfig `yaml:"file_integrity" json:"file_integrity"`
+	// 访问日志记录范围，为空时记录全部请求
+	VisitLogScope string `yaml:"visit_log_scope" json:"visit_log_scope"`
+	// 访问日志来源过滤配置
+	RefererFilter RefererFilterConfig `yaml:"referer_filter" json:"referer_filter"`
+	// 负载均衡健康检查路径，如 /healthz
+	ProbePaths []string `yaml:"probe_paths" json:"probe_paths"`
+	// 排除的URL规则，以/开头的规则同push.target_rules（前缀或*通配符匹配路径），其他规则作为正则表达式匹配路由（含查询参数），
+	// 如 /admin/、/api/*、^/(login|logout)；匹配的URL不被爬虫记录、不预热渲染、不推送到搜索引擎
+	ExcludePatterns []string `yaml:"exclude_patterns" json:"exclude_patterns"`
+	// HTTPS配置
+	TLS SiteTLSConfig `yaml:"tls" json:"tls"`
+}
+
+// SiteTLSConfig 站点HTTPS配置
+// 证书和私钥可以使用文件路径（相对路径基于Dirs.CertsDir）或内联PEM内容，内联PEM优先；
+// 启用ACME时证书自动签发并保存在 Dirs.CertsDir/<站点ID>/ 下，签发成功后站点开始使用HTTPS
+type SiteTLSConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	ACME     bool   `yaml:"acme" json:"acme"`
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+	CertPEM  string `yaml:"cert_pem,omitempty" json:"cert_pem,omitempty"`
+	KeyPEM   string `yaml:"key_pem,omitempty" json:"-"` // 私钥不通过API返回
+}
+
+// 上传和ACME签发的证书在站点证书目录中的文件名
+const (
+	SiteCertFileName = "cert.pem"
+	SiteKeyFileName  = "key.pem"
+)
+
+// CertificateFiles 返回站点证书和私钥的文件路径，ACME签发的证书固定保存在站点ID命名的目录下
+func (s SiteConfig) CertificateFiles() (certFile, keyFile string) {
+	if s.TLS.ACME {
+		return filepath.Join(s.ID, SiteCertFileName), filepath.Join(s.ID, SiteKeyFileName)
+	}
+	return s.TLS.CertFile, s.TLS.KeyFile
+}
+
+// IsEnabled 判断站点是否启用，未设置时默认启用
+func (s SiteConfig) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// SetEnabled 设置站点启用状态
+func (s *SiteConfig) SetEnabled(enabled bool) {
+	s.Enabled = &enabled
+}
+
+// 访问日志记录范围
+const (
+	VisitLogScopeAll      = "all"      // 记录全部请求
+	VisitLogScopeHumans   = "humans"   // 仅记录普通用户请求
+	VisitLogScopeCrawlers = "crawlers" // 仅记录爬虫请求
+	VisitLogScopeNone     = "none"     // 不记录访问日志
+)
+
+// RefererFilterConfig 访问日志来源(Referer)过滤配置
+type RefererFilterConfig struct {
+	Blocklist   []string `yaml:"blocklist" json:"blocklist"`       // 垃圾来源域名，同时匹配其子域名
+	Action      string   `yaml:"action" json:"action"`             // 命中后的处理方式：drop(不记录，默认) 或 flag(记录但不计入统计)
+	SelfReferer string   `yaml:"self_referer" json:"self_referer"` // 来源为本站的访问：include(计入，默认) 或 exclude(按Action处理)
+}
+
+// 来源过滤处理方式
+const (
+	RefererActionDrop = "drop" // 不记录访问日志
+	RefererActionFlag = "flag" // 记录访问日志但不计入PV/UV/IP统计
+)
+
+// PreheatTarget 根据站点模式返回预热爬取使用的baseURL和Domain
+func (s *SiteConfig) PreheatTarget() (baseURL, domain string) {
+	switch s.Mode {
+	case "proxy":
+		// 代理模式下，使用第一个后端的地址
+		if upstreams := s.Proxy.UpstreamList(); len(upstreams) > 0 {
+			return upstreams[0].URL, upstreams[0].URL
+		}
+		return "", ""
+	case "redirect":
+		// 重定向模式下，使用重定向的目标URL
+		return s.Redirect.TargetURL, s.Redirect.TargetURL
+	default:
+		// 静态模式下，使用站点的域名和端口，未配置域名时使用localhost
+		host := "localhost"
+		if len(s.Domains) > 0 {
+			host = s.Domains[0]
+		}
+		domain = fmt.Sprintf("%s:%d", host, s.Port)
+		return fmt.Sprintf("http://%s", domain), domain
+	}
+}
+
+// ApplyDefaults 用默认配置填充站点未设置（零值）的渲染预热和防火墙配置项，嵌套配置逐项合并
+// 布尔值false视为未设置，因此默认开启的开关在新建站点时不能通过false关闭
+func (s *SiteConfig) ApplyDefaults(prerender PrerenderConfig, firewall FirewallConfig) {
+	mergeZeroFields(reflect.ValueOf(&s.Prerender).Elem(), reflect.ValueOf(prerender))
+	mergeZeroFields(reflect.ValueOf(&s.Firewall).Elem(), reflect.ValueOf(firewall))
+}
+
+// mergeZeroFields 将src的字段值复制到dst中为零值的字段，结构体字段递归合并
+func mergeZeroFields(dst, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field, value := dst.Field(i), src.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			mergeZeroFields(field, value)
+		} else if field.IsZero() && !value.IsZero() {
+			field.Set(cloneValue(value))
+		}
+	}
+}
+
+// cloneValue 复制切片和映射，避免站点与默认配置共享底层数据
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		return reflect.AppendSlice(reflect.MakeSlice(v.Type(), 0, v.Len()), v)
+	case reflect.Map:
+		clone := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			clone.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return clone
+	}
+	return v
 }
 
 // FileIntegrityConfig 网页防篡改配置结构体
@@ -137,9 +281,57 @@ type RedirectConfig struct {
 //
 // 字段:
 //   TargetURL: 代理目标URL，即真实后端服务地址
+//   PreserveHost: 是否将客户端请求的Host转发给后端，默认使用后端地址的Host
+//   Headers: 添加到后端请求的请求头
+//   StripPrefix: 转发前从请求路径中移除的前缀
+//   Timeout: 等待后端响应头的超时时间（秒），0表示不限制
+//   Upstreams: 负载均衡的后端列表，配置后代替TargetURL，按权重轮询
+//   HealthCheckInterval: 后端健康检查间隔（秒），0表示使用默认值10秒
 
 type ProxyConfig struct {
-	TargetURL string `yaml:"target_url" json:"target_url"`
+	TargetURL           string            `yaml:"target_url" json:"target_url"`
+	PreserveHost        bool              `yaml:"preserve_host" json:"preserve_host"`
+	Headers             map[string]string `yaml:"headers" json:"headers"`
+	StripPrefix         string            `yaml:"strip_prefix" json:"strip_prefix"`
+	Timeout             int               `yaml:"timeout" json:"timeout"`
+	Upstreams           []UpstreamConfig  `yaml:"upstreams" json:"upstreams"`
+	HealthCheckInterval int               `yaml:"health_check_interval" json:"health_check_interval"`
+}
+
+// UpstreamConfig 代理后端配置
+//
+// 字段:
+//   URL: 后端地址
+//   Weight: 轮询权重，0表示使用默认值1
+//   HealthCheckPath: 健康检查路径，返回2xx或3xx视为健康；为空时只检查TCP连接
+
+type UpstreamConfig struct {
+	URL             string `yaml:"url" json:"url"`
+	Weight          int    `yaml:"weight" json:"weight"`
+	HealthCheckPath string `yaml:"health_check_path" json:"health_check_path"`
+}
+
+// StaticConfig 静态资源站模式配置
+//
+// 字段:
+//   CompressionLevel: gzip实时压缩级别（1-9），0表示使用默认级别，-1表示关闭实时压缩；预压缩的.br/.gz文件始终优先使用
+//   CacheControl: 按扩展名（如".js"）设置的Cache-Control，未配置的扩展名使用默认策略：
+//     带内容哈希的文件名长期缓存，.html每次重新验证，其余文件缓存1小时
+
+type StaticConfig struct {
+	CompressionLevel int               `yaml:"compression_level" json:"compression_level"`
+	CacheControl     map[string]string `yaml:"cache_control" json:"cache_control"`
+}
+
+// UpstreamList 返回代理后端列表，未配置Upstreams时将TargetURL作为唯一的后端，兼容旧配置
+func (p ProxyConfig) UpstreamList() []UpstreamConfig {
+	if len(p.Upstreams) > 0 {
+		return p.Upstreams
+	}
+	if p.TargetURL == "" {
+		return nil
+	}
+	return []UpstreamConfig{{URL: p.TargetURL}}
 }
 
 // Config 应用全局配置结构体
@@ -155,19 +347,30 @@ type ProxyConfig struct {
 
 type Config struct {
 	// 服务器配置
-	Server ServerConfig `yaml:"server"`
+	Server ServerConfig `yaml:"server" json:"server"`
 	// 目录配置
-	Dirs DirsConfig `yaml:"dirs"`
+	Dirs DirsConfig `yaml:"dirs" json:"dirs"`
 	// 缓存配置
-	Cache CacheConfig `yaml:"cache"`
+	Cache CacheConfig `yaml:"cache" json:"cache"`
 	// 存储配置
-	Storage StorageConfig `yaml:"storage"`
+	Storage StorageConfig `yaml:"storage" json:"storage"`
 	// 监控配置
-	Monitoring MonitoringConfig `yaml:"monitoring"`
+	Monitoring MonitoringConfig `yaml:"monitoring" json:"monitoring"`
 	// 应用配置
-	App AppConfig `yaml:"app"`
+	App AppConfig `yaml:"app" json:"app"`
+	// 运行日志配置
+	Logging LoggingConfig `yaml:"logging" json:"logging"`
+	// GeoIP查询配置
+	GeoIP GeoIPLookupConfig `yaml:"geoip" json:"geoip"`
+	// 渲染API配置
+	RenderAPI RenderAPIConfig `yaml:"render_api" json:"render_api"`
+	// ACME自动签发证书配置
+	ACME ACMEConfig `yaml:"acme" json:"acme"`
+	// 新建站点未设置的渲染预热和防火墙配置项使用的默认值
+	DefaultPrerenderConfig PrerenderConfig `yaml:"default_prerender" json:"default_prerender"`
+	DefaultFirewallConfig  FirewallConfig  `yaml:"default_firewall" json:"default_firewall"`
 	// 站点列表
-	Sites []SiteConfig `yaml:"sites"`
+	Sites []SiteConfig `yaml:"sites" json:"sites"`
 }
 
 // AppConfig 应用全局配置
@@ -178,9 +381,55 @@ type AppConfig struct {
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Address     string `yaml:"address"`
-	APIPort     int    `yaml:"api_port"`
-	ConsolePort int    `yaml:"console_port"`
+	Address     string `yaml:"address" json:"address"`
+	APIPort     int    `yaml:"api_port" json:"api_port"`
+	ConsolePort int    `yaml:"console_port" json:"console_port"`
+	// 仅允许127.0.0.1或localhost作为站点域名，用于本地演示环境
+	RestrictLocalDomains bool `yaml:"restrict_local_domains" json:"restrict_local_domains"`
+	// 可信代理的IP或CIDR网段，只有来自可信代理的请求才采用X-Forwarded-For和X-Real-IP中的客户端IP，为空时使用TCP对端地址
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+	// 概览数据缓存时间（秒），在此时间内的重复请求共享同一次统计结果，为0时使用默认值
+	OverviewCacheTTL int `yaml:"overview_cache_ttl" json:"overview_cache_ttl"`
+	// 推送统计和趋势的缓存时间（秒），在此时间内的重复请求直接读取缓存，为0时使用默认值10秒
+	PushStatsCacheTTL int `yaml:"push_stats_cache_ttl" json:"push_stats_cache_ttl"`
+	// 站点端口绑定失败后的自动重试次数和首次重试间隔（秒），之后每次间隔翻倍
+	SiteBindRetries    int `yaml:"site_bind_retries" json:"site_bind_retries"`
+	SiteBindRetryDelay int `yaml:"site_bind_retry_delay" json:"site_bind_retry_delay"`
+	// 全局同时进行的静态资源解压任务数量，超出的任务排队等待，为0时使用默认值
+	MaxConcurrentExtractions int `yaml:"max_concurrent_extractions" json:"max_concurrent_extractions"`
+	// 单次解压允许写入的最大大小（MB），防止解压炸弹，为0时使用默认值1024MB
+	MaxExtractSizeMB int `yaml:"max_extract_size_mb" json:"max_extract_size_mb"`
+	// 单次解压允许写入的最大文件数，为0时使用默认值100000
+	MaxExtractFiles int `yaml:"max_extract_files" json:"max_extract_files"`
+	// 整站发布时每个站点保留的历史版本数量，用于回滚，为0时使用默认值3
+	StaticReleasesToKeep int `yaml:"static_releases_to_keep" json:"static_releases_to_keep"`
+	// 每次保存配置前在data_dir/config-backups下保留的配置快照数量，用于回滚，为0时使用默认值10
+	ConfigBackupsToKeep int `yaml:"config_backups_to_keep" json:"config_backups_to_keep"`
+	// 静态资源上传允许的文件扩展名（如".html"），为空时使用默认的网页资源、媒体和归档文件列表
+	UploadAllowedExtensions []string `yaml:"upload_allowed_extensions" json:"upload_allowed_extensions"`
+	// 静态资源上传禁止的文件扩展名，优先于允许列表，为空时使用默认的服务端脚本和可执行文件列表
+	UploadBlockedExtensions []string `yaml:"upload_blocked_extensions" json:"upload_blocked_extensions"`
+	// 上传文件和解压内容的病毒扫描，在文件可以被访问之前执行
+	UploadScan UploadScanConfig `yaml:"upload_scan" json:"upload_scan"`
+}
+
+// 上传扫描出错时的处理方式
+const (
+	UploadScanFailOpen   = "open"
+	UploadScanFailClosed = "closed"
+)
+
+// UploadScanConfig 上传文件病毒扫描配置，Command和URL二选一，同时配置时使用Command
+type UploadScanConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// 扫描命令，文件路径作为最后一个参数追加，退出码0表示无毒、1表示发现病毒（与clamdscan一致）
+	Command []string `yaml:"command" json:"command"`
+	// HTTP扫描服务地址，文件内容以POST请求体发送，响应JSON为{"infected": bool, "signature": string}
+	URL string `yaml:"url" json:"url"`
+	// 单个文件的扫描超时时间（秒），为0时使用默认值60秒
+	Timeout int `yaml:"timeout" json:"timeout"`
+	// 扫描出错时的处理方式：closed（默认）将文件隔离，open记录日志后放行
+	FailPolicy string `yaml:"fail_policy" json:"fail_policy"`
 }
 
 // FirewallConfig 防火墙配置
@@ -192,6 +441,21 @@ type FirewallConfig struct {
 	RateLimitConfig RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
 	Blacklist       []string        `yaml:"blacklist" json:"blacklist"`
 	Whitelist       []string        `yaml:"whitelist" json:"whitelist"`
+	// 已验证爬虫绕过规则检测配置
+	CrawlerBypass CrawlerBypassConfig `yaml:"crawler_bypass" json:"crawler_bypass"`
+}
+
+// CrawlerBypassConfig 已验证爬虫绕过WAF配置
+// 通过反向DNS验证的搜索引擎爬虫将跳过OWASP规则检测，但仍受频率限制约束；
+// 可信爬虫列表和验证结果缓存时间使用prerender.crawler_verification中的配置
+type CrawlerBypassConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// VerifiedCrawler 可信爬虫定义
+type VerifiedCrawler struct {
+	UserAgent string   `yaml:"user_agent" json:"user_agent"` // User-Agent关键字，不区分大小写
+	Domains   []string `yaml:"domains" json:"domains"`       // 反向DNS解析结果允许的域名后缀
 }
 
 // GeoIPConfig 地理位置访问控制配置
@@ -207,12 +471,43 @@ type RateLimitConfig struct {
 	Requests int  `yaml:"requests" json:"requests"` // 时间窗口内允许的请求数
 	Window   int  `yaml:"window" json:"window"`     // 时间窗口（秒）
 	BanTime  int  `yaml:"ban_time" json:"ban_time"` // 封禁时间（秒）
+	// 按路径覆盖的频率限制，如登录、搜索接口使用比静态资源更严格的限制
+	Paths []RateLimitPathRule `yaml:"paths" json:"paths"`
+}
+
+// RateLimitPathRule 路径匹配Pattern的请求使用的频率限制，Pattern规则与推送目标规则相同
+type RateLimitPathRule struct {
+	Pattern  string `yaml:"pattern" json:"pattern"`
+	Requests int    `yaml:"requests" json:"requests"` // 时间窗口内允许的请求数
+	Window   int    `yaml:"window" json:"window"`     // 时间窗口（秒），为0时使用全局时间窗口
+}
+
+// LimitFor 返回请求路径适用的频率限制，多条路径规则匹配时使用Pattern最长的规则，
+// 未匹配任何规则时使用全局限制并返回空的pattern
+func (c RateLimitConfig) LimitFor(urlPath string) (pattern string, requests, window int) {
+	requests, window = c.Requests, c.Window
+	matched := -1
+	for _, rule := range c.Paths {
+		if len(rule.Pattern) <= matched || !matchPathPattern(rule.Pattern, urlPath) {
+			continue
+		}
+		matched = len(rule.Pattern)
+		pattern, requests, window = rule.Pattern, rule.Requests, rule.Window
+		if window <= 0 {
+			window = c.Window
+		}
+	}
+	return pattern, requests, window
 }
 
 // ActionConfig 防火墙动作配置
 type ActionConfig struct {
-	DefaultAction string `yaml:"default_action" json:"default_action"`
+	DefaultAction string `yaml:"default_action" json:"default_action"` // block或challenge（返回JS挑战页面，通过后放行）
 	BlockMessage  string `yaml:"block_message" json:"block_message"`
+	ChallengeTTL  int    `yaml:"challenge_ttl" json:"challenge_ttl"` // 挑战通过后令牌的有效期（秒），0表示30分钟
+	// 拦截页面模板，相对静态文件目录的路径，使用html/template语法，可用字段见firewall.BlockPageData
+	BlockPagePath string `yaml:"block_page_path" json:"block_page_path"`
+	ContactURL    string `yaml:"contact_url" json:"contact_url"` // 拦截页面中的联系链接
 }
 
 // PrerenderConfig 渲染预热配置
@@ -231,6 +526,41 @@ type PrerenderConfig struct {
 	Push              PushConfig    `yaml:"push" json:"push"`
 	CrawlerHeaders    []string      `yaml:"crawler_headers" json:"crawler_headers"`         // 爬虫协议头列表
 	UseDefaultHeaders bool          `yaml:"use_default_headers" json:"use_default_headers"` // 是否使用默认爬虫协议头
+	// URL规范化配置
+	IgnoredQueryParams     []string `yaml:"ignored_query_params" json:"ignored_query_params"`         // 缓存时忽略的查询参数，支持*前缀匹配，为空时忽略utm_*、gclid、fbclid
+	SignificantQueryParams []string `yaml:"significant_query_params" json:"significant_query_params"` // 影响渲染结果的查询参数（如page、sort），支持*前缀匹配，非空时其余参数在渲染前移除
+	CollapseTrailingSlash  bool     `yaml:"collapse_trailing_slash" json:"collapse_trailing_slash"`   // 是否合并URL末尾斜杠
+	// 按爬虫分组（User-Agent）公平分配渲染能力，避免单个爬虫占满渲染池
+	FairQueueing bool `yaml:"fair_queueing" json:"fair_queueing"`
+	// 渲染队列已满时等待入队的最长时间（秒），超时后直接返回原始页面，0表示使用默认值5秒
+	MaxQueueWait int `yaml:"max_queue_wait" json:"max_queue_wait"`
+	// 向所有访客（不仅是爬虫）返回预渲染HTML，加快首屏显示，默认关闭
+	ServeRenderedToAll bool `yaml:"serve_rendered_to_all" json:"serve_rendered_to_all"`
+	// 不写入缓存的源站状态码，4xx和5xx始终不缓存并将状态码返回给爬虫
+	NoCacheStatuses []int `yaml:"no_cache_statuses" json:"no_cache_statuses"`
+	// 视为HTML的页面Content-Type，为空时使用text/html和application/xhtml+xml，其他类型的页面返回not_html错误
+	HTMLContentTypes []string `yaml:"html_content_types" json:"html_content_types"`
+	// 页面不是HTML时在渲染结果中携带源站原始内容，渲染接口据此原样返回
+	PassThroughNonHTML bool `yaml:"pass_through_non_html" json:"pass_through_non_html"`
+	// 声称是搜索引擎爬虫的请求通过反向DNS验证后才返回预渲染结果
+	CrawlerVerification CrawlerVerificationConfig `yaml:"crawler_verification" json:"crawler_verification"`
+}
+
+// 爬虫验证失败时的处理方式
+const (
+	CrawlerVerifyPolicySPA   = "spa"   // 按普通访客返回原始页面，不进行预渲染
+	CrawlerVerifyPolicyBlock = "block" // 返回403
+	CrawlerVerifyPolicyLog   = "log"   // 只记录，仍按爬虫处理
+)
+
+// CrawlerVerificationConfig 爬虫身份验证配置
+// User-Agent匹配可信爬虫的请求需要反向DNS解析到爬虫域名并正向解析回同一IP，验证结果在Redis中缓存；
+// Crawlers和CacheTTL同时用于防火墙的已验证爬虫绕过（firewall.crawler_bypass），Enabled只控制预渲染前的验证
+type CrawlerVerificationConfig struct {
+	Enabled  bool              `yaml:"enabled" json:"enabled"`
+	Policy   string            `yaml:"policy" json:"policy"`       // spa（默认）、block或log
+	Crawlers []VerifiedCrawler `yaml:"crawlers" json:"crawlers"`   // 为空时使用内置的主流搜索引擎爬虫列表
+	CacheTTL int               `yaml:"cache_ttl" json:"cache_ttl"` // 验证结果缓存时间（秒），为0时使用默认值24小时
 }
 
 // PreheatConfig 缓存预热配置
@@ -241,6 +571,14 @@ type PreheatConfig struct {
 	Concurrency     int    `yaml:"concurrency" json:"concurrency"`
 	DefaultPriority int    `yaml:"default_priority" json:"default_priority"`
 	MaxDepth        int    `yaml:"max_depth" json:"max_depth"` // 爬取深度
+	// 配置SitemapURL时跳过lastmod未晚于上次缓存时间的URL
+	SkipUnchanged bool `yaml:"skip_unchanged" json:"skip_unchanged"`
+	// 链接爬虫最多发现的URL数量，达到后停止发现新URL，0表示不限制
+	MaxURLs int `yaml:"max_urls" json:"max_urls"`
+	// 链接爬虫遵守站点robots.txt，跳过UserAgent被禁止访问的路径
+	RespectRobots bool `yaml:"respect_robots" json:"respect_robots"`
+	// 匹配robots.txt规则组使用的User-Agent，为空时只使用"*"规则组
+	UserAgent string `yaml:"user_agent" json:"user_agent"`
 }
 
 // PushConfig 搜索引擎推送配置
@@ -252,7 +590,129 @@ type PushConfig struct {
 	BingToken       string `yaml:"bing_token" json:"bing_token"`
 	BaiduDailyLimit int    `yaml:"baidu_daily_limit" json:"baidu_daily_limit"`
 	BingDailyLimit  int    `yaml:"bing_daily_limit" json:"bing_daily_limit"`
-	PushDomain      string `yaml:"push_domain" json:"push_domain"`
+	BatchSize       int    `yaml:"batch_size" json:"batch_size"`     // 百度、必应单次请求提交的URL数量
+	MaxAttempts     int    `yaml:"max_attempts" json:"max_attempts"` // 百度、必应遇到429/5xx时单批最多尝试的次数
+	// 同一URL推送到同一搜索引擎的最小间隔天数，0表示每天最多推送一次；今日已推送的URL计入每日限额
+	MinRepushDays int    `yaml:"min_repush_days" json:"min_repush_days"`
+	PushDomain    string `yaml:"push_domain" json:"push_domain"`
+	// Google Indexing API推送配置，服务账号支持填写JSON内容或JSON文件路径
+	GoogleServiceAccountJSON string `yaml:"google_service_account_json" json:"google_service_account_json"`
+	GoogleDailyLimit         int    `yaml:"google_daily_limit" json:"google_daily_limit"`
+	// IndexNow推送配置，密钥验证文件会写入站点静态目录
+	IndexNowEnabled bool   `yaml:"indexnow_enabled" json:"indexnow_enabled"`
+	IndexNowKey     string `yaml:"indexnow_key" json:"indexnow_key"`
+	// 按URL路径选择推送的搜索引擎，按顺序使用第一条匹配的规则，未匹配任何规则的URL推送到所有已配置的搜索引擎
+	TargetRules []PushTargetRule `yaml:"target_rules" json:"target_rules"`
+	// 每日限额分成多少个子批次在一天中均匀推送（如24表示每小时推送限额的1/24），0或1表示每天8点一次推送全部限额
+	DailyBatches int `yaml:"daily_batches" json:"daily_batches"`
+	// 单次推送请求的超时时间（秒），0表示使用默认的30秒
+	Timeout int `yaml:"timeout" json:"timeout"`
+}
+
+// MaxPushDailyBatches 每日限额最多拆分的子批次数，即每分钟推送一次
+const MaxPushDailyBatches = 1440
+
+// 推送目标规则可选的搜索引擎
+const (
+	PushEngineBaidu    = "baidu"
+	PushEngineBing     = "bing"
+	PushEngineGoogle   = "google"
+	PushEngineIndexNow = "indexnow"
+)
+
+// PushTargetRule 推送目标规则，路径匹配Pattern的URL只推送到Engines中的搜索引擎
+// Pattern为路径前缀（如 /en/），包含*时按通配符匹配整个路径（如 /*/news/*）
+type PushTargetRule struct {
+	Pattern string   `yaml:"pattern" json:"pattern"`
+	Engines []string `yaml:"engines" json:"engines"`
+}
+
+// Matches 判断URL路径是否匹配规则
+func (r PushTargetRule) Matches(urlPath string) bool {
+	return matchPathPattern(r.Pattern, urlPath)
+}
+
+// matchPathPattern 判断URL路径是否匹配路径规则：包含*时按通配符匹配整个路径，否则按前缀匹配
+func matchPathPattern(pattern, urlPath string) bool {
+	if strings.Contains(pattern, "*") {
+		matched, err := path.Match(pattern, urlPath)
+		return err == nil && matched
+	}
+	return strings.HasPrefix(urlPath, pattern)
+}
+
+// IsExcluded 判断路由是否匹配站点的排除规则
+func (s SiteConfig) IsExcluded(route string) bool {
+	return MatchExcludePatterns(s.ExcludePatterns, route)
+}
+
+// MatchExcludePatterns 判断路由是否匹配任一排除规则：以/开头的规则按路径规则匹配路由的路径部分，
+// 其他规则作为正则表达式匹配整个路由（含查询参数）
+func MatchExcludePatterns(patterns []string, route string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	if !strings.HasPrefix(route, "/") {
+		route = "/" + route
+	}
+	urlPath := route
+	if i := strings.IndexAny(urlPath, "?#"); i >= 0 {
+		urlPath = urlPath[:i]
+	}
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "/") {
+			if matchPathPattern(pattern, urlPath) {
+				return true
+			}
+			continue
+		}
+		if matched, err := regexp.MatchString(pattern, route); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExcludePattern 检查排除规则的路径通配符或正则表达式语法有效
+func validateExcludePattern(pattern string) error {
+	if strings.HasPrefix(pattern, "/") {
+		return validatePathPattern(pattern)
+	}
+	if pattern == "" {
+		return fmt.Errorf("pattern must not be empty")
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("pattern %q is invalid: %v", pattern, err)
+	}
+	return nil
+}
+
+// validatePathPattern 检查路径规则以/开头且通配符语法有效
+func validatePathPattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("pattern %q must start with /", pattern)
+	}
+	if _, err := path.Match(pattern, "/"); err != nil {
+		return fmt.Errorf("pattern %q is invalid: %v", pattern, err)
+	}
+	return nil
+}
+
+// ShouldPushTo 判断URL路径是否应推送到指定搜索引擎，未匹配任何规则时推送到所有搜索引擎，
+// 匹配的规则未配置搜索引擎时不推送
+func (p PushConfig) ShouldPushTo(urlPath, engine string) bool {
+	for _, rule := range p.TargetRules {
+		if !rule.Matches(urlPath) {
+			continue
+		}
+		for _, target := range rule.Engines {
+			if target == engine {
+				return true
+			}
+		}
+		return false
+	}
+	return true
 }
 
 // RoutingConfig 路由配置
@@ -270,24 +730,78 @@ type RouteRule struct {
 
 // CacheConfig 缓存配置
 type CacheConfig struct {
-	Type          string `yaml:"type"`
-	RedisURL      string `yaml:"redis_url"`
-	RedisPassword string `yaml:"redis_password"`
-	RedisDB       int    `yaml:"redis_db"`
-	MemorySize    int    `yaml:"memory_size"`
+	Type          string `yaml:"type" json:"type"`
+	RedisURL      string `yaml:"redis_url" json:"redis_url"`
+	RedisPassword string `yaml:"redis_password" json:"redis_password"`
+	RedisDB       int    `yaml:"redis_db" json:"redis_db"`
+	MemorySize    int    `yaml:"memory_size" json:"memory_size"`
 }
 
 // StorageConfig 存储配置
 type StorageConfig struct {
-	Type string `yaml:"type"`
+	Type string `yaml:"type" json:"type"`
 }
 
 // MonitoringConfig 监控配置
 type MonitoringConfig struct {
-	Enabled           bool   `yaml:"enabled"`
-	PrometheusAddress string `yaml:"prometheus_address"`
+	Enabled           bool   `yaml:"enabled" json:"enabled"`
+	PrometheusAddress string `yaml:"prometheus_address" json:"prometheus_address"`
 }
 
+// LoggingConfig 运行日志配置，日志级别可以通过 PUT /api/v1/logs/level 在运行时修改
+type LoggingConfig struct {
+	// 默认日志级别：debug、info、warn、error，为空时使用info
+	Level string `yaml:"level" json:"level"`
+	// 日志格式：console（文本，默认）或json
+	Format string `yaml:"format" json:"format"`
+	// 模块单独的日志级别，模块包括prerender、firewall、api、site
+	Modules map[string]string `yaml:"modules" json:"modules"`
+	// 除标准输出外同时写入data_dir/logs下的日志文件
+	File LogFileConfig `yaml:"file" json:"file"`
+	// 审计日志持久化配置
+	Audit AuditLogConfig `yaml:"audit" json:"audit"`
+}
+
+// LogFileConfig 日志文件配置，文件按大小轮转
+type LogFileConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// 单个日志文件的最大大小（MB），为0时使用默认值100MB
+	MaxSizeMB int `yaml:"max_size_mb" json:"max_size_mb"`
+	// 轮转后保留的历史日志文件数量，为0时使用默认值5
+	MaxBackups int `yaml:"max_backups" json:"max_backups"`
+}
+
+// AuditLogConfig 审计日志配置，审计日志持久化到data_dir/logs/audit.log，重启后保留
+type AuditLogConfig struct {
+	// 审计日志保留天数，为0时使用默认值90天
+	RetentionDays int `yaml:"retention_days" json:"retention_days"`
+}
+
+// GeoIPLookupConfig GeoIP查询配置，配置本地数据库时优先本地查询，用于访问日志清洗时限制外部API调用
+type GeoIPLookupConfig struct {
+	DatabasePath     string `yaml:"database_path" json:"database_path"`           // 本地MaxMind GeoLite2数据库（.mmdb）路径，文件变化时自动重新加载
+	Concurrency      int    `yaml:"concurrency" json:"concurrency"`               // 日志清洗时最大并发查询数
+	RateLimit        int    `yaml:"rate_limit" json:"rate_limit"`                 // 每分钟最多调用外部API的次数
+	NegativeCacheTTL int    `yaml:"negative_cache_ttl" json:"negative_cache_ttl"` // 查询失败的IP在该时间内不再查询（秒）
+}
+
+// RenderAPIConfig 兼容prerender.io中间件的渲染API配置（GET /render?url=...）
+type RenderAPIConfig struct {
+	Enabled bool   `yaml:"enabled" json:"enabled"`
+	Path    string `yaml:"path" json:"path"`   // 接口路径，默认/render
+	Token   string `yaml:"token" json:"token"` // 访问令牌，通过X-Prerender-Token请求头传递，为空时不校验
+}
+
+// ACMEConfig ACME自动签发证书配置，站点设置 tls.acme: true 后通过HTTP-01验证签发证书
+type ACMEConfig struct {
+	Email        string `yaml:"email" json:"email"`                 // 账户联系邮箱，用于接收证书过期提醒
+	DirectoryURL string `yaml:"directory_url" json:"directory_url"` // ACME服务目录地址，默认Let's Encrypt
+	RenewBefore  int    `yaml:"renew_before" json:"renew_before"`   // 证书剩余有效期少于该天数时续签
+}
+
+// DefaultACMEDirectoryURL Let's Encrypt生产环境目录地址
+const DefaultACMEDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+
 // GetInstance 获取配置管理器实例
 type ConfigManagerInterface interface {
 	GetConfig() *Config
@@ -307,7 +821,7 @@ func GetInstance() *ConfigManager {
 	return instance
 }
 
-// LoadConfig 从环境变量和YAML配置文件加载配置
+// LoadConfig 从环境变量和YAML或JSON配置文件加载配置，按扩展名判断配置文件格式
 func LoadConfig(configPath string) (*Config, error) {
 	manager := GetInstance()
 	manager.mutex.Lock()
@@ -374,7 +888,7 @@ func LoadConfig(configPath string) (*Config, error) {
 			return nil, fmt.Errorf("读取配置文件失败: %s, 错误: %v", configPath, err)
 		}
 
-		if err := yaml.Unmarshal(file, cfg); err != nil {
+		if err := decodeConfig(file, configFormat(configPath), cfg); err != nil {
 			return nil, fmt.Errorf("解析配置文件失败: %s, 错误: %v", configPath, err)
 		}
 
@@ -464,59 +978,6 @@ func (cm *ConfigManager) GetConfig() *Config {
 	return cm.config
 }
 
-// ValidateConfig 验证配置的合法性
-func (cm *ConfigManager) ValidateConfig(config *Config) error {
-	// 验证服务器配置
-	if config.Server.Address == "" {
-		config.Server.Address = "0.0.0.0" // 使用默认地址
-	}
-
-	// 验证站点配置
-	for i, site := range config.Sites {
-		// 验证站点ID
-		if site.ID == "" {
-			return fmt.Errorf("site at index %d has no ID", i)
-		}
-
-		// 验证站点名称
-		if site.Name == "" {
-			return fmt.Errorf("site %s has no name", site.ID)
-		}
-
-		// 验证站点域名
-		if len(site.Domains) == 0 {
-			return fmt.Errorf("site %s has no domains", site.ID)
-		}
-
-		// 验证站点模式
-		validModes := map[string]bool{"proxy": true, "static": true, "redirect": true}
-		if !validModes[site.Mode] {
-			return fmt.Errorf("site %s has invalid mode: %s", site.ID, site.Mode)
-		}
-
-		// 根据站点模式验证特定配置
-		switch site.Mode {
-		case "proxy":
-			if site.Proxy.TargetURL == "" {
-				return fmt.Errorf("site %s is in proxy mode but has no target URL", site.ID)
-			}
-		case "redirect":
-			if site.Redirect.TargetURL == "" {
-				return fmt.Errorf("site %s is in redirect mode but has no target URL", site.ID)
-			}
-		}
-
-		// 验证渲染预热配置
-		if site.Prerender.Enabled {
-			if site.Prerender.PoolSize < 1 {
-				site.Prerender.PoolSize = 1 // 使用默认值
-			}
-		}
-	}
-
-	return nil
-}
-
 // SetRedisClient 设置Redis客户端
 func (cm *ConfigManager) SetRedisClient(client *redis.Client) {
 	cm.mutex.Lock()
@@ -609,14 +1070,19 @@ func (cm *ConfigManager) SaveConfig() error {
 		return err
 	}
 
-	// 序列化配置为YAML
-	content, err := yaml.Marshal(cm.config)
+	// 按配置文件的格式序列化配置
+	content, err := encodeConfig(cm.config, configFormat(cm.configPath))
 	if err != nil {
 		return err
 	}
 
-	// 写入配置文件
-	if err := os.WriteFile(cm.configPath, content, 0644); err != nil {
+	// 覆盖前保存当前配置文件的快照，快照失败不影响保存
+	if err := cm.snapshotConfigLocked(); err != nil {
+		logging.DefaultLogger.Warn("Failed to snapshot config before saving: %v", err)
+	}
+
+	// 写入临时文件后改名替换配置文件，持有cm.mutex保证并发保存不会交错写入
+	if err := writeConfigFile(cm.configPath, content); err != nil {
 		return err
 	}
 
@@ -738,7 +1204,7 @@ func (cm *ConfigManager) reloadConfig() {
 		return
 	}
 
-	if err := yaml.Unmarshal(file, cfg); err != nil {
+	if err := decodeConfig(file, configFormat(cm.configPath), cfg); err != nil {
 		logging.DefaultLogger.Error("重新解析配置文件失败: %s, 错误: %v", cm.configPath, err)
 		return
 	}
@@ -900,16 +1366,21 @@ func defaultConfig() *Config {
 				Concurrency:     5,
 				DefaultPriority: 0,
 				MaxDepth:        3, // 默认爬取深度为3
+				MaxURLs:         10000,
+				RespectRobots:   true,
 			},
 			Push: PushConfig{
-				Enabled:         false,
-				BaiduAPI:        "http://data.zz.baidu.com/urls",
-				BaiduToken:      "",
-				BingAPI:         "https://ssl.bing.com/webmaster/api.svc/json/SubmitUrl",
-				BingToken:       "",
-				BaiduDailyLimit: 1000,
-				BingDailyLimit:  1000,
-				PushDomain:      "",
+				Enabled:          false,
+				BaiduAPI:         "http://data.zz.baidu.com/urls",
+				BaiduToken:       "",
+				BingAPI:          "https://ssl.bing.com/webmaster/api.svc/json/SubmitUrl",
+				BingToken:        "",
+				BaiduDailyLimit:  1000,
+				BingDailyLimit:   1000,
+				BatchSize:        500,
+				MaxAttempts:      3,
+				GoogleDailyLimit: 200,
+				PushDomain:       "",
 			},
 		},
 		Routing: RoutingConfig{
@@ -925,9 +1396,11 @@ func defaultConfig() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			Address:     "0.0.0.0",
-			APIPort:     9598,
-			ConsolePort: 9597,
+			Address:            "0.0.0.0",
+			APIPort:            9598,
+			ConsolePort:        9597,
+			SiteBindRetries:    5,
+			SiteBindRetryDelay: 2,
 		},
 		Dirs: DirsConfig{
 			DataDir:        "./data",   // 数据目录
@@ -953,6 +1426,27 @@ func defaultConfig() *Config {
 			Version:     "1.0.1",
 			OfficialURL: "https://prerender.websitetool.cn",
 		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "console",
+			Audit: AuditLogConfig{
+				RetentionDays: 90,
+			},
+		},
+		GeoIP: GeoIPLookupConfig{
+			DatabasePath:     "./data/GeoLite2-Country.mmdb",
+			Concurrency:      4,
+			RateLimit:        40, // ip-api.com免费版限制为每分钟45次
+			NegativeCacheTTL: 300,
+		},
+		RenderAPI: RenderAPIConfig{
+			Enabled: false,
+			Path:    "/render",
+		},
+		ACME: ACMEConfig{
+			DirectoryURL: DefaultACMEDirectoryURL,
+			RenewBefore:  30,
+		},
 		Sites: []SiteConfig{defaultSite},
 	}
 }
@@ -1000,6 +1494,9 @@ func loadFromEnv(cfg *Config) {
 	cfg.Monitoring.Enabled = getEnvAsBool("MONITORING_ENABLED", cfg.Monitoring.Enabled)
 	cfg.Monitoring.PrometheusAddress = getEnv("MONITORING_PROMETHEUS_ADDRESS", cfg.Monitoring.PrometheusAddress)
 
+	// 渲染API配置
+	cfg.RenderAPI.Token = getEnv("RENDER_API_TOKEN", cfg.RenderAPI.Token)
+
 	// 注意：站点配置主要通过 YAML 文件管理，环境变量加载暂不支持站点级配置
 }
 
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// wildcardPrefix 通配符域名前缀，如 *.example.com
+const wildcardPrefix = "*."
+
+// NormalizeDomain 校验并规范化站点域名
+// 支持IP地址、RFC 1123主机名和 *.example.com 形式的通配符域名，
+// 国际化域名转换为punycode，结果统一为小写
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return "", fmt.Errorf("domain is empty")
+	}
+
+	if ip := net.ParseIP(domain); ip != nil {
+		return ip.String(), nil
+	}
+
+	wildcard := strings.HasPrefix(domain, wildcardPrefix)
+	host := strings.TrimPrefix(domain, wildcardPrefix)
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %v", domain, err)
+	}
+	ascii = strings.ToLower(ascii)
+
+	if len(ascii) > 253 {
+		return "", fmt.Errorf("invalid domain %q: longer than 253 characters", domain)
+	}
+	labels := strings.Split(ascii, ".")
+	// 通配符只能用于至少两级的域名，避免 *.com 这类匹配整个顶级域的配置
+	if wildcard && len(labels) < 2 {
+		return "", fmt.Errorf("invalid domain %q: wildcard requires at least two labels", domain)
+	}
+	for _, label := range labels {
+		if !isValidDomainLabel(label) {
+			return "", fmt.Errorf("invalid domain %q: bad label %q", domain, label)
+		}
+	}
+
+	if wildcard {
+		return wildcardPrefix + ascii, nil
+	}
+	return ascii, nil
+}
+
+// isValidDomainLabel 按RFC 1123校验域名标签：1-63位字母、数字或短横线，且不以短横线开头或结尾
+func isValidDomainLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLocalDomain 判断域名是否为本机地址
+func IsLocalDomain(domain string) bool {
+	return domain == "127.0.0.1" || domain == "localhost"
+}
+
+// matchDomain 判断主机名是否匹配站点域名，通配符域名匹配其下的任意子域名
+func matchDomain(pattern, host string) bool {
+	if strings.HasPrefix(pattern, wildcardPrefix) {
+		suffix := pattern[1:] // .example.com
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return pattern == host
+}
+
+// FindSiteByHost 根据主机名在所有站点的域名中查找站点，忽略大小写和端口
+// 精确匹配的域名优先于通配符域名
+func (c *Config) FindSiteByHost(host string) *SiteConfig {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	var wildcardMatch *SiteConfig
+	for i := range c.Sites {
+		for _, domain := range c.Sites[i].Domains {
+			domain = strings.ToLower(domain)
+			if h, _, err := net.SplitHostPort(domain); err == nil {
+				domain = h
+			}
+			if domain == host {
+				return &c.Sites[i]
+			}
+			if wildcardMatch == nil && matchDomain(domain, host) {
+				wildcardMatch = &c.Sites[i]
+			}
+		}
+	}
+	return wildcardMatch
+}
+
+// FindSiteByDomain 查找已绑定指定域名的站点，excludeSiteID对应的站点不参与比较
+func (c *Config) FindSiteByDomain(domain, excludeSiteID string) *SiteConfig {
+	domain = strings.ToLower(domain)
+	for i := range c.Sites {
+		if c.Sites[i].ID == excludeSiteID {
+			continue
+		}
+		for _, existing := range c.Sites[i].Domains {
+			if strings.ToLower(existing) == domain {
+				return &c.Sites[i]
+			}
+		}
+	}
+	return nil
+}
+
+// CheckReferer 判断来源是否需要过滤，返回空字符串表示正常记录，否则返回处理方式(drop或flag)
+// siteDomains为站点绑定的域名，用于识别本站来源
+func (f RefererFilterConfig) CheckReferer(referer string, siteDomains []string) string {
+	if referer == "" {
+		return ""
+	}
+	parsed, err := url.Parse(referer)
+	if err != nil || parsed.Hostname() == "" {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+
+	action := f.Action
+	if action == "" {
+		action = RefererActionDrop
+	}
+
+	for _, blocked := range f.Blocklist {
+		blocked = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(blocked), wildcardPrefix))
+		if blocked != "" && (host == blocked || strings.HasSuffix(host, "."+blocked)) {
+			return action
+		}
+	}
+
+	if f.SelfReferer == "exclude" {
+		for _, domain := range siteDomains {
+			if matchDomain(strings.ToLower(domain), host) {
+				return action
+			}
+		}
+	}
+	return ""
+}
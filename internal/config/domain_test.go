@@ -0,0 +1,93 @@
+package config
+
+import "testing"
+
+// TestNormalizeDomain 测试域名校验和规范化
+func TestNormalizeDomain(t *testing.T) {
+	valid := map[string]string{
+		"localhost":        "localhost",
+		"127.0.0.1":        "127.0.0.1",
+		"Example.COM":      "example.com",
+		"www.example.com.": "www.example.com",
+		"*.example.com":    "*.example.com",
+		"bücher.example":   "xn--bcher-kva.example",
+		"my-site.example":  "my-site.example",
+	}
+	for input, expected := range valid {
+		got, err := NormalizeDomain(input)
+		if err != nil || got != expected {
+			t.Errorf("NormalizeDomain(%q) = %q, %v; expected %q", input, got, err, expected)
+		}
+	}
+
+	invalid := []string{"", "bad_domain.com", "-example.com", "example-.com", "a..com", "*.com", "example.com/path", "*.*.example.com"}
+	for _, input := range invalid {
+		if got, err := NormalizeDomain(input); err == nil {
+			t.Errorf("NormalizeDomain(%q) = %q, expected error", input, got)
+		}
+	}
+}
+
+// TestFindSiteByHost 测试按主机名匹配站点，精确域名优先于通配符
+func TestFindSiteByHost(t *testing.T) {
+	cfg := &Config{Sites: []SiteConfig{
+		{ID: "wildcard", Domains: []string{"*.example.com"}},
+		{ID: "exact", Domains: []string{"api.example.com"}},
+	}}
+
+	cases := map[string]string{
+		"api.example.com":     "exact",
+		"www.example.com:443": "wildcard",
+		"a.b.example.com":     "wildcard",
+		"example.com":         "",
+		"other.com":           "",
+	}
+	for host, expected := range cases {
+		site := cfg.FindSiteByHost(host)
+		got := ""
+		if site != nil {
+			got = site.ID
+		}
+		if got != expected {
+			t.Errorf("FindSiteByHost(%q) = %q, expected %q", host, got, expected)
+		}
+	}
+
+	if cfg.FindSiteByDomain("API.example.com", "") == nil || cfg.FindSiteByDomain("api.example.com", "exact") != nil {
+		t.Error("FindSiteByDomain should match case-insensitively and honor excludeSiteID")
+	}
+}
+
+// TestRefererFilter 测试垃圾来源和本站来源的过滤
+func TestRefererFilter(t *testing.T) {
+	domains := []string{"example.com", "*.example.com"}
+
+	filter := RefererFilterConfig{Blocklist: []string{"spam.test", "*.seo-offers.test"}}
+	cases := map[string]string{
+		"":                              "",
+		"https://www.google.com/search": "",
+		"https://spam.test/page":        RefererActionDrop,
+		"http://cdn.spam.test":          RefererActionDrop,
+		"https://buy.seo-offers.test/x": RefererActionDrop,
+		"https://notspam.test/":         "",
+		"https://www.example.com/about": "",
+		"not a url with spaces %zz":     "",
+	}
+	for referer, expected := range cases {
+		if got := filter.CheckReferer(referer, domains); got != expected {
+			t.Errorf("CheckReferer(%q) = %q, expected %q", referer, got, expected)
+		}
+	}
+
+	// 标记模式下，本站来源按排除策略处理
+	filter = RefererFilterConfig{Blocklist: []string{"spam.test"}, Action: RefererActionFlag, SelfReferer: "exclude"}
+	if got := filter.CheckReferer("https://spam.test/", domains); got != RefererActionFlag {
+		t.Errorf("expected blocklisted referer to be flagged, got %q", got)
+	}
+	if got := filter.CheckReferer("https://blog.example.com/post", domains); got != RefererActionFlag {
+		t.Errorf("expected self referer to be flagged, got %q", got)
+	}
+	if got := filter.CheckReferer("https://www.bing.com/", domains); got != "" {
+		t.Errorf("expected external referer to be recorded, got %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// FormatYAML YAML格式的配置文件，扩展名为.yml、.yaml或其他
+	FormatYAML = "yaml"
+	// FormatJSON JSON格式的配置文件，扩展名为.json
+	FormatJSON = "json"
+)
+
+// configFormat 按扩展名判断配置文件格式，.json为JSON，其他按YAML处理
+func configFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// contentFormat 按内容判断配置格式，以{开头的有效JSON为JSON，其他按YAML处理
+func contentFormat(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// formatExt 返回配置格式对应的文件扩展名
+func formatExt(format string) string {
+	if format == FormatJSON {
+		return ".json"
+	}
+	return ".yml"
+}
+
+// jsonFileConfig JSON配置文件的结构，站点TLS私钥在API中不返回（json:"-"），但需要读写配置文件
+type jsonFileConfig struct {
+	*Config
+	Sites []jsonFileSite `json:"sites"`
+}
+
+// jsonFileSite JSON配置文件中的站点配置
+type jsonFileSite struct {
+	SiteConfig
+	TLS jsonFileSiteTLS `json:"tls"`
+}
+
+// jsonFileSiteTLS JSON配置文件中的站点HTTPS配置，包含私钥
+type jsonFileSiteTLS struct {
+	SiteTLSConfig
+	KeyPEM string `json:"key_pem,omitempty"`
+}
+
+// decodeConfig 按格式解析配置内容到cfg，cfg中已有的值作为默认值；
+// JSON格式按json标签解析，配置文件中包含sites时替换默认站点，与YAML相同
+func decodeConfig(data []byte, format string, cfg *Config) error {
+	if format != FormatJSON {
+		return yaml.Unmarshal(data, cfg)
+	}
+
+	file := jsonFileConfig{Config: cfg}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	if file.Sites != nil {
+		cfg.Sites = make([]SiteConfig, len(file.Sites))
+		for i, site := range file.Sites {
+			cfg.Sites[i] = site.SiteConfig
+			cfg.Sites[i].TLS = site.TLS.SiteTLSConfig
+			cfg.Sites[i].TLS.KeyPEM = site.TLS.KeyPEM
+		}
+	}
+	return nil
+}
+
+// encodeConfig 按格式序列化配置，JSON格式按json标签序列化，站点私钥同样写入配置文件
+func encodeConfig(cfg *Config, format string) ([]byte, error) {
+	if format != FormatJSON {
+		return yaml.Marshal(cfg)
+	}
+
+	file := jsonFileConfig{Config: cfg, Sites: make([]jsonFileSite, len(cfg.Sites))}
+	for i, site := range cfg.Sites {
+		file.Sites[i] = jsonFileSite{
+			SiteConfig: site,
+			TLS:        jsonFileSiteTLS{SiteTLSConfig: site.TLS, KeyPEM: site.TLS.KeyPEM},
+		}
+	}
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// backupSuffix 上一份可以正常解析的配置文件的备份后缀
+const backupSuffix = ".bak"
+
+// writeTempFile 将配置内容写入临时文件，测试时可替换以模拟写入中断
+var writeTempFile = func(file *os.File, content []byte) error {
+	_, err := file.Write(content)
+	return err
+}
+
+// writeConfigFile 原子地替换配置文件：先写入同目录下的临时文件并落盘，再改名覆盖原文件，
+// 进程在写入过程中退出时原文件保持不变；覆盖前将可以正常解析的原文件保存为path.bak
+func writeConfigFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if err := writeTempFile(tmp, content); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	backupConfigFile(path, mode)
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// backupConfigFile 原配置文件可以正常解析时保存为path.bak，只保留一份备份
+func backupConfigFile(path string, mode os.FileMode) {
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var parsed Config
+	if err := decodeConfig(previous, configFormat(path), &parsed); err != nil {
+		return
+	}
+	os.WriteFile(path+backupSuffix, previous, mode)
+}
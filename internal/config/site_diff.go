@@ -0,0 +1,49 @@
+package config
+
+import "reflect"
+
+// SiteChange 配置发生变化的站点，Old为变化前的配置
+type SiteChange struct {
+	Old SiteConfig
+	New SiteConfig
+}
+
+// SiteDiff 两份站点列表之间的差异，站点按ID匹配
+type SiteDiff struct {
+	Added   []SiteConfig
+	Removed []SiteConfig
+	Changed []SiteChange
+}
+
+// Empty 站点列表没有任何变化时返回true
+func (d SiteDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffSites 比较配置变化前后的站点列表，返回新增、删除和配置变化的站点
+// 新增和变化的站点按newSites中的顺序排列，删除的站点按oldSites中的顺序排列
+func DiffSites(oldSites, newSites []SiteConfig) SiteDiff {
+	var diff SiteDiff
+
+	oldByID := make(map[string]SiteConfig, len(oldSites))
+	for _, site := range oldSites {
+		oldByID[site.ID] = site
+	}
+	newIDs := make(map[string]bool, len(newSites))
+	for _, site := range newSites {
+		newIDs[site.ID] = true
+		old, exists := oldByID[site.ID]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, site)
+		case !reflect.DeepEqual(old, site):
+			diff.Changed = append(diff.Changed, SiteChange{Old: old, New: site})
+		}
+	}
+	for _, site := range oldSites {
+		if !newIDs[site.ID] {
+			diff.Removed = append(diff.Removed, site)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,32 @@
+package config
+
+import "testing"
+
+// TestDiffSites 测试按站点ID比较配置变化前后的站点列表
+func TestDiffSites(t *testing.T) {
+	oldSites := []SiteConfig{
+		{ID: "a", Name: "A", Port: 8081},
+		{ID: "b", Name: "B", Port: 8082},
+		{ID: "c", Name: "C", Port: 8083},
+	}
+	newSites := []SiteConfig{
+		{ID: "a", Name: "A", Port: 8081},
+		{ID: "c", Name: "C", Port: 8083, Prerender: PrerenderConfig{Enabled: true}},
+		{ID: "d", Name: "D", Port: 8084},
+	}
+
+	diff := DiffSites(oldSites, newSites)
+	if len(diff.Added) != 1 || diff.Added[0].ID != "d" {
+		t.Errorf("Added = %+v, expected site d", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ID != "b" {
+		t.Errorf("Removed = %+v, expected site b", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Old.ID != "c" || !diff.Changed[0].New.Prerender.Enabled {
+		t.Errorf("Changed = %+v, expected site c with prerender enabled", diff.Changed)
+	}
+
+	if diff := DiffSites(oldSites, oldSites); !diff.Empty() {
+		t.Errorf("DiffSites of identical lists = %+v, expected no changes", diff)
+	}
+}
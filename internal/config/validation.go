@@ -0,0 +1,338 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"prerender-shield/internal/logging"
+	"prerender-shield/internal/utils"
+)
+
+// FieldError 配置中某个字段的校验错误，Field为字段路径，如 sites[0].proxy.target_url
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors 配置校验发现的全部错误
+type ValidationErrors []FieldError
+
+// Error 实现error接口，按顺序拼接所有错误
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add 记录一个字段错误
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ParseConfig 解析YAML或JSON格式的配置内容，按内容判断格式，未设置的配置项使用默认值，与加载配置文件时相同
+func ParseConfig(data []byte) (*Config, error) {
+	return parseConfig(data, contentFormat(data))
+}
+
+// parseConfig 按指定格式解析配置内容，未设置的配置项使用默认值
+func parseConfig(data []byte, format string) (*Config, error) {
+	cfg := defaultConfig()
+	if err := decodeConfig(data, format, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ValidateConfig 验证配置的合法性，返回的错误为ValidationErrors，包含所有不合法的字段
+func (cm *ConfigManager) ValidateConfig(config *Config) error {
+	var errs ValidationErrors
+
+	// 验证服务器配置
+	if config.Server.Address == "" {
+		config.Server.Address = "0.0.0.0" // 使用默认地址
+	}
+	if _, err := utils.ParseIPList(config.Server.TrustedProxies); err != nil {
+		errs.add("server.trusted_proxies", "%v", err)
+	}
+
+	// 验证上传扫描配置
+	if scan := config.Server.UploadScan; scan.Enabled {
+		if len(scan.Command) == 0 && scan.URL == "" {
+			errs.add("server.upload_scan", "upload scan is enabled but neither command nor url is configured")
+		}
+		switch scan.FailPolicy {
+		case "", UploadScanFailOpen, UploadScanFailClosed:
+		default:
+			errs.add("server.upload_scan.fail_policy", "invalid upload scan fail policy: %s", scan.FailPolicy)
+		}
+	}
+
+	// 验证日志配置
+	if config.Logging.Level != "" {
+		if _, err := logging.ParseLevel(config.Logging.Level); err != nil {
+			errs.add("logging.level", "%v", err)
+		}
+	}
+	switch config.Logging.Format {
+	case "", logging.FormatConsole, logging.FormatJSON:
+	default:
+		errs.add("logging.format", "invalid log format: %s", config.Logging.Format)
+	}
+	for module, level := range config.Logging.Modules {
+		if _, err := logging.ParseLevel(level); err != nil {
+			errs.add("logging.modules."+module, "%v", err)
+		}
+	}
+	if config.Logging.Audit.RetentionDays < 0 {
+		errs.add("logging.audit.retention_days", "audit log retention days cannot be negative")
+	}
+
+	// 验证站点配置
+	reserved := reservedPorts(config)
+	for i, site := range config.Sites {
+		validateSite(&errs, fmt.Sprintf("sites[%d].", i), site, reserved)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateSite 验证单个站点配置，端口不能与当前配置中管理服务占用的端口冲突，字段路径相对站点配置
+func (cm *ConfigManager) ValidateSite(site SiteConfig) error {
+	var reserved map[int]string
+	if current := cm.GetConfig(); current != nil {
+		reserved = reservedPorts(current)
+	}
+
+	var errs ValidationErrors
+	validateSite(&errs, "", site, reserved)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// reservedPorts 返回API、管理控制台和Prometheus监控占用的端口，站点不能使用这些端口
+func reservedPorts(config *Config) map[int]string {
+	reserved := make(map[int]string)
+	if config.Server.APIPort > 0 {
+		reserved[config.Server.APIPort] = "server.api_port"
+	}
+	if config.Server.ConsolePort > 0 {
+		reserved[config.Server.ConsolePort] = "server.console_port"
+	}
+	if config.Monitoring.Enabled {
+		if _, portStr, err := net.SplitHostPort(config.Monitoring.PrometheusAddress); err == nil {
+			if port, err := strconv.Atoi(portStr); err == nil && port > 0 {
+				reserved[port] = "monitoring.prometheus_address"
+			}
+		}
+	}
+	return reserved
+}
+
+// validateSite 验证站点配置，错误的字段路径以prefix开头
+func validateSite(errs *ValidationErrors, prefix string, site SiteConfig, reserved map[int]string) {
+	// 验证站点ID
+	if site.ID == "" {
+		errs.add(prefix+"id", "site has no ID")
+	}
+
+	// 验证站点名称
+	if site.Name == "" {
+		errs.add(prefix+"name", "site has no name")
+	}
+
+	// 验证站点域名
+	if len(site.Domains) == 0 {
+		errs.add(prefix+"domains", "site has no domains")
+	}
+
+	// 验证站点端口，0表示未设置
+	if site.Port < 0 || site.Port > 65535 {
+		errs.add(prefix+"port", "port %d must be between 1 and 65535", site.Port)
+	} else if owner, exists := reserved[site.Port]; exists {
+		errs.add(prefix+"port", "port %d is reserved by %s", site.Port, owner)
+	}
+
+	// 验证站点模式
+	validModes := map[string]bool{"proxy": true, "static": true, "redirect": true}
+	if !validModes[site.Mode] {
+		errs.add(prefix+"mode", "invalid mode: %s", site.Mode)
+	}
+
+	// 验证访问日志记录范围
+	switch site.VisitLogScope {
+	case "", VisitLogScopeAll, VisitLogScopeHumans, VisitLogScopeCrawlers, VisitLogScopeNone:
+	default:
+		errs.add(prefix+"visit_log_scope", "invalid visit log scope: %s", site.VisitLogScope)
+	}
+
+	// 验证来源过滤配置
+	switch site.RefererFilter.Action {
+	case "", RefererActionDrop, RefererActionFlag:
+	default:
+		errs.add(prefix+"referer_filter.action", "invalid referer filter action: %s", site.RefererFilter.Action)
+	}
+	switch site.RefererFilter.SelfReferer {
+	case "", "include", "exclude":
+	default:
+		errs.add(prefix+"referer_filter.self_referer", "invalid self referer policy: %s", site.RefererFilter.SelfReferer)
+	}
+
+	// 验证爬虫验证失败处理方式
+	switch site.Prerender.CrawlerVerification.Policy {
+	case "", CrawlerVerifyPolicySPA, CrawlerVerifyPolicyBlock, CrawlerVerifyPolicyLog:
+	default:
+		errs.add(prefix+"prerender.crawler_verification.policy", "invalid crawler verification policy: %s", site.Prerender.CrawlerVerification.Policy)
+	}
+
+	// 验证健康检查路径
+	for i, probePath := range site.ProbePaths {
+		if !strings.HasPrefix(probePath, "/") {
+			errs.add(fmt.Sprintf("%sprobe_paths[%d]", prefix, i), "invalid probe path: %s", probePath)
+		}
+	}
+
+	// 验证URL排除规则
+	for i, pattern := range site.ExcludePatterns {
+		if err := validateExcludePattern(pattern); err != nil {
+			errs.add(fmt.Sprintf("%sexclude_patterns[%d]", prefix, i), "%v", err)
+		}
+	}
+
+	// 验证HTTPS配置
+	if site.TLS.Enabled && !site.TLS.ACME {
+		if site.TLS.CertFile == "" && site.TLS.CertPEM == "" {
+			errs.add(prefix+"tls.cert_file", "TLS is enabled but no certificate is configured")
+		}
+		if site.TLS.KeyFile == "" && site.TLS.KeyPEM == "" {
+			errs.add(prefix+"tls.key_file", "TLS is enabled but no private key is configured")
+		}
+	}
+
+	// 根据站点模式验证特定配置
+	switch site.Mode {
+	case "proxy":
+		if len(site.Proxy.UpstreamList()) == 0 {
+			errs.add(prefix+"proxy.target_url", "proxy mode requires a target URL")
+		}
+		if len(site.Proxy.Upstreams) == 0 && site.Proxy.TargetURL != "" {
+			if err := validateTargetURL(site.Proxy.TargetURL); err != nil {
+				errs.add(prefix+"proxy.target_url", "%v", err)
+			}
+		}
+		for i, upstream := range site.Proxy.Upstreams {
+			field := fmt.Sprintf("%sproxy.upstreams[%d]", prefix, i)
+			if upstream.URL == "" {
+				errs.add(field+".url", "upstream has no url")
+			} else if err := validateTargetURL(upstream.URL); err != nil {
+				errs.add(field+".url", "%v", err)
+			}
+			if upstream.Weight < 0 {
+				errs.add(field+".weight", "weight must not be negative")
+			}
+			if upstream.HealthCheckPath != "" && !strings.HasPrefix(upstream.HealthCheckPath, "/") {
+				errs.add(field+".health_check_path", "health_check_path must start with /")
+			}
+		}
+		if site.Proxy.HealthCheckInterval < 0 {
+			errs.add(prefix+"proxy.health_check_interval", "health_check_interval must not be negative")
+		}
+		if site.Proxy.StripPrefix != "" && !strings.HasPrefix(site.Proxy.StripPrefix, "/") {
+			errs.add(prefix+"proxy.strip_prefix", "strip_prefix must start with /")
+		}
+		if site.Proxy.Timeout < 0 {
+			errs.add(prefix+"proxy.timeout", "timeout must not be negative")
+		}
+	case "static":
+		if site.Static.CompressionLevel < -1 || site.Static.CompressionLevel > 9 {
+			errs.add(prefix+"static.compression_level", "compression_level must be between -1 and 9")
+		}
+		for ext := range site.Static.CacheControl {
+			if !strings.HasPrefix(ext, ".") {
+				errs.add(prefix+"static.cache_control", "key %q must be a file extension starting with .", ext)
+			}
+		}
+	case "redirect":
+		if site.Redirect.TargetURL == "" {
+			errs.add(prefix+"redirect.target_url", "redirect mode requires a target URL")
+		}
+		if site.Redirect.StatusCode < 300 || site.Redirect.StatusCode > 399 {
+			errs.add(prefix+"redirect.status_code", "status code %d must be a 3xx redirect status", site.Redirect.StatusCode)
+		}
+	}
+
+	// 验证渲染预热配置
+	for i, status := range site.Prerender.NoCacheStatuses {
+		if status < 100 || status > 599 {
+			errs.add(fmt.Sprintf("%sprerender.no_cache_statuses[%d]", prefix, i), "invalid status code %d", status)
+		}
+	}
+	if batches := site.Prerender.Push.DailyBatches; batches < 0 || batches > MaxPushDailyBatches {
+		errs.add(prefix+"prerender.push.daily_batches", "daily_batches must be between 0 and %d", MaxPushDailyBatches)
+	}
+	if site.Prerender.Push.Timeout < 0 {
+		errs.add(prefix+"prerender.push.timeout", "timeout must not be negative")
+	}
+	for i, rule := range site.Prerender.Push.TargetRules {
+		field := fmt.Sprintf("%sprerender.push.target_rules[%d]", prefix, i)
+		if err := validatePathPattern(rule.Pattern); err != nil {
+			errs.add(field+".pattern", "%v", err)
+		}
+		for _, engine := range rule.Engines {
+			switch engine {
+			case PushEngineBaidu, PushEngineBing, PushEngineGoogle, PushEngineIndexNow:
+			default:
+				errs.add(field+".engines", "invalid engine: %s", engine)
+			}
+		}
+	}
+
+	// 验证防火墙配置
+	for i, rule := range site.Firewall.RateLimitConfig.Paths {
+		field := fmt.Sprintf("%sfirewall.rate_limit.paths[%d]", prefix, i)
+		if err := validatePathPattern(rule.Pattern); err != nil {
+			errs.add(field+".pattern", "%v", err)
+		}
+		if rule.Requests < 1 || rule.Window < 0 {
+			errs.add(field, "rule %s must allow at least one request per non-negative window", rule.Pattern)
+		}
+	}
+	switch site.Firewall.ActionConfig.DefaultAction {
+	case "", "allow", "block", "challenge":
+	default:
+		errs.add(prefix+"firewall.action.default_action", "default_action must be block or challenge")
+	}
+	if site.Firewall.ActionConfig.ChallengeTTL < 0 {
+		errs.add(prefix+"firewall.action.challenge_ttl", "challenge_ttl must not be negative")
+	}
+	if _, err := utils.ParseIPList(site.Firewall.Blacklist); err != nil {
+		errs.add(prefix+"firewall.blacklist", "%v", err)
+	}
+	if _, err := utils.ParseIPList(site.Firewall.Whitelist); err != nil {
+		errs.add(prefix+"firewall.whitelist", "%v", err)
+	}
+}
+
+// validateTargetURL 检查代理后端地址是包含主机名的http或https地址
+func validateTargetURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("URL %q must use http or https", raw)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("URL %q has no host", raw)
+	}
+	return nil
+}
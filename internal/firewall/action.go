@@ -1,78 +1,174 @@
 package firewall
 
 import (
-	"fmt"
+	"bytes"
+	"encoding/json"
+	"html/template"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+
+	"prerender-shield/internal/utils"
 )
 
+// defaultBlockMessage 未配置拦截提示时使用的提示
+const defaultBlockMessage = "Access Denied by WAF"
+
+// legacyBlockPage 未配置拦截页面模板时，站点静态目录下作为拦截页面的文件名
+const legacyBlockPage = "waf_block.html"
+
+// defaultBlockPage 默认拦截页面模板
+var defaultBlockPage = template.Must(template.New("block").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <title>Access Denied</title>
+    <style>
+        body { font-family: Arial, sans-serif; text-align: center; padding-top: 50px; }
+        h1 { color: #d9534f; }
+        p { color: #555; }
+        .incident { font-family: monospace; color: #777; }
+        .footer { margin-top: 50px; font-size: 12px; color: #999; }
+    </style>
+</head>
+<body>
+    <h1>Access Denied</h1>
+    <p>{{.Message}}</p>
+    <p class="incident">Incident ID: {{.IncidentID}}</p>
+    {{if .ContactURL}}<p>If you believe this is a mistake, please <a href="{{.ContactURL}}">contact us</a> with the incident ID.</p>{{end}}
+    <div class="footer">Prerender Shield WAF</div>
+</body>
+</html>`))
+
+// BlockPageData 拦截页面模板可以使用的数据
+type BlockPageData struct {
+	IncidentID string
+	Message    string
+	ContactURL string
+	Path       string
+	ClientIP   string
+	Time       time.Time
+}
+
 // DefaultActionHandler 默认动作处理器
 type DefaultActionHandler struct {
-	config    ActionConfig
-	staticDir string
-	siteName  string
+	config     ActionConfig
+	staticDir  string
+	siteName   string
+	challenger *challenger // 默认动作为challenge或命中challenge规则时签发和校验挑战令牌
 }
 
-// NewDefaultActionHandler 创建默认动作处理器
-func NewDefaultActionHandler(config ActionConfig, staticDir, siteName string) *DefaultActionHandler {
+// NewDefaultActionHandler 创建默认动作处理器，redisClient用于保存挑战令牌，可以为nil
+func NewDefaultActionHandler(config ActionConfig, staticDir, siteName string, redisClient *redis.Client) *DefaultActionHandler {
 	return &DefaultActionHandler{
-		config:    config,
-		staticDir: staticDir,
-		siteName:  siteName,
+		config:     config,
+		staticDir:  staticDir,
+		siteName:   siteName,
+		challenger: newChallenger(siteName, time.Duration(config.ChallengeTTL)*time.Second, redisClient),
 	}
 }
 
 // Handle 处理请求
+// 默认动作为challenge或拦截的威胁全部来自challenge规则时，携带有效挑战令牌的请求和标记跳过挑战的爬虫请求放行，
+// 其他请求返回挑战页面；命中黑名单或地区限制的请求始终拦截
 func (h *DefaultActionHandler) Handle(w http.ResponseWriter, req *http.Request, result *CheckResult) bool {
 	if result.Allow {
+		result.Action = ActionAllow
+		if len(result.Threats) > 0 {
+			result.Action = ActionLog
+		}
 		return true
 	}
 
-	// 阻止请求
-	w.WriteHeader(http.StatusForbidden)
-
-	// 尝试读取自定义拦截页面
-	// 路径：staticDir/siteName/waf_block.html
-	// 或者是全局的？用户需求是"站点管理 -> WAF设置"，所以是站点级别的。
-	// 我们假设上传的文件名为 waf_block.html
-	
-	// 如果配置中指定了BlockPage路径，也可以使用
-	// 但ActionConfig目前只有BlockMessage。
-	
-	customPagePath := filepath.Join(h.staticDir, h.siteName, "waf_block.html")
-	if content, err := os.ReadFile(customPagePath); err == nil {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write(content)
+	if (result.Challenge || h.config.DefaultAction == ActionChallenge) && challengeable(result) {
+		if challengeBypassed(req) || h.challenger.verify(req) {
+			result.Action = ActionAllow
+			return true
+		}
+		result.Action = ActionChallenge
+		h.challenger.writeChallenge(w, req)
 		return false
 	}
 
-	// 使用默认拦截页面
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	
+	// 阻止请求，事件ID同时返回给客户端和写入WAF日志，便于用户反馈误拦截时定位
+	result.Action = ActionBlock
+	result.IncidentID = uuid.New().String()
+	h.writeBlock(w, req, result)
+	return false
+}
+
+// writeBlock 返回拦截响应：Accept优先JSON时返回JSON，否则返回拦截页面
+func (h *DefaultActionHandler) writeBlock(w http.ResponseWriter, req *http.Request, result *CheckResult) {
 	message := h.config.BlockMessage
 	if message == "" {
-		message = "Access Denied by WAF"
+		message = defaultBlockMessage
 	}
+	w.Header().Set("X-Incident-ID", result.IncidentID)
 
-	html := fmt.Sprintf(`<!DOCTYPE html>
-<html>
-<head>
-    <title>Access Denied</title>
-    <style>
-        body { font-family: Arial, sans-serif; text-align: center; padding-top: 50px; }
-        h1 { color: #d9534f; }
-        p { color: #555; }
-        .footer { margin-top: 50px; font-size: 12px; color: #999; }
-    </style>
-</head>
-<body>
-    <h1>Access Denied</h1>
-    <p>%s</p>
-    <div class="footer">Prerender Shield WAF</div>
-</body>
-</html>`, message)
+	if prefersJSON(req.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":        http.StatusForbidden,
+			"message":     message,
+			"incident_id": result.IncidentID,
+		})
+		return
+	}
 
-	w.Write([]byte(html))
-	return false
+	data := BlockPageData{
+		IncidentID: result.IncidentID,
+		Message:    message,
+		ContactURL: h.config.ContactURL,
+		Path:       req.URL.Path,
+		ClientIP:   utils.ClientIP(req),
+		Time:       result.CreatedAt,
+	}
+	var buf bytes.Buffer
+	if err := h.blockPage().Execute(&buf, data); err != nil {
+		logger.Error("Failed to render block page for site %s: %v", h.siteName, err)
+		buf.Reset()
+		defaultBlockPage.Execute(&buf, data)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(buf.Bytes())
+}
+
+// blockPage 返回拦截页面模板：配置了BlockPagePath时使用静态目录下的该模板，
+// 否则使用站点目录下的waf_block.html，都不存在或解析失败时使用默认页面
+func (h *DefaultActionHandler) blockPage() *template.Template {
+	path := filepath.Join(h.staticDir, h.siteName, legacyBlockPage)
+	if h.config.BlockPagePath != "" {
+		securePath, err := utils.SecurePath(h.staticDir, h.config.BlockPagePath)
+		if err != nil {
+			logger.Warn("Invalid block page path %q for site %s: %v", h.config.BlockPagePath, h.siteName, err)
+			return defaultBlockPage
+		}
+		path = securePath
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if h.config.BlockPagePath != "" {
+			logger.Warn("Failed to read block page for site %s: %v", h.siteName, err)
+		}
+		return defaultBlockPage
+	}
+	tmpl, err := template.New("block").Parse(string(content))
+	if err != nil {
+		logger.Warn("Failed to parse block page for site %s: %v", h.siteName, err)
+		return defaultBlockPage
+	}
+	return tmpl
+}
+
+// prefersJSON 判断Accept请求头是否要求JSON响应，同时接受HTML时按HTML处理
+func prefersJSON(accept string) bool {
+	accept = strings.ToLower(accept)
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
 }
@@ -0,0 +1,76 @@
+package firewall
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultActionHandler_BlockPage(t *testing.T) {
+	staticDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(staticDir, "pages"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(staticDir, "pages", "blocked.html"),
+		[]byte(`<p>{{.Message}}</p><p id="incident">{{.IncidentID}}</p><a href="{{.ContactURL}}">contact</a>`), 0644))
+
+	engine, err := NewEngine("test-site", Config{
+		ActionConfig: ActionConfig{
+			DefaultAction: ActionBlock,
+			BlockMessage:  "Blocked <by> test",
+			BlockPagePath: "pages/blocked.html",
+			ContactURL:    "mailto:security@example.com",
+		},
+		StaticDir: staticDir,
+		Blacklist: []string{"192.0.2.0/24"},
+	})
+	assert.NoError(t, err)
+
+	newRequest := func(accept string) *http.Request {
+		req := httptest.NewRequest("GET", "http://example.com/page", nil)
+		req.RemoteAddr = "192.0.2.10:12345"
+		req.Header.Set("Accept", accept)
+		return req
+	}
+
+	// 浏览器请求返回渲染后的模板，消息经过HTML转义
+	rec := httptest.NewRecorder()
+	allowed, result := engine.HandleRequest(rec, newRequest("text/html,application/xhtml+xml,*/*;q=0.8"))
+	assert.False(t, allowed)
+	assert.Equal(t, ActionBlock, result.Action)
+	assert.NotEmpty(t, result.IncidentID)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, result.IncidentID, rec.Header().Get("X-Incident-ID"))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "Blocked &lt;by&gt; test")
+	assert.Contains(t, rec.Body.String(), `<p id="incident">`+result.IncidentID+"</p>")
+	assert.Contains(t, rec.Body.String(), "mailto:security@example.com")
+
+	// API请求返回JSON，每次拦截生成新的事件ID
+	rec = httptest.NewRecorder()
+	allowed, jsonResult := engine.HandleRequest(rec, newRequest("application/json"))
+	assert.False(t, allowed)
+	assert.NotEqual(t, result.IncidentID, jsonResult.IncidentID)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "Blocked <by> test", body["message"])
+	assert.Equal(t, jsonResult.IncidentID, body["incident_id"])
+}
+
+func TestDefaultActionHandler_BlockPageFallback(t *testing.T) {
+	for name, path := range map[string]string{"missing": "missing.html", "outside static dir": "../blocked.html"} {
+		t.Run(name, func(t *testing.T) {
+			handler := NewDefaultActionHandler(ActionConfig{BlockPagePath: path}, t.TempDir(), "test-site", nil)
+			rec := httptest.NewRecorder()
+			result := &CheckResult{}
+			assert.False(t, handler.Handle(rec, httptest.NewRequest("GET", "http://example.com/", nil), result))
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Contains(t, rec.Body.String(), defaultBlockMessage)
+			assert.Contains(t, rec.Body.String(), result.IncidentID)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package firewall
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"prerender-shield/internal/utils"
+)
+
+// 防火墙检测到威胁时可选的动作
+const (
+	ActionAllow     = "allow"
+	ActionBlock     = "block"
+	ActionChallenge = "challenge"
+	ActionLog       = "log" // 只命中动作为allow的自定义规则时放行并记录
+)
+
+// challengeCookieName 挑战通过后浏览器携带的令牌Cookie
+const challengeCookieName = "ps_waf_challenge"
+
+// defaultChallengeTTL 未配置时挑战令牌的有效期
+const defaultChallengeTTL = 30 * time.Minute
+
+// challengeBypassKey 请求上下文中标记跳过挑战的键
+type challengeBypassKey struct{}
+
+// WithChallengeBypass 标记请求跳过挑战，用于预渲染引擎识别出的爬虫，爬虫不执行JS，无法通过挑战
+func WithChallengeBypass(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), challengeBypassKey{}, true))
+}
+
+// challengeBypassed 判断请求是否已标记跳过挑战
+func challengeBypassed(req *http.Request) bool {
+	bypass, _ := req.Context().Value(challengeBypassKey{}).(bool)
+	return bypass
+}
+
+// alwaysBlockThreats 挑战模式下仍直接拦截的威胁类型，黑名单和地区限制是管理员明确的拦截决定
+var alwaysBlockThreats = map[string]bool{"blacklist": true, "geoip": true}
+
+// challenger 签发和校验JS挑战令牌
+// 令牌格式为 {随机数}.{过期时间}.{签名}，签名绑定客户端IP和User-Agent；
+// 配置Redis时签发的随机数保存在Redis中，令牌只有在Redis中仍存在时才有效
+type challenger struct {
+	siteName    string
+	secret      []byte
+	ttl         time.Duration
+	redisClient *redis.Client
+}
+
+// newChallenger 创建挑战令牌签发器，签名密钥在进程启动时随机生成，重启后需要重新通过挑战
+func newChallenger(siteName string, ttl time.Duration, redisClient *redis.Client) *challenger {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	if ttl <= 0 {
+		ttl = defaultChallengeTTL
+	}
+	return &challenger{siteName: siteName, secret: secret, ttl: ttl, redisClient: redisClient}
+}
+
+// redisKey 返回随机数在Redis中的键
+func (c *challenger) redisKey(nonce string) string {
+	return fmt.Sprintf("firewall:%s:challenge:%s", c.siteName, nonce)
+}
+
+// sign 计算令牌签名
+func (c *challenger) sign(req *http.Request, nonce string, expiry int64) string {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%s|%d|%s|%s", nonce, expiry, utils.ClientIP(req), req.UserAgent())
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue 为请求签发新令牌
+func (c *challenger) issue(req *http.Request) string {
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	nonce := hex.EncodeToString(raw)
+	expiry := time.Now().Add(c.ttl).Unix()
+
+	if c.redisClient != nil {
+		if err := c.redisClient.Set(req.Context(), c.redisKey(nonce), 1, c.ttl).Err(); err != nil {
+			logger.Warn("Failed to store challenge token of site %s: %v", c.siteName, err)
+		}
+	}
+	return fmt.Sprintf("%s.%d.%s", nonce, expiry, c.sign(req, nonce, expiry))
+}
+
+// verify 校验请求携带的令牌，Redis不可用时只校验签名和过期时间
+func (c *challenger) verify(req *http.Request) bool {
+	cookie, err := req.Cookie(challengeCookieName)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return false
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(c.sign(req, parts[0], expiry))) {
+		return false
+	}
+
+	if c.redisClient != nil {
+		exists, err := c.redisClient.Exists(req.Context(), c.redisKey(parts[0])).Result()
+		if err != nil {
+			logger.Warn("Failed to verify challenge token of site %s: %v", c.siteName, err)
+			return true
+		}
+		return exists > 0
+	}
+	return true
+}
+
+// challengeable 判断检测结果是否可以通过挑战放行
+func challengeable(result *CheckResult) bool {
+	for _, threat := range result.Threats {
+		if alwaysBlockThreats[threat.Type] {
+			return false
+		}
+	}
+	return true
+}
+
+// challengePage 挑战页面，通过JS写入令牌Cookie后重新加载，不执行JS的客户端停留在此页面
+var challengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>Checking your browser</title>
+    <style>
+        body { font-family: Arial, sans-serif; text-align: center; padding-top: 50px; }
+        p { color: #555; }
+        .footer { margin-top: 50px; font-size: 12px; color: #999; }
+    </style>
+</head>
+<body>
+    <h1>Checking your browser</h1>
+    <p>This page will reload automatically.</p>
+    <noscript><p>Please enable JavaScript to continue.</p></noscript>
+    <script>
+        document.cookie = "{{.Name}}=" + {{.Token}} + "; path=/; max-age={{.MaxAge}}; SameSite=Lax";
+        window.location.reload();
+    </script>
+    <div class="footer">Prerender Shield WAF</div>
+</body>
+</html>`))
+
+// writeChallenge 返回挑战页面
+func (c *challenger) writeChallenge(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	challengePage.Execute(w, map[string]interface{}{
+		"Name":   challengeCookieName,
+		"Token":  c.issue(req),
+		"MaxAge": int(c.ttl.Seconds()),
+	})
+}
@@ -0,0 +1,63 @@
+package firewall
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// challengeTokenPattern 从挑战页面中提取令牌
+var challengeTokenPattern = regexp.MustCompile(`"ps_waf_challenge=" \+ "([^"]+)"`)
+
+func TestEngine_ChallengeAction(t *testing.T) {
+	engine, err := NewEngine("test-site", Config{
+		ActionConfig: ActionConfig{DefaultAction: ActionChallenge, BlockMessage: "Blocked by test"},
+		Blacklist:    []string{"192.0.2.0/24"},
+	})
+	assert.NoError(t, err)
+
+	newRequest := func(ip, userAgent string) *http.Request {
+		req := httptest.NewRequest("GET", "http://example.com/?q=%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+		req.RemoteAddr = ip + ":12345"
+		req.Header.Set("User-Agent", userAgent)
+		return req
+	}
+
+	// 首次请求返回挑战页面
+	rec := httptest.NewRecorder()
+	allowed, result := engine.HandleRequest(rec, newRequest("10.0.0.1", "Browser/1.0"))
+	assert.False(t, allowed)
+	assert.Equal(t, ActionChallenge, result.Action)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	match := challengeTokenPattern.FindStringSubmatch(rec.Body.String())
+	if !assert.Len(t, match, 2, "challenge page should contain a token") {
+		return
+	}
+
+	// 带回令牌的重试请求放行
+	req := newRequest("10.0.0.1", "Browser/1.0")
+	req.AddCookie(&http.Cookie{Name: challengeCookieName, Value: match[1]})
+	allowed, result = engine.HandleRequest(httptest.NewRecorder(), req)
+	assert.True(t, allowed)
+	assert.Equal(t, "allow", result.Action)
+
+	// 令牌绑定客户端，其他User-Agent使用同一令牌仍被挑战
+	req = newRequest("10.0.0.1", "Scraper/1.0")
+	req.AddCookie(&http.Cookie{Name: challengeCookieName, Value: match[1]})
+	allowed, _ = engine.HandleRequest(httptest.NewRecorder(), req)
+	assert.False(t, allowed)
+
+	// 标记跳过挑战的爬虫直接放行
+	allowed, _ = engine.HandleRequest(httptest.NewRecorder(), WithChallengeBypass(newRequest("10.0.0.2", "Googlebot/2.1")))
+	assert.True(t, allowed)
+
+	// 黑名单IP不能通过挑战放行
+	rec = httptest.NewRecorder()
+	allowed, result = engine.HandleRequest(rec, WithChallengeBypass(newRequest("192.0.2.10", "Browser/1.0")))
+	assert.False(t, allowed)
+	assert.Equal(t, ActionBlock, result.Action)
+	assert.Contains(t, rec.Body.String(), "Blocked by test")
+}
@@ -0,0 +1,243 @@
+package firewall
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/utils"
+)
+
+const (
+	// crawlerVerifyKeyPrefix Redis中爬虫验证结果的键前缀，键为 crawler_verify:{IP}|{UA关键字}
+	crawlerVerifyKeyPrefix = "crawler_verify:"
+	// maxVerifyCacheEntries 内存中最多缓存的验证结果数量，清理过期结果后仍然超出时不再缓存到内存
+	maxVerifyCacheEntries = 10000
+)
+
+// DNSResolver DNS解析接口，便于测试Mock
+type DNSResolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// defaultVerifiedCrawlers 内置的可信搜索引擎爬虫及其反向DNS域名
+var defaultVerifiedCrawlers = []config.VerifiedCrawler{
+	{UserAgent: "Googlebot", Domains: []string{"googlebot.com", "google.com"}},
+	{UserAgent: "Bingbot", Domains: []string{"search.msn.com"}},
+	{UserAgent: "Baiduspider", Domains: []string{"baidu.com", "baidu.jp"}},
+	{UserAgent: "YandexBot", Domains: []string{"yandex.ru", "yandex.net", "yandex.com"}},
+	{UserAgent: "Sogou", Domains: []string{"sogou.com"}},
+	{UserAgent: "Applebot", Domains: []string{"applebot.apple.com"}},
+}
+
+// CrawlerVerifier 爬虫身份验证器
+// 先根据User-Agent匹配可信爬虫，再通过反向DNS + 正向DNS确认请求IP确实属于该爬虫
+type CrawlerVerifier struct {
+	crawlers []config.VerifiedCrawler
+	resolver DNSResolver
+	timeout  time.Duration
+	cacheTTL time.Duration
+	cache    map[string]verifyCacheEntry // 键为 "IP|UA关键字"
+	sweepAt  time.Time                   // 下次清理过期验证结果的时间
+	mutex    sync.RWMutex
+	redis    *redis.Client // 非nil时验证结果同时缓存在Redis中，多个实例和重启后共享
+}
+
+type verifyCacheEntry struct {
+	verified  bool
+	checkedAt time.Time
+}
+
+// NewCrawlerVerifier 创建爬虫身份验证器，resolver为nil时使用系统DNS
+func NewCrawlerVerifier(crawlers []config.VerifiedCrawler, resolver DNSResolver) *CrawlerVerifier {
+	if len(crawlers) == 0 {
+		crawlers = defaultVerifiedCrawlers
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &CrawlerVerifier{
+		crawlers: crawlers,
+		resolver: resolver,
+		timeout:  3 * time.Second,
+		cacheTTL: time.Hour,
+		cache:    make(map[string]verifyCacheEntry),
+	}
+}
+
+// WithRedisCache 将验证结果同时缓存到Redis，ttl为缓存时间，为0时保持默认值
+func (v *CrawlerVerifier) WithRedisCache(client *redis.Client, ttl time.Duration) *CrawlerVerifier {
+	v.redis = client
+	if ttl > 0 {
+		v.cacheTTL = ttl
+	}
+	return v
+}
+
+// IsVerifiedCrawler 检查请求是否来自通过验证的爬虫
+func (v *CrawlerVerifier) IsVerifiedCrawler(req *http.Request) bool {
+	claimed, verified := v.Verify(req)
+	return claimed && verified
+}
+
+// Verify 验证请求的爬虫身份，claimed表示User-Agent匹配了可信爬虫，verified表示请求IP通过了DNS验证
+func (v *CrawlerVerifier) Verify(req *http.Request) (claimed, verified bool) {
+	crawler := v.matchUserAgent(req.UserAgent())
+	if crawler == nil {
+		return false, false
+	}
+
+	// 验证站点处理器解析出的客户端IP，只有可信代理转发的X-Forwarded-For才被采用
+	ip := utils.ClientIP(req)
+	if ip == "" {
+		return true, false
+	}
+
+	cacheKey := ip + "|" + crawler.UserAgent
+	v.mutex.RLock()
+	entry, exists := v.cache[cacheKey]
+	v.mutex.RUnlock()
+	if exists && time.Since(entry.checkedAt) < v.cacheTTL {
+		return true, entry.verified
+	}
+
+	verified, cached := v.getRedisVerdict(cacheKey)
+	if !cached {
+		verified = v.verifyIP(ip, crawler.Domains)
+		v.setRedisVerdict(cacheKey, verified)
+	}
+
+	v.storeVerdict(cacheKey, verified)
+	return true, verified
+}
+
+// storeVerdict 将验证结果缓存到内存，每隔一个缓存时间清理一次过期结果，
+// 避免每个声称是爬虫的IP一直留在内存中
+func (v *CrawlerVerifier) storeVerdict(cacheKey string, verified bool) {
+	now := time.Now()
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if !now.Before(v.sweepAt) || len(v.cache) >= maxVerifyCacheEntries {
+		for key, entry := range v.cache {
+			if now.Sub(entry.checkedAt) >= v.cacheTTL {
+				delete(v.cache, key)
+			}
+		}
+		v.sweepAt = now.Add(v.cacheTTL)
+	}
+	if _, exists := v.cache[cacheKey]; !exists && len(v.cache) >= maxVerifyCacheEntries {
+		return
+	}
+	v.cache[cacheKey] = verifyCacheEntry{verified: verified, checkedAt: now}
+}
+
+// getRedisVerdict 读取Redis中缓存的验证结果，第二个返回值表示是否存在
+func (v *CrawlerVerifier) getRedisVerdict(cacheKey string) (bool, bool) {
+	if v.redis == nil {
+		return false, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	value, err := v.redis.Get(ctx, crawlerVerifyKeyPrefix+cacheKey).Result()
+	if err != nil {
+		if err != redis.Nil {
+			logger.Warn("Failed to read crawler verification from Redis: %v", err)
+		}
+		return false, false
+	}
+	return value == "1", true
+}
+
+// setRedisVerdict 将验证结果写入Redis
+func (v *CrawlerVerifier) setRedisVerdict(cacheKey string, verified bool) {
+	if v.redis == nil {
+		return
+	}
+	value := "0"
+	if verified {
+		value = "1"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+	if err := v.redis.Set(ctx, crawlerVerifyKeyPrefix+cacheKey, value, v.cacheTTL).Err(); err != nil {
+		logger.Warn("Failed to save crawler verification to Redis: %v", err)
+	}
+}
+
+// matchUserAgent 根据User-Agent查找对应的可信爬虫定义
+func (v *CrawlerVerifier) matchUserAgent(userAgent string) *config.VerifiedCrawler {
+	if userAgent == "" {
+		return nil
+	}
+	lowerUA := strings.ToLower(userAgent)
+	for i := range v.crawlers {
+		if v.crawlers[i].UserAgent != "" && strings.Contains(lowerUA, strings.ToLower(v.crawlers[i].UserAgent)) {
+			return &v.crawlers[i]
+		}
+	}
+	return nil
+}
+
+// verifyIP 反向解析IP得到主机名，校验域名后缀，再正向解析主机名确认包含该IP
+func (v *CrawlerVerifier) verifyIP(ip string, domains []string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+
+	hosts, err := v.resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return false
+	}
+
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if !hostMatchesDomains(host, domains) {
+			continue
+		}
+
+		addrs, err := v.resolver.LookupHost(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr == ip {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// hostMatchesDomains 检查主机名是否属于指定域名（本身或子域名）
+func hostMatchesDomains(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifiedCrawlerKey 请求上下文中标记已验证爬虫的键
+type verifiedCrawlerKey struct{}
+
+// WithVerifiedCrawler 标记请求来自通过DNS验证的爬虫，由站点处理器使用站点的爬虫验证器验证后设置，
+// 引擎启用了已验证爬虫绕过时跳过OWASP规则检测
+func WithVerifiedCrawler(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), verifiedCrawlerKey{}, true))
+}
+
+// isVerifiedCrawler 判断请求是否已标记为通过验证的爬虫
+func isVerifiedCrawler(req *http.Request) bool {
+	verified, _ := req.Context().Value(verifiedCrawlerKey{}).(bool)
+	return verified
+}
@@ -6,7 +6,7 @@ import (
 	"net/http"
 
 	"prerender-shield/internal/firewall/types"
-	"prerender-shield/internal/logging"
+	"prerender-shield/internal/utils"
 
 	"github.com/go-redis/redis/v8"
 )
@@ -15,17 +15,25 @@ import (
 type BlacklistDetector struct {
 	redisClient *redis.Client
 	siteID      string
-	blacklist   []string // 静态黑名单
-	whitelist   []string // 静态白名单
+	blacklist   *utils.IPList // 静态黑名单
+	whitelist   *utils.IPList // 静态白名单
 }
 
-// NewBlacklistDetector 创建黑白名单检测器
+// NewBlacklistDetector 创建黑白名单检测器，名单条目为单个IP或CIDR网段，无法解析的条目被忽略
 func NewBlacklistDetector(redisClient *redis.Client, siteID string, blacklist, whitelist []string) *BlacklistDetector {
+	blacklistIPs, err := utils.ParseIPList(blacklist)
+	if err != nil {
+		logger.Warn("Site %s firewall blacklist: %v", siteID, err)
+	}
+	whitelistIPs, err := utils.ParseIPList(whitelist)
+	if err != nil {
+		logger.Warn("Site %s firewall whitelist: %v", siteID, err)
+	}
 	return &BlacklistDetector{
 		redisClient: redisClient,
 		siteID:      siteID,
-		blacklist:   blacklist,
-		whitelist:   whitelist,
+		blacklist:   blacklistIPs,
+		whitelist:   whitelistIPs,
 	}
 }
 
@@ -34,27 +42,23 @@ func (d *BlacklistDetector) Name() string {
 	return "blacklist"
 }
 
-// Detect 检测请求
+// Detect 检测请求，黑白名单按站点处理器解析出的客户端IP匹配
 func (d *BlacklistDetector) Detect(req *http.Request) ([]types.Threat, error) {
-	ip := logging.GetClientIP(req)
+	ip := utils.ClientIP(req)
 	
-	// 1. 检查静态白名单
-	for _, allowed := range d.whitelist {
-		if allowed == ip {
-			return nil, nil // 放行
-		}
+	// 1. 检查静态白名单，白名单优先于黑名单网段
+	if d.whitelist.Contains(ip) {
+		return nil, nil // 放行
 	}
 	
 	// 2. 检查静态黑名单
-	for _, blocked := range d.blacklist {
-		if blocked == ip {
-			return []types.Threat{{
-				Type:     "blacklist",
-				Message:  fmt.Sprintf("IP %s matches static blacklist", ip),
-				Severity: "critical",
-				Details:  map[string]interface{}{"ip": ip, "source": "static"},
-			}}, nil
-		}
+	if d.blacklist.Contains(ip) {
+		return []types.Threat{{
+			Type:     "blacklist",
+			Message:  fmt.Sprintf("IP %s matches static blacklist", ip),
+			Severity: "critical",
+			Details:  map[string]interface{}{"ip": ip, "source": "static"},
+		}}, nil
 	}
 	
 	// 3. 检查动态黑名单 (Redis)
@@ -0,0 +1,34 @@
+package detectors
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBlacklistDetector_CIDR 测试黑名单网段拦截，白名单中的IP即使位于黑名单网段内也放行
+func TestBlacklistDetector_CIDR(t *testing.T) {
+	detector := NewBlacklistDetector(nil, "test-site", []string{"10.0.0.0/8", "192.0.2.7"}, []string{"10.1.2.3"})
+
+	detect := func(ip string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":12345"
+		threats, err := detector.Detect(req)
+		assert.NoError(t, err)
+		return len(threats)
+	}
+
+	assert.Equal(t, 1, detect("10.200.3.4"), "IP inside 10.0.0.0/8 should be blocked")
+	assert.Equal(t, 1, detect("192.0.2.7"), "exact blacklisted IP should be blocked")
+	assert.Equal(t, 0, detect("10.1.2.3"), "whitelisted IP should take precedence over the blocked range")
+	assert.Equal(t, 0, detect("11.0.0.1"))
+
+	// 黑名单中的对端伪造白名单中的X-Forwarded-For仍被拦截
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.200.3.4:12345"
+	req.Header.Set("X-Forwarded-For", "10.1.2.3")
+	threats, err := detector.Detect(req)
+	assert.NoError(t, err)
+	assert.Len(t, threats, 1)
+}
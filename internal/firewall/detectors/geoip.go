@@ -1,41 +1,66 @@
 package detectors
 
 import (
+	"container/list"
 	"net/http"
-	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 
-	"github.com/oschwald/geoip2-golang"
 	"prerender-shield/internal/config"
 	"prerender-shield/internal/firewall/types"
+	"prerender-shield/internal/utils"
+)
+
+const (
+	// geoIPCacheSize 国家代码缓存的最大IP数
+	geoIPCacheSize = 4096
+	// geoIPCacheTTL 国家代码缓存有效期，数据库更新后缓存逐步失效
+	geoIPCacheTTL = 10 * time.Minute
+)
+
+// 国家代码缓存监控指标，所有站点的缓存合计
+var (
+	geoIPCacheEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "firewall_geoip_cache_entries",
+			Help: "Number of entries in the GeoIP country LRU caches",
+		},
+	)
+	geoIPCacheEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "firewall_geoip_cache_evictions_total",
+			Help: "Total number of least recently used entries evicted from the GeoIP country caches",
+		},
+	)
 )
 
+func init() {
+	prometheus.MustRegister(geoIPCacheEntries, geoIPCacheEvictions)
+}
+
+// GeoIPResolver 查询IP所属国家代码，防火墙使用services.MMDBReader只查询本地数据库，不在请求路径上访问网络
+type GeoIPResolver interface {
+	LookupCountryISO(ip string) (string, error)
+}
+
 // GeoIPDetector 地理位置访问控制检测器
 type GeoIPDetector struct {
-	reader      *geoip2.Reader
+	resolver    GeoIPResolver
+	cache       *countryCache
 	geoIPConfig *config.GeoIPConfig
 }
 
-// NewGeoIPDetector 创建新的地理位置访问控制检测器
-func NewGeoIPDetector(geoIPConfig *config.GeoIPConfig) *GeoIPDetector {
-	// 尝试加载GeoIP数据库
-	// 这里假设数据库文件在配置目录中，或者使用默认路径
-	// 由于环境限制，我们这里实现一个容错机制：
-	// 如果无法加载数据库，我们将使用模拟模式
-	
-	var reader *geoip2.Reader
-	// 实际项目中应该从配置文件读取路径
-	dbPath := "./rules/GeoLite2-Country.mmdb"
-	
-	r, err := geoip2.Open(dbPath)
-	if err != nil {
-		// Log error but continue with nil reader (will use fallback/mock)
-		// fmt.Printf("Warning: Failed to open GeoIP database: %v. Using mock mode.\n", err)
-	} else {
-		reader = r
+// NewGeoIPDetector 创建新的地理位置访问控制检测器，resolver为空或查询失败时不按地区拦截
+func NewGeoIPDetector(geoIPConfig *config.GeoIPConfig, resolver GeoIPResolver) *GeoIPDetector {
+	if geoIPConfig != nil && geoIPConfig.Enabled && resolver == nil {
+		logger.Warn("GeoIP access control is enabled but no local GeoIP database is configured, country rules are not enforced")
 	}
 
 	return &GeoIPDetector{
-		reader:      reader,
+		resolver:    resolver,
+		cache:       newCountryCache(geoIPCacheSize, geoIPCacheTTL),
 		geoIPConfig: geoIPConfig,
 	}
 }
@@ -49,29 +74,16 @@ func (d *GeoIPDetector) Detect(req *http.Request) ([]types.Threat, error) {
 		return threats, nil
 	}
 
-	// 获取请求IP地址
-	ip := getClientIP(req)
+	// 获取站点处理器解析出的客户端IP地址
+	ip := utils.ClientIP(req)
 	if ip == "" {
 		return threats, nil
 	}
 
-	// 获取国家/地区代码
-	countryCode := "UNKNOWN"
-	
-	if d.reader != nil {
-		// 使用数据库查询
-		// net.ParseIP(ip)
-		// ...
-		// 暂时略过实际查询代码，因为需要引入net包
-	} else {
-		// 模拟模式/回退模式
-		// 本地IP视为中国(CN)以便测试
-		if ip == "127.0.0.1" || ip == "::1" || ip == "localhost" {
-			countryCode = "CN"
-		} else {
-			// 其他IP随机或默认为US
-			countryCode = "US"
-		}
+	// 获取国家/地区代码，无法确定地区的请求（如内网IP或数据库中没有记录）不拦截
+	countryCode, ok := d.lookupCountry(ip)
+	if !ok {
+		return threats, nil
 	}
 
 	// 检查是否在阻止列表中
@@ -125,27 +137,97 @@ func (d *GeoIPDetector) Name() string {
 	return "geoip"
 }
 
-// getClientIP 获取客户端真实IP地址
-func getClientIP(req *http.Request) string {
-	// 首先检查X-Forwarded-For头
-	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For格式：client, proxy1, proxy2
-		parts := strings.Split(xff, ",")
-		if len(parts) > 0 {
-			return strings.TrimSpace(parts[0])
-		}
+// lookupCountry 先查询缓存，未命中时查询本地数据库，查询失败的结果不缓存
+func (d *GeoIPDetector) lookupCountry(ip string) (string, bool) {
+	if countryCode, ok := d.cache.Get(ip); ok {
+		return countryCode, true
+	}
+	if d.resolver == nil {
+		return "", false
 	}
 
-	// 检查X-Real-IP头
-	if xrip := req.Header.Get("X-Real-IP"); xrip != "" {
-		return xrip
+	countryCode, err := d.resolver.LookupCountryISO(ip)
+	if err != nil || countryCode == "" {
+		return "", false
 	}
+	d.cache.Add(ip, countryCode)
+	return countryCode, true
+}
 
-	// 直接使用RemoteAddr
-	remoteAddr := req.RemoteAddr
-	if idx := strings.LastIndex(remoteAddr, ":"); idx != -1 {
-		return remoteAddr[:idx]
+// countryCache 固定容量的IP国家代码LRU缓存
+type countryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List // 最近使用的条目在前
+}
+
+// countryCacheEntry 缓存条目
+type countryCacheEntry struct {
+	ip          string
+	countryCode string
+	expiresAt   time.Time
+}
+
+// newCountryCache 创建国家代码缓存
+func newCountryCache(capacity int, ttl time.Duration) *countryCache {
+	return &countryCache{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get 返回未过期的缓存条目并标记为最近使用
+func (c *countryCache) Get(ip string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[ip]
+	if !ok {
+		return "", false
 	}
+	entry := element.Value.(*countryCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.items, ip)
+		geoIPCacheEntries.Dec()
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return entry.countryCode, true
+}
+
+// Add 写入缓存，超过容量时淘汰最久未使用的条目
+func (c *countryCache) Add(ip, countryCode string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.items[ip]; ok {
+		entry := element.Value.(*countryCacheEntry)
+		entry.countryCode = countryCode
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[ip] = c.order.PushFront(&countryCacheEntry{ip: ip, countryCode: countryCode, expiresAt: expiresAt})
+	geoIPCacheEntries.Inc()
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*countryCacheEntry).ip)
+		geoIPCacheEntries.Dec()
+		geoIPCacheEvictions.Inc()
+	}
+}
 
-	return remoteAddr
+// Len 返回缓存条目数
+func (c *countryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
 }
@@ -0,0 +1,91 @@
+package detectors
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/config"
+)
+
+// fakeGeoIPResolver 按IP返回固定国家代码并记录查询次数
+type fakeGeoIPResolver struct {
+	countries map[string]string
+	lookups   int
+}
+
+func (r *fakeGeoIPResolver) LookupCountryISO(ip string) (string, error) {
+	r.lookups++
+	if country, ok := r.countries[ip]; ok {
+		return country, nil
+	}
+	return "", errors.New("no geoip record")
+}
+
+// TestGeoIPDetector_LocalLookup 测试按本地数据库的国家代码拦截，查询结果缓存，无法确定地区的请求不拦截
+func TestGeoIPDetector_LocalLookup(t *testing.T) {
+	resolver := &fakeGeoIPResolver{countries: map[string]string{"203.0.113.1": "US", "198.51.100.1": "CN"}}
+	detector := NewGeoIPDetector(&config.GeoIPConfig{Enabled: true, BlockList: []string{"CN"}}, resolver)
+
+	detect := func(ip string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":12345"
+		threats, err := detector.Detect(req)
+		assert.NoError(t, err)
+		return len(threats)
+	}
+
+	assert.Equal(t, 1, detect("198.51.100.1"))
+	assert.Equal(t, 0, detect("203.0.113.1"))
+	assert.Equal(t, 1, detect("198.51.100.1"))
+	assert.Equal(t, 2, resolver.lookups, "repeated lookups should be served from the cache")
+
+	assert.Equal(t, 0, detect("192.0.2.1"), "IPs without a record are not blocked")
+	assert.Equal(t, 0, detect("192.0.2.1"))
+	assert.Equal(t, 4, resolver.lookups, "failed lookups are not cached")
+
+	// 没有本地数据库时地区限制不生效
+	withoutDatabase := NewGeoIPDetector(&config.GeoIPConfig{Enabled: true, AllowList: []string{"US"}}, nil)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "198.51.100.1:12345"
+	threats, err := withoutDatabase.Detect(req)
+	assert.NoError(t, err)
+	assert.Empty(t, threats)
+}
+
+// TestCountryCache_Eviction 测试缓存超过容量时淘汰最久未使用的条目，过期条目重新查询
+func TestCountryCache_Eviction(t *testing.T) {
+	cache := newCountryCache(3, time.Minute)
+	for i := 1; i <= 3; i++ {
+		cache.Add(fmt.Sprintf("192.0.2.%d", i), "US")
+	}
+	_, ok := cache.Get("192.0.2.1")
+	assert.True(t, ok)
+
+	evictions := counterValue(t)
+	cache.Add("192.0.2.4", "CN")
+	assert.Equal(t, 3, cache.Len())
+	assert.Equal(t, evictions+1, counterValue(t), "eviction should be counted")
+	_, ok = cache.Get("192.0.2.2")
+	assert.False(t, ok, "least recently used entry should be evicted")
+	_, ok = cache.Get("192.0.2.1")
+	assert.True(t, ok, "recently used entry should be kept")
+
+	expiring := newCountryCache(3, -time.Second)
+	expiring.Add("192.0.2.1", "US")
+	_, ok = expiring.Get("192.0.2.1")
+	assert.False(t, ok)
+	assert.Equal(t, 0, expiring.Len())
+}
+
+// counterValue 返回国家代码缓存淘汰计数
+func counterValue(t *testing.T) float64 {
+	var metric dto.Metric
+	assert.NoError(t, geoIPCacheEvictions.Write(&metric))
+	return metric.GetCounter().GetValue()
+}
@@ -1,36 +1,76 @@
 package detectors
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"prerender-shield/internal/config"
 	"prerender-shield/internal/firewall/types"
+	"prerender-shield/internal/logging"
+	"prerender-shield/internal/utils"
+
+	"github.com/go-redis/redis/v8"
 )
 
+// logger 防火墙模块的日志记录器，日志级别可以单独设置
+var logger = logging.Module("firewall")
+
 // RateLimitDetector 频率限制检测器
+// 计数器按客户端IP和匹配的路径规则分别统计，超过路径规则的限制只封禁该IP对这类路径的访问。
+// 配置了Redis时使用Redis滑动窗口计数并保存封禁记录，多个实例共享状态；Redis不可用时回退到内存计数
 type RateLimitDetector struct {
 	mutex           sync.RWMutex
-	ipCounters      map[string]*IPCounter
+	ipCounters      map[string]*IPCounter // IP + 路径规则 -> 计数器
 	rateLimitConfig *config.RateLimitConfig
+	redisClient     *redis.Client
+	siteID          string
+	sequence        uint64 // 滑动窗口成员序号，保证同一时刻的请求各自计数
+	cancel          context.CancelFunc
+	done            chan struct{} // 清理协程退出时关闭
+}
+
+// Ban 频率限制封禁记录
+type Ban struct {
+	IP        string    `json:"ip"`
+	Pattern   string    `json:"pattern,omitempty"` // 触发封禁的路径规则，为空表示全局限制
+	BannedAt  time.Time `json:"banned_at"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 // IPCounter IP请求计数器
 type IPCounter struct {
 	Requests    []time.Time
+	BannedAt    time.Time
 	BannedUntil time.Time
 }
 
-// NewRateLimitDetector 创建新的频率限制检测器
+// NewRateLimitDetector 创建新的频率限制检测器，计数和封禁只保存在内存中
 func NewRateLimitDetector(rateLimitConfig *config.RateLimitConfig) *RateLimitDetector {
+	return NewRedisRateLimitDetector(rateLimitConfig, nil, "")
+}
+
+// NewRedisRateLimitDetector 创建使用Redis共享计数和封禁状态的频率限制检测器，redisClient为nil时只使用内存
+func NewRedisRateLimitDetector(rateLimitConfig *config.RateLimitConfig, redisClient *redis.Client, siteID string) *RateLimitDetector {
+	ctx, cancel := context.WithCancel(context.Background())
 	d := &RateLimitDetector{
 		ipCounters:      make(map[string]*IPCounter),
 		rateLimitConfig: rateLimitConfig,
+		redisClient:     redisClient,
+		siteID:          siteID,
+		cancel:          cancel,
+		done:            make(chan struct{}),
 	}
 
-	// 启动清理过期请求的协程
-	go d.cleanupLoop()
+	// 启动清理过期请求的协程，Close时退出
+	go d.cleanupLoop(ctx)
 
 	return d
 }
@@ -44,35 +84,28 @@ func (d *RateLimitDetector) Detect(req *http.Request) ([]types.Threat, error) {
 		return threats, nil
 	}
 
-	// 获取请求IP地址
-	ip := getClientIP(req)
+	// 按站点处理器解析出的客户端IP计数，只有可信代理转发的X-Forwarded-For才被采用
+	ip := utils.ClientIP(req)
 	if ip == "" {
 		return threats, nil
 	}
 
+	// 选取请求路径适用的频率限制，计数器按IP和匹配的路径规则区分
+	pattern, maxRequests, windowSeconds := d.rateLimitConfig.LimitFor(req.URL.Path)
+	key := rateLimitKey(ip, pattern)
+
 	// 检查是否被封禁
-	if d.isBanned(ip) {
-		threats = append(threats, types.Threat{
-			Type:     "rate_limit",
-			SubType:  "banned",
-			Severity: "high",
-			Message:  "IP is banned due to excessive requests",
-			SourceIP: ip,
-			Details: map[string]interface{}{
-				"reason": "banned",
-			},
-		})
-		return threats, nil
+	if d.isBanned(req.Context(), key) {
+		return append(threats, bannedThreat(ip, pattern)), nil
 	}
 
 	// 从配置中获取频率限制参数
-	maxRequests := d.rateLimitConfig.Requests
-	window := time.Duration(d.rateLimitConfig.Window) * time.Second
+	window := time.Duration(windowSeconds) * time.Second
 	banTime := time.Duration(d.rateLimitConfig.BanTime) * time.Second
 
-	if d.exceedsRateLimit(ip, maxRequests, window) {
+	if d.exceedsRateLimit(req.Context(), key, maxRequests, window) {
 		// 封禁IP
-		d.banIP(ip, banTime)
+		d.banIP(req.Context(), ip, pattern, banTime)
 
 		threats = append(threats, types.Threat{
 			Type:     "rate_limit",
@@ -84,6 +117,7 @@ func (d *RateLimitDetector) Detect(req *http.Request) ([]types.Threat, error) {
 				"max_requests": maxRequests,
 				"window":       window.Seconds(),
 				"ban_time":     banTime.Seconds(),
+				"pattern":      pattern,
 			},
 		})
 	}
@@ -91,13 +125,101 @@ func (d *RateLimitDetector) Detect(req *http.Request) ([]types.Threat, error) {
 	return threats, nil
 }
 
+// CheckBan 只检查请求IP是否处于封禁期，不计数，供引擎在执行其他检测前快速拦截
+func (d *RateLimitDetector) CheckBan(req *http.Request) []types.Threat {
+	if d.rateLimitConfig == nil || !d.rateLimitConfig.Enabled {
+		return nil
+	}
+
+	ip := utils.ClientIP(req)
+	if ip == "" {
+		return nil
+	}
+
+	pattern, _, _ := d.rateLimitConfig.LimitFor(req.URL.Path)
+	if !d.isBanned(req.Context(), rateLimitKey(ip, pattern)) {
+		return nil
+	}
+	return []types.Threat{bannedThreat(ip, pattern)}
+}
+
+// bannedThreat 返回封禁期内请求的威胁
+func bannedThreat(ip, pattern string) types.Threat {
+	return types.Threat{
+		Type:     "rate_limit",
+		SubType:  "banned",
+		Severity: "high",
+		Message:  "IP is banned due to excessive requests",
+		SourceIP: ip,
+		Details: map[string]interface{}{
+			"reason":  "banned",
+			"pattern": pattern,
+		},
+	}
+}
+
+// rateLimitKey 返回计数器的键，未匹配路径规则时只使用IP
+func rateLimitKey(ip, pattern string) string {
+	if pattern == "" {
+		return ip
+	}
+	return ip + "|" + pattern
+}
+
 // Name 返回检测器名称
 func (d *RateLimitDetector) Name() string {
 	return "rate_limit"
 }
 
-// exceedsRateLimit 检查IP是否超过频率限制
-func (d *RateLimitDetector) exceedsRateLimit(ip string, maxRequests int, window time.Duration) bool {
+// splitRateLimitKey 从计数器的键中还原IP和路径规则
+func splitRateLimitKey(key string) (ip, pattern string) {
+	if i := strings.Index(key, "|"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return key, ""
+}
+
+// counterKey 返回Redis中滑动窗口计数的键
+func (d *RateLimitDetector) counterKey(key string) string {
+	return fmt.Sprintf("firewall:%s:ratelimit:%s", d.siteID, key)
+}
+
+// banKey 返回Redis中封禁记录的键
+func (d *RateLimitDetector) banKey(key string) string {
+	return fmt.Sprintf("firewall:%s:ban:%s", d.siteID, key)
+}
+
+// exceedsRateLimit 检查IP是否超过频率限制，Redis出错时回退到内存计数
+func (d *RateLimitDetector) exceedsRateLimit(ctx context.Context, key string, maxRequests int, window time.Duration) bool {
+	if d.redisClient != nil {
+		count, err := d.redisWindowCount(ctx, key, window)
+		if err == nil {
+			return count > int64(maxRequests)
+		}
+		logger.Warn("Site %s rate limit falls back to memory: %v", d.siteID, err)
+	}
+	return d.memoryExceedsRateLimit(key, maxRequests, window)
+}
+
+// redisWindowCount 在Redis有序集合中记录本次请求并返回窗口内的请求数
+func (d *RateLimitDetector) redisWindowCount(ctx context.Context, key string, window time.Duration) (int64, error) {
+	now := time.Now()
+	redisKey := d.counterKey(key)
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatUint(atomic.AddUint64(&d.sequence, 1), 10)
+
+	pipe := d.redisClient.Pipeline()
+	pipe.ZRemRangeByScore(ctx, redisKey, "-inf", strconv.FormatInt(now.Add(-window).UnixMicro(), 10))
+	pipe.ZAdd(ctx, redisKey, &redis.Z{Score: float64(now.UnixMicro()), Member: member})
+	count := pipe.ZCard(ctx, redisKey)
+	pipe.Expire(ctx, redisKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// memoryExceedsRateLimit 使用内存计数器检查IP是否超过频率限制
+func (d *RateLimitDetector) memoryExceedsRateLimit(ip string, maxRequests int, window time.Duration) bool {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
@@ -131,8 +253,20 @@ func (d *RateLimitDetector) exceedsRateLimit(ip string, maxRequests int, window
 	return len(validRequests) > maxRequests
 }
 
-// isBanned 检查IP是否被封禁
-func (d *RateLimitDetector) isBanned(ip string) bool {
+// isBanned 检查IP是否被封禁，Redis出错时回退到内存记录
+func (d *RateLimitDetector) isBanned(ctx context.Context, key string) bool {
+	if d.redisClient != nil {
+		exists, err := d.redisClient.Exists(ctx, d.banKey(key)).Result()
+		if err == nil {
+			return exists > 0
+		}
+		logger.Warn("Site %s rate limit falls back to memory: %v", d.siteID, err)
+	}
+	return d.memoryIsBanned(key)
+}
+
+// memoryIsBanned 检查内存中的封禁记录
+func (d *RateLimitDetector) memoryIsBanned(ip string) bool {
 	d.mutex.RLock()
 	defer d.mutex.RUnlock()
 
@@ -144,8 +278,41 @@ func (d *RateLimitDetector) isBanned(ip string) bool {
 	return !counter.BannedUntil.IsZero() && time.Now().Before(counter.BannedUntil)
 }
 
-// banIP 封禁IP
-func (d *RateLimitDetector) banIP(ip string, duration time.Duration) {
+// banIP 封禁IP对路径规则的访问，封禁记录以BanTime为TTL保存在Redis中，Redis出错时保存在内存中
+func (d *RateLimitDetector) banIP(ctx context.Context, ip, pattern string, duration time.Duration) {
+	key := rateLimitKey(ip, pattern)
+	if d.redisClient != nil {
+		err := d.redisBan(ctx, ip, pattern, duration)
+		if err == nil {
+			return
+		}
+		logger.Warn("Site %s rate limit falls back to memory: %v", d.siteID, err)
+	}
+	d.memoryBan(key, duration)
+}
+
+// redisBan 在Redis中保存封禁记录并清空窗口计数
+func (d *RateLimitDetector) redisBan(ctx context.Context, ip, pattern string, duration time.Duration) error {
+	key := rateLimitKey(ip, pattern)
+	if duration <= 0 {
+		return d.redisClient.Del(ctx, d.counterKey(key)).Err()
+	}
+
+	now := time.Now()
+	data, err := json.Marshal(Ban{IP: ip, Pattern: pattern, BannedAt: now, ExpiresAt: now.Add(duration)})
+	if err != nil {
+		return err
+	}
+
+	pipe := d.redisClient.Pipeline()
+	pipe.Set(ctx, d.banKey(key), data, duration)
+	pipe.Del(ctx, d.counterKey(key))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// memoryBan 在内存中封禁IP
+func (d *RateLimitDetector) memoryBan(ip string, duration time.Duration) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
@@ -157,21 +324,142 @@ func (d *RateLimitDetector) banIP(ip string, duration time.Duration) {
 		d.ipCounters[ip] = counter
 	}
 
-	counter.BannedUntil = time.Now().Add(duration)
+	counter.BannedAt = time.Now()
+	counter.BannedUntil = counter.BannedAt.Add(duration)
 	// 清空请求记录
 	counter.Requests = make([]time.Time, 0)
 }
 
+// ListBans 返回当前有效的封禁记录，包括Redis和内存中的记录，按过期时间排序
+func (d *RateLimitDetector) ListBans(ctx context.Context) ([]Ban, error) {
+	bans := d.memoryBans()
+
+	if d.redisClient != nil {
+		banKeys, err := d.scanKeys(ctx, d.banKey("*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, banKey := range banKeys {
+			data, err := d.redisClient.Get(ctx, banKey).Result()
+			if err == redis.Nil {
+				continue // 扫描后已过期
+			}
+			if err != nil {
+				return nil, err
+			}
+			var ban Ban
+			if err := json.Unmarshal([]byte(data), &ban); err != nil {
+				logger.Warn("Site %s ignores malformed ban %s: %v", d.siteID, banKey, err)
+				continue
+			}
+			bans = append(bans, ban)
+		}
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].ExpiresAt.Before(bans[j].ExpiresAt)
+	})
+	return bans, nil
+}
+
+// memoryBans 返回内存中有效的封禁记录
+func (d *RateLimitDetector) memoryBans() []Ban {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	now := time.Now()
+	bans := make([]Ban, 0)
+	for key, counter := range d.ipCounters {
+		if counter.BannedUntil.IsZero() || !now.Before(counter.BannedUntil) {
+			continue
+		}
+		ip, pattern := splitRateLimitKey(key)
+		bans = append(bans, Ban{IP: ip, Pattern: pattern, BannedAt: counter.BannedAt, ExpiresAt: counter.BannedUntil})
+	}
+	return bans
+}
+
+// Unban 解除IP的全部封禁（包括各路径规则的封禁）并清空计数，返回解除的封禁数
+func (d *RateLimitDetector) Unban(ctx context.Context, ip string) (int, error) {
+	lifted := 0
+
+	d.mutex.Lock()
+	for key, counter := range d.ipCounters {
+		if keyIP, _ := splitRateLimitKey(key); keyIP != ip {
+			continue
+		}
+		if !counter.BannedUntil.IsZero() && time.Now().Before(counter.BannedUntil) {
+			lifted++
+		}
+		delete(d.ipCounters, key)
+	}
+	d.mutex.Unlock()
+
+	if d.redisClient == nil {
+		return lifted, nil
+	}
+
+	// 同时匹配全局封禁和各路径规则的封禁，配置变更后遗留的路径规则封禁同样解除
+	banKeys, err := d.scanKeys(ctx, d.banKey(ip), d.banKey(ip+"|*"))
+	if err != nil {
+		return lifted, err
+	}
+	counterKeys, err := d.scanKeys(ctx, d.counterKey(ip), d.counterKey(ip+"|*"))
+	if err != nil {
+		return lifted, err
+	}
+	lifted += len(banKeys)
+
+	if keys := append(banKeys, counterKeys...); len(keys) > 0 {
+		if err := d.redisClient.Del(ctx, keys...).Err(); err != nil {
+			return lifted, err
+		}
+	}
+	return lifted, nil
+}
+
+// scanKeys 返回匹配任一模式的全部Redis键
+func (d *RateLimitDetector) scanKeys(ctx context.Context, matches ...string) ([]string, error) {
+	var keys []string
+	for _, match := range matches {
+		var cursor uint64
+		for {
+			batch, next, err := d.redisClient.Scan(ctx, cursor, match, 100).Result()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, batch...)
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return keys, nil
+}
+
 // cleanupLoop 定期清理过期的请求记录
-func (d *RateLimitDetector) cleanupLoop() {
+func (d *RateLimitDetector) cleanupLoop(ctx context.Context) {
+	defer close(d.done)
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		d.cleanupExpired()
+	for {
+		select {
+		case <-ticker.C:
+			d.cleanupExpired()
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
+// Close 停止清理协程并等待其退出，可以重复调用
+func (d *RateLimitDetector) Close() {
+	d.cancel()
+	<-d.done
+}
+
 // cleanupExpired 清理过期的请求记录
 func (d *RateLimitDetector) cleanupExpired() {
 	d.mutex.Lock()
@@ -0,0 +1,172 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/config"
+)
+
+// TestRateLimitDetector_PathOverrides 测试按路径覆盖的频率限制：/login每分钟5次，其他路径每分钟100次
+func TestRateLimitDetector_PathOverrides(t *testing.T) {
+	detector := NewRateLimitDetector(&config.RateLimitConfig{
+		Enabled:  true,
+		Requests: 100,
+		Window:   60,
+		BanTime:  3600,
+		Paths: []config.RateLimitPathRule{
+			{Pattern: "/login", Requests: 5, Window: 60},
+			{Pattern: "/log", Requests: 50},
+		},
+	})
+
+	request := func(path, ip string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":12345"
+		threats, err := detector.Detect(req)
+		assert.NoError(t, err)
+		return len(threats)
+	}
+
+	// 最具体的规则生效：/login前5次放行，第6次被限制
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, 0, request("/login", "10.0.0.1"), "login request %d should pass", i+1)
+	}
+	assert.Equal(t, 1, request("/login", "10.0.0.1"))
+	assert.Equal(t, 1, request("/login", "10.0.0.1"), "IP should stay banned from /login")
+
+	// 同一IP访问其他路径使用全局限制，计数器与/login分开
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 0, request("/", "10.0.0.1"), "request %d to / should pass", i+1)
+	}
+	assert.Equal(t, 1, request("/", "10.0.0.1"))
+
+	// 其他IP不受影响
+	assert.Equal(t, 0, request("/login", "10.0.0.2"))
+
+	pattern, requests, window := detector.rateLimitConfig.LimitFor("/logs")
+	assert.Equal(t, "/log", pattern)
+	assert.Equal(t, 50, requests)
+	assert.Equal(t, 60, window, "rule without window should use the global window")
+}
+
+// TestRateLimitDetector_IgnoresForwardedFor 测试频率限制按TCP对端IP计数，更换X-Forwarded-For不能重置计数或绕过封禁
+func TestRateLimitDetector_IgnoresForwardedFor(t *testing.T) {
+	detector := NewRateLimitDetector(&config.RateLimitConfig{
+		Enabled:  true,
+		Requests: 3,
+		Window:   60,
+		BanTime:  3600,
+	})
+	defer detector.Close()
+
+	request := func(i int) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))
+		req.Header.Set("X-Real-IP", fmt.Sprintf("198.51.100.%d", i))
+		threats, err := detector.Detect(req)
+		assert.NoError(t, err)
+		if len(threats) > 0 {
+			assert.Equal(t, "10.0.0.1", threats[0].SourceIP)
+		}
+		return len(threats)
+	}
+
+	for i := 1; i <= 3; i++ {
+		assert.Equal(t, 0, request(i), "request %d should pass", i)
+	}
+	assert.Equal(t, 1, request(4), "changing X-Forwarded-For should not reset the count")
+	assert.Equal(t, 1, request(5), "changing X-Forwarded-For should not lift the ban")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	req.Header.Set("X-Forwarded-For", "203.0.113.99")
+	assert.Len(t, detector.CheckBan(req), 1)
+}
+
+// TestRateLimitDetector_RedisSharedState 测试多个检测器实例通过Redis共享滑动窗口计数和封禁记录
+func TestRateLimitDetector_RedisSharedState(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer client.Close()
+
+	rateLimitConfig := &config.RateLimitConfig{Enabled: true, Requests: 5, Window: 60, BanTime: 3600}
+	first := NewRedisRateLimitDetector(rateLimitConfig, client, "site1")
+	second := NewRedisRateLimitDetector(rateLimitConfig, client, "site1")
+
+	request := func(detector *RateLimitDetector, ip string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":12345"
+		threats, err := detector.Detect(req)
+		assert.NoError(t, err)
+		return len(threats)
+	}
+
+	// 两个实例轮流处理请求，合计超过限制后封禁
+	for i := 0; i < 5; i++ {
+		detector := first
+		if i%2 == 1 {
+			detector = second
+		}
+		assert.Equal(t, 0, request(detector, "10.0.0.1"), "request %d should pass", i+1)
+	}
+	assert.Equal(t, 1, request(second, "10.0.0.1"), "6th request across instances should exceed the limit")
+	assert.Equal(t, 0, request(second, "10.0.0.2"), "other IPs are not affected")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+	threats := first.CheckBan(req)
+	if assert.Len(t, threats, 1, "ban should be visible to the other instance") {
+		assert.Equal(t, "banned", threats[0].SubType)
+	}
+
+	bans, err := second.ListBans(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, bans, 1) {
+		assert.Equal(t, "10.0.0.1", bans[0].IP)
+		assert.True(t, bans[0].ExpiresAt.After(time.Now().Add(59*time.Minute)))
+	}
+
+	lifted, err := first.Unban(context.Background(), "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, lifted)
+	assert.Empty(t, second.CheckBan(req))
+	assert.Equal(t, 0, request(second, "10.0.0.1"), "counter should be reset after lifting the ban")
+}
+
+// TestRateLimitDetector_RedisUnavailable 测试Redis不可用时回退到内存计数
+func TestRateLimitDetector_RedisUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+
+	detector := NewRedisRateLimitDetector(&config.RateLimitConfig{Enabled: true, Requests: 2, Window: 60, BanTime: 60}, client, "site1")
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:12345"
+
+	for i := 0; i < 2; i++ {
+		threats, err := detector.Detect(req)
+		assert.NoError(t, err)
+		assert.Empty(t, threats, "request %d should pass", i+1)
+	}
+	threats, err := detector.Detect(req)
+	assert.NoError(t, err)
+	assert.Len(t, threats, 1)
+	assert.Len(t, detector.CheckBan(req), 1, "ban should be kept in memory")
+
+	bans, err := detector.ListBans(context.Background())
+	assert.Error(t, err, "listing bans should report the Redis failure")
+	assert.Nil(t, bans)
+}
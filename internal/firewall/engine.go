@@ -1,7 +1,13 @@
 package firewall
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,8 +16,13 @@ import (
 	"prerender-shield/internal/config"
 	"prerender-shield/internal/firewall/detectors"
 	"prerender-shield/internal/firewall/types"
+	"prerender-shield/internal/logging"
+	"prerender-shield/internal/utils"
 )
 
+// logger 防火墙模块的日志记录器，日志级别可以单独设置
+var logger = logging.Module("firewall")
+
 // Engine 防火墙引擎
 type Engine struct {
 	SiteName       string // 站点名称
@@ -21,9 +32,14 @@ type Engine struct {
 	actionHandler  ActionHandler
 	ruleManager    *RuleManager
 	logger         Logger
-	requestCache   map[string]*CheckResult // 请求缓存，用于相同请求快速返回结果
-	cacheMutex     sync.RWMutex            // 请求缓存互斥锁
-	cacheTTL       time.Duration           // 请求缓存过期时间
+	requestCache   map[string]*CheckResult      // 请求缓存，用于相同请求快速返回结果
+	cacheMutex     sync.RWMutex                 // 请求缓存互斥锁
+	cacheTTL       time.Duration                // 请求缓存过期时间
+	crawlerBypass  bool                         // 为true时标记为已验证爬虫的请求跳过OWASP检测
+	whitelist      *utils.IPList                // 静态白名单，匹配的IP跳过全部检测
+	rateLimiter    *detectors.RateLimitDetector // 频率限制检测器，封禁检查在其他检测之前执行
+	cancel         context.CancelFunc           // 停止后台清理协程
+	wg             sync.WaitGroup               // 等待后台清理协程退出
 }
 
 // OWASPDetector OWASP Top 10检测器接口
@@ -43,29 +59,6 @@ type ActionHandler interface {
 	Handle(w http.ResponseWriter, req *http.Request, result *CheckResult) bool
 }
 
-// RuleManager 规则管理器
-type RuleManager struct {
-	rules map[string][]types.Rule
-}
-
-// GetRulesByCategory 根据分类获取规则
-func (rm *RuleManager) GetRulesByCategory(category string) []types.Rule {
-	return rm.rules[category]
-}
-
-// ReloadRules 重新加载规则
-func (rm *RuleManager) ReloadRules() error {
-	// 实现规则重新加载逻辑
-	return nil
-}
-
-// NewRuleManager 创建新的规则管理器
-func NewRuleManager() *RuleManager {
-	return &RuleManager{
-		rules: make(map[string][]types.Rule),
-	}
-}
-
 // Logger 日志接口
 type Logger interface {
 	Error(format string, args ...interface{})
@@ -74,35 +67,64 @@ type Logger interface {
 
 // Config 防火墙配置
 type Config struct {
-	RulesPath           string
-	ActionConfig        ActionConfig
-	CacheTTL            int                         // 请求缓存过期时间（秒）
-	StaticDir           string                      // 静态文件目录
-	GeoIPConfig         *config.GeoIPConfig         // 地理位置访问控制配置
-	RateLimitConfig     *config.RateLimitConfig     // 频率限制配置
-	FileIntegrityConfig *config.FileIntegrityConfig // 网页防篡改配置
-	Blacklist           []string                    // 静态黑名单
-	Whitelist           []string                    // 静态白名单
-	RedisClient         *redis.Client               // Redis客户端
+	RulesPath       string
+	ActionConfig    ActionConfig
+	CacheTTL        int                         // 请求缓存过期时间（秒）
+	StaticDir       string                      // 静态文件目录
+	GeoIPConfig     *config.GeoIPConfig         // 地理位置访问控制配置
+	RateLimitConfig *config.RateLimitConfig     // 频率限制配置
+	Blacklist       []string                    // 静态黑名单
+	Whitelist       []string                    // 静态白名单
+	RedisClient     *redis.Client               // Redis客户端
+	CrawlerBypass   *config.CrawlerBypassConfig // 已验证爬虫绕过规则检测配置，爬虫由站点处理器验证后通过WithVerifiedCrawler标记
+	GeoIPResolver   detectors.GeoIPResolver     // 地区限制使用的本地GeoIP数据库，为空时使用引擎管理器设置的数据库
+}
+
+// NewSiteConfig 根据站点配置创建防火墙引擎配置
+func NewSiteConfig(site config.SiteConfig, staticDir string, redisClient *redis.Client) Config {
+	return Config{
+		RulesPath: site.Firewall.RulesPath,
+		ActionConfig: ActionConfig{
+			DefaultAction: site.Firewall.ActionConfig.DefaultAction,
+			BlockMessage:  site.Firewall.ActionConfig.BlockMessage,
+			ChallengeTTL:  site.Firewall.ActionConfig.ChallengeTTL,
+			BlockPagePath: site.Firewall.ActionConfig.BlockPagePath,
+			ContactURL:    site.Firewall.ActionConfig.ContactURL,
+		},
+		StaticDir:       staticDir,
+		GeoIPConfig:     &site.Firewall.GeoIPConfig,
+		RateLimitConfig: &site.Firewall.RateLimitConfig,
+		Blacklist:       site.Firewall.Blacklist,
+		Whitelist:       site.Firewall.Whitelist,
+		RedisClient:     redisClient,
+		CrawlerBypass:   &site.Firewall.CrawlerBypass,
+	}
 }
 
 // ActionConfig 动作配置
 type ActionConfig struct {
 	DefaultAction string
 	BlockMessage  string
+	ChallengeTTL  int    // 挑战令牌有效期（秒）
+	BlockPagePath string // 拦截页面模板，相对静态文件目录的路径
+	ContactURL    string // 拦截页面中的联系链接
 }
 
 // CheckResult 检查结果
 type CheckResult struct {
-	Threats   []types.Threat
-	CreatedAt time.Time
-	Allow     bool
+	Threats    []types.Threat
+	CreatedAt  time.Time
+	Allow      bool
+	Challenge  bool   // 拦截的威胁全部来自动作为challenge的自定义规则，返回JS挑战而不是直接拦截
+	Action     string // 动作处理器执行的动作：allow、block、challenge或log
+	IncidentID string // 拦截时生成的事件ID，返回给客户端并写入WAF日志
 }
 
 // EngineManager 防火墙引擎管理器，用于管理多个站点的防火墙引擎
 type EngineManager struct {
-	mutex   sync.RWMutex
-	engines map[string]*Engine
+	mutex         sync.RWMutex
+	engines       map[string]*Engine
+	geoIPResolver detectors.GeoIPResolver
 }
 
 // NewEngineManager 创建新的防火墙引擎管理器
@@ -112,6 +134,14 @@ func NewEngineManager() *EngineManager {
 	}
 }
 
+// SetGeoIPResolver 设置各站点地区限制共用的本地GeoIP数据库，之后创建的引擎生效
+func (em *EngineManager) SetGeoIPResolver(resolver detectors.GeoIPResolver) {
+	em.mutex.Lock()
+	defer em.mutex.Unlock()
+
+	em.geoIPResolver = resolver
+}
+
 // AddSite 添加站点并创建对应的防火墙引擎
 func (em *EngineManager) AddSite(siteName string, config Config) error {
 	em.mutex.Lock()
@@ -122,6 +152,10 @@ func (em *EngineManager) AddSite(siteName string, config Config) error {
 		return nil // 站点已存在，无需重复创建
 	}
 
+	if config.GeoIPResolver == nil {
+		config.GeoIPResolver = em.geoIPResolver
+	}
+
 	// 创建新的防火墙引擎
 	engine, err := NewEngine(siteName, config)
 	if err != nil {
@@ -132,12 +166,60 @@ func (em *EngineManager) AddSite(siteName string, config Config) error {
 	return nil
 }
 
-// RemoveSite 移除站点及其防火墙引擎
-func (em *EngineManager) RemoveSite(siteName string) {
+// ReplaceSite 使用新配置重建站点的防火墙引擎，站点改名时移除旧名称对应的引擎
+// 新引擎创建失败时保留旧引擎
+func (em *EngineManager) ReplaceSite(oldSiteName, siteName string, config Config) error {
+	if config.GeoIPResolver == nil {
+		em.mutex.RLock()
+		config.GeoIPResolver = em.geoIPResolver
+		em.mutex.RUnlock()
+	}
+
+	engine, err := NewEngine(siteName, config)
+	if err != nil {
+		return err
+	}
+
 	em.mutex.Lock()
-	defer em.mutex.Unlock()
+	replaced := make([]*Engine, 0, 2)
+	for _, name := range []string{oldSiteName, siteName} {
+		if old, exists := em.engines[name]; exists {
+			replaced = append(replaced, old)
+			delete(em.engines, name)
+		}
+	}
+	em.engines[siteName] = engine
+	em.mutex.Unlock()
+
+	// 被替换的引擎不再接收新请求，停止其后台协程
+	for _, old := range replaced {
+		old.Stop()
+	}
+	return nil
+}
 
+// RemoveSite 移除站点并停止其防火墙引擎的后台协程
+func (em *EngineManager) RemoveSite(siteName string) {
+	em.mutex.Lock()
+	engine, exists := em.engines[siteName]
 	delete(em.engines, siteName)
+	em.mutex.Unlock()
+
+	if exists {
+		engine.Stop()
+	}
+}
+
+// StopAll 移除所有站点并停止它们的防火墙引擎，用于进程退出
+func (em *EngineManager) StopAll() {
+	em.mutex.Lock()
+	engines := em.engines
+	em.engines = make(map[string]*Engine)
+	em.mutex.Unlock()
+
+	for _, engine := range engines {
+		engine.Stop()
+	}
 }
 
 // GetEngine 获取指定站点的防火墙引擎
@@ -149,6 +231,25 @@ func (em *EngineManager) GetEngine(siteName string) (*Engine, bool) {
 	return engine, exists
 }
 
+// ReloadRules 重新加载使用指定规则目录的所有站点引擎的规则，多个站点可以共用同一规则目录
+func (em *EngineManager) ReloadRules(rulesPath string) error {
+	em.mutex.RLock()
+	engines := make([]*Engine, 0, len(em.engines))
+	for _, engine := range em.engines {
+		if filepath.Clean(engine.ruleManager.RulesPath()) == filepath.Clean(rulesPath) {
+			engines = append(engines, engine)
+		}
+	}
+	em.mutex.RUnlock()
+
+	for _, engine := range engines {
+		if err := engine.UpdateRules(); err != nil {
+			return fmt.Errorf("failed to reload rules of site %s: %v", engine.SiteName, err)
+		}
+	}
+	return nil
+}
+
 // ListSites 列出所有站点
 func (em *EngineManager) ListSites() []string {
 	em.mutex.RLock()
@@ -164,8 +265,11 @@ func (em *EngineManager) ListSites() []string {
 
 // NewEngine 创建新的防火墙引擎
 func NewEngine(siteName string, config Config) (*Engine, error) {
-	// 创建规则管理器
-	ruleManager := NewRuleManager()
+	// 创建规则管理器并加载规则目录中的自定义规则
+	ruleManager := NewRuleManager(config.RulesPath)
+	if err := ruleManager.ReloadRules(); err != nil {
+		return nil, err
+	}
 
 	// 设置默认缓存TTL为60秒
 	cacheTTL := 60 * time.Second
@@ -175,169 +279,287 @@ func NewEngine(siteName string, config Config) (*Engine, error) {
 
 	// 创建引擎实例
 	e := &Engine{
-		SiteName:       siteName,
-		owaspDetectors: make(map[string]OWASPDetector),
-		coreDetectors:  make([]CoreDetector, 0),
-		ruleManager:    ruleManager,
-		requestCache:   make(map[string]*CheckResult),
-		cacheTTL:       cacheTTL,
+		SiteName:      siteName,
+		coreDetectors: make([]CoreDetector, 0),
+		ruleManager:   ruleManager,
+		requestCache:  make(map[string]*CheckResult),
+		cacheTTL:      cacheTTL,
 	}
 
+	// 解析静态白名单，无法解析的条目由黑白名单检测器记录警告
+	e.whitelist, _ = utils.ParseIPList(config.Whitelist)
+
+	// 已验证爬虫绕过规则检测，爬虫身份由站点共用的爬虫验证器验证
+	e.crawlerBypass = config.CrawlerBypass != nil && config.CrawlerBypass.Enabled
+
 	// 初始化动作处理器
-	e.actionHandler = NewDefaultActionHandler(config.ActionConfig, config.StaticDir, siteName)
+	e.actionHandler = NewDefaultActionHandler(config.ActionConfig, config.StaticDir, siteName, config.RedisClient)
 
 	// 初始化OWASP Top 10检测器
-	e.owaspDetectors["injection"] = detectors.NewInjectionDetector(ruleManager)
-	e.owaspDetectors["xss"] = detectors.NewXSSDetector(ruleManager)
-	e.owaspDetectors["csrf"] = detectors.NewCSRFDetector(ruleManager)
-	e.owaspDetectors["deserialization"] = detectors.NewDeserializationDetector(ruleManager)
-	e.owaspDetectors["sensitive-data"] = detectors.NewSensitiveDataDetector(ruleManager)
+	e.owaspDetectors = newOWASPDetectors(ruleManager)
 
 	// 初始化核心检测器
-	e.coreDetectors = append(e.coreDetectors, detectors.NewGeoIPDetector(config.GeoIPConfig))
-	e.coreDetectors = append(e.coreDetectors, detectors.NewRateLimitDetector(config.RateLimitConfig))
-	e.coreDetectors = append(e.coreDetectors, detectors.NewFileIntegrityDetector(config.StaticDir, config.FileIntegrityConfig))
+	e.coreDetectors = append(e.coreDetectors, detectors.NewGeoIPDetector(config.GeoIPConfig, config.GeoIPResolver))
+	e.rateLimiter = detectors.NewRedisRateLimitDetector(config.RateLimitConfig, config.RedisClient, siteName)
+	e.coreDetectors = append(e.coreDetectors, e.rateLimiter)
 	e.coreDetectors = append(e.coreDetectors, detectors.NewBlacklistDetector(config.RedisClient, siteName, config.Blacklist, config.Whitelist))
 
-	// 启动缓存清理协程
-	go e.cleanCacheLoop()
+	// 启动缓存清理协程，Stop时退出
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+	e.wg.Add(1)
+	go e.cleanCacheLoop(ctx)
 
 	return e, nil
 }
 
+// Stop 停止引擎的缓存清理和频率限制清理协程并等待退出，可以重复调用；
+// 停止后引擎仍可处理请求，只是不再定期清理过期数据
+func (e *Engine) Stop() {
+	e.cancel()
+	e.wg.Wait()
+	e.rateLimiter.Close()
+}
+
+// newOWASPDetectors 使用规则管理器当前的规则创建OWASP Top 10检测器
+func newOWASPDetectors(ruleManager *RuleManager) map[string]OWASPDetector {
+	return map[string]OWASPDetector{
+		"injection":       detectors.NewInjectionDetector(ruleManager),
+		"xss":             detectors.NewXSSDetector(ruleManager),
+		"csrf":            detectors.NewCSRFDetector(ruleManager),
+		"deserialization": detectors.NewDeserializationDetector(ruleManager),
+		"sensitive-data":  detectors.NewSensitiveDataDetector(ruleManager),
+	}
+}
+
 // CheckRequest 检查请求
+// OWASP规则检测只依赖请求内容，结果按请求缓存；频率限制、黑名单等核心检测依赖每次请求的状态，每次都执行。
+// 检测器返回错误或发生panic时忽略该检测器的结果，不阻止请求；白名单中的IP跳过全部检测，频率限制封禁中的IP直接拦截
 func (e *Engine) CheckRequest(req *http.Request) (*CheckResult, error) {
-	// 生成请求缓存键
-	cacheKey := e.generateRequestCacheKey(req)
-
-	// 检查请求缓存
-	if cachedResult := e.getFromCache(cacheKey); cachedResult != nil {
-		return cachedResult, nil
+	// 白名单中的IP（含网段）直接放行，不执行任何检测；客户端IP只采用可信代理转发的X-Forwarded-For，伪造的请求头不能绕过检测
+	if e.whitelist.Contains(utils.ClientIP(req)) {
+		return &CheckResult{Threats: []types.Threat{}, CreatedAt: time.Now(), Allow: true}, nil
 	}
 
-	// 创建结果通道
-	threatsChan := make(chan []types.Threat, len(e.owaspDetectors)+len(e.coreDetectors))
-	errChan := make(chan error, len(e.owaspDetectors)+len(e.coreDetectors))
+	// 频率限制封禁期内的IP直接拦截，不再执行其他检测
+	if threats := e.rateLimiter.CheckBan(req); len(threats) > 0 {
+		return &CheckResult{Threats: threats, CreatedAt: time.Now(), Allow: false}, nil
+	}
 
-	// 并行执行OWASP Top 10检测
-	var wg sync.WaitGroup
+	// 已验证的爬虫跳过OWASP规则检测，但仍执行频率限制等核心检测
+	verifiedCrawler := e.crawlerBypass && isVerifiedCrawler(req)
 
-	// 执行OWASP检测器
 	e.mutex.RLock()
-	owaspDetectors := make(map[string]OWASPDetector)
-	for k, v := range e.owaspDetectors {
-		owaspDetectors[k] = v
+	owaspDetectors := make([]CoreDetector, 0, len(e.owaspDetectors))
+	if !verifiedCrawler {
+		for _, detector := range e.owaspDetectors {
+			owaspDetectors = append(owaspDetectors, detector)
+		}
 	}
 	coreDetectors := make([]CoreDetector, len(e.coreDetectors))
 	copy(coreDetectors, e.coreDetectors)
 	e.mutex.RUnlock()
 
-	// 启动OWASP检测器协程
-	for name, detector := range owaspDetectors {
-		wg.Add(1)
-		go func(det OWASPDetector, detectorName string) {
-			defer wg.Done()
-			threats, err := det.Detect(req)
-			if err != nil {
-				errChan <- err
-				return
-			}
-			threatsChan <- threats
-		}(detector, name)
+	// 检查OWASP检测结果缓存，带请求体的请求检测结果取决于请求体，不使用缓存
+	var owaspResult *CheckResult
+	cacheKey, cacheable := e.generateRequestCacheKey(req)
+	if cacheable {
+		if verifiedCrawler {
+			cacheKey += "|verified-crawler"
+		}
+		owaspResult = e.getFromCache(cacheKey)
+	}
+	if owaspResult == nil {
+		owaspResult = &CheckResult{
+			Threats:   e.runDetectors(req, owaspDetectors),
+			CreatedAt: time.Now(),
+		}
+		owaspResult.Allow = len(owaspResult.Threats) == 0
+		if cacheable {
+			e.addToCache(cacheKey, owaspResult)
+		}
+	}
+
+	result := &CheckResult{
+		Threats:   append(append(make([]types.Threat, 0), owaspResult.Threats...), e.runDetectors(req, coreDetectors)...),
+		CreatedAt: time.Now(),
+		Allow:     true,
+	}
+
+	// 按命中规则的动作决定是否放行：核心检测和内置规则的威胁直接拦截，
+	// 自定义规则按其动作拦截、挑战或只记录（allow或log），只命中只记录规则时由动作处理器标记为log
+	challengeOnly := true
+	for _, threat := range result.Threats {
+		action := ActionBlock
+		if threat.RuleID != "" {
+			action = e.ruleManager.RuleAction(threat.RuleID)
+		}
+		switch action {
+		case ActionAllow, ActionLog:
+		case ActionChallenge:
+			result.Allow = false
+		default:
+			result.Allow = false
+			challengeOnly = false
+		}
 	}
+	result.Challenge = !result.Allow && challengeOnly
 
-	// 启动核心检测器协程
-	for _, detector := range coreDetectors {
+	return result, nil
+}
+
+// runDetectors 并行执行检测器并合并检测到的威胁，出错或panic的检测器只记录日志
+func (e *Engine) runDetectors(req *http.Request, detectors []CoreDetector) []types.Threat {
+	threatsChan := make(chan []types.Threat, len(detectors))
+	errChan := make(chan error, len(detectors))
+
+	var wg sync.WaitGroup
+	for _, detector := range detectors {
 		wg.Add(1)
 		go func(det CoreDetector) {
 			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errChan <- fmt.Errorf("detector %s panicked: %v", det.Name(), r)
+				}
+			}()
 			threats, err := det.Detect(req)
 			if err != nil {
-				errChan <- err
+				errChan <- fmt.Errorf("detector %s: %v", det.Name(), err)
 				return
 			}
 			threatsChan <- threats
 		}(detector)
 	}
+	wg.Wait()
+	close(threatsChan)
+	close(errChan)
 
-	// 等待所有检测器完成
-	go func() {
-		wg.Wait()
-		close(threatsChan)
-		close(errChan)
-	}()
-
-	// 收集检测结果
-	result := &CheckResult{
-		Threats:   make([]types.Threat, 0),
-		CreatedAt: time.Now(),
-		Allow:     true,
-	}
-
-	// 收集威胁
-	for threats := range threatsChan {
-		result.Threats = append(result.Threats, threats...)
+	threats := make([]types.Threat, 0)
+	for detected := range threatsChan {
+		threats = append(threats, detected...)
 	}
-
-	// 收集错误
 	for err := range errChan {
 		if e.logger != nil {
 			e.logger.Error("Detector error: %s", err.Error())
 		}
 	}
-
-	// 如果有威胁，设置Allow为false
-	if len(result.Threats) > 0 {
-		result.Allow = false
-	}
-
-	// 将结果添加到缓存
-	e.addToCache(cacheKey, result)
-
-	return result, nil
+	return threats
 }
 
-// HandleRequest 处理请求
-func (e *Engine) HandleRequest(w http.ResponseWriter, req *http.Request) bool {
+// HandleRequest 检查请求并在检测到威胁时执行拦截动作，返回是否允许请求继续处理及检查结果
+// 检查出错时允许请求通过
+func (e *Engine) HandleRequest(w http.ResponseWriter, req *http.Request) (bool, *CheckResult) {
 	// 检查请求
 	result, err := e.CheckRequest(req)
 	if err != nil {
 		if e.logger != nil {
 			e.logger.Error("Check request error: %s", err.Error())
 		}
-		return true // 出错时默认允许请求通过
+		return true, nil // 出错时默认允许请求通过
 	}
 
 	// 如果检测到威胁，执行相应动作
+	allowed := true
 	if len(result.Threats) > 0 {
-		// 记录安全事件
-		// 执行动作
+		for _, threat := range result.Threats {
+			firewallThreatsTotal.WithLabelValues(e.SiteName, threat.Type).Inc()
+		}
+		// 执行动作，没有动作处理器时默认阻止
+		allowed = false
 		if e.actionHandler != nil {
-			return e.actionHandler.Handle(w, req, result)
+			allowed = e.actionHandler.Handle(w, req, result)
 		}
-		return false // 没有动作处理器，默认阻止
 	}
 
-	return true // 允许请求通过
+	action := result.Action
+	if action == "" {
+		action = ActionAllow
+		if !allowed {
+			action = ActionBlock
+		}
+	}
+	firewallRequestsTotal.WithLabelValues(e.SiteName, action).Inc()
+
+	return allowed, result
 }
 
-// UpdateRules 更新规则
+// ListBans 返回频率限制当前有效的封禁记录
+func (e *Engine) ListBans(ctx context.Context) ([]detectors.Ban, error) {
+	return e.rateLimiter.ListBans(ctx)
+}
+
+// Unban 解除IP的频率限制封禁，返回解除的封禁数
+func (e *Engine) Unban(ctx context.Context, ip string) (int, error) {
+	return e.rateLimiter.Unban(ctx, ip)
+}
+
+// EngineHealth 防火墙引擎的运行状态
+type EngineHealth struct {
+	Detectors     int `json:"detectors"`      // 已加载的检测器数
+	CustomRules   int `json:"custom_rules"`   // 启用的自定义规则数
+	CachedResults int `json:"cached_results"` // 请求缓存中的检测结果数
+}
+
+// Health 返回引擎当前的运行状态
+func (e *Engine) Health() EngineHealth {
+	e.mutex.RLock()
+	health := EngineHealth{Detectors: len(e.owaspDetectors) + len(e.coreDetectors)}
+	e.mutex.RUnlock()
+
+	for _, category := range RuleCategories {
+		health.CustomRules += len(e.ruleManager.GetRulesByCategory(category))
+	}
+
+	e.cacheMutex.RLock()
+	health.CachedResults = len(e.requestCache)
+	e.cacheMutex.RUnlock()
+	return health
+}
+
+// Rules 返回引擎的规则管理器
+func (e *Engine) Rules() *RuleManager {
+	return e.ruleManager
+}
+
+// UpdateRules 从规则目录重新加载规则，加载失败时保留原有规则并返回错误
 func (e *Engine) UpdateRules() error {
 	// 更新规则
 	if err := e.ruleManager.ReloadRules(); err != nil {
 		return err
 	}
 
+	// 检测器在创建时复制规则，重新创建检测器以使用新规则
+	e.mutex.Lock()
+	e.owaspDetectors = newOWASPDetectors(e.ruleManager)
+	e.mutex.Unlock()
+
 	// 清空请求缓存，因为规则更新后，之前的缓存结果可能不再有效
 	e.clearCache()
 
 	return nil
 }
 
-// generateRequestCacheKey 生成请求缓存键
-func (e *Engine) generateRequestCacheKey(req *http.Request) string {
-	// 简单的缓存键生成，实际生产环境中可以使用更复杂的算法
-	return req.Method + "|" + req.URL.String() + "|" + req.RemoteAddr
+// generateRequestCacheKey 生成请求缓存键，由方法、Host、完整URL、客户端IP（不含端口）和请求头摘要组成。
+// 注入和敏感数据检测会检查全部请求头，因此摘要包含所有请求头；带请求体的请求不缓存，第二个返回值为false
+func (e *Engine) generateRequestCacheKey(req *http.Request) (string, bool) {
+	if req.Body != nil && req.Body != http.NoBody {
+		return "", false
+	}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			fmt.Fprintf(h, "%s:%s\n", name, value)
+		}
+	}
+
+	return req.Method + "|" + req.Host + "|" + req.URL.String() + "|" + utils.ClientIP(req) + "|" + hex.EncodeToString(h.Sum(nil)), true
 }
 
 // getFromCache 从缓存获取结果
@@ -375,12 +597,19 @@ func (e *Engine) clearCache() {
 }
 
 // cleanCacheLoop 定期清理过期缓存
-func (e *Engine) cleanCacheLoop() {
+func (e *Engine) cleanCacheLoop(ctx context.Context) {
+	defer e.wg.Done()
+
 	// 每5分钟清理一次过期缓存
 	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
 	for {
-		<-ticker.C
-		e.cleanExpiredCache()
+		select {
+		case <-ticker.C:
+			e.cleanExpiredCache()
+		case <-ctx.Done():
+			return
+		}
 	}
 }
 
@@ -1,7 +1,17 @@
 package firewall
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"prerender-shield/internal/config"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -36,3 +46,197 @@ func TestEngine_UpdateRules(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// mockResolver 模拟DNS解析
+type mockResolver struct {
+	ptr map[string][]string
+	a   map[string][]string
+}
+
+func (r *mockResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if hosts, ok := r.ptr[addr]; ok {
+		return hosts, nil
+	}
+	return nil, errors.New("no PTR record")
+}
+
+func (r *mockResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r.a[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no A record")
+}
+
+func TestEngine_VerifiedCrawlerBypass(t *testing.T) {
+	engine, err := NewEngine("test-site", Config{
+		CrawlerBypass: &config.CrawlerBypassConfig{Enabled: true},
+	})
+	assert.NoError(t, err)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/search?q=1'+OR+1=1", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+		req.RemoteAddr = "66.249.66.1:41234"
+		return req
+	}
+
+	// 站点爬虫验证器标记为已验证的爬虫跳过规则检测
+	result, err := engine.CheckRequest(WithVerifiedCrawler(newRequest()))
+	assert.NoError(t, err)
+	assert.True(t, result.Allow)
+
+	// 只有爬虫UA而未标记的请求仍然被规则拦截
+	result, err = engine.CheckRequest(newRequest())
+	assert.NoError(t, err)
+	assert.False(t, result.Allow)
+}
+
+func TestEngine_CrawlerBypassDisabled(t *testing.T) {
+	engine, err := NewEngine("test-site", Config{})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/search?q=1'+OR+1=1", nil)
+	req.Header.Set("User-Agent", "Googlebot/2.1")
+	req.RemoteAddr = "66.249.66.1:41234"
+
+	result, err := engine.CheckRequest(WithVerifiedCrawler(req))
+	assert.NoError(t, err)
+	assert.False(t, result.Allow)
+}
+
+func TestEngine_WhitelistShortCircuits(t *testing.T) {
+	engine, err := NewEngine("test-site", Config{
+		Blacklist: []string{"10.0.0.0/8"},
+		Whitelist: []string{"10.1.0.0/16"},
+	})
+	assert.NoError(t, err)
+
+	// 白名单IP跳过包括OWASP规则在内的全部检测
+	req := httptest.NewRequest("GET", "http://example.com/?q=%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+	req.RemoteAddr = "10.1.2.3:12345"
+	result, err := engine.CheckRequest(req)
+	assert.NoError(t, err)
+	assert.True(t, result.Allow)
+	assert.Empty(t, result.Threats)
+
+	req = httptest.NewRequest("GET", "http://example.com/", nil)
+	req.RemoteAddr = "10.2.0.1:12345"
+	result, err = engine.CheckRequest(req)
+	assert.NoError(t, err)
+	assert.False(t, result.Allow)
+
+	// 白名单按TCP对端匹配，非白名单客户端伪造X-Forwarded-For和X-Real-IP仍被检测拦截
+	req = httptest.NewRequest("GET", "http://example.com/?q=%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+	req.RemoteAddr = "203.0.113.5:12345"
+	req.Header.Set("X-Forwarded-For", "10.1.2.3")
+	req.Header.Set("X-Real-IP", "10.1.2.3")
+	result, err = engine.CheckRequest(req)
+	assert.NoError(t, err)
+	assert.False(t, result.Allow)
+	assert.NotEmpty(t, result.Threats)
+}
+
+func TestEngine_RequestCacheKey(t *testing.T) {
+	engine, err := NewEngine("test-site", Config{})
+	assert.NoError(t, err)
+
+	newRequest := func(remoteAddr, userAgent string) *http.Request {
+		req := httptest.NewRequest("GET", "http://example.com/page?q=1", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("User-Agent", userAgent)
+		return req
+	}
+	cacheSize := func() int {
+		engine.cacheMutex.RLock()
+		defer engine.cacheMutex.RUnlock()
+		return len(engine.requestCache)
+	}
+
+	// 同一客户端使用不同端口的请求命中同一缓存
+	_, err = engine.CheckRequest(newRequest("10.0.0.1:40001", "Browser/1.0"))
+	assert.NoError(t, err)
+	_, err = engine.CheckRequest(newRequest("10.0.0.1:40002", "Browser/1.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cacheSize())
+
+	// 请求头不同时使用不同的缓存
+	_, err = engine.CheckRequest(newRequest("10.0.0.1:40003", "Browser/2.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cacheSize())
+
+	// 带请求体的请求不缓存，恶意请求体不会复用无害请求的结果
+	benign := httptest.NewRequest("POST", "http://example.com/page?q=1", strings.NewReader("name=alice"))
+	benign.RemoteAddr = "10.0.0.1:40004"
+	_, err = engine.CheckRequest(benign)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cacheSize())
+}
+
+// cleanupGoroutines 返回防火墙引擎缓存清理和频率限制清理协程的数量
+func cleanupGoroutines() int {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	stacks := string(buf)
+	return strings.Count(stacks, "firewall.(*Engine).cleanCacheLoop") +
+		strings.Count(stacks, "detectors.(*RateLimitDetector).cleanupLoop")
+}
+
+func TestEngineManager_RemoveSiteStopsCleanup(t *testing.T) {
+	before := cleanupGoroutines()
+
+	manager := NewEngineManager()
+	assert.NoError(t, manager.AddSite("site-a", Config{}))
+	assert.NoError(t, manager.AddSite("site-b", Config{}))
+	assert.Eventually(t, func() bool { return cleanupGoroutines() == before+4 }, time.Second, 10*time.Millisecond)
+
+	// 移除站点后其引擎的清理协程退出
+	manager.RemoveSite("site-a")
+	assert.Eventually(t, func() bool { return cleanupGoroutines() == before+2 }, time.Second, 10*time.Millisecond)
+
+	// 替换引擎时停止旧引擎
+	assert.NoError(t, manager.ReplaceSite("site-b", "site-c", Config{}))
+	assert.Eventually(t, func() bool { return cleanupGoroutines() == before+2 }, time.Second, 10*time.Millisecond)
+
+	manager.StopAll()
+	assert.Eventually(t, func() bool { return cleanupGoroutines() == before }, time.Second, 10*time.Millisecond)
+	assert.Empty(t, manager.ListSites())
+}
+
+func TestCrawlerVerifier_EvictsExpiredEntries(t *testing.T) {
+	verifier := NewCrawlerVerifier(nil, &mockResolver{})
+	verifier.cacheTTL = 50 * time.Millisecond
+
+	newRequest := func(remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("User-Agent", "Googlebot/2.1")
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+	cacheSize := func() int {
+		verifier.mutex.RLock()
+		defer verifier.mutex.RUnlock()
+		return len(verifier.cache)
+	}
+
+	verifier.Verify(newRequest("203.0.113.1:1234"))
+	verifier.Verify(newRequest("203.0.113.2:1234"))
+	assert.Equal(t, 2, cacheSize())
+
+	// 过期后再次写入时清理所有过期结果
+	time.Sleep(60 * time.Millisecond)
+	verifier.Verify(newRequest("203.0.113.3:1234"))
+	assert.Equal(t, 1, cacheSize())
+
+	// 缓存已满且没有过期结果时不再缓存新的IP
+	verifier.cacheTTL = time.Hour
+	for i := 0; i < maxVerifyCacheEntries+10; i++ {
+		verifier.Verify(newRequest(fmt.Sprintf("10.%d.%d.%d:1234", i>>16&255, i>>8&255, i&255)))
+	}
+	assert.Equal(t, maxVerifyCacheEntries, cacheSize())
+}
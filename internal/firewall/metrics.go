@@ -0,0 +1,28 @@
+package firewall
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 防火墙引擎监控指标
+var (
+	firewallRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "firewall_requests_total",
+			Help: "Total number of requests checked by the firewall engine, by resulting action",
+		},
+		[]string{"site", "action"},
+	)
+
+	firewallThreatsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "firewall_threats_total",
+			Help: "Total number of threats detected by the firewall engine, by threat type",
+		},
+		[]string{"site", "type"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(firewallRequestsTotal, firewallThreatsTotal)
+}
@@ -0,0 +1,340 @@
+package firewall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"prerender-shield/internal/firewall/types"
+)
+
+// RuleCategories 支持自定义规则的检测分类，与OWASP检测器一一对应
+var RuleCategories = []string{"injection", "xss", "csrf", "deserialization", "sensitive-data"}
+
+// ruleSeverities 规则允许的严重级别
+var ruleSeverities = map[string]bool{"low": true, "medium": true, "high": true, "critical": true}
+
+// ruleActions 规则允许的动作，log与allow相同，命中后放行并记录
+var ruleActions = map[string]bool{ActionAllow: true, ActionLog: true, ActionBlock: true, ActionChallenge: true}
+
+// ErrRuleNotFound 规则不存在
+var ErrRuleNotFound = errors.New("rule not found")
+
+// ErrRuleExists 规则ID已存在
+var ErrRuleExists = errors.New("rule already exists")
+
+// ErrInvalidRule 规则校验失败
+var ErrInvalidRule = errors.New("invalid rule")
+
+// ErrRulesPathNotConfigured 站点未配置规则目录，无法保存自定义规则
+var ErrRulesPathNotConfigured = errors.New("firewall rules_path is not configured")
+
+// RuleManager 规则管理器
+// 规则按分类保存在规则目录下的 {分类}.json 文件中，已存在 {分类}.yaml 或 {分类}.yml 时使用YAML文件。
+// 规则文件中的字段为id、name、category、pattern、severity、action和enabled，category为空时使用文件对应的分类
+type RuleManager struct {
+	mutex     sync.RWMutex
+	rulesPath string
+	rules     map[string][]types.Rule
+}
+
+// NewRuleManager 创建新的规则管理器，rulesPath为空时规则只保存在内存中且不能修改
+func NewRuleManager(rulesPath string) *RuleManager {
+	return &RuleManager{
+		rulesPath: rulesPath,
+		rules:     make(map[string][]types.Rule),
+	}
+}
+
+// RulesPath 返回规则目录
+func (rm *RuleManager) RulesPath() string {
+	return rm.rulesPath
+}
+
+// GetRulesByCategory 根据分类获取启用的规则，供检测器使用
+func (rm *RuleManager) GetRulesByCategory(category string) []types.Rule {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	var rules []types.Rule
+	for _, rule := range rm.rules[category] {
+		if rule.IsEnabled() {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ListRules 返回指定分类的规则（包括停用的规则），category为空时按分类顺序返回全部规则
+func (rm *RuleManager) ListRules(category string) []types.Rule {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	rules := make([]types.Rule, 0)
+	for _, c := range RuleCategories {
+		if category == "" || category == c {
+			rules = append(rules, rm.rules[c]...)
+		}
+	}
+	return rules
+}
+
+// RuleAction 返回规则命中时的动作，规则不存在（如检测器的内置规则）时为block
+func (rm *RuleManager) RuleAction(id string) string {
+	rm.mutex.RLock()
+	defer rm.mutex.RUnlock()
+	if category, index, exists := rm.findRule(id); exists {
+		return rm.rules[category][index].Action
+	}
+	return ActionBlock
+}
+
+// ReloadRules 重新加载规则目录下各分类的规则文件，文件不存在的分类没有自定义规则。
+// 规则文件格式错误、规则校验失败（如正则表达式无法编译）或规则ID重复时返回包含文件和规则位置的错误，并保留原有规则
+func (rm *RuleManager) ReloadRules() error {
+	rules := make(map[string][]types.Rule)
+	if rm.rulesPath != "" {
+		if info, err := os.Stat(rm.rulesPath); err == nil && !info.IsDir() {
+			return fmt.Errorf("rules path %s is not a directory", rm.rulesPath)
+		}
+		seen := make(map[string]string)
+		for _, category := range RuleCategories {
+			path := rm.ruleFilePath(category)
+			loaded, err := readRuleFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to load rule file %s: %v", path, err)
+			}
+			for i, rule := range loaded {
+				if rule.Category == "" {
+					rule.Category = category
+				}
+				if rule.Category != category {
+					return fmt.Errorf("rule file %s: rule #%d (%s) has category %q, expected %q", path, i+1, rule.ID, rule.Category, category)
+				}
+				if rule.ID == "" {
+					return fmt.Errorf("rule file %s: rule #%d has no id", path, i+1)
+				}
+				if err := validateRule(&rule); err != nil {
+					return fmt.Errorf("rule file %s: rule #%d (%s): %v", path, i+1, rule.ID, err)
+				}
+				if other, exists := seen[rule.ID]; exists {
+					return fmt.Errorf("rule file %s: rule #%d has duplicate id %s (also in %s)", path, i+1, rule.ID, other)
+				}
+				seen[rule.ID] = path
+				loaded[i] = rule
+			}
+			rules[category] = loaded
+		}
+	}
+
+	rm.mutex.Lock()
+	rm.rules = rules
+	rm.mutex.Unlock()
+	return nil
+}
+
+// AddRule 校验并保存新规则，ID为空时自动生成
+func (rm *RuleManager) AddRule(rule types.Rule) (types.Rule, error) {
+	if err := validateRule(&rule); err != nil {
+		return rule, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	if rule.ID == "" {
+		rule.ID = rule.Category + "-" + uuid.New().String()[:8]
+	}
+	if _, _, exists := rm.findRule(rule.ID); exists {
+		return rule, fmt.Errorf("%w: %s", ErrRuleExists, rule.ID)
+	}
+
+	rules := append(append([]types.Rule(nil), rm.rules[rule.Category]...), rule)
+	if err := rm.saveCategory(rule.Category, rules); err != nil {
+		return rule, err
+	}
+	rm.rules[rule.Category] = rules
+	return rule, nil
+}
+
+// UpdateRule 校验并替换指定ID的规则，分类改变时规则移动到新分类的文件
+func (rm *RuleManager) UpdateRule(id string, rule types.Rule) (types.Rule, error) {
+	rule.ID = id
+	if err := validateRule(&rule); err != nil {
+		return rule, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	oldCategory, index, exists := rm.findRule(id)
+	if !exists {
+		return rule, ErrRuleNotFound
+	}
+
+	if oldCategory == rule.Category {
+		rules := append([]types.Rule(nil), rm.rules[oldCategory]...)
+		rules[index] = rule
+		if err := rm.saveCategory(oldCategory, rules); err != nil {
+			return rule, err
+		}
+		rm.rules[oldCategory] = rules
+		return rule, nil
+	}
+
+	// 先写入新分类再从旧分类删除，中途失败时最多出现一条重复规则而不会丢失
+	moved := append(append([]types.Rule(nil), rm.rules[rule.Category]...), rule)
+	if err := rm.saveCategory(rule.Category, moved); err != nil {
+		return rule, err
+	}
+	rm.rules[rule.Category] = moved
+	remaining := removeRuleAt(rm.rules[oldCategory], index)
+	if err := rm.saveCategory(oldCategory, remaining); err != nil {
+		return rule, err
+	}
+	rm.rules[oldCategory] = remaining
+	return rule, nil
+}
+
+// DeleteRule 删除指定ID的规则
+func (rm *RuleManager) DeleteRule(id string) error {
+	rm.mutex.Lock()
+	defer rm.mutex.Unlock()
+	category, index, exists := rm.findRule(id)
+	if !exists {
+		return ErrRuleNotFound
+	}
+
+	remaining := removeRuleAt(rm.rules[category], index)
+	if err := rm.saveCategory(category, remaining); err != nil {
+		return err
+	}
+	rm.rules[category] = remaining
+	return nil
+}
+
+// findRule 查找规则所在的分类和下标，调用方需持有锁
+func (rm *RuleManager) findRule(id string) (string, int, bool) {
+	for category, rules := range rm.rules {
+		for i, rule := range rules {
+			if rule.ID == id {
+				return category, i, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// ruleFilePath 返回分类规则文件的路径，已有YAML文件时沿用YAML格式
+func (rm *RuleManager) ruleFilePath(category string) string {
+	for _, ext := range []string{".yaml", ".yml"} {
+		path := filepath.Join(rm.rulesPath, category+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(rm.rulesPath, category+".json")
+}
+
+// saveCategory 将分类的规则写入规则文件，先写临时文件再改名，避免写入中断导致文件损坏
+func (rm *RuleManager) saveCategory(category string, rules []types.Rule) error {
+	if rm.rulesPath == "" {
+		return ErrRulesPathNotConfigured
+	}
+	if err := os.MkdirAll(rm.rulesPath, 0755); err != nil {
+		return err
+	}
+
+	path := rm.ruleFilePath(category)
+	if rules == nil {
+		rules = []types.Rule{}
+	}
+	var data []byte
+	var err error
+	if strings.HasSuffix(path, ".json") {
+		data, err = json.MarshalIndent(rules, "", "  ")
+	} else {
+		data, err = yaml.Marshal(rules)
+	}
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// readRuleFile 读取规则文件，文件不存在时返回空列表
+func readRuleFile(path string) ([]types.Rule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []types.Rule
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, &rules)
+	} else {
+		err = yaml.Unmarshal(data, &rules)
+	}
+	return rules, err
+}
+
+// validateRule 校验规则的分类、名称、严重级别、动作和正则表达式，严重级别为空时默认为medium，动作为空时默认为block
+func validateRule(rule *types.Rule) error {
+	if !isRuleCategory(rule.Category) {
+		return fmt.Errorf("category must be one of %s", strings.Join(RuleCategories, ", "))
+	}
+	if strings.TrimSpace(rule.Name) == "" {
+		return errors.New("name is required")
+	}
+	if rule.Pattern == "" {
+		return errors.New("pattern is required")
+	}
+	// 部分检测器将模式转为大写或小写后匹配，三种形式都必须能编译
+	for _, pattern := range []string{rule.Pattern, strings.ToUpper(rule.Pattern), strings.ToLower(rule.Pattern)} {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+	if rule.Severity == "" {
+		rule.Severity = "medium"
+	}
+	if !ruleSeverities[rule.Severity] {
+		return errors.New("severity must be one of low, medium, high, critical")
+	}
+	if rule.Action == "" {
+		rule.Action = ActionBlock
+	}
+	if !ruleActions[rule.Action] {
+		return errors.New("action must be one of allow, log, block, challenge")
+	}
+	return nil
+}
+
+// isRuleCategory 判断是否为支持的规则分类
+func isRuleCategory(category string) bool {
+	for _, c := range RuleCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// removeRuleAt 返回删除指定下标后的规则列表副本
+func removeRuleAt(rules []types.Rule, index int) []types.Rule {
+	remaining := make([]types.Rule, 0, len(rules)-1)
+	remaining = append(remaining, rules[:index]...)
+	return append(remaining, rules[index+1:]...)
+}
@@ -0,0 +1,187 @@
+package firewall
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/firewall/types"
+)
+
+func TestRuleManager_CRUD(t *testing.T) {
+	rulesPath := t.TempDir()
+	rm := NewRuleManager(rulesPath)
+	assert.NoError(t, rm.ReloadRules())
+	assert.Empty(t, rm.ListRules(""))
+
+	// 无法编译的正则表达式在保存时被拒绝
+	_, err := rm.AddRule(types.Rule{Name: "Broken", Category: "xss", Pattern: "(unclosed"})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+	_, err = rm.AddRule(types.Rule{Name: "Unknown", Category: "unknown", Pattern: "x"})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+
+	rule, err := rm.AddRule(types.Rule{ID: "xss-custom", Name: "Custom XSS", Category: "xss", Pattern: "onmouseover="})
+	assert.NoError(t, err)
+	assert.Equal(t, "medium", rule.Severity)
+	_, err = rm.AddRule(rule)
+	assert.ErrorIs(t, err, ErrRuleExists)
+	assert.FileExists(t, filepath.Join(rulesPath, "xss.json"))
+
+	// 修改分类时规则移动到新分类的文件
+	rule.Category = "injection"
+	rule.Severity = "high"
+	_, err = rm.UpdateRule(rule.ID, rule)
+	assert.NoError(t, err)
+	assert.Empty(t, rm.ListRules("xss"))
+	assert.Len(t, rm.ListRules("injection"), 1)
+
+	// 重新加载后规则从文件恢复
+	reloaded := NewRuleManager(rulesPath)
+	assert.NoError(t, reloaded.ReloadRules())
+	assert.Equal(t, []types.Rule{rule}, reloaded.ListRules(""))
+
+	assert.NoError(t, rm.DeleteRule(rule.ID))
+	assert.ErrorIs(t, rm.DeleteRule(rule.ID), ErrRuleNotFound)
+	_, err = rm.UpdateRule("missing", rule)
+	assert.ErrorIs(t, err, ErrRuleNotFound)
+
+	_, err = NewRuleManager("").AddRule(types.Rule{Name: "No path", Category: "xss", Pattern: "x"})
+	assert.ErrorIs(t, err, ErrRulesPathNotConfigured)
+}
+
+func TestRuleManager_LoadsYAML(t *testing.T) {
+	rulesPath := t.TempDir()
+	yamlRules := "- id: sd-1\n  name: Secret\n  category: sensitive-data\n  pattern: secret\n  severity: low\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(rulesPath, "sensitive-data.yaml"), []byte(yamlRules), 0644))
+
+	rm := NewRuleManager(rulesPath)
+	assert.NoError(t, rm.ReloadRules())
+	assert.Len(t, rm.ListRules("sensitive-data"), 1)
+
+	// 已有YAML文件的分类继续写入YAML文件
+	_, err := rm.AddRule(types.Rule{Name: "Token", Category: "sensitive-data", Pattern: "token"})
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(rulesPath, "sensitive-data.json"))
+	reloaded := NewRuleManager(rulesPath)
+	assert.NoError(t, reloaded.ReloadRules())
+	assert.Len(t, reloaded.ListRules("sensitive-data"), 2)
+}
+
+func TestEngineManager_ReloadRules(t *testing.T) {
+	rulesPath := t.TempDir()
+	em := NewEngineManager()
+	assert.NoError(t, em.AddSite("site-a", Config{RulesPath: rulesPath}))
+	assert.NoError(t, em.AddSite("site-b", Config{RulesPath: rulesPath}))
+	engineA, _ := em.GetEngine("site-a")
+	engineB, _ := em.GetEngine("site-b")
+
+	// 先检测一次使结果进入请求缓存
+	req := httptest.NewRequest("GET", "http://example.com/?q=forbidden-word", nil)
+	result, err := engineB.CheckRequest(req)
+	assert.NoError(t, err)
+	assert.True(t, result.Allow)
+
+	_, err = engineA.Rules().AddRule(types.Rule{Name: "Forbidden", Category: "sensitive-data", Pattern: "forbidden-word", Severity: "high"})
+	assert.NoError(t, err)
+	assert.NoError(t, em.ReloadRules(rulesPath))
+
+	// 共用规则目录的站点清空缓存并使用新规则
+	result, err = engineB.CheckRequest(req)
+	assert.NoError(t, err)
+	assert.False(t, result.Allow)
+}
+
+func TestRuleManager_ReloadErrors(t *testing.T) {
+	cases := map[string]string{
+		"syntax":     "- id: x-1\n  name: [unclosed\n",
+		"regex":      "- id: x-1\n  name: Broken\n  pattern: \"(unclosed\"\n",
+		"category":   "- id: x-1\n  name: Wrong\n  category: injection\n  pattern: x\n",
+		"missing id": "- name: Anonymous\n  pattern: x\n",
+		"action":     "- id: x-1\n  name: Drop\n  pattern: x\n  action: drop\n",
+	}
+	for name, content := range cases {
+		rulesPath := t.TempDir()
+		path := filepath.Join(rulesPath, "xss.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		rm := NewRuleManager(rulesPath)
+		err := rm.ReloadRules()
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), path, "%s: error should name the rule file", name)
+		}
+		assert.Empty(t, rm.ListRules(""), name)
+
+		_, err = NewEngine("site", Config{RulesPath: rulesPath})
+		assert.Error(t, err, "%s: engine should refuse malformed rule files", name)
+	}
+}
+
+func TestEngine_RuleActionAndEnabled(t *testing.T) {
+	rulesPath := t.TempDir()
+	yamlRules := `- id: xss-log
+  name: Suspicious marker
+  pattern: watch-me
+  action: allow
+- id: xss-challenge
+  name: Challenged marker
+  pattern: verify-me
+  action: challenge
+- id: xss-off
+  name: Disabled rule
+  pattern: ignore-me
+  enabled: false
+- id: xss-block
+  name: Blocked marker
+  pattern: block-me
+- id: xss-log-only
+  name: Logged marker
+  pattern: note-me
+  action: log
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(rulesPath, "xss.yaml"), []byte(yamlRules), 0644))
+
+	engine, err := NewEngine("site", Config{RulesPath: rulesPath})
+	assert.NoError(t, err)
+	assert.Len(t, engine.Rules().ListRules("xss"), 5)
+	assert.Len(t, engine.Rules().GetRulesByCategory("xss"), 4, "disabled rules are not used by detectors")
+	assert.Equal(t, "xss", engine.Rules().ListRules("xss")[0].Category, "category defaults to the file's category")
+
+	check := func(query string) *CheckResult {
+		result, err := engine.CheckRequest(httptest.NewRequest("GET", "http://example.com/?q="+query, nil))
+		assert.NoError(t, err)
+		return result
+	}
+
+	// 只命中allow规则时放行，动作处理器标记为只记录
+	result := check("watch-me")
+	assert.True(t, result.Allow)
+	if assert.Len(t, result.Threats, 1) {
+		assert.Equal(t, "xss-log", result.Threats[0].RuleID)
+	}
+	recorder := httptest.NewRecorder()
+	allowed, handled := engine.HandleRequest(recorder, httptest.NewRequest("GET", "http://example.com/?q=watch-me", nil))
+	assert.True(t, allowed)
+	assert.Equal(t, ActionLog, handled.Action)
+	allowed, handled = engine.HandleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/?q=note-me", nil))
+	assert.True(t, allowed, "log rules behave like allow rules")
+	assert.Equal(t, ActionLog, handled.Action)
+
+	assert.Empty(t, check("ignore-me").Threats)
+	assert.False(t, check("block-me").Allow)
+	assert.False(t, check("block-me").Challenge)
+	assert.False(t, check("watch-me-block-me").Allow, "a blocking rule wins over allow rules")
+
+	// challenge规则在站点默认动作为block时也返回挑战页面
+	result = check("verify-me")
+	assert.False(t, result.Allow)
+	assert.True(t, result.Challenge)
+	recorder = httptest.NewRecorder()
+	allowed, handled = engine.HandleRequest(recorder, httptest.NewRequest("GET", "http://example.com/?q=verify-me", nil))
+	assert.False(t, allowed)
+	assert.Equal(t, ActionChallenge, handled.Action)
+	assert.Contains(t, recorder.Body.String(), challengeCookieName)
+	assert.False(t, check("verify-me-block-me").Challenge, "a blocking rule wins over challenge rules")
+}
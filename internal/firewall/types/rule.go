@@ -2,9 +2,18 @@ package types
 
 // Rule 规则
 type Rule struct {
-	ID       string
-	Name     string
-	Category string
-	Pattern  string
-	Severity string
-}
\ No newline at end of file
+	ID       string `json:"id" yaml:"id"`
+	Name     string `json:"name" yaml:"name"`
+	Category string `json:"category" yaml:"category"`
+	Pattern  string `json:"pattern" yaml:"pattern"`
+	Severity string `json:"severity" yaml:"severity"`
+	// 命中规则时的动作：block拦截（默认），challenge返回JS挑战，allow只记录不拦截
+	Action string `json:"action" yaml:"action"`
+	// 是否启用规则，未设置时视为启用
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// IsEnabled 判断规则是否启用，未设置时默认启用
+func (r Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
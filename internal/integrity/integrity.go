@@ -0,0 +1,347 @@
+package integrity
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/logging"
+)
+
+// 文件变化类型
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeDeleted  = "deleted"
+)
+
+// defaultHashAlgorithm 未配置或配置了不支持的哈希算法时使用的算法
+const defaultHashAlgorithm = "sha256"
+
+// maxEventsPerSite 每个站点保留的最近篡改事件数
+const maxEventsPerSite = 1000
+
+// Event 文件篡改事件，记录基线之后新增、修改或删除的文件
+type Event struct {
+	SiteID     string    `json:"site_id"`
+	Path       string    `json:"path"`   // 相对站点静态目录的路径，使用/分隔
+	Change     string    `json:"change"` // added、modified或deleted
+	OldHash    string    `json:"old_hash,omitempty"`
+	NewHash    string    `json:"new_hash,omitempty"`
+	Algorithm  string    `json:"algorithm"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// Baseline 站点静态目录的文件哈希基线
+type Baseline struct {
+	SiteID    string            `json:"site_id"`
+	Algorithm string            `json:"algorithm"`
+	BuiltAt   time.Time         `json:"built_at"`
+	CheckedAt time.Time         `json:"checked_at,omitempty"` // 最近一次校验时间
+	Files     map[string]string `json:"files"`                // 相对路径 -> 哈希值
+}
+
+// Status 站点防篡改监控状态
+type Status struct {
+	SiteID    string    `json:"site_id"`
+	Algorithm string    `json:"algorithm"`
+	BuiltAt   time.Time `json:"built_at"`
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	Files     int       `json:"files"`
+}
+
+// Manager 网页防篡改管理器，为站点静态目录建立哈希基线并定期校验
+// 基线和篡改事件保存在dataDir/integrity/下，每个站点一个基线文件和一个事件文件
+type Manager struct {
+	staticDir string
+	dir       string
+	mutex     sync.Mutex
+}
+
+// NewManager 创建网页防篡改管理器，校验staticDir/<站点ID>/下的文件
+func NewManager(staticDir, dataDir string) *Manager {
+	return &Manager{
+		staticDir: staticDir,
+		dir:       filepath.Join(dataDir, "integrity"),
+	}
+}
+
+// BuildBaseline 重新计算站点静态目录的哈希基线，用于合法发布后避免误报
+func (m *Manager) BuildBaseline(siteID string, cfg config.FileIntegrityConfig) (*Baseline, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	baseline, err := m.scan(siteID, hashAlgorithm(cfg.HashAlgorithm))
+	if err != nil {
+		return nil, err
+	}
+	if err := m.saveBaseline(baseline); err != nil {
+		return nil, err
+	}
+	return baseline, nil
+}
+
+// Rebuild 站点启用了防篡改时重建基线，供静态文件发布和修改后调用
+func (m *Manager) Rebuild(site config.SiteConfig) {
+	if m == nil || !site.FileIntegrityConfig.Enabled {
+		return
+	}
+	if _, err := m.BuildBaseline(site.ID, site.FileIntegrityConfig); err != nil {
+		logging.DefaultLogger.Error("Failed to rebuild integrity baseline for site %s: %v", site.ID, err)
+	}
+}
+
+// Verify 将站点静态目录与基线比较，记录新增、修改和删除的文件并返回本次发现的事件
+// 之后以当前状态作为新的基线，每次变化只报告一次；没有基线或哈希算法变化时只建立基线
+func (m *Manager) Verify(siteID string, cfg config.FileIntegrityConfig) ([]Event, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	algorithm := hashAlgorithm(cfg.HashAlgorithm)
+	current, err := m.scan(siteID, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline, err := m.loadBaseline(siteID)
+	if err != nil || baseline.Algorithm != algorithm {
+		return nil, m.saveBaseline(current)
+	}
+
+	events := diff(siteID, algorithm, baseline.Files, current.Files, current.CheckedAt)
+	if len(events) > 0 {
+		for _, event := range events {
+			logging.DefaultLogger.Warn("File integrity violation on site %s: %s %s", siteID, event.Path, event.Change)
+		}
+		if err := m.appendEvents(siteID, events); err != nil {
+			return events, err
+		}
+		baseline.Files = current.Files
+	}
+	baseline.CheckedAt = current.CheckedAt
+	return events, m.saveBaseline(baseline)
+}
+
+// Events 返回站点最近的篡改事件，按发现时间从新到旧排序
+func (m *Manager) Events(siteID string) ([]Event, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	events, err := m.loadEvents(siteID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].DetectedAt.After(events[j].DetectedAt) })
+	return events, nil
+}
+
+// GetStatus 返回站点的基线状态，站点尚未建立基线时第二个返回值为false
+func (m *Manager) GetStatus(siteID string) (Status, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	baseline, err := m.loadBaseline(siteID)
+	if err != nil {
+		return Status{}, false
+	}
+	return Status{
+		SiteID:    siteID,
+		Algorithm: baseline.Algorithm,
+		BuiltAt:   baseline.BuiltAt,
+		CheckedAt: baseline.CheckedAt,
+		Files:     len(baseline.Files),
+	}, true
+}
+
+// RemoveSite 删除站点的基线和篡改事件
+func (m *Manager) RemoveSite(siteID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	os.Remove(m.baselinePath(siteID))
+	os.Remove(m.eventsPath(siteID))
+}
+
+// scan 计算站点静态目录下所有文件的哈希值，目录不存在时返回空基线
+func (m *Manager) scan(siteID, algorithm string) (*Baseline, error) {
+	now := time.Now()
+	baseline := &Baseline{
+		SiteID:    siteID,
+		Algorithm: algorithm,
+		BuiltAt:   now,
+		CheckedAt: now,
+		Files:     make(map[string]string),
+	}
+
+	root := filepath.Join(m.staticDir, siteID)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		sum, err := HashFile(path, algorithm)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		baseline.Files[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan static directory of site %s: %v", siteID, err)
+	}
+	return baseline, nil
+}
+
+// diff 比较基线和当前文件哈希，返回按路径排序的变化事件
+func diff(siteID, algorithm string, baseline, current map[string]string, detectedAt time.Time) []Event {
+	var events []Event
+	for path, newHash := range current {
+		oldHash, exists := baseline[path]
+		switch {
+		case !exists:
+			events = append(events, Event{Path: path, Change: ChangeAdded, NewHash: newHash})
+		case oldHash != newHash:
+			events = append(events, Event{Path: path, Change: ChangeModified, OldHash: oldHash, NewHash: newHash})
+		}
+	}
+	for path, oldHash := range baseline {
+		if _, exists := current[path]; !exists {
+			events = append(events, Event{Path: path, Change: ChangeDeleted, OldHash: oldHash})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	for i := range events {
+		events[i].SiteID = siteID
+		events[i].Algorithm = algorithm
+		events[i].DetectedAt = detectedAt
+	}
+	return events
+}
+
+// HashFile 使用指定算法计算文件哈希值，支持md5、sha1、sha256和sha512，其他值使用sha256
+func HashFile(path, algorithm string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var h hash.Hash
+	switch algorithm {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashAlgorithm 返回实际使用的哈希算法名称
+func hashAlgorithm(algorithm string) string {
+	switch algorithm {
+	case "md5", "sha1", "sha256", "sha512":
+		return algorithm
+	default:
+		return defaultHashAlgorithm
+	}
+}
+
+// baselinePath 返回站点基线文件路径
+func (m *Manager) baselinePath(siteID string) string {
+	return filepath.Join(m.dir, siteID+".baseline.json")
+}
+
+// eventsPath 返回站点篡改事件文件路径
+func (m *Manager) eventsPath(siteID string) string {
+	return filepath.Join(m.dir, siteID+".events.json")
+}
+
+// loadBaseline 读取站点基线
+func (m *Manager) loadBaseline(siteID string) (*Baseline, error) {
+	var baseline Baseline
+	if err := readJSON(m.baselinePath(siteID), &baseline); err != nil {
+		return nil, err
+	}
+	return &baseline, nil
+}
+
+// saveBaseline 保存站点基线
+func (m *Manager) saveBaseline(baseline *Baseline) error {
+	return writeJSON(m.baselinePath(baseline.SiteID), baseline)
+}
+
+// loadEvents 读取站点篡改事件，事件文件不存在时返回空列表
+func (m *Manager) loadEvents(siteID string) ([]Event, error) {
+	var events []Event
+	if err := readJSON(m.eventsPath(siteID), &events); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return events, nil
+}
+
+// appendEvents 追加篡改事件，只保留最近maxEventsPerSite条
+func (m *Manager) appendEvents(siteID string, events []Event) error {
+	existing, err := m.loadEvents(siteID)
+	if err != nil {
+		return err
+	}
+	existing = append(existing, events...)
+	if len(existing) > maxEventsPerSite {
+		existing = existing[len(existing)-maxEventsPerSite:]
+	}
+	return writeJSON(m.eventsPath(siteID), existing)
+}
+
+// readJSON 读取JSON文件
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON 先写入临时文件再改名，避免进程中断时留下不完整的文件
+func writeJSON(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,99 @@
+package integrity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"prerender-shield/internal/config"
+)
+
+// writeFile 在站点静态目录下写入文件
+func writeFile(t *testing.T, staticDir, siteID, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(staticDir, siteID, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+// TestVerifyReportsChanges 测试校验发现新增、修改和删除的文件，并且每次变化只报告一次
+func TestVerifyReportsChanges(t *testing.T) {
+	staticDir := t.TempDir()
+	m := NewManager(staticDir, t.TempDir())
+	cfg := config.FileIntegrityConfig{Enabled: true, HashAlgorithm: "md5"}
+
+	writeFile(t, staticDir, "site1", "index.html", "<html>home</html>")
+	writeFile(t, staticDir, "site1", "js/app.js", "console.log(1)")
+	writeFile(t, staticDir, "site1", "about.html", "<html>about</html>")
+
+	// 没有基线时只建立基线
+	events, err := m.Verify("site1", cfg)
+	if err != nil || len(events) != 0 {
+		t.Fatalf("expected first verify to build the baseline, got %v, %v", events, err)
+	}
+	if status, exists := m.GetStatus("site1"); !exists || status.Files != 3 || status.Algorithm != "md5" {
+		t.Fatalf("unexpected baseline status: %+v, %v", status, exists)
+	}
+
+	writeFile(t, staticDir, "site1", "index.html", "<html>defaced</html>")
+	writeFile(t, staticDir, "site1", "shell.php", "<?php")
+	os.Remove(filepath.Join(staticDir, "site1", "about.html"))
+
+	events, err = m.Verify("site1", cfg)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	changes := make(map[string]string)
+	for _, event := range events {
+		changes[event.Path] = event.Change
+	}
+	expected := map[string]string{"about.html": ChangeDeleted, "index.html": ChangeModified, "shell.php": ChangeAdded}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+	for path, change := range expected {
+		if changes[path] != change {
+			t.Errorf("expected %s to be %s, got %q", path, change, changes[path])
+		}
+	}
+
+	// 已报告的变化不再重复报告，事件保留在事件列表中
+	if events, _ := m.Verify("site1", cfg); len(events) != 0 {
+		t.Errorf("expected no new events, got %v", events)
+	}
+	if recorded, err := m.Events("site1"); err != nil || len(recorded) != 3 {
+		t.Errorf("expected 3 recorded events, got %d, %v", len(recorded), err)
+	}
+}
+
+// TestRebuildSuppressesDeployChanges 测试发布后重建基线，合法变更不被记为篡改；未启用防篡改的站点不建立基线
+func TestRebuildSuppressesDeployChanges(t *testing.T) {
+	staticDir := t.TempDir()
+	m := NewManager(staticDir, t.TempDir())
+	site := config.SiteConfig{ID: "site1", FileIntegrityConfig: config.FileIntegrityConfig{Enabled: true}}
+
+	writeFile(t, staticDir, "site1", "index.html", "v1")
+	m.Rebuild(site)
+	writeFile(t, staticDir, "site1", "index.html", "v2")
+	writeFile(t, staticDir, "site1", "new.html", "new")
+	m.Rebuild(site)
+
+	if events, err := m.Verify("site1", site.FileIntegrityConfig); err != nil || len(events) != 0 {
+		t.Errorf("expected no events after rebuilding the baseline, got %v, %v", events, err)
+	}
+
+	disabled := config.SiteConfig{ID: "site2"}
+	m.Rebuild(disabled)
+	if _, exists := m.GetStatus("site2"); exists {
+		t.Error("expected no baseline for a site without file integrity enabled")
+	}
+
+	m.RemoveSite("site1")
+	if _, exists := m.GetStatus("site1"); exists {
+		t.Error("expected baseline to be removed with the site")
+	}
+}
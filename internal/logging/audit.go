@@ -0,0 +1,289 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAuditRetentionDays 未配置时审计日志的保留天数
+	defaultAuditRetentionDays = 90
+	// auditCompactInterval 清理审计日志文件中过期日志的间隔
+	auditCompactInterval = 24 * time.Hour
+)
+
+// AuditFilter 审计日志查询条件，为空的条件不限制
+type AuditFilter struct {
+	Action   string    // 操作类型，如config_rollback
+	Resource string    // 操作的资源
+	Actor    string    // 操作用户
+	Result   string    // 操作结果：success、failure
+	Keyword  string    // 在消息中搜索，不区分大小写
+	Start    time.Time // 开始时间（包含）
+	End      time.Time // 结束时间（包含）
+}
+
+// Match 判断审计日志是否满足查询条件
+func (f AuditFilter) Match(entry AuditLogEntry) bool {
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.Resource != "" && entry.Resource != f.Resource {
+		return false
+	}
+	if f.Actor != "" && entry.User != f.Actor {
+		return false
+	}
+	if f.Result != "" && !strings.EqualFold(entry.Result, f.Result) {
+		return false
+	}
+	if f.Keyword != "" && !strings.Contains(strings.ToLower(entry.Message), strings.ToLower(f.Keyword)) {
+		return false
+	}
+	if !f.Start.IsZero() && entry.Timestamp.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && entry.Timestamp.After(f.End) {
+		return false
+	}
+	return true
+}
+
+// GetAuditLogs 获取满足条件的审计日志，最新的在前，支持分页
+func (l *Logger) GetAuditLogs(filter AuditFilter, page, pageSize int) ([]AuditLogEntry, int) {
+	l.audit.mutex.Lock()
+	defer l.audit.mutex.Unlock()
+
+	// 验证参数
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	// 过滤在分页前进行，total为满足条件的日志总数
+	start := (page - 1) * pageSize
+	total := 0
+	logs := []AuditLogEntry{}
+	for _, entry := range l.audit.entries {
+		if !filter.Match(entry) {
+			continue
+		}
+		if total >= start && len(logs) < pageSize {
+			logs = append(logs, entry)
+		}
+		total++
+	}
+	return logs, total
+}
+
+// ExportAuditLogs 按时间顺序对满足条件的全部审计日志调用fn，fn返回错误时停止；
+// 启用持久化时从审计日志文件读取，包含保留时间内超出内存数量上限的日志
+func (l *Logger) ExportAuditLogs(filter AuditFilter, fn func(entry AuditLogEntry) error) error {
+	l.audit.mutex.Lock()
+	if l.audit.file == nil {
+		entries := make([]AuditLogEntry, 0, len(l.audit.entries))
+		for i := len(l.audit.entries) - 1; i >= 0; i-- {
+			if filter.Match(l.audit.entries[i]) {
+				entries = append(entries, l.audit.entries[i])
+			}
+		}
+		l.audit.mutex.Unlock()
+
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 只读取到当前文件末尾，之后追加的日志和写入到一半的行不导出；
+	// 导出期间文件被清理替换时，已打开的文件仍可读取
+	file, err := os.Open(l.audit.path)
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = file.Stat(); err == nil {
+			size = info.Size()
+		} else {
+			file.Close()
+		}
+	}
+	l.audit.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return scanAuditEntries(io.LimitReader(file, size), func(entry AuditLogEntry) error {
+		if !filter.Match(entry) {
+			return nil
+		}
+		return fn(entry)
+	})
+}
+
+// EnableAuditPersistence 将审计日志持久化到path，每行一条JSON格式的日志，重启后从文件恢复；
+// 超过retentionDays天的日志从文件和内存中删除，retentionDays为0时使用默认值90天
+func (l *Logger) EnableAuditPersistence(path string, retentionDays int) error {
+	if retentionDays <= 0 {
+		retentionDays = defaultAuditRetentionDays
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	a := l.audit
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// 首次启用前记录的日志尚未写入文件，清理文件后追加
+	var pending []AuditLogEntry
+	if a.file == nil {
+		pending = a.entries
+	}
+	a.path = path
+	a.retention = time.Duration(retentionDays) * 24 * time.Hour
+
+	if err := a.compactLocked(pending); err != nil {
+		return err
+	}
+
+	// 内存中保存文件中最新的日志
+	var entries []AuditLogEntry
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = scanAuditEntries(file, func(entry AuditLogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if len(entries) > a.max {
+		entries = entries[len(entries)-a.max:]
+	}
+	a.entries = make([]AuditLogEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		a.entries = append(a.entries, entries[i])
+	}
+	return nil
+}
+
+// persistLocked 将审计日志追加到文件，并按间隔清理过期日志，调用方需持有a.mutex
+func (a *auditLog) persistLocked(timestamp time.Time, jsonData []byte) {
+	if a.file == nil {
+		return
+	}
+	if _, err := a.file.Write(append(jsonData, '\n')); err != nil {
+		DefaultLogger.Error("Failed to persist audit log: %v", err)
+	}
+
+	// 内存中的日志按时间倒序，从末尾删除过期日志
+	cutoff := timestamp.Add(-a.retention)
+	for len(a.entries) > 0 && a.entries[len(a.entries)-1].Timestamp.Before(cutoff) {
+		a.entries = a.entries[:len(a.entries)-1]
+	}
+	if time.Since(a.compactedAt) >= auditCompactInterval {
+		if err := a.compactLocked(nil); err != nil {
+			DefaultLogger.Error("Failed to compact audit log file: %v", err)
+		}
+	}
+}
+
+// compactLocked 重写审计日志文件，删除过期日志并追加pending中的日志（按时间倒序），
+// 完成后切换到新文件继续追加；失败时保留原文件和已打开的文件句柄，下次写入时重试，调用方需持有a.mutex
+func (a *auditLog) compactLocked(pending []AuditLogEntry) error {
+	tmpPath := a.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	cutoff := time.Now().Add(-a.retention)
+
+	if file, err := os.Open(a.path); err == nil {
+		err = scanAuditEntries(file, func(entry AuditLogEntry) error {
+			if entry.Timestamp.Before(cutoff) {
+				return nil
+			}
+			return encoder.Encode(entry)
+		})
+		file.Close()
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	for i := len(pending) - 1; i >= 0; i-- {
+		if err := encoder.Encode(pending[i]); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// 先以追加方式打开新文件再替换，替换后句柄仍指向新文件，任何一步失败都继续使用原文件
+	file, err := os.OpenFile(tmpPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, a.path); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if a.file != nil {
+		a.file.Close()
+	}
+	a.file = file
+	a.compactedAt = time.Now()
+	return nil
+}
+
+// scanAuditEntries 逐行解析审计日志文件，跳过无法解析的行
+func scanAuditEntries(r io.Reader, fn func(entry AuditLogEntry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry AuditLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -28,6 +28,8 @@ type CrawlerLog struct {
 	Method     string    `json:"method"`
 	CacheTTL   int       `json:"cache_ttl"`
 	RenderTime float64   `json:"render_time"`
+	// NormalizedURL 实际用于渲染和缓存的规范化URL
+	NormalizedURL string `json:"normalized_url,omitempty"`
 	
 	// GeoIP fields
 	Country     string  `json:"country,omitempty"`
@@ -43,6 +45,14 @@ type CrawlerLogManager struct {
 	redisClient *redis.Client
 	ctx         context.Context
 	logChan     chan CrawlerLog
+	geo         *geoEnricher
+
+	// mutex 保护closed，关闭后不再向logChan发送日志
+	mutex     sync.RWMutex
+	closed    bool
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
 }
 
 // NewCrawlerLogManager 创建爬虫日志管理器
@@ -97,16 +107,43 @@ func NewCrawlerLogManager(redisURL string) *CrawlerLogManager {
 	manager := &CrawlerLogManager{
 		redisClient: client,
 		ctx:         ctx,
-		logChan:     make(chan CrawlerLog, 1000), // 缓冲区大小
 	}
+	manager.start()
 
-	// 启动异步日志处理
-	go manager.processLogs()
+	return manager
+}
+
+// start 创建日志通道，启动异步日志处理和自动清理任务
+func (clm *CrawlerLogManager) start() {
+	clm.logChan = make(chan CrawlerLog, 1000) // 缓冲区大小
+	clm.stopCh = make(chan struct{})
 
+	clm.wg.Add(2)
+	// 启动异步日志处理
+	go func() {
+		defer clm.wg.Done()
+		clm.processLogs()
+	}()
 	// 启动自动清理任务
-	go manager.startCleanupTask()
+	go func() {
+		defer clm.wg.Done()
+		clm.startCleanupTask()
+	}()
+}
 
-	return manager
+// Close 停止自动清理任务，将通道中剩余的日志和正在解析地理位置的日志写入Redis后返回，
+// 关闭后记录的日志直接同步写入；可以重复调用
+func (clm *CrawlerLogManager) Close() {
+	clm.closeOnce.Do(func() {
+		clm.mutex.Lock()
+		clm.closed = true
+		close(clm.logChan)
+		clm.mutex.Unlock()
+
+		close(clm.stopCh)
+		clm.wg.Wait()
+		clm.geo.wait()
+	})
 }
 
 // RecordCrawlerLog 记录爬虫访问日志
@@ -116,6 +153,13 @@ func (clm *CrawlerLogManager) RecordCrawlerLog(crawlerLog CrawlerLog) {
 		crawlerLog.Time = time.Now()
 	}
 
+	clm.mutex.RLock()
+	defer clm.mutex.RUnlock()
+	if clm.closed {
+		clm.writeLog(crawlerLog)
+		return
+	}
+
 	// 发送到日志通道
 	select {
 	case clm.logChan <- crawlerLog:
@@ -133,8 +177,37 @@ func (clm *CrawlerLogManager) processLogs() {
 	}
 }
 
-// saveLog 保存日志到Redis或内存存储
+// SetGeoIPResolver 设置IP地理位置解析器，设置后日志在保存前异步补全地理位置
+func (clm *CrawlerLogManager) SetGeoIPResolver(resolver GeoIPResolver) {
+	clm.geo = newGeoEnricher(resolver, defaultGeoEnrichConcurrency)
+}
+
+// SetLocation 填充地理位置并标记为已清洗，location为nil时保持不变
+func (l *CrawlerLog) SetLocation(location *GeoLocation) {
+	if location == nil {
+		return
+	}
+	l.Country = location.Country
+	l.CountryCode = location.CountryCode
+	l.City = location.City
+	l.Latitude = location.Latitude
+	l.Longitude = location.Longitude
+	l.Washed = true
+}
+
+// saveLog 保存日志，未清洗的日志先在后台解析地理位置
 func (clm *CrawlerLogManager) saveLog(crawlerLog CrawlerLog) {
+	if !crawlerLog.Washed && clm.geo.enrich(crawlerLog.IP, func(location *GeoLocation) {
+		crawlerLog.SetLocation(location)
+		clm.writeLog(crawlerLog)
+	}) {
+		return
+	}
+	clm.writeLog(crawlerLog)
+}
+
+// writeLog 保存日志到Redis或内存存储
+func (clm *CrawlerLogManager) writeLog(crawlerLog CrawlerLog) {
 	// 生成ID
 	id := fmt.Sprintf("%d_%s", crawlerLog.Time.UnixNano(), crawlerLog.IP)
 	crawlerLog.ID = id
@@ -262,6 +335,8 @@ func (clm *CrawlerLogManager) startCleanupTask() {
 		select {
 		case <-ticker.C:
 			clm.cleanupOldLogs()
+		case <-clm.stopCh:
+			return
 		}
 	}
 }
@@ -365,14 +440,42 @@ func (clm *CrawlerLogManager) cleanupOldLogs() {
 	}
 }
 
-// GetCrawlerLogs 获取爬虫访问日志
-func (clm *CrawlerLogManager) GetCrawlerLogs(site string, startTime, endTime time.Time, page, pageSize int) ([]CrawlerLog, int64, error) {
-	// 获取时间范围内的所有日期
-	days := int(endTime.Sub(startTime).Hours()/24) + 1
+// dailyKeys 返回时间范围覆盖的每一天的日志键名
+// 日志按记录时的本地日期分键存储，因此按本地日历日遍历，跨越日期边界的范围也会包含结束时间所在的日期
+func dailyKeys(keyPrefix string, startTime, endTime time.Time) []string {
+	start := startTime.Local()
+	end := endTime.Local()
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+	var keys []string
+	for !day.After(end) {
+		keys = append(keys, keyPrefix+day.Format("2006-01-02"))
+		day = day.AddDate(0, 0, 1)
+	}
+	return keys
+}
+
+// CrawlerLogFilter 爬虫日志过滤条件，零值表示不过滤
+type CrawlerLogFilter struct {
+	UA       string // User-Agent包含的子串，不区分大小写
+	HitCache *bool  // 是否命中缓存，nil表示不限
+}
+
+// Match 判断日志是否满足过滤条件
+func (f CrawlerLogFilter) Match(crawlerLog CrawlerLog) bool {
+	if f.UA != "" && !strings.Contains(strings.ToLower(crawlerLog.UA), strings.ToLower(f.UA)) {
+		return false
+	}
+	if f.HitCache != nil && crawlerLog.HitCache != *f.HitCache {
+		return false
+	}
+	return true
+}
 
-	// 初始化总日志列表和总数
+// GetCrawlerLogs 获取爬虫访问日志，过滤后按时间倒序分页，total为过滤后的总数
+// pageSize<=0时返回时间范围内的全部日志
+func (clm *CrawlerLogManager) GetCrawlerLogs(site string, startTime, endTime time.Time, filter CrawlerLogFilter, page, pageSize int) ([]CrawlerLog, int64, error) {
+	// 初始化总日志列表
 	var allLogJSONs []string
-	total := int64(0)
 
 	// 确定键前缀
 	keyPrefix := "crawler_logs:all:"
@@ -385,18 +488,7 @@ func (clm *CrawlerLogManager) GetCrawlerLogs(site string, startTime, endTime tim
 	endScore := float64(endTime.UnixNano())
 
 	// 遍历所有日期，获取日志
-	for i := 0; i < days; i++ {
-		date := startTime.AddDate(0, 0, i)
-		dateStr := date.Format("2006-01-02")
-		key := keyPrefix + dateStr
-
-		// 获取当日日志总数
-		dailyTotal, err := clm.redisClient.ZCount(clm.ctx, key, fmt.Sprintf("%f", startScore), fmt.Sprintf("%f", endScore)).Result()
-		if err != nil {
-			continue
-		}
-		total += dailyTotal
-
+	for _, key := range dailyKeys(keyPrefix, startTime, endTime) {
 		// 获取当日所有日志
 		dailyLogs, err := clm.redisClient.ZRangeByScore(clm.ctx, key, &redis.ZRangeBy{
 			Min: fmt.Sprintf("%f", startScore),
@@ -412,20 +504,26 @@ func (clm *CrawlerLogManager) GetCrawlerLogs(site string, startTime, endTime tim
 	// 计算分页参数
 	offset := (page - 1) * pageSize
 
-	// 反序列化日志
+	// 反序列化并过滤日志
 	var allLogs []CrawlerLog
 	for _, logJSON := range allLogJSONs {
 		var log CrawlerLog
 		if err := json.Unmarshal([]byte(logJSON), &log); err != nil {
 			continue
 		}
-		allLogs = append(allLogs, log)
+		if filter.Match(log) {
+			allLogs = append(allLogs, log)
+		}
 	}
+	total := int64(len(allLogs))
 
 	// 按时间倒序排序
 	sort.Slice(allLogs, func(i, j int) bool {
 		return allLogs[i].Time.After(allLogs[j].Time)
 	})
+	if pageSize <= 0 {
+		return allLogs, total, nil
+	}
 
 	// 分页处理
 	var pagedLogs []CrawlerLog
@@ -455,15 +553,8 @@ func (clm *CrawlerLogManager) GetCrawlerStats(site string, startTime, endTime ti
 		keyPrefix = fmt.Sprintf("crawler_logs:%s:", site)
 	}
 
-	// 获取时间范围内的所有日期
-	days := int(endTime.Sub(startTime).Hours()/24) + 1
-
 	// 遍历所有日期，获取日志
-	for i := 0; i < days; i++ {
-		date := startTime.AddDate(0, 0, i)
-		dateStr := date.Format("2006-01-02")
-		key := keyPrefix + dateStr
-
+	for _, key := range dailyKeys(keyPrefix, startTime, endTime) {
 		// 计算起始和结束时间戳
 		startScore := float64(startTime.UnixNano())
 		endScore := float64(endTime.UnixNano())
@@ -676,38 +767,3 @@ func (clm *CrawlerLogManager) GetCrawlerStats(site string, startTime, endTime ti
 
 	return stats, nil
 }
-
-// GetClientIP 获取客户端真实IP
-func GetClientIP(r *http.Request) string {
-	// 从X-Forwarded-For头获取真实IP
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For格式: client, proxy1, proxy2
-		if idx := strings.Index(xff, ","); idx != -1 {
-			return strings.TrimSpace(xff[:idx])
-		}
-		return strings.TrimSpace(xff)
-	}
-
-	// 从X-Real-IP头获取真实IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return strings.TrimSpace(xri)
-	}
-
-	// 直接获取RemoteAddr
-	ip := r.RemoteAddr
-
-	// 处理IPv6地址，格式为 [::1]:51234
-	if strings.HasPrefix(ip, "[") {
-		// 查找IPv6地址的结束位置
-		if idx := strings.Index(ip, "]"); idx != -1 {
-			return ip[1:idx] // 提取[和]之间的部分
-		}
-	}
-
-	// 处理IPv4地址，格式为 127.0.0.1:51234
-	if idx := strings.Index(ip, ":"); idx != -1 {
-		return ip[:idx]
-	}
-
-	return ip
-}
\ No newline at end of file
@@ -0,0 +1,177 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// TestDailyKeys 测试按本地日历日生成时间范围覆盖的日志键名，包含跨越日期边界的结束日期
+func TestDailyKeys(t *testing.T) {
+	start := time.Date(2024, 3, 1, 23, 0, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		end  time.Time
+		want []string
+	}{
+		{"same day", start.Add(30 * time.Minute), []string{"p:2024-03-01"}},
+		{"crosses midnight", start.Add(2 * time.Hour), []string{"p:2024-03-01", "p:2024-03-02"}},
+		{"multiple days", start.Add(48 * time.Hour), []string{"p:2024-03-01", "p:2024-03-02", "p:2024-03-03"}},
+		{"end before start", start.Add(-48 * time.Hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dailyKeys("p:", start, tt.end); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dailyKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGetCrawlerLogsDateRange 测试跨多天查询时合并各天的日志，按时间倒序排列后再分页
+func TestGetCrawlerLogsDateRange(t *testing.T) {
+	clm := &CrawlerLogManager{
+		redisClient: redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		ctx:         context.Background(),
+	}
+	defer clm.redisClient.Close()
+
+	day1 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	times := []time.Time{day1, day1.AddDate(0, 0, 1), day1.AddDate(0, 0, 2)}
+	for i, logTime := range times {
+		clm.writeLog(CrawlerLog{Site: "site1", IP: fmt.Sprintf("10.0.0.%d", i+1), Time: logTime, Washed: true})
+	}
+	// 其他站点的日志不计入站点查询
+	clm.writeLog(CrawlerLog{Site: "site2", IP: "10.0.0.9", Time: day1, Washed: true})
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2024, 3, 3, 23, 59, 59, 0, time.Local)
+	logs, total, err := clm.GetCrawlerLogs("site1", start, end, CrawlerLogFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("GetCrawlerLogs() error = %v", err)
+	}
+	if total != 3 || len(logs) != 3 {
+		t.Fatalf("expected 3 logs across three days, got %d (total %d)", len(logs), total)
+	}
+	for i, log := range logs {
+		if want := times[len(times)-1-i]; !log.Time.Equal(want) {
+			t.Errorf("logs[%d].Time = %v, want %v", i, log.Time, want)
+		}
+	}
+
+	// 分页作用于合并后的结果
+	logs, total, _ = clm.GetCrawlerLogs("site1", start, end, CrawlerLogFilter{}, 2, 2)
+	if total != 3 || len(logs) != 1 || !logs[0].Time.Equal(day1) {
+		t.Errorf("expected oldest log on second page, got %v (total %d)", logs, total)
+	}
+
+	logs, total, _ = clm.GetCrawlerLogs("", start, end, CrawlerLogFilter{}, 1, 10)
+	if total != 4 || len(logs) != 4 {
+		t.Errorf("expected 4 logs for all sites, got %d (total %d)", len(logs), total)
+	}
+
+	// 时间范围之外的日期不返回
+	logs, total, _ = clm.GetCrawlerLogs("site1", start.AddDate(0, 0, 1), end, CrawlerLogFilter{}, 1, 10)
+	if total != 2 || len(logs) != 2 {
+		t.Errorf("expected 2 logs from the last two days, got %d (total %d)", len(logs), total)
+	}
+}
+
+// TestGetCrawlerLogsFilter 测试按User-Agent子串和缓存命中状态过滤，过滤在分页前进行
+func TestGetCrawlerLogsFilter(t *testing.T) {
+	clm := &CrawlerLogManager{
+		redisClient: redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		ctx:         context.Background(),
+	}
+	defer clm.redisClient.Close()
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	entries := []struct {
+		ua       string
+		hitCache bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1)", false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1)", true},
+		{"Mozilla/5.0 (compatible; bingbot/2.0)", false},
+		{"Googlebot-Image/1.0", false},
+	}
+	for i, entry := range entries {
+		clm.writeLog(CrawlerLog{
+			Site:     "site1",
+			IP:       fmt.Sprintf("10.0.0.%d", i+1),
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			UA:       entry.ua,
+			HitCache: entry.hitCache,
+			Washed:   true,
+		})
+	}
+
+	missed, hit := false, true
+	tests := []struct {
+		name   string
+		filter CrawlerLogFilter
+		want   int64
+	}{
+		{"no filter", CrawlerLogFilter{}, 4},
+		{"ua case-insensitive", CrawlerLogFilter{UA: "googlebot"}, 3},
+		{"cache hit", CrawlerLogFilter{HitCache: &hit}, 1},
+		{"googlebot cache miss", CrawlerLogFilter{UA: "GOOGLEBOT", HitCache: &missed}, 2},
+		{"no match", CrawlerLogFilter{UA: "yandex"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, total, err := clm.GetCrawlerLogs("site1", start, start.Add(time.Hour), tt.filter, 1, 1)
+			if err != nil {
+				t.Fatalf("GetCrawlerLogs() error = %v", err)
+			}
+			if total != tt.want {
+				t.Errorf("total = %d, want %d", total, tt.want)
+			}
+			if len(logs) > 0 && !tt.filter.Match(logs[0]) {
+				t.Errorf("returned log %+v does not match filter", logs[0])
+			}
+		})
+	}
+}
+
+// TestCrawlerLogManagerCloseFlushes 测试Close返回前将通道中缓冲的日志和正在解析地理位置的日志写入Redis
+func TestCrawlerLogManagerCloseFlushes(t *testing.T) {
+	clm := &CrawlerLogManager{
+		redisClient: redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		ctx:         context.Background(),
+	}
+	defer clm.redisClient.Close()
+	resolver := &stubResolver{
+		locations: map[string]*GeoLocation{"8.8.8.8": {Country: "United States", CountryCode: "US"}},
+		block:     make(chan struct{}),
+	}
+	clm.SetGeoIPResolver(resolver)
+	clm.start()
+
+	logTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	clm.RecordCrawlerLog(CrawlerLog{Site: "site1", IP: "8.8.8.8", Time: logTime})
+	// 地理位置解析稍后完成，Close需要等待其写入
+	time.AfterFunc(50*time.Millisecond, func() { close(resolver.block) })
+	clm.Close()
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	logs, total, err := clm.GetCrawlerLogs("site1", start, start.Add(24*time.Hour-time.Second), CrawlerLogFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("GetCrawlerLogs() error = %v", err)
+	}
+	if total != 1 || len(logs) != 1 || logs[0].CountryCode != "US" {
+		t.Fatalf("expected the buffered log to be flushed with its location, got %v (total %d)", logs, total)
+	}
+
+	// 关闭后记录的日志直接写入，重复关闭不会panic
+	clm.RecordCrawlerLog(CrawlerLog{Site: "site1", IP: "10.0.0.2", Time: logTime.Add(time.Minute), Washed: true})
+	clm.Close()
+	if _, total, _ = clm.GetCrawlerLogs("site1", start, start.Add(24*time.Hour-time.Second), CrawlerLogFilter{}, 1, 10); total != 2 {
+		t.Errorf("expected log recorded after Close to be written, total %d", total)
+	}
+}
@@ -0,0 +1,76 @@
+package logging
+
+import "sync"
+
+// GeoLocation 地理位置信息
+type GeoLocation struct {
+	Country     string  `json:"country"`      // 国家名称
+	CountryCode string  `json:"country_code"` // 国家代码 (ISO 3166-1 alpha-2)
+	City        string  `json:"city"`         // 城市
+	Latitude    float64 `json:"latitude"`     // 纬度
+	Longitude   float64 `json:"longitude"`    // 经度
+}
+
+// GeoIPResolver 解析IP地理位置，由services.GeoIPService实现
+type GeoIPResolver interface {
+	GetLocation(ip string) (*GeoLocation, error)
+}
+
+// defaultGeoEnrichConcurrency 记录日志时并发解析地理位置的上限
+const defaultGeoEnrichConcurrency = 8
+
+// geoEnricher 在后台解析日志IP的地理位置，不阻塞日志通道
+// 并发数达到上限时不再解析，日志以未清洗状态保存，由LogProcessor稍后清洗
+type geoEnricher struct {
+	resolver GeoIPResolver
+	slots    chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newGeoEnricher 创建地理位置解析器，resolver为nil时返回nil
+func newGeoEnricher(resolver GeoIPResolver, concurrency int) *geoEnricher {
+	if resolver == nil {
+		return nil
+	}
+	if concurrency <= 0 {
+		concurrency = defaultGeoEnrichConcurrency
+	}
+	return &geoEnricher{
+		resolver: resolver,
+		slots:    make(chan struct{}, concurrency),
+	}
+}
+
+// enrich 有空闲名额时在后台解析ip的地理位置并调用save，解析失败时location为nil
+// 返回false表示未接手该日志，调用方应直接保存
+func (e *geoEnricher) enrich(ip string, save func(location *GeoLocation)) bool {
+	if e == nil || ip == "" {
+		return false
+	}
+	select {
+	case e.slots <- struct{}{}:
+	default:
+		return false
+	}
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer func() { <-e.slots }()
+		location, err := e.resolver.GetLocation(ip)
+		if err != nil {
+			DefaultLogger.Warn("GeoIP failed for %s: %v", ip, err)
+			location = nil
+		}
+		save(location)
+	}()
+	return true
+}
+
+// wait 等待正在后台解析的日志全部保存
+func (e *geoEnricher) wait() {
+	if e == nil {
+		return
+	}
+	e.wg.Wait()
+}
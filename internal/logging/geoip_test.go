@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// stubResolver 按IP返回固定位置的解析器，block非nil时解析会阻塞到其关闭
+type stubResolver struct {
+	locations map[string]*GeoLocation
+	block     chan struct{}
+}
+
+func (r *stubResolver) GetLocation(ip string) (*GeoLocation, error) {
+	if r.block != nil {
+		<-r.block
+	}
+	if location, ok := r.locations[ip]; ok {
+		return location, nil
+	}
+	return nil, fmt.Errorf("unknown ip %s", ip)
+}
+
+// TestGeoEnricher 测试后台解析地理位置并填充日志，失败时保持未清洗
+func TestGeoEnricher(t *testing.T) {
+	resolver := &stubResolver{locations: map[string]*GeoLocation{
+		"8.8.8.8": {Country: "United States", CountryCode: "US", City: "Mountain View", Latitude: 37.4, Longitude: -122.1},
+	}}
+	enricher := newGeoEnricher(resolver, 2)
+
+	saved := make(chan VisitLog, 1)
+	visitLog := VisitLog{IP: "8.8.8.8"}
+	if !enricher.enrich(visitLog.IP, func(location *GeoLocation) {
+		visitLog.SetLocation(location)
+		saved <- visitLog
+	}) {
+		t.Fatal("expected enricher to accept the log")
+	}
+	got := <-saved
+	if !got.Washed || got.CountryCode != "US" || got.City != "Mountain View" || got.Latitude != 37.4 {
+		t.Errorf("unexpected enriched log: %+v", got)
+	}
+
+	unknown := VisitLog{IP: "1.2.3.4"}
+	enricher.enrich(unknown.IP, func(location *GeoLocation) {
+		unknown.SetLocation(location)
+		saved <- unknown
+	})
+	if got := <-saved; got.Washed || got.Country != "" {
+		t.Errorf("expected failed lookup to leave log unwashed, got %+v", got)
+	}
+
+	// 未设置解析器或IP为空时不接手
+	var disabled *geoEnricher
+	if disabled.enrich("8.8.8.8", func(*GeoLocation) {}) || enricher.enrich("", func(*GeoLocation) {}) {
+		t.Error("expected enrich to be skipped")
+	}
+}
+
+// TestGeoEnricherConcurrencyLimit 测试并发解析达到上限时不阻塞，交由调用方直接保存
+func TestGeoEnricherConcurrencyLimit(t *testing.T) {
+	resolver := &stubResolver{block: make(chan struct{})}
+	enricher := newGeoEnricher(resolver, 1)
+
+	done := make(chan struct{})
+	if !enricher.enrich("8.8.8.8", func(*GeoLocation) { close(done) }) {
+		t.Fatal("expected first log to be accepted")
+	}
+	if enricher.enrich("8.8.4.4", func(*GeoLocation) {}) {
+		t.Error("expected second log to be rejected while the slot is busy")
+	}
+
+	close(resolver.block)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("enrichment did not finish")
+	}
+}
@@ -3,8 +3,10 @@ package logging
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -25,19 +27,69 @@ const (
 	FATAL
 )
 
+// 日志格式
+const (
+	// FormatConsole 文本格式，每行为级别、时间、模块和消息
+	FormatConsole = "console"
+	// FormatJSON JSON格式，每行一个JSON对象，便于日志采集系统解析
+	FormatJSON = "json"
+)
+
+// levelNames 日志级别名称
+var levelNames = map[LogLevel]string{
+	DEBUG: "debug",
+	INFO:  "info",
+	WARN:  "warn",
+	ERROR: "error",
+	FATAL: "fatal",
+}
+
+// String 返回日志级别名称
+func (level LogLevel) String() string {
+	return levelNames[level]
+}
+
+// ParseLevel 解析日志级别名称，不区分大小写
+func ParseLevel(name string) (LogLevel, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return INFO, fmt.Errorf("invalid log level: %s", name)
+}
+
 // Logger 日志记录器
+// 通过Module创建的模块日志记录器与创建它的日志记录器共享输出、级别和审计日志，只是输出时带有模块名并使用模块的日志级别
 type Logger struct {
-	debugLogger  *log.Logger
-	infoLogger   *log.Logger
-	warnLogger   *log.Logger
-	errorLogger  *log.Logger
-	fatalLogger  *log.Logger
-	auditLogger  *log.Logger
-	auditLogs    []AuditLogEntry
-	level        LogLevel
-	auditEnabled bool
-	maxAuditLogs int
-	mutex        sync.Mutex
+	core   *logCore
+	audit  *auditLog
+	module string
+}
+
+// logCore 日志输出、格式和各模块的日志级别
+type logCore struct {
+	mutex   sync.RWMutex
+	writer  io.Writer
+	file    *rotatingFile // 日志文件，未启用时为nil
+	format  string
+	level   LogLevel            // 未单独设置级别的模块使用的级别
+	modules map[string]LogLevel // 模块 -> 日志级别
+	loggers map[string]*Logger  // 模块 -> 模块日志记录器
+}
+
+// auditLog 审计日志，保存在内存中并以JSON格式输出，启用持久化后同时追加到审计日志文件
+type auditLog struct {
+	logger  *log.Logger
+	entries []AuditLogEntry
+	enabled bool
+	max     int
+	mutex   sync.Mutex
+
+	file        *os.File      // 审计日志文件，未启用持久化时为nil
+	path        string        // 审计日志文件路径
+	retention   time.Duration // 超过保留时间的审计日志从内存和文件中删除
+	compactedAt time.Time     // 上次清理审计日志文件的时间
 }
 
 // Config 日志配置
@@ -46,6 +98,16 @@ type Config struct {
 	Output       string
 	AuditEnabled bool
 	AuditOutput  string
+	// Format 日志格式：console（默认）或json
+	Format string
+	// Modules 模块单独的日志级别，如 prerender: debug
+	Modules map[string]string
+	// File 除Output外同时写入的日志文件，为空时不写入文件
+	File string
+	// MaxSizeMB 日志文件达到此大小后轮转，为0时使用默认值100MB
+	MaxSizeMB int
+	// MaxBackups 轮转后保留的历史日志文件数量，为0时使用默认值5
+	MaxBackups int
 }
 
 // AuditLogEntry 审计日志条目
@@ -64,106 +126,200 @@ type AuditLogEntry struct {
 
 // NewLogger 创建新的日志记录器
 func NewLogger(config Config) *Logger {
-	// 设置日志级别
-	level := INFO
-	switch config.Level {
-	case "debug":
-		level = DEBUG
-	case "info":
-		level = INFO
-	case "warn":
-		level = WARN
-	case "error":
-		level = ERROR
-	case "fatal":
-		level = FATAL
+	logger := &Logger{
+		core: &logCore{loggers: make(map[string]*Logger)},
+		audit: &auditLog{
+			entries: make([]AuditLogEntry, 0, 1000),
+			enabled: config.AuditEnabled,
+			max:     10000, // 最多保存10000条审计日志
+		},
 	}
+	logger.Configure(config)
 
-	// 设置输出
-	var output *os.File
-	if config.Output == "stdout" || config.Output == "" {
-		output = os.Stdout
-	} else {
-		var err error
-		output, err = os.OpenFile(config.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// 初始化审计日志记录器
+	if config.AuditEnabled {
+		logger.audit.logger = log.New(openOutput(config.AuditOutput, "audit log"), "", 0) // 审计日志使用JSON格式，不需要前缀和时间戳
+	}
+
+	return logger
+}
+
+// Configure 重新设置日志输出、格式和级别，模块日志记录器同时生效；审计日志的设置不变
+func (l *Logger) Configure(config Config) {
+	level, err := ParseLevel(config.Level)
+	if err != nil && config.Level != "" {
+		fmt.Printf("%v, using info instead\n", err)
+	}
+	modules := make(map[string]LogLevel, len(config.Modules))
+	for module, name := range config.Modules {
+		moduleLevel, err := ParseLevel(name)
 		if err != nil {
-			fmt.Printf("Failed to open log file: %v, using stdout instead\n", err)
-			output = os.Stdout
+			fmt.Printf("Module %s: %v, using default level instead\n", module, err)
+			continue
 		}
+		modules[module] = moduleLevel
 	}
-
-	// 创建基本日志记录器
-	flags := log.Ldate | log.Ltime | log.Lmicroseconds
-	logger := &Logger{
-		debugLogger:  log.New(output, "[DEBUG] ", flags),
-		infoLogger:   log.New(output, "[INFO]  ", flags),
-		warnLogger:   log.New(output, "[WARN]  ", flags),
-		errorLogger:  log.New(output, "[ERROR] ", flags),
-		fatalLogger:  log.New(output, "[FATAL] ", flags),
-		auditLogs:    make([]AuditLogEntry, 0, 1000),
-		level:        level,
-		auditEnabled: config.AuditEnabled,
-		maxAuditLogs: 10000, // 最多保存10000条审计日志
+	format := FormatConsole
+	if config.Format == FormatJSON {
+		format = FormatJSON
 	}
 
-	// 初始化审计日志记录器
-	if config.AuditEnabled {
-		var auditOutput *os.File
-		if config.AuditOutput == "stdout" || config.AuditOutput == "" {
-			auditOutput = os.Stdout
+	// 设置输出，配置了日志文件时同时写入文件
+	var writer io.Writer = openOutput(config.Output, "log")
+	var file *rotatingFile
+	if config.File != "" {
+		if file, err = openRotatingFile(config.File, config.MaxSizeMB, config.MaxBackups); err != nil {
+			fmt.Printf("Failed to open log file: %v, logging to %s only\n", err, config.Output)
 		} else {
-			var err error
-			auditOutput, err = os.OpenFile(config.AuditOutput, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				fmt.Printf("Failed to open audit log file: %v, using stdout instead\n", err)
-				auditOutput = os.Stdout
-			}
+			writer = io.MultiWriter(writer, file)
 		}
-		logger.auditLogger = log.New(auditOutput, "", 0) // 审计日志使用JSON格式，不需要前缀和时间戳
 	}
 
+	core := l.core
+	core.mutex.Lock()
+	previous := core.file
+	core.writer = writer
+	core.file = file
+	core.format = format
+	core.level = level
+	core.modules = modules
+	core.mutex.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+}
+
+// openOutput 打开日志输出，stdout或为空时使用标准输出，文件无法打开时回退到标准输出
+func openOutput(output, name string) *os.File {
+	if output == "stdout" || output == "" {
+		return os.Stdout
+	}
+	file, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Printf("Failed to open %s file: %v, using stdout instead\n", name, err)
+		return os.Stdout
+	}
+	return file
+}
+
+// Module 返回模块的日志记录器，同一模块返回同一个实例
+func (l *Logger) Module(name string) *Logger {
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+
+	if logger, exists := l.core.loggers[name]; exists {
+		return logger
+	}
+	logger := &Logger{core: l.core, audit: l.audit, module: name}
+	l.core.loggers[name] = logger
 	return logger
 }
 
+// Module 返回默认日志记录器的模块日志记录器
+func Module(name string) *Logger {
+	return DefaultLogger.Module(name)
+}
+
+// SetLevel 在运行时修改日志级别，module为空时修改默认级别，否则只修改该模块的级别
+func (l *Logger) SetLevel(module, name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	l.core.mutex.Lock()
+	defer l.core.mutex.Unlock()
+	if module == "" {
+		l.core.level = level
+	} else {
+		l.core.modules[module] = level
+	}
+	return nil
+}
+
+// Levels 返回默认日志级别和单独设置了级别的模块
+func (l *Logger) Levels() (string, map[string]string) {
+	l.core.mutex.RLock()
+	defer l.core.mutex.RUnlock()
+
+	modules := make(map[string]string, len(l.core.modules))
+	for module, level := range l.core.modules {
+		modules[module] = level.String()
+	}
+	return l.core.level.String(), modules
+}
+
+// enabled 判断当前模块是否输出该级别的日志
+func (l *Logger) enabled(level LogLevel) bool {
+	l.core.mutex.RLock()
+	defer l.core.mutex.RUnlock()
+
+	if moduleLevel, exists := l.core.modules[l.module]; exists {
+		return level >= moduleLevel
+	}
+	return level >= l.core.level
+}
+
+// output 按配置的格式输出一条日志
+func (l *Logger) output(level LogLevel, format string, v ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+
+	now := time.Now()
+	message := fmt.Sprintf(format, v...)
+	var line []byte
+	l.core.mutex.RLock()
+	logFormat, writer := l.core.format, l.core.writer
+	l.core.mutex.RUnlock()
+
+	if logFormat == FormatJSON {
+		line, _ = json.Marshal(struct {
+			Time    string `json:"time"`
+			Level   string `json:"level"`
+			Module  string `json:"module,omitempty"`
+			Message string `json:"msg"`
+		}{now.Format(time.RFC3339Nano), level.String(), l.module, message})
+	} else {
+		prefix := fmt.Sprintf("%-7s %s ", "["+strings.ToUpper(level.String())+"]", now.Format("2006/01/02 15:04:05.000000"))
+		if l.module != "" {
+			prefix += "[" + l.module + "] "
+		}
+		line = []byte(prefix + strings.TrimSuffix(message, "\n"))
+	}
+	writer.Write(append(line, '\n'))
+}
+
 // Debug 记录调试日志
 func (l *Logger) Debug(format string, v ...interface{}) {
-	if l.level <= DEBUG {
-		l.debugLogger.Printf(format, v...)
-	}
+	l.output(DEBUG, format, v...)
 }
 
 // Info 记录信息日志
 func (l *Logger) Info(format string, v ...interface{}) {
-	if l.level <= INFO {
-		l.infoLogger.Printf(format, v...)
-	}
+	l.output(INFO, format, v...)
 }
 
 // Warn 记录警告日志
 func (l *Logger) Warn(format string, v ...interface{}) {
-	if l.level <= WARN {
-		l.warnLogger.Printf(format, v...)
-	}
+	l.output(WARN, format, v...)
 }
 
 // Error 记录错误日志
 func (l *Logger) Error(format string, v ...interface{}) {
-	if l.level <= ERROR {
-		l.errorLogger.Printf(format, v...)
-	}
+	l.output(ERROR, format, v...)
 }
 
 // Fatal 记录致命日志并退出程序
 func (l *Logger) Fatal(format string, v ...interface{}) {
-	if l.level <= FATAL {
-		l.fatalLogger.Printf(format, v...)
-		os.Exit(1)
-	}
+	l.output(FATAL, format, v...)
+	os.Exit(1)
 }
 
 // Audit 记录审计日志
 func (l *Logger) Audit(entry AuditLogEntry) {
-	if !l.auditEnabled {
+	if !l.audit.enabled {
 		return
 	}
 
@@ -173,15 +329,15 @@ func (l *Logger) Audit(entry AuditLogEntry) {
 	}
 
 	// 将日志添加到内存缓存
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
+	l.audit.mutex.Lock()
+	defer l.audit.mutex.Unlock()
 
 	// 添加新日志到开头
-	l.auditLogs = append([]AuditLogEntry{entry}, l.auditLogs...)
+	l.audit.entries = append([]AuditLogEntry{entry}, l.audit.entries...)
 
 	// 如果超过最大日志数量，删除最旧的日志
-	if len(l.auditLogs) > l.maxAuditLogs {
-		l.auditLogs = l.auditLogs[:l.maxAuditLogs]
+	if len(l.audit.entries) > l.audit.max {
+		l.audit.entries = l.audit.entries[:l.audit.max]
 	}
 
 	// 转换为JSON格式
@@ -192,7 +348,8 @@ func (l *Logger) Audit(entry AuditLogEntry) {
 	}
 
 	// 写入日志
-	l.auditLogger.Println(string(jsonData))
+	l.audit.logger.Println(string(jsonData))
+	l.audit.persistLocked(entry.Timestamp, jsonData)
 }
 
 // LogSecurityEvent 记录安全事件
@@ -237,43 +394,6 @@ func (l *Logger) LogThreatDetection(ip string, threatType string, details map[st
 	l.Info("Threat detected: %s from %s, result: %s", threatType, ip, result)
 }
 
-// GetAuditLogs 获取审计日志，支持分页
-func (l *Logger) GetAuditLogs(page, pageSize int) ([]AuditLogEntry, int) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-
-	// 计算总页数
-	total := len(l.auditLogs)
-
-	// 验证参数
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 {
-		pageSize = 10
-	}
-	if pageSize > 100 {
-		pageSize = 100
-	}
-
-	// 计算起始和结束索引
-	start := (page - 1) * pageSize
-	end := start + pageSize
-
-	// 如果结束索引超过总日志数，调整结束索引
-	if end > total {
-		end = total
-	}
-
-	// 如果起始索引超过总日志数，返回空列表
-	if start >= total {
-		return []AuditLogEntry{}, total
-	}
-
-	// 返回分页日志
-	return l.auditLogs[start:end], total
-}
-
 // LogEntry 日志条目
 type LogEntry struct {
 	Time    time.Time
@@ -293,7 +413,7 @@ type LoggerInterface interface {
 	LogSecurityEvent(eventType string, ip string, details map[string]interface{}, result string, message string)
 	LogAdminAction(user string, ip string, action string, resource string, details map[string]interface{}, result string, message string)
 	LogThreatDetection(ip string, threatType string, details map[string]interface{}, result string, message string)
-	GetAuditLogs(page, pageSize int) ([]AuditLogEntry, int)
+	GetAuditLogs(filter AuditFilter, page, pageSize int) ([]AuditLogEntry, int)
 }
 
 // DefaultLogger 默认日志记录器
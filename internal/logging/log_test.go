@@ -0,0 +1,207 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readLines 读取日志文件中的所有行
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// TestModuleLevels 测试模块单独的日志级别、JSON格式和运行时修改级别
+func TestModuleLevels(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "app.log")
+	logger := NewLogger(Config{
+		Level:   "warn",
+		Output:  output,
+		Format:  FormatJSON,
+		Modules: map[string]string{"prerender": "debug"},
+	})
+	prerender := logger.Module("prerender")
+	firewall := logger.Module("firewall")
+	if logger.Module("prerender") != prerender {
+		t.Fatal("expected the same logger for the same module")
+	}
+
+	prerender.Debug("render %s", "/a")
+	firewall.Info("dropped by default level")
+	firewall.Warn("rule %d reloaded", 3)
+
+	lines := readLines(t, output)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d: %v", len(lines), lines)
+	}
+	var entry struct {
+		Level   string `json:"level"`
+		Module  string `json:"module"`
+		Message string `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if entry.Level != "debug" || entry.Module != "prerender" || entry.Message != "render /a" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	// 运行时修改默认级别后模块日志记录器立即生效
+	if err := logger.SetLevel("", "info"); err != nil {
+		t.Fatalf("failed to set level: %v", err)
+	}
+	firewall.Info("now visible")
+	if lines = readLines(t, output); len(lines) != 3 || !strings.Contains(lines[2], "now visible") {
+		t.Errorf("expected info log after lowering level, got %v", lines)
+	}
+	if err := logger.SetLevel("firewall", "verbose"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	level, modules := logger.Levels()
+	if level != "info" || modules["prerender"] != "debug" {
+		t.Errorf("unexpected levels: %s %v", level, modules)
+	}
+}
+
+// TestRotatingFile 测试日志文件超过大小后轮转并只保留指定数量的历史文件
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	file, err := openRotatingFile(path, 1, 2)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+	file.maxSize = 10
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	expected := map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"}
+	for name, content := range expected {
+		data, err := os.ReadFile(name)
+		if err != nil || string(data) != content {
+			t.Errorf("expected %s to contain %q, got %q (%v)", name, content, data, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be kept")
+	}
+}
+
+// TestAuditPersistence 测试审计日志按条件过滤、重启后从文件恢复、删除过期日志以及按时间顺序导出
+func TestAuditPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.log")
+	logger := NewLogger(Config{Level: "error", Output: os.DevNull, AuditEnabled: true, AuditOutput: os.DevNull})
+	// 启用持久化前记录的日志同样写入文件
+	logger.LogAdminAction("admin", "10.0.0.1", "config_rollback", "config", nil, "success", "Config rolled back")
+	if err := logger.EnableAuditPersistence(path, 30); err != nil {
+		t.Fatalf("failed to enable persistence: %v", err)
+	}
+	logger.LogAdminAction("bob", "10.0.0.2", "log_level_update", "logging", nil, "failure", "Invalid level")
+	logger.Audit(AuditLogEntry{Timestamp: time.Now().Add(-40 * 24 * time.Hour), Action: "config_rollback", Result: "success"})
+
+	// 重启后从文件恢复，过期日志被删除
+	restarted := NewLogger(Config{Level: "error", Output: os.DevNull, AuditEnabled: true, AuditOutput: os.DevNull})
+	if err := restarted.EnableAuditPersistence(path, 30); err != nil {
+		t.Fatalf("failed to enable persistence: %v", err)
+	}
+	logs, total := restarted.GetAuditLogs(AuditFilter{}, 1, 10)
+	if total != 2 || logs[0].User != "bob" || logs[1].User != "admin" {
+		t.Fatalf("expected 2 restored logs newest first, got %v (total %d)", logs, total)
+	}
+
+	filters := map[string]AuditFilter{
+		"action":  {Action: "config_rollback"},
+		"actor":   {Actor: "bob"},
+		"result":  {Result: "FAILURE"},
+		"keyword": {Keyword: "rolled back"},
+		"range":   {Start: logs[0].Timestamp, End: logs[0].Timestamp},
+	}
+	for name, filter := range filters {
+		if _, total := restarted.GetAuditLogs(filter, 1, 10); total != 1 {
+			t.Errorf("%s filter: expected 1 log, got %d", name, total)
+		}
+	}
+
+	var exported []string
+	err := restarted.ExportAuditLogs(AuditFilter{Resource: "config"}, func(entry AuditLogEntry) error {
+		exported = append(exported, entry.User)
+		return nil
+	})
+	if err != nil || len(exported) != 1 || exported[0] != "admin" {
+		t.Errorf("unexpected export result %v (%v)", exported, err)
+	}
+	restarted.LogAdminAction("carol", "10.0.0.3", "config_rollback", "config", nil, "success", "Config rolled back")
+	exported = nil
+	restarted.ExportAuditLogs(AuditFilter{Action: "config_rollback"}, func(entry AuditLogEntry) error {
+		exported = append(exported, entry.User)
+		return nil
+	})
+	if strings.Join(exported, ",") != "admin,carol" {
+		t.Errorf("expected export in time order, got %v", exported)
+	}
+}
+
+// TestAuditCompactFailureKeepsPersisting 测试清理审计日志文件失败时继续写入原文件，之后的写入重试清理
+func TestAuditCompactFailureKeepsPersisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger := NewLogger(Config{Level: "error", Output: os.DevNull, AuditEnabled: true, AuditOutput: os.DevNull})
+	if err := logger.EnableAuditPersistence(path, 30); err != nil {
+		t.Fatalf("failed to enable persistence: %v", err)
+	}
+	logger.LogAdminAction("admin", "10.0.0.1", "config_rollback", "config", nil, "success", "Config rolled back")
+
+	// 临时文件路径被目录占用，清理无法创建临时文件
+	if err := os.MkdirAll(filepath.Join(path+".tmp", "blocked"), 0755); err != nil {
+		t.Fatalf("failed to block temp file: %v", err)
+	}
+	logger.audit.mutex.Lock()
+	logger.audit.compactedAt = time.Time{}
+	logger.audit.mutex.Unlock()
+
+	logger.LogAdminAction("bob", "10.0.0.2", "config_rollback", "config", nil, "success", "Config rolled back")
+	logger.LogAdminAction("carol", "10.0.0.3", "config_rollback", "config", nil, "success", "Config rolled back")
+
+	// 读取文件中的日志，不经过内存中的日志
+	users := func() string {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open audit log file: %v", err)
+		}
+		defer file.Close()
+		var users []string
+		scanAuditEntries(file, func(entry AuditLogEntry) error {
+			users = append(users, entry.User)
+			return nil
+		})
+		return strings.Join(users, ",")
+	}
+	if got := users(); got != "admin,bob,carol" {
+		t.Fatalf("expected logs to keep persisting after compaction failure, got %s", got)
+	}
+
+	// 临时文件路径恢复后，下一次写入完成清理并切换到新文件
+	os.RemoveAll(path + ".tmp")
+	logger.LogAdminAction("dave", "10.0.0.4", "config_rollback", "config", nil, "success", "Config rolled back")
+	logger.audit.mutex.Lock()
+	compacted := !logger.audit.compactedAt.IsZero()
+	logger.audit.mutex.Unlock()
+	if !compacted {
+		t.Error("expected compaction to be retried on the next entry")
+	}
+	logger.LogAdminAction("erin", "10.0.0.5", "config_rollback", "config", nil, "success", "Config rolled back")
+	if got := users(); got != "admin,bob,carol,dave,erin" {
+		t.Errorf("expected logs to persist after compaction, got %s", got)
+	}
+}
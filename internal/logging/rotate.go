@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// defaultLogMaxSizeMB 日志文件轮转的默认大小
+	defaultLogMaxSizeMB = 100
+	// defaultLogMaxBackups 默认保留的历史日志文件数量
+	defaultLogMaxBackups = 5
+)
+
+// rotatingFile 按大小轮转的日志文件，写入后超过maxSize时将当前文件改名为path.1，
+// 原有的path.1改名为path.2，依此类推，超出maxBackups的文件删除
+type rotatingFile struct {
+	mutex      sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// openRotatingFile 打开日志文件，目录不存在时创建
+func openRotatingFile(path string, maxSizeMB, maxBackups int) (*rotatingFile, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultLogMaxSizeMB
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultLogMaxBackups
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	r := &rotatingFile{path: path, maxSize: int64(maxSizeMB) * 1024 * 1024, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open 以追加方式打开日志文件并记录当前大小
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Write 实现io.Writer，当前文件写满时先轮转
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件，依次后移历史文件并重新打开日志文件
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	os.Remove(r.backupPath(r.maxBackups))
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		os.Rename(r.backupPath(i), r.backupPath(i+1))
+	}
+	if err := os.Rename(r.path, r.backupPath(1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+// backupPath 返回第n个历史日志文件的路径
+func (r *rotatingFile) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// Close 关闭日志文件，之后的写入返回错误
+func (r *rotatingFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
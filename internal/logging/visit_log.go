@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -25,6 +26,7 @@ type VisitLog struct {
 	UA       string    `json:"ua"`
 	Duration float64   `json:"duration"` // 请求耗时（秒）
 	Referer  string    `json:"referer"`
+	Filtered bool      `json:"filtered,omitempty"` // 来源命中过滤规则，只保留在站点日志中，不计入统计
 
 	// GeoIP fields
 	Country     string  `json:"country,omitempty"`
@@ -40,6 +42,14 @@ type VisitLogManager struct {
 	redisClient *redis.Client
 	ctx         context.Context
 	logChan     chan VisitLog
+	geo         *geoEnricher
+
+	// mutex 保护closed，关闭后不再向logChan发送日志
+	mutex     sync.RWMutex
+	closed    bool
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
 }
 
 // NewVisitLogManager 创建访问日志管理器
@@ -77,36 +87,54 @@ func NewVisitLogManager(redisURL string) *VisitLogManager {
 	manager := &VisitLogManager{
 		redisClient: client,
 		ctx:         ctx,
-		logChan:     make(chan VisitLog, 2000), // Larger buffer for visit logs
 	}
-
-	go manager.processLogs()
-	go manager.startCleanupTask()
+	manager.start()
 
 	return manager
 }
 
+// start 创建日志通道，启动异步日志处理和自动清理任务
+func (vlm *VisitLogManager) start() {
+	vlm.logChan = make(chan VisitLog, 2000) // Larger buffer for visit logs
+	vlm.stopCh = make(chan struct{})
+
+	vlm.wg.Add(2)
+	go func() {
+		defer vlm.wg.Done()
+		vlm.processLogs()
+	}()
+	go func() {
+		defer vlm.wg.Done()
+		vlm.startCleanupTask()
+	}()
+}
+
+// Close 停止自动清理任务，将通道中剩余的日志和正在解析地理位置的日志写入Redis后返回，
+// 关闭后记录的日志直接同步写入；可以重复调用
+func (vlm *VisitLogManager) Close() {
+	vlm.closeOnce.Do(func() {
+		vlm.mutex.Lock()
+		vlm.closed = true
+		close(vlm.logChan)
+		vlm.mutex.Unlock()
+
+		close(vlm.stopCh)
+		vlm.wg.Wait()
+		vlm.geo.wait()
+	})
+}
+
 // RecordVisitLog 记录访问日志
 func (vlm *VisitLogManager) RecordVisitLog(visitLog VisitLog) {
 	if visitLog.Time.IsZero() {
 		visitLog.Time = time.Now()
 	}
 
-	// 简单的日志清洗/增强
-	// 在没有独立清洗服务的情况下，我们在记录时直接进行简单的GeoIP处理
-	if visitLog.IP != "" {
-		// 模拟GeoIP解析
-		if visitLog.IP == "127.0.0.1" || visitLog.IP == "::1" || visitLog.IP == "localhost" {
-			visitLog.Country = "China"
-			visitLog.City = "Local"
-			visitLog.Latitude = 39.9042
-			visitLog.Longitude = 116.4074
-		} else {
-			// 默认值
-			visitLog.Country = "Unknown"
-		}
-		// 标记为已清洗，以便在统计中显示
-		visitLog.Washed = true
+	vlm.mutex.RLock()
+	defer vlm.mutex.RUnlock()
+	if vlm.closed {
+		vlm.writeLog(visitLog)
+		return
 	}
 
 	select {
@@ -122,7 +150,37 @@ func (vlm *VisitLogManager) processLogs() {
 	}
 }
 
+// SetGeoIPResolver 设置IP地理位置解析器，设置后日志在保存前异步补全地理位置
+func (vlm *VisitLogManager) SetGeoIPResolver(resolver GeoIPResolver) {
+	vlm.geo = newGeoEnricher(resolver, defaultGeoEnrichConcurrency)
+}
+
+// SetLocation 填充地理位置并标记为已清洗，location为nil时保持不变
+func (l *VisitLog) SetLocation(location *GeoLocation) {
+	if location == nil {
+		return
+	}
+	l.Country = location.Country
+	l.CountryCode = location.CountryCode
+	l.City = location.City
+	l.Latitude = location.Latitude
+	l.Longitude = location.Longitude
+	l.Washed = true
+}
+
+// saveLog 保存日志，未清洗的日志先在后台解析地理位置
 func (vlm *VisitLogManager) saveLog(visitLog VisitLog) {
+	if !visitLog.Washed && vlm.geo.enrich(visitLog.IP, func(location *GeoLocation) {
+		visitLog.SetLocation(location)
+		vlm.writeLog(visitLog)
+	}) {
+		return
+	}
+	vlm.writeLog(visitLog)
+}
+
+// writeLog 将日志写入Redis并更新PV/UV/IP统计
+func (vlm *VisitLogManager) writeLog(visitLog VisitLog) {
 	id := fmt.Sprintf("%d_%s", visitLog.Time.UnixNano(), visitLog.IP)
 	visitLog.ID = id
 
@@ -140,6 +198,13 @@ func (vlm *VisitLogManager) saveLog(visitLog VisitLog) {
 	pipe := vlm.redisClient.Pipeline()
 	pipe.ZAdd(vlm.ctx, siteKey, &redis.Z{Score: score, Member: logJSON})
 	pipe.Expire(vlm.ctx, siteKey, 15*24*time.Hour)
+
+	// 被标记的垃圾来源访问不写入汇总日志和PV/UV/IP统计
+	if visitLog.Filtered {
+		pipe.Exec(vlm.ctx)
+		return
+	}
+
 	pipe.ZAdd(vlm.ctx, totalKey, &redis.Z{Score: score, Member: logJSON})
 	pipe.Expire(vlm.ctx, totalKey, 15*24*time.Hour)
 
@@ -264,7 +329,7 @@ func (vlm *VisitLogManager) GetAccessStats(startTime, endTime time.Time) (int64,
 	return totalPV, totalUV, totalIP
 }
 
-// GetTrafficTrend 获取流量趋势
+// TrafficData 流量趋势数据点
 type TrafficData struct {
 	Time            string `json:"time"`
 	TotalRequests   int64  `json:"totalRequests"`
@@ -272,6 +337,7 @@ type TrafficData struct {
 	BlockedRequests int64  `json:"blockedRequests"` // WAF blocked
 }
 
+// GetTrafficTrend 获取当天按4小时聚合的流量趋势，没有访问统计时返回空数组
 func (vlm *VisitLogManager) GetTrafficTrend(startTime, endTime time.Time) []TrafficData {
 	// 获取当天的每小时数据
 	// 为了简化，我们只返回当天的
@@ -279,7 +345,7 @@ func (vlm *VisitLogManager) GetTrafficTrend(startTime, endTime time.Time) []Traf
 	hourlyKey := fmt.Sprintf("stats:hourly:%s", dateStr)
 
 	hourlyData, err := vlm.redisClient.HGetAll(vlm.ctx, hourlyKey).Result()
-	if err != nil {
+	if err != nil || len(hourlyData) == 0 {
 		return []TrafficData{}
 	}
 
@@ -369,8 +435,13 @@ func (vlm *VisitLogManager) startCleanupTask() {
 	ticker := time.NewTicker(24 * time.Hour)
 	defer ticker.Stop()
 	vlm.cleanupOldLogs()
-	for range ticker.C {
-		vlm.cleanupOldLogs()
+	for {
+		select {
+		case <-ticker.C:
+			vlm.cleanupOldLogs()
+		case <-vlm.stopCh:
+			return
+		}
 	}
 }
 
@@ -508,7 +579,7 @@ func (vlm *VisitLogManager) GetVisitStats(site string, startTime, endTime time.T
 			if err := json.Unmarshal([]byte(logJSON), &l); err != nil {
 				continue
 			}
-			if l.Washed && l.Latitude != 0 && l.Longitude != 0 {
+			if !l.Filtered && l.Washed && l.Latitude != 0 && l.Longitude != 0 {
 				geoKey := fmt.Sprintf("%.2f,%.2f", l.Latitude, l.Longitude)
 				if _, ok := geoStats[geoKey]; !ok {
 					geoStats[geoKey] = map[string]interface{}{
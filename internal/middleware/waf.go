@@ -13,24 +13,30 @@ import (
 	"prerender-shield/internal/redis"
 	"prerender-shield/internal/repository"
 	"prerender-shield/internal/services"
+	"prerender-shield/internal/utils"
 )
 
 // WafMiddleware implements the Web Application Firewall logic
 func WafMiddleware(site config.SiteConfig, wafRepo *repository.WafRepository, redisClient *redis.Client, geoIP services.GeoIPResolver) gin.HandlerFunc {
+	// Entries may be single IPs or CIDR ranges; invalid entries are rejected by config validation
+	whitelist, _ := utils.ParseIPList(site.Firewall.Whitelist)
+	blacklist, _ := utils.ParseIPList(site.Firewall.Blacklist)
+
 	return func(c *gin.Context) {
 		if !site.Firewall.Enabled {
 			c.Next()
 			return
 		}
 
-		clientIP := c.ClientIP()
+		// Use the IP resolved by the site handler; gin's ClientIP() trusts a client-supplied X-Forwarded-For
+		clientIP := utils.ClientIP(c.Request)
 		requestPath := c.Request.URL.Path
 		userAgent := c.Request.UserAgent()
 		method := c.Request.Method
 		requestID := uuid.New().String()
 
 		// Helper to log and block
-		block := func(reason, ruleID string) {
+		block := func(reason, ruleID, threatType string) {
 			// Log to DB
 			log := models.AccessLog{
 				ID:          uuid.New().String(),
@@ -45,6 +51,8 @@ func WafMiddleware(site config.SiteConfig, wafRepo *repository.WafRepository, re
 				RuleID:      ruleID,
 				Reason:      reason,
 				CreatedAt:   time.Now(),
+				ThreatType:  threatType,
+				Threats:     []models.ThreatDetail{{Type: threatType, RuleID: ruleID, Message: reason}},
 			}
 			// Use a goroutine to avoid blocking the response
 			go func() {
@@ -64,21 +72,17 @@ func WafMiddleware(site config.SiteConfig, wafRepo *repository.WafRepository, re
 			c.Abort()
 		}
 
-		// 1. Whitelist Check
-		for _, ip := range site.Firewall.Whitelist {
-			if ip == clientIP {
-				// Allowed, skip other checks
-				c.Next()
-				return
-			}
+		// 1. Whitelist Check, takes precedence over blacklisted ranges
+		if whitelist.Contains(clientIP) {
+			// Allowed, skip other checks
+			c.Next()
+			return
 		}
 
 		// 2. Blacklist Check
-		for _, ip := range site.Firewall.Blacklist {
-			if ip == clientIP {
-				block("IP is in blacklist", "ip_blacklist")
-				return
-			}
+		if blacklist.Contains(clientIP) {
+			block("IP is in blacklist", "ip_blacklist", "blacklist")
+			return
 		}
 
 		// 3. GeoIP Check
@@ -88,7 +92,7 @@ func WafMiddleware(site config.SiteConfig, wafRepo *repository.WafRepository, re
 				// Check BlockList
 				for _, blockedCode := range site.Firewall.GeoIPConfig.BlockList {
 					if blockedCode == countryCode {
-						block("Country is blocked: "+countryCode, "geoip_block")
+						block("Country is blocked: "+countryCode, "geoip_block", "geoip")
 						return
 					}
 				}
@@ -103,7 +107,7 @@ func WafMiddleware(site config.SiteConfig, wafRepo *repository.WafRepository, re
 						}
 					}
 					if !allowed {
-						block("Country not in allow list: "+countryCode, "geoip_allow")
+						block("Country not in allow list: "+countryCode, "geoip_allow", "geoip")
 						return
 					}
 				}
@@ -112,11 +116,14 @@ func WafMiddleware(site config.SiteConfig, wafRepo *repository.WafRepository, re
 
 		// 4. Rate Limiting
 		if site.Firewall.RateLimitConfig.Enabled && redisClient != nil {
-			limit := site.Firewall.RateLimitConfig.Requests
-			window := site.Firewall.RateLimitConfig.Window
+			// 使用请求路径匹配的最具体的限制，计数器按IP和匹配的路径规则区分
+			pattern, limit, window := site.Firewall.RateLimitConfig.LimitFor(requestPath)
 			// banTime := site.Firewall.RateLimitConfig.BanTime
 
 			key := fmt.Sprintf("ratelimit:%s:%s", site.ID, clientIP)
+			if pattern != "" {
+				key += ":" + pattern
+			}
 
 			// Simple counter implementation
 			// In production, use a sliding window or token bucket
@@ -130,7 +137,7 @@ func WafMiddleware(site config.SiteConfig, wafRepo *repository.WafRepository, re
 					rdb.Expire(ctx, key, time.Duration(window)*time.Second)
 				}
 				if int(count) > limit {
-					block("Rate limit exceeded", "rate_limit")
+					block("Rate limit exceeded", "rate_limit", "rate_limit")
 					return
 				}
 			}
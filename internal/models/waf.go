@@ -61,4 +61,17 @@ type AccessLog struct {
 	Reason      string    `json:"reason"`
 	IsCleaned   bool      `json:"is_cleaned"`
 	CreatedAt   time.Time `json:"created_at"`
+
+	// ThreatType is the type of the first matched threat, e.g. xss, geoip, rate_limit
+	ThreatType string         `json:"threat_type,omitempty"`
+	Threats    []ThreatDetail `json:"threats,omitempty"`
+}
+
+// ThreatDetail represents a threat matched by a firewall detector or custom rule
+type ThreatDetail struct {
+	Type     string `json:"type"`
+	SubType  string `json:"sub_type,omitempty"`
+	RuleID   string `json:"rule_id,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message"`
 }
@@ -8,7 +8,6 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/disk"
 	"github.com/shirou/gopsutil/v4/mem"
 	"github.com/shirou/gopsutil/v4/net"
@@ -47,6 +46,13 @@ var (
 		},
 	)
 
+	spoofedCrawlerRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prerender_spoofed_crawler_requests_total",
+			Help: "Total number of requests claiming to be a search engine crawler that failed DNS verification",
+		},
+	)
+
 	cacheHits = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "prerender_cache_hits_total",
@@ -68,6 +74,13 @@ var (
 		},
 	)
 
+	upstreamErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "prerender_upstream_errors_total",
+			Help: "Total number of failed requests to proxy upstreams",
+		},
+	)
+
 	renderTime = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Name:    "prerender_render_time_seconds",
@@ -89,6 +102,8 @@ type Monitor struct {
 type Config struct {
 	Enabled           bool
 	PrometheusAddress string
+	DataDir           string // 统计所在文件系统的磁盘使用情况
+	StaticDir         string // 统计所在文件系统的磁盘使用情况和各站点静态目录大小
 }
 
 // NewMonitor 创建新的监控管理器
@@ -112,15 +127,18 @@ func (m *Monitor) Start() error {
 		responseTime,
 		crawlerRequests,
 		blockedRequests,
+		spoofedCrawlerRequests,
 		cacheHits,
 		cacheMisses,
 		activeBrowsers,
+		upstreamErrors,
 		renderTime,
+		siteResponseTime,
 	)
 
 	// 启动Prometheus服务器
+	m.wg.Add(1)
 	go func() {
-		m.wg.Add(1)
 		defer m.wg.Done()
 
 		http.Handle("/metrics", promhttp.Handler())
@@ -132,6 +150,10 @@ func (m *Monitor) Start() error {
 		http.ListenAndServe(addr, nil)
 	}()
 
+	// 后台采样系统资源，统计接口直接返回最近一次的结果
+	m.wg.Add(1)
+	go m.runSystemSampler()
+
 	m.isRunning = true
 	return nil
 }
@@ -171,8 +193,8 @@ func isStaticResource(path string) bool {
 	return false
 }
 
-// RecordRequest 记录请求，排除静态资源
-func (m *Monitor) RecordRequest(method, path string, status int, duration time.Duration) {
+// RecordRequest 记录站点请求，排除静态资源；siteID为空时只计入全局统计
+func (m *Monitor) RecordRequest(siteID, method, path string, status int, duration time.Duration) {
 	// 检查是否为静态资源，如果是则跳过记录
 	if isStaticResource(path) {
 		return
@@ -187,10 +209,11 @@ func (m *Monitor) RecordRequest(method, path string, status int, duration time.D
 	statsStore.mu.Lock()
 	statsStore.totalRequests++
 	statsStore.mu.Unlock()
+	recordSiteRequest(siteID, duration)
 }
 
-// RecordCrawlerRequest 记录爬虫请求
-func (m *Monitor) RecordCrawlerRequest() {
+// RecordCrawlerRequest 记录站点的爬虫请求
+func (m *Monitor) RecordCrawlerRequest(siteID string) {
 	// 更新Prometheus指标
 	crawlerRequests.Inc()
 
@@ -198,10 +221,11 @@ func (m *Monitor) RecordCrawlerRequest() {
 	statsStore.mu.Lock()
 	statsStore.crawlerRequests++
 	statsStore.mu.Unlock()
+	updateSite(siteID, func(s *siteCounters) { s.crawlerRequests++ })
 }
 
-// RecordBlockedRequest 记录被阻止的请求
-func (m *Monitor) RecordBlockedRequest() {
+// RecordBlockedRequest 记录站点被阻止的请求
+func (m *Monitor) RecordBlockedRequest(siteID string) {
 	// 更新Prometheus指标
 	blockedRequests.Inc()
 
@@ -209,10 +233,33 @@ func (m *Monitor) RecordBlockedRequest() {
 	statsStore.mu.Lock()
 	statsStore.blockedRequests++
 	statsStore.mu.Unlock()
+	updateSite(siteID, func(s *siteCounters) { s.blockedRequests++ })
+}
+
+// RecordSpoofedCrawlerRequest 记录未通过反向DNS验证的伪造爬虫请求
+func (m *Monitor) RecordSpoofedCrawlerRequest() {
+	// 更新Prometheus指标
+	spoofedCrawlerRequests.Inc()
+
+	// 更新实时统计数据
+	statsStore.mu.Lock()
+	statsStore.spoofedCrawlerRequests++
+	statsStore.mu.Unlock()
+}
+
+// RecordUpstreamError 记录代理模式下后端请求失败
+func (m *Monitor) RecordUpstreamError() {
+	// 更新Prometheus指标
+	upstreamErrors.Inc()
+
+	// 更新实时统计数据
+	statsStore.mu.Lock()
+	statsStore.upstreamErrors++
+	statsStore.mu.Unlock()
 }
 
-// RecordCacheHit 记录缓存命中
-func (m *Monitor) RecordCacheHit() {
+// RecordCacheHit 记录站点渲染缓存命中
+func (m *Monitor) RecordCacheHit(siteID string) {
 	// 更新Prometheus指标
 	cacheHits.Inc()
 
@@ -220,10 +267,11 @@ func (m *Monitor) RecordCacheHit() {
 	statsStore.mu.Lock()
 	statsStore.cacheHits++
 	statsStore.mu.Unlock()
+	updateSite(siteID, func(s *siteCounters) { s.cacheHits++ })
 }
 
-// RecordCacheMiss 记录缓存未命中
-func (m *Monitor) RecordCacheMiss() {
+// RecordCacheMiss 记录站点渲染缓存未命中
+func (m *Monitor) RecordCacheMiss(siteID string) {
 	// 更新Prometheus指标
 	cacheMisses.Inc()
 
@@ -231,6 +279,7 @@ func (m *Monitor) RecordCacheMiss() {
 	statsStore.mu.Lock()
 	statsStore.cacheMisses++
 	statsStore.mu.Unlock()
+	updateSite(siteID, func(s *siteCounters) { s.cacheMisses++ })
 }
 
 // SetActiveBrowsers 设置活跃浏览器数量
@@ -251,13 +300,15 @@ func (m *Monitor) RecordRenderTime(duration time.Duration) {
 
 // 实时统计数据存储
 var statsStore = struct {
-	mu              sync.Mutex
-	totalRequests   int64
-	crawlerRequests int64
-	blockedRequests int64
-	cacheHits       int64
-	cacheMisses     int64
-	activeBrowsers  int
+	mu                     sync.Mutex
+	totalRequests          int64
+	crawlerRequests        int64
+	blockedRequests        int64
+	spoofedCrawlerRequests int64
+	upstreamErrors         int64
+	cacheHits              int64
+	cacheMisses            int64
+	activeBrowsers         int
 	// 系统指标
 	cpuUsage          float64
 	memoryUsage       float64
@@ -284,6 +335,16 @@ func formatFloat(f float64) float64 {
 
 // GetStats 获取统计数据
 func (m *Monitor) GetStats() map[string]interface{} {
+	// 系统资源来自后台采样，不在持有statsStore锁时采样
+	system := m.systemStats()
+	netInfo, _ := getNetworkInfo()
+	diskInfo, exists := system.disks["data"]
+	if !exists {
+		if root, err := getDiskInfo("/"); err == nil {
+			diskInfo = *root
+		}
+	}
+
 	statsStore.mu.Lock()
 	defer statsStore.mu.Unlock()
 
@@ -294,53 +355,48 @@ func (m *Monitor) GetStats() map[string]interface{} {
 		cacheHitRate = formatFloat(cacheHitRate)
 	}
 
-	// 获取真实系统指标数据
-	cpuUsage, _ := getCPUUsage()
-	memoryInfo, _ := getMemoryInfo()
-	diskInfo, _ := getDiskInfo()
-	netInfo, _ := getNetworkInfo()
-
-	// 计算请求每秒
-	requestsPerSecond := formatFloat(float64(statsStore.totalRequests) / 1000)
+	// 最近一分钟的平均每秒请求数
+	rps := requestsPerSecond()
 
 	return map[string]interface{}{
 		"totalRequests":   float64(statsStore.totalRequests),
 		"crawlerRequests": float64(statsStore.crawlerRequests),
 		"blockedRequests": float64(statsStore.blockedRequests),
+		"upstreamErrors":  float64(statsStore.upstreamErrors),
 		"cacheHits":       float64(statsStore.cacheHits),
 		"cacheMisses":     float64(statsStore.cacheMisses),
 		"cacheHitRate":    cacheHitRate,
 		"activeBrowsers":  float64(statsStore.activeBrowsers),
-		// 添加系统指标
-		"cpuUsage":           cpuUsage,
-		"memoryUsage":        memoryInfo.UsagePercent,
-		"memoryTotal":        memoryInfo.Total,
-		"memoryUsed":         memoryInfo.Used,
-		"memoryFree":         memoryInfo.Free,
+		// 未通过反向DNS验证的伪造爬虫请求数
+		"spoofedCrawlerRequests": float64(statsStore.spoofedCrawlerRequests),
+		// 按站点的请求、延迟和缓存命中统计
+		"sites": m.GetAllSiteStats(),
+		// 系统指标，CPU使用率为最近一个采样间隔的平均值，磁盘为数据目录所在的文件系统
+		"cpuUsage":           system.cpuUsage,
+		"memoryUsage":        system.memory.UsagePercent,
+		"memoryTotal":        system.memory.Total,
+		"memoryUsed":         system.memory.Used,
+		"memoryFree":         system.memory.Free,
 		"diskUsage":          diskInfo.UsagePercent,
 		"diskTotal":          diskInfo.Total,
 		"diskUsed":           diskInfo.Used,
 		"diskFree":           diskInfo.Free,
-		"requestsPerSecond":  requestsPerSecond,
+		"requestsPerSecond":  rps,
 		"networkSent":        netInfo.BytesSent,
 		"networkRecv":        netInfo.BytesRecv,
 		"networkPacketsSent": netInfo.PacketsSent,
 		"networkPacketsRecv": netInfo.PacketsRecv,
+		// 本进程和无头浏览器进程的内存，浏览器通常是内存占用的主要来源
+		"process":  system.process,
+		"browsers": system.browsers,
+		// 数据目录和静态目录所在文件系统的使用情况
+		"disks": system.disks,
+		// 各站点静态目录大小，每staticSizeInterval刷新一次
+		"siteStaticSizes": system.staticSizes,
+		"sampledAt":       system.sampledAt,
 	}
 }
 
-// getCPUUsage 获取CPU使用率
-func getCPUUsage() (float64, error) {
-	percent, err := cpu.Percent(time.Second, false)
-	if err != nil {
-		return 0, err
-	}
-	if len(percent) > 0 {
-		return formatFloat(percent[0]), nil
-	}
-	return 0, nil
-}
-
 // MemoryInfo 内存信息
 
 type MemoryInfo struct {
@@ -373,10 +429,9 @@ type DiskInfo struct {
 	UsagePercent float64 `json:"usagePercent"`
 }
 
-// getDiskInfo 获取磁盘信息
-func getDiskInfo() (*DiskInfo, error) {
-	// 获取根目录磁盘信息
-	d, err := disk.Usage("/")
+// getDiskInfo 获取路径所在文件系统的磁盘信息
+func getDiskInfo(path string) (*DiskInfo, error) {
+	d, err := disk.Usage(path)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,189 @@
+package monitoring
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// latencySamples 每个站点保留用于计算延迟分位数的最近请求数
+const latencySamples = 1024
+
+// rateWindow 计算每秒请求数使用的时间窗口（秒）
+const rateWindow = 60
+
+// siteResponseTime 按站点统计的响应时间
+var siteResponseTime = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "prerender_site_response_time_seconds",
+		Help:    "Response time in seconds per site",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"site"},
+)
+
+// SiteStats 单个站点的监控统计
+type SiteStats struct {
+	SiteID          string  `json:"siteId"`
+	TotalRequests   int64   `json:"totalRequests"`
+	CrawlerRequests int64   `json:"crawlerRequests"`
+	BlockedRequests int64   `json:"blockedRequests"`
+	CacheHits       int64   `json:"cacheHits"`
+	CacheMisses     int64   `json:"cacheMisses"`
+	CacheHitRate    float64 `json:"cacheHitRate"`      // 百分比
+	LatencyP50      float64 `json:"latencyP50"`        // 最近请求的响应时间中位数（毫秒）
+	LatencyP95      float64 `json:"latencyP95"`        // 最近请求的响应时间95分位（毫秒）
+	RequestsPerSec  float64 `json:"requestsPerSecond"` // 最近一分钟的平均每秒请求数
+}
+
+// siteCounters 单个站点的实时计数
+type siteCounters struct {
+	totalRequests   int64
+	crawlerRequests int64
+	blockedRequests int64
+	cacheHits       int64
+	cacheMisses     int64
+	latencies       []float64 // 最近请求的响应时间（毫秒），环形缓冲
+	next            int
+	rate            requestRate
+}
+
+// requestRate 按秒分桶统计最近rateWindow秒的请求数
+type requestRate struct {
+	buckets [rateWindow]int64
+	seconds [rateWindow]int64
+}
+
+// add 记录一次请求
+func (r *requestRate) add(now time.Time) {
+	sec := now.Unix()
+	i := sec % rateWindow
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.buckets[i] = 0
+	}
+	r.buckets[i]++
+}
+
+// perSecond 返回最近rateWindow秒的平均每秒请求数
+func (r *requestRate) perSecond(now time.Time) float64 {
+	sec := now.Unix()
+	var total int64
+	for i := range r.buckets {
+		if sec-r.seconds[i] < rateWindow {
+			total += r.buckets[i]
+		}
+	}
+	return float64(total) / rateWindow
+}
+
+// siteStore 按站点ID保存的实时统计
+var siteStore = struct {
+	mu    sync.Mutex
+	sites map[string]*siteCounters
+	rate  requestRate // 所有站点的请求速率
+}{sites: make(map[string]*siteCounters)}
+
+// site 返回站点的计数，调用方需持有siteStore.mu
+func site(siteID string) *siteCounters {
+	counters, exists := siteStore.sites[siteID]
+	if !exists {
+		counters = &siteCounters{}
+		siteStore.sites[siteID] = counters
+	}
+	return counters
+}
+
+// recordSiteRequest 记录站点请求及其响应时间，siteID为空时只计入全局请求速率
+func recordSiteRequest(siteID string, duration time.Duration) {
+	now := time.Now()
+	siteStore.mu.Lock()
+	defer siteStore.mu.Unlock()
+
+	siteStore.rate.add(now)
+	if siteID == "" {
+		return
+	}
+	siteResponseTime.WithLabelValues(siteID).Observe(duration.Seconds())
+
+	counters := site(siteID)
+	counters.totalRequests++
+	counters.rate.add(now)
+	ms := float64(duration) / float64(time.Millisecond)
+	if len(counters.latencies) < latencySamples {
+		counters.latencies = append(counters.latencies, ms)
+	} else {
+		counters.latencies[counters.next] = ms
+	}
+	counters.next = (counters.next + 1) % latencySamples
+}
+
+// updateSite 在持有锁的情况下更新站点计数，siteID为空时忽略
+func updateSite(siteID string, update func(*siteCounters)) {
+	if siteID == "" {
+		return
+	}
+	siteStore.mu.Lock()
+	update(site(siteID))
+	siteStore.mu.Unlock()
+}
+
+// requestsPerSecond 返回所有站点最近一分钟的平均每秒请求数
+func requestsPerSecond() float64 {
+	siteStore.mu.Lock()
+	defer siteStore.mu.Unlock()
+	return formatFloat(siteStore.rate.perSecond(time.Now()))
+}
+
+// GetSiteStats 返回站点的监控统计，站点没有请求记录时各项为0
+func (m *Monitor) GetSiteStats(siteID string) SiteStats {
+	siteStore.mu.Lock()
+	defer siteStore.mu.Unlock()
+
+	stats := SiteStats{SiteID: siteID}
+	counters, exists := siteStore.sites[siteID]
+	if !exists {
+		return stats
+	}
+	stats.TotalRequests = counters.totalRequests
+	stats.CrawlerRequests = counters.crawlerRequests
+	stats.BlockedRequests = counters.blockedRequests
+	stats.CacheHits = counters.cacheHits
+	stats.CacheMisses = counters.cacheMisses
+	if lookups := counters.cacheHits + counters.cacheMisses; lookups > 0 {
+		stats.CacheHitRate = formatFloat(float64(counters.cacheHits) / float64(lookups) * 100)
+	}
+	stats.LatencyP50 = formatFloat(percentile(counters.latencies, 0.50))
+	stats.LatencyP95 = formatFloat(percentile(counters.latencies, 0.95))
+	stats.RequestsPerSec = formatFloat(counters.rate.perSecond(time.Now()))
+	return stats
+}
+
+// GetAllSiteStats 返回所有有请求记录的站点的监控统计，按站点ID排序
+func (m *Monitor) GetAllSiteStats() []SiteStats {
+	siteStore.mu.Lock()
+	ids := make([]string, 0, len(siteStore.sites))
+	for id := range siteStore.sites {
+		ids = append(ids, id)
+	}
+	siteStore.mu.Unlock()
+
+	sort.Strings(ids)
+	stats := make([]SiteStats, 0, len(ids))
+	for _, id := range ids {
+		stats = append(stats, m.GetSiteStats(id))
+	}
+	return stats
+}
+
+// percentile 返回样本的分位数，没有样本时返回0
+func percentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	return sorted[int(p*float64(len(sorted)-1)+0.5)]
+}
@@ -0,0 +1,34 @@
+package monitoring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSiteStats(t *testing.T) {
+	m := NewMonitor(Config{})
+	for i := 1; i <= 100; i++ {
+		m.RecordRequest("stats-site", "GET", "/page", 200, time.Duration(i)*time.Millisecond)
+	}
+	m.RecordRequest("stats-site", "GET", "/app.js", 200, time.Second) // 静态资源不计入
+	m.RecordRequest("other-site", "GET", "/", 200, time.Millisecond)
+	m.RecordCrawlerRequest("stats-site")
+	m.RecordBlockedRequest("stats-site")
+	m.RecordCacheHit("stats-site")
+	m.RecordCacheHit("stats-site")
+	m.RecordCacheHit("stats-site")
+	m.RecordCacheMiss("stats-site")
+
+	stats := m.GetSiteStats("stats-site")
+	assert.Equal(t, int64(100), stats.TotalRequests)
+	assert.Equal(t, int64(1), stats.CrawlerRequests)
+	assert.Equal(t, int64(1), stats.BlockedRequests)
+	assert.Equal(t, 75.0, stats.CacheHitRate)
+	assert.Equal(t, 51.0, stats.LatencyP50)
+	assert.Equal(t, 95.0, stats.LatencyP95)
+	assert.Greater(t, stats.RequestsPerSec, 0.0)
+
+	assert.Equal(t, SiteStats{SiteID: "missing"}, m.GetSiteStats("missing"))
+}
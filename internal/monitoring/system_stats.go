@@ -0,0 +1,236 @@
+package monitoring
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/process"
+
+	"prerender-shield/internal/config"
+)
+
+const (
+	// systemSampleInterval 系统资源的采样间隔，CPU使用率为两次采样之间的平均值
+	systemSampleInterval = 5 * time.Second
+	// staticSizeInterval 站点静态目录大小的刷新间隔，遍历目录开销较大，刷新频率低于其他指标
+	staticSizeInterval = time.Minute
+	// maxProcessDepth 查找子进程时向上追溯父进程的最大层数
+	maxProcessDepth = 32
+)
+
+// ProcessMemory 本进程的内存使用
+type ProcessMemory struct {
+	RSS        uint64 `json:"rss"`       // 常驻内存（字节）
+	HeapAlloc  uint64 `json:"heapAlloc"` // Go堆上已分配的内存（字节）
+	Sys        uint64 `json:"sys"`       // Go运行时从系统申请的内存（字节）
+	Goroutines int    `json:"goroutines"`
+}
+
+// BrowserProcesses 本进程启动的无头浏览器进程
+type BrowserProcesses struct {
+	Count int    `json:"count"`
+	RSS   uint64 `json:"rss"` // 所有浏览器进程的常驻内存合计（字节）
+}
+
+// SiteStaticSize 站点静态目录的大小
+type SiteStaticSize struct {
+	SiteID string `json:"siteId"`
+	Bytes  int64  `json:"bytes"`
+	Files  int    `json:"files"`
+}
+
+// systemSnapshot 最近一次采样的系统资源数据
+type systemSnapshot struct {
+	sampledAt     time.Time
+	cpuUsage      float64
+	memory        MemoryInfo
+	process       ProcessMemory
+	browsers      BrowserProcesses
+	disks         map[string]DiskInfo // data、static -> 所在文件系统的使用情况
+	staticSizes   []SiteStaticSize
+	staticSizesAt time.Time
+}
+
+// systemStore 系统资源采样结果，GetStats直接读取，不在请求路径上采样
+var systemStore = struct {
+	mu       sync.RWMutex
+	snapshot systemSnapshot
+}{}
+
+// runSystemSampler 定期采样系统资源，直到监控停止
+func (m *Monitor) runSystemSampler() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(systemSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.sampleSystem()
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+// sampleSystem 采样CPU、内存、磁盘和浏览器进程，到达刷新间隔时重新计算站点静态目录大小
+func (m *Monitor) sampleSystem() {
+	systemStore.mu.RLock()
+	staticSizes := systemStore.snapshot.staticSizes
+	staticSizesAt := systemStore.snapshot.staticSizesAt
+	systemStore.mu.RUnlock()
+
+	snapshot := systemSnapshot{
+		sampledAt:     time.Now(),
+		process:       processMemory(),
+		browsers:      browserProcesses(),
+		disks:         make(map[string]DiskInfo),
+		staticSizes:   staticSizes,
+		staticSizesAt: staticSizesAt,
+	}
+	// 间隔为0时返回与上一次调用之间的平均使用率，不阻塞
+	if percent, err := cpu.Percent(0, false); err == nil && len(percent) > 0 {
+		snapshot.cpuUsage = formatFloat(percent[0])
+	}
+	if memory, err := getMemoryInfo(); err == nil {
+		snapshot.memory = *memory
+	}
+	for name, dir := range map[string]string{"data": m.config.DataDir, "static": m.config.StaticDir} {
+		if dir == "" {
+			continue
+		}
+		if usage, err := getDiskInfo(dir); err == nil {
+			snapshot.disks[name] = *usage
+		}
+	}
+	if m.config.StaticDir != "" && time.Since(staticSizesAt) >= staticSizeInterval {
+		snapshot.staticSizes = siteStaticSizes(m.config.StaticDir, configuredSiteIDs())
+		snapshot.staticSizesAt = snapshot.sampledAt
+	}
+
+	systemStore.mu.Lock()
+	systemStore.snapshot = snapshot
+	systemStore.mu.Unlock()
+}
+
+// systemStats 返回最近一次采样结果，尚未采样时（如监控未启动）立即采样一次
+func (m *Monitor) systemStats() systemSnapshot {
+	systemStore.mu.RLock()
+	snapshot := systemStore.snapshot
+	systemStore.mu.RUnlock()
+	if snapshot.sampledAt.IsZero() {
+		m.sampleSystem()
+		systemStore.mu.RLock()
+		snapshot = systemStore.snapshot
+		systemStore.mu.RUnlock()
+	}
+	return snapshot
+}
+
+// processMemory 返回本进程的常驻内存和Go运行时内存统计
+func processMemory() ProcessMemory {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	memory := ProcessMemory{
+		HeapAlloc:  stats.HeapAlloc,
+		Sys:        stats.Sys,
+		Goroutines: runtime.NumGoroutine(),
+	}
+	if self, err := process.NewProcess(int32(os.Getpid())); err == nil {
+		if info, err := self.MemoryInfo(); err == nil {
+			memory.RSS = info.RSS
+		}
+	}
+	return memory
+}
+
+// browserProcesses 统计本进程启动的浏览器进程（包括浏览器的渲染和GPU子进程）的数量和常驻内存
+func browserProcesses() BrowserProcesses {
+	var result BrowserProcesses
+	procs, err := process.Processes()
+	if err != nil {
+		return result
+	}
+
+	parents := make(map[int32]int32, len(procs))
+	for _, proc := range procs {
+		if ppid, err := proc.Ppid(); err == nil {
+			parents[proc.Pid] = ppid
+		}
+	}
+	self := int32(os.Getpid())
+	for _, proc := range procs {
+		if !isDescendant(proc.Pid, self, parents) {
+			continue
+		}
+		name, err := proc.Name()
+		if err != nil || !isBrowserProcess(name) {
+			continue
+		}
+		result.Count++
+		if info, err := proc.MemoryInfo(); err == nil {
+			result.RSS += info.RSS
+		}
+	}
+	return result
+}
+
+// isDescendant 判断pid是否为ancestor的子孙进程
+func isDescendant(pid, ancestor int32, parents map[int32]int32) bool {
+	for i := 0; i < maxProcessDepth; i++ {
+		ppid, exists := parents[pid]
+		if !exists || ppid == pid || ppid <= 0 {
+			return false
+		}
+		if ppid == ancestor {
+			return true
+		}
+		pid = ppid
+	}
+	return false
+}
+
+// isBrowserProcess 判断进程名是否为Chrome或Chromium
+func isBrowserProcess(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "chrome") || strings.Contains(name, "chromium") || strings.Contains(name, "headless_shell")
+}
+
+// configuredSiteIDs 返回配置中所有站点的ID
+func configuredSiteIDs() []string {
+	cfg := config.GetInstance().GetConfig()
+	if cfg == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(cfg.Sites))
+	for _, site := range cfg.Sites {
+		ids = append(ids, site.ID)
+	}
+	return ids
+}
+
+// siteStaticSizes 统计各站点静态目录 staticDir/<站点ID> 下文件的总大小和数量，目录不存在时为0
+func siteStaticSizes(staticDir string, siteIDs []string) []SiteStaticSize {
+	sizes := make([]SiteStaticSize, 0, len(siteIDs))
+	for _, id := range siteIDs {
+		size := SiteStaticSize{SiteID: id}
+		filepath.Walk(filepath.Join(staticDir, id), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// 无法访问的文件或目录跳过，统计其余部分
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				size.Bytes += info.Size()
+				size.Files++
+			}
+			return nil
+		})
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
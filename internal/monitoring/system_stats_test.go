@@ -0,0 +1,74 @@
+package monitoring
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSiteStaticSizes(t *testing.T) {
+	staticDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(staticDir, "site1", "assets"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(staticDir, "site1", "index.html"), make([]byte, 100), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(staticDir, "site1", "assets", "app.js"), make([]byte, 250), 0644))
+
+	sizes := siteStaticSizes(staticDir, []string{"site1", "missing"})
+	assert.Equal(t, []SiteStaticSize{
+		{SiteID: "site1", Bytes: 350, Files: 2},
+		{SiteID: "missing"},
+	}, sizes)
+}
+
+func TestGetStats_SystemMetrics(t *testing.T) {
+	dir := t.TempDir()
+	m := NewMonitor(Config{DataDir: dir, StaticDir: dir})
+	m.sampleSystem()
+
+	stats := m.GetStats()
+	process := stats["process"].(ProcessMemory)
+	assert.NotZero(t, process.HeapAlloc)
+	assert.NotZero(t, process.Goroutines)
+	disks := stats["disks"].(map[string]DiskInfo)
+	assert.Contains(t, disks, "data")
+	assert.Contains(t, disks, "static")
+	assert.NotZero(t, disks["data"].Total)
+	assert.Contains(t, stats, "browsers")
+	assert.Contains(t, stats, "siteStaticSizes")
+}
+
+func TestBrowserProcesses(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not available")
+	}
+	// 以chrome为名启动子进程，模拟渲染引擎启动的浏览器
+	data, err := os.ReadFile(sleep)
+	if err != nil {
+		t.Skip("sleep not readable")
+	}
+	fakeChrome := filepath.Join(t.TempDir(), "chrome")
+	assert.NoError(t, os.WriteFile(fakeChrome, data, 0755))
+	cmd := exec.Command(fakeChrome, "30")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("failed to start fake browser: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	browsers := browserProcesses()
+	assert.Equal(t, 1, browsers.Count)
+	assert.NotZero(t, browsers.RSS)
+}
+
+func TestIsDescendant(t *testing.T) {
+	parents := map[int32]int32{10: 1, 20: 10, 30: 20, 40: 1}
+	assert.True(t, isDescendant(30, 10, parents))
+	assert.True(t, isDescendant(20, 10, parents))
+	assert.False(t, isDescendant(40, 10, parents))
+	assert.False(t, isDescendant(10, 10, parents))
+}
@@ -3,11 +3,14 @@ package prerender
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
-	"prerender-shield/internal/logging"
+	"prerender-shield/internal/config"
 	"prerender-shield/internal/redis"
 
 	"golang.org/x/net/html"
@@ -31,7 +34,17 @@ type Crawler struct {
 	semaphore    chan struct{}
 	ctx          context.Context
 	cancel       context.CancelFunc
-	fetcher      FetcherFunc // 用于获取页面内容的函数
+	fetcher      FetcherFunc    // 用于获取页面内容的函数
+	normalizer   *URLNormalizer // URL规范化器，用于合并重复链接
+	maxURLs      int            // 最多发现的URL数量，0表示不限制
+	urlCount     int            // 已发现的URL数量，由visitedMutex保护
+
+	respectRobots bool
+	userAgent     string
+	robotsFetcher FetcherFunc
+	robots        *RobotsRules // Start时加载，为nil时允许访问所有路径
+
+	excludePatterns []string // 排除的URL规则，匹配的URL不记录也不继续爬取
 }
 
 // CrawlerConfig 爬取器配置
@@ -42,7 +55,15 @@ type CrawlerConfig struct {
 	MaxDepth    int
 	Concurrency int
 	RedisClient *redis.Client
-	Fetcher     FetcherFunc // 必须提供
+	Fetcher     FetcherFunc    // 必须提供
+	Normalizer  *URLNormalizer // URL规范化器，为空时使用默认规则
+	MaxURLs     int            // 最多发现的URL数量，达到后停止爬取，0表示不限制
+	// 遵守站点robots.txt，跳过UserAgent被禁止访问的路径
+	RespectRobots bool
+	UserAgent     string
+	RobotsFetcher FetcherFunc // 获取robots.txt的函数，为空时直接发起HTTP请求
+	// 排除的URL规则（规则格式同站点exclude_patterns），匹配的URL不写入Redis也不继续爬取
+	ExcludePatterns []string
 }
 
 // NewCrawler 创建新的链接爬取器
@@ -61,6 +82,12 @@ func NewCrawler(config CrawlerConfig) *Crawler {
 		concurrency = 5
 	}
 
+	// 如果没有提供URL规范化器，使用默认规则
+	normalizer := config.Normalizer
+	if normalizer == nil {
+		normalizer = NewURLNormalizer(nil, nil, false)
+	}
+
 	return &Crawler{
 		siteName:    config.SiteName,
 		domain:      config.Domain,
@@ -74,6 +101,14 @@ func NewCrawler(config CrawlerConfig) *Crawler {
 		ctx:         ctx,
 		cancel:      cancel,
 		fetcher:     config.Fetcher,
+		normalizer:  normalizer,
+		maxURLs:     config.MaxURLs,
+
+		respectRobots: config.RespectRobots,
+		userAgent:     config.UserAgent,
+		robotsFetcher: config.RobotsFetcher,
+
+		excludePatterns: config.ExcludePatterns,
 	}
 }
 
@@ -89,11 +124,20 @@ func (c *Crawler) Start() error {
 		return fmt.Errorf("failed to clear previous URLs: %v", err)
 	}
 
+	if c.respectRobots {
+		c.loadRobots()
+	}
+
 	// 标记起始URL为已访问
 	c.markVisited(c.baseURL)
 
 	// 提取初始URL的路由部分
 	initialRoute := c.extractRoute(c.baseURL)
+	if !c.robotsAllowed(c.baseURL) {
+		logger.Info("Start URL %s is disallowed by robots.txt, skipping crawl", c.baseURL)
+		return nil
+	}
+	c.takeURLSlot()
 
 	// 添加到Redis，只存储路由部分
 	if err := c.redisClient.AddURL(c.siteName, initialRoute); err != nil {
@@ -103,7 +147,7 @@ func (c *Crawler) Start() error {
 	// 设置初始URL的初始状态和更新时间
 	if err := c.redisClient.SetURLPreheatStatus(c.siteName, initialRoute, "pending", 0); err != nil {
 		// 记录错误但不中断爬取
-		logging.DefaultLogger.Warn("Failed to set initial URL preheat status %s: %v", initialRoute, err)
+		logger.Warn("Failed to set initial URL preheat status %s: %v", initialRoute, err)
 	}
 
 	// 开始递归爬取
@@ -126,7 +170,7 @@ func (c *Crawler) crawl(urlStr string, depth int) {
 	// 添加panic恢复机制，防止单个爬取任务崩溃整个服务
 	defer func() {
 		if r := recover(); r != nil {
-			logging.DefaultLogger.Error("Panic recovered in crawl %s: %v", urlStr, r)
+			logger.Error("Panic recovered in crawl %s: %v", urlStr, r)
 		}
 		c.wg.Done()
 	}()
@@ -143,21 +187,27 @@ func (c *Crawler) crawl(urlStr string, depth int) {
 		return
 	}
 
-	// 使用Fetcher获取页面内容
-	logging.DefaultLogger.Debug("Fetching %s (depth: %d)", urlStr, depth)
-	
+	// 使用Fetcher获取页面内容，使用信号量控制同时获取的页面数
+	logger.Debug("Fetching %s (depth: %d)", urlStr, depth)
+
+	select {
+	case <-c.ctx.Done():
+		return
+	case c.semaphore <- struct{}{}:
+	}
 	htmlContent, err := c.fetcher(urlStr)
+	<-c.semaphore
 	if err != nil {
-		logging.DefaultLogger.Error("Failed to fetch %s: %v", urlStr, err)
+		logger.Error("Failed to fetch %s: %v", urlStr, err)
 		return
 	}
 
-	logging.DefaultLogger.Debug("Page HTML length: %d", len(htmlContent))
+	logger.Debug("Page HTML length: %d", len(htmlContent))
 
 	// 提取所有链接
 	links, err := c.extractLinks(htmlContent)
 	if err != nil {
-		logging.DefaultLogger.Error("Failed to extract links from %s: %v", urlStr, err)
+		logger.Error("Failed to extract links from %s: %v", urlStr, err)
 		return
 	}
 
@@ -170,6 +220,9 @@ func (c *Crawler) crawl(urlStr string, depth int) {
 		default:
 		}
 
+		// 规范化链接，避免跟踪参数等导致重复爬取
+		link = c.normalizer.Normalize(link)
+
 		// 检查是否已访问
 		if c.isVisited(link) {
 			continue
@@ -178,34 +231,44 @@ func (c *Crawler) crawl(urlStr string, depth int) {
 		// 标记为已访问
 		c.markVisited(link)
 
+		// 跳过robots.txt禁止访问的路径
+		if !c.robotsAllowed(link) {
+			logger.Debug("Skipping %s disallowed by robots.txt", link)
+			continue
+		}
+
+		// 跳过匹配站点排除规则的URL，如后台、API和登录页面
+		if config.MatchExcludePatterns(c.excludePatterns, c.extractRoute(link)) {
+			logger.Debug("Skipping %s matched by exclude patterns", link)
+			continue
+		}
+
+		// 达到URL数量上限后停止发现新URL，结束所有爬取任务
+		if !c.takeURLSlot() {
+			logger.Info("Crawler for site %s reached max URLs %d, stopping", c.siteName, c.maxURLs)
+			c.cancel()
+			return
+		}
+
 		// 提取URL的路由部分（去除域名）
 		route := c.extractRoute(link)
 		
 		// 添加到Redis，只存储路由部分
 		if err := c.redisClient.AddURL(c.siteName, route); err != nil {
-			logging.DefaultLogger.Warn("Failed to add URL to redis %s: %v", route, err)
+			logger.Warn("Failed to add URL to redis %s: %v", route, err)
 			continue
 		}
 		
 		// 设置URL的初始状态和更新时间
 		if err := c.redisClient.SetURLPreheatStatus(c.siteName, route, "pending", 0); err != nil {
-			logging.DefaultLogger.Warn("Failed to set URL preheat status %s: %v", route, err)
+			logger.Warn("Failed to set URL preheat status %s: %v", route, err)
 			// 不中断流程，继续处理
 		}
 
-		// 递归爬取，使用信号量控制并发
-		c.semaphore <- struct{}{}
+		// 递归爬取，crawl负责恢复panic和调用wg.Done；信号量只在获取页面时占用，
+		// 避免持有名额的任务等待为子链接获取名额而互相阻塞
 		c.wg.Add(1)
-		go func(link string, depth int) {
-			defer func() {
-				if r := recover(); r != nil {
-					logging.DefaultLogger.Error("Panic recovered in recursive crawl %s: %v", link, r)
-				}
-				<-c.semaphore
-				c.wg.Done()
-			}()
-			c.crawl(link, depth+1)
-		}(link, depth)
+		go c.crawl(link, depth+1)
 	}
 }
 
@@ -355,6 +418,11 @@ func (c *Crawler) isValidURL(urlStr string) bool {
 
 // extractRoute 从URL中提取路由部分（去除域名）
 func (c *Crawler) extractRoute(urlStr string) string {
+	return extractRoute(urlStr)
+}
+
+// extractRoute 从完整URL中提取路由部分，包括path、query和fragment
+func extractRoute(urlStr string) string {
 	parsed, err := url.Parse(urlStr)
 	if err != nil {
 		return urlStr
@@ -390,3 +458,78 @@ func (c *Crawler) markVisited(urlStr string) {
 	defer c.visitedMutex.Unlock()
 	c.visited[urlStr] = true
 }
+
+// takeURLSlot 占用一个URL名额，已达到MaxURLs上限时返回false
+func (c *Crawler) takeURLSlot() bool {
+	c.visitedMutex.Lock()
+	defer c.visitedMutex.Unlock()
+	if c.maxURLs > 0 && c.urlCount >= c.maxURLs {
+		return false
+	}
+	c.urlCount++
+	return true
+}
+
+// robotsAllowed 检查URL是否被robots.txt允许访问，未加载robots.txt时允许所有URL
+func (c *Crawler) robotsAllowed(urlStr string) bool {
+	if c.robots == nil {
+		return true
+	}
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if parsed.RawQuery != "" {
+		path += "?" + parsed.RawQuery
+	}
+	return c.robots.Allowed(path)
+}
+
+// loadRobots 获取并解析站点根目录的robots.txt，获取失败时允许访问所有路径
+func (c *Crawler) loadRobots() {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return
+	}
+	robotsURL := (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/robots.txt"}).String()
+
+	fetch := c.robotsFetcher
+	if fetch == nil {
+		fetch = fetchRobotsTxt
+	}
+	content, err := fetch(robotsURL)
+	if err != nil {
+		logger.Warn("Failed to fetch %s, crawling without robots rules: %v", robotsURL, err)
+		return
+	}
+	c.robots = ParseRobotsTxt(content, c.userAgent)
+}
+
+// maxRobotsTxtSize robots.txt读取的最大字节数，超出部分忽略
+const maxRobotsTxtSize = 512 << 10
+
+// fetchRobotsTxt 通过HTTP获取robots.txt，不存在（4xx）时返回空内容表示不限制
+func fetchRobotsTxt(robotsURL string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(robotsURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsTxtSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
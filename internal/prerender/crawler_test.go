@@ -0,0 +1,128 @@
+package prerender
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"prerender-shield/internal/redis"
+)
+
+// newTestCrawler 创建使用本地robots.txt服务和内存页面的爬虫，返回爬虫和已抓取页面的路由
+func newTestCrawler(t *testing.T, robotsTxt string, pages map[string]string, maxURLs int) (*Crawler, func() []string, func() int) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(robotsTxt))
+	}))
+	t.Cleanup(server.Close)
+
+	mr := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(mr.Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+
+	var mu sync.Mutex
+	var fetched []string
+	host := strings.TrimPrefix(server.URL, "http://")
+	crawler := NewCrawler(CrawlerConfig{
+		SiteName:      "site1",
+		Domain:        host,
+		BaseURL:       server.URL + "/",
+		MaxDepth:      5,
+		Concurrency:   5,
+		RedisClient:   redisClient,
+		MaxURLs:       maxURLs,
+		RespectRobots: true,
+		UserAgent:     "PrerenderShield",
+		Fetcher: func(pageURL string) (string, error) {
+			parsed, _ := url.Parse(pageURL)
+			mu.Lock()
+			fetched = append(fetched, parsed.RequestURI())
+			mu.Unlock()
+			return pages[parsed.RequestURI()], nil
+		},
+	})
+
+	fetchedRoutes := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		routes := append([]string(nil), fetched...)
+		sort.Strings(routes)
+		return routes
+	}
+	addedURLs := func() int {
+		urls, _ := mr.Members("prerender:site1:urls")
+		return len(urls)
+	}
+	return crawler, fetchedRoutes, addedURLs
+}
+
+// TestCrawlerRespectsRobotsTxt 测试爬虫跳过robots.txt禁止的路径
+func TestCrawlerRespectsRobotsTxt(t *testing.T) {
+	pages := map[string]string{
+		"/":      `<a href="/about">about</a><a href="/search?color=red">search</a><a href="/private/admin">admin</a>`,
+		"/about": `<a href="/search?color=blue">search</a><a href="/team">team</a>`,
+	}
+	robotsTxt := "User-agent: *\nDisallow: /search\n\nUser-agent: PrerenderShield\nDisallow: /private\nDisallow: /*?color=\n"
+	crawler, fetched, added := newTestCrawler(t, robotsTxt, pages, 0)
+
+	if err := crawler.Start(); err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	expected := []string{"/", "/about", "/team"}
+	if got := fetched(); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("fetched %v, want %v", got, expected)
+	}
+	if added() != len(expected) {
+		t.Errorf("expected %d URLs added to redis, got %d", len(expected), added())
+	}
+}
+
+// TestCrawlerMaxURLs 测试达到URL数量上限后停止发现新URL
+func TestCrawlerMaxURLs(t *testing.T) {
+	var links strings.Builder
+	for i := 0; i < 50; i++ {
+		links.WriteString(`<a href="/facet?page=` + string(rune('a'+i%26)) + string(rune('a'+i/26)) + `">x</a>`)
+	}
+	pages := map[string]string{"/": links.String()}
+	crawler, _, added := newTestCrawler(t, "", pages, 10)
+
+	if err := crawler.Start(); err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	if added() != 10 {
+		t.Errorf("expected discovery to stop at 10 URLs, got %d", added())
+	}
+}
+
+// TestCrawlerExcludePatterns 测试爬虫不记录也不继续爬取匹配排除规则的URL
+func TestCrawlerExcludePatterns(t *testing.T) {
+	pages := map[string]string{
+		"/":            `<a href="/about">about</a><a href="/admin/users">admin</a><a href="/api/items">api</a><a href="/login?next=/">login</a>`,
+		"/about":       `<a href="/team">team</a>`,
+		"/admin/users": `<a href="/admin-only">hidden</a>`,
+	}
+	crawler, fetched, added := newTestCrawler(t, "", pages, 0)
+	crawler.excludePatterns = []string{"/admin/", "/api/*", "^/login"}
+
+	if err := crawler.Start(); err != nil {
+		t.Fatalf("crawl failed: %v", err)
+	}
+	expected := []string{"/", "/about", "/team"}
+	if got := fetched(); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("fetched %v, want %v", got, expected)
+	}
+	if added() != len(expected) {
+		t.Errorf("expected %d URLs added to redis, got %d", len(expected), added())
+	}
+}
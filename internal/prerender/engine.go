@@ -2,16 +2,26 @@ package prerender
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"prerender-shield/internal/config"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/redis"
+	"prerender-shield/internal/utils"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
@@ -19,6 +29,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// logger 渲染预热模块的日志记录器，日志级别可以单独设置
+var logger = logging.Module("prerender")
+
 // Engine 渲染预热引擎
 type Engine struct {
 	SiteName           string
@@ -41,8 +54,28 @@ type Engine struct {
 	redisClient        *redis.Client
 	// 默认爬虫协议头列表
 	defaultCrawlerHeaders []string
+	headersMutex          sync.RWMutex               // 爬虫协议头配置读写锁
+	inflight              map[string]*inflightRender // 正在进行中的渲染，按规范化URL合并并发请求
+	inflightMutex         sync.Mutex                 // 进行中渲染映射互斥锁
+	coalescedRequests     int64                      // 被合并的渲染请求数
+	urlNormalizer         *URLNormalizer             // URL规范化器
+	// launchBrowser 启动浏览器实例，默认为launchRodBrowser，测试时可替换
+	launchBrowser func() (*rod.Browser, error)
+}
+
+// inflightRender 进行中的共享渲染任务，所有相同URL的并发请求等待同一个结果
+type inflightRender struct {
+	done   chan struct{}
+	result *RenderResult
+	err    error // 渲染任务未能提交时的错误，如ErrRenderQueueFull
 }
 
+// defaultMaxQueueWait 渲染队列已满时默认等待入队的最长时间
+const defaultMaxQueueWait = 5 * time.Second
+
+// ErrRenderQueueFull 渲染队列在MaxQueueWait内一直处于满载状态，调用方应直接回退而不是继续等待
+var ErrRenderQueueFull = errors.New("render queue full")
+
 // EngineManager 渲染预热引擎管理器，管理多个站点的渲染预热引擎
 type EngineManager struct {
 	mutex             sync.RWMutex
@@ -64,6 +97,10 @@ type Browser struct {
 	Instance   *rod.Browser // 实际的浏览器实例
 }
 
+// RenderRequestHeader 渲染浏览器访问站点时携带的请求头
+// 站点处理器据此识别渲染自身发起的请求，不再返回预渲染结果，避免渲染请求再次进入渲染
+const RenderRequestHeader = "X-Prerender-Shield-Render"
+
 // RenderTask 渲染任务
 type RenderTask struct {
 	ID      string
@@ -76,6 +113,7 @@ type RenderTask struct {
 type RenderOptions struct {
 	Timeout   int
 	WaitUntil string
+	Crawler   string // 爬虫分组，用于公平调度，为空时归入"other"
 }
 
 // RenderResult 渲染结果
@@ -83,8 +121,21 @@ type RenderResult struct {
 	HTML    string
 	Success bool
 	Error   string
+	NoIndex bool // 渲染后的页面包含 <meta name="robots" content="noindex">
+	// StatusCode 源站对页面请求返回的HTTP状态码，缓存命中或未知时为0
+	StatusCode int
+	// ContentType 源站页面响应的Content-Type，缓存命中或未知时为空
+	ContentType string
+	// Body 页面不是HTML且开启PassThroughNonHTML时源站返回的原始内容
+	Body []byte
 }
 
+// RenderErrorNotHTML 页面响应的Content-Type不是HTML，渲染结果不可用作预渲染页面
+const RenderErrorNotHTML = "not_html"
+
+// defaultHTMLContentTypes 未配置HTMLContentTypes时视为HTML的Content-Type
+var defaultHTMLContentTypes = []string{"text/html", "application/xhtml+xml"}
+
 // PrerenderConfig 渲染预热配置
 type PrerenderConfig struct {
 	Enabled           bool
@@ -96,14 +147,72 @@ type PrerenderConfig struct {
 	Preheat           PreheatConfig
 	CrawlerHeaders    []string // 爬虫协议头列表
 	UseDefaultHeaders bool     // 是否使用默认爬虫协议头
+	// URL规范化配置
+	IgnoredQueryParams     []string      // 忽略的查询参数，为空时使用默认跟踪参数
+	SignificantQueryParams []string      // 影响渲染结果的查询参数，非空时其余参数在渲染和缓存前移除
+	CollapseTrailingSlash  bool          // 是否合并末尾斜杠
+	FairQueueing           bool          // 是否按爬虫分组公平分配渲染能力
+	MaxQueueWait           time.Duration // 渲染队列已满时等待入队的最长时间，为0时使用默认值
+	NoCacheStatuses        []int         // 不写入缓存的源站状态码，4xx和5xx始终不缓存
+	HTMLContentTypes       []string      // 视为HTML的页面Content-Type，为空时使用defaultHTMLContentTypes
+	PassThroughNonHTML     bool          // 页面不是HTML时在渲染结果中携带源站原始内容
+	ExcludePatterns        []string      // 站点排除的URL规则，匹配的URL不被爬虫记录、不预热
 }
 
 // PreheatConfig 缓存预热配置
 type PreheatConfig struct {
-	Enabled  bool
-	MaxDepth int
+	Enabled       bool
+	Concurrency   int // 同时预热的URL数，为0时按浏览器池大小确定
+	MaxDepth      int
+	SitemapURL    string // sitemap地址，配置后使用sitemap代替链接爬虫发现URL
+	SkipUnchanged bool   // 跳过lastmod未晚于上次缓存时间的URL
+	MaxURLs       int    // 链接爬虫最多发现的URL数量，0表示不限制
+	RespectRobots bool   // 链接爬虫跳过robots.txt禁止访问的路径
+	UserAgent     string // 匹配robots.txt规则组使用的User-Agent
+}
+
+// NewPrerenderConfig 将站点配置中的渲染预热配置转换为引擎配置
+func NewPrerenderConfig(cfg config.PrerenderConfig) PrerenderConfig {
+	return PrerenderConfig{
+		Enabled:                cfg.Enabled,
+		PoolSize:               cfg.PoolSize,
+		MinPoolSize:            cfg.MinPoolSize,
+		MaxPoolSize:            cfg.MaxPoolSize,
+		Timeout:                cfg.Timeout,
+		CacheTTL:               cfg.CacheTTL,
+		CrawlerHeaders:         cfg.CrawlerHeaders,
+		UseDefaultHeaders:      cfg.UseDefaultHeaders,
+		IgnoredQueryParams:     cfg.IgnoredQueryParams,
+		SignificantQueryParams: cfg.SignificantQueryParams,
+		CollapseTrailingSlash:  cfg.CollapseTrailingSlash,
+		FairQueueing:           cfg.FairQueueing,
+		MaxQueueWait:           time.Duration(cfg.MaxQueueWait) * time.Second,
+		NoCacheStatuses:        cfg.NoCacheStatuses,
+		HTMLContentTypes:       cfg.HTMLContentTypes,
+		PassThroughNonHTML:     cfg.PassThroughNonHTML,
+		Preheat: PreheatConfig{
+			Enabled:       cfg.Preheat.Enabled,
+			Concurrency:   cfg.Preheat.Concurrency,
+			MaxDepth:      cfg.Preheat.MaxDepth,
+			SitemapURL:    cfg.Preheat.SitemapURL,
+			SkipUnchanged: cfg.Preheat.SkipUnchanged,
+			MaxURLs:       cfg.Preheat.MaxURLs,
+			RespectRobots: cfg.Preheat.RespectRobots,
+			UserAgent:     cfg.Preheat.UserAgent,
+		},
+	}
 }
 
+// NewSitePrerenderConfig 将站点配置转换为引擎配置，包括站点级别的URL排除规则
+func NewSitePrerenderConfig(site config.SiteConfig) PrerenderConfig {
+	prerenderConfig := NewPrerenderConfig(site.Prerender)
+	prerenderConfig.ExcludePatterns = site.ExcludePatterns
+	return prerenderConfig
+}
+
+// errPreheatCanceled 预热任务被停止或被新任务替换
+var errPreheatCanceled = errors.New("preheat canceled")
+
 // PreheatManager 缓存预热管理器
 type PreheatManager struct {
 	config        PrerenderConfig
@@ -113,6 +222,8 @@ type PreheatManager struct {
 	preheatWorker *PreheatWorker
 	isRunning     bool
 	currentTaskID string
+	job           *PreheatJob        // 当前或最近一次预热任务
+	cancel        context.CancelFunc // 取消当前预热任务
 	mutex         sync.Mutex
 }
 
@@ -137,13 +248,9 @@ func (pm *PreheatManager) TriggerPreheat() (string, error) {
 func (pm *PreheatManager) TriggerPreheatWithURL(baseURL, domain string) (string, error) {
 	pm.mutex.Lock()
 	if pm.isRunning {
-		logging.DefaultLogger.Info("Preheat already running, stopping previous task to restart...")
-		// 如果正在运行，停止之前的任务
-		if pm.crawler != nil {
-			pm.crawler.Stop()
-		}
-		// 标记为停止，但不要重置 currentTaskID，直到我们分配新的
-		pm.isRunning = false
+		logger.Info("Preheat already running, stopping previous task to restart...")
+		// 如果正在运行，取消之前的任务
+		pm.cancelLocked()
 	}
 
 	pm.isRunning = true
@@ -178,103 +285,95 @@ func (pm *PreheatManager) TriggerPreheatWithURL(baseURL, domain string) (string,
 		return "", fmt.Errorf("failed to create preheat task: %v", err)
 	}
 
-	// 更新 currentTaskID 为 Redis 返回的 ID
+	// 更新 currentTaskID 为 Redis 返回的 ID，并创建可取消的任务上下文
+	jobCtx, cancel := context.WithCancel(context.Background())
+	job := newPreheatJob(redisTaskID, pm.engine.SiteName)
 	pm.mutex.Lock()
 	pm.currentTaskID = redisTaskID
 	taskID = redisTaskID
+	pm.job = job
+	pm.cancel = cancel
 	pm.mutex.Unlock()
+	pm.saveJob(job)
 
 	// 异步执行预热流程，包括爬虫和渲染
 	go func() {
 		defer func() {
+			cancel()
 			pm.mutex.Lock()
 			// 只有当 currentTaskID 匹配时，才重置 isRunning
 			if pm.currentTaskID == taskID {
 				pm.isRunning = false
 				pm.currentTaskID = ""
+				pm.cancel = nil
 			}
 			pm.mutex.Unlock()
 		}()
 
-		// 1. 首先爬取站点的所有链接
-		logging.DefaultLogger.Info("Starting URL crawler for site: %s with baseURL: %s", pm.engine.SiteName, baseURL)
-
-		// 创建爬虫配置
-		crawlerConfig := CrawlerConfig{
-			SiteName:    pm.engine.SiteName,
-			Domain:      domain,
-			BaseURL:     baseURL,
-			MaxDepth:    pm.config.Preheat.MaxDepth,
-			Concurrency: 3, // 降低爬虫并发度，减少资源消耗
-			RedisClient: pm.redisClient,
-			Fetcher: func(url string) (string, error) {
-				// Use a short timeout for crawler requests
-				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-				defer cancel()
-
-				// Use default options for crawler
-				res, err := pm.engine.Render(ctx, url, RenderOptions{
-					Timeout:   20,
-					WaitUntil: "networkidle0",
-				})
-				if err != nil {
-					return "", err
-				}
-				if !res.Result.Success {
-					return "", fmt.Errorf("render failed: %s", res.Result.Error)
-				}
-				return res.Result.HTML, nil
-			},
-		}
-
-		// 创建爬虫实例并保存到 pm.crawler
-		crawler := NewCrawler(crawlerConfig)
-		pm.mutex.Lock()
-		// 如果在创建过程中已经被停止/替换（currentTaskID变了），则终止
-		if pm.currentTaskID != taskID {
-			pm.mutex.Unlock()
-			return
+		// fail 标记任务失败并记录错误
+		fail := func(message string, err error) {
+			pm.redisClient.SetPreheatTaskStatus(pm.engine.SiteName, taskID, "failed")
+			logger.Error("%s: %v", message, err)
+			job.recordError(fmt.Sprintf("%s: %v", message, err))
+			job.finish(PreheatJobFailed)
+			pm.saveJob(job)
 		}
-		pm.crawler = crawler
-		pm.mutex.Unlock()
 
-		// 同步执行爬虫，确保先完成URL爬取
-		if err := crawler.Start(); err != nil {
-			// 检查是否是因为上下文取消
-			if strings.Contains(err.Error(), "context canceled") {
-				logging.DefaultLogger.Info("Crawler canceled for site: %s", pm.engine.SiteName)
+		// 1. 配置了sitemap时从sitemap获取URL，否则爬取站点的所有链接
+		var sitemapRoutes []string
+		if pm.config.Preheat.SitemapURL != "" {
+			logger.Info("Loading sitemap for site: %s from %s", pm.engine.SiteName, pm.config.Preheat.SitemapURL)
+			routes, skipped, err := pm.ingestSitemap(jobCtx, pm.config.Preheat.SitemapURL)
+			if err != nil {
+				if jobCtx.Err() == nil {
+					fail("Failed to load sitemap", err)
+				}
 				return
 			}
-			pm.redisClient.SetPreheatTaskStatus(pm.engine.SiteName, taskID, "failed")
-			logging.DefaultLogger.Error("Failed to crawl URLs: %v", err)
+			job.addSkipped(int64(skipped))
+			sitemapRoutes = routes
+		} else if err := pm.crawlSite(jobCtx, taskID, baseURL, domain); err != nil {
+			if err != errPreheatCanceled {
+				fail("Failed to crawl URLs", err)
+			}
 			return
 		}
 
 		// 再次检查任务是否已被取消
-		pm.mutex.Lock()
-		if pm.currentTaskID != taskID {
-			pm.mutex.Unlock()
+		if jobCtx.Err() != nil {
 			return
 		}
-		pm.mutex.Unlock()
 
-		// 2. 获取所有URL后执行预热
-		urls, err := pm.redisClient.GetURLs(pm.engine.SiteName)
-		if err != nil {
-			pm.redisClient.SetPreheatTaskStatus(pm.engine.SiteName, taskID, "failed")
-			logging.DefaultLogger.Error("Failed to get URLs for preheat: %v", err)
-			return
+		// 2. 获取所有URL后执行预热，sitemap模式下按优先级顺序预热
+		urls := sitemapRoutes
+		if urls == nil {
+			allURLs, err := pm.redisClient.GetURLs(pm.engine.SiteName)
+			if err != nil {
+				fail("Failed to get URLs for preheat", err)
+				return
+			}
+			urls = allURLs
+		}
+
+		// 跳过匹配排除规则的URL，包括配置排除规则前已记录的URL
+		urls, excluded := excludeURLs(urls, pm.config.ExcludePatterns)
+		if excluded > 0 {
+			logger.Info("Skipping %d excluded URLs for site %s", excluded, pm.engine.SiteName)
+			job.addSkipped(int64(excluded))
 		}
 
 		// 防御性编程：限制最大URL数量，防止资源耗尽
 		const MaxPreheatURLs = 1000
 		if len(urls) > MaxPreheatURLs {
-			logging.DefaultLogger.Warn("Too many URLs to preheat, limiting to %d (total: %d)", MaxPreheatURLs, len(urls))
+			logger.Warn("Too many URLs to preheat, limiting to %d (total: %d)", MaxPreheatURLs, len(urls))
+			job.addSkipped(int64(len(urls) - MaxPreheatURLs))
 			urls = urls[:MaxPreheatURLs]
 		}
 
 		// 更新任务的总URL数
 		totalURLs := int64(len(urls))
+		job.setTotal(totalURLs)
+		pm.saveJob(job)
 		pm.redisClient.UpdatePreheatTaskProgress(pm.engine.SiteName, taskID, totalURLs, 0, 0, 0)
 
 		// 初始化进度统计
@@ -285,100 +384,257 @@ func (pm *PreheatManager) TriggerPreheatWithURL(baseURL, domain string) (string,
 			progressMux sync.Mutex
 		)
 
-		// 获取浏览器池大小，基于池大小动态调整并发度
-		maxConcurrency := pm.engine.config.PoolSize
-		if maxConcurrency < 1 {
-			maxConcurrency = 1
-		}
-		if maxConcurrency > 10 {
-			maxConcurrency = 10 // 限制最大并发度，防止资源耗尽
+		// 固定数量的预热协程从共享队列中获取URL，URL数量再多也不会创建更多协程
+		runPreheatPool(jobCtx, urls, pm.concurrency(), func(url string) {
+			defer func() {
+				// 更新进度
+				progressMux.Lock()
+				processed++
+				pm.redisClient.UpdatePreheatTaskProgress(pm.engine.SiteName, taskID, totalURLs, processed, success, failed)
+				pm.saveJob(job)
+				progressMux.Unlock()
+			}()
+
+			logger.Debug("Starting preheat for URL: %s", url)
+
+			// Redis中保存的是路由，需要结合baseURL得到完整URL
+			resultWithCache, err := pm.renderPreheatURL(resolvePreheatURL(baseURL, url))
+
+			if err != nil {
+				logger.Error("Preheat failed for URL %s: %v", url, err)
+				progressMux.Lock()
+				failed++
+				progressMux.Unlock()
+				job.recordFailure(url, err.Error())
+				// 更新URL状态为failed
+				pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "failed", 0)
+				return
+			}
+
+			if !resultWithCache.Result.Success {
+				logger.Error("Render failed for URL %s: %s", url, resultWithCache.Result.Error)
+				progressMux.Lock()
+				failed++
+				progressMux.Unlock()
+				job.recordFailure(url, resultWithCache.Result.Error)
+				// 更新URL状态为failed
+				pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "failed", 0)
+				return
+			}
+
+			// 渲染成功，更新成功计数和URL状态
+			logger.Debug("Successfully preheated URL: %s", url)
+			progressMux.Lock()
+			success++
+			progressMux.Unlock()
+			job.recordRendered()
+			// 更新URL状态为cached
+			pm.recordCached(url, resultWithCache.Result.HTML)
+		})
+
+		// 更新统计数据
+		pm.updateStats()
+
+		// 任务已被取消时，状态由Cancel设置
+		if jobCtx.Err() != nil {
+			logger.Info("Preheat canceled for site: %s", pm.engine.SiteName)
+			return
 		}
 
-		// 创建并发控制信号量
-		semaphore := make(chan struct{}, maxConcurrency)
-		var wg sync.WaitGroup
+		// 标记任务完成
+		pm.redisClient.SetPreheatTaskStatus(pm.engine.SiteName, taskID, "completed")
+		job.finish(PreheatJobCompleted)
+		pm.saveJob(job)
+		logger.Info("Preheat completed for site: %s", pm.engine.SiteName)
+		logger.Info("Preheat summary: total=%d, success=%d, failed=%d", totalURLs, success, failed)
+	}()
 
-		// 并发执行渲染预热
-		for _, url := range urls {
-			// 检查预热是否已被停止或替换
-			pm.mutex.Lock()
-			if !pm.isRunning || pm.currentTaskID != taskID {
-				pm.mutex.Unlock()
-				break
+	return taskID, nil
+}
+
+// concurrency 返回同时预热的URL数，未配置时按浏览器池大小确定，最多10个
+func (pm *PreheatManager) concurrency() int {
+	if pm.config.Preheat.Concurrency > 0 {
+		return pm.config.Preheat.Concurrency
+	}
+	concurrency := pm.engine.config.PoolSize
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > 10 {
+		concurrency = 10 // 限制最大并发度，防止资源耗尽
+	}
+	return concurrency
+}
+
+// runPreheatPool 启动concurrency个协程从共享队列中依次取出URL执行preheat，全部完成后返回
+// 任务取消时停止分发剩余的URL，等待正在执行的URL结束
+func runPreheatPool(ctx context.Context, urls []string, concurrency int, preheat func(url string)) {
+	if concurrency > len(urls) {
+		concurrency = len(urls)
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for url := range queue {
+				preheat(url)
 			}
-			pm.mutex.Unlock()
+		}()
+	}
 
-			wg.Add(1)
-			semaphore <- struct{}{}
+dispatch:
+	for _, url := range urls {
+		// 取消后即使有空闲的协程也不再分发（select在多个分支就绪时随机选择）
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case queue <- url:
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
 
-			go func(url string) {
-				defer func() {
-					wg.Done()
-					<-semaphore
+// renderPreheatURL 使用渲染引擎渲染预热URL，渲染结果自动写入缓存
+// 渲染不随预热任务取消而中断：取消后不再分发新的URL，已开始的渲染继续完成并计入进度
+func (pm *PreheatManager) renderPreheatURL(pageURL string) (*RenderResultWithCache, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	return pm.engine.Render(ctx, pageURL, RenderOptions{
+		Timeout:   20,
+		WaitUntil: "networkidle0",
+	})
+}
 
-					// 更新进度
-					progressMux.Lock()
-					processed++
-					pm.redisClient.UpdatePreheatTaskProgress(pm.engine.SiteName, taskID, totalURLs, processed, success, failed)
-					progressMux.Unlock()
-				}()
+// crawlSite 使用链接爬虫发现站点URL并写入Redis
+func (pm *PreheatManager) crawlSite(ctx context.Context, taskID, baseURL, domain string) error {
+	logger.Info("Starting URL crawler for site: %s with baseURL: %s", pm.engine.SiteName, baseURL)
+
+	// 创建爬虫配置
+	crawlerConfig := CrawlerConfig{
+		SiteName:    pm.engine.SiteName,
+		Domain:      domain,
+		BaseURL:     baseURL,
+		MaxDepth:    pm.config.Preheat.MaxDepth,
+		Concurrency: 3, // 降低爬虫并发度，减少资源消耗
+		RedisClient: pm.redisClient,
+		Normalizer:  pm.engine.urlNormalizer,
+		MaxURLs:     pm.config.Preheat.MaxURLs,
+		// 遵守robots.txt，避免爬取站点不希望被抓取的分面导航等路径
+		RespectRobots:   pm.config.Preheat.RespectRobots,
+		UserAgent:       pm.config.Preheat.UserAgent,
+		ExcludePatterns: pm.config.ExcludePatterns,
+		Fetcher: func(url string) (string, error) {
+			// Use a short timeout for crawler requests
+			fetchCtx, cancel := context.WithTimeout(ctx, 20*time.Second)
+			defer cancel()
+
+			// Use default options for crawler
+			res, err := pm.engine.Render(fetchCtx, url, RenderOptions{
+				Timeout:   20,
+				WaitUntil: "networkidle0",
+			})
+			if err != nil {
+				return "", err
+			}
+			if !res.Result.Success {
+				return "", fmt.Errorf("render failed: %s", res.Result.Error)
+			}
+			return res.Result.HTML, nil
+		},
+	}
 
-				// 使用渲染引擎进行真正的缓存预热
-				ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second) // 缩短超时时间
-				defer cancel()
+	// 创建爬虫实例并保存到 pm.crawler
+	crawler := NewCrawler(crawlerConfig)
+	pm.mutex.Lock()
+	// 如果在创建过程中已经被停止/替换（currentTaskID变了），则终止
+	if pm.currentTaskID != taskID || ctx.Err() != nil {
+		pm.mutex.Unlock()
+		return errPreheatCanceled
+	}
+	pm.crawler = crawler
+	pm.mutex.Unlock()
 
-				logging.DefaultLogger.Debug("Starting preheat for URL: %s", url)
+	// 同步执行爬虫，确保先完成URL爬取
+	if err := crawler.Start(); err != nil {
+		// 检查是否是因为上下文取消
+		if ctx.Err() != nil || strings.Contains(err.Error(), "context canceled") {
+			logger.Info("Crawler canceled for site: %s", pm.engine.SiteName)
+			return errPreheatCanceled
+		}
+		return err
+	}
 
-				// 调用引擎的Render方法，这将自动缓存渲染结果
-				resultWithCache, err := pm.engine.Render(ctx, url, RenderOptions{
-					Timeout:   20,
-					WaitUntil: "networkidle0",
-				})
+	if ctx.Err() != nil {
+		return errPreheatCanceled
+	}
+	return nil
+}
 
-				if err != nil {
-					logging.DefaultLogger.Error("Preheat failed for URL %s: %v", url, err)
-					progressMux.Lock()
-					failed++
-					progressMux.Unlock()
-					// 更新URL状态为failed
-					pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "failed", 0)
-					return
-				}
+// ingestSitemap 从sitemap获取URL并写入Redis，返回按优先级排序的待预热路由及跳过的URL数
+// 开启SkipUnchanged时，lastmod未晚于上次缓存时间的URL不会加入预热列表
+func (pm *PreheatManager) ingestSitemap(ctx context.Context, sitemapURL string) ([]string, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
 
-				if !resultWithCache.Result.Success {
-					logging.DefaultLogger.Error("Render failed for URL %s: %s", url, resultWithCache.Result.Error)
-					progressMux.Lock()
-					failed++
-					progressMux.Unlock()
-					// 更新URL状态为failed
-					pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "failed", 0)
-					return
-				}
+	entries, err := NewSitemapFetcher(nil).Fetch(ctx, sitemapURL)
+	if err != nil {
+		return nil, 0, err
+	}
 
-				// 渲染成功，更新成功计数和URL状态
-				logging.DefaultLogger.Debug("Successfully preheated URL: %s", url)
-				progressMux.Lock()
-				success++
-				progressMux.Unlock()
-				// 更新URL状态为cached
-				cacheSize := int64(len(resultWithCache.Result.HTML))
-				pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "cached", cacheSize)
-			}(url)
+	routes := make([]string, 0, len(entries))
+	skipped := 0
+	for _, entry := range entries {
+		route := extractRoute(pm.engine.NormalizeURL(entry.Loc))
+		if config.MatchExcludePatterns(pm.config.ExcludePatterns, route) {
+			skipped++
+			continue
+		}
+		if err := pm.redisClient.AddURL(pm.engine.SiteName, route); err != nil {
+			return nil, 0, fmt.Errorf("failed to add URL to redis: %v", err)
 		}
 
-		// 等待所有预热任务完成
-		wg.Wait()
+		if pm.config.Preheat.SkipUnchanged {
+			status, err := pm.redisClient.GetURLPreheatStatus(pm.engine.SiteName, route)
+			if err == nil && isUnchangedSinceCached(status, entry.LastMod, pm.config.CacheTTL, time.Now()) {
+				skipped++
+				continue
+			}
+		}
+		routes = append(routes, route)
+	}
 
-		// 更新统计数据
-		pm.updateStats()
+	logger.Info("Sitemap loaded for site %s: %d URLs, %d unchanged skipped", pm.engine.SiteName, len(entries), skipped)
+	return routes, skipped, nil
+}
 
-		// 标记任务完成
-		pm.redisClient.SetPreheatTaskStatus(pm.engine.SiteName, taskID, "completed")
-		logging.DefaultLogger.Info("Preheat completed for site: %s", pm.engine.SiteName)
-		logging.DefaultLogger.Info("Preheat summary: total=%d, success=%d, failed=%d", totalURLs, success, failed)
-	}()
+// excludeURLs 移除匹配排除规则的路由，返回保留的路由和移除的数量
+func excludeURLs(routes []string, patterns []string) ([]string, int) {
+	if len(patterns) == 0 {
+		return routes, 0
+	}
+	kept := make([]string, 0, len(routes))
+	for _, route := range routes {
+		if !config.MatchExcludePatterns(patterns, route) {
+			kept = append(kept, route)
+		}
+	}
+	return kept, len(routes) - len(kept)
+}
 
-	return taskID, nil
+// resolvePreheatURL 将Redis中保存的路由解析为可渲染的完整URL
+func resolvePreheatURL(baseURL, route string) string {
+	if !strings.HasPrefix(route, "/") {
+		return route
+	}
+	return strings.TrimRight(baseURL, "/") + route
 }
 
 // updateStats 更新站点统计数据
@@ -426,7 +682,7 @@ func (pm *PreheatManager) TriggerPreheatForURL(url string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	logging.DefaultLogger.Info("Starting preheat for single URL: %s", url)
+	logger.Info("Starting preheat for single URL: %s", url)
 
 	// 调用引擎的Render方法，这将自动缓存渲染结果
 	resultWithCache, err := pm.engine.Render(ctx, url, RenderOptions{
@@ -435,27 +691,37 @@ func (pm *PreheatManager) TriggerPreheatForURL(url string) error {
 	})
 
 	if err != nil {
-		logging.DefaultLogger.Error("Preheat failed for URL %s: %v", url, err)
+		logger.Error("Preheat failed for URL %s: %v", url, err)
 		// 更新URL状态为failed
 		pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "failed", 0)
 		return err
 	}
 
 	if !resultWithCache.Result.Success {
-		logging.DefaultLogger.Error("Render failed for URL %s: %s", url, resultWithCache.Result.Error)
+		logger.Error("Render failed for URL %s: %s", url, resultWithCache.Result.Error)
 		// 更新URL状态为failed
 		pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "failed", 0)
 		return fmt.Errorf("render failed: %s", resultWithCache.Result.Error)
 	}
 
 	// 渲染成功，更新URL状态为cached
-	cacheSize := int64(len(resultWithCache.Result.HTML))
-	pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "cached", cacheSize)
+	cacheSize := pm.recordCached(url, resultWithCache.Result.HTML)
 
-	logging.DefaultLogger.Info("Successfully preheated URL: %s (size: %d bytes)", url, cacheSize)
+	logger.Info("Successfully preheated URL: %s (size: %d bytes)", url, cacheSize)
 	return nil
 }
 
+// recordCached 将预热成功的URL标记为cached，并记录渲染内容的哈希供推送判断内容是否变化，返回缓存大小
+func (pm *PreheatManager) recordCached(url, html string) int64 {
+	cacheSize := int64(len(html))
+	pm.redisClient.SetURLPreheatStatus(pm.engine.SiteName, url, "cached", cacheSize)
+	sum := sha256.Sum256([]byte(html))
+	if err := pm.redisClient.SetURLContentHash(pm.engine.SiteName, url, hex.EncodeToString(sum[:])); err != nil {
+		logger.Warn("Failed to record content hash for URL %s: %v", url, err)
+	}
+	return cacheSize
+}
+
 // GetStats 获取站点预热统计数据
 func (pm *PreheatManager) GetStats() (map[string]string, error) {
 	// 检查Redis客户端是否可用
@@ -478,30 +744,81 @@ func (pm *PreheatManager) IsRunning() bool {
 	return pm.isRunning
 }
 
-// GetStatus 获取预热状态
-func (pm *PreheatManager) GetStatus() map[string]interface{} {
+// GetStatus 获取当前或最近一次预热任务的进度，从未执行过预热时返回nil
+// 内存中没有任务时从Redis恢复，重启前仍在运行的任务标记为interrupted
+func (pm *PreheatManager) GetStatus() *PreheatJob {
 	pm.mutex.Lock()
-	defer pm.mutex.Unlock()
-	return map[string]interface{}{
-		"isRunning": pm.isRunning,
+	job := pm.job
+	pm.mutex.Unlock()
+	if job != nil {
+		return job.Snapshot()
+	}
+
+	if pm.redisClient == nil {
+		return nil
+	}
+	data, err := pm.redisClient.GetPreheatJob(pm.engine.SiteName)
+	if err != nil {
+		return nil
+	}
+	var saved PreheatJob
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		logger.Warn("Failed to decode preheat job for site %s: %v", pm.engine.SiteName, err)
+		return nil
+	}
+	if saved.Status == PreheatJobRunning {
+		saved.Status = PreheatJobInterrupted
 	}
+	return &saved
+}
+
+// Cancel 取消正在运行的预热任务，没有运行中的任务时返回false
+func (pm *PreheatManager) Cancel() bool {
+	pm.mutex.Lock()
+	running := pm.isRunning
+	pm.cancelLocked()
+	pm.isRunning = false
+	pm.currentTaskID = ""
+	pm.mutex.Unlock()
+	return running
 }
 
 // Stop 停止预热
 func (pm *PreheatManager) Stop() {
+	pm.Cancel()
+
 	pm.mutex.Lock()
 	defer pm.mutex.Unlock()
+	if pm.preheatWorker != nil {
+		pm.preheatWorker.Stop()
+	}
+}
 
+// cancelLocked 取消当前预热任务并标记为canceled，调用方需持有锁
+func (pm *PreheatManager) cancelLocked() {
+	if pm.cancel != nil {
+		pm.cancel()
+		pm.cancel = nil
+	}
 	if pm.crawler != nil {
 		pm.crawler.Stop()
 	}
-
-	if pm.preheatWorker != nil {
-		pm.preheatWorker.Stop()
+	if pm.job != nil && pm.job.finish(PreheatJobCanceled) {
+		if pm.redisClient != nil {
+			pm.redisClient.SetPreheatTaskStatus(pm.engine.SiteName, pm.job.ID, PreheatJobCanceled)
+		}
+		pm.saveJob(pm.job)
 	}
+}
 
-	pm.isRunning = false
-	pm.currentTaskID = ""
+// saveJob 将预热任务进度持久化到Redis，服务重启后仍可查询
+func (pm *PreheatManager) saveJob(job *PreheatJob) {
+	if pm.redisClient == nil {
+		return
+	}
+	if err := pm.redisClient.SetPreheatJob(pm.engine.SiteName, job.Snapshot()); err != nil {
+		logger.Warn("Failed to save preheat job for site %s: %v", pm.engine.SiteName, err)
+	}
 }
 
 // NewEngine 创建新的渲染预热引擎
@@ -515,6 +832,13 @@ func NewEngine(siteName string, config PrerenderConfig, redisClient *redis.Clien
 	if config.MaxPoolSize == 0 {
 		config.MaxPoolSize = config.PoolSize * 2 // 默认最大浏览器数为初始值的2倍
 	}
+	if config.MaxQueueWait <= 0 {
+		config.MaxQueueWait = defaultMaxQueueWait
+	}
+	// 空闲浏览器通道按最大浏览器数分配，需要能容纳最小数量的浏览器
+	if config.MaxPoolSize < config.MinPoolSize {
+		config.MaxPoolSize = config.MinPoolSize
+	}
 
 	// 默认爬虫协议头列表
 	defaultCrawlerHeaders := []string{
@@ -555,6 +879,9 @@ func NewEngine(siteName string, config PrerenderConfig, redisClient *redis.Clien
 		activeTasks:           0,
 		defaultCrawlerHeaders: defaultCrawlerHeaders,
 		redisClient:           redisClient,
+		inflight:              make(map[string]*inflightRender),
+		urlNormalizer:         NewURLNormalizer(config.IgnoredQueryParams, config.SignificantQueryParams, config.CollapseTrailingSlash),
+		launchBrowser:         launchRodBrowser,
 	}
 
 	return engine, nil
@@ -600,6 +927,27 @@ func (em *EngineManager) AddSite(siteName string, config PrerenderConfig, redisC
 	return nil
 }
 
+// ReplaceSite 使用新配置重建站点引擎，新引擎启动成功后才替换并停止旧引擎，站点不存在时直接添加
+func (em *EngineManager) ReplaceSite(siteName string, config PrerenderConfig, redisClient *redis.Client) error {
+	engine, err := NewEngine(siteName, config, redisClient, em.staticDir)
+	if err != nil {
+		return err
+	}
+	if err := engine.Start(); err != nil {
+		return err
+	}
+
+	em.mutex.Lock()
+	oldEngine := em.engines[siteName]
+	em.engines[siteName] = engine
+	em.mutex.Unlock()
+
+	if oldEngine != nil {
+		oldEngine.Stop()
+	}
+	return nil
+}
+
 // RemoveSite 移除站点
 func (em *EngineManager) RemoveSite(siteName string) error {
 	em.mutex.Lock()
@@ -627,6 +975,17 @@ func (em *EngineManager) GetEngine(siteName string) (*Engine, bool) {
 	return engine, exists
 }
 
+// UpdateCrawlerHeaders 更新指定站点的自定义爬虫协议头，返回合并后的完整列表
+func (em *EngineManager) UpdateCrawlerHeaders(siteName string, headers []string) ([]string, error) {
+	engine, exists := em.GetEngine(siteName)
+	if !exists {
+		return nil, fmt.Errorf("site %s not found", siteName)
+	}
+
+	engine.SetCrawlerHeaders(headers)
+	return engine.GetCrawlerHeaders(), nil
+}
+
 // ListSites 列出所有站点
 func (em *EngineManager) ListSites() []string {
 	em.mutex.RLock()
@@ -723,7 +1082,7 @@ func (em *EngineManager) checkAutoPreheat() {
 				go func(url string) {
 					if err := engine.preheatManager.TriggerPreheatForURL(url); err != nil {
 						// Log error but continue with other URLs
-						logging.DefaultLogger.Error("Auto-preheat failed for URL %s: %v", url, err)
+						logger.Error("Auto-preheat failed for URL %s: %v", url, err)
 					}
 				}(url)
 			}
@@ -792,6 +1151,7 @@ func (e *Engine) Start() error {
 	// 启动浏览器健康检查
 	e.startHealthCheck()
 
+	engineMetrics.add(e)
 	e.isRunning = true
 	return nil
 }
@@ -811,12 +1171,19 @@ func (e *Engine) Stop() error {
 		e.healthCheckTicker = nil
 	}
 
+	engineMetrics.remove(e)
+
 	// 取消上下文
 	e.cancel()
 
 	// 等待工作协程结束
 	e.workerWg.Wait()
 
+	// 清理进行中的渲染映射，等待者会通过引擎上下文感知到停止
+	e.inflightMutex.Lock()
+	e.inflight = make(map[string]*inflightRender)
+	e.inflightMutex.Unlock()
+
 	// 关闭浏览器池
 	e.closeBrowserPool()
 
@@ -879,12 +1246,24 @@ func (e *Engine) isPaymentReturn(url string) bool {
 
 // RenderResultWithCache 包含缓存命中信息的渲染结果
 type RenderResultWithCache struct {
-	Result   *RenderResult
-	HitCache bool
+	Result        *RenderResult
+	HitCache      bool
+	NormalizedURL string // 实际用于渲染和缓存的规范化URL
+}
+
+// NormalizeURL 按站点配置规范化URL
+func (e *Engine) NormalizeURL(url string) string {
+	if e.urlNormalizer == nil {
+		return url
+	}
+	return e.urlNormalizer.Normalize(url)
 }
 
 // Render 执行渲染任务
 func (e *Engine) Render(ctx context.Context, url string, options RenderOptions) (*RenderResultWithCache, error) {
+	// 规范化URL，避免跟踪参数、片段和参数顺序导致重复渲染和缓存
+	url = e.NormalizeURL(url)
+
 	// 检查是否为静态资源或支付返回页面，如果是则跳过渲染预热
 	if e.isStaticResource(url) || e.isPaymentReturn(url) {
 		// 直接返回空结果或跳过渲染预热，这里返回一个特殊的成功结果表示不需要渲染预热
@@ -894,10 +1273,19 @@ func (e *Engine) Render(ctx context.Context, url string, options RenderOptions)
 				Success: true,
 				Error:   "",
 			},
-			HitCache: false,
+			HitCache:      false,
+			NormalizedURL: url,
 		}, nil
 	}
 
+	start := time.Now()
+	result, err := e.renderPage(ctx, url, options)
+	observeRender(e.SiteName, start, result, err)
+	return result, err
+}
+
+// renderPage 依次从缓存、本地静态文件和浏览器获取规范化URL的渲染结果
+func (e *Engine) renderPage(ctx context.Context, url string, options RenderOptions) (*RenderResultWithCache, error) {
 	// 构建缓存键
 	cacheKey := fmt.Sprintf("prerender:%s:content:%s", e.SiteName, url)
 
@@ -913,7 +1301,8 @@ func (e *Engine) Render(ctx context.Context, url string, options RenderOptions)
 					Success: true,
 					Error:   "",
 				},
-				HitCache: true,
+				HitCache:      true,
+				NormalizedURL: url,
 			}, nil
 		}
 	}
@@ -944,20 +1333,24 @@ func (e *Engine) Render(ctx context.Context, url string, options RenderOptions)
 		// 获取实际路径（移除hash部分）
 		actualPath := getActualPath(path)
 
-		// 构建文件路径
-		filePath := staticDir + actualPath
+		// 构建文件路径，路径超出静态目录时不读取本地文件
+		var htmlContent []byte
+		filePath, err := utils.SecurePath(staticDir, actualPath)
+		if err == nil {
+			// 如果路径是目录或不存在，尝试添加index.html
+			if info, statErr := os.Stat(filePath); statErr != nil || info.IsDir() {
+				filePath = filepath.Join(filePath, "index.html")
+			}
 
-		// 如果路径是目录或不存在，尝试添加index.html
-		if info, err := os.Stat(filePath); err != nil || info.IsDir() {
-			filePath = filepath.Join(staticDir, actualPath, "index.html")
+			// 读取文件内容
+			htmlContent, err = os.ReadFile(filePath)
 		}
-
-		// 读取文件内容
-		htmlContent, err := os.ReadFile(filePath)
 		if err == nil {
 			// 成功读取文件，将内容返回并缓存
 			htmlStr := string(htmlContent)
-			if e.redisClient != nil {
+			noIndex := hasNoIndexMeta(htmlStr)
+			// noindex页面不写入缓存
+			if e.redisClient != nil && !noIndex {
 				// 将渲染结果存入Redis缓存
 				cacheTTL := time.Duration(e.config.CacheTTL) * time.Second
 				e.redisClient.GetRawClient().Set(e.ctx, cacheKey, htmlStr, cacheTTL).Err()
@@ -969,12 +1362,75 @@ func (e *Engine) Render(ctx context.Context, url string, options RenderOptions)
 					HTML:    htmlStr,
 					Success: true,
 					Error:   "",
+					NoIndex: noIndex,
 				},
-				HitCache: false,
+				HitCache:      false,
+				NormalizedURL: url,
 			}, nil
 		}
 	}
 
+	// 合并相同URL的并发渲染请求，避免缓存未命中时同时占用多个浏览器
+	flight := e.joinRender(url, options, cacheKey)
+
+	select {
+	case <-flight.done:
+		return &RenderResultWithCache{
+			Result:        flight.result,
+			HitCache:      false,
+			NormalizedURL: url,
+		}, flight.err
+	case <-ctx.Done():
+		// 调用方取消时仅自身退出，不影响共享的渲染任务
+		return &RenderResultWithCache{
+			Result:        &RenderResult{Success: false, Error: "context canceled"},
+			HitCache:      false,
+			NormalizedURL: url,
+		}, ctx.Err()
+	case <-e.ctx.Done():
+		return &RenderResultWithCache{
+			Result:        &RenderResult{Success: false, Error: "engine stopped"},
+			HitCache:      false,
+			NormalizedURL: url,
+		}, nil
+	}
+}
+
+// joinRender 加入或创建指定URL的共享渲染任务
+func (e *Engine) joinRender(url string, options RenderOptions, cacheKey string) *inflightRender {
+	// 传入的URL已经过规范化，直接作为合并键
+	key := url
+
+	e.inflightMutex.Lock()
+	if flight, exists := e.inflight[key]; exists {
+		e.inflightMutex.Unlock()
+		atomic.AddInt64(&e.coalescedRequests, 1)
+		renderCoalescedTotal.WithLabelValues(e.SiteName).Inc()
+		return flight
+	}
+	flight := &inflightRender{done: make(chan struct{})}
+	e.inflight[key] = flight
+	e.inflightMutex.Unlock()
+
+	// 共享渲染使用引擎上下文，与单个调用方的上下文解耦
+	go func() {
+		flight.result, flight.err = e.executeRender(url, options, cacheKey)
+
+		e.inflightMutex.Lock()
+		if e.inflight[key] == flight {
+			delete(e.inflight, key)
+		}
+		e.inflightMutex.Unlock()
+
+		close(flight.done)
+	}()
+
+	return flight
+}
+
+// executeRender 提交渲染任务并等待结果，成功时写入缓存
+// 队列在MaxQueueWait内一直处于满载状态时返回ErrRenderQueueFull
+func (e *Engine) executeRender(url string, options RenderOptions, cacheKey string) (*RenderResult, error) {
 	// 创建渲染任务
 	task := &RenderTask{
 		ID:      uuid.New().String(),
@@ -983,45 +1439,105 @@ func (e *Engine) Render(ctx context.Context, url string, options RenderOptions)
 		Result:  make(chan *RenderResult, 1),
 	}
 
-	// 发送到任务队列
+	// 发送到任务队列，队列已满时最多等待MaxQueueWait
+	queueWait := time.NewTimer(e.config.MaxQueueWait)
+	defer queueWait.Stop()
 	select {
 	case e.taskQueue <- task:
-		// 等待结果
-		select {
-		case result := <-task.Result:
-			if result.Success && result.HTML != "" && e.redisClient != nil {
-				// 将渲染结果存入Redis缓存
-				cacheTTL := time.Duration(e.config.CacheTTL) * time.Second
-				e.redisClient.GetRawClient().Set(e.ctx, cacheKey, result.HTML, cacheTTL).Err()
-				// 更新URL状态为cached
-				e.redisClient.SetURLPreheatStatus(e.SiteName, url, "cached", int64(len(result.HTML)))
-			}
-			return &RenderResultWithCache{
-				Result:   result,
-				HitCache: false,
-			}, nil
-		case <-ctx.Done():
-			return &RenderResultWithCache{
-				Result:   &RenderResult{Success: false, Error: "context canceled"},
-				HitCache: false,
-			}, ctx.Err()
-		case <-e.ctx.Done():
-			return &RenderResultWithCache{
-				Result:   &RenderResult{Success: false, Error: "engine stopped"},
-				HitCache: false,
-			}, nil
+	case <-queueWait.C:
+		renderQueueFullTotal.WithLabelValues(e.SiteName).Inc()
+		logger.Warn("Render queue full for site %s, skipping %s", e.SiteName, url)
+		return &RenderResult{Success: false, Error: ErrRenderQueueFull.Error()}, ErrRenderQueueFull
+	case <-e.ctx.Done():
+		return &RenderResult{Success: false, Error: "engine stopped"}, nil
+	}
+
+	// 等待结果
+	select {
+	case result, ok := <-task.Result:
+		if !ok || result == nil {
+			return &RenderResult{Success: false, Error: "render task aborted"}, nil
 		}
-	case <-ctx.Done():
-		return &RenderResultWithCache{
-			Result:   &RenderResult{Success: false, Error: "context canceled"},
-			HitCache: false,
-		}, ctx.Err()
+		// noindex页面和源站错误页面不写入缓存，由调用方决定如何响应
+		if result.Success && result.HTML != "" && !result.NoIndex && e.isCacheableStatus(result.StatusCode) && e.redisClient != nil {
+			// 将渲染结果存入Redis缓存
+			cacheTTL := time.Duration(e.config.CacheTTL) * time.Second
+			e.redisClient.GetRawClient().Set(e.ctx, cacheKey, result.HTML, cacheTTL).Err()
+			// 更新URL状态为cached
+			e.redisClient.SetURLPreheatStatus(e.SiteName, url, "cached", int64(len(result.HTML)))
+		}
+		return result, nil
 	case <-e.ctx.Done():
-		return &RenderResultWithCache{
-			Result:   &RenderResult{Success: false, Error: "engine stopped"},
-			HitCache: false,
-		}, nil
+		return &RenderResult{Success: false, Error: "engine stopped"}, nil
+	}
+}
+
+// isCacheableStatus 判断源站状态码的渲染结果是否可以缓存，4xx、5xx和配置的状态码不缓存
+func (e *Engine) isCacheableStatus(status int) bool {
+	if status >= http.StatusBadRequest {
+		return false
+	}
+	for _, noCache := range e.config.NoCacheStatuses {
+		if status == noCache {
+			return false
+		}
 	}
+	return true
+}
+
+// isHTMLContentType 判断页面响应的Content-Type是否为配置的HTML类型，忽略参数和大小写
+func (e *Engine) isHTMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	allowed := e.config.HTMLContentTypes
+	if len(allowed) == 0 {
+		allowed = defaultHTMLContentTypes
+	}
+	for _, htmlType := range allowed {
+		if strings.EqualFold(mediaType, strings.TrimSpace(htmlType)) {
+			return true
+		}
+	}
+	return false
+}
+
+// EngineHealth 渲染预热引擎的运行状态
+type EngineHealth struct {
+	Running         bool `json:"running"`
+	Browsers        int  `json:"browsers"`         // 浏览器池中的浏览器数
+	HealthyBrowsers int  `json:"healthy_browsers"` // 健康的浏览器数
+	IdleBrowsers    int  `json:"idle_browsers"`    // 空闲的浏览器数
+	ActiveTasks     int  `json:"active_tasks"`     // 正在渲染的任务数
+	QueuedTasks     int  `json:"queued_tasks"`     // 等待分发的渲染任务数
+}
+
+// Health 返回引擎当前的运行状态
+func (e *Engine) Health() EngineHealth {
+	e.mutex.RLock()
+	health := EngineHealth{
+		Running:  e.isRunning,
+		Browsers: len(e.browserPool),
+	}
+	for _, browser := range e.browserPool {
+		if browser.Healthy {
+			health.HealthyBrowsers++
+		}
+	}
+	e.mutex.RUnlock()
+
+	health.IdleBrowsers = len(e.idleBrowsers)
+	health.QueuedTasks = len(e.taskQueue)
+	e.taskMutex.RLock()
+	health.ActiveTasks = e.activeTasks
+	e.taskMutex.RUnlock()
+	return health
+}
+
+// GetCoalescedRequests 获取被合并的渲染请求数
+func (e *Engine) GetCoalescedRequests() int64 {
+	return atomic.LoadInt64(&e.coalescedRequests)
 }
 
 // TriggerPreheat 触发缓存预热
@@ -1041,18 +1557,26 @@ func (e *Engine) TriggerPreheatWithURL(baseURL, domain string) (string, error) {
 	return e.preheatManager.TriggerPreheatWithURL(baseURL, domain)
 }
 
-// GetPreheatStatus 获取预热状态
-func (e *Engine) GetPreheatStatus() map[string]interface{} {
+// GetPreheatStatus 获取当前或最近一次预热任务的进度，从未执行过预热时返回nil
+func (e *Engine) GetPreheatStatus() *PreheatJob {
 	if e.preheatManager == nil {
-		return map[string]interface{}{
-			"isRunning": false,
-		}
+		return nil
 	}
 	return e.preheatManager.GetStatus()
 }
 
+// CancelPreheat 取消正在运行的预热任务，没有运行中的任务时返回false
+func (e *Engine) CancelPreheat() bool {
+	if e.preheatManager == nil {
+		return false
+	}
+	return e.preheatManager.Cancel()
+}
+
 // GetConfig 获取引擎配置
 func (e *Engine) GetConfig() PrerenderConfig {
+	e.headersMutex.RLock()
+	defer e.headersMutex.RUnlock()
 	return e.config
 }
 
@@ -1063,8 +1587,13 @@ func (e *Engine) GetPreheatManager() *PreheatManager {
 
 // GetCrawlerHeaders 获取完整的爬虫协议头列表
 func (e *Engine) GetCrawlerHeaders() []string {
+	e.headersMutex.RLock()
+	defer e.headersMutex.RUnlock()
+
 	// 合并默认爬虫协议头和配置中的爬虫协议头
-	allHeaders := append(e.defaultCrawlerHeaders, e.config.CrawlerHeaders...)
+	allHeaders := make([]string, 0, len(e.defaultCrawlerHeaders)+len(e.config.CrawlerHeaders))
+	allHeaders = append(allHeaders, e.defaultCrawlerHeaders...)
+	allHeaders = append(allHeaders, e.config.CrawlerHeaders...)
 
 	// 去重
 	uniqueHeaders := make([]string, 0, len(allHeaders))
@@ -1079,23 +1608,92 @@ func (e *Engine) GetCrawlerHeaders() []string {
 	return uniqueHeaders
 }
 
+// GetDefaultCrawlerHeaders 获取默认爬虫协议头列表
+func (e *Engine) GetDefaultCrawlerHeaders() []string {
+	return append([]string(nil), e.defaultCrawlerHeaders...)
+}
+
+// GetCustomCrawlerHeaders 获取站点自定义的爬虫协议头列表
+func (e *Engine) GetCustomCrawlerHeaders() []string {
+	e.headersMutex.RLock()
+	defer e.headersMutex.RUnlock()
+	return append([]string(nil), e.config.CrawlerHeaders...)
+}
+
+// SetCrawlerHeaders 更新站点自定义的爬虫协议头列表，去除空白和重复项
+func (e *Engine) SetCrawlerHeaders(headers []string) {
+	cleaned := make([]string, 0, len(headers))
+	seen := make(map[string]bool)
+	for _, header := range headers {
+		header = strings.TrimSpace(header)
+		if header == "" || seen[header] {
+			continue
+		}
+		seen[header] = true
+		cleaned = append(cleaned, header)
+	}
+
+	e.headersMutex.Lock()
+	e.config.CrawlerHeaders = cleaned
+	e.headersMutex.Unlock()
+}
+
 // IsCrawlerRequest 检查请求是否来自爬虫
 func (e *Engine) IsCrawlerRequest(userAgent string) bool {
-	crawlerHeaders := e.GetCrawlerHeaders()
+	_, matched := e.MatchCrawlerHeader(userAgent)
+	return matched
+}
 
-	// 如果没有配置爬虫协议头，默认返回false
-	if len(crawlerHeaders) == 0 {
-		return false
-	}
+// MatchCrawlerHeader 返回User-Agent匹配到的第一个爬虫协议头
+func (e *Engine) MatchCrawlerHeader(userAgent string) (string, bool) {
+	lowerUA := strings.ToLower(userAgent)
 
 	// 检查User-Agent是否包含任何爬虫协议头
-	for _, header := range crawlerHeaders {
-		if strings.Contains(strings.ToLower(userAgent), strings.ToLower(header)) {
-			return true
+	for _, header := range e.GetCrawlerHeaders() {
+		if header == "" {
+			continue
+		}
+		if strings.Contains(lowerUA, strings.ToLower(header)) {
+			return header, true
 		}
 	}
 
-	return false
+	return "", false
+}
+
+// launchRodBrowser 启动无头浏览器并建立连接
+func launchRodBrowser() (*rod.Browser, error) {
+	launchOpts := launcher.New()
+	// 优先使用我们安装的浏览器路径
+	if _, err := os.Stat("./browser/chrome"); err == nil {
+		launchOpts.Set("executablePath", "./browser/chrome")
+	} else if _, err := os.Stat("./browser/chromium"); err == nil {
+		launchOpts.Set("executablePath", "./browser/chromium")
+	}
+	launchOpts.Set("headless")
+	launchOpts.Set("no-sandbox")
+	launchOpts.Set("disable-dev-shm-usage")
+	launchOpts.Set("disable-gpu")
+	launchOpts.Set("disable-setuid-sandbox")
+	launchOpts.Set("single-process")
+	launchOpts.Set("disable-accelerated-2d-canvas")
+	launchOpts.Set("disable-javascript-harmony")
+	launchOpts.Set("disable-features", "site-per-process")
+	launchOpts.Set("ignore-certificate-errors")
+	launchOpts.Set("disable-web-security")
+
+	// 启动浏览器
+	browserURL, err := launchOpts.Launch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to launch browser: %v", err)
+	}
+
+	// 连接到浏览器
+	rodBrowser := rod.New().ControlURL(browserURL)
+	if err := rodBrowser.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to browser: %v", err)
+	}
+	return rodBrowser, nil
 }
 
 // initBrowserPool 初始化浏览器池
@@ -1104,35 +1702,9 @@ func (e *Engine) initBrowserPool() error {
 
 	for i := 0; i < e.config.PoolSize; i++ {
 		// 启动一个新的浏览器实例
-		launchOpts := launcher.New()
-		// 优先使用我们安装的浏览器路径
-		if _, err := os.Stat("./browser/chrome"); err == nil {
-			launchOpts.Set("executablePath", "./browser/chrome")
-		} else if _, err := os.Stat("./browser/chromium"); err == nil {
-			launchOpts.Set("executablePath", "./browser/chromium")
-		}
-		launchOpts.Set("headless")
-		launchOpts.Set("no-sandbox")
-		launchOpts.Set("disable-dev-shm-usage")
-		launchOpts.Set("disable-gpu")
-		launchOpts.Set("disable-setuid-sandbox")
-		launchOpts.Set("single-process")
-		launchOpts.Set("disable-accelerated-2d-canvas")
-		launchOpts.Set("disable-javascript-harmony")
-		launchOpts.Set("disable-features", "site-per-process")
-		launchOpts.Set("ignore-certificate-errors")
-		launchOpts.Set("disable-web-security")
-
-		// 启动浏览器
-		browserURL, err := launchOpts.Launch()
+		rodBrowser, err := e.launchBrowser()
 		if err != nil {
-			return fmt.Errorf("failed to launch browser: %v", err)
-		}
-
-		// 连接到浏览器
-		rodBrowser := rod.New().ControlURL(browserURL)
-		if err := rodBrowser.Connect(); err != nil {
-			return fmt.Errorf("failed to connect to browser: %v", err)
+			return err
 		}
 
 		// 创建浏览器实例
@@ -1163,7 +1735,7 @@ func (e *Engine) closeBrowserPool() {
 		// 关闭实际的浏览器实例
 		if browser.Instance != nil {
 			if err := browser.Instance.Close(); err != nil {
-				logging.DefaultLogger.Warn("Failed to close browser %s: %v", browser.ID, err)
+				logger.Warn("Failed to close browser %s: %v", browser.ID, err)
 			}
 		}
 
@@ -1185,11 +1757,13 @@ func (e *Engine) startWorkers() {
 // startHealthCheck 启动浏览器健康检查
 func (e *Engine) startHealthCheck() {
 	// 每30秒检查一次浏览器健康状态
-	e.healthCheckTicker = time.NewTicker(30 * time.Second)
+	// 协程使用局部变量，Stop将healthCheckTicker置为nil时不会读取到nil
+	ticker := time.NewTicker(30 * time.Second)
+	e.healthCheckTicker = ticker
 	go func() {
 		for {
 			select {
-			case <-e.healthCheckTicker.C:
+			case <-ticker.C:
 				e.checkBrowsersHealth()
 			case <-e.ctx.Done():
 				return
@@ -1224,6 +1798,39 @@ func (e *Engine) checkBrowsersHealth() {
 			continue
 		}
 	}
+
+	// 浏览器崩溃或被移除后补足到最小数量
+	e.replenishPool()
+}
+
+// replenishPool 浏览器池少于MinPoolSize时启动新浏览器补足，启动失败时等待下一次健康检查重试
+func (e *Engine) replenishPool() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for len(e.browserPool) < e.config.MinPoolSize {
+		rodBrowser, err := e.launchBrowser()
+		if err != nil {
+			logger.Error("Failed to replenish browser pool for site %s (%d/%d): %v", e.SiteName, len(e.browserPool), e.config.MinPoolSize, err)
+			return
+		}
+
+		browser := &Browser{
+			ID:        fmt.Sprintf("browser-%d", time.Now().UnixNano()),
+			Status:    "available",
+			LastUsed:  time.Now(),
+			Healthy:   true,
+			CreatedAt: time.Now(),
+			Instance:  rodBrowser,
+		}
+		e.browserPool = append(e.browserPool, browser)
+		select {
+		case e.idleBrowsers <- browser:
+		default:
+			// 空闲通道已满时不阻塞，避免持锁等待
+		}
+		logger.Info("Replenished browser pool for site %s (%d/%d)", e.SiteName, len(e.browserPool), e.config.MinPoolSize)
+	}
 }
 
 // replaceBrowser 替换不健康的浏览器
@@ -1237,41 +1844,12 @@ func (e *Engine) replaceBrowser(index int, oldBrowser *Browser) {
 	}
 
 	// 启动一个新的浏览器实例
-	launchOpts := launcher.New()
-	// 优先使用我们安装的浏览器路径
-	if _, err := os.Stat("./browser/chrome"); err == nil {
-		launchOpts.Set("executablePath", "./browser/chrome")
-	} else if _, err := os.Stat("./browser/chromium"); err == nil {
-		launchOpts.Set("executablePath", "./browser/chromium")
-	}
-	launchOpts.Set("headless")
-	launchOpts.Set("no-sandbox")
-	launchOpts.Set("disable-dev-shm-usage")
-	launchOpts.Set("disable-gpu")
-	launchOpts.Set("disable-setuid-sandbox")
-	launchOpts.Set("single-process")
-	launchOpts.Set("disable-accelerated-2d-canvas")
-	launchOpts.Set("disable-javascript-harmony")
-	launchOpts.Set("disable-features", "site-per-process")
-	launchOpts.Set("ignore-certificate-errors")
-	launchOpts.Set("disable-web-security")
-
-	// 启动浏览器
-	browserURL, err := launchOpts.Launch()
+	rodBrowser, err := e.launchBrowser()
 	if err != nil {
 		// 如果启动失败，标记原浏览器为健康并返回
 		oldBrowser.Healthy = true
 		oldBrowser.ErrorCount = 0
-		return
-	}
-
-	// 连接到浏览器
-	rodBrowser := rod.New().ControlURL(browserURL)
-	if err := rodBrowser.Connect(); err != nil {
-		// 如果连接失败，标记原浏览器为健康并返回
-		oldBrowser.Healthy = true
-		oldBrowser.ErrorCount = 0
-		logging.DefaultLogger.Error("Failed to connect to browser: %v", err)
+		logger.Error("Failed to replace browser %s: %v", oldBrowser.ID, err)
 		return
 	}
 
@@ -1289,12 +1867,13 @@ func (e *Engine) replaceBrowser(index int, oldBrowser *Browser) {
 	// 关闭旧浏览器实例
 	if oldBrowser.Instance != nil {
 		if err := oldBrowser.Instance.Close(); err != nil {
-			logging.DefaultLogger.Warn("Failed to close old browser %s: %v", oldBrowser.ID, err)
+			logger.Warn("Failed to close old browser %s: %v", oldBrowser.ID, err)
 		}
 	}
 
 	// 替换浏览器
 	e.browserPool[index] = newBrowser
+	browserReplacementsTotal.WithLabelValues(e.SiteName).Inc()
 
 	// 将新浏览器添加到空闲通道
 	select {
@@ -1308,6 +1887,11 @@ func (e *Engine) replaceBrowser(index int, oldBrowser *Browser) {
 func (e *Engine) taskDispatcher() {
 	defer e.workerWg.Done()
 
+	if e.config.FairQueueing {
+		e.fairTaskDispatcher()
+		return
+	}
+
 	for {
 		select {
 		case task := <-e.taskQueue:
@@ -1326,6 +1910,59 @@ func (e *Engine) taskDispatcher() {
 	}
 }
 
+// fairTaskDispatcher 公平任务分发器
+// 等待空闲浏览器期间持续接收任务并按爬虫分组排队，有浏览器空闲时按分组轮询分配
+func (e *Engine) fairTaskDispatcher() {
+	e.runFairDispatch(func(browser *Browser, task *RenderTask) {
+		e.workerWg.Add(1)
+		go e.processTask(browser, task)
+	})
+}
+
+// runFairDispatch 执行公平分发循环，dispatch负责在指定浏览器上处理任务
+func (e *Engine) runFairDispatch(dispatch func(browser *Browser, task *RenderTask)) {
+	queue := newFairQueue()
+	maxPending := cap(e.taskQueue)
+
+	for {
+		// 队列为空时阻塞等待新任务
+		if queue.Len() == 0 {
+			select {
+			case task := <-e.taskQueue:
+				queue.Push(task)
+			case <-e.ctx.Done():
+				return
+			}
+		}
+
+		// 尽量收取已提交的任务，使各分组都能参与轮询
+	drain:
+		for queue.Len() < maxPending {
+			select {
+			case task := <-e.taskQueue:
+				queue.Push(task)
+			default:
+				break drain
+			}
+		}
+
+		// 待处理任务达到上限时不再接收新任务，由任务队列提供背压
+		intake := e.taskQueue
+		if queue.Len() >= maxPending {
+			intake = nil
+		}
+
+		select {
+		case task := <-intake:
+			queue.Push(task)
+		case browser := <-e.idleBrowsers:
+			dispatch(browser, queue.Pop())
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
 // processTask 处理渲染任务
 func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 	defer e.workerWg.Done()
@@ -1379,7 +2016,7 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 				browser.Healthy = false
 				browser.ErrorCount++
 				e.mutex.Unlock()
-				logging.DefaultLogger.Error("Render panic for URL %s: %v", task.URL, r)
+				logger.Error("Render panic for URL %s: %v", task.URL, r)
 			}
 		}()
 
@@ -1410,66 +2047,93 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 			browser.Healthy = false
 			browser.ErrorCount++
 			e.mutex.Unlock()
-			logging.DefaultLogger.Error("Failed to create page for URL %s: %v", task.URL, err)
+			logger.Error("Failed to create page for URL %s: %v", task.URL, err)
 			return
 		}
 
+		// 将任务超时上下文绑定到页面操作，超时后所有页面操作立即返回
+		rawPage := page
+		page = page.Context(taskCtx)
+
 		// 页面关闭防护，确保资源释放
 		pageClosed := false
 		defer func() {
+			// 渲染超时，计入浏览器错误次数
+			if !result.Success && taskCtx.Err() == context.DeadlineExceeded {
+				result.Success = false
+				result.Error = "render timeout"
+				e.mutex.Lock()
+				browser.ErrorCount++
+				e.mutex.Unlock()
+				logger.Warn("Render timeout for URL %s", task.URL)
+			}
 			if !pageClosed {
 				// 异步关闭页面，避免阻塞主流程
+				// 使用未绑定任务上下文的页面，确保超时后仍能关闭
 				go func() {
-					if err := page.Close(); err != nil {
-						logging.DefaultLogger.Warn("Failed to close page: %v", err)
+					if err := rawPage.Close(); err != nil {
+						logger.Warn("Failed to close page: %v", err)
 					}
 				}()
 			}
 		}()
 
-		// 导航到URL，增加超时控制
-		navigateDone := make(chan bool)
-		go func() {
-			defer close(navigateDone)
-			if err := page.Navigate(task.URL); err != nil {
-				select {
-				case <-taskCtx.Done():
-					// 上下文已取消，忽略错误
-				default:
-					err = fmt.Errorf("failed to navigate to %s: %v", task.URL, err)
-				}
-			}
-		}()
-
-		select {
-		case <-navigateDone:
-		case <-taskCtx.Done():
-			result.Error = "navigation timeout"
+		// 标记渲染请求，站点处理器对其按站点模式返回原始页面
+		if _, err := page.SetExtraHeaders([]string{RenderRequestHeader, "1"}); err != nil {
+			result.Error = fmt.Sprintf("failed to set render header: %v", err)
 			return
 		}
 
-		if err != nil {
-			result.Error = err.Error()
+		// 记录主框架文档响应的状态码和Content-Type，重定向时为最终页面的响应
+		var statusCode int64
+		var documentMutex sync.Mutex
+		var contentType string
+		var documentRequestID proto.NetworkRequestID
+		go page.EachEvent(func(ev *proto.NetworkResponseReceived) {
+			if ev.Type == proto.NetworkResourceTypeDocument && ev.FrameID == rawPage.FrameID {
+				atomic.StoreInt64(&statusCode, int64(ev.Response.Status))
+				documentMutex.Lock()
+				contentType = ev.Response.MIMEType
+				documentRequestID = ev.RequestID
+				documentMutex.Unlock()
+			}
+		})()
+
+		// 导航到URL，页面已绑定任务上下文，超时后立即返回
+		if err := page.Navigate(task.URL); err != nil {
+			result.Error = fmt.Sprintf("failed to navigate to %s: %v", task.URL, err)
 			return
 		}
 
 		// 等待页面加载完成，使用更安全的等待策略
-		waitDone := make(chan bool)
-		go func() {
-			defer close(waitDone)
-			// 使用多个等待策略，提高成功率
-			waitErr := page.WaitLoad()
-			if waitErr != nil {
-				logging.DefaultLogger.Warn("WaitLoad failed for %s, trying to wait for network idle: %v", task.URL, waitErr)
-				// 使用简单的等待策略，适用于hash模式
-				time.Sleep(1 * time.Second)
+		if err := page.WaitLoad(); err != nil {
+			if taskCtx.Err() != nil {
+				return
 			}
-		}()
+			logger.Warn("WaitLoad failed for %s, trying to wait for network idle: %v", task.URL, err)
+			// 使用简单的等待策略，适用于hash模式
+			time.Sleep(1 * time.Second)
+		}
 
-		select {
-		case <-waitDone:
-		case <-taskCtx.Done():
-			result.Error = "page load timeout"
+		// 页面不是HTML（如JSON接口或二进制文件）时不做HTML结构校验，直接返回not_html
+		documentMutex.Lock()
+		mimeType, requestID := contentType, documentRequestID
+		documentMutex.Unlock()
+		if mimeType != "" && !e.isHTMLContentType(mimeType) {
+			result.Error = RenderErrorNotHTML
+			result.ContentType = mimeType
+			result.StatusCode = int(atomic.LoadInt64(&statusCode))
+			if e.config.PassThroughNonHTML {
+				body, err := proto.NetworkGetResponseBody{RequestID: requestID}.Call(page)
+				if err != nil {
+					logger.Warn("Failed to get response body for %s: %v", task.URL, err)
+				} else if body.Base64Encoded {
+					result.Body, _ = base64.StdEncoding.DecodeString(body.Body)
+				} else {
+					result.Body = []byte(body.Body)
+				}
+			}
+			logger.Info("Skipping render of non-HTML page %s (%s)", task.URL, mimeType)
 			return
 		}
 
@@ -1489,7 +2153,7 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 			// 我们给它一个稍长的超时时间来检测空闲
 			if err := page.WaitIdle(time.Minute); err != nil {
 				// 如果WaitIdle超时或失败，回退到Sleep策略
-				logging.DefaultLogger.Warn("WaitIdle failed for %s: %v, fallback to sleep", task.URL, err)
+				logger.Warn("WaitIdle failed for %s: %v, fallback to sleep", task.URL, err)
 				time.Sleep(baseWaitTime + 1*time.Second)
 			}
 		case "networkidle2":
@@ -1507,29 +2171,8 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 			time.Sleep(baseWaitTime)
 		}
 
-		// 获取完整的HTML内容，增加超时控制
-		htmlDone := make(chan struct {
-			html string
-			err  error
-		})
-		go func() {
-			defer close(htmlDone)
-			html, err := page.HTML()
-			htmlDone <- struct {
-				html string
-				err  error
-			}{html, err}
-		}()
-
-		var html string
-		select {
-		case res := <-htmlDone:
-			html, err = res.html, res.err
-		case <-taskCtx.Done():
-			result.Error = "html extraction timeout"
-			return
-		}
-
+		// 获取完整的HTML内容，超时后立即返回
+		html, err := page.HTML()
 		if err != nil {
 			result.Error = fmt.Sprintf("failed to get html: %v", err)
 			return
@@ -1553,18 +2196,21 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 			// 允许只有body的情况
 		} else if !strings.Contains(lowerHTML, "<body") {
 			// 如果有html但没有body，也允许通过
-			logging.DefaultLogger.Warn("HTML missing body tag for URL %s", task.URL)
+			logger.Warn("HTML missing body tag for URL %s", task.URL)
 		}
 
 		// 标记页面已关闭，避免重复关闭
 		pageClosed = true
-		if err := page.Close(); err != nil {
-			logging.DefaultLogger.Warn("Failed to close page: %v", err)
+		if err := rawPage.Close(); err != nil {
+			logger.Warn("Failed to close page: %v", err)
 		}
 
 		// 成功获取HTML
 		result.HTML = html
 		result.Success = true
+		result.NoIndex = hasNoIndexMeta(html)
+		result.StatusCode = int(atomic.LoadInt64(&statusCode))
+		result.ContentType = mimeType
 	}()
 
 	// 更新浏览器状态并返回结果
@@ -1586,7 +2232,7 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 			// 如果通道已满，关闭该浏览器并创建新的
 			if browser.Instance != nil {
 				if err := browser.Instance.Close(); err != nil {
-					logging.DefaultLogger.Warn("Failed to close extra browser %s: %v", browser.ID, err)
+					logger.Warn("Failed to close extra browser %s: %v", browser.ID, err)
 				}
 			}
 			// 异步替换浏览器
@@ -1607,7 +2253,7 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 			// 异步关闭浏览器，避免阻塞主流程
 			go func() {
 				if err := browser.Instance.Close(); err != nil {
-					logging.DefaultLogger.Warn("Failed to close unhealthy browser %s: %v", browser.ID, err)
+					logger.Warn("Failed to close unhealthy browser %s: %v", browser.ID, err)
 				}
 			}()
 		}
@@ -1626,11 +2272,11 @@ func (e *Engine) processTask(browser *Browser, task *RenderTask) {
 	}
 
 	// 发送结果，使用非阻塞方式
+	// 结果通道带缓冲，超时后也要送达失败结果，避免等待方一直阻塞
 	select {
 	case task.Result <- result:
-	case <-taskCtx.Done():
-		// 如果任务上下文已取消，忽略结果
-		logging.DefaultLogger.Warn("Task context canceled, result ignored for URL %s", task.URL)
+	default:
+		logger.Warn("Result channel is full, result ignored for URL %s", task.URL)
 	}
 	close(task.Result)
 }
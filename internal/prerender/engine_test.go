@@ -0,0 +1,616 @@
+package prerender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/redis"
+)
+
+// TestRenderCoalescesConcurrentRequests 测试相同URL的并发渲染请求只生成一个渲染任务
+func TestRenderCoalescesConcurrentRequests(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1, CacheTTL: 60}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	const callers = 10
+	release := make(chan struct{})
+	taskCount := make(chan int, 1)
+
+	// 模拟任务分发器：收集任务，待所有调用方加入后再返回结果
+	go func() {
+		count := 0
+		var tasks []*RenderTask
+		for {
+			select {
+			case task := <-engine.taskQueue:
+				count++
+				tasks = append(tasks, task)
+			case <-release:
+				for _, task := range tasks {
+					task.Result <- &RenderResult{HTML: "<html><body>ok</body></html>", Success: true}
+					close(task.Result)
+				}
+				taskCount <- count
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	results := make([]*RenderResultWithCache, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			result, err := engine.Render(context.Background(), "http://Example.com/page", RenderOptions{})
+			if err != nil {
+				t.Errorf("Render returned error: %v", err)
+				return
+			}
+			results[index] = result
+		}(i)
+	}
+
+	// 等待所有调用方加入共享渲染
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.GetCoalescedRequests() < callers-1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if count := <-taskCount; count != 1 {
+		t.Errorf("Expected 1 render task, got %d", count)
+	}
+	if coalesced := engine.GetCoalescedRequests(); coalesced != callers-1 {
+		t.Errorf("Expected %d coalesced requests, got %d", callers-1, coalesced)
+	}
+	for i, result := range results {
+		if result == nil || !result.Result.Success || result.Result.HTML == "" {
+			t.Errorf("Caller %d did not receive the shared result", i)
+		}
+	}
+
+	engine.inflightMutex.Lock()
+	remaining := len(engine.inflight)
+	engine.inflightMutex.Unlock()
+	if remaining != 0 {
+		t.Errorf("Expected inflight map to be cleaned up, got %d entries", remaining)
+	}
+}
+
+// TestRenderCallerCancelDetaches 测试调用方取消后不影响共享渲染
+func TestRenderCallerCancelDetaches(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := engine.Render(ctx, "http://example.com/slow", RenderOptions{})
+		done <- err
+	}()
+
+	task := <-engine.taskQueue
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// 第二个调用方仍然可以加入尚未完成的共享渲染
+	second := make(chan *RenderResultWithCache, 1)
+	go func() {
+		result, _ := engine.Render(context.Background(), "http://example.com/slow", RenderOptions{})
+		second <- result
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for engine.GetCoalescedRequests() < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	task.Result <- &RenderResult{HTML: "<html><body>slow</body></html>", Success: true}
+	close(task.Result)
+
+	select {
+	case result := <-second:
+		if result == nil || !result.Result.Success {
+			t.Error("Expected shared render to complete successfully")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for shared render")
+	}
+}
+
+// TestRenderSignificantQueryParams 测试有效参数分别渲染，无关参数共享同一缓存
+func TestRenderSignificantQueryParams(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{
+		PoolSize:               1,
+		SignificantQueryParams: []string{"page"},
+	}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	// 模拟任务分发器：记录实际渲染的URL
+	renderedURLs := make(chan string, 10)
+	go func() {
+		for {
+			select {
+			case task := <-engine.taskQueue:
+				renderedURLs <- task.URL
+				task.Result <- &RenderResult{HTML: "<html><body>" + task.URL + "</body></html>", Success: true}
+				close(task.Result)
+			case <-engine.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	render := func(url string) *RenderResultWithCache {
+		result, err := engine.Render(context.Background(), url, RenderOptions{})
+		if err != nil || !result.Result.Success {
+			t.Fatalf("Render(%s) failed: %v", url, err)
+		}
+		return result
+	}
+
+	first := render("http://example.com/list?page=2&sessionid=a")
+	second := render("http://example.com/list?sessionid=b&page=2")
+	third := render("http://example.com/list?page=3&sessionid=a")
+
+	// 无关参数不影响规范化URL，因此使用同一缓存键
+	if first.NormalizedURL != "http://example.com/list?page=2" || second.NormalizedURL != first.NormalizedURL {
+		t.Errorf("Expected session variants to share cache URL, got %q and %q", first.NormalizedURL, second.NormalizedURL)
+	}
+	if third.NormalizedURL == first.NormalizedURL {
+		t.Errorf("Expected page=3 to render distinctly, got %q", third.NormalizedURL)
+	}
+
+	// 渲染时已去除无关参数
+	expected := []string{"http://example.com/list?page=2", "http://example.com/list?page=2", "http://example.com/list?page=3"}
+	for _, want := range expected {
+		if got := <-renderedURLs; got != want {
+			t.Errorf("Rendered URL %q, expected %q", got, want)
+		}
+	}
+}
+
+// TestUpdateCrawlerHeaders 测试编辑自定义爬虫协议头后IsCrawlerRequest能识别新的UA
+func TestUpdateCrawlerHeaders(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{CrawlerHeaders: []string{"Googlebot"}}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	manager := &EngineManager{engines: map[string]*Engine{"test-site": engine}}
+
+	customUA := "Mozilla/5.0 (compatible; MyCustomBot/1.0)"
+	if engine.IsCrawlerRequest(customUA) {
+		t.Fatalf("custom UA should not be recognized before editing")
+	}
+
+	headers, err := manager.UpdateCrawlerHeaders("test-site", []string{"Googlebot", " MyCustomBot ", "MyCustomBot", ""})
+	if err != nil {
+		t.Fatalf("UpdateCrawlerHeaders failed: %v", err)
+	}
+
+	if !engine.IsCrawlerRequest(customUA) {
+		t.Errorf("custom UA should be recognized after editing")
+	}
+	if got := engine.GetCustomCrawlerHeaders(); len(got) != 2 || got[1] != "MyCustomBot" {
+		t.Errorf("unexpected custom headers: %v", got)
+	}
+
+	// 合并后的列表应包含默认协议头和自定义协议头，且不重复
+	seen := make(map[string]bool)
+	for _, header := range headers {
+		if seen[header] {
+			t.Errorf("duplicate header in effective list: %s", header)
+		}
+		seen[header] = true
+	}
+	if !seen["MyCustomBot"] || !seen["Bingbot"] {
+		t.Errorf("effective list should contain defaults and custom headers: %v", headers)
+	}
+
+	if _, err := manager.UpdateCrawlerHeaders("missing-site", nil); err == nil {
+		t.Errorf("expected error for unknown site")
+	}
+}
+
+// TestMatchCrawlerHeader 测试检测User-Agent是否为爬虫并返回匹配的协议头
+func TestMatchCrawlerHeader(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	header, matched := engine.MatchCrawlerHeader("Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	if !matched || header != "Googlebot" {
+		t.Errorf("expected Googlebot to be matched, got %q, %v", header, matched)
+	}
+
+	header, matched = engine.MatchCrawlerHeader("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+	if matched || header != "" {
+		t.Errorf("expected browser UA not to be matched, got %q, %v", header, matched)
+	}
+}
+
+// TestReplenishPool 测试浏览器池低于最小数量时自动补足，启动失败时保持现状等待下次重试
+func TestReplenishPool(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1, MinPoolSize: 3}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	launches := 0
+	launchErr := error(nil)
+	engine.launchBrowser = func() (*rod.Browser, error) {
+		if launchErr != nil {
+			return nil, launchErr
+		}
+		launches++
+		return rod.New(), nil
+	}
+
+	engine.checkBrowsersHealth()
+	if len(engine.browserPool) != 3 || len(engine.idleBrowsers) != 3 {
+		t.Fatalf("expected pool to be filled to 3 browsers, got %d (%d idle)", len(engine.browserPool), len(engine.idleBrowsers))
+	}
+
+	// 模拟浏览器崩溃后被移出池
+	engine.browserPool = engine.browserPool[:1]
+	for len(engine.idleBrowsers) > 0 {
+		<-engine.idleBrowsers
+	}
+	engine.idleBrowsers <- engine.browserPool[0]
+
+	launchErr = errors.New("chrome not found")
+	engine.checkBrowsersHealth()
+	if len(engine.browserPool) != 1 {
+		t.Fatalf("expected pool to stay at 1 browser while launches fail, got %d", len(engine.browserPool))
+	}
+
+	launchErr = nil
+	engine.checkBrowsersHealth()
+	if len(engine.browserPool) != 3 || len(engine.idleBrowsers) != 3 || launches != 5 {
+		t.Errorf("expected pool to refill to 3 browsers, got %d (%d idle) after %d launches", len(engine.browserPool), len(engine.idleBrowsers), launches)
+	}
+}
+
+// TestRenderQueueFull 测试渲染队列已满时在MaxQueueWait后立即返回ErrRenderQueueFull，而不是一直阻塞
+func TestRenderQueueFull(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1, MaxQueueWait: 50 * time.Millisecond}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	// 没有分发器消费任务，填满队列
+	for i := 0; i < cap(engine.taskQueue); i++ {
+		engine.taskQueue <- &RenderTask{ID: "filler"}
+	}
+
+	start := time.Now()
+	result, err := engine.Render(context.Background(), "http://example.com/page", RenderOptions{})
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrRenderQueueFull) {
+		t.Fatalf("expected ErrRenderQueueFull, got %v", err)
+	}
+	if result.Result.Success || elapsed > time.Second {
+		t.Errorf("expected prompt failure, got success=%v after %v", result.Result.Success, elapsed)
+	}
+
+	// 失败的渲染不保留在合并映射中，队列空出后可以重新提交
+	<-engine.taskQueue
+	go func() {
+		task := <-engine.taskQueue
+		for task.ID == "filler" {
+			task = <-engine.taskQueue
+		}
+		task.Result <- &RenderResult{HTML: "<html>ok</html>", Success: true}
+	}()
+	result, err = engine.Render(context.Background(), "http://example.com/page", RenderOptions{})
+	if err != nil || !result.Result.Success {
+		t.Errorf("expected render to succeed once the queue has room, got %v, %+v", err, result.Result)
+	}
+}
+
+// TestRenderSkipsCachingErrorStatus 测试源站返回4xx/5xx或配置的状态码时不缓存渲染结果，并返回源站状态码
+func TestRenderSkipsCachingErrorStatus(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(mr.Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1, CacheTTL: 60, NoCacheStatuses: []int{203}}, redisClient, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	// 模拟任务分发器：按URL路径返回对应的源站状态码
+	statuses := map[string]int{"/error": 500, "/missing": 404, "/partial": 203, "/ok": 200}
+	go func() {
+		for task := range engine.taskQueue {
+			status := statuses[task.URL[strings.LastIndex(task.URL, "/"):]]
+			task.Result <- &RenderResult{HTML: "<html><body>page</body></html>", Success: true, StatusCode: status}
+		}
+	}()
+
+	for path, status := range statuses {
+		result, err := engine.Render(context.Background(), "http://example.com"+path, RenderOptions{})
+		if err != nil || !result.Result.Success {
+			t.Fatalf("render %s failed: %v", path, err)
+		}
+		if result.Result.StatusCode != status {
+			t.Errorf("render %s: expected status %d, got %d", path, status, result.Result.StatusCode)
+		}
+	}
+
+	for path, status := range statuses {
+		if cached := mr.Exists("prerender:test-site:content:http://example.com" + path); cached != (status == 200) {
+			t.Errorf("render %s with status %d: cached=%v", path, status, cached)
+		}
+	}
+}
+
+// TestRunPreheatPoolBoundsConcurrency 测试预热协程池同时执行的URL数不超过配置的并发数，且全部URL都被处理
+func TestRunPreheatPoolBoundsConcurrency(t *testing.T) {
+	const concurrency = 3
+	urls := make([]string, 50)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("/page-%d", i)
+	}
+
+	var active, maxActive, processed int64
+	runPreheatPool(context.Background(), urls, concurrency, func(url string) {
+		current := atomic.AddInt64(&active, 1)
+		for {
+			observed := atomic.LoadInt64(&maxActive)
+			if current <= observed || atomic.CompareAndSwapInt64(&maxActive, observed, current) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt64(&active, -1)
+		atomic.AddInt64(&processed, 1)
+	})
+
+	if processed != int64(len(urls)) {
+		t.Errorf("expected %d URLs processed, got %d", len(urls), processed)
+	}
+	if maxActive != concurrency {
+		t.Errorf("expected exactly %d concurrent preheats, got %d", concurrency, maxActive)
+	}
+
+	// 任务取消后不再分发剩余的URL
+	ctx, cancel := context.WithCancel(context.Background())
+	processed = 0
+	runPreheatPool(ctx, urls, concurrency, func(url string) {
+		if atomic.AddInt64(&processed, 1) == 5 {
+			cancel()
+		}
+	})
+	if processed >= int64(len(urls)) {
+		t.Errorf("expected dispatch to stop after cancel, processed %d", processed)
+	}
+}
+
+// TestPreheatCancelFinishesInFlightRenders 测试取消预热后已开始的渲染继续完成，不再分发新的URL
+func TestPreheatCancelFinishesInFlightRenders(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1, CacheTTL: 60}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+	pm := NewPreheatManager(engine, nil)
+
+	const concurrency = 2
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	var dispatched int64
+
+	// 模拟任务分发器：渲染在release关闭后才完成
+	go func() {
+		for {
+			select {
+			case task := <-engine.taskQueue:
+				atomic.AddInt64(&dispatched, 1)
+				started <- struct{}{}
+				go func() {
+					<-release
+					task.Result <- &RenderResult{HTML: "<html><body>ok</body></html>", Success: true}
+					close(task.Result)
+				}()
+			case <-engine.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("/page-%d", i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var rendered int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runPreheatPool(ctx, urls, concurrency, func(url string) {
+			result, err := pm.renderPreheatURL("http://example.com" + url)
+			if err == nil && result.Result.Success {
+				atomic.AddInt64(&rendered, 1)
+			}
+		})
+	}()
+
+	for i := 0; i < concurrency; i++ {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for renders to start")
+		}
+	}
+	cancel()
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("preheat pool did not stop after cancel")
+	}
+	if rendered != concurrency {
+		t.Errorf("expected %d in-flight renders to finish, got %d", concurrency, rendered)
+	}
+	if dispatched != concurrency {
+		t.Errorf("expected no new URLs after cancel, got %d renders", dispatched)
+	}
+}
+
+// TestPreheatExcludePatterns 测试预热跳过匹配站点排除规则的URL
+func TestPreheatExcludePatterns(t *testing.T) {
+	site := config.SiteConfig{ID: "site1", ExcludePatterns: []string{"/admin/", "/api/*", `^/login(\?|$)`}}
+	prerenderConfig := NewSitePrerenderConfig(site)
+
+	routes := []string{"/", "/admin/users", "/api/items", "/login", "/login?next=/", "/login-help", "/about"}
+	kept, excluded := excludeURLs(routes, prerenderConfig.ExcludePatterns)
+	if strings.Join(kept, ",") != "/,/login-help,/about" {
+		t.Errorf("unexpected preheat urls: %v", kept)
+	}
+	if excluded != 4 {
+		t.Errorf("expected 4 excluded urls, got %d", excluded)
+	}
+
+	if kept, excluded := excludeURLs(routes, nil); len(kept) != len(routes) || excluded != 0 {
+		t.Errorf("expected no urls excluded without patterns, got %v", kept)
+	}
+
+	// sitemap中匹配排除规则的URL不记录也不预热
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc></url>
+  <url><loc>https://example.com/admin/settings</loc></url>
+  <url><loc>https://example.com/about</loc></url>
+</urlset>`)
+	}))
+	defer server.Close()
+
+	mr := miniredis.RunT(t)
+	redisClient, err := redis.NewClient(mr.Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	engine, err := NewEngine("site1", prerenderConfig, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+	pm := NewPreheatManager(engine, redisClient)
+
+	sitemapRoutes, skipped, err := pm.ingestSitemap(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("ingestSitemap failed: %v", err)
+	}
+	if strings.Join(sitemapRoutes, ",") != "/,/about" || skipped != 1 {
+		t.Errorf("unexpected sitemap routes %v (skipped %d)", sitemapRoutes, skipped)
+	}
+	stored, _ := mr.Members("prerender:site1:urls")
+	stored = append(stored, mr.Keys()...)
+	for _, value := range stored {
+		if strings.Contains(value, "/admin/") {
+			t.Errorf("excluded url stored in redis: %s", value)
+		}
+	}
+}
+
+// TestRenderNotHTML 测试渲染JSON接口时返回not_html错误而不是HTML结构校验失败，并按配置携带原始内容
+func TestRenderNotHTML(t *testing.T) {
+	browserPath, found := launcher.LookPath()
+	if !found {
+		t.Skip("Skipping test that requires a local Chrome/Chromium")
+	}
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer origin.Close()
+
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1, Timeout: 10, PassThroughNonHTML: true}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	engine.launchBrowser = func() (*rod.Browser, error) {
+		controlURL, err := launcher.New().Bin(browserPath).Headless(true).NoSandbox(true).Launch()
+		if err != nil {
+			return nil, err
+		}
+		browser := rod.New().ControlURL(controlURL)
+		return browser, browser.Connect()
+	}
+	if err := engine.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer engine.Stop()
+
+	result, err := engine.Render(context.Background(), origin.URL+"/api/data", RenderOptions{})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if result.Result.Success || result.Result.Error != RenderErrorNotHTML {
+		t.Fatalf("expected %s error, got success=%v error=%q", RenderErrorNotHTML, result.Result.Success, result.Result.Error)
+	}
+	if result.Result.ContentType != "application/json" || string(result.Result.Body) != `{"ok":true}` {
+		t.Errorf("expected raw JSON body to pass through, got %q %q", result.Result.ContentType, result.Result.Body)
+	}
+}
+
+// TestIsHTMLContentType 测试Content-Type判断忽略参数和大小写，并支持自定义HTML类型
+func TestIsHTMLContentType(t *testing.T) {
+	engine := &Engine{}
+	for contentType, expected := range map[string]bool{
+		"text/html":                true,
+		"TEXT/HTML; charset=utf-8": true,
+		"application/xhtml+xml":    true,
+		"application/json":         false,
+		"application/octet-stream": false,
+	} {
+		if got := engine.isHTMLContentType(contentType); got != expected {
+			t.Errorf("isHTMLContentType(%q) = %v, expected %v", contentType, got, expected)
+		}
+	}
+
+	engine.config.HTMLContentTypes = []string{"application/vnd.custom+html"}
+	if engine.isHTMLContentType("text/html") || !engine.isHTMLContentType("application/vnd.custom+html") {
+		t.Errorf("expected configured HTML content types to replace the defaults")
+	}
+}
@@ -0,0 +1,77 @@
+package prerender
+
+import (
+	"strings"
+)
+
+// defaultCrawlerFamily 未识别爬虫或预热等内部任务使用的分组
+const defaultCrawlerFamily = "other"
+
+// fairQueue 按爬虫分组的公平队列
+// 每个爬虫分组维护独立的FIFO队列，出队时按分组轮询，
+// 避免单个激进爬虫的大量请求占满渲染池而饿死其他爬虫
+type fairQueue struct {
+	queues map[string][]*RenderTask // 分组 -> 待处理任务
+	order  []string                 // 有待处理任务的分组，按轮询顺序排列
+	size   int
+}
+
+// newFairQueue 创建公平队列
+func newFairQueue() *fairQueue {
+	return &fairQueue{
+		queues: make(map[string][]*RenderTask),
+	}
+}
+
+// Push 将任务加入其爬虫分组的队列
+func (q *fairQueue) Push(task *RenderTask) {
+	family := task.Options.Crawler
+	if family == "" {
+		family = defaultCrawlerFamily
+	}
+
+	if _, exists := q.queues[family]; !exists {
+		q.order = append(q.order, family)
+	}
+	q.queues[family] = append(q.queues[family], task)
+	q.size++
+}
+
+// Pop 按分组轮询取出下一个任务，队列为空时返回nil
+func (q *fairQueue) Pop() *RenderTask {
+	if q.size == 0 {
+		return nil
+	}
+
+	family := q.order[0]
+	tasks := q.queues[family]
+	task := tasks[0]
+	tasks[0] = nil
+
+	if len(tasks) == 1 {
+		// 该分组已无任务，从轮询顺序中移除
+		delete(q.queues, family)
+		q.order = q.order[1:]
+	} else {
+		// 该分组仍有任务，移到轮询末尾
+		q.queues[family] = tasks[1:]
+		q.order = append(q.order[1:], family)
+	}
+	q.size--
+
+	return task
+}
+
+// Len 返回队列中的任务总数
+func (q *fairQueue) Len() int {
+	return q.size
+}
+
+// CrawlerFamily 根据User-Agent识别爬虫分组，用于公平调度
+// 返回匹配到的第一个爬虫协议头（小写），未匹配时返回"other"
+func (e *Engine) CrawlerFamily(userAgent string) string {
+	if header, matched := e.MatchCrawlerHeader(userAgent); matched {
+		return strings.ToLower(header)
+	}
+	return defaultCrawlerFamily
+}
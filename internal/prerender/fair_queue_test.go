@@ -0,0 +1,106 @@
+package prerender
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestFairQueueRoundRobin 测试公平队列按爬虫分组轮询出队
+func TestFairQueueRoundRobin(t *testing.T) {
+	queue := newFairQueue()
+	for i := 0; i < 5; i++ {
+		queue.Push(&RenderTask{ID: fmt.Sprintf("g%d", i), Options: RenderOptions{Crawler: "googlebot"}})
+	}
+	queue.Push(&RenderTask{ID: "b0", Options: RenderOptions{Crawler: "bingbot"}})
+	queue.Push(&RenderTask{ID: "b1", Options: RenderOptions{Crawler: "bingbot"}})
+	queue.Push(&RenderTask{ID: "o0"})
+
+	var got []string
+	for queue.Len() > 0 {
+		got = append(got, queue.Pop().ID)
+	}
+
+	expected := []string{"g0", "b0", "o0", "g1", "b1", "g2", "g3", "g4"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("unexpected pop order: got %v, want %v", got, expected)
+	}
+	if queue.Pop() != nil {
+		t.Error("expected nil from empty queue")
+	}
+}
+
+// TestCrawlerFamily 测试根据User-Agent识别爬虫分组
+func TestCrawlerFamily(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{CrawlerHeaders: []string{"Googlebot", "bingbot"}}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	cases := map[string]string{
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)": "googlebot",
+		"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)":  "bingbot",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64)":                                defaultCrawlerFamily,
+	}
+	for ua, expected := range cases {
+		if got := engine.CrawlerFamily(ua); got != expected {
+			t.Errorf("CrawlerFamily(%q) = %q, want %q", ua, got, expected)
+		}
+	}
+}
+
+// TestFairDispatcherDoesNotStarveCrawlers 测试单个爬虫的突发请求不会饿死其他爬虫的并发请求
+func TestFairDispatcherDoesNotStarveCrawlers(t *testing.T) {
+	engine, err := NewEngine("test-site", PrerenderConfig{PoolSize: 1, MaxPoolSize: 1, FairQueueing: true}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+
+	const burst = 30
+	const others = 3
+
+	// 先提交激进爬虫的突发请求，再提交另一个爬虫的请求
+	for i := 0; i < burst; i++ {
+		engine.taskQueue <- &RenderTask{ID: fmt.Sprintf("google-%d", i), Options: RenderOptions{Crawler: "googlebot"}}
+	}
+	for i := 0; i < others; i++ {
+		engine.taskQueue <- &RenderTask{ID: fmt.Sprintf("bing-%d", i), Options: RenderOptions{Crawler: "bingbot"}}
+	}
+
+	// 模拟单个浏览器的渲染池：记录分发顺序，处理完成后归还浏览器
+	var order []string
+	done := make(chan struct{})
+	go engine.runFairDispatch(func(browser *Browser, task *RenderTask) {
+		order = append(order, task.Options.Crawler)
+		if len(order) == burst+others {
+			close(done)
+			return
+		}
+		engine.idleBrowsers <- browser
+	})
+	engine.idleBrowsers <- &Browser{ID: "fake"}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("dispatcher did not finish, dispatched %d tasks", len(order))
+	}
+
+	// 另一个爬虫的所有请求应在突发请求完成前得到处理
+	bingSeen, googleBefore := 0, 0
+	for _, crawler := range order {
+		if crawler == "bingbot" {
+			bingSeen++
+			if bingSeen == others {
+				break
+			}
+		} else {
+			googleBefore++
+		}
+	}
+	if googleBefore > others {
+		t.Errorf("bingbot requests were starved: %d googlebot tasks dispatched before all bingbot tasks", googleBefore)
+	}
+}
@@ -0,0 +1,193 @@
+package prerender
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 渲染预热引擎监控指标
+var (
+	renderCoalescedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prerender_render_coalesced_total",
+			Help: "Total number of render requests coalesced into an in-flight render",
+		},
+		[]string{"site"},
+	)
+
+	renderQueueFullTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prerender_render_queue_full_total",
+			Help: "Total number of renders rejected because the render queue stayed full",
+		},
+		[]string{"site"},
+	)
+
+	renderDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "prerender_render_duration_seconds",
+			Help:    "Duration of render requests in seconds, including cache lookups",
+			Buckets: []float64{0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30},
+		},
+		[]string{"site", "cache_hit"},
+	)
+
+	renderFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prerender_render_failures_total",
+			Help: "Total number of failed renders by error class",
+		},
+		[]string{"site", "class"},
+	)
+
+	browserReplacementsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prerender_browser_replacements_total",
+			Help: "Total number of browsers replaced because they were unhealthy, idle or too old",
+		},
+		[]string{"site"},
+	)
+)
+
+// 浏览器池和任务队列的实时指标，采集时从运行中的引擎读取
+var (
+	browserPoolSizeDesc = prometheus.NewDesc(
+		"prerender_browser_pool_size",
+		"Number of browsers in the pool",
+		[]string{"site"}, nil,
+	)
+	browserPoolIdleDesc = prometheus.NewDesc(
+		"prerender_browser_pool_idle",
+		"Number of idle browsers in the pool",
+		[]string{"site"}, nil,
+	)
+	activeTasksDesc = prometheus.NewDesc(
+		"prerender_render_active_tasks",
+		"Number of render tasks currently running in a browser",
+		[]string{"site"}, nil,
+	)
+	queueDepthDesc = prometheus.NewDesc(
+		"prerender_render_queue_depth",
+		"Number of render tasks waiting to be dispatched",
+		[]string{"site"}, nil,
+	)
+)
+
+// 渲染失败的错误分类
+const (
+	failureTimeout    = "timeout"
+	failureQueueFull  = "queue_full"
+	failureNotHTML    = "not_html"
+	failureBrowser    = "browser"
+	failureNavigation = "navigation"
+	failureContent    = "content"
+	failureCanceled   = "canceled"
+	failureOther      = "other"
+)
+
+// engineCollector 采集运行中引擎的浏览器池和任务队列状态
+type engineCollector struct {
+	mutex   sync.Mutex
+	engines map[string]*Engine // 站点名 -> 运行中的引擎
+}
+
+// engineMetrics 运行中引擎的指标采集器，引擎启动时注册、停止时注销
+var engineMetrics = &engineCollector{engines: make(map[string]*Engine)}
+
+func init() {
+	prometheus.MustRegister(
+		renderCoalescedTotal,
+		renderQueueFullTotal,
+		renderDuration,
+		renderFailuresTotal,
+		browserReplacementsTotal,
+		engineMetrics,
+	)
+}
+
+// add 注册引擎，同一站点重建引擎时新引擎替换旧引擎
+func (c *engineCollector) add(engine *Engine) {
+	c.mutex.Lock()
+	c.engines[engine.SiteName] = engine
+	c.mutex.Unlock()
+}
+
+// remove 注销引擎，站点已注册了新引擎时保留新引擎
+func (c *engineCollector) remove(engine *Engine) {
+	c.mutex.Lock()
+	if c.engines[engine.SiteName] == engine {
+		delete(c.engines, engine.SiteName)
+	}
+	c.mutex.Unlock()
+}
+
+// Describe 实现prometheus.Collector
+func (c *engineCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- browserPoolSizeDesc
+	ch <- browserPoolIdleDesc
+	ch <- activeTasksDesc
+	ch <- queueDepthDesc
+}
+
+// Collect 实现prometheus.Collector，先复制引擎列表再读取状态，避免与引擎启停的锁顺序相反
+func (c *engineCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	engines := make([]*Engine, 0, len(c.engines))
+	for _, engine := range c.engines {
+		engines = append(engines, engine)
+	}
+	c.mutex.Unlock()
+
+	for _, engine := range engines {
+		health := engine.Health()
+		ch <- prometheus.MustNewConstMetric(browserPoolSizeDesc, prometheus.GaugeValue, float64(health.Browsers), engine.SiteName)
+		ch <- prometheus.MustNewConstMetric(browserPoolIdleDesc, prometheus.GaugeValue, float64(health.IdleBrowsers), engine.SiteName)
+		ch <- prometheus.MustNewConstMetric(activeTasksDesc, prometheus.GaugeValue, float64(health.ActiveTasks), engine.SiteName)
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(health.QueuedTasks), engine.SiteName)
+	}
+}
+
+// observeRender 记录渲染耗时，渲染失败时按错误分类计数
+func observeRender(siteName string, start time.Time, result *RenderResultWithCache, err error) {
+	if result == nil {
+		return
+	}
+	renderDuration.WithLabelValues(siteName, strconv.FormatBool(result.HitCache)).Observe(time.Since(start).Seconds())
+	if err != nil || result.Result == nil || !result.Result.Success {
+		renderFailuresTotal.WithLabelValues(siteName, failureClass(result.Result, err)).Inc()
+	}
+}
+
+// failureClass 根据渲染结果的错误信息返回错误分类
+func failureClass(result *RenderResult, err error) string {
+	message := ""
+	if result != nil {
+		message = result.Error
+	}
+	if message == "" && err != nil {
+		message = err.Error()
+	}
+
+	switch {
+	case message == ErrRenderQueueFull.Error():
+		return failureQueueFull
+	case message == RenderErrorNotHTML:
+		return failureNotHTML
+	case strings.Contains(message, "timeout"), strings.Contains(message, "deadline exceeded"):
+		return failureTimeout
+	case strings.Contains(message, "canceled"), message == "engine stopped", message == "render task aborted":
+		return failureCanceled
+	case strings.Contains(message, "browser"), strings.Contains(message, "panic"), strings.HasPrefix(message, "failed to create page"):
+		return failureBrowser
+	case strings.HasPrefix(message, "failed to navigate"):
+		return failureNavigation
+	case strings.Contains(message, "html"):
+		return failureContent
+	default:
+		return failureOther
+	}
+}
@@ -0,0 +1,78 @@
+package prerender
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TestRenderMetrics 测试渲染后/metrics中包含浏览器池、任务队列和渲染指标
+func TestRenderMetrics(t *testing.T) {
+	engine, err := NewEngine("metrics-site", PrerenderConfig{PoolSize: 1, CacheTTL: 60}, nil, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.cancel()
+	engineMetrics.add(engine)
+	defer engineMetrics.remove(engine)
+
+	// 模拟任务分发器：第一个任务成功，第二个任务超时
+	go func() {
+		task := <-engine.taskQueue
+		task.Result <- &RenderResult{HTML: "<html><body>ok</body></html>", Success: true}
+		close(task.Result)
+		task = <-engine.taskQueue
+		task.Result <- &RenderResult{Error: "render timeout"}
+		close(task.Result)
+	}()
+	if _, err := engine.Render(context.Background(), "http://example.com/ok", RenderOptions{}); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if _, err := engine.Render(context.Background(), "http://example.com/slow", RenderOptions{}); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	// 抓取monitoring.Monitor暴露的默认注册表
+	recorder := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	metrics := string(body)
+
+	for _, family := range []string{
+		`prerender_browser_pool_size{site="metrics-site"} 0`,
+		`prerender_browser_pool_idle{site="metrics-site"} 0`,
+		`prerender_render_active_tasks{site="metrics-site"} 0`,
+		`prerender_render_queue_depth{site="metrics-site"} 0`,
+		`prerender_render_duration_seconds_count{cache_hit="false",site="metrics-site"} 2`,
+		`prerender_render_failures_total{class="timeout",site="metrics-site"} 1`,
+	} {
+		if !strings.Contains(metrics, family) {
+			t.Errorf("expected /metrics to contain %s", family)
+		}
+	}
+}
+
+// TestFailureClass 测试渲染错误分类
+func TestFailureClass(t *testing.T) {
+	cases := map[string]string{
+		"render queue full":                       failureQueueFull,
+		RenderErrorNotHTML:                        failureNotHTML,
+		"render timeout":                          failureTimeout,
+		"context canceled":                        failureCanceled,
+		"engine stopped":                          failureCanceled,
+		"browser is not healthy":                  failureBrowser,
+		"failed to create page: closed":           failureBrowser,
+		"failed to navigate to http://x: refused": failureNavigation,
+		"empty html content":                      failureContent,
+		"something else":                          failureOther,
+	}
+	for message, expected := range cases {
+		if got := failureClass(&RenderResult{Error: message}, nil); got != expected {
+			t.Errorf("failureClass(%q) = %q, expected %q", message, got, expected)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package prerender
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultIgnoredQueryParams 默认忽略的跟踪参数，以*结尾表示前缀匹配
+var DefaultIgnoredQueryParams = []string{"utm_*", "gclid", "fbclid"}
+
+// URLNormalizer URL规范化器，用于缓存键和渲染URL去重
+type URLNormalizer struct {
+	ignoredParams         []string
+	significantParams     []string // 影响渲染结果的参数，非空时仅保留这些参数
+	collapseTrailingSlash bool
+}
+
+// NewURLNormalizer 创建URL规范化器，ignoredParams为空时使用默认跟踪参数列表
+// significantParams非空时，只有列出的参数会参与渲染和缓存，其余参数全部移除
+func NewURLNormalizer(ignoredParams, significantParams []string, collapseTrailingSlash bool) *URLNormalizer {
+	if len(ignoredParams) == 0 {
+		ignoredParams = DefaultIgnoredQueryParams
+	}
+	return &URLNormalizer{
+		ignoredParams:         ignoredParams,
+		significantParams:     significantParams,
+		collapseTrailingSlash: collapseTrailingSlash,
+	}
+}
+
+// Normalize 规范化URL：主机名小写、去除片段、移除跟踪参数和无关参数、查询参数排序、可选合并末尾斜杠
+func (n *URLNormalizer) Normalize(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	// 移除忽略的参数和不影响渲染结果的参数，Encode会按参数名排序
+	if parsed.RawQuery != "" {
+		query, err := url.ParseQuery(parsed.RawQuery)
+		if err == nil {
+			for name := range query {
+				if n.isIgnoredParam(name) || !n.isSignificantParam(name) {
+					query.Del(name)
+				}
+			}
+			parsed.RawQuery = query.Encode()
+		}
+	}
+	parsed.ForceQuery = false
+
+	// 合并末尾斜杠，根路径保持不变
+	if n.collapseTrailingSlash && len(parsed.Path) > 1 && strings.HasSuffix(parsed.Path, "/") {
+		parsed.Path = strings.TrimRight(parsed.Path, "/")
+		if parsed.Path == "" {
+			parsed.Path = "/"
+		}
+		parsed.RawPath = ""
+	}
+
+	return parsed.String()
+}
+
+// isIgnoredParam 检查查询参数是否在忽略列表中
+func (n *URLNormalizer) isIgnoredParam(name string) bool {
+	return matchParam(n.ignoredParams, name)
+}
+
+// isSignificantParam 检查查询参数是否影响渲染结果，未配置时所有参数都视为有效
+func (n *URLNormalizer) isSignificantParam(name string) bool {
+	if len(n.significantParams) == 0 {
+		return true
+	}
+	return matchParam(n.significantParams, name)
+}
+
+// matchParam 检查参数名是否匹配列表中的任一模式，以*结尾表示前缀匹配，不区分大小写
+func matchParam(patterns []string, name string) bool {
+	lowerName := strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(lowerName, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		} else if lowerName == pattern {
+			return true
+		}
+	}
+	return false
+}
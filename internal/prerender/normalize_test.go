@@ -0,0 +1,53 @@
+package prerender
+
+import "testing"
+
+// TestURLNormalizer 测试URL规范化
+func TestURLNormalizer(t *testing.T) {
+	normalizer := NewURLNormalizer(nil, nil, true)
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"http://Example.COM/page?utm_source=x", "http://example.com/page"},
+		{"http://example.com/page/", "http://example.com/page"},
+		{"http://example.com/page#section", "http://example.com/page"},
+		{"http://example.com/page?b=2&a=1&gclid=abc&fbclid=def", "http://example.com/page?a=1&b=2"},
+		{"http://example.com/", "http://example.com/"},
+		{"/about/?UTM_Campaign=spring&id=3", "/about?id=3"},
+	}
+
+	for _, c := range cases {
+		if got := normalizer.Normalize(c.input); got != c.expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", c.input, got, c.expected)
+		}
+	}
+}
+
+// TestURLNormalizerCustomParams 测试自定义忽略参数且不合并末尾斜杠
+func TestURLNormalizerCustomParams(t *testing.T) {
+	normalizer := NewURLNormalizer([]string{"ref", "session*"}, nil, false)
+
+	got := normalizer.Normalize("http://example.com/list/?ref=home&sessionid=1&utm_source=x&page=2")
+	expected := "http://example.com/list/?page=2&utm_source=x"
+	if got != expected {
+		t.Errorf("Normalize = %q, expected %q", got, expected)
+	}
+}
+
+// TestURLNormalizerSignificantParams 测试配置有效参数后只保留影响渲染结果的参数
+func TestURLNormalizerSignificantParams(t *testing.T) {
+	normalizer := NewURLNormalizer(nil, []string{"page", "sort", "filter_*"}, false)
+
+	got := normalizer.Normalize("http://example.com/list?sessionid=abc&page=2&Filter_Color=red&utm_source=x&sort=price")
+	expected := "http://example.com/list?Filter_Color=red&page=2&sort=price"
+	if got != expected {
+		t.Errorf("Normalize = %q, expected %q", got, expected)
+	}
+
+	// 仅包含无关参数时去掉整个查询串
+	if got := normalizer.Normalize("http://example.com/list?sessionid=abc"); got != "http://example.com/list" {
+		t.Errorf("Normalize = %q, expected query to be dropped", got)
+	}
+}
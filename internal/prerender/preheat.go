@@ -19,6 +19,7 @@ type PreheatWorker struct {
 	crawlerHeaders []string
 	wg             sync.WaitGroup
 	semaphore      chan struct{}
+	normalizer     *URLNormalizer
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -29,6 +30,7 @@ type PreheatWorkerConfig struct {
 	RedisClient    *redis.Client
 	Concurrency    int
 	CrawlerHeaders []string
+	Normalizer     *URLNormalizer // URL规范化器，为空时使用默认规则
 }
 
 // NewPreheatWorker 创建新的预热执行器
@@ -58,12 +60,19 @@ func NewPreheatWorker(config PreheatWorkerConfig) *PreheatWorker {
 		crawlerHeaders = defaultHeaders
 	}
 
+	// 如果没有提供URL规范化器，使用默认规则
+	normalizer := config.Normalizer
+	if normalizer == nil {
+		normalizer = NewURLNormalizer(nil, nil, false)
+	}
+
 	return &PreheatWorker{
 		siteName:       config.SiteName,
 		redisClient:    config.RedisClient,
 		concurrency:    concurrency,
 		crawlerHeaders: crawlerHeaders,
 		semaphore:      make(chan struct{}, concurrency),
+		normalizer:     normalizer,
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -167,16 +176,19 @@ func (p *PreheatWorker) preheatURL(url string) bool {
 	default:
 	}
 
+	// 规范化URL，与渲染缓存使用相同的键
+	url = p.normalizer.Normalize(url)
+
 	// 为每个URL使用随机的爬虫协议头
 	headerIndex := int(time.Now().UnixNano() % int64(len(p.crawlerHeaders)))
 	userAgent := p.crawlerHeaders[headerIndex]
 
-	fmt.Printf("Preheating URL: %s with UA: %s\n", url, userAgent)
+	logger.Debug("Preheating URL: %s with UA: %s", url, userAgent)
 
 	// 发送HTTP请求，模拟爬虫访问
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		fmt.Printf("Failed to create request for %s: %v\n", url, err)
+		logger.Error("Failed to create request for %s: %v", url, err)
 		p.redisClient.SetURLPreheatStatus(p.siteName, url, "failed", 0)
 		return false
 	}
@@ -195,7 +207,7 @@ func (p *PreheatWorker) preheatURL(url string) bool {
 	// 发送请求
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Printf("Failed to preheat %s: %v\n", url, err)
+		logger.Error("Failed to preheat %s: %v", url, err)
 		p.redisClient.SetURLPreheatStatus(p.siteName, url, "failed", 0)
 		return false
 	}
@@ -204,14 +216,14 @@ func (p *PreheatWorker) preheatURL(url string) bool {
 	// 读取响应内容
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Printf("Failed to read response for %s: %v\n", url, err)
+		logger.Error("Failed to read response for %s: %v", url, err)
 		p.redisClient.SetURLPreheatStatus(p.siteName, url, "failed", 0)
 		return false
 	}
 
 	// 检查响应状态码
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Preheat failed for %s: status code %d\n", url, resp.StatusCode)
+		logger.Error("Preheat failed for %s: status code %d", url, resp.StatusCode)
 		p.redisClient.SetURLPreheatStatus(p.siteName, url, "failed", 0)
 		return false
 	}
@@ -219,10 +231,10 @@ func (p *PreheatWorker) preheatURL(url string) bool {
 	// 记录预热成功
 	cacheSize := int64(len(body))
 	if err := p.redisClient.SetURLPreheatStatus(p.siteName, url, "cached", cacheSize); err != nil {
-		fmt.Printf("Failed to set preheat status for %s: %v\n", url, err)
+		logger.Error("Failed to set preheat status for %s: %v", url, err)
 	}
 
-	fmt.Printf("Successfully preheated URL: %s (size: %d bytes)\n", url, cacheSize)
+	logger.Info("Successfully preheated URL: %s (size: %d bytes)", url, cacheSize)
 	return true
 }
 
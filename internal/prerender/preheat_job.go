@@ -0,0 +1,151 @@
+package prerender
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// 预热任务状态
+const (
+	PreheatJobRunning     = "running"
+	PreheatJobCompleted   = "completed"
+	PreheatJobFailed      = "failed"
+	PreheatJobCanceled    = "canceled"
+	PreheatJobInterrupted = "interrupted" // 服务重启时仍在运行的任务
+)
+
+// maxPreheatJobErrors 预热任务保留的最近错误数
+const maxPreheatJobErrors = 10
+
+// PreheatJob 预热任务进度
+type PreheatJob struct {
+	ID         string     `json:"id"`
+	SiteName   string     `json:"siteName"`
+	Status     string     `json:"status"`
+	TotalURLs  int64      `json:"totalUrls"`
+	Rendered   int64      `json:"rendered"`
+	Failed     int64      `json:"failed"`
+	Skipped    int64      `json:"skipped"`
+	StartedAt  time.Time  `json:"startedAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	ETA        *time.Time `json:"eta,omitempty"` // 预计完成时间，根据已处理URL的平均耗时估算
+	Progress   float64    `json:"progress"`      // 已处理URL占总数的百分比，保留一位小数
+	LastErrors []string   `json:"lastErrors"`
+
+	mutex sync.Mutex
+}
+
+// newPreheatJob 创建预热任务
+func newPreheatJob(id, siteName string) *PreheatJob {
+	now := time.Now()
+	return &PreheatJob{
+		ID:         id,
+		SiteName:   siteName,
+		Status:     PreheatJobRunning,
+		StartedAt:  now,
+		UpdatedAt:  now,
+		LastErrors: []string{},
+	}
+}
+
+// setTotal 设置待预热URL总数
+func (j *PreheatJob) setTotal(total int64) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.TotalURLs = total
+	j.UpdatedAt = time.Now()
+}
+
+// addSkipped 增加跳过的URL数
+func (j *PreheatJob) addSkipped(n int64) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Skipped += n
+	j.UpdatedAt = time.Now()
+}
+
+// recordRendered 记录一个渲染成功的URL
+func (j *PreheatJob) recordRendered() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Rendered++
+	j.UpdatedAt = time.Now()
+}
+
+// recordFailure 记录一个渲染失败的URL，仅保留最近的错误信息
+func (j *PreheatJob) recordFailure(url, message string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Failed++
+	j.addError(fmt.Sprintf("%s: %s", url, message))
+}
+
+// recordError 记录与具体URL无关的任务错误
+func (j *PreheatJob) recordError(message string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.addError(message)
+}
+
+// addError 追加错误信息，调用方需持有锁
+func (j *PreheatJob) addError(message string) {
+	j.LastErrors = append(j.LastErrors, message)
+	if len(j.LastErrors) > maxPreheatJobErrors {
+		j.LastErrors = j.LastErrors[len(j.LastErrors)-maxPreheatJobErrors:]
+	}
+	j.UpdatedAt = time.Now()
+}
+
+// finish 标记任务结束，已结束的任务不会被再次修改状态
+func (j *PreheatJob) finish(status string) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.Status != PreheatJobRunning {
+		return false
+	}
+	now := time.Now()
+	j.Status = status
+	j.FinishedAt = &now
+	j.UpdatedAt = now
+	return true
+}
+
+// Snapshot 返回任务进度的副本，并计算预计完成时间
+func (j *PreheatJob) Snapshot() *PreheatJob {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	snapshot := &PreheatJob{
+		ID:         j.ID,
+		SiteName:   j.SiteName,
+		Status:     j.Status,
+		TotalURLs:  j.TotalURLs,
+		Rendered:   j.Rendered,
+		Failed:     j.Failed,
+		Skipped:    j.Skipped,
+		StartedAt:  j.StartedAt,
+		UpdatedAt:  j.UpdatedAt,
+		FinishedAt: j.FinishedAt,
+		LastErrors: append([]string{}, j.LastErrors...),
+	}
+
+	processed := j.Rendered + j.Failed
+	if j.TotalURLs > 0 {
+		snapshot.Progress = math.Round(float64(processed)/float64(j.TotalURLs)*1000) / 10
+	} else if j.Status == PreheatJobCompleted {
+		snapshot.Progress = 100
+	}
+
+	// 按已处理URL的平均耗时估算剩余时间
+	if j.Status == PreheatJobRunning && processed > 0 && j.TotalURLs > processed {
+		elapsed := time.Since(j.StartedAt)
+		remaining := time.Duration(float64(elapsed) / float64(processed) * float64(j.TotalURLs-processed))
+		eta := time.Now().Add(remaining)
+		snapshot.ETA = &eta
+	}
+
+	return snapshot
+}
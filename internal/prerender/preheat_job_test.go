@@ -0,0 +1,80 @@
+package prerender
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestPreheatJobProgress 测试预热任务计数和预计完成时间
+func TestPreheatJobProgress(t *testing.T) {
+	job := newPreheatJob("task-1", "site1")
+	job.setTotal(4)
+	job.addSkipped(2)
+
+	if snapshot := job.Snapshot(); snapshot.ETA != nil {
+		t.Error("ETA should be unknown before any URL is processed")
+	}
+
+	job.StartedAt = time.Now().Add(-10 * time.Second)
+	job.recordRendered()
+	job.recordFailure("/broken", "render timeout")
+
+	snapshot := job.Snapshot()
+	if snapshot.Rendered != 1 || snapshot.Failed != 1 || snapshot.Skipped != 2 || snapshot.TotalURLs != 4 {
+		t.Fatalf("unexpected counters: %+v", snapshot)
+	}
+	if snapshot.Progress != 50 {
+		t.Errorf("expected progress 50%%, got %v", snapshot.Progress)
+	}
+	if snapshot.ETA == nil {
+		t.Fatal("expected ETA while job is running")
+	}
+	// 已处理2个URL耗时约10秒，剩余2个约需10秒
+	if remaining := time.Until(*snapshot.ETA); remaining < 8*time.Second || remaining > 12*time.Second {
+		t.Errorf("unexpected ETA, remaining %v", remaining)
+	}
+	if len(snapshot.LastErrors) != 1 || snapshot.LastErrors[0] != "/broken: render timeout" {
+		t.Errorf("unexpected errors: %v", snapshot.LastErrors)
+	}
+}
+
+// TestPreheatJobKeepsRecentErrors 测试只保留最近的错误信息
+func TestPreheatJobKeepsRecentErrors(t *testing.T) {
+	job := newPreheatJob("task-1", "site1")
+	for i := 0; i < maxPreheatJobErrors+5; i++ {
+		job.recordFailure(fmt.Sprintf("/page/%d", i), "failed")
+	}
+
+	snapshot := job.Snapshot()
+	if snapshot.Failed != int64(maxPreheatJobErrors+5) {
+		t.Errorf("expected %d failures, got %d", maxPreheatJobErrors+5, snapshot.Failed)
+	}
+	if len(snapshot.LastErrors) != maxPreheatJobErrors {
+		t.Fatalf("expected %d errors, got %d", maxPreheatJobErrors, len(snapshot.LastErrors))
+	}
+	if snapshot.LastErrors[0] != "/page/5: failed" {
+		t.Errorf("oldest errors should be dropped, got %s", snapshot.LastErrors[0])
+	}
+}
+
+// TestPreheatJobFinish 测试任务结束后状态不再改变
+func TestPreheatJobFinish(t *testing.T) {
+	job := newPreheatJob("task-1", "site1")
+	job.setTotal(10)
+
+	if !job.finish(PreheatJobCanceled) {
+		t.Fatal("running job should be finished")
+	}
+	if job.finish(PreheatJobCompleted) {
+		t.Error("finished job should not change status")
+	}
+
+	snapshot := job.Snapshot()
+	if snapshot.Status != PreheatJobCanceled || snapshot.FinishedAt == nil {
+		t.Errorf("unexpected status: %+v", snapshot)
+	}
+	if snapshot.ETA != nil {
+		t.Error("finished job should not report ETA")
+	}
+}
@@ -0,0 +1,29 @@
+package push
+
+import (
+	"net/http"
+	"time"
+
+	"prerender-shield/internal/config"
+)
+
+// defaultPushTimeout 未配置超时时单次推送请求的超时时间
+const defaultPushTimeout = 30 * time.Second
+
+// newPushTransport 创建推送请求共用的连接池，同一搜索引擎接口的请求复用keep-alive连接，避免每批都重新握手
+func newPushTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 50
+	transport.MaxIdleConnsPerHost = 10
+	transport.IdleConnTimeout = 90 * time.Second
+	return transport
+}
+
+// httpClient 返回使用共享连接池的HTTP客户端，超时时间取站点推送配置
+func (pm *PushManager) httpClient(pushConfig config.PushConfig) *http.Client {
+	timeout := defaultPushTimeout
+	if pushConfig.Timeout > 0 {
+		timeout = time.Duration(pushConfig.Timeout) * time.Second
+	}
+	return &http.Client{Transport: pm.transport, Timeout: timeout}
+}
@@ -0,0 +1,52 @@
+package push
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"prerender-shield/internal/config"
+)
+
+// TestPushReusesConnections 测试多次推送共用连接池复用同一个TCP连接
+func TestPushReusesConnections(t *testing.T) {
+	var requests, connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"remain":100,"success":1}`)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	pm := NewPushManager(&config.Config{}, nil)
+	pushConfig := config.PushConfig{BaiduAPI: server.URL, BaiduToken: "token", PushDomain: "example.com", BatchSize: 1, Timeout: 5}
+	siteConfig := &config.SiteConfig{ID: "site1", Name: "Site 1", Port: 80}
+
+	for i := 0; i < 3; i++ {
+		if result := pm.pushToBaidu([]string{"/a", "/b"}, pushConfig, siteConfig); result.Success != 2 {
+			t.Fatalf("push %d: expected 2 success, got %+v", i, result)
+		}
+	}
+	if requests != 6 {
+		t.Errorf("expected 6 requests, got %d", requests)
+	}
+	if connections != 1 {
+		t.Errorf("expected all pushes to reuse one connection, got %d connections", connections)
+	}
+
+	if got := pm.httpClient(pushConfig).Timeout; got != 5*time.Second {
+		t.Errorf("expected configured timeout 5s, got %v", got)
+	}
+	if got := pm.httpClient(config.PushConfig{}).Timeout; got != defaultPushTimeout {
+		t.Errorf("expected default timeout, got %v", got)
+	}
+}
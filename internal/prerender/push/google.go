@@ -0,0 +1,193 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"prerender-shield/internal/config"
+)
+
+const (
+	// googleIndexingScope Google Indexing API的OAuth2授权范围
+	googleIndexingScope = "https://www.googleapis.com/auth/indexing"
+	// googleDefaultTokenURI 服务账号JSON未指定token_uri时使用的令牌地址
+	googleDefaultTokenURI = "https://oauth2.googleapis.com/token"
+)
+
+// googleIndexingEndpoint Google Indexing API推送地址
+var googleIndexingEndpoint = "https://indexing.googleapis.com/v3/urlNotifications:publish"
+
+// googleServiceAccount Google服务账号密钥文件中使用到的字段
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// googleToken 缓存的访问令牌
+type googleToken struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+// googleTokenCache 按服务账号缓存访问令牌，避免每个URL都重新换取令牌
+var googleTokenCache = struct {
+	sync.Mutex
+	tokens map[string]googleToken
+}{tokens: make(map[string]googleToken)}
+
+// parseGoogleServiceAccount 解析服务账号配置，支持直接填写JSON内容或JSON文件路径
+func parseGoogleServiceAccount(value string) (*googleServiceAccount, error) {
+	data := []byte(strings.TrimSpace(value))
+	if len(data) == 0 {
+		return nil, fmt.Errorf("google service account is not configured")
+	}
+	if data[0] != '{' {
+		fileData, err := os.ReadFile(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read google service account file: %v", err)
+		}
+		data = fileData
+	}
+
+	var account googleServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid google service account json: %v", err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("google service account json missing client_email or private_key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = googleDefaultTokenURI
+	}
+	return &account, nil
+}
+
+// getGoogleAccessToken 使用服务账号JWT换取Indexing API访问令牌
+func getGoogleAccessToken(client *http.Client, account *googleServiceAccount) (string, error) {
+	googleTokenCache.Lock()
+	defer googleTokenCache.Unlock()
+
+	// 令牌过期前1分钟刷新
+	if token, ok := googleTokenCache.tokens[account.ClientEmail]; ok && time.Now().Add(time.Minute).Before(token.expiresAt) {
+		return token.accessToken, nil
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid google service account private key: %v", err)
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   account.ClientEmail,
+		"scope": googleIndexingScope,
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign google assertion: %v", err)
+	}
+
+	resp, err := client.PostForm(account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to request google access token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read google token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google token request failed: %s", string(body))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.AccessToken == "" {
+		return "", fmt.Errorf("invalid google token response: %s", string(body))
+	}
+
+	googleTokenCache.tokens[account.ClientEmail] = googleToken{
+		accessToken: result.AccessToken,
+		expiresAt:   now.Add(time.Duration(result.ExpiresIn) * time.Second),
+	}
+	return result.AccessToken, nil
+}
+
+// pushToGoogle 通过Google Indexing API推送URL_UPDATED通知
+func (pm *PushManager) pushToGoogle(url, route string, pushConfig config.PushConfig, siteConfig *config.SiteConfig) error {
+	account, err := parseGoogleServiceAccount(pushConfig.GoogleServiceAccountJSON)
+	if err != nil {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "failed", err.Error())
+		return err
+	}
+
+	client := pm.httpClient(pushConfig)
+	accessToken, err := getGoogleAccessToken(client, account)
+	if err != nil {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "failed", err.Error())
+		return err
+	}
+
+	// 构建请求体
+	jsonData, err := json.Marshal(map[string]string{
+		"url":  url,
+		"type": "URL_UPDATED",
+	})
+	if err != nil {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "failed", err.Error())
+		return err
+	}
+
+	// 构建请求
+	req, err := http.NewRequest("POST", googleIndexingEndpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "failed", err.Error())
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	// 发送请求
+	resp, err := client.Do(req)
+	if err != nil {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "failed", err.Error())
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 读取响应
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "failed", err.Error())
+		return err
+	}
+
+	// 检查响应状态
+	if resp.StatusCode == http.StatusOK {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "success", string(body))
+		return nil
+	}
+
+	pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "google", "failed", string(body))
+	return fmt.Errorf("google push failed: %s", string(body))
+}
@@ -0,0 +1,164 @@
+package push
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"prerender-shield/internal/config"
+)
+
+// TestPushToGoogle 测试服务账号换取令牌并推送URL_UPDATED通知
+func TestPushToGoogle(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	tokenRequests := 0
+	var published []map[string]string
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("unexpected grant_type: %s", r.FormValue("grant_type"))
+		}
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(r.FormValue("assertion"), claims, func(token *jwt.Token) (interface{}, error) {
+			return &privateKey.PublicKey, nil
+		}); err != nil {
+			t.Errorf("invalid assertion: %v", err)
+		}
+		if claims["iss"] != "indexer@example.iam.gserviceaccount.com" || claims["scope"] != googleIndexingScope {
+			t.Errorf("unexpected assertion claims: %v", claims)
+		}
+		fmt.Fprint(w, `{"access_token":"test-token","expires_in":3600}`)
+	})
+	mux.HandleFunc("/publish", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("unexpected Authorization header: %s", r.Header.Get("Authorization"))
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		published = append(published, body)
+		fmt.Fprint(w, `{"urlNotificationMetadata":{}}`)
+	})
+
+	originalEndpoint := googleIndexingEndpoint
+	googleIndexingEndpoint = server.URL + "/publish"
+	defer func() { googleIndexingEndpoint = originalEndpoint }()
+
+	account, _ := json.Marshal(map[string]string{
+		"client_email": "indexer@example.iam.gserviceaccount.com",
+		"private_key":  string(keyPEM),
+		"token_uri":    server.URL + "/token",
+	})
+	pushConfig := config.PushConfig{GoogleServiceAccountJSON: string(account), GoogleDailyLimit: 200}
+	siteConfig := &config.SiteConfig{ID: "site1", Name: "Site 1"}
+
+	pm := NewPushManager(&config.Config{}, nil)
+	for _, route := range []string{"/a", "/b"} {
+		if err := pm.pushToGoogle("http://example.com"+route, route, pushConfig, siteConfig); err != nil {
+			t.Fatalf("pushToGoogle failed: %v", err)
+		}
+	}
+
+	// 访问令牌在有效期内复用
+	if tokenRequests != 1 {
+		t.Errorf("expected 1 token request, got %d", tokenRequests)
+	}
+	expected := []map[string]string{
+		{"url": "http://example.com/a", "type": "URL_UPDATED"},
+		{"url": "http://example.com/b", "type": "URL_UPDATED"},
+	}
+	if !reflect.DeepEqual(published, expected) {
+		t.Errorf("unexpected notifications: %v", published)
+	}
+}
+
+// TestParseGoogleServiceAccountInvalid 测试缺少必要字段的服务账号配置
+func TestParseGoogleServiceAccountInvalid(t *testing.T) {
+	if _, err := parseGoogleServiceAccount(`{"client_email":"a@example.com"}`); err == nil {
+		t.Error("expected error for missing private_key")
+	}
+	if _, err := parseGoogleServiceAccount(""); err == nil {
+		t.Error("expected error for empty config")
+	}
+}
+
+// TestSelectPushURLs 测试按偏移量和限额选取URL
+func TestSelectPushURLs(t *testing.T) {
+	urls := []string{"/1", "/2", "/3", "/4"}
+
+	cases := []struct {
+		offset, limit int
+		expected      []string
+	}{
+		{0, 2, []string{"/1", "/2"}},
+		{3, 2, []string{"/4", "/1"}},
+		{1, 10, []string{"/2", "/3", "/4", "/1"}},
+		{0, 0, nil},
+	}
+	for _, c := range cases {
+		if got := selectPushURLs(urls, c.offset, c.limit); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("selectPushURLs(offset=%d, limit=%d) = %v, expected %v", c.offset, c.limit, got, c.expected)
+		}
+	}
+	if got := selectPushURLs(nil, 0, 10); got != nil {
+		t.Errorf("expected nil for empty URL list, got %v", got)
+	}
+}
+
+// TestPushOffsetWithChangedURLList 测试URL列表在两次推送之间变短、变长或清空时偏移量的处理
+func TestPushOffsetWithChangedURLList(t *testing.T) {
+	// 上次推送后偏移量为6，URL列表缩减为4个，从头开始推送
+	shrunk := []string{"/1", "/2", "/3", "/4"}
+	if got := selectPushURLs(shrunk, 6, 3); !reflect.DeepEqual(got, []string{"/1", "/2", "/3"}) {
+		t.Errorf("unexpected urls after shrink: %v", got)
+	}
+	if got := nextPushOffset(6, 3, len(shrunk)); got != 3 {
+		t.Errorf("expected offset 3 after shrink, got %d", got)
+	}
+
+	// 偏移量等于列表长度
+	if got := selectPushURLs(shrunk, 4, 2); !reflect.DeepEqual(got, []string{"/1", "/2"}) {
+		t.Errorf("unexpected urls when offset equals length: %v", got)
+	}
+
+	// URL列表变长，从原偏移量继续推送
+	grown := []string{"/1", "/2", "/3", "/4", "/5", "/6", "/7", "/8"}
+	if got := selectPushURLs(grown, 3, 3); !reflect.DeepEqual(got, []string{"/4", "/5", "/6"}) {
+		t.Errorf("unexpected urls after grow: %v", got)
+	}
+	if got := nextPushOffset(3, 3, len(grown)); got != 6 {
+		t.Errorf("expected offset 6 after grow, got %d", got)
+	}
+	if got := nextPushOffset(6, 3, len(grown)); got != 0 {
+		t.Errorf("expected offset to reset at the end of the list, got %d", got)
+	}
+
+	// URL列表为空或偏移量异常
+	if got := selectPushURLs([]string{}, 5, 3); got != nil {
+		t.Errorf("expected nil for empty list, got %v", got)
+	}
+	if got := nextPushOffset(5, 3, 0); got != 0 {
+		t.Errorf("expected offset 0 for empty list, got %d", got)
+	}
+	if got := selectPushURLs(shrunk, -1, 2); !reflect.DeepEqual(got, []string{"/1", "/2"}) {
+		t.Errorf("unexpected urls for negative offset: %v", got)
+	}
+}
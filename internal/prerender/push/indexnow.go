@@ -0,0 +1,127 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"prerender-shield/internal/config"
+)
+
+// indexNowEndpoint IndexNow批量提交地址，会同步给Bing、Yandex、Seznam等搜索引擎
+var indexNowEndpoint = "https://api.indexnow.org/indexnow"
+
+// indexNowBatchSize 单次请求最多提交的URL数量（IndexNow协议限制为10000）
+var indexNowBatchSize = 10000
+
+// indexNowKeyPattern IndexNow协议要求密钥为8-128位字母、数字或短横线
+var indexNowKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9-]{8,128}$`)
+
+// indexNowRequest IndexNow批量提交请求体
+type indexNowRequest struct {
+	Host        string   `json:"host"`
+	Key         string   `json:"key"`
+	KeyLocation string   `json:"keyLocation,omitempty"`
+	URLList     []string `json:"urlList"`
+}
+
+// writeIndexNowKeyFile 在站点静态目录写入密钥验证文件 {key}.txt，供搜索引擎校验站点所有权
+func writeIndexNowKeyFile(staticDir, siteID, key string) error {
+	if !indexNowKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid IndexNow key: must be 8-128 characters of a-z, A-Z, 0-9 or '-'")
+	}
+
+	siteStaticDir := filepath.Join(staticDir, siteID)
+	if err := os.MkdirAll(siteStaticDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(siteStaticDir, key+".txt"), []byte(key), 0644)
+}
+
+// pushToIndexNow 通过IndexNow批量提交URL，返回成功和失败的URL数量
+func (pm *PushManager) pushToIndexNow(routes []string, pushConfig config.PushConfig, siteConfig *config.SiteConfig) (int, int) {
+	if len(routes) == 0 {
+		return 0, 0
+	}
+
+	urls := make([]string, len(routes))
+	for i, route := range routes {
+		urls[i] = buildFullURL(pushConfig.PushDomain, siteConfig.Port, route)
+	}
+
+	// 确保密钥验证文件存在，否则搜索引擎会拒绝提交
+	if err := writeIndexNowKeyFile(pm.config.Dirs.StaticDir, siteConfig.ID, pushConfig.IndexNowKey); err != nil {
+		pm.logIndexNowResult(urls, routes, siteConfig, "failed", err.Error())
+		return 0, len(urls)
+	}
+
+	// 同一请求中的URL必须属于同一主机
+	parsed, err := url.Parse(urls[0])
+	if err != nil {
+		pm.logIndexNowResult(urls, routes, siteConfig, "failed", err.Error())
+		return 0, len(urls)
+	}
+	keyLocation := fmt.Sprintf("%s://%s/%s.txt", parsed.Scheme, parsed.Host, pushConfig.IndexNowKey)
+
+	successCount, failedCount := 0, 0
+	client := pm.httpClient(pushConfig)
+	forEachBatch(len(urls), indexNowBatchSize, func(start, end int) bool {
+		if err := submitIndexNowBatch(client, indexNowRequest{
+			Host:        parsed.Hostname(),
+			Key:         pushConfig.IndexNowKey,
+			KeyLocation: keyLocation,
+			URLList:     urls[start:end],
+		}); err != nil {
+			pm.logIndexNowResult(urls[start:end], routes[start:end], siteConfig, "failed", err.Error())
+			failedCount += end - start
+			return true
+		}
+
+		pm.logIndexNowResult(urls[start:end], routes[start:end], siteConfig, "success", "submitted")
+		successCount += end - start
+		return true
+	})
+
+	return successCount, failedCount
+}
+
+// submitIndexNowBatch 提交一批URL到IndexNow
+func submitIndexNowBatch(client *http.Client, payload indexNowRequest) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", indexNowEndpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 200表示已接收，202表示已接收但密钥尚待验证
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("indexnow push failed: status %d: %s", resp.StatusCode, string(body))
+}
+
+// logIndexNowResult 为批量提交的每个URL记录推送结果
+func (pm *PushManager) logIndexNowResult(urls, routes []string, siteConfig *config.SiteConfig, status, message string) {
+	for i := range urls {
+		pm.logPushResult(siteConfig.ID, siteConfig.Name, urls[i], routes[i], "indexnow", status, message)
+	}
+}
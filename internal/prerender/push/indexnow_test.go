@@ -0,0 +1,80 @@
+package push
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"prerender-shield/internal/config"
+)
+
+// TestPushToIndexNowBatches 测试IndexNow按批提交并写入密钥验证文件
+func TestPushToIndexNowBatches(t *testing.T) {
+	var requests []indexNowRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload indexNowRequest
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		requests = append(requests, payload)
+		// 第二批模拟限流
+		if len(requests) == 2 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	originalEndpoint, originalBatchSize := indexNowEndpoint, indexNowBatchSize
+	indexNowEndpoint, indexNowBatchSize = server.URL, 2
+	defer func() { indexNowEndpoint, indexNowBatchSize = originalEndpoint, originalBatchSize }()
+
+	staticDir := t.TempDir()
+	pm := NewPushManager(&config.Config{Dirs: config.DirsConfig{StaticDir: staticDir}}, nil)
+	pushConfig := config.PushConfig{PushDomain: "example.com", IndexNowEnabled: true, IndexNowKey: "abcdef0123456789"}
+	siteConfig := &config.SiteConfig{ID: "site1", Name: "Site 1", Port: 80}
+
+	success, failed := pm.pushToIndexNow([]string{"/a", "/b", "/c", "/d", "/e"}, pushConfig, siteConfig)
+	if success != 3 || failed != 2 {
+		t.Errorf("expected 3 success and 2 failed, got %d and %d", success, failed)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 batch requests, got %d", len(requests))
+	}
+	first := requests[0]
+	if first.Host != "example.com" || first.Key != "abcdef0123456789" || first.KeyLocation != "http://example.com/abcdef0123456789.txt" {
+		t.Errorf("unexpected request metadata: %+v", first)
+	}
+	if len(first.URLList) != 2 || first.URLList[0] != "http://example.com/a" {
+		t.Errorf("unexpected first batch: %v", first.URLList)
+	}
+	if len(requests[2].URLList) != 1 || requests[2].URLList[0] != "http://example.com/e" {
+		t.Errorf("unexpected last batch: %v", requests[2].URLList)
+	}
+
+	content, err := os.ReadFile(filepath.Join(staticDir, "site1", "abcdef0123456789.txt"))
+	if err != nil || string(content) != "abcdef0123456789" {
+		t.Errorf("key file not written correctly: %q, %v", content, err)
+	}
+}
+
+// TestPushToIndexNowRejectsInvalidKey 测试非法密钥不会写文件也不会提交
+func TestPushToIndexNowRejectsInvalidKey(t *testing.T) {
+	staticDir := t.TempDir()
+	pm := NewPushManager(&config.Config{Dirs: config.DirsConfig{StaticDir: staticDir}}, nil)
+	pushConfig := config.PushConfig{IndexNowEnabled: true, IndexNowKey: "../../etc/passwd"}
+	siteConfig := &config.SiteConfig{ID: "site1", Port: 80}
+
+	success, failed := pm.pushToIndexNow([]string{"/a"}, pushConfig, siteConfig)
+	if success != 0 || failed != 1 {
+		t.Errorf("expected submission to fail, got %d success and %d failed", success, failed)
+	}
+	if entries, _ := os.ReadDir(staticDir); len(entries) != 0 {
+		t.Errorf("no files should be written for an invalid key")
+	}
+}
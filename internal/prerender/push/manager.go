@@ -6,19 +6,25 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/logging"
 	"prerender-shield/internal/redis"
 )
 
+// logger 渲染预热模块的日志记录器，日志级别可以单独设置
+var logger = logging.Module("prerender")
+
 // PushManager 推送管理器
 type PushManager struct {
 	config      *config.Config
 	redisClient *redis.Client
 	mutex       sync.Mutex
+	transport   *http.Transport // 推送请求共用的连接池
 }
 
 // NewPushManager 创建推送管理器实例
@@ -26,6 +32,7 @@ func NewPushManager(config *config.Config, redisClient *redis.Client) *PushManag
 	return &PushManager{
 		config:      config,
 		redisClient: redisClient,
+		transport:   newPushTransport(),
 	}
 }
 
@@ -35,12 +42,15 @@ type PushTask struct {
 	SiteID       string    `json:"siteId"`
 	SiteName     string    `json:"siteName"`
 	URLs         []string  `json:"urls"`
-	Status       string    `json:"status"` // pending, running, completed, failed
+	Status       string    `json:"status"` // pending, running, completed, failed, dry-run
 	CreatedAt    time.Time `json:"createdAt"`
 	StartedAt    time.Time `json:"startedAt,omitempty"`
 	CompletedAt  time.Time `json:"completedAt,omitempty"`
 	SuccessCount int       `json:"successCount"`
 	FailedCount  int       `json:"failedCount"`
+	// 试运行时本次将推送到各搜索引擎的完整URL
+	BaiduURLs []string `json:"baiduUrls,omitempty"`
+	BingURLs  []string `json:"bingUrls,omitempty"`
 }
 
 // PushLog 推送日志
@@ -57,7 +67,8 @@ type PushLog struct {
 }
 
 // TriggerPush 触发推送
-func (pm *PushManager) TriggerPush(siteID string) (string, error) {
+// dryRun为true时只计算本次将推送的URL并随任务返回，不调用搜索引擎接口，也不更新推送进度
+func (pm *PushManager) TriggerPush(siteID string, dryRun bool) (*PushTask, error) {
 	// 获取站点配置
 	var siteConfig *config.SiteConfig
 	for _, site := range pm.config.Sites {
@@ -68,12 +79,12 @@ func (pm *PushManager) TriggerPush(siteID string) (string, error) {
 	}
 
 	if siteConfig == nil {
-		return "", fmt.Errorf("site not found: %s", siteID)
+		return nil, fmt.Errorf("site not found: %s", siteID)
 	}
 
 	// 检查推送是否启用
 	if !siteConfig.Prerender.Push.Enabled {
-		return "", fmt.Errorf("push is not enabled for site: %s", siteID)
+		return nil, fmt.Errorf("push is not enabled for site: %s", siteID)
 	}
 
 	// 创建推送任务
@@ -88,15 +99,55 @@ func (pm *PushManager) TriggerPush(siteID string) (string, error) {
 		FailedCount:  0,
 	}
 
+	if dryRun {
+		return pm.dryRunPush(task, siteConfig)
+	}
+
 	// 保存任务到Redis
 	if err := pm.redisClient.SetPushTask(siteID, task); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// 异步执行推送
 	go pm.executePush(task, siteConfig)
 
-	return taskID, nil
+	return &task, nil
+}
+
+// dryRunPush 按当前推送进度和每日限额计算本次将推送的URL，只读取Redis，不保存任务
+func (pm *PushManager) dryRunPush(task PushTask, siteConfig *config.SiteConfig) (*PushTask, error) {
+	allURLs, err := pm.pushCandidates(siteConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	pushOffset, err := pm.redisClient.GetPushOffset(task.SiteID)
+	if err != nil {
+		pushOffset = 0
+	}
+	pushOffset = normalizePushOffset(pushOffset, len(allURLs))
+
+	history := pm.loadPushHistory(task.SiteID, config.PushEngineBaidu, config.PushEngineBing)
+	task.BaiduURLs, task.BingURLs = planPushURLs(allURLs, pushOffset, siteConfig.Prerender.Push, siteConfig, history, time.Now())
+	task.Status = "dry-run"
+	return &task, nil
+}
+
+// planPushURLs 计算从偏移量开始本次将推送到百度和必应的完整URL，与executePush的选取规则一致
+// history为各搜索引擎的URL最近推送日期
+func planPushURLs(allURLs []string, pushOffset int, pushConfig config.PushConfig, siteConfig *config.SiteConfig, history map[string]map[string]string, now time.Time) (baiduURLs, bingURLs []string) {
+	quota := subBatchLimiter(pushConfig.DailyBatches, now)
+	if pushConfig.BaiduAPI != "" && pushConfig.BaiduToken != "" {
+		for _, route := range selectDuePushURLs(allURLs, pushOffset, quota(pushConfig.BaiduDailyLimit), history[config.PushEngineBaidu], now, pushConfig.MinRepushDays, pushTargetFilter(pushConfig, config.PushEngineBaidu)) {
+			baiduURLs = append(baiduURLs, buildFullURL(pushConfig.PushDomain, siteConfig.Port, route))
+		}
+	}
+	if pushConfig.BingAPI != "" && pushConfig.BingToken != "" {
+		for _, route := range selectDuePushURLs(allURLs, pushOffset, quota(pushConfig.BingDailyLimit), history[config.PushEngineBing], now, pushConfig.MinRepushDays, pushTargetFilter(pushConfig, config.PushEngineBing)) {
+			bingURLs = append(bingURLs, buildFullURL(pushConfig.PushDomain, siteConfig.Port, route))
+		}
+	}
+	return baiduURLs, bingURLs
 }
 
 // executePush 执行推送任务
@@ -106,8 +157,8 @@ func (pm *PushManager) executePush(task PushTask, siteConfig *config.SiteConfig)
 	task.StartedAt = time.Now()
 	pm.redisClient.SetPushTask(task.SiteID, task)
 
-	// 获取站点的URL列表
-	allURLs, err := pm.redisClient.GetURLs(siteConfig.ID)
+	// 获取站点的URL列表，跳过标记为已推送且内容未变化的URL
+	allURLs, err := pm.pushCandidates(siteConfig)
 	if err != nil {
 		// 记录错误日志
 		task.Status = "failed"
@@ -119,88 +170,127 @@ func (pm *PushManager) executePush(task PushTask, siteConfig *config.SiteConfig)
 	pushConfig := siteConfig.Prerender.Push
 
 	// 获取今日日期
-	today := time.Now().Format("2006-01-02")
+	now := time.Now()
+	today := now.Format("2006-01-02")
 
 	// 获取当前推送进度
 	pushOffset, err := pm.redisClient.GetPushOffset(task.SiteID)
 	if err != nil {
 		pushOffset = 0
 	}
+	// URL列表可能在两次推送之间被删减，超出范围的偏移量从头开始
+	pushOffset = normalizePushOffset(pushOffset, len(allURLs))
 
 	// 推送URL到搜索引擎
 	totalPushed := 0
 	successCount := 0
 	failedCount := 0
 
-	// 分别处理百度和必应的推送，使用不同的偏移量逻辑
-	// 推送到百度
-	if pushConfig.BaiduAPI != "" && pushConfig.BaiduToken != "" {
-		// 计算百度本次推送的URL数量
-		var baiduUrlsToPush []string
-
-		// 计算百度本次推送的URL数量
-		baiduStart := pushOffset % len(allURLs)
-		baiduEnd := baiduStart + pushConfig.BaiduDailyLimit
-
-		// 如果超过URL总数，循环到开头
-		if baiduEnd > len(allURLs) {
-			// 推送剩余部分
-			baiduUrlsToPush = append(allURLs[baiduStart:], allURLs[:baiduEnd-len(allURLs)]...)
-		} else {
-			// 正常推送
-			baiduUrlsToPush = allURLs[baiduStart:baiduEnd]
+	// 计算本次推送后偏移量前进的步长，使用最小的限制作为基准，确保所有搜索引擎都能完成推送
+	minLimit := 0
+	for _, limit := range []int{pushConfig.BaiduDailyLimit, pushConfig.BingDailyLimit, pushConfig.GoogleDailyLimit} {
+		if limit > 0 && (minLimit == 0 || limit < minLimit) {
+			minLimit = limit
 		}
+	}
 
-		// 执行百度推送
-		for _, route := range baiduUrlsToPush {
-			// 构建完整URL
-			fullURL := buildFullURL(pushConfig.PushDomain, siteConfig.Port, route)
+	// 如果没有设置限制，使用默认值100
+	if minLimit == 0 {
+		minLimit = 100
+	}
 
-			if err := pm.pushToBaidu(fullURL, route, pushConfig, siteConfig); err != nil {
-				failedCount++
-			} else {
-				successCount++
-			}
-			totalPushed++
+	// 分别处理百度、必应、Google和IndexNow的推送，各自按每日限额从当前偏移量开始推送
+	// 配额耗尽的搜索引擎未推送完本次窗口，偏移量只前进到其已推送的位置，剩余URL下次继续推送
+	// 每日限额分为多个子批次时，偏移量每次只前进当前子批次的份额
+	batch := subBatchIndex(now, pushConfig.DailyBatches)
+	advance, _ := subBatchQuota(minLimit, pushConfig.DailyBatches, batch)
+	quota := subBatchLimiter(pushConfig.DailyBatches, now)
 
-			// 避免推送过快
-			time.Sleep(100 * time.Millisecond)
+	// 跳过未到再次推送间隔的URL，今日已推送的URL计入每日限额，同一天多次推送不会重复提交
+	history := pm.loadPushHistory(task.SiteID, config.PushEngineBaidu, config.PushEngineBing, config.PushEngineGoogle, config.PushEngineIndexNow)
+	dueURLs := func(engine string, limit int) []string {
+		return selectDuePushURLs(allURLs, pushOffset, limit, history[engine], now, pushConfig.MinRepushDays, pushTargetFilter(pushConfig, engine))
+	}
+	recordStats := func(engine string, success, failed int) {
+		if success+failed == 0 {
+			return
+		}
+		if err := pm.redisClient.IncrPushStats(task.SiteID, engine, success, failed); err != nil {
+			logger.Warn("Failed to update %s push stats for site %s: %v", engine, task.SiteID, err)
+		}
+	}
+	recordPushed := func(engine string, routes []string) {
+		if err := pm.redisClient.SetURLPushDates(task.SiteID, engine, routes, today); err != nil {
+			logger.Warn("Failed to record %s push dates for site %s: %v", engine, task.SiteID, err)
+		}
+		// 内容变化后重新推送的URL不再保留已推送标记
+		if err := pm.redisClient.ClearURLPushMarks(task.SiteID, routes); err != nil {
+			logger.Warn("Failed to clear push marks for site %s: %v", task.SiteID, err)
 		}
 	}
 
-	// 推送到必应
-	if pushConfig.BingAPI != "" && pushConfig.BingToken != "" {
-		// 计算必应本次推送的URL数量
-		var bingUrlsToPush []string
-
-		// 计算必应本次推送的URL数量
-		bingStart := pushOffset % len(allURLs)
-		bingEnd := bingStart + pushConfig.BingDailyLimit
+	// 推送到百度，按批提交
+	if pushConfig.BaiduAPI != "" && pushConfig.BaiduToken != "" {
+		routes := dueURLs(config.PushEngineBaidu, quota(pushConfig.BaiduDailyLimit))
+		result := pm.pushToBaidu(routes, pushConfig, siteConfig)
+		recordPushed(config.PushEngineBaidu, routes[:result.Processed()])
+		recordStats(config.PushEngineBaidu, result.Success, result.Failed)
+		successCount += result.Success
+		failedCount += result.Failed
+		totalPushed += result.Processed()
+		if result.QuotaExhausted && result.Processed() < advance {
+			advance = result.Processed()
+		}
+	}
 
-		// 如果超过URL总数，循环到开头
-		if bingEnd > len(allURLs) {
-			// 推送剩余部分
-			bingUrlsToPush = append(allURLs[bingStart:], allURLs[:bingEnd-len(allURLs)]...)
-		} else {
-			// 正常推送
-			bingUrlsToPush = allURLs[bingStart:bingEnd]
+	// 推送到必应，按批提交
+	if pushConfig.BingAPI != "" && pushConfig.BingToken != "" {
+		routes := dueURLs(config.PushEngineBing, quota(pushConfig.BingDailyLimit))
+		result := pm.pushToBing(routes, pushConfig, siteConfig)
+		recordPushed(config.PushEngineBing, routes[:result.Processed()])
+		recordStats(config.PushEngineBing, result.Success, result.Failed)
+		successCount += result.Success
+		failedCount += result.Failed
+		totalPushed += result.Processed()
+		if result.QuotaExhausted && result.Processed() < advance {
+			advance = result.Processed()
 		}
+	}
 
-		// 执行必应推送
-		for _, route := range bingUrlsToPush {
+	// 推送到Google
+	if pushConfig.GoogleServiceAccountJSON != "" {
+		// 执行Google推送
+		routes := dueURLs(config.PushEngineGoogle, quota(pushConfig.GoogleDailyLimit))
+		googleSuccess, googleFailed := 0, 0
+		for _, route := range routes {
 			// 构建完整URL
 			fullURL := buildFullURL(pushConfig.PushDomain, siteConfig.Port, route)
 
-			if err := pm.pushToBing(fullURL, route, pushConfig, siteConfig); err != nil {
-				failedCount++
+			if err := pm.pushToGoogle(fullURL, route, pushConfig, siteConfig); err != nil {
+				googleFailed++
 			} else {
-				successCount++
+				googleSuccess++
 			}
 			totalPushed++
 
 			// 避免推送过快
 			time.Sleep(100 * time.Millisecond)
 		}
+		recordPushed(config.PushEngineGoogle, routes)
+		recordStats(config.PushEngineGoogle, googleSuccess, googleFailed)
+		successCount += googleSuccess
+		failedCount += googleFailed
+	}
+
+	// 推送到IndexNow，批量提交本次偏移量窗口内的URL
+	if pushConfig.IndexNowEnabled && pushConfig.IndexNowKey != "" {
+		routes := dueURLs(config.PushEngineIndexNow, quota(minLimit))
+		success, failed := pm.pushToIndexNow(routes, pushConfig, siteConfig)
+		recordPushed(config.PushEngineIndexNow, routes)
+		recordStats(config.PushEngineIndexNow, success, failed)
+		successCount += success
+		failedCount += failed
+		totalPushed += len(routes)
 	}
 
 	// 更新每日推送计数
@@ -209,23 +299,7 @@ func (pm *PushManager) executePush(task PushTask, siteConfig *config.SiteConfig)
 	}
 
 	// 更新推送进度和日期
-	// 计算新的偏移量，使用最小的限制作为偏移量计算基准，确保所有搜索引擎都能完成推送
-	minLimit := pushConfig.BaiduDailyLimit
-	if minLimit == 0 || (pushConfig.BingDailyLimit > 0 && pushConfig.BingDailyLimit < minLimit) {
-		minLimit = pushConfig.BingDailyLimit
-	}
-
-	// 如果没有设置限制，使用默认值100
-	if minLimit == 0 {
-		minLimit = 100
-	}
-
-	newOffset := pushOffset + minLimit
-	if newOffset >= len(allURLs) {
-		newOffset = 0 // 推送完毕，重置偏移量
-	}
-
-	pm.redisClient.SetPushOffset(task.SiteID, newOffset)
+	pm.redisClient.SetPushOffset(task.SiteID, nextPushOffset(pushOffset, advance, len(allURLs)))
 	pm.redisClient.SetLastPushDate(task.SiteID, today)
 
 	// 更新任务状态
@@ -234,9 +308,187 @@ func (pm *PushManager) executePush(task PushTask, siteConfig *config.SiteConfig)
 	task.SuccessCount = successCount
 	task.FailedCount = failedCount
 	pm.redisClient.SetPushTask(task.SiteID, task)
+}
+
+// pushCandidates 获取站点待推送的URL，匹配站点排除规则的URL、标记为已推送且内容哈希与标记时相同的URL不参与推送
+func (pm *PushManager) pushCandidates(siteConfig *config.SiteConfig) ([]string, error) {
+	siteID := siteConfig.ID
+	allURLs, err := pm.redisClient.GetURLs(siteID)
+	if err != nil {
+		return nil, err
+	}
+	allURLs = filterExcludedURLs(allURLs, siteConfig)
+	marks, err := pm.redisClient.GetURLPushMarks(siteID)
+	if err != nil || len(marks) == 0 {
+		return allURLs, nil
+	}
+	hashes, err := pm.redisClient.GetURLContentHashes(siteID)
+	if err != nil {
+		return nil, err
+	}
+	return filterChangedURLs(allURLs, marks, hashes), nil
+}
+
+// filterExcludedURLs 过滤掉匹配站点排除规则的URL
+func filterExcludedURLs(allURLs []string, siteConfig *config.SiteConfig) []string {
+	if len(siteConfig.ExcludePatterns) == 0 {
+		return allURLs
+	}
+	urls := make([]string, 0, len(allURLs))
+	for _, route := range allURLs {
+		if !siteConfig.IsExcluded(route) {
+			urls = append(urls, route)
+		}
+	}
+	return urls
+}
+
+// filterChangedURLs 过滤掉标记为已推送且内容未变化的URL，未标记的URL保留
+func filterChangedURLs(allURLs []string, marks, hashes map[string]string) []string {
+	var urls []string
+	for _, route := range allURLs {
+		if markedHash, marked := marks[route]; marked && markedHash == hashes[route] {
+			continue
+		}
+		urls = append(urls, route)
+	}
+	return urls
+}
+
+// MarkAllPushed 将站点当前所有URL标记为已推送，记录当前内容哈希，内容变化前的推送都会跳过这些URL，
+// 用于导入已被搜索引擎收录的站点时避免重复推送；返回标记的URL数量
+func (pm *PushManager) MarkAllPushed(siteID string) (int, error) {
+	found := false
+	for _, site := range pm.config.Sites {
+		if site.ID == siteID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("site not found: %s", siteID)
+	}
+
+	allURLs, err := pm.redisClient.GetURLs(siteID)
+	if err != nil {
+		return 0, err
+	}
+	hashes, err := pm.redisClient.GetURLContentHashes(siteID)
+	if err != nil {
+		return 0, err
+	}
+
+	marks := make(map[string]string, len(allURLs))
+	for _, route := range allURLs {
+		marks[route] = hashes[route]
+	}
+	if err := pm.redisClient.SetURLPushMarks(siteID, marks); err != nil {
+		return 0, err
+	}
+	return len(marks), nil
+}
+
+// selectPushURLs 从偏移量开始选取不超过limit个URL，超过末尾时循环到开头
+func selectPushURLs(allURLs []string, offset, limit int) []string {
+	if len(allURLs) == 0 || limit <= 0 {
+		return nil
+	}
+	if limit > len(allURLs) {
+		limit = len(allURLs)
+	}
+
+	start := normalizePushOffset(offset, len(allURLs))
+	end := start + limit
+
+	// 如果超过URL总数，循环到开头
+	if end > len(allURLs) {
+		return append(append([]string{}, allURLs[start:]...), allURLs[:end-len(allURLs)]...)
+	}
+	return allURLs[start:end]
+}
+
+// selectDuePushURLs 从偏移量开始循环选取不超过每日剩余限额的URL，跳过距上次推送不足minDays天的URL，
+// include不为nil时只选取其返回true的URL
+// lastPushed为各URL最近推送到该搜索引擎的日期，今日已推送的URL计入每日限额，
+// 因此限额超过URL总数或同一天多次推送时，每个URL当天最多推送一次
+func selectDuePushURLs(allURLs []string, offset, limit int, lastPushed map[string]string, now time.Time, minDays int, include func(route string) bool) []string {
+	today := now.Format("2006-01-02")
+	for _, date := range lastPushed {
+		if date == today {
+			limit--
+		}
+	}
+
+	var routes []string
+	for _, route := range selectPushURLs(allURLs, offset, len(allURLs)) {
+		if len(routes) >= limit {
+			break
+		}
+		if (include == nil || include(route)) && isPushDue(lastPushed[route], now, minDays) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// pushTargetFilter 返回按推送目标规则判断URL是否推送到指定搜索引擎的函数，未配置规则时返回nil
+func pushTargetFilter(pushConfig config.PushConfig, engine string) func(route string) bool {
+	if len(pushConfig.TargetRules) == 0 {
+		return nil
+	}
+	return func(route string) bool {
+		if !strings.HasPrefix(route, "/") {
+			route = "/" + route
+		}
+		return pushConfig.ShouldPushTo(route, engine)
+	}
+}
+
+// isPushDue 判断距上次推送是否已满minDays天，minDays小于1时按1天处理
+func isPushDue(lastDate string, now time.Time, minDays int) bool {
+	if lastDate == "" {
+		return true
+	}
+	last, err := time.ParseInLocation("2006-01-02", lastDate, now.Location())
+	if err != nil {
+		return true
+	}
+	if minDays < 1 {
+		minDays = 1
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return !last.AddDate(0, 0, minDays).After(today)
+}
 
-	// 更新站点统计
-	pm.redisClient.IncrPushStats(task.SiteID, successCount, failedCount)
+// loadPushHistory 读取URL最近推送到各搜索引擎的日期，读取失败时视为没有推送记录
+func (pm *PushManager) loadPushHistory(siteID string, engines ...string) map[string]map[string]string {
+	history := make(map[string]map[string]string, len(engines))
+	for _, engine := range engines {
+		dates, err := pm.redisClient.GetURLPushDates(siteID, engine)
+		if err != nil {
+			logger.Warn("Failed to load %s push dates for site %s: %v", engine, siteID, err)
+			continue
+		}
+		history[engine] = dates
+	}
+	return history
+}
+
+// normalizePushOffset 校正保存的推送偏移量，URL列表为空或偏移量超出当前列表长度时从头开始
+func normalizePushOffset(offset, total int) int {
+	if total <= 0 || offset < 0 || offset >= total {
+		return 0
+	}
+	return offset
+}
+
+// nextPushOffset 计算本次推送后的偏移量，推送到列表末尾后重置为0
+func nextPushOffset(offset, advance, total int) int {
+	next := normalizePushOffset(offset, total) + advance
+	if next >= total || next < 0 {
+		return 0
+	}
+	return next
 }
 
 // buildFullURL 构建完整URL
@@ -269,98 +521,243 @@ func buildFullURL(pushDomain string, port int, route string) string {
 	return urlBuilder.String()
 }
 
-// pushToBaidu 推送到百度
-func (pm *PushManager) pushToBaidu(url, route string, pushConfig config.PushConfig, siteConfig *config.SiteConfig) error {
-	// 构建请求
-	req, err := http.NewRequest("POST", pushConfig.BaiduAPI, bytes.NewBuffer([]byte(url)))
+// 单次请求最多提交的URL数量，百度普通收录接口限制为2000，必应批量接口限制为500
+const (
+	maxBaiduBatchSize = 2000
+	maxBingBatchSize  = 500
+)
+
+// pushBatchSize 返回实际使用的批大小，未配置或超过上限时使用上限
+func pushBatchSize(configured, max int) int {
+	if configured <= 0 || configured > max {
+		return max
+	}
+	return configured
+}
+
+// forEachBatch 将n个元素按size分批，依次回调每批的起止下标，回调返回false时停止
+func forEachBatch(n, size int, fn func(start, end int) bool) {
+	for start := 0; start < n; start += size {
+		end := start + size
+		if end > n {
+			end = n
+		}
+		if !fn(start, end) {
+			return
+		}
+	}
+}
+
+// batchPushResult 按批推送的结果
+type batchPushResult struct {
+	Success        int
+	Failed         int
+	QuotaExhausted bool // 配额耗尽提前停止，剩余URL未推送
+}
+
+// Processed 已推送（成功或重试后仍失败）的URL数量
+func (r batchPushResult) Processed() int {
+	return r.Success + r.Failed
+}
+
+// baiduPushResponse 百度普通收录接口响应
+type baiduPushResponse struct {
+	Success     int      `json:"success"`
+	Remain      int      `json:"remain"`
+	NotSameSite []string `json:"not_same_site"` // 不是本站的URL
+	NotValid    []string `json:"not_valid"`     // 不合法的URL
+	Error       int      `json:"error"`
+	Message     string   `json:"message"`
+}
+
+// pushToBaidu 按批推送到百度，每批URL以换行分隔放在同一个请求体中
+// 临时错误按配置的次数重试，配额耗尽时停止推送剩余的批次
+func (pm *PushManager) pushToBaidu(routes []string, pushConfig config.PushConfig, siteConfig *config.SiteConfig) batchPushResult {
+	var result batchPushResult
+	client := pm.httpClient(pushConfig)
+
+	forEachBatch(len(routes), pushBatchSize(pushConfig.BatchSize, maxBaiduBatchSize), func(start, end int) bool {
+		batchRoutes := routes[start:end]
+		urls := make([]string, len(batchRoutes))
+		for i, route := range batchRoutes {
+			urls[i] = buildFullURL(pushConfig.PushDomain, siteConfig.Port, route)
+		}
+
+		var rejected map[string]string
+		var body string
+		err := withRetry(pushConfig.MaxAttempts, func() error {
+			var err error
+			rejected, body, err = submitBaiduBatch(client, pushConfig, urls)
+			return err
+		})
+		if isQuotaExhausted(err) {
+			result.QuotaExhausted = true
+			return false
+		}
+		if err != nil {
+			for i := range urls {
+				pm.logPushResult(siteConfig.ID, siteConfig.Name, urls[i], batchRoutes[i], "baidu", "failed", err.Error())
+			}
+			result.Failed += len(urls)
+			return true
+		}
+
+		// 百度响应中列出了被拒绝的URL，其余URL视为推送成功
+		for i, u := range urls {
+			if reason, ok := rejected[u]; ok {
+				pm.logPushResult(siteConfig.ID, siteConfig.Name, u, batchRoutes[i], "baidu", "failed", reason)
+				result.Failed++
+				continue
+			}
+			pm.logPushResult(siteConfig.ID, siteConfig.Name, u, batchRoutes[i], "baidu", "success", body)
+			result.Success++
+		}
+		return true
+	})
+
+	return result
+}
+
+// submitBaiduBatch 提交一批URL到百度，返回被拒绝的URL及原因和响应内容
+func submitBaiduBatch(client *http.Client, pushConfig config.PushConfig, urls []string) (map[string]string, string, error) {
+	req, err := http.NewRequest("POST", pushConfig.BaiduAPI, strings.NewReader(strings.Join(urls, "\n")))
 	if err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "baidu", "failed", err.Error())
-		return err
+		return nil, "", err
 	}
 
 	req.Header.Set("Content-Type", "text/plain")
 	req.Header.Set("Authorization", fmt.Sprintf("token %s", pushConfig.BaiduToken))
 
-	// 发送请求
-	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "baidu", "failed", err.Error())
-		return err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
-	// 读取响应
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "baidu", "failed", err.Error())
-		return err
+		return nil, "", err
 	}
 
-	// 解析响应
-	var result map[string]interface{}
+	var result baiduPushResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "baidu", "success", string(body))
-		return nil
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", newPushError(resp.StatusCode, string(body))
+		}
+		// 无法解析的成功响应按全部成功处理
+		return nil, string(body), nil
+	}
+	if resp.StatusCode != http.StatusOK || result.Error != 0 {
+		return nil, "", newPushError(resp.StatusCode, string(body))
 	}
 
-	// 检查结果
-	if success, ok := result["success"].(float64); ok && success > 0 {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "baidu", "success", string(body))
-		return nil
+	rejected := make(map[string]string, len(result.NotSameSite)+len(result.NotValid))
+	for _, u := range result.NotSameSite {
+		rejected[u] = "not_same_site"
+	}
+	for _, u := range result.NotValid {
+		rejected[u] = "not_valid"
 	}
+	return rejected, string(body), nil
+}
+
+// pushToBing 按批推送到必应批量提交接口
+// 必应只返回整批的结果，因此一批中的URL同时成功或失败；临时错误按配置的次数重试，配额耗尽时停止推送剩余的批次
+func (pm *PushManager) pushToBing(routes []string, pushConfig config.PushConfig, siteConfig *config.SiteConfig) batchPushResult {
+	var result batchPushResult
+	client := pm.httpClient(pushConfig)
+
+	forEachBatch(len(routes), pushBatchSize(pushConfig.BatchSize, maxBingBatchSize), func(start, end int) bool {
+		batchRoutes := routes[start:end]
+		urls := make([]string, len(batchRoutes))
+		for i, route := range batchRoutes {
+			urls[i] = buildFullURL(pushConfig.PushDomain, siteConfig.Port, route)
+		}
+
+		var body string
+		err := withRetry(pushConfig.MaxAttempts, func() error {
+			var err error
+			body, err = submitBingBatch(client, pushConfig, urls)
+			return err
+		})
+		if isQuotaExhausted(err) {
+			result.QuotaExhausted = true
+			return false
+		}
+
+		status, message := "success", body
+		if err != nil {
+			status, message = "failed", err.Error()
+			result.Failed += len(urls)
+		} else {
+			result.Success += len(urls)
+		}
+		for i := range urls {
+			pm.logPushResult(siteConfig.ID, siteConfig.Name, urls[i], batchRoutes[i], "bing", status, message)
+		}
+		return true
+	})
 
-	pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "baidu", "failed", string(body))
-	return fmt.Errorf("baidu push failed: %s", string(body))
+	return result
 }
 
-// pushToBing 推送到必应
-func (pm *PushManager) pushToBing(url, route string, pushConfig config.PushConfig, siteConfig *config.SiteConfig) error {
-	// 构建请求体
-	reqBody := map[string]string{
-		"apikey": pushConfig.BingToken,
-		"url":    url,
+// bingBatchEndpoint 返回必应批量提交地址，兼容配置为单条提交接口(SubmitUrl)的情况
+func bingBatchEndpoint(api, apiKey string) (string, error) {
+	if strings.HasSuffix(api, "/SubmitUrl") {
+		api += "batch"
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	endpoint, err := url.Parse(api)
 	if err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "bing", "failed", err.Error())
-		return err
+		return "", err
 	}
+	query := endpoint.Query()
+	query.Set("apikey", apiKey)
+	endpoint.RawQuery = query.Encode()
+	return endpoint.String(), nil
+}
 
-	// 构建请求
-	req, err := http.NewRequest("POST", pushConfig.BingAPI, bytes.NewBuffer(jsonData))
+// submitBingBatch 提交一批URL到必应，返回响应内容
+func submitBingBatch(client *http.Client, pushConfig config.PushConfig, urls []string) (string, error) {
+	endpoint, err := bingBatchEndpoint(pushConfig.BingAPI, pushConfig.BingToken)
 	if err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "bing", "failed", err.Error())
-		return err
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	// 同一批URL属于同一站点，siteUrl取第一个URL的协议和主机
+	siteURL := ""
+	if parsed, err := url.Parse(urls[0]); err == nil {
+		siteURL = fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host)
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"siteUrl": siteURL,
+		"urlList": urls,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
-	// 发送请求
-	client := &http.Client{Timeout: 10 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "bing", "failed", err.Error())
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	// 读取响应
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "bing", "failed", err.Error())
-		return err
+		return "", err
 	}
-
-	// 检查响应状态
-	if resp.StatusCode == http.StatusOK {
-		pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "bing", "success", string(body))
-		return nil
+	if resp.StatusCode != http.StatusOK {
+		return "", newPushError(resp.StatusCode, string(body))
 	}
-
-	pm.logPushResult(siteConfig.ID, siteConfig.Name, url, route, "bing", "failed", string(body))
-	return fmt.Errorf("bing push failed: %s", string(body))
+	return string(body), nil
 }
 
 // logPushResult 记录推送结果
@@ -378,19 +775,12 @@ func (pm *PushManager) logPushResult(siteID, siteName, url, route, searchEngine,
 	}
 
 	// 保存到Redis
+	if pm.redisClient == nil {
+		return
+	}
 	pm.redisClient.AddPushLog(siteID, log)
 }
 
-// GetPushStats 获取推送统计
-func (pm *PushManager) GetPushStats(siteID string) (map[string]interface{}, error) {
-	return pm.redisClient.GetPushStatsWithURLCounts(siteID)
-}
-
-// GetPushTrend 获取最近15天的推送趋势
-func (pm *PushManager) GetPushTrend(siteID string) (map[string]int64, error) {
-	return pm.redisClient.GetLast15DaysPushCount(siteID)
-}
-
 // GetPushLogs 获取推送日志
 func (pm *PushManager) GetPushLogs(siteID string, limit, offset int) ([]PushLog, error) {
 	// 从Redis获取日志
@@ -0,0 +1,381 @@
+package push
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/redis"
+)
+
+// TestPushToBaiduBatches 测试百度按批提交，并根据响应中的not_valid统计失败URL
+func TestPushToBaiduBatches(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token baidu-token" {
+			t.Errorf("unexpected Authorization header: %s", r.Header.Get("Authorization"))
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		urls := strings.Split(string(body), "\n")
+		batches = append(batches, urls)
+
+		// 第一批中的/b被判定为不合法
+		if len(batches) == 1 {
+			fmt.Fprintf(w, `{"remain":100,"success":%d,"not_valid":["http://example.com/b"]}`, len(urls)-1)
+			return
+		}
+		fmt.Fprintf(w, `{"remain":100,"success":%d}`, len(urls))
+	}))
+	defer server.Close()
+
+	pm := NewPushManager(&config.Config{}, nil)
+	pushConfig := config.PushConfig{BaiduAPI: server.URL, BaiduToken: "baidu-token", PushDomain: "example.com", BatchSize: 2}
+	siteConfig := &config.SiteConfig{ID: "site1", Name: "Site 1", Port: 80}
+
+	result := pm.pushToBaidu([]string{"/a", "/b", "/c", "/d", "/e"}, pushConfig, siteConfig)
+	if success, failed := result.Success, result.Failed; success != 4 || failed != 1 {
+		t.Errorf("expected 4 success and 1 failed, got %d and %d", success, failed)
+	}
+	if len(batches) != 3 {
+		t.Fatalf("expected 3 batch requests, got %d", len(batches))
+	}
+	if strings.Join(batches[0], ",") != "http://example.com/a,http://example.com/b" {
+		t.Errorf("unexpected first batch: %v", batches[0])
+	}
+}
+
+// TestPushToBingBatches 测试必应使用批量接口提交，配额耗尽时停止推送剩余批次
+func TestPushToBingBatches(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json/SubmitUrlbatch" || r.URL.Query().Get("apikey") != "bing-key" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads = append(payloads, payload)
+
+		// 第二批模拟超出配额
+		if len(payloads) == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"ErrorCode":2,"Message":"ERROR!!! Quota remaining for today: 0"}`)
+			return
+		}
+		fmt.Fprint(w, `{"d":null}`)
+	}))
+	defer server.Close()
+
+	pm := NewPushManager(&config.Config{}, nil)
+	pushConfig := config.PushConfig{BingAPI: server.URL + "/json/SubmitUrl", BingToken: "bing-key", PushDomain: "example.com", BatchSize: 3}
+	siteConfig := &config.SiteConfig{ID: "site1", Name: "Site 1", Port: 80}
+
+	result := pm.pushToBing([]string{"/a", "/b", "/c", "/d", "/e"}, pushConfig, siteConfig)
+	if result.Success != 3 || result.Failed != 0 || !result.QuotaExhausted {
+		t.Errorf("expected 3 success and quota exhausted, got %+v", result)
+	}
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 batch requests, got %d", len(payloads))
+	}
+	if payloads[0]["siteUrl"] != "http://example.com" || len(payloads[0]["urlList"].([]interface{})) != 3 {
+		t.Errorf("unexpected first payload: %v", payloads[0])
+	}
+}
+
+// TestPushBatchSize 测试批大小受各搜索引擎上限约束
+func TestPushBatchSize(t *testing.T) {
+	if got := pushBatchSize(0, maxBingBatchSize); got != maxBingBatchSize {
+		t.Errorf("expected default %d, got %d", maxBingBatchSize, got)
+	}
+	if got := pushBatchSize(5000, maxBaiduBatchSize); got != maxBaiduBatchSize {
+		t.Errorf("expected cap %d, got %d", maxBaiduBatchSize, got)
+	}
+	if got := pushBatchSize(100, maxBaiduBatchSize); got != 100 {
+		t.Errorf("expected 100, got %d", got)
+	}
+}
+
+// TestPushRetryTransientErrors 测试临时错误按指数退避重试，非临时错误不重试
+func TestPushRetryTransientErrors(t *testing.T) {
+	originalDelay := pushRetryBaseDelay
+	pushRetryBaseDelay = time.Millisecond
+	defer func() { pushRetryBaseDelay = originalDelay }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/flaky") && requests < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case strings.HasPrefix(r.URL.Path, "/invalid"):
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"ErrorCode":3,"Message":"ERROR!!! InvalidUrl"}`)
+		default:
+			fmt.Fprint(w, `{"d":null}`)
+		}
+	}))
+	defer server.Close()
+
+	pm := NewPushManager(&config.Config{}, nil)
+	siteConfig := &config.SiteConfig{ID: "site1", Name: "Site 1", Port: 80}
+
+	// 前两次返回503，第三次成功
+	pushConfig := config.PushConfig{BingAPI: server.URL + "/flaky", BingToken: "key", PushDomain: "example.com", MaxAttempts: 3}
+	result := pm.pushToBing([]string{"/a", "/b"}, pushConfig, siteConfig)
+	if result.Success != 2 || result.Failed != 0 || requests != 3 {
+		t.Errorf("expected success after 3 attempts, got %+v after %d requests", result, requests)
+	}
+
+	// 尝试次数用尽后计为失败
+	requests = 0
+	pushConfig.MaxAttempts = 2
+	result = pm.pushToBing([]string{"/a"}, pushConfig, siteConfig)
+	if result.Failed != 1 || requests != 2 {
+		t.Errorf("expected failure after 2 attempts, got %+v after %d requests", result, requests)
+	}
+
+	// 400不重试
+	requests = 0
+	pushConfig = config.PushConfig{BingAPI: server.URL + "/invalid", BingToken: "key", PushDomain: "example.com", MaxAttempts: 3}
+	result = pm.pushToBing([]string{"/a"}, pushConfig, siteConfig)
+	if result.Failed != 1 || result.QuotaExhausted || requests != 1 {
+		t.Errorf("expected a single failed attempt, got %+v after %d requests", result, requests)
+	}
+}
+
+// TestBaiduQuotaExhausted 测试百度返回over quota时停止推送且不计为失败
+func TestBaiduQuotaExhausted(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":400,"message":"over quota"}`)
+	}))
+	defer server.Close()
+
+	pm := NewPushManager(&config.Config{}, nil)
+	pushConfig := config.PushConfig{BaiduAPI: server.URL, BaiduToken: "token", PushDomain: "example.com", BatchSize: 1}
+	result := pm.pushToBaidu([]string{"/a", "/b", "/c"}, pushConfig, &config.SiteConfig{ID: "site1", Port: 80})
+
+	if !result.QuotaExhausted || result.Processed() != 0 || requests != 1 {
+		t.Errorf("expected run to stop on quota exhaustion, got %+v after %d requests", result, requests)
+	}
+}
+
+// TestPlanPushURLs 测试试运行按偏移量和各搜索引擎每日限额选取URL，未配置的搜索引擎不选取
+func TestPlanPushURLs(t *testing.T) {
+	allURLs := []string{"/a", "/b", "/c", "/d"}
+	pushConfig := config.PushConfig{
+		BaiduAPI: "http://baidu", BaiduToken: "token", BaiduDailyLimit: 3,
+		BingAPI: "http://bing", BingDailyLimit: 2,
+		PushDomain: "example.com",
+	}
+	siteConfig := &config.SiteConfig{ID: "site1", Port: 8080}
+
+	baiduURLs, bingURLs := planPushURLs(allURLs, 2, pushConfig, siteConfig, nil, time.Now())
+	if strings.Join(baiduURLs, ",") != "http://example.com:8080/c,http://example.com:8080/d,http://example.com:8080/a" {
+		t.Errorf("unexpected baidu urls: %v", baiduURLs)
+	}
+	if len(bingURLs) != 0 {
+		t.Errorf("expected no bing urls without a token, got %v", bingURLs)
+	}
+
+	pushConfig.BingToken = "key"
+	_, bingURLs = planPushURLs(allURLs, 2, pushConfig, siteConfig, nil, time.Now())
+	if strings.Join(bingURLs, ",") != "http://example.com:8080/c,http://example.com:8080/d" {
+		t.Errorf("unexpected bing urls: %v", bingURLs)
+	}
+}
+
+// TestSelectDuePushURLs 测试每日限额超过URL总数时每个URL当天只推送一次，并按最小间隔天数跳过近期推送过的URL
+func TestSelectDuePushURLs(t *testing.T) {
+	var allURLs []string
+	for i := 0; i < 10; i++ {
+		allURLs = append(allURLs, fmt.Sprintf("/page%d", i))
+	}
+	now := time.Date(2024, 5, 10, 15, 0, 0, 0, time.Local)
+	today := now.Format("2006-01-02")
+
+	routes := selectDuePushURLs(allURLs, 3, 100, nil, now, 0, nil)
+	distinct := make(map[string]bool)
+	for _, route := range routes {
+		distinct[route] = true
+	}
+	if len(routes) != 10 || len(distinct) != 10 {
+		t.Fatalf("expected 10 distinct urls, got %v", routes)
+	}
+
+	// 同一天再次推送时不再重复提交
+	lastPushed := make(map[string]string)
+	for _, route := range routes {
+		lastPushed[route] = today
+	}
+	if got := selectDuePushURLs(allURLs, 3, 100, lastPushed, now, 0, nil); len(got) != 0 {
+		t.Errorf("expected no urls on the same day, got %v", got)
+	}
+
+	// 第二天全部重新推送
+	tomorrow := now.AddDate(0, 0, 1)
+	if got := selectDuePushURLs(allURLs, 0, 100, lastPushed, tomorrow, 1, nil); len(got) != 10 {
+		t.Errorf("expected 10 urls the next day, got %v", got)
+	}
+	// 最小间隔3天时，2天后不推送，3天后推送
+	if got := selectDuePushURLs(allURLs, 0, 100, lastPushed, now.AddDate(0, 0, 2), 3, nil); len(got) != 0 {
+		t.Errorf("expected no urls within 3 days, got %v", got)
+	}
+	if got := selectDuePushURLs(allURLs, 0, 100, lastPushed, now.AddDate(0, 0, 3), 3, nil); len(got) != 10 {
+		t.Errorf("expected 10 urls after 3 days, got %v", got)
+	}
+
+	// 今日已推送的URL计入每日限额
+	partial := map[string]string{"/page0": today, "/page1": today, "/page2": "2024-05-01"}
+	got := selectDuePushURLs(allURLs, 0, 5, partial, now, 0, nil)
+	if strings.Join(got, ",") != "/page2,/page3,/page4" {
+		t.Errorf("unexpected urls with partial budget: %v", got)
+	}
+}
+
+// TestMarkAllPushedSkipsUnchangedURLs 测试标记所有URL为已推送后，推送跳过这些URL，直到URL内容变化
+func TestMarkAllPushedSkipsUnchangedURLs(t *testing.T) {
+	redisClient, err := redis.NewClient(miniredis.RunT(t).Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	site := config.SiteConfig{ID: "site1", Port: 8080}
+	site.Prerender.Push = config.PushConfig{
+		Enabled:  true,
+		BaiduAPI: "http://baidu", BaiduToken: "token", BaiduDailyLimit: 100,
+		PushDomain: "example.com",
+	}
+	pm := NewPushManager(&config.Config{Sites: []config.SiteConfig{site}}, redisClient)
+
+	for _, route := range []string{"/a", "/b", "/c"} {
+		redisClient.AddURL("site1", route)
+		redisClient.SetURLContentHash("site1", route, "hash-"+route)
+	}
+	// 未渲染过的URL没有内容哈希
+	redisClient.AddURL("site1", "/d")
+
+	plan := func() []string {
+		task, err := pm.TriggerPush("site1", true)
+		if err != nil {
+			t.Fatalf("dry run failed: %v", err)
+		}
+		return task.BaiduURLs
+	}
+	if got := plan(); len(got) != 4 {
+		t.Fatalf("expected all 4 urls before marking, got %v", got)
+	}
+
+	marked, err := pm.MarkAllPushed("site1")
+	if err != nil || marked != 4 {
+		t.Fatalf("MarkAllPushed = %d, %v; expected 4 urls", marked, err)
+	}
+	if got := plan(); len(got) != 0 {
+		t.Errorf("expected nothing to push after marking, got %v", got)
+	}
+
+	// 内容变化或首次渲染后的URL重新参与推送
+	redisClient.SetURLContentHash("site1", "/b", "hash-/b-v2")
+	redisClient.SetURLContentHash("site1", "/d", "hash-/d")
+	if got := plan(); strings.Join(got, ",") != "http://example.com:8080/b,http://example.com:8080/d" {
+		t.Errorf("expected only changed urls to be pushed, got %v", got)
+	}
+
+	// 推送后移除标记
+	redisClient.ClearURLPushMarks("site1", []string{"/b"})
+	marks, _ := redisClient.GetURLPushMarks("site1")
+	if _, ok := marks["/b"]; ok || len(marks) != 3 {
+		t.Errorf("unexpected marks after clearing: %v", marks)
+	}
+
+	if _, err := pm.MarkAllPushed("unknown"); err == nil {
+		t.Error("expected error for unknown site")
+	}
+}
+
+// TestPushTargetRules 测试按URL路径规则选择推送的搜索引擎
+func TestPushTargetRules(t *testing.T) {
+	allURLs := []string{"/en/about", "/zh/about", "/index.html"}
+	pushConfig := config.PushConfig{
+		BaiduAPI: "http://baidu", BaiduToken: "token", BaiduDailyLimit: 100,
+		BingAPI: "http://bing", BingToken: "key", BingDailyLimit: 100,
+		PushDomain: "example.com",
+		TargetRules: []config.PushTargetRule{
+			{Pattern: "/en/", Engines: []string{config.PushEngineBing, config.PushEngineGoogle}},
+			{Pattern: "/zh/", Engines: []string{config.PushEngineBaidu}},
+		},
+	}
+	now := time.Now()
+
+	expected := map[string]string{
+		config.PushEngineBaidu:    "/zh/about,/index.html",
+		config.PushEngineBing:     "/en/about,/index.html",
+		config.PushEngineGoogle:   "/en/about,/index.html",
+		config.PushEngineIndexNow: "/index.html",
+	}
+	for engine, want := range expected {
+		got := selectDuePushURLs(allURLs, 0, 100, nil, now, 0, pushTargetFilter(pushConfig, engine))
+		if strings.Join(got, ",") != want {
+			t.Errorf("%s: expected %s, got %v", engine, want, got)
+		}
+	}
+
+	// 预览推送与实际推送使用相同的规则
+	baiduURLs, bingURLs := planPushURLs(allURLs, 0, pushConfig, &config.SiteConfig{ID: "site1", Port: 80}, nil, now)
+	if strings.Join(baiduURLs, ",") != "http://example.com/zh/about,http://example.com/index.html" {
+		t.Errorf("unexpected baidu urls: %v", baiduURLs)
+	}
+	if strings.Join(bingURLs, ",") != "http://example.com/en/about,http://example.com/index.html" {
+		t.Errorf("unexpected bing urls: %v", bingURLs)
+	}
+
+	// 通配符规则，规则未配置搜索引擎时不推送
+	pushConfig.TargetRules = []config.PushTargetRule{{Pattern: "/*/about"}}
+	if got := selectDuePushURLs(allURLs, 0, 100, nil, now, 0, pushTargetFilter(pushConfig, config.PushEngineBing)); strings.Join(got, ",") != "/index.html" {
+		t.Errorf("expected matching urls to be excluded, got %v", got)
+	}
+}
+
+// TestPushExcludePatterns 测试匹配站点排除规则的URL不推送
+func TestPushExcludePatterns(t *testing.T) {
+	redisClient, err := redis.NewClient(miniredis.RunT(t).Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	site := config.SiteConfig{ID: "site1", Port: 80, ExcludePatterns: []string{"/admin/", `^/account\?`}}
+	site.Prerender.Push = config.PushConfig{
+		Enabled:  true,
+		BaiduAPI: "http://baidu", BaiduToken: "token", BaiduDailyLimit: 100,
+		BingAPI: "http://bing", BingToken: "key", BingDailyLimit: 100,
+		PushDomain: "example.com",
+	}
+	pm := NewPushManager(&config.Config{Sites: []config.SiteConfig{site}}, redisClient)
+
+	for _, route := range []string{"/", "/admin/users", "/account?tab=orders", "/about"} {
+		redisClient.AddURL("site1", route)
+	}
+
+	task, err := pm.TriggerPush("site1", true)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	want := "http://example.com/,http://example.com/about"
+	if got := strings.Join(task.BaiduURLs, ","); got != want {
+		t.Errorf("baidu: expected %s, got %s", want, got)
+	}
+	if got := strings.Join(task.BingURLs, ","); got != want {
+		t.Errorf("bing: expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,82 @@
+package push
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultPushMaxAttempts 未配置时单批推送的最大尝试次数
+const defaultPushMaxAttempts = 3
+
+// pushRetryBaseDelay 第一次重试前的等待时间，之后每次翻倍
+var pushRetryBaseDelay = time.Second
+
+// pushRetryMaxDelay 单次重试等待时间上限
+var pushRetryMaxDelay = 30 * time.Second
+
+// pushError 搜索引擎接口返回的错误
+type pushError struct {
+	StatusCode     int
+	Message        string
+	Retryable      bool // 429或5xx等临时错误，可以重试
+	QuotaExhausted bool // 今日配额已用完，应停止本次推送
+}
+
+func (e *pushError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+// newPushError 根据响应状态码和内容构造推送错误
+func newPushError(statusCode int, body string) *pushError {
+	lowerBody := strings.ToLower(body)
+	return &pushError{
+		StatusCode: statusCode,
+		Message:    body,
+		Retryable:  statusCode == http.StatusTooManyRequests || statusCode >= 500,
+		// 百度返回 {"error":400,"message":"over quota"}，必应返回 "Quota remaining for today: 0"
+		QuotaExhausted: strings.Contains(lowerBody, "over quota") || strings.Contains(lowerBody, "quota remaining for today: 0"),
+	}
+}
+
+// isQuotaExhausted 判断错误是否表示配额已用完
+func isQuotaExhausted(err error) bool {
+	var pe *pushError
+	return errors.As(err, &pe) && pe.QuotaExhausted
+}
+
+// withRetry 执行推送请求，可重试的错误按指数退避加随机抖动重试，最多尝试maxAttempts次
+// 网络错误视为可重试，接口明确拒绝或配额耗尽时立即返回
+func withRetry(maxAttempts int, fn func() error) error {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultPushMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxAttempts {
+			return err
+		}
+		var pe *pushError
+		if errors.As(err, &pe) && (!pe.Retryable || pe.QuotaExhausted) {
+			return err
+		}
+		time.Sleep(retryBackoff(attempt))
+	}
+}
+
+// retryBackoff 计算第attempt次失败后的等待时间，在退避时间的一半到全部之间随机取值
+func retryBackoff(attempt int) time.Duration {
+	delay := pushRetryBaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > pushRetryMaxDelay {
+		delay = pushRetryMaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,76 @@
+package push
+
+import "time"
+
+// 每日限额按子批次分散推送：一天从0点起均分为batches个时段，每个时段开始时推送一个子批次。
+// 今日已推送的URL计入每日限额，因此每个子批次按截至该时段的累计限额选取URL，
+// 错过的子批次会在下一个子批次中补齐，一天推送的总数不会超过每日限额。
+
+// splitDailyQuota 将每日限额平均分为batches份，余数分给靠前的子批次，batches小于2时不拆分
+func splitDailyQuota(limit, batches int) []int {
+	if batches < 2 {
+		return []int{limit}
+	}
+	shares := make([]int, batches)
+	for i := range shares {
+		shares[i] = limit / batches
+		if i < limit%batches {
+			shares[i]++
+		}
+	}
+	return shares
+}
+
+// subBatchQuota 返回第index个子批次的限额和截至该子批次（含）的累计限额
+func subBatchQuota(limit, batches, index int) (share, cumulative int) {
+	shares := splitDailyQuota(limit, batches)
+	if index < 0 || index >= len(shares) {
+		index = len(shares) - 1
+	}
+	for _, s := range shares[:index+1] {
+		cumulative += s
+	}
+	return shares[index], cumulative
+}
+
+// subBatchLimiter 返回将每日限额换算为now所在子批次累计限额的函数，不拆分时原样返回每日限额
+func subBatchLimiter(batches int, now time.Time) func(dailyLimit int) int {
+	index := subBatchIndex(now, batches)
+	return func(dailyLimit int) int {
+		_, cumulative := subBatchQuota(dailyLimit, batches, index)
+		return cumulative
+	}
+}
+
+// subBatchInterval 返回每个子批次时段的长度
+func subBatchInterval(batches int) time.Duration {
+	if batches < 2 {
+		return 24 * time.Hour
+	}
+	return 24 * time.Hour / time.Duration(batches)
+}
+
+// subBatchIndex 返回now所在的子批次序号
+func subBatchIndex(now time.Time, batches int) int {
+	if batches < 2 {
+		return 0
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	index := int(now.Sub(midnight) / subBatchInterval(batches))
+	if index >= batches {
+		index = batches - 1
+	}
+	return index
+}
+
+// NextSubBatchTime 返回now之后下一个子批次时段的开始时间，供定时任务调度
+func NextSubBatchTime(now time.Time, batches int) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	interval := subBatchInterval(batches)
+	next := midnight.Add(time.Duration(now.Sub(midnight)/interval+1) * interval)
+	tomorrow := midnight.AddDate(0, 0, 1)
+	if !next.Before(tomorrow) {
+		return tomorrow
+	}
+	return next
+}
@@ -0,0 +1,75 @@
+package push
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestDailyQuotaSubBatches 测试每日限额拆分为子批次后的份额、偏移量和每批选取的URL数量
+func TestDailyQuotaSubBatches(t *testing.T) {
+	shares := splitDailyQuota(1000, 24)
+	if len(shares) != 24 {
+		t.Fatalf("expected 24 sub-batches, got %d", len(shares))
+	}
+	sum := 0
+	for i, share := range shares {
+		// 1000 = 24*41 + 16，前16个子批次各多分一个
+		expected := 41
+		if i < 16 {
+			expected = 42
+		}
+		if share != expected {
+			t.Errorf("sub-batch %d: expected share %d, got %d", i, expected, share)
+		}
+		sum += share
+	}
+	if sum != 1000 {
+		t.Errorf("shares should add up to the daily limit, got %d", sum)
+	}
+	if shares := splitDailyQuota(1000, 0); len(shares) != 1 || shares[0] != 1000 {
+		t.Errorf("daily limit should not be split without sub-batches, got %v", shares)
+	}
+
+	// 模拟一天的24个子批次：每批按累计限额选取，今日已推送的URL计入限额
+	var allURLs []string
+	for i := 0; i < 5000; i++ {
+		allURLs = append(allURLs, fmt.Sprintf("/page/%d", i))
+	}
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)
+	lastPushed := map[string]string{}
+	offset := 0
+	for i := 0; i < 24; i++ {
+		now := day.Add(time.Duration(i)*time.Hour + 5*time.Minute)
+		if index := subBatchIndex(now, 24); index != i {
+			t.Fatalf("expected sub-batch %d at %s, got %d", i, now.Format("15:04"), index)
+		}
+		if expected := 42*min(i, 16) + 41*max(i-16, 0); offset != expected {
+			t.Errorf("sub-batch %d: expected offset %d, got %d", i, expected, offset)
+		}
+
+		routes := selectDuePushURLs(allURLs, offset, subBatchLimiter(24, now)(1000), lastPushed, now, 0, nil)
+		if len(routes) != shares[i] {
+			t.Errorf("sub-batch %d: expected %d URLs, got %d", i, shares[i], len(routes))
+		}
+		if len(routes) > 0 && routes[0] != allURLs[offset] {
+			t.Errorf("sub-batch %d should start at offset %d, got %s", i, offset, routes[0])
+		}
+		for _, route := range routes {
+			lastPushed[route] = now.Format("2006-01-02")
+		}
+		advance, _ := subBatchQuota(1000, 24, i)
+		offset = nextPushOffset(offset, advance, len(allURLs))
+	}
+	if len(lastPushed) != 1000 || offset != 1000 {
+		t.Errorf("a full day should push the daily limit once, pushed %d, offset %d", len(lastPushed), offset)
+	}
+
+	// 下一个子批次的时间，最后一个时段之后为次日0点
+	if next := NextSubBatchTime(day.Add(90*time.Minute), 24); !next.Equal(day.Add(2 * time.Hour)) {
+		t.Errorf("unexpected next sub-batch time %s", next)
+	}
+	if next := NextSubBatchTime(day.Add(23*time.Hour+30*time.Minute), 24); !next.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("expected next day midnight, got %s", next)
+	}
+}
@@ -0,0 +1,86 @@
+package push
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultPushStatsCacheTTL 未配置时推送统计和趋势的缓存时间
+const defaultPushStatsCacheTTL = 10 * time.Second
+
+// statsReadAttempts 读取推送统计遇到临时错误时的最大尝试次数
+const statsReadAttempts = 3
+
+// statsRetryDelay 读取推送统计第一次重试前的等待时间，之后每次翻倍
+var statsRetryDelay = 50 * time.Millisecond
+
+// GetPushStats 获取推送统计，缓存时间内的重复请求直接读取缓存
+func (pm *PushManager) GetPushStats(siteID string) (map[string]interface{}, error) {
+	var stats map[string]interface{}
+	err := pm.cachedStats(siteID, "stats", &stats, func() (err error) {
+		stats, err = pm.redisClient.GetPushStatsWithURLCounts(siteID)
+		return err
+	})
+	return stats, err
+}
+
+// GetPushTrend 获取最近15天的推送趋势，缓存时间内的重复请求直接读取缓存
+func (pm *PushManager) GetPushTrend(siteID string) (map[string]int64, error) {
+	var trend map[string]int64
+	err := pm.cachedStats(siteID, "trend", &trend, func() (err error) {
+		trend, err = pm.redisClient.GetLast15DaysPushCount(siteID)
+		return err
+	})
+	return trend, err
+}
+
+// cachedStats 优先从缓存读取推送统计数据到dest，未命中时通过load重新统计并写入缓存
+// 读取缓存失败不影响重新统计，统计遇到临时错误时重试
+func (pm *PushManager) cachedStats(siteID, kind string, dest interface{}, load func() error) error {
+	if hit, err := pm.redisClient.GetPushStatsCache(siteID, kind, dest); err == nil && hit {
+		return nil
+	}
+
+	if err := withStatsRetry(load); err != nil {
+		return err
+	}
+	if err := pm.redisClient.SetPushStatsCache(siteID, kind, dest, pm.statsCacheTTL()); err != nil {
+		logger.Warn("Failed to cache push %s for site %s: %v", kind, siteID, err)
+	}
+	return nil
+}
+
+// statsCacheTTL 返回推送统计的缓存时间
+func (pm *PushManager) statsCacheTTL() time.Duration {
+	if pm.config != nil && pm.config.Server.PushStatsCacheTTL > 0 {
+		return time.Duration(pm.config.Server.PushStatsCacheTTL) * time.Second
+	}
+	return defaultPushStatsCacheTTL
+}
+
+// withStatsRetry 执行Redis读取，连接中断、超时或Redis暂时不可用时按指数退避重试
+func withStatsRetry(fn func() error) error {
+	delay := statsRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= statsReadAttempts || !isTransientRedisError(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isTransientRedisError 判断Redis错误是否为可重试的临时错误
+func isTransientRedisError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Redis加载数据或执行脚本期间返回的错误
+	message := err.Error()
+	return strings.HasPrefix(message, "LOADING") || strings.HasPrefix(message, "BUSY") || strings.HasPrefix(message, "TRYAGAIN")
+}
@@ -0,0 +1,131 @@
+package push
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/redis"
+)
+
+// startStatsRedis 启动miniredis，第一次HGETALL时断开连接以模拟临时故障；返回服务器地址和各命令的执行次数
+func startStatsRedis(t *testing.T) (string, func(command string) int) {
+	mr := miniredis.RunT(t)
+	var mu sync.Mutex
+	counts := make(map[string]int)
+	mr.Server().SetPreHook(func(peer *server.Peer, command string, args ...string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[command]++
+		if command == "HGETALL" && counts[command] == 1 {
+			peer.Close()
+			return true
+		}
+		return false
+	})
+	return mr.Addr(), func(command string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[command]
+	}
+}
+
+// TestPushStatsCache 测试缓存时间内重复读取推送统计和趋势命中缓存，统计遇到连接中断时重试
+func TestPushStatsCache(t *testing.T) {
+	addr, count := startStatsRedis(t)
+	redisClient, err := redis.NewClient(addr)
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	defer redisClient.Close()
+	pm := NewPushManager(&config.Config{}, redisClient)
+
+	for i := 0; i < 3; i++ {
+		stats, err := pm.GetPushStats("site1")
+		if err != nil {
+			t.Fatalf("GetPushStats failed: %v", err)
+		}
+		if fmt.Sprint(stats["total"]) != "0" || fmt.Sprint(stats["total_urls"]) != "0" {
+			t.Errorf("unexpected stats: %v", stats)
+		}
+	}
+	// 第一次HGETALL因连接中断重试，之后的读取命中缓存
+	if got := count("HGETALL"); got != 4 {
+		t.Errorf("expected 4 HGETALL commands (1 failed, 3 for stats, today's engine stats and URL statuses), got %d", got)
+	}
+	if got := count("SMEMBERS"); got != 1 {
+		t.Errorf("expected URL set to be read once, got %d", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		trend, err := pm.GetPushTrend("site1")
+		if err != nil {
+			t.Fatalf("GetPushTrend failed: %v", err)
+		}
+		if len(trend) != 15 {
+			t.Errorf("expected 15 days of trend, got %d", len(trend))
+		}
+	}
+	// 15个每日计数键只读取一次，另外每次读取缓存键一次
+	if got := count("GET"); got != 6+15 {
+		t.Errorf("expected daily counts to be read once, got %d GET commands", got)
+	}
+	if got := count("SET"); got != 2 {
+		t.Errorf("expected stats and trend to be cached once each, got %d SET commands", got)
+	}
+}
+
+// TestPushStatsEngineBreakdown 测试推送统计按搜索引擎区分总计和当日的成功、失败数
+func TestPushStatsEngineBreakdown(t *testing.T) {
+	redisClient, err := redis.NewClient(miniredis.RunT(t).Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	for _, seed := range []struct {
+		engine          string
+		success, failed int
+	}{
+		{config.PushEngineBaidu, 8, 2},
+		{config.PushEngineBing, 5, 0},
+		{config.PushEngineBaidu, 1, 1},
+		{config.PushEngineGoogle, 0, 3},
+	} {
+		if err := redisClient.IncrPushStats("site1", seed.engine, seed.success, seed.failed); err != nil {
+			t.Fatalf("failed to seed push stats: %v", err)
+		}
+	}
+
+	pm := NewPushManager(&config.Config{}, redisClient)
+	stats, err := pm.GetPushStats("site1")
+	if err != nil {
+		t.Fatalf("failed to get push stats: %v", err)
+	}
+	if stats["total"] != int64(20) || stats["success"] != int64(14) || stats["failed"] != int64(6) {
+		t.Errorf("unexpected totals: %v", stats)
+	}
+
+	want := map[string]map[string]int64{
+		config.PushEngineBaidu:  {"success": 9, "failed": 3},
+		config.PushEngineBing:   {"success": 5, "failed": 0},
+		config.PushEngineGoogle: {"success": 0, "failed": 3},
+	}
+	for _, scope := range []string{"overall", "today"} {
+		breakdown := stats
+		if scope == "today" {
+			breakdown, _ = stats["today"].(map[string]interface{})
+		}
+		engines, ok := breakdown["engines"].(map[string]map[string]int64)
+		if !ok {
+			t.Fatalf("%s: unexpected engines value %#v", scope, breakdown["engines"])
+		}
+		if fmt.Sprint(engines) != fmt.Sprint(want) {
+			t.Errorf("%s: expected %v, got %v", scope, want, engines)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package prerender
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// metaTagPattern 匹配HTML中的meta标签
+	metaTagPattern = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	// metaAttrPattern 匹配meta标签中的name和content属性
+	metaAttrPattern = regexp.MustCompile(`(?is)\b(name|content)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// hasNoIndexMeta 检查渲染后的HTML是否包含 <meta name="robots" content="noindex">
+// 同时识别针对googlebot的同类声明，以及content中的"none"指令
+func hasNoIndexMeta(html string) bool {
+	for _, tag := range metaTagPattern.FindAllString(html, -1) {
+		var name, content string
+		for _, match := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			value := match[2] + match[3] + match[4]
+			switch strings.ToLower(match[1]) {
+			case "name":
+				name = strings.ToLower(strings.TrimSpace(value))
+			case "content":
+				content = strings.ToLower(value)
+			}
+		}
+
+		if name != "robots" && name != "googlebot" {
+			continue
+		}
+
+		for _, directive := range strings.Split(content, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "noindex" || directive == "none" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// robotsRule robots.txt中的一条Allow或Disallow规则
+type robotsRule struct {
+	allow   bool
+	length  int            // 规则路径的长度，匹配多条规则时最长的规则生效
+	pattern *regexp.Regexp // 支持"*"通配符和"$"结尾锚点
+}
+
+// robotsGroup robots.txt中以一组User-agent开头的规则组
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// RobotsRules 某个User-Agent在robots.txt中适用的规则
+type RobotsRules struct {
+	rules []robotsRule
+}
+
+// ParseRobotsTxt 解析robots.txt并返回userAgent适用的规则：
+// 使用User-agent与userAgent匹配且最长的规则组，没有匹配的规则组时使用"*"规则组
+func ParseRobotsTxt(content, userAgent string) *RobotsRules {
+	var groups []*robotsGroup
+	var current *robotsGroup
+	lastWasAgent := false
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// 连续的User-agent行属于同一个规则组
+			if current == nil || !lastWasAgent {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			// 空的Disallow表示允许访问全部路径
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: compileRobotsPattern(value),
+			})
+		}
+	}
+
+	ua := strings.ToLower(userAgent)
+	best := ""
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if agent != "*" && ua != "" && strings.Contains(ua, agent) && len(agent) > len(best) {
+				best = agent
+			}
+		}
+	}
+	if best == "" {
+		best = "*"
+	}
+
+	rules := &RobotsRules{}
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			if agent == best {
+				rules.rules = append(rules.rules, group.rules...)
+				break
+			}
+		}
+	}
+	return rules
+}
+
+// compileRobotsPattern 将robots.txt规则路径转换为从路径开头匹配的正则表达式
+func compileRobotsPattern(value string) *regexp.Regexp {
+	anchored := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+	pattern := "^" + strings.ReplaceAll(regexp.QuoteMeta(value), `\*`, ".*")
+	if anchored {
+		pattern += "$"
+	}
+	return regexp.MustCompile(pattern)
+}
+
+// Allowed 判断路径（包含查询参数）是否允许访问，最长的匹配规则生效，长度相同时Allow优先
+func (r *RobotsRules) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	allowed, matched := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > matched || (rule.length == matched && rule.allow) {
+			allowed, matched = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,72 @@
+package prerender
+
+import (
+	"testing"
+)
+
+// TestHasNoIndexMeta 测试识别robots noindex元标签
+func TestHasNoIndexMeta(t *testing.T) {
+	cases := []struct {
+		html     string
+		expected bool
+	}{
+		{`<html><head><meta name="robots" content="noindex"></head></html>`, true},
+		{`<html><head><META NAME="Robots" CONTENT="NOINDEX, NOFOLLOW"></head></html>`, true},
+		{`<html><head><meta content='noindex' name='googlebot'/></head></html>`, true},
+		{`<html><head><meta name="robots" content="none"></head></html>`, true},
+		{`<html><head><meta name="robots" content="index, follow"></head></html>`, false},
+		{`<html><head><meta name="description" content="noindex"></head></html>`, false},
+		{`<html><head><title>noindex</title></head></html>`, false},
+	}
+
+	for _, c := range cases {
+		if got := hasNoIndexMeta(c.html); got != c.expected {
+			t.Errorf("hasNoIndexMeta(%q) = %v, want %v", c.html, got, c.expected)
+		}
+	}
+}
+
+// TestParseRobotsTxt 测试按User-Agent选择robots.txt规则组，以及最长匹配规则和通配符
+func TestParseRobotsTxt(t *testing.T) {
+	content := `# 示例
+User-agent: *
+Disallow: /search
+Disallow: /*?filter=
+Allow: /search/help
+Disallow: /*.pdf$
+
+User-agent: PrerenderShield
+User-agent: OtherBot
+Disallow: /private
+Disallow:
+`
+	defaultRules := ParseRobotsTxt(content, "")
+	cases := []struct {
+		path     string
+		expected bool
+	}{
+		{"/", true},
+		{"/search", false},
+		{"/search?q=1", false},
+		{"/search/help", true},
+		{"/products?filter=red", false},
+		{"/files/a.pdf", false},
+		{"/files/a.pdf.html", true},
+		{"/private", true},
+	}
+	for _, c := range cases {
+		if got := defaultRules.Allowed(c.path); got != c.expected {
+			t.Errorf("* rules: Allowed(%q) = %v, want %v", c.path, got, c.expected)
+		}
+	}
+
+	// 匹配到专属规则组后不再使用"*"规则组
+	botRules := ParseRobotsTxt(content, "Mozilla/5.0 (compatible; PrerenderShield/1.0)")
+	if botRules.Allowed("/private/page") || !botRules.Allowed("/search") {
+		t.Error("expected the PrerenderShield group to be used")
+	}
+
+	if !ParseRobotsTxt("", "PrerenderShield").Allowed("/anything") {
+		t.Error("empty robots.txt should allow everything")
+	}
+}
@@ -0,0 +1,221 @@
+package prerender
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSitemapSize 单个sitemap文件的最大解压后大小（sitemap协议限制为50MB）
+	maxSitemapSize = 50 * 1024 * 1024
+	// maxSitemapURLs 单次解析的最大URL数量（sitemap协议限制单文件50000条）
+	maxSitemapURLs = 50000
+	// maxSitemapIndexDepth sitemap索引文件的最大嵌套深度
+	maxSitemapIndexDepth = 3
+	// defaultSitemapPriority sitemap协议规定的默认优先级
+	defaultSitemapPriority = 0.5
+)
+
+// SitemapEntry sitemap中的URL条目
+type SitemapEntry struct {
+	Loc      string
+	LastMod  time.Time // 未提供时为零值
+	Priority float64
+}
+
+// sitemapDocument sitemap文件结构，同时兼容urlset和sitemapindex
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapURL `xml:"url"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// sitemapURL sitemap中的url或sitemap节点
+type sitemapURL struct {
+	Loc      string `xml:"loc"`
+	LastMod  string `xml:"lastmod"`
+	Priority string `xml:"priority"`
+}
+
+// SitemapFetcher sitemap获取与解析器
+type SitemapFetcher struct {
+	client *http.Client
+}
+
+// NewSitemapFetcher 创建sitemap获取器，client为空时使用默认HTTP客户端
+func NewSitemapFetcher(client *http.Client) *SitemapFetcher {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &SitemapFetcher{client: client}
+}
+
+// Fetch 获取并解析sitemap，支持sitemap索引文件和gzip压缩的sitemap
+// 返回的条目已去重，并按优先级从高到低排序
+func (f *SitemapFetcher) Fetch(ctx context.Context, sitemapURL string) ([]SitemapEntry, error) {
+	entries := make(map[string]SitemapEntry)
+	visited := make(map[string]bool)
+
+	if err := f.fetch(ctx, sitemapURL, 0, visited, entries); err != nil {
+		return nil, err
+	}
+
+	result := make([]SitemapEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Priority != result[j].Priority {
+			return result[i].Priority > result[j].Priority
+		}
+		return result[i].Loc < result[j].Loc
+	})
+
+	return result, nil
+}
+
+// fetch 递归获取sitemap，索引文件中的子sitemap会继续展开
+func (f *SitemapFetcher) fetch(ctx context.Context, sitemapURL string, depth int, visited map[string]bool, entries map[string]SitemapEntry) error {
+	if visited[sitemapURL] {
+		return nil
+	}
+	visited[sitemapURL] = true
+
+	doc, err := f.download(ctx, sitemapURL)
+	if err != nil {
+		return err
+	}
+
+	switch doc.XMLName.Local {
+	case "sitemapindex":
+		if depth >= maxSitemapIndexDepth {
+			return fmt.Errorf("sitemap index nested too deep: %s", sitemapURL)
+		}
+		for _, child := range doc.Sitemaps {
+			loc := strings.TrimSpace(child.Loc)
+			if loc == "" {
+				continue
+			}
+			if err := f.fetch(ctx, loc, depth+1, visited, entries); err != nil {
+				return err
+			}
+		}
+	case "urlset":
+		for _, u := range doc.URLs {
+			if len(entries) >= maxSitemapURLs {
+				break
+			}
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" {
+				continue
+			}
+			entries[loc] = SitemapEntry{
+				Loc:      loc,
+				LastMod:  parseSitemapTime(u.LastMod),
+				Priority: parseSitemapPriority(u.Priority),
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported sitemap root element %q in %s", doc.XMLName.Local, sitemapURL)
+	}
+
+	return nil
+}
+
+// download 下载并解析单个sitemap文件
+func (f *SitemapFetcher) download(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sitemap request: %v", err)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: %v", sitemapURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: status code %d", sitemapURL, resp.StatusCode)
+	}
+
+	// 通过gzip魔数识别压缩的sitemap（如sitemap.xml.gz），不依赖文件后缀
+	var reader io.Reader = bufio.NewReader(resp.Body)
+	if magic, err := reader.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress sitemap %s: %v", sitemapURL, err)
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(io.LimitReader(reader, maxSitemapSize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap %s: %v", sitemapURL, err)
+	}
+
+	return &doc, nil
+}
+
+// parseSitemapTime 解析lastmod，支持W3C Datetime的常见格式
+func parseSitemapTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}
+	}
+
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseSitemapPriority 解析priority，无效值使用默认优先级0.5
+func parseSitemapPriority(value string) float64 {
+	priority, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || priority < 0 || priority > 1 {
+		return defaultSitemapPriority
+	}
+	return priority
+}
+
+// isUnchangedSinceCached 判断URL自上次缓存以来是否未变化
+// 仅当URL已缓存、缓存未过期且lastmod不晚于缓存时间时返回true
+func isUnchangedSinceCached(status map[string]string, lastMod time.Time, cacheTTL int, now time.Time) bool {
+	if lastMod.IsZero() || status["status"] != "cached" {
+		return false
+	}
+
+	updatedAt, err := strconv.ParseInt(status["updated_at"], 10, 64)
+	if err != nil {
+		return false
+	}
+	cachedAt := time.Unix(updatedAt, 0)
+
+	// 缓存已过期的URL仍需要重新预热
+	if cacheTTL > 0 && now.Sub(cachedAt) >= time.Duration(cacheTTL)*time.Second {
+		return false
+	}
+
+	return !lastMod.After(cachedAt)
+}
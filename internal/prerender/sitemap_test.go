@@ -0,0 +1,125 @@
+package prerender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestSitemapFetcherIndexAndGzip 测试解析sitemap索引文件和gzip压缩的sitemap
+func TestSitemapFetcherIndexAndGzip(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/sitemap_index.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>%[1]s/pages.xml</loc></sitemap>
+  <sitemap><loc>%[1]s/posts.xml.gz</loc></sitemap>
+</sitemapindex>`, server.URL)
+	})
+	mux.HandleFunc("/pages.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc><priority>1.0</priority><lastmod>2024-05-01</lastmod></url>
+  <url><loc>https://example.com/about</loc></url>
+</urlset>`)
+	})
+	mux.HandleFunc("/posts.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		fmt.Fprint(gz, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/posts/1</loc><priority>0.8</priority><lastmod>2024-05-02T10:00:00+08:00</lastmod></url>
+  <url><loc>https://example.com/about</loc><priority>0.3</priority></url>
+</urlset>`)
+		gz.Close()
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write(buf.Bytes())
+	})
+
+	entries, err := NewSitemapFetcher(server.Client()).Fetch(context.Background(), server.URL+"/sitemap_index.xml")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 unique entries, got %d: %+v", len(entries), entries)
+	}
+
+	// 按优先级从高到低排序
+	expectedOrder := []string{"https://example.com/", "https://example.com/posts/1", "https://example.com/about"}
+	for i, loc := range expectedOrder {
+		if entries[i].Loc != loc {
+			t.Errorf("entry %d: got %s, want %s", i, entries[i].Loc, loc)
+		}
+	}
+
+	if !entries[0].LastMod.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected lastmod: %v", entries[0].LastMod)
+	}
+	if entries[1].LastMod.IsZero() {
+		t.Errorf("expected lastmod for gzipped entry")
+	}
+}
+
+// TestSitemapFetcherRejectsInvalidDocument 测试无法识别的sitemap返回错误
+func TestSitemapFetcherRejectsInvalidDocument(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body>not a sitemap</body></html>`)
+	}))
+	defer server.Close()
+
+	if _, err := NewSitemapFetcher(server.Client()).Fetch(context.Background(), server.URL); err == nil {
+		t.Error("expected error for non-sitemap document")
+	}
+}
+
+// TestParseSitemapPriority 测试priority解析及默认值
+func TestParseSitemapPriority(t *testing.T) {
+	cases := map[string]float64{
+		"0.8":  0.8,
+		" 1 ":  1.0,
+		"":     defaultSitemapPriority,
+		"high": defaultSitemapPriority,
+		"2.0":  defaultSitemapPriority,
+	}
+	for value, expected := range cases {
+		if got := parseSitemapPriority(value); got != expected {
+			t.Errorf("parseSitemapPriority(%q) = %v, want %v", value, got, expected)
+		}
+	}
+}
+
+// TestIsUnchangedSinceCached 测试根据lastmod和缓存状态判断URL是否可跳过
+func TestIsUnchangedSinceCached(t *testing.T) {
+	now := time.Now()
+	cachedAt := now.Add(-time.Hour)
+	status := map[string]string{
+		"status":     "cached",
+		"updated_at": strconv.FormatInt(cachedAt.Unix(), 10),
+	}
+
+	if !isUnchangedSinceCached(status, cachedAt.Add(-time.Hour), 86400, now) {
+		t.Error("URL modified before caching should be unchanged")
+	}
+	if isUnchangedSinceCached(status, now, 86400, now) {
+		t.Error("URL modified after caching should be re-preheated")
+	}
+	if isUnchangedSinceCached(status, time.Time{}, 86400, now) {
+		t.Error("URL without lastmod should be re-preheated")
+	}
+	if isUnchangedSinceCached(status, cachedAt.Add(-time.Hour), 1800, now) {
+		t.Error("URL with expired cache should be re-preheated")
+	}
+	if isUnchangedSinceCached(map[string]string{"status": "failed", "updated_at": status["updated_at"]}, cachedAt.Add(-time.Hour), 86400, now) {
+		t.Error("URL that failed last time should be re-preheated")
+	}
+}
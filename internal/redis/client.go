@@ -3,6 +3,7 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -328,6 +329,22 @@ func (c *Client) GetCurrentPreheatTask(siteID string) (string, error) {
 	return c.client.Get(c.ctx, key).Result()
 }
 
+// SetPreheatJob 保存站点最近一次预热任务的进度
+func (c *Client) SetPreheatJob(siteID string, job interface{}) error {
+	key := fmt.Sprintf("prerender:%s:preheat:job", siteID)
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(c.ctx, key, data, 0).Err()
+}
+
+// GetPreheatJob 获取站点最近一次预热任务的进度（JSON）
+func (c *Client) GetPreheatJob(siteID string) (string, error) {
+	key := fmt.Sprintf("prerender:%s:preheat:job", siteID)
+	return c.client.Get(c.ctx, key).Result()
+}
+
 // GetUser 获取用户信息
 func (c *Client) GetUser(userID string) (map[string]string, error) {
 	key := "user:" + userID
@@ -370,6 +387,46 @@ func (c *Client) SaveUser(userID, username, password string) error {
 	return c.client.Set(c.ctx, "username:"+username, userID, 0).Err()
 }
 
+var (
+	// ErrUserNotExist 要删除的用户不存在
+	ErrUserNotExist = errors.New("user does not exist")
+	// ErrLastUserRemaining 要删除的用户是最后一个用户
+	ErrLastUserRemaining = errors.New("user is the last remaining user")
+)
+
+// deleteUserScript 在同一个脚本中检查用户数量并删除用户及用户名映射，
+// 并发删除不会删除掉最后一个用户；返回-1表示用户不存在，0表示是最后一个用户，1表示已删除
+var deleteUserScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return -1
+end
+if #redis.call('KEYS', 'user:*') <= 1 then
+	return 0
+end
+local username = redis.call('HGET', KEYS[1], 'username')
+redis.call('DEL', KEYS[1])
+if username then
+	redis.call('DEL', 'username:' .. username)
+end
+return 1
+`)
+
+// DeleteUserIfNotLast 删除用户信息及用户名映射，检查和删除是原子的；
+// 用户不存在时返回ErrUserNotExist，是最后一个用户时返回ErrLastUserRemaining
+func (c *Client) DeleteUserIfNotLast(userID string) error {
+	result, err := deleteUserScript.Run(c.ctx, c.client, []string{"user:" + userID}).Int()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case -1:
+		return ErrUserNotExist
+	case 0:
+		return ErrLastUserRemaining
+	}
+	return nil
+}
+
 // SetPushTask 保存推送任务
 func (c *Client) SetPushTask(siteID string, task interface{}) error {
 	key := fmt.Sprintf("prerender:%s:push:task", siteID)
@@ -386,17 +443,60 @@ func (c *Client) GetPushTask(siteID string, taskID string) (map[string]string, e
 	return c.client.HGetAll(c.ctx, key).Result()
 }
 
-// IncrPushStats 增加推送统计
-func (c *Client) IncrPushStats(siteID string, success, failed int) error {
+// pushEngineDailyStatsTTL 按天统计的搜索引擎推送结果的保留时间
+const pushEngineDailyStatsTTL = 31 * 24 * time.Hour
+
+// pushEngineStatsPrefix 推送统计哈希中按搜索引擎区分的字段前缀，字段格式为 engine:{搜索引擎}:{success|failed}
+const pushEngineStatsPrefix = "engine:"
+
+// pushEngineDailyStatsKey 返回站点指定日期按搜索引擎区分的推送统计键
+func pushEngineDailyStatsKey(siteID, date string) string {
+	return fmt.Sprintf("prerender:%s:push:daily:%s:engines", siteID, date)
+}
+
+// IncrPushStats 增加推送统计，同时累加该搜索引擎的总计和当日统计
+func (c *Client) IncrPushStats(siteID, engine string, success, failed int) error {
 	key := fmt.Sprintf("prerender:%s:push:stats", siteID)
+	dailyKey := pushEngineDailyStatsKey(siteID, time.Now().Format("2006-01-02"))
 	pipe := c.client.Pipeline()
-	pipe.HIncrBy(c.ctx, key, "total", int64(success+failed))
-	pipe.HIncrBy(c.ctx, key, "success", int64(success))
-	pipe.HIncrBy(c.ctx, key, "failed", int64(failed))
+	for _, k := range []string{key, dailyKey} {
+		pipe.HIncrBy(c.ctx, k, "total", int64(success+failed))
+		pipe.HIncrBy(c.ctx, k, "success", int64(success))
+		pipe.HIncrBy(c.ctx, k, "failed", int64(failed))
+		pipe.HIncrBy(c.ctx, k, pushEngineStatsPrefix+engine+":success", int64(success))
+		pipe.HIncrBy(c.ctx, k, pushEngineStatsPrefix+engine+":failed", int64(failed))
+	}
+	pipe.Expire(c.ctx, dailyKey, pushEngineDailyStatsTTL)
 	_, err := pipe.Exec(c.ctx)
 	return err
 }
 
+// parsePushEngineStats 将推送统计哈希转换为总计和按搜索引擎区分的成功、失败数
+func parsePushEngineStats(stats map[string]string) map[string]interface{} {
+	result := map[string]interface{}{"total": int64(0), "success": int64(0), "failed": int64(0)}
+	engines := make(map[string]map[string]int64)
+	for k, v := range stats {
+		val, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		if !strings.HasPrefix(k, pushEngineStatsPrefix) {
+			result[k] = val
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(k, pushEngineStatsPrefix), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if engines[parts[0]] == nil {
+			engines[parts[0]] = map[string]int64{"success": 0, "failed": 0}
+		}
+		engines[parts[0]][parts[1]] = val
+	}
+	result["engines"] = engines
+	return result
+}
+
 // SetURLPushStatus 设置URL的推送状态
 func (c *Client) SetURLPushStatus(siteID, url, status string) error {
 	key := fmt.Sprintf("prerender:%s:push:status", siteID)
@@ -415,6 +515,65 @@ func (c *Client) GetAllURLPushStatuses(siteID string) (map[string]string, error)
 	return c.client.HGetAll(c.ctx, key).Result()
 }
 
+// GetURLPushDates 获取站点各URL最近一次推送到指定搜索引擎的日期
+func (c *Client) GetURLPushDates(siteID, engine string) (map[string]string, error) {
+	key := fmt.Sprintf("prerender:%s:push:dates:%s", siteID, engine)
+	return c.client.HGetAll(c.ctx, key).Result()
+}
+
+// SetURLPushDates 记录URL推送到指定搜索引擎的日期
+func (c *Client) SetURLPushDates(siteID, engine string, urls []string, date string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	key := fmt.Sprintf("prerender:%s:push:dates:%s", siteID, engine)
+	values := make([]interface{}, 0, len(urls)*2)
+	for _, url := range urls {
+		values = append(values, url, date)
+	}
+	return c.client.HSet(c.ctx, key, values...).Err()
+}
+
+// SetURLContentHash 记录URL最近一次渲染内容的哈希，用于判断内容是否变化
+func (c *Client) SetURLContentHash(siteID, url, hash string) error {
+	key := fmt.Sprintf("prerender:%s:content:hashes", siteID)
+	return c.client.HSet(c.ctx, key, url, hash).Err()
+}
+
+// GetURLContentHashes 获取站点各URL最近一次渲染内容的哈希
+func (c *Client) GetURLContentHashes(siteID string) (map[string]string, error) {
+	key := fmt.Sprintf("prerender:%s:content:hashes", siteID)
+	return c.client.HGetAll(c.ctx, key).Result()
+}
+
+// SetURLPushMarks 将URL标记为已推送，值为标记时的内容哈希，内容变化前推送时跳过这些URL
+func (c *Client) SetURLPushMarks(siteID string, marks map[string]string) error {
+	if len(marks) == 0 {
+		return nil
+	}
+	key := fmt.Sprintf("prerender:%s:push:marked", siteID)
+	values := make([]interface{}, 0, len(marks)*2)
+	for url, hash := range marks {
+		values = append(values, url, hash)
+	}
+	return c.client.HSet(c.ctx, key, values...).Err()
+}
+
+// GetURLPushMarks 获取被标记为已推送的URL及标记时的内容哈希
+func (c *Client) GetURLPushMarks(siteID string) (map[string]string, error) {
+	key := fmt.Sprintf("prerender:%s:push:marked", siteID)
+	return c.client.HGetAll(c.ctx, key).Result()
+}
+
+// ClearURLPushMarks 移除URL的已推送标记
+func (c *Client) ClearURLPushMarks(siteID string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	key := fmt.Sprintf("prerender:%s:push:marked", siteID)
+	return c.client.HDel(c.ctx, key, urls...).Err()
+}
+
 // GetURLPushStats 获取站点的URL推送统计
 func (c *Client) GetURLPushStats(siteID string) (map[string]int64, error) {
 	// 获取所有URL
@@ -448,29 +607,8 @@ func (c *Client) GetPushStats(siteID string) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to get push stats for site %s: %v", siteID, err)
 	}
 
-	// 转换为数字类型
-	result := make(map[string]interface{})
-	for k, v := range stats {
-		val, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			// 记录转换错误，但不中断处理
-			logging.DefaultLogger.Warn("Failed to parse %s value %s to int: %v", k, v, err)
-			result[k] = v
-		} else {
-			result[k] = val
-		}
-	}
-
-	// 添加默认值
-	if _, exists := result["total"]; !exists {
-		result["total"] = 0
-	}
-	if _, exists := result["success"]; !exists {
-		result["success"] = 0
-	}
-	if _, exists := result["failed"]; !exists {
-		result["failed"] = 0
-	}
+	// 转换为数字类型，按搜索引擎区分的统计放在engines中
+	result := parsePushEngineStats(stats)
 
 	// 获取URL推送统计，即使失败也不影响主功能
 	urlStats, err := c.GetURLPushStats(siteID)
@@ -665,27 +803,15 @@ func (c *Client) GetPushStatsWithURLCounts(siteID string) (map[string]interface{
 		return nil, err
 	}
 
-	// 转换为数字类型
-	result := make(map[string]interface{})
-	for k, v := range stats {
-		val, err := strconv.ParseInt(v, 10, 64)
-		if err != nil {
-			result[k] = v
-		} else {
-			result[k] = val
-		}
-	}
+	// 转换为数字类型，按搜索引擎区分的统计放在engines中
+	result := parsePushEngineStats(stats)
 
-	// 添加默认值
-	if _, exists := result["total"]; !exists {
-		result["total"] = 0
-	}
-	if _, exists := result["success"]; !exists {
-		result["success"] = 0
-	}
-	if _, exists := result["failed"]; !exists {
-		result["failed"] = 0
+	// 当日按搜索引擎区分的统计
+	daily, err := c.client.HGetAll(c.ctx, pushEngineDailyStatsKey(siteID, time.Now().Format("2006-01-02"))).Result()
+	if err != nil {
+		return nil, err
 	}
+	result["today"] = parsePushEngineStats(daily)
 
 	// 获取所有URL
 	allURLs, err := c.GetURLs(siteID)
@@ -709,6 +835,35 @@ func (c *Client) GetPushStatsWithURLCounts(siteID string) (map[string]interface{
 	return result, nil
 }
 
+// GetPushStatsCache 读取缓存的推送统计数据，kind区分统计（stats）和趋势（trend）
+// 未缓存或缓存内容无法解析时返回false
+func (c *Client) GetPushStatsCache(siteID, kind string, dest interface{}) (bool, error) {
+	key := fmt.Sprintf("prerender:%s:push:cache:%s", siteID, kind)
+	data, err := c.client.Get(c.ctx, key).Bytes()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(dest); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// SetPushStatsCache 缓存推送统计数据，过期后重新从统计键读取
+func (c *Client) SetPushStatsCache(siteID, kind string, value interface{}, ttl time.Duration) error {
+	key := fmt.Sprintf("prerender:%s:push:cache:%s", siteID, kind)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(c.ctx, key, data, ttl).Err()
+}
+
 // GetPushLogs 获取推送日志
 func (c *Client) GetPushLogs(siteID string, limit, offset int) ([]interface{}, error) {
 	key := fmt.Sprintf("prerender:%s:push:logs", siteID)
@@ -791,6 +946,24 @@ func (c *Client) DeleteSession(sessionID string) error {
 	return c.client.Del(c.ctx, key).Err()
 }
 
+// DeleteUserSessions 删除指定用户的所有会话
+func (c *Client) DeleteUserSessions(userID string) error {
+	iter := c.client.Scan(c.ctx, 0, "session:*", 100).Iterator()
+	for iter.Next(c.ctx) {
+		key := iter.Val()
+		owner, err := c.client.HGet(c.ctx, key, "user_id").Result()
+		if err != nil {
+			continue
+		}
+		if owner == userID {
+			if err := c.client.Del(c.ctx, key).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return iter.Err()
+}
+
 // CheckSessionExists 检查会话是否存在
 func (c *Client) CheckSessionExists(sessionID string) (bool, error) {
 	key := fmt.Sprintf("session:%s", sessionID)
@@ -259,12 +259,86 @@ func (r *WafRepository) CreateAccessLog(log *models.AccessLog) error {
 		}
 	}
 
+	// Blocked and challenged requests are also indexed by time for the firewall log API
+	if IsBlockedAction(log.Action) {
+		r.addBlockedLog(log, data)
+	}
+
 	// Update stats
 	r.incrementStats(log)
 
 	return nil
 }
 
+// blockedLogLimit caps the number of blocked request logs kept per site
+const blockedLogLimit = 10000
+
+// IsBlockedAction reports whether a WAF action stopped the request
+func IsBlockedAction(action string) bool {
+	return action == "block" || action == "challenge"
+}
+
+// blockedLogKey returns the sorted set holding blocked request logs of a site, or of all sites when siteID is empty
+func blockedLogKey(siteID string) string {
+	if siteID == "" {
+		return "waf:blocked:all"
+	}
+	return fmt.Sprintf("waf:blocked:%s", siteID)
+}
+
+// addBlockedLog adds a blocked request log to the per-site and all-sites sorted sets scored by time,
+// keeping only the newest blockedLogLimit entries
+func (r *WafRepository) addBlockedLog(log *models.AccessLog, data []byte) {
+	ctx := r.client.Context()
+	score := float64(log.CreatedAt.UnixNano())
+
+	pipe := r.client.GetRawClient().Pipeline()
+	for _, key := range []string{blockedLogKey(log.SiteID), blockedLogKey("")} {
+		pipe.ZAdd(ctx, key, &redis.Z{Score: score, Member: data})
+		pipe.ZRemRangeByRank(ctx, key, 0, -blockedLogLimit-1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		fmt.Printf("Failed to save blocked request log: %v\n", err)
+	}
+}
+
+// GetBlockedLogs retrieves blocked request logs of a site (all sites when siteID is empty) within a time range,
+// newest first. A non-positive pageSize returns all matching logs
+func (r *WafRepository) GetBlockedLogs(siteID string, startTime, endTime time.Time, page, pageSize int) ([]models.AccessLog, int64, error) {
+	ctx := r.client.Context()
+	key := blockedLogKey(siteID)
+	min := strconv.FormatInt(startTime.UnixNano(), 10)
+	max := strconv.FormatInt(endTime.UnixNano(), 10)
+
+	total, err := r.client.GetRawClient().ZCount(ctx, key, min, max).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rangeBy := &redis.ZRangeBy{Min: min, Max: max}
+	if pageSize > 0 {
+		if page < 1 {
+			page = 1
+		}
+		rangeBy.Offset = int64((page - 1) * pageSize)
+		rangeBy.Count = int64(pageSize)
+	}
+	rawLogs, err := r.client.GetRawClient().ZRevRangeByScore(ctx, key, rangeBy).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	logs := make([]models.AccessLog, 0, len(rawLogs))
+	for _, raw := range rawLogs {
+		var log models.AccessLog
+		if err := json.Unmarshal([]byte(raw), &log); err == nil {
+			logs = append(logs, log)
+		}
+	}
+
+	return logs, total, nil
+}
+
 func (r *WafRepository) incrementStats(log *models.AccessLog) {
 	ctx := r.client.Context()
 	// Global Stats
@@ -0,0 +1,238 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/logging"
+)
+
+// defaultTimeout 未配置时单个文件的扫描超时时间
+const defaultTimeout = 60 * time.Second
+
+// Result 单个文件的扫描结果
+type Result struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature,omitempty"` // 命中的病毒特征名称
+}
+
+// Scanner 病毒扫描器
+type Scanner interface {
+	Scan(ctx context.Context, path string) (Result, error)
+}
+
+// CommandScanner 调用外部命令扫描文件，文件路径作为最后一个参数，
+// 退出码0表示无毒、1表示发现病毒，其他退出码视为扫描出错（与clamscan/clamdscan一致）
+type CommandScanner struct {
+	Command []string
+}
+
+// Scan 扫描文件
+func (s *CommandScanner) Scan(ctx context.Context, path string) (Result, error) {
+	if len(s.Command) == 0 {
+		return Result{}, errors.New("scan command is empty")
+	}
+	args := append(append([]string{}, s.Command[1:]...), path)
+	output, err := exec.CommandContext(ctx, s.Command[0], args...).CombinedOutput()
+	if err == nil {
+		return Result{}, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return Result{Infected: true, Signature: parseSignature(string(output))}, nil
+	}
+	return Result{}, fmt.Errorf("scan command failed: %v: %s", err, strings.TrimSpace(string(output)))
+}
+
+// parseSignature 从 "path: Eicar-Signature FOUND" 格式的输出中提取病毒特征名称
+func parseSignature(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasSuffix(line, " FOUND") {
+			continue
+		}
+		line = strings.TrimSuffix(line, " FOUND")
+		if i := strings.LastIndex(line, ": "); i >= 0 {
+			return line[i+2:]
+		}
+		return line
+	}
+	return ""
+}
+
+// HTTPScanner 将文件内容POST到HTTP扫描服务，响应JSON格式与Result相同
+type HTTPScanner struct {
+	URL    string
+	Client *http.Client
+}
+
+// Scan 扫描文件
+func (s *HTTPScanner) Scan(ctx context.Context, path string) (Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, file)
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-File-Name", filepath.Base(path))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("scan service returned status %d", resp.StatusCode)
+	}
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Result{}, fmt.Errorf("invalid scan service response: %v", err)
+	}
+	return result, nil
+}
+
+// Finding 被隔离的文件
+type Finding struct {
+	Path      string `json:"path"`                // 相对扫描目录的路径，使用/分隔
+	Signature string `json:"signature,omitempty"` // 命中的病毒特征名称
+	Error     string `json:"error,omitempty"`     // 扫描出错且策略为closed时的错误信息
+}
+
+// Guard 上传文件扫描守卫，在文件可以被访问之前扫描，发现病毒的文件移到隔离目录
+type Guard struct {
+	scanner       Scanner
+	timeout       time.Duration
+	failOpen      bool
+	quarantineDir string
+}
+
+// New 根据配置创建扫描守卫，未启用扫描时返回nil，nil守卫不扫描任何文件
+func New(cfg config.UploadScanConfig, quarantineDir string) *Guard {
+	if !cfg.Enabled {
+		return nil
+	}
+	var scanner Scanner
+	if len(cfg.Command) > 0 {
+		scanner = &CommandScanner{Command: cfg.Command}
+	} else {
+		scanner = &HTTPScanner{URL: cfg.URL}
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	return NewGuard(scanner, timeout, cfg.FailPolicy == config.UploadScanFailOpen, quarantineDir)
+}
+
+// NewGuard 使用指定扫描器创建扫描守卫，failOpen为true时扫描出错的文件放行，否则隔离
+func NewGuard(scanner Scanner, timeout time.Duration, failOpen bool, quarantineDir string) *Guard {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Guard{
+		scanner:       scanner,
+		timeout:       timeout,
+		failOpen:      failOpen,
+		quarantineDir: quarantineDir,
+	}
+}
+
+// ScanDir 扫描目录下的所有文件，发现病毒或按策略需要隔离的文件移到 quarantineDir/<站点ID>/<时间戳>/ 下，
+// 返回被隔离的文件；只有遍历目录或移动文件失败时返回错误
+func (g *Guard) ScanDir(ctx context.Context, siteID, root string) ([]Finding, error) {
+	if g == nil {
+		return nil, nil
+	}
+
+	var findings []Finding
+	quarantineRoot := filepath.Join(g.quarantineDir, siteID, fmt.Sprintf("%d", time.Now().UnixNano()))
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		finding, flagged := g.scanFile(ctx, siteID, path)
+		if !flagged {
+			return nil
+		}
+		finding.Path = filepath.ToSlash(rel)
+		target := filepath.Join(quarantineRoot, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		if err := moveFile(path, target); err != nil {
+			return fmt.Errorf("failed to quarantine %s: %v", finding.Path, err)
+		}
+		findings = append(findings, finding)
+		return nil
+	})
+	return findings, err
+}
+
+// scanFile 扫描单个文件，返回是否需要隔离
+func (g *Guard) scanFile(ctx context.Context, siteID, path string) (Finding, bool) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	result, err := g.scanner.Scan(ctx, path)
+	if err != nil {
+		if g.failOpen {
+			logging.DefaultLogger.Warn("Failed to scan uploaded file %s of site %s, allowing it: %v", path, siteID, err)
+			return Finding{}, false
+		}
+		logging.DefaultLogger.Warn("Failed to scan uploaded file %s of site %s, quarantining it: %v", path, siteID, err)
+		return Finding{Error: err.Error()}, true
+	}
+	if result.Infected {
+		logging.DefaultLogger.Warn("Malware %q found in uploaded file %s of site %s, quarantining it", result.Signature, path, siteID)
+		return Finding{Signature: result.Signature}, true
+	}
+	return Finding{}, false
+}
+
+// moveFile 移动文件，隔离目录与站点目录不在同一文件系统时复制后删除原文件
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockScanner 内容包含EICAR的文件视为病毒，内容包含BROKEN的文件扫描出错
+type mockScanner struct{}
+
+func (mockScanner) Scan(ctx context.Context, path string) (Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	switch {
+	case strings.Contains(string(data), "EICAR"):
+		return Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	case strings.Contains(string(data), "BROKEN"):
+		return Result{}, errors.New("scanner unavailable")
+	}
+	return Result{}, nil
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestGuard_QuarantinesFlaggedFiles(t *testing.T) {
+	root := t.TempDir()
+	quarantineDir := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"index.html":      "<html></html>",
+		"assets/evil.js":  "EICAR",
+		"assets/weird.js": "BROKEN",
+	})
+
+	guard := NewGuard(mockScanner{}, time.Second, false, quarantineDir)
+	findings, err := guard.ScanDir(context.Background(), "site1", root)
+	assert.NoError(t, err)
+	if assert.Len(t, findings, 2) {
+		assert.Equal(t, Finding{Path: "assets/evil.js", Signature: "Eicar-Test-Signature"}, findings[0])
+		assert.Equal(t, "assets/weird.js", findings[1].Path)
+		assert.Contains(t, findings[1].Error, "scanner unavailable")
+	}
+
+	assert.FileExists(t, filepath.Join(root, "index.html"))
+	assert.NoFileExists(t, filepath.Join(root, "assets", "evil.js"))
+	assert.NoFileExists(t, filepath.Join(root, "assets", "weird.js"))
+	quarantined, _ := filepath.Glob(filepath.Join(quarantineDir, "site1", "*", "assets", "evil.js"))
+	assert.Len(t, quarantined, 1)
+}
+
+func TestGuard_FailOpen(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.js": "BROKEN", "b.js": "EICAR"})
+
+	findings, err := NewGuard(mockScanner{}, time.Second, true, t.TempDir()).ScanDir(context.Background(), "site1", root)
+	assert.NoError(t, err)
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, "b.js", findings[0].Path, "infected files are quarantined even when failing open")
+	}
+	assert.FileExists(t, filepath.Join(root, "a.js"))
+
+	// 未启用扫描时返回nil守卫，不扫描任何文件
+	var guard *Guard
+	findings, err = guard.ScanDir(context.Background(), "site1", root)
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestCommandScanner(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("/bin/sh not available")
+	}
+	// 模拟clamdscan：文件内容包含EICAR时输出特征并以1退出
+	script := `if grep -q EICAR "$1"; then echo "$1: Eicar-Signature FOUND"; exit 1; fi; if grep -q BROKEN "$1"; then exit 2; fi`
+	scanner := &CommandScanner{Command: []string{"/bin/sh", "-c", script, "scan"}}
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"clean.txt": "hello", "evil.txt": "EICAR", "broken.txt": "BROKEN"})
+
+	result, err := scanner.Scan(context.Background(), filepath.Join(root, "clean.txt"))
+	assert.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = scanner.Scan(context.Background(), filepath.Join(root, "evil.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Infected: true, Signature: "Eicar-Signature"}, result)
+
+	_, err = scanner.Scan(context.Background(), filepath.Join(root, "broken.txt"))
+	assert.Error(t, err)
+}
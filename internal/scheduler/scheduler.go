@@ -8,7 +8,9 @@ import (
 
 	"github.com/robfig/cron/v3"
 
+	"prerender-shield/internal/acme"
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/integrity"
 	"prerender-shield/internal/prerender"
 	"prerender-shield/internal/prerender/push"
 	"prerender-shield/internal/redis"
@@ -19,41 +21,94 @@ type Scheduler struct {
 	cron          *cron.Cron
 	engineManager *prerender.EngineManager
 	pushManager   *push.PushManager
+	acmeManager   *acme.Manager
+	integrity     *integrity.Manager
 	redisClient   *redis.Client
-	tasks         map[string]cron.EntryID // 站点名 -> 任务ID
+	cfg           *config.Config
+	configMutex   sync.RWMutex
+	tasks         map[string]*siteSchedule // 站点ID -> 已注册的定时任务
 	tasksMutex    sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
 	wg            sync.WaitGroup
 }
 
+// siteSchedule 站点已注册的定时任务
+type siteSchedule struct {
+	preheatSchedule string       // 预热cron表达式，未启用预热时为空
+	preheatEntry    cron.EntryID // 预热任务ID，表达式无效时为0
+	pushEnabled     bool
+	pushBatches     int // 每日限额拆分的子批次数，小于2时每天推送一次
+	pushEntry       cron.EntryID
+	integrityCheck  int // 防篡改校验间隔（秒），未启用时为0
+	integrityEntry  cron.EntryID
+}
+
+// defaultIntegrityCheckInterval 未配置校验间隔时的防篡改校验间隔（秒）
+const defaultIntegrityCheckInterval = 300
+
+// pushBatchSchedule 在每个推送子批次时段开始时触发的定时规则
+type pushBatchSchedule struct {
+	batches int
+}
+
+// Next 实现cron.Schedule接口
+func (p pushBatchSchedule) Next(t time.Time) time.Time {
+	return push.NextSubBatchTime(t, p.batches)
+}
+
 // NewScheduler 创建新的定时任务调度器
 func NewScheduler(engineManager *prerender.EngineManager, redisClient *redis.Client, cfg *config.Config) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
-	
-	// 创建cron实例，支持秒级精度
-	c := cron.New(cron.WithSeconds())
-	
+
+	// 创建cron实例，秒字段可选，同时兼容标准5段表达式（如"0 0 * * *"）和6段表达式
+	parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	c := cron.New(cron.WithParser(parser))
+
 	return &Scheduler{
 		cron:          c,
 		engineManager: engineManager,
 		pushManager:   push.NewPushManager(cfg, redisClient),
 		redisClient:   redisClient,
-		tasks:         make(map[string]cron.EntryID),
+		cfg:           cfg,
+		tasks:         make(map[string]*siteSchedule),
 		ctx:           ctx,
 		cancel:        cancel,
 	}
 }
 
+// acmeRenewSchedule 检查ACME证书是否需要续签的周期
+const acmeRenewSchedule = "@every 1h"
+
+// acmeIssueTimeout 单个站点签发证书的超时时间
+const acmeIssueTimeout = 5 * time.Minute
+
+// SetACMEManager 设置ACME证书管理器，需在Start之前调用
+func (s *Scheduler) SetACMEManager(acmeManager *acme.Manager) {
+	s.acmeManager = acmeManager
+}
+
+// SetIntegrityManager 设置网页防篡改管理器，需在Start之前调用
+func (s *Scheduler) SetIntegrityManager(integrityManager *integrity.Manager) {
+	s.integrity = integrityManager
+}
+
 // Start 启动定时任务调度器
 func (s *Scheduler) Start() {
+	// 定期检查ACME证书，签发缺失的证书并续签即将过期的证书
+	if s.acmeManager != nil {
+		if _, err := s.cron.AddFunc(acmeRenewSchedule, s.renewCertificates); err != nil {
+			fmt.Printf("Failed to add ACME renewal cron task: %v\n", err)
+		}
+	}
+
 	// 启动cron调度器
 	s.cron.Start()
-	
+
 	// 启动监控协程
 	s.wg.Add(1)
 	go s.monitorSites()
-	
+
 	fmt.Println("Scheduler started")
 }
 
@@ -61,27 +116,43 @@ func (s *Scheduler) Start() {
 func (s *Scheduler) Stop() {
 	// 取消上下文
 	s.cancel()
-	
+
 	// 停止cron调度器
 	s.cron.Stop()
-	
+
 	// 等待监控协程结束
 	s.wg.Wait()
-	
+
 	fmt.Println("Scheduler stopped")
 }
 
+// UpdateConfig 配置变化时更新配置并重新注册定时任务，用作ConfigManager的配置变化处理函数
+func (s *Scheduler) UpdateConfig(newConfig *config.Config) {
+	s.configMutex.Lock()
+	s.cfg = newConfig
+	s.configMutex.Unlock()
+
+	s.reloadSites()
+}
+
+// getConfig 获取当前配置
+func (s *Scheduler) getConfig() *config.Config {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	return s.cfg
+}
+
 // monitorSites 监控站点配置变化，动态调整定时任务
 func (s *Scheduler) monitorSites() {
 	defer s.wg.Done()
-	
+
 	// 初始加载所有站点的定时任务
 	s.reloadSites()
-	
-	// 定期检查站点配置变化（每30秒）
+
+	// 定期检查站点配置变化（每30秒），覆盖通过API增删改站点的情况
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-s.ctx.Done():
@@ -92,61 +163,104 @@ func (s *Scheduler) monitorSites() {
 	}
 }
 
-// reloadSites 重新加载所有站点的定时任务
+// reloadSites 按站点配置重新注册定时任务
+// 配置未变化的站点保持原任务不变，已删除站点的任务会被移除
 func (s *Scheduler) reloadSites() {
-	// 获取所有站点名称
-	siteNames := s.engineManager.ListSites()
-	
-	// 记录当前所有站点名
+	cfg := s.getConfig()
+	if cfg == nil {
+		return
+	}
+
+	s.tasksMutex.Lock()
+	defer s.tasksMutex.Unlock()
+
+	// 记录当前所有站点ID
 	currentSites := make(map[string]bool)
-	
-	// 为每个站点创建或更新定时任务
-	for _, siteName := range siteNames {
-		currentSites[siteName] = true
-		
-		// 简化实现：直接创建或更新任务，不检查配置
-		// 检查是否已存在该站点的任务
-		s.tasksMutex.RLock()
-		_, taskExists := s.tasks[siteName]
-		s.tasksMutex.RUnlock()
-		
-		// 简化实现：使用默认配置创建任务
-		defaultConfig := config.PrerenderConfig{}
-		if taskExists {
-			// 任务已存在，更新任务
-			s.updateTask(siteName, defaultConfig)
-		} else {
-			// 任务不存在，创建新任务
-			s.createTask(siteName, defaultConfig)
+
+	for _, site := range cfg.Sites {
+		currentSites[site.ID] = true
+
+		preheatSchedule := ""
+		if site.Prerender.Preheat.Enabled {
+			preheatSchedule = site.Prerender.Preheat.Schedule
+		}
+
+		// 定时配置未变化，无需重新注册
+		if existing, exists := s.tasks[site.ID]; exists {
+			if existing.preheatSchedule == preheatSchedule && existing.pushEnabled == site.Prerender.Push.Enabled &&
+				existing.pushBatches == site.Prerender.Push.DailyBatches && existing.integrityCheck == s.integrityCheckInterval(site) {
+				continue
+			}
+			s.removeTask(site.ID)
 		}
+		s.createTask(site)
 	}
-	
+
 	// 删除不再存在的站点的任务
-	s.tasksMutex.RLock()
-	for siteName := range s.tasks {
-		if !currentSites[siteName] {
-			// 站点已不存在，删除任务
-			go s.removeTask(siteName)
+	for siteID := range s.tasks {
+		if !currentSites[siteID] {
+			s.removeTask(siteID)
 		}
 	}
-	s.tasksMutex.RUnlock()
+
+	// 新启用ACME的站点立即签发证书，不等待下一次续签检查
+	if s.acmeManager != nil {
+		for _, site := range cfg.Sites {
+			if site.IsEnabled() && site.TLS.ACME {
+				if _, checked := s.acmeManager.GetStatus(site.ID); !checked {
+					go s.ensureCertificate(site, cfg.Server.RestrictLocalDomains)
+				}
+			}
+		}
+	}
+}
+
+// renewCertificates 检查所有启用ACME的站点证书
+func (s *Scheduler) renewCertificates() {
+	cfg := s.getConfig()
+	if cfg == nil {
+		return
+	}
+	for _, site := range cfg.Sites {
+		if site.IsEnabled() && site.TLS.ACME {
+			s.ensureCertificate(site, cfg.Server.RestrictLocalDomains)
+		}
+	}
+}
+
+// ensureCertificate 为站点签发或续签ACME证书
+func (s *Scheduler) ensureCertificate(site config.SiteConfig, restrictLocalDomains bool) {
+	ctx, cancel := context.WithTimeout(s.ctx, acmeIssueTimeout)
+	defer cancel()
+	if err := s.acmeManager.EnsureCertificate(ctx, site, restrictLocalDomains); err != nil {
+		fmt.Printf("Failed to obtain ACME certificate for site %s: %v\n", site.ID, err)
+	}
 }
 
-// createTask 为站点创建定时任务
-func (s *Scheduler) createTask(siteName string, config config.PrerenderConfig) {
+// createTask 为站点创建定时任务，调用方需持有tasksMutex
+func (s *Scheduler) createTask(site config.SiteConfig) {
+	siteID, config := site.ID, site.Prerender
+	schedule := &siteSchedule{
+		pushEnabled: config.Push.Enabled,
+		pushBatches: config.Push.DailyBatches,
+	}
+
 	// 为预热任务创建定时任务
 	if config.Preheat.Enabled && config.Preheat.Schedule != "" {
+		schedule.preheatSchedule = config.Preheat.Schedule
+
 		// 创建预热任务函数
 		preheatTaskFunc := func() {
-			s.executePreheat(siteName)
+			s.executePreheat(siteID)
 		}
-		
+
 		// 添加到cron调度器
-		_, err := s.cron.AddFunc(config.Preheat.Schedule, preheatTaskFunc)
+		entryID, err := s.cron.AddFunc(config.Preheat.Schedule, preheatTaskFunc)
 		if err != nil {
-			fmt.Printf("Failed to add preheat cron task for site %s: %v\n", siteName, err)
+			fmt.Printf("Failed to add preheat cron task for site %s: %v\n", siteID, err)
 		} else {
-			fmt.Printf("Created preheat cron task for site %s with schedule: %s\n", siteName, config.Preheat.Schedule)
+			schedule.preheatEntry = entryID
+			fmt.Printf("Created preheat cron task for site %s with schedule: %s\n", siteID, config.Preheat.Schedule)
 		}
 	}
 
@@ -154,136 +268,200 @@ func (s *Scheduler) createTask(siteName string, config config.PrerenderConfig) {
 	if config.Push.Enabled {
 		// 创建推送任务函数
 		pushTaskFunc := func() {
-			s.executePush(siteName)
+			s.executePush(siteID)
 		}
-		
-		// 固定每天早上8点推送
-		cronExpr := "0 0 8 * * *"
-		
-		// 添加到cron调度器
-		_, err := s.cron.AddFunc(cronExpr, pushTaskFunc)
-		if err != nil {
-			fmt.Printf("Failed to add push cron task for site %s: %v\n", siteName, err)
+
+		if config.Push.DailyBatches > 1 {
+			// 每日限额拆分为子批次，在一天中均匀推送
+			schedule.pushEntry = s.cron.Schedule(pushBatchSchedule{batches: config.Push.DailyBatches}, cron.FuncJob(pushTaskFunc))
+			fmt.Printf("Created push cron task for site %s with %d daily batches\n", siteID, config.Push.DailyBatches)
 		} else {
-			fmt.Printf("Created push cron task for site %s with schedule: %s\n", siteName, cronExpr)
+			// 固定每天早上8点推送
+			cronExpr := "0 0 8 * * *"
+
+			// 添加到cron调度器
+			entryID, err := s.cron.AddFunc(cronExpr, pushTaskFunc)
+			if err != nil {
+				fmt.Printf("Failed to add push cron task for site %s: %v\n", siteID, err)
+			} else {
+				schedule.pushEntry = entryID
+				fmt.Printf("Created push cron task for site %s with schedule: %s\n", siteID, cronExpr)
+			}
 		}
 	}
+
+	// 为启用防篡改的站点创建定时校验任务
+	if interval := s.integrityCheckInterval(site); interval > 0 {
+		schedule.integrityCheck = interval
+		// 首次启用时立即建立基线，之后的变化才能被发现；已有基线时保留，停机期间的篡改在下次校验时报告
+		if _, exists := s.integrity.GetStatus(siteID); !exists {
+			go s.integrity.Rebuild(site)
+		}
+		schedule.integrityEntry = s.cron.Schedule(cron.Every(time.Duration(interval)*time.Second), cron.FuncJob(func() {
+			s.executeIntegrityCheck(siteID)
+		}))
+		fmt.Printf("Created integrity check task for site %s every %ds\n", siteID, interval)
+	}
+
+	s.tasks[siteID] = schedule
 }
 
-// updateTask 更新站点的定时任务
-func (s *Scheduler) updateTask(siteName string, config config.PrerenderConfig) {
-	// 简化实现：直接删除旧任务，创建新任务
-	s.removeTask(siteName)
-	s.createTask(siteName, config)
+// integrityCheckInterval 返回站点防篡改校验间隔（秒），未设置防篡改管理器或站点未启用防篡改时为0
+func (s *Scheduler) integrityCheckInterval(site config.SiteConfig) int {
+	if s.integrity == nil || !site.IsEnabled() || !site.FileIntegrityConfig.Enabled {
+		return 0
+	}
+	if site.FileIntegrityConfig.CheckInterval > 0 {
+		return site.FileIntegrityConfig.CheckInterval
+	}
+	return defaultIntegrityCheckInterval
 }
 
-// removeTask 移除站点的定时任务
-func (s *Scheduler) removeTask(siteName string) {
-	// 获取任务ID
-	s.tasksMutex.RLock()
-	entryID, exists := s.tasks[siteName]
-	s.tasksMutex.RUnlock()
-	
+// removeTask 移除站点的定时任务，调用方需持有tasksMutex
+func (s *Scheduler) removeTask(siteID string) {
+	schedule, exists := s.tasks[siteID]
 	if !exists {
 		return
 	}
-	
+
 	// 从cron调度器中移除任务
-	s.cron.Remove(entryID)
-	
+	if schedule.preheatEntry != 0 {
+		s.cron.Remove(schedule.preheatEntry)
+	}
+	if schedule.pushEntry != 0 {
+		s.cron.Remove(schedule.pushEntry)
+	}
+	if schedule.integrityEntry != 0 {
+		s.cron.Remove(schedule.integrityEntry)
+	}
+
 	// 从任务映射中移除
-	s.tasksMutex.Lock()
-	delete(s.tasks, siteName)
-	s.tasksMutex.Unlock()
-	
-	fmt.Printf("Removed cron task for site %s\n", siteName)
+	delete(s.tasks, siteID)
+
+	fmt.Printf("Removed cron task for site %s\n", siteID)
 }
 
-// executePreheat 执行站点的预热任务
-func (s *Scheduler) executePreheat(siteName string) {
-	fmt.Printf("Executing preheat for site %s at %s\n", siteName, time.Now().Format("2006-01-02 15:04:05"))
-	
+// executePreheat 执行站点的预热任务，上一次预热仍在运行时跳过本次执行
+func (s *Scheduler) executePreheat(siteID string) {
+	fmt.Printf("Executing preheat for site %s at %s\n", siteID, time.Now().Format("2006-01-02 15:04:05"))
+
 	// 获取站点的引擎实例
-	engine, exists := s.engineManager.GetEngine(siteName)
+	engine, exists := s.engineManager.GetEngine(siteID)
 	if !exists {
-		fmt.Printf("Engine not found for site %s\n", siteName)
+		fmt.Printf("Engine not found for site %s\n", siteID)
 		return
 	}
-	
-	// 简化实现：直接调用引擎的TriggerPreheat方法
-	_, err := engine.TriggerPreheat()
+
+	if pm := engine.GetPreheatManager(); pm != nil && pm.IsRunning() {
+		fmt.Printf("Skipping scheduled preheat for site %s: previous preheat is still running\n", siteID)
+		return
+	}
+
+	// 按站点模式确定预热地址，找不到站点配置时使用默认地址
+	var err error
+	if site := s.findSite(siteID); site != nil {
+		baseURL, domain := site.PreheatTarget()
+		_, err = engine.TriggerPreheatWithURL(baseURL, domain)
+	} else {
+		_, err = engine.TriggerPreheat()
+	}
 	if err != nil {
-		fmt.Printf("Failed to trigger preheat for site %s: %v\n", siteName, err)
+		fmt.Printf("Failed to trigger preheat for site %s: %v\n", siteID, err)
 		return
 	}
-	
-	fmt.Printf("Preheat completed for site %s\n", siteName)
+
+	fmt.Printf("Preheat triggered for site %s\n", siteID)
+}
+
+// findSite 根据站点ID查找站点配置
+func (s *Scheduler) findSite(siteID string) *config.SiteConfig {
+	cfg := s.getConfig()
+	if cfg == nil {
+		return nil
+	}
+	for i := range cfg.Sites {
+		if cfg.Sites[i].ID == siteID {
+			return &cfg.Sites[i]
+		}
+	}
+	return nil
 }
 
 // executePush 执行站点的推送任务
 func (s *Scheduler) executePush(siteName string) {
 	fmt.Printf("Executing push for site %s at %s\n", siteName, time.Now().Format("2006-01-02 15:04:05"))
-	
+
 	// 调用推送管理器的TriggerPush方法
-	_, err := s.pushManager.TriggerPush(siteName)
+	_, err := s.pushManager.TriggerPush(siteName, false)
 	if err != nil {
 		fmt.Printf("Failed to trigger push for site %s: %v\n", siteName, err)
 		return
 	}
-	
+
 	fmt.Printf("Push completed for site %s\n", siteName)
 }
 
+// executeIntegrityCheck 校验站点静态目录与防篡改基线，发现的变化由防篡改管理器记录为安全事件
+func (s *Scheduler) executeIntegrityCheck(siteID string) {
+	site := s.findSite(siteID)
+	if site == nil {
+		return
+	}
+	events, err := s.integrity.Verify(siteID, site.FileIntegrityConfig)
+	if err != nil {
+		fmt.Printf("Failed to verify file integrity for site %s: %v\n", siteID, err)
+		return
+	}
+	if len(events) > 0 {
+		fmt.Printf("File integrity check found %d changed files for site %s\n", len(events), siteID)
+	}
+}
+
 // AddManualTask 添加手动触发的预热任务
 func (s *Scheduler) AddManualTask(siteName string) {
 	// 异步执行预热任务
 	go s.executePreheat(siteName)
 }
 
-// GetTaskStatus 获取站点的任务状态
-func (s *Scheduler) GetTaskStatus(siteName string) (bool, string) {
+// GetTaskStatus 获取站点的预热定时任务状态，返回是否已调度及下次执行时间
+func (s *Scheduler) GetTaskStatus(siteID string) (bool, string) {
 	// 检查任务是否存在
 	s.tasksMutex.RLock()
-	entryID, exists := s.tasks[siteName]
+	schedule, exists := s.tasks[siteID]
 	s.tasksMutex.RUnlock()
-	
-	if !exists {
+
+	if !exists || schedule.preheatEntry == 0 {
 		return false, "not scheduled"
 	}
-	
+
 	// 获取任务的下次执行时间
-	entries := s.cron.Entries()
-	for _, entry := range entries {
-		if entry.ID == entryID {
-			nextRun := entry.Next.Format("2006-01-02 15:04:05")
-			return true, nextRun
-		}
+	entry := s.cron.Entry(schedule.preheatEntry)
+	if !entry.Valid() {
+		return false, "not found"
+	}
+	if entry.Next.IsZero() {
+		// 调度器尚未启动时，根据表达式计算下次执行时间
+		return true, entry.Schedule.Next(time.Now()).Format("2006-01-02 15:04:05")
 	}
-	
-	return false, "not found"
+	return true, entry.Next.Format("2006-01-02 15:04:05")
 }
 
-// ListTasks 列出所有定时任务
+// ListTasks 列出所有站点预热定时任务的下次执行时间
 func (s *Scheduler) ListTasks() map[string]string {
 	result := make(map[string]string)
-	
-	// 获取所有任务
-	entries := s.cron.Entries()
-	
-	// 反向映射：entryID -> siteName
+
 	s.tasksMutex.RLock()
-	entryToSite := make(map[cron.EntryID]string)
-	for siteName, entryID := range s.tasks {
-		entryToSite[entryID] = siteName
+	siteIDs := make([]string, 0, len(s.tasks))
+	for siteID := range s.tasks {
+		siteIDs = append(siteIDs, siteID)
 	}
 	s.tasksMutex.RUnlock()
-	
-	// 构建结果
-	for _, entry := range entries {
-		if siteName, exists := entryToSite[entry.ID]; exists {
-			result[siteName] = entry.Next.Format("2006-01-02 15:04:05")
+
+	for _, siteID := range siteIDs {
+		if scheduled, nextRun := s.GetTaskStatus(siteID); scheduled {
+			result[siteID] = nextRun
 		}
 	}
-	
+
 	return result
 }
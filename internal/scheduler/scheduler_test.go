@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/integrity"
+	"prerender-shield/internal/prerender"
+)
+
+// newTestSite 创建测试用站点配置
+func newTestSite(id string, enabled bool, schedule string) config.SiteConfig {
+	site := config.SiteConfig{ID: id}
+	site.Prerender.Preheat.Enabled = enabled
+	site.Prerender.Preheat.Schedule = schedule
+	return site
+}
+
+// TestSchedulerRegistersPreheatPerSite 测试按站点配置注册、更新和移除定时预热任务
+func TestSchedulerRegistersPreheatPerSite(t *testing.T) {
+	cfg := &config.Config{Sites: []config.SiteConfig{
+		newTestSite("site1", true, "0 3 * * *"),
+		newTestSite("site2", false, "0 3 * * *"),
+		newTestSite("site3", true, "not a cron"),
+	}}
+	s := NewScheduler(prerender.NewEngineManager(t.TempDir()), nil, cfg)
+	defer s.Stop()
+	s.reloadSites()
+
+	scheduled, nextRun := s.GetTaskStatus("site1")
+	if !scheduled {
+		t.Fatal("site1 should be scheduled")
+	}
+	next, err := time.ParseInLocation("2006-01-02 15:04:05", nextRun, time.Local)
+	if err != nil || next.Hour() != 3 || next.Minute() != 0 {
+		t.Errorf("unexpected nextRun for site1: %q", nextRun)
+	}
+	if scheduled, _ := s.GetTaskStatus("site2"); scheduled {
+		t.Error("site2 has preheat disabled and should not be scheduled")
+	}
+	if scheduled, _ := s.GetTaskStatus("site3"); scheduled {
+		t.Error("site3 has an invalid schedule and should not be scheduled")
+	}
+	if tasks := s.ListTasks(); len(tasks) != 1 || tasks["site1"] != nextRun {
+		t.Errorf("expected only site1 in task list, got %v", tasks)
+	}
+
+	// 修改站点的预热时间后重新注册
+	s.UpdateConfig(&config.Config{Sites: []config.SiteConfig{
+		newTestSite("site1", true, "30 4 * * *"),
+	}})
+	if _, nextRun := s.GetTaskStatus("site1"); nextRun[11:16] != "04:30" {
+		t.Errorf("expected updated nextRun at 04:30, got %q", nextRun)
+	}
+	if entries := len(s.cron.Entries()); entries != 1 {
+		t.Errorf("expected 1 cron entry after update, got %d", entries)
+	}
+
+	// 删除站点后移除定时任务
+	s.UpdateConfig(&config.Config{})
+	if scheduled, _ := s.GetTaskStatus("site1"); scheduled {
+		t.Error("removed site should not be scheduled")
+	}
+	if entries := len(s.cron.Entries()); entries != 0 {
+		t.Errorf("expected no cron entries after removing site, got %d", entries)
+	}
+}
+
+// TestSchedulerSpreadsPushBatches 测试配置子批次后推送任务按时段触发，修改子批次数后重新注册
+func TestSchedulerSpreadsPushBatches(t *testing.T) {
+	site := config.SiteConfig{ID: "site1"}
+	site.Prerender.Push.Enabled = true
+	site.Prerender.Push.DailyBatches = 24
+	s := NewScheduler(prerender.NewEngineManager(t.TempDir()), nil, &config.Config{Sites: []config.SiteConfig{site}})
+	defer s.Stop()
+	s.reloadSites()
+
+	now := time.Date(2026, 3, 1, 10, 20, 0, 0, time.Local)
+	entry := s.cron.Entry(s.tasks["site1"].pushEntry)
+	if entry.Schedule == nil {
+		t.Fatal("push task should be registered")
+	}
+	if next := entry.Schedule.Next(now); !next.Equal(time.Date(2026, 3, 1, 11, 0, 0, 0, time.Local)) {
+		t.Errorf("expected hourly push batch at 11:00, got %s", next)
+	}
+
+	site.Prerender.Push.DailyBatches = 0
+	s.UpdateConfig(&config.Config{Sites: []config.SiteConfig{site}})
+	entry = s.cron.Entry(s.tasks["site1"].pushEntry)
+	if next := entry.Schedule.Next(now); !next.Equal(time.Date(2026, 3, 2, 8, 0, 0, 0, time.Local)) {
+		t.Errorf("expected daily push at 08:00, got %s", next)
+	}
+}
+
+// TestSchedulerRegistersIntegrityCheck 测试启用防篡改的站点按校验间隔注册定时校验，并在注册时建立基线
+func TestSchedulerRegistersIntegrityCheck(t *testing.T) {
+	site := config.SiteConfig{ID: "site1"}
+	site.FileIntegrityConfig = config.FileIntegrityConfig{Enabled: true, CheckInterval: 60}
+	s := NewScheduler(prerender.NewEngineManager(t.TempDir()), nil, &config.Config{Sites: []config.SiteConfig{site}})
+	defer s.Stop()
+	integrityManager := integrity.NewManager(t.TempDir(), t.TempDir())
+	s.SetIntegrityManager(integrityManager)
+	s.reloadSites()
+
+	entry := s.cron.Entry(s.tasks["site1"].integrityEntry)
+	if entry.Schedule == nil {
+		t.Fatal("integrity check should be registered")
+	}
+	now := time.Date(2026, 3, 1, 10, 20, 0, 0, time.Local)
+	if next := entry.Schedule.Next(now); !next.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected integrity check every 60s, got %s", next)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, exists := integrityManager.GetStatus("site1"); exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected baseline to be built when the check is registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 关闭防篡改后移除定时校验
+	site.FileIntegrityConfig.Enabled = false
+	s.UpdateConfig(&config.Config{Sites: []config.SiteConfig{site}})
+	if s.tasks["site1"].integrityEntry != 0 || len(s.cron.Entries()) != 0 {
+		t.Errorf("expected integrity check to be removed, got %d cron entries", len(s.cron.Entries()))
+	}
+}
@@ -11,35 +11,54 @@ import (
 	"prerender-shield/internal/logging"
 )
 
-// GeoLocation 地理位置信息
-type GeoLocation struct {
-	Country     string  `json:"country"`      // 国家名称
-	CountryCode string  `json:"country_code"` // 国家代码 (ISO 3166-1 alpha-2)
-	City        string  `json:"city"`         // 城市
-	Latitude    float64 `json:"latitude"`     // 纬度
-	Longitude   float64 `json:"longitude"`    // 经度
-}
+// GeoLocation 地理位置信息，与日志管理器共用同一类型，使GeoIPService可直接作为logging.GeoIPResolver
+type GeoLocation = logging.GeoLocation
 
 // GeoIPResolver 定义GeoIP解析接口，便于测试Mock
 type GeoIPResolver interface {
 	LookupCountryISO(ip string) (string, error)
 }
 
+const (
+	// defaultGeoIPRateLimit 默认每分钟最多调用外部API的次数（ip-api.com免费版限制为每分钟45次）
+	defaultGeoIPRateLimit = 40
+	// defaultGeoIPNegativeCacheTTL 默认查询失败IP的缓存时间
+	defaultGeoIPNegativeCacheTTL = 5 * time.Minute
+)
+
+// errGeoIPRateLimited 非阻塞查询时超出外部API调用频率
+var errGeoIPRateLimited = fmt.Errorf("geoip lookup rate limited")
+
 // GeoIPService IP地理位置解析服务
 type GeoIPService struct {
 	client         *http.Client
 	mu             sync.RWMutex
 	serverLocation *GeoLocation // 本机地理位置（用于内网IP回退）
 	cache          sync.Map     // 内存缓存 map[string]*GeoLocation (IP -> Location)
+	negativeCache  sync.Map     // 查询失败的IP map[string]time.Time (IP -> 过期时间)
+	negativeTTL    time.Duration
+	limiter        *rateLimiter                         // 外部API调用频率限制
+	providers      []func(string) (*GeoLocation, error) // 按顺序回退的查询接口
+	database       *MMDBReader                          // 本地GeoIP数据库，可用时不再调用外部API
 }
 
 // NewGeoIPService 创建新的GeoIP服务
+// dbPath为本地MaxMind GeoLite2数据库路径，数据库可用时只查询本地数据库，为空或文件不存在时回退到在线API
 func NewGeoIPService(dbPath string) *GeoIPService {
-	// dbPath参数被忽略，因为不再使用本地数据库
 	service := &GeoIPService{
 		client: &http.Client{
 			Timeout: 5 * time.Second, // 缩短超时时间
 		},
+		negativeTTL: defaultGeoIPNegativeCacheTTL,
+		limiter:     newRateLimiter(defaultGeoIPRateLimit, time.Minute),
+	}
+	service.providers = []func(string) (*GeoLocation, error){
+		service.queryIPAPI,
+		service.queryIPAPIco,
+		service.queryGeoJS,
+	}
+	if dbPath != "" {
+		service.database = NewMMDBReader(dbPath, defaultGeoIPDatabaseCheckInterval)
 	}
 
 	// 异步初始化本机地理位置信息
@@ -48,6 +67,16 @@ func NewGeoIPService(dbPath string) *GeoIPService {
 	return service
 }
 
+// SetLimits 设置外部API调用频率（每分钟次数）和查询失败IP的缓存时间，非正数保持原值
+func (s *GeoIPService) SetLimits(ratePerMinute int, negativeCacheTTL time.Duration) {
+	if ratePerMinute > 0 {
+		s.limiter = newRateLimiter(ratePerMinute, time.Minute)
+	}
+	if negativeCacheTTL > 0 {
+		s.negativeTTL = negativeCacheTTL
+	}
+}
+
 // initServerLocation 初始化本机地理位置
 func (s *GeoIPService) initServerLocation() {
 	// 尝试多次获取，直到成功
@@ -122,10 +151,14 @@ func (s *GeoIPService) fetchServerLocation() (*GeoLocation, error) {
 	return nil, fmt.Errorf("failed to fetch server location")
 }
 
-// Close 关闭GeoIP服务（清理资源）
+// Database 返回本地GeoIP数据库，未配置数据库路径时返回nil
+func (s *GeoIPService) Database() *MMDBReader {
+	return s.database
+}
+
+// Close 关闭GeoIP服务，停止检查本地数据库文件变化
 func (s *GeoIPService) Close() error {
-	// 不再需要关闭数据库连接
-	return nil
+	return s.database.Close()
 }
 
 // LookupCountryISO 快速查询IP所属国家代码 (用于WAF)
@@ -149,8 +182,8 @@ func (s *GeoIPService) LookupCountryISO(ip string) (string, error) {
 		}
 	}
 
-	// 3. 调用API
-	location, err := s.queryAPIWithFallback(ip)
+	// 3. 查询本地数据库或调用API，WAF在请求路径上查询，超出调用频率时不等待
+	location, err := s.resolve(ip, false)
 
 	// 1. 检查是否为内网IP或获取位置失败的IP
 	if isPrivateIP(ip) || err != nil || location == nil {
@@ -164,8 +197,6 @@ func (s *GeoIPService) LookupCountryISO(ip string) (string, error) {
 		return "Local", nil
 	}
 
-	// 写入缓存
-	s.cache.Store(ip, location)
 	return location.CountryCode, nil
 }
 
@@ -193,8 +224,8 @@ func (s *GeoIPService) GetLocation(ip string) (*GeoLocation, error) {
 		}
 	}
 
-	// 3. 调用API (作为回退)
-	location, err := s.queryAPIWithFallback(ip)
+	// 3. 查询本地数据库或调用API (作为回退)，日志清洗可以等待调用频率限制
+	location, err := s.resolve(ip, true)
 
 	// 如果API查询失败，或者返回空，也回退到本机位置
 	if err != nil || location == nil {
@@ -215,21 +246,44 @@ func (s *GeoIPService) GetLocation(ip string) (*GeoLocation, error) {
 		}, nil
 	}
 
-	// 写入缓存
+	return location, nil
+}
+
+// resolve 本地数据库可用时只查询本地数据库，数据库不存在时调用外部API并缓存查询结果
+func (s *GeoIPService) resolve(ip string, wait bool) (*GeoLocation, error) {
+	if s.database.Available() {
+		return s.database.Lookup(ip)
+	}
+
+	location, err := s.queryAPIWithFallback(ip, wait)
+	if err != nil || location == nil {
+		return location, err
+	}
 	s.cache.Store(ip, location)
 	return location, nil
 }
 
 // queryAPIWithFallback 轮询API获取地理位置
-func (s *GeoIPService) queryAPIWithFallback(ip string) (*GeoLocation, error) {
-	providers := []func(string) (*GeoLocation, error){
-		s.queryIPAPI,
-		s.queryIPAPIco,
-		s.queryGeoJS,
+// 每次调用外部API都受频率限制，wait为false时超出频率直接返回错误；
+// 全部接口失败的IP会在负缓存有效期内直接返回错误，避免反复查询
+func (s *GeoIPService) queryAPIWithFallback(ip string, wait bool) (*GeoLocation, error) {
+	if expiry, ok := s.negativeCache.Load(ip); ok {
+		if time.Now().Before(expiry.(time.Time)) {
+			return nil, fmt.Errorf("geoip lookup for %s failed recently", ip)
+		}
+		s.negativeCache.Delete(ip)
 	}
 
 	var lastErr error
-	for _, provider := range providers {
+	for _, provider := range s.providers {
+		if s.limiter != nil {
+			if wait {
+				s.limiter.Wait()
+			} else if !s.limiter.Allow() {
+				return nil, errGeoIPRateLimited
+			}
+		}
+
 		location, err := provider(ip)
 		if err == nil {
 			return location, nil
@@ -237,6 +291,8 @@ func (s *GeoIPService) queryAPIWithFallback(ip string) (*GeoLocation, error) {
 		lastErr = err
 		time.Sleep(100 * time.Millisecond)
 	}
+
+	s.negativeCache.Store(ip, time.Now().Add(s.negativeTTL))
 	return nil, fmt.Errorf("all providers failed, last error: %v", lastErr)
 }
 
@@ -368,3 +424,41 @@ func isPrivateIP(ip string) bool {
 		(len(ip) >= 7 && ip[:7] == "192.168") ||
 		(len(ip) >= 4 && ip[:4] == "172.")
 }
+
+// rateLimiter 按固定间隔发放调用名额的频率限制器
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // 下一个可用名额的时间
+}
+
+// newRateLimiter 创建每个周期最多允许limit次调用的频率限制器
+func newRateLimiter(limit int, per time.Duration) *rateLimiter {
+	return &rateLimiter{interval: per / time.Duration(limit)}
+}
+
+// Wait 阻塞直到获得调用名额
+func (l *rateLimiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	time.Sleep(wait)
+}
+
+// Allow 当前有可用名额时占用并返回true，否则立即返回false
+func (l *rateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.next.After(now) {
+		return false
+	}
+	l.next = now.Add(l.interval)
+	return true
+}
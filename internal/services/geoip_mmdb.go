@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+
+	"prerender-shield/internal/logging"
+)
+
+// defaultGeoIPDatabaseCheckInterval 默认检查本地GeoIP数据库文件是否变化的间隔
+const defaultGeoIPDatabaseCheckInterval = time.Minute
+
+// errGeoIPDatabaseUnavailable 本地GeoIP数据库未加载
+var errGeoIPDatabaseUnavailable = fmt.Errorf("geoip database is not available")
+
+// MMDBReader 本地MaxMind GeoLite2数据库（.mmdb）读取器，实现GeoIPResolver
+// 定期检查文件的修改时间和大小，文件变化时重新加载；文件不存在时不可用，出现后自动加载
+type MMDBReader struct {
+	path string
+
+	mu      sync.RWMutex
+	reader  *geoip2.Reader
+	modTime time.Time
+	size    int64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMMDBReader 创建本地GeoIP数据库读取器，checkInterval为检查文件变化的间隔，非正数时不自动重新加载
+func NewMMDBReader(path string, checkInterval time.Duration) *MMDBReader {
+	r := &MMDBReader{
+		path: path,
+		stop: make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		logging.DefaultLogger.Warn("Failed to load GeoIP database %s: %v", path, err)
+	}
+	if checkInterval > 0 {
+		go r.watch(checkInterval)
+	}
+	return r
+}
+
+// watch 定期检查数据库文件是否变化
+func (r *MMDBReader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.reload(); err != nil {
+				logging.DefaultLogger.Warn("Failed to reload GeoIP database %s: %v", r.path, err)
+			}
+		}
+	}
+}
+
+// reload 文件修改时间或大小变化时重新加载数据库，文件被删除时卸载数据库
+// 数据库整体读入内存，更新文件时不会影响正在进行的查询；加载失败时继续使用旧数据库
+func (r *MMDBReader) reload() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		r.mu.Lock()
+		removed := r.reader != nil
+		r.reader = nil
+		r.modTime = time.Time{}
+		r.size = 0
+		r.mu.Unlock()
+		if removed {
+			logging.DefaultLogger.Warn("GeoIP database %s was removed, falling back to online lookups", r.path)
+		}
+		return nil
+	}
+
+	r.mu.RLock()
+	unchanged := r.reader != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size
+	r.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	reader, err := geoip2.FromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.reader = reader
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+	r.mu.Unlock()
+
+	metadata := reader.Metadata()
+	logging.DefaultLogger.Info("GeoIP database loaded: %s (%s, built %s)", r.path, metadata.DatabaseType,
+		time.Unix(int64(metadata.BuildEpoch), 0).UTC().Format("2006-01-02"))
+	return nil
+}
+
+// Available 数据库是否已加载
+func (r *MMDBReader) Available() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.reader != nil
+}
+
+// Lookup 在本地数据库中查询IP地理位置，City数据库同时返回城市和经纬度
+func (r *MMDBReader) Lookup(ip string) (*GeoLocation, error) {
+	if r == nil {
+		return nil, errGeoIPDatabaseUnavailable
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.reader == nil {
+		return nil, errGeoIPDatabaseUnavailable
+	}
+
+	var location GeoLocation
+	if strings.Contains(r.reader.Metadata().DatabaseType, "City") {
+		record, err := r.reader.City(parsed)
+		if err != nil {
+			return nil, err
+		}
+		location = GeoLocation{
+			Country:     record.Country.Names["en"],
+			CountryCode: record.Country.IsoCode,
+			City:        record.City.Names["en"],
+			Latitude:    record.Location.Latitude,
+			Longitude:   record.Location.Longitude,
+		}
+	} else {
+		record, err := r.reader.Country(parsed)
+		if err != nil {
+			return nil, err
+		}
+		location = GeoLocation{
+			Country:     record.Country.Names["en"],
+			CountryCode: record.Country.IsoCode,
+		}
+	}
+
+	if location.CountryCode == "" {
+		return nil, fmt.Errorf("no geoip record for %s", ip)
+	}
+	return &location, nil
+}
+
+// LookupCountryISO 在本地数据库中查询IP所属国家代码，不会访问网络
+func (r *MMDBReader) LookupCountryISO(ip string) (string, error) {
+	location, err := r.Lookup(ip)
+	if err != nil {
+		return "", err
+	}
+	return location.CountryCode, nil
+}
+
+// Close 停止检查文件变化并卸载数据库
+func (r *MMDBReader) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.stopOnce.Do(func() { close(r.stop) })
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reader == nil {
+		return nil
+	}
+	err := r.reader.Close()
+	r.reader = nil
+	return err
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestMMDB 写入只有一个搜索树节点的IPv4国家数据库：首位为0的IP属于left国家，首位为1的IP属于right国家
+func writeTestMMDB(t *testing.T, path, left, right string) {
+	t.Helper()
+
+	str := func(s string) []byte { return append([]byte{0x40 | byte(len(s))}, s...) }
+	mapHeader := func(pairs int) []byte { return []byte{0xe0 | byte(pairs)} }
+	uint16Value := func(v uint16) []byte { return []byte{0xa2, byte(v >> 8), byte(v)} }
+	uint32Value := func(v uint32) []byte {
+		b := []byte{0xc4, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], v)
+		return b
+	}
+	countryRecord := func(iso string) []byte {
+		var b []byte
+		b = append(b, mapHeader(1)...)
+		b = append(b, str("country")...)
+		b = append(b, mapHeader(1)...)
+		b = append(b, str("iso_code")...)
+		return append(b, str(iso)...)
+	}
+
+	const nodeCount = 1
+	leftData := countryRecord(left)
+	rightData := countryRecord(right)
+
+	// 记录值大于节点数时指向数据区：nodeCount + 16字节分隔符 + 数据偏移
+	var db []byte
+	for _, pointer := range []int{nodeCount + 16, nodeCount + 16 + len(leftData)} {
+		db = append(db, byte(pointer>>16), byte(pointer>>8), byte(pointer))
+	}
+	db = append(db, make([]byte, 16)...)
+	db = append(db, leftData...)
+	db = append(db, rightData...)
+
+	db = append(db, "\xab\xcd\xefMaxMind.com"...)
+	db = append(db, mapHeader(5)...)
+	db = append(db, str("database_type")...)
+	db = append(db, str("GeoLite2-Country")...)
+	db = append(db, str("ip_version")...)
+	db = append(db, uint16Value(4)...)
+	db = append(db, str("node_count")...)
+	db = append(db, uint32Value(nodeCount)...)
+	db = append(db, str("record_size")...)
+	db = append(db, uint16Value(24)...)
+	db = append(db, str("binary_format_major_version")...)
+	db = append(db, uint16Value(2)...)
+
+	if err := os.WriteFile(path, db, 0644); err != nil {
+		t.Fatalf("failed to write test database: %v", err)
+	}
+}
+
+// TestMMDBReaderHotReload 测试本地数据库查询，以及文件出现、更新和删除后自动重新加载
+func TestMMDBReaderHotReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+
+	reader := NewMMDBReader(path, 0)
+	defer reader.Close()
+	if reader.Available() {
+		t.Fatal("expected reader to be unavailable before the database exists")
+	}
+	if _, err := reader.LookupCountryISO("1.2.3.4"); err == nil {
+		t.Fatal("expected lookup to fail without a database")
+	}
+
+	writeTestMMDB(t, path, "US", "CN")
+	if err := reader.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	for ip, want := range map[string]string{"1.2.3.4": "US", "200.1.1.1": "CN"} {
+		if got, err := reader.LookupCountryISO(ip); err != nil || got != want {
+			t.Fatalf("LookupCountryISO(%s) = %q, %v; want %q", ip, got, err, want)
+		}
+	}
+
+	// 修改时间变化后加载新文件
+	writeTestMMDB(t, path, "DE", "JP")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if got, _ := reader.LookupCountryISO("1.2.3.4"); got != "DE" {
+		t.Fatalf("expected updated database to return DE, got %q", got)
+	}
+
+	// 无效文件不替换已加载的数据库
+	if err := os.WriteFile(path, []byte("not a database"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.reload(); err == nil {
+		t.Fatal("expected reload of an invalid database to fail")
+	}
+	if got, _ := reader.LookupCountryISO("200.1.1.1"); got != "JP" {
+		t.Fatalf("expected previous database to stay loaded, got %q", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if reader.Available() {
+		t.Fatal("expected reader to be unavailable after the database was removed")
+	}
+}
+
+// TestGeoIPServicePrefersDatabase 测试本地数据库可用时不调用外部API，数据库不存在时回退到外部API
+func TestGeoIPServicePrefersDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+
+	calls := 0
+	service := &GeoIPService{
+		negativeTTL: time.Minute,
+		providers: []func(string) (*GeoLocation, error){func(ip string) (*GeoLocation, error) {
+			calls++
+			return &GeoLocation{CountryCode: "FR"}, nil
+		}},
+		database: NewMMDBReader(path, 0),
+	}
+	defer service.Close()
+
+	if code, _ := service.LookupCountryISO("8.8.8.8"); code != "FR" || calls != 1 {
+		t.Fatalf("expected API fallback without database, got %q after %d calls", code, calls)
+	}
+
+	writeTestMMDB(t, path, "US", "CN")
+	if err := service.database.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if code, _ := service.LookupCountryISO("200.1.1.1"); code != "CN" {
+		t.Fatalf("expected database lookup, got %q", code)
+	}
+	location, err := service.GetLocation("9.9.9.9")
+	if err != nil || location.CountryCode != "US" {
+		t.Fatalf("expected database location, got %+v, %v", location, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected no API calls while the database is available, got %d", calls)
+	}
+}
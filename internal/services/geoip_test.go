@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestLookupLocationsRespectsLimits 测试清洗大量积压IP时不超过并发上限和API调用频率
+func TestLookupLocationsRespectsLimits(t *testing.T) {
+	const (
+		concurrency = 3
+		interval    = 20 * time.Millisecond
+	)
+
+	var (
+		mu       sync.Mutex
+		active   int
+		maxSeen  int
+		callTime []time.Time
+	)
+	provider := func(ip string) (*GeoLocation, error) {
+		mu.Lock()
+		active++
+		if active > maxSeen {
+			maxSeen = active
+		}
+		callTime = append(callTime, time.Now())
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return &GeoLocation{CountryCode: "US"}, nil
+	}
+
+	service := &GeoIPService{
+		negativeTTL: time.Minute,
+		limiter:     newRateLimiter(1, interval),
+		providers:   []func(string) (*GeoLocation, error){provider},
+	}
+	processor := &LogProcessor{geoIP: service, washConcurrency: concurrency}
+
+	ips := make([]string, 20)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("8.8.4.%d", i+1)
+	}
+
+	start := time.Now()
+	locations := processor.lookupLocations(ips)
+
+	if len(locations) != len(ips) {
+		t.Fatalf("expected %d locations, got %d", len(ips), len(locations))
+	}
+	if maxSeen > concurrency {
+		t.Errorf("expected at most %d concurrent lookups, got %d", concurrency, maxSeen)
+	}
+
+	// 第i次调用不能早于 start + i*interval
+	sort.Slice(callTime, func(i, j int) bool { return callTime[i].Before(callTime[j]) })
+	for i, ts := range callTime {
+		if earliest := time.Duration(i) * interval; ts.Sub(start) < earliest-time.Millisecond {
+			t.Fatalf("call %d happened after %v, rate limit allows it after %v", i, ts.Sub(start), earliest)
+		}
+	}
+}
+
+// TestGeoIPNegativeCache 测试查询失败的IP在负缓存有效期内不再调用API
+func TestGeoIPNegativeCache(t *testing.T) {
+	calls := 0
+	service := &GeoIPService{
+		negativeTTL: time.Minute,
+		limiter:     newRateLimiter(1000, time.Second),
+		providers: []func(string) (*GeoLocation, error){
+			func(ip string) (*GeoLocation, error) {
+				calls++
+				return nil, errors.New("lookup failed")
+			},
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := service.GetLocation("8.8.8.8"); err == nil {
+			t.Fatal("expected lookup error")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected provider to be called once, got %d", calls)
+	}
+}
+
+// TestLookupCountryISORateLimited 测试WAF查询超出调用频率时不等待
+func TestLookupCountryISORateLimited(t *testing.T) {
+	calls := 0
+	service := &GeoIPService{
+		negativeTTL: time.Minute,
+		limiter:     newRateLimiter(1, time.Hour),
+		providers: []func(string) (*GeoLocation, error){
+			func(ip string) (*GeoLocation, error) {
+				calls++
+				return &GeoLocation{CountryCode: "US"}, nil
+			},
+		},
+	}
+
+	if code, err := service.LookupCountryISO("8.8.8.8"); err != nil || code != "US" {
+		t.Fatalf("expected US, got %q, %v", code, err)
+	}
+
+	start := time.Now()
+	service.LookupCountryISO("1.1.1.1")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rate limited lookup should not block, took %v", elapsed)
+	}
+	if calls != 1 {
+		t.Errorf("expected provider to be called once, got %d", calls)
+	}
+}
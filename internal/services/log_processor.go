@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"prerender-shield/internal/config"
@@ -12,13 +13,21 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	// washBatchSize 每轮清洗的日志数量
+	washBatchSize = 100
+	// defaultWashConcurrency 默认GeoIP并发查询数
+	defaultWashConcurrency = 4
+)
+
 type LogProcessor struct {
-	crawlerLogMgr *logging.CrawlerLogManager
-	visitLogMgr   *logging.VisitLogManager
-	geoIP         *GeoIPService
-	configMgr     *config.ConfigManager
-	redisClient   *redis.Client
-	ctx           context.Context
+	crawlerLogMgr   *logging.CrawlerLogManager
+	visitLogMgr     *logging.VisitLogManager
+	geoIP           *GeoIPService
+	configMgr       *config.ConfigManager
+	redisClient     *redis.Client
+	ctx             context.Context
+	washConcurrency int // 清洗时GeoIP并发查询上限
 }
 
 func NewLogProcessor(
@@ -28,56 +37,65 @@ func NewLogProcessor(
 	configMgr *config.ConfigManager,
 	redisClient *redis.Client,
 ) *LogProcessor {
+	concurrency := configMgr.GetConfig().GeoIP.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultWashConcurrency
+	}
+
 	return &LogProcessor{
-		crawlerLogMgr: crawlerLogMgr,
-		visitLogMgr:   visitLogMgr,
-		geoIP:         geoIP,
-		configMgr:     configMgr,
-		redisClient:   redisClient,
-		ctx:           context.Background(),
+		crawlerLogMgr:   crawlerLogMgr,
+		visitLogMgr:     visitLogMgr,
+		geoIP:           geoIP,
+		configMgr:       configMgr,
+		redisClient:     redisClient,
+		ctx:             context.Background(),
+		washConcurrency: concurrency,
 	}
 }
 
 func (p *LogProcessor) Start() {
 	go func() {
 		for {
-			p.processCrawlerLogs()
-			p.processVisitLogs()
-			time.Sleep(5 * time.Second)
+			crawlerCount := p.processCrawlerLogs()
+			visitCount := p.processVisitLogs()
+			// 积压未清洗完时立即处理下一批；只计算更新成功的日志，Redis写入失败时等待后再处理，
+			// 避免立即取出下一批日志重复查询地理位置
+			if crawlerCount < washBatchSize && visitCount < washBatchSize {
+				time.Sleep(5 * time.Second)
+			}
 		}
 	}()
 }
 
-func (p *LogProcessor) processCrawlerLogs() {
-	logs, err := p.crawlerLogMgr.GetUnwashedLogs(10)
+// processCrawlerLogs 清洗一批爬虫日志，返回更新成功的日志数量
+func (p *LogProcessor) processCrawlerLogs() int {
+	logs, err := p.crawlerLogMgr.GetUnwashedLogs(washBatchSize)
 	if err != nil || len(logs) == 0 {
-		return
+		return 0
 	}
 
 	logsByIP := make(map[string][]*logging.CrawlerLog)
+	ips := make([]string, 0, len(logs))
 	for i := range logs {
+		if _, ok := logsByIP[logs[i].IP]; !ok {
+			ips = append(ips, logs[i].IP)
+		}
 		logsByIP[logs[i].IP] = append(logsByIP[logs[i].IP], &logs[i])
 	}
+	locations := p.lookupLocations(ips)
 
+	washed := 0
 	for ip, ipLogs := range logsByIP {
-		location, err := p.geoIP.GetLocation(ip)
-		if err != nil {
-			logging.DefaultLogger.Warn("GeoIP failed for %s: %v", ip, err)
-		}
-
+		location := locations[ip]
 		for _, logEntry := range ipLogs {
 			oldLog := *logEntry
-			if location != nil {
-				logEntry.Country = location.Country
-				logEntry.CountryCode = location.CountryCode
-				logEntry.City = location.City
-				logEntry.Latitude = location.Latitude
-				logEntry.Longitude = location.Longitude
-			}
+			logEntry.SetLocation(location)
 			logEntry.Washed = true
 
 			if err := p.crawlerLogMgr.UpdateLog(oldLog, *logEntry); err != nil {
 				logging.DefaultLogger.Error("Failed to update crawler log: %v", err)
+			} else {
+				washed++
 			}
 
 			if location != nil {
@@ -85,38 +103,38 @@ func (p *LogProcessor) processCrawlerLogs() {
 			}
 		}
 	}
+	return washed
 }
 
-func (p *LogProcessor) processVisitLogs() {
-	logs, err := p.visitLogMgr.GetUnwashedLogs(10)
+// processVisitLogs 清洗一批访问日志，返回更新成功的日志数量
+func (p *LogProcessor) processVisitLogs() int {
+	logs, err := p.visitLogMgr.GetUnwashedLogs(washBatchSize)
 	if err != nil || len(logs) == 0 {
-		return
+		return 0
 	}
 
 	logsByIP := make(map[string][]*logging.VisitLog)
+	ips := make([]string, 0, len(logs))
 	for i := range logs {
+		if _, ok := logsByIP[logs[i].IP]; !ok {
+			ips = append(ips, logs[i].IP)
+		}
 		logsByIP[logs[i].IP] = append(logsByIP[logs[i].IP], &logs[i])
 	}
+	locations := p.lookupLocations(ips)
 
+	washed := 0
 	for ip, ipLogs := range logsByIP {
-		location, err := p.geoIP.GetLocation(ip)
-		if err != nil {
-			logging.DefaultLogger.Warn("GeoIP failed for %s: %v", ip, err)
-		}
-
+		location := locations[ip]
 		for _, logEntry := range ipLogs {
 			oldLog := *logEntry
-			if location != nil {
-				logEntry.Country = location.Country
-				logEntry.CountryCode = location.CountryCode
-				logEntry.City = location.City
-				logEntry.Latitude = location.Latitude
-				logEntry.Longitude = location.Longitude
-			}
+			logEntry.SetLocation(location)
 			logEntry.Washed = true
 
 			if err := p.visitLogMgr.UpdateLog(oldLog, *logEntry); err != nil {
 				logging.DefaultLogger.Error("Failed to update visit log: %v", err)
+			} else {
+				washed++
 			}
 
 			if location != nil {
@@ -124,6 +142,42 @@ func (p *LogProcessor) processVisitLogs() {
 			}
 		}
 	}
+	return washed
+}
+
+// lookupLocations 使用有界的工作池并发查询IP地理位置，查询失败的IP不在结果中
+func (p *LogProcessor) lookupLocations(ips []string) map[string]*GeoLocation {
+	concurrency := p.washConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultWashConcurrency
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		locations = make(map[string]*GeoLocation, len(ips))
+		semaphore = make(chan struct{}, concurrency)
+	)
+	for _, ip := range ips {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(ip string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			location, err := p.geoIP.GetLocation(ip)
+			if err != nil {
+				logging.DefaultLogger.Warn("GeoIP failed for %s: %v", ip, err)
+				return
+			}
+			mu.Lock()
+			locations[ip] = location
+			mu.Unlock()
+		}(ip)
+	}
+	wg.Wait()
+
+	return locations
 }
 
 func (p *LogProcessor) checkAndBan(siteID, ip, countryCode string) {
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+
+	"prerender-shield/internal/logging"
+)
+
+// startFailingUpdateRedis 启动miniredis，待清洗队列中依次放入queue中的日志，
+// 更新日志用到的ZREM、ZADD返回错误，模拟清洗时写入失败，返回服务器地址
+func startFailingUpdateRedis(t *testing.T, queue []string) string {
+	mr := miniredis.RunT(t)
+	for _, logJSON := range queue {
+		mr.RPush("crawler_logs:unwashed", logJSON)
+	}
+	mr.Server().SetPreHook(func(peer *server.Peer, command string, args ...string) bool {
+		if command == "ZREM" || command == "ZADD" {
+			peer.WriteError("ERR write failed")
+			return true
+		}
+		return false
+	})
+	return mr.Addr()
+}
+
+// TestProcessCrawlerLogsCountsOnlyWashed 测试更新失败的日志不计入清洗数量，满批次取出但写入失败时不会立即处理下一批
+func TestProcessCrawlerLogsCountsOnlyWashed(t *testing.T) {
+	queue := make([]string, washBatchSize)
+	for i := range queue {
+		data, _ := json.Marshal(logging.CrawlerLog{Site: "site1", IP: fmt.Sprintf("8.8.%d.%d", i/250, i%250+1), Time: time.Now()})
+		queue[i] = string(data)
+	}
+
+	crawlerLogMgr := logging.NewCrawlerLogManager(startFailingUpdateRedis(t, queue))
+	defer crawlerLogMgr.Close()
+
+	service := &GeoIPService{
+		negativeTTL: time.Minute,
+		limiter:     newRateLimiter(1000, time.Millisecond),
+		providers: []func(string) (*GeoLocation, error){
+			func(ip string) (*GeoLocation, error) { return nil, errors.New("lookup failed") },
+		},
+	}
+	processor := &LogProcessor{crawlerLogMgr: crawlerLogMgr, geoIP: service, washConcurrency: 4}
+
+	if washed := processor.processCrawlerLogs(); washed != 0 {
+		t.Errorf("expected no washed logs when updates fail, got %d", washed)
+	}
+}
@@ -0,0 +1,70 @@
+package sitehandler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/monitoring"
+	"prerender-shield/internal/utils"
+)
+
+// defaultCrawlerVerifyTTL 未配置时爬虫验证结果的缓存时间
+const defaultCrawlerVerifyTTL = 24 * time.Hour
+
+// newCrawlerVerifier 创建站点的爬虫验证器，预渲染前的爬虫验证和防火墙的已验证爬虫绕过共用同一个验证器、
+// 爬虫列表和验证结果缓存；两者都未启用时返回nil
+func (h *Handler) newCrawlerVerifier(site config.SiteConfig) *firewall.CrawlerVerifier {
+	cfg := site.Prerender.CrawlerVerification
+	if !cfg.Enabled && !crawlerBypassEnabled(site) {
+		return nil
+	}
+	ttl := defaultCrawlerVerifyTTL
+	if cfg.CacheTTL > 0 {
+		ttl = time.Duration(cfg.CacheTTL) * time.Second
+	}
+	verifier := firewall.NewCrawlerVerifier(cfg.Crawlers, h.dnsResolver)
+	if h.redisClient != nil {
+		return verifier.WithRedisCache(h.redisClient.GetRawClient(), ttl)
+	}
+	return verifier.WithRedisCache(nil, ttl)
+}
+
+// crawlerBypassEnabled 判断站点是否启用了防火墙的已验证爬虫绕过
+func crawlerBypassEnabled(site config.SiteConfig) bool {
+	return site.Firewall.Enabled && site.Firewall.CrawlerBypass.Enabled
+}
+
+// crawlerSpoofed 判断声称是可信爬虫的请求是否未通过DNS验证，站点未启用爬虫验证时返回false
+func crawlerSpoofed(site config.SiteConfig, verifier *firewall.CrawlerVerifier, req *http.Request) bool {
+	if verifier == nil || !site.Prerender.CrawlerVerification.Enabled {
+		return false
+	}
+	claimed, verified := verifier.Verify(req)
+	return claimed && !verified
+}
+
+// handleSpoofedCrawler 按站点策略处理未通过验证的爬虫请求，返回true表示请求已处理，不再按爬虫预渲染。
+// spa策略按普通访客继续处理，block策略返回403，log策略只记录后仍按爬虫处理
+func handleSpoofedCrawler(c *gin.Context, site config.SiteConfig, monitor *monitoring.Monitor) bool {
+	policy := site.Prerender.CrawlerVerification.Policy
+	monitor.RecordSpoofedCrawlerRequest()
+	logger.Warn("Crawler verification failed for %q from %s on site %s, policy %s",
+		c.Request.UserAgent(), utils.ClientIP(c.Request), site.Name, policy)
+
+	switch policy {
+	case config.CrawlerVerifyPolicyLog:
+		return false
+	case config.CrawlerVerifyPolicyBlock:
+		monitor.RecordBlockedRequest(site.ID)
+		c.JSON(http.StatusForbidden, gin.H{"code": 403, "message": "Crawler verification failed"})
+		monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusForbidden, 0)
+		c.Abort()
+	default:
+		c.Next()
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package sitehandler
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/firewall/types"
+	"prerender-shield/internal/models"
+	"prerender-shield/internal/monitoring"
+	"prerender-shield/internal/utils"
+)
+
+// SetFirewallManager 设置防火墙引擎管理器，设置后启用防火墙的站点会对每个请求执行规则检测
+func (h *Handler) SetFirewallManager(manager *firewall.EngineManager) {
+	h.firewallManager = manager
+}
+
+// firewallMiddleware 使用站点的防火墙引擎检测请求，被拦截的请求由引擎的动作处理器返回拦截或挑战页面。
+// 引擎按站点名称查找，站点未加载引擎或检测出错时放行请求；预渲染引擎识别出的爬虫跳过JS挑战，
+// 启用了爬虫验证时未通过验证的爬虫不跳过（策略为log时仍跳过）；启用了已验证爬虫绕过时，
+// 通过站点爬虫验证器验证的请求标记为已验证爬虫，由引擎跳过规则检测
+func (h *Handler) firewallMiddleware(site config.SiteConfig, monitor *monitoring.Monitor, crawlerVerifier *firewall.CrawlerVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+		engine, exists := h.firewallManager.GetEngine(site.Name)
+		if !exists {
+			c.Next()
+			return
+		}
+
+		req := c.Request
+		if h.isCrawlerRequest(site.ID, req.UserAgent()) &&
+			(site.Prerender.CrawlerVerification.Policy == config.CrawlerVerifyPolicyLog || !crawlerSpoofed(site, crawlerVerifier, req)) {
+			req = firewall.WithChallengeBypass(req)
+		}
+		if crawlerVerifier != nil && crawlerBypassEnabled(site) && crawlerVerifier.IsVerifiedCrawler(req) {
+			req = firewall.WithVerifiedCrawler(req)
+		}
+		allowed, result := engine.HandleRequest(c.Writer, req)
+		if allowed {
+			// 只命中动作为log的规则时记录后放行
+			if result != nil && result.Action == firewall.ActionLog {
+				h.logFirewallAction(c, site, result)
+			}
+			c.Next()
+			return
+		}
+
+		if monitor != nil {
+			monitor.RecordBlockedRequest(site.ID)
+			monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), time.Since(startTime))
+		}
+		h.logFirewallAction(c, site, result)
+		c.Abort()
+	}
+}
+
+// logFirewallAction 将防火墙拦截、挑战或只记录的命中写入WAF日志
+func (h *Handler) logFirewallAction(c *gin.Context, site config.SiteConfig, result *firewall.CheckResult) {
+	log := firewallAccessLog(c, site, result)
+	logger.Warn("Firewall %s %s %s from %s on site %s: %s", log.Action, log.Method, log.RequestPath, log.IPAddress, site.Name, log.Reason)
+
+	if h.wafRepo == nil {
+		return
+	}
+	// 异步写入，避免阻塞响应
+	go func() {
+		if err := h.wafRepo.CreateAccessLog(&log); err != nil {
+			logger.Error("Failed to create firewall access log: %v", err)
+		}
+	}()
+}
+
+// firewallAccessLog 根据检测结果生成WAF日志，记录全部威胁，规则ID、威胁类型和原因取自第一个检测到的威胁
+func firewallAccessLog(c *gin.Context, site config.SiteConfig, result *firewall.CheckResult) models.AccessLog {
+	var threat types.Threat
+	action := firewall.ActionBlock
+	var threats []models.ThreatDetail
+	requestID := uuid.New().String()
+	if result != nil {
+		if result.IncidentID != "" {
+			requestID = result.IncidentID
+		}
+		if len(result.Threats) > 0 {
+			threat = result.Threats[0]
+		}
+		if result.Action != "" {
+			action = result.Action
+		}
+		for _, t := range result.Threats {
+			threats = append(threats, models.ThreatDetail{
+				Type:     t.Type,
+				SubType:  t.SubType,
+				RuleID:   t.RuleID,
+				Severity: t.Severity,
+				Message:  t.Message,
+			})
+		}
+	}
+	ruleID := threat.RuleID
+	if ruleID == "" {
+		ruleID = threat.Type
+	}
+
+	return models.AccessLog{
+		ID:          uuid.New().String(),
+		SiteID:      site.ID,
+		RequestID:   requestID,
+		IPAddress:   utils.ClientIP(c.Request),
+		Method:      c.Request.Method,
+		RequestPath: c.Request.URL.Path,
+		UserAgent:   c.Request.UserAgent(),
+		StatusCode:  c.Writer.Status(),
+		Action:      action,
+		RuleID:      ruleID,
+		Reason:      threat.Message,
+		CreatedAt:   time.Now(),
+		ThreatType:  threat.Type,
+		Threats:     threats,
+	}
+}
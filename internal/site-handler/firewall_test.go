@@ -0,0 +1,166 @@
+package sitehandler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/firewall/types"
+	"prerender-shield/internal/monitoring"
+	"prerender-shield/internal/utils"
+)
+
+// TestFirewallMiddleware 测试启用防火墙的站点由引擎拦截恶意请求并返回拦截信息，未启用的站点不检测
+func TestFirewallMiddleware(t *testing.T) {
+	testSite := config.SiteConfig{
+		ID:       "fw-site",
+		Name:     "fw-site",
+		Mode:     "redirect",
+		Redirect: config.RedirectConfig{StatusCode: 301, TargetURL: "https://target.example.com"},
+		Firewall: config.FirewallConfig{
+			Enabled:      true,
+			ActionConfig: config.ActionConfig{DefaultAction: "block", BlockMessage: "Blocked by test firewall"},
+		},
+	}
+
+	manager := firewall.NewEngineManager()
+	assert.NoError(t, manager.AddSite(testSite.Name, firewall.NewSiteConfig(testSite, t.TempDir(), nil)))
+
+	handler := NewHandler(nil, nil, nil, nil)
+	handler.SetFirewallManager(manager)
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	siteHandler := handler.CreateSiteHandler(testSite, nil, nil, monitor, t.TempDir())
+
+	attack := "http://example.com/search?q=%3Cscript%3Ealert(1)%3C%2Fscript%3E"
+	rec := httptest.NewRecorder()
+	siteHandler.ServeHTTP(rec, httptest.NewRequest("GET", attack, nil))
+	assert.Equal(t, 403, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Blocked by test firewall")
+
+	rec = httptest.NewRecorder()
+	siteHandler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/search?q=shoes", nil))
+	assert.Equal(t, 301, rec.Code)
+
+	// 站点关闭防火墙时不经过引擎检测
+	testSite.Firewall.Enabled = false
+	siteHandler = handler.CreateSiteHandler(testSite, nil, nil, monitor, t.TempDir())
+	rec = httptest.NewRecorder()
+	siteHandler.ServeHTTP(rec, httptest.NewRequest("GET", attack, nil))
+	assert.Equal(t, 301, rec.Code)
+}
+
+// TestFirewallVerifiedCrawlerBypass 测试防火墙的已验证爬虫绕过使用站点爬虫验证器和prerender.crawler_verification中的爬虫列表
+func TestFirewallVerifiedCrawlerBypass(t *testing.T) {
+	testSite := config.SiteConfig{
+		ID:       "fw-crawler-site",
+		Name:     "fw-crawler-site",
+		Mode:     "redirect",
+		Redirect: config.RedirectConfig{StatusCode: 301, TargetURL: "https://target.example.com"},
+		Firewall: config.FirewallConfig{
+			Enabled:       true,
+			ActionConfig:  config.ActionConfig{DefaultAction: "block"},
+			CrawlerBypass: config.CrawlerBypassConfig{Enabled: true},
+		},
+	}
+
+	manager := firewall.NewEngineManager()
+	defer manager.StopAll()
+	assert.NoError(t, manager.AddSite(testSite.Name, firewall.NewSiteConfig(testSite, t.TempDir(), nil)))
+
+	handler := NewHandler(nil, nil, nil, nil)
+	handler.SetFirewallManager(manager)
+	handler.dnsResolver = crawlerResolver{}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+
+	request := func(site config.SiteConfig, ip string) int {
+		req := httptest.NewRequest("GET", "http://example.com/search?q=1'+OR+1=1", nil)
+		req.RemoteAddr = ip + ":40000"
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+		rec := httptest.NewRecorder()
+		handler.CreateSiteHandler(site, nil, nil, monitor, t.TempDir()).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// 预渲染的爬虫验证未启用时，绕过仍使用内置爬虫列表验证，伪造的爬虫被规则拦截
+	assert.Equal(t, 301, request(testSite, "66.249.66.1"))
+	assert.Equal(t, 403, request(testSite, "203.0.113.5"))
+
+	// 爬虫列表只在prerender.crawler_verification中配置，不包含Googlebot时不再绕过
+	testSite.Prerender.CrawlerVerification.Crawlers = []config.VerifiedCrawler{{UserAgent: "Bingbot", Domains: []string{"search.msn.com"}}}
+	assert.Equal(t, 403, request(testSite, "66.249.66.1"))
+}
+
+// TestFirewallAccessLog 测试拦截日志记录站点、IP、路径、命中的规则和全部威胁详情
+func TestFirewallAccessLog(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://example.com/search?q=x", nil)
+	c.Request.RemoteAddr = "203.0.113.9:4321"
+
+	result := &firewall.CheckResult{
+		Action: firewall.ActionChallenge,
+		Threats: []types.Threat{
+			{Type: "xss", SubType: "custom", RuleID: "xss-custom-1", Severity: "high", Message: "Inline event handler"},
+			{Type: "rate_limit", Message: "Rate limit exceeded"},
+		},
+	}
+	log := firewallAccessLog(c, config.SiteConfig{ID: "fw-site", Name: "fw-site"}, result)
+
+	assert.Equal(t, "fw-site", log.SiteID)
+	assert.Equal(t, "203.0.113.9", log.IPAddress)
+	assert.Equal(t, "/search", log.RequestPath)
+	assert.Equal(t, firewall.ActionChallenge, log.Action)
+	assert.Equal(t, "xss-custom-1", log.RuleID)
+	assert.Equal(t, "xss", log.ThreatType)
+	assert.Equal(t, "Inline event handler", log.Reason)
+	assert.Len(t, log.Threats, 2)
+	assert.Equal(t, "rate_limit", log.Threats[1].Type)
+	assert.False(t, log.CreatedAt.IsZero())
+
+	// 没有检测结果时按拦截记录
+	log = firewallAccessLog(c, config.SiteConfig{ID: "fw-site"}, nil)
+	assert.Equal(t, firewall.ActionBlock, log.Action)
+	assert.Empty(t, log.Threats)
+}
+
+// TestFirewallTrustedProxyClientIP 测试防火墙只采用可信代理转发的X-Forwarded-For，其他客户端伪造的请求头不生效
+func TestFirewallTrustedProxyClientIP(t *testing.T) {
+	testSite := config.SiteConfig{
+		ID:       "fw-proxy-site",
+		Name:     "fw-proxy-site",
+		Mode:     "redirect",
+		Redirect: config.RedirectConfig{StatusCode: 301, TargetURL: "https://target.example.com"},
+		Firewall: config.FirewallConfig{
+			Enabled:      true,
+			Blacklist:    []string{"198.51.100.9"},
+			ActionConfig: config.ActionConfig{DefaultAction: "block"},
+		},
+	}
+
+	manager := firewall.NewEngineManager()
+	assert.NoError(t, manager.AddSite(testSite.Name, firewall.NewSiteConfig(testSite, t.TempDir(), nil)))
+
+	handler := NewHandler(nil, nil, nil, nil)
+	handler.SetFirewallManager(manager)
+	resolver, err := utils.NewClientIPResolver([]string{"10.0.0.0/8"})
+	assert.NoError(t, err)
+	handler.SetClientIPResolver(resolver)
+	siteHandler := handler.CreateSiteHandler(testSite, nil, nil, monitoring.NewMonitor(monitoring.Config{Enabled: false}), t.TempDir())
+
+	serve := func(peer, xff string) int {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = peer
+		req.Header.Set("X-Forwarded-For", xff)
+		rec := httptest.NewRecorder()
+		siteHandler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, 403, serve("10.0.0.1:1234", "198.51.100.9"), "blacklisted client behind a trusted proxy should be blocked")
+	assert.Equal(t, 301, serve("10.0.0.1:1234", "203.0.113.7"))
+	assert.Equal(t, 403, serve("198.51.100.9:1234", "203.0.113.7"), "untrusted peer cannot hide behind a spoofed header")
+	assert.Equal(t, 301, serve("203.0.113.5:1234", "198.51.100.9"), "spoofed header from an untrusted peer is ignored")
+}
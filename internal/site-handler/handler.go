@@ -1,18 +1,21 @@
 package sitehandler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"prerender-shield/internal/acme"
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/middleware"
 	"prerender-shield/internal/monitoring"
@@ -20,8 +23,12 @@ import (
 	"prerender-shield/internal/redis"
 	"prerender-shield/internal/repository"
 	"prerender-shield/internal/services"
+	"prerender-shield/internal/utils"
 )
 
+// logger 站点模块的日志记录器，日志级别可以单独设置
+var logger = logging.Module("site")
+
 // Handler 站点处理器，负责处理站点的HTTP请求
 // 管理站点的请求路由、爬虫检测和响应处理
 //
@@ -30,11 +37,37 @@ import (
 //   wafRepo: WAF仓库，用于记录WAF日志
 //   redisClient: Redis客户端，用于限流
 //   geoIP: GeoIP服务，用于地理位置访问控制
+//   acmeChallenges: ACME HTTP-01验证响应，用于自动签发站点证书
+//   firewallManager: 防火墙引擎管理器，用于对站点请求执行规则检测
+//   clientIPResolver: 客户端IP解析器，只采用可信代理转发的X-Forwarded-For
+//   upstreams: 代理模式站点的后端列表及健康检查
 type Handler struct {
 	prerenderManager *prerender.EngineManager
 	wafRepo          *repository.WafRepository
 	redisClient      *redis.Client
 	geoIP            services.GeoIPResolver
+	acmeChallenges   ACMEChallengeStore
+	firewallManager  *firewall.EngineManager
+	dnsResolver      firewall.DNSResolver // 爬虫验证使用的DNS解析器，为nil时使用系统DNS
+	clientIPResolver *utils.ClientIPResolver
+
+	upstreamsMutex sync.Mutex
+	upstreams      map[string]*upstreamPool // 代理模式站点的后端列表，按站点ID索引
+}
+
+// ACMEChallengeStore 提供ACME HTTP-01验证令牌对应的响应内容，由acme.Manager实现
+type ACMEChallengeStore interface {
+	GetChallenge(token string) (string, bool)
+}
+
+// SetACMEChallenges 设置ACME验证响应来源，设置后所有站点都会响应HTTP-01验证请求
+func (h *Handler) SetACMEChallenges(store ACMEChallengeStore) {
+	h.acmeChallenges = store
+}
+
+// SetClientIPResolver 设置客户端IP解析器，未设置时所有请求都使用TCP对端地址
+func (h *Handler) SetClientIPResolver(resolver *utils.ClientIPResolver) {
+	h.clientIPResolver = resolver
 }
 
 // NewHandler 创建站点处理器实例
@@ -59,9 +92,39 @@ func NewHandler(prerenderManager *prerender.EngineManager, wafRepo *repository.W
 		wafRepo:          wafRepo,
 		redisClient:      redisClient,
 		geoIP:            geoIP,
+		upstreams:        make(map[string]*upstreamPool),
 	}
 }
 
+// replaceUpstreams 保存站点新的后端列表并停止旧列表的健康检查，pool为nil时只移除旧列表
+func (h *Handler) replaceUpstreams(siteID string, pool *upstreamPool) {
+	h.upstreamsMutex.Lock()
+	defer h.upstreamsMutex.Unlock()
+	if old, exists := h.upstreams[siteID]; exists {
+		old.stop()
+		delete(h.upstreams, siteID)
+	}
+	if pool != nil {
+		h.upstreams[siteID] = pool
+	}
+}
+
+// RemoveSite 停止站点后端的健康检查，在站点停止或删除时调用
+func (h *Handler) RemoveSite(siteID string) {
+	h.replaceUpstreams(siteID, nil)
+}
+
+// UpstreamStatus 返回代理模式站点各后端的健康状态，非代理模式站点返回nil
+func (h *Handler) UpstreamStatus(siteID string) []UpstreamStatus {
+	h.upstreamsMutex.Lock()
+	pool, exists := h.upstreams[siteID]
+	h.upstreamsMutex.Unlock()
+	if !exists {
+		return nil
+	}
+	return pool.statuses()
+}
+
 // CreateSiteHandler 创建基于站点配置的HTTP处理器
 // 根据站点配置创建对应的HTTP处理器，支持proxy、static和redirect三种模式
 //
@@ -84,11 +147,41 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 	// 创建站点级别的Gin路由器
 	siteRouter := gin.Default()
 
+	// 在请求入口解析一次客户端IP，WAF、防火墙、频率限制、挑战令牌和日志都使用同一个地址
+	siteRouter.Use(func(c *gin.Context) {
+		c.Request = utils.WithClientIP(c.Request, h.clientIPResolver.Resolve(c.Request))
+		c.Next()
+	})
+
+	// ACME HTTP-01验证请求无论站点模式都由本服务响应
+	if h.acmeChallenges != nil {
+		siteRouter.Use(acmeChallengeMiddleware(h.acmeChallenges))
+	}
+
+	// 健康检查探测路径直接返回200，不经过WAF、爬虫检测和访问日志
+	if len(site.ProbePaths) > 0 {
+		siteRouter.Use(probeMiddleware(site.ProbePaths))
+	}
+
 	// WAF中间件 - 最先执行，保护后续处理
 	siteRouter.Use(middleware.WafMiddleware(site, h.wafRepo, h.redisClient, h.geoIP))
 
+	// 声称是搜索引擎爬虫的请求需通过DNS验证，未通过的请求不享受爬虫待遇；防火墙和爬虫检测共用站点的验证器
+	crawlerVerifier := h.newCrawlerVerifier(site)
+
+	// 防火墙引擎规则检测，在爬虫检测之前拦截恶意请求
+	if site.Firewall.Enabled && h.firewallManager != nil {
+		siteRouter.Use(h.firewallMiddleware(site, monitor, crawlerVerifier))
+	}
+
 	// 爬虫检测中间件 - 第一个执行，确保爬虫请求得到正确处理
 	siteRouter.Use(func(c *gin.Context) {
+		// WebSocket和SSE长连接请求不进行预渲染
+		if isStreamingRequest(c.Request) {
+			c.Next()
+			return
+		}
+
 		// 获取请求的User-Agent
 		userAgent := c.Request.UserAgent()
 
@@ -97,25 +190,19 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 
 		// 只有当prerenderManager不为nil时才使用引擎的检测方法
 		if h.prerenderManager != nil {
-			prerenderEngine, _ := h.prerenderManager.GetEngine(site.ID)
-			if prerenderEngine != nil {
-				isCrawler = prerenderEngine.IsCrawlerRequest(userAgent)
-			} else {
-				// 降级方案：使用默认的爬虫UA检测
-				lowerUA := strings.ToLower(userAgent)
-				isCrawler = strings.Contains(lowerUA, "baiduspider") ||
-					strings.Contains(lowerUA, "googlebot") ||
-					strings.Contains(lowerUA, "bingbot") ||
-					strings.Contains(lowerUA, "yandexbot") ||
-					strings.Contains(lowerUA, "sogou")
-			}
+			isCrawler = h.isCrawlerRequest(site.ID, userAgent)
+		}
+
+		// 伪造的爬虫按站点策略处理
+		if isCrawler && crawlerSpoofed(site, crawlerVerifier, c.Request) && handleSpoofedCrawler(c, site, monitor) {
+			return
 		}
 
 		if isCrawler {
 			// 如果prerenderManager为nil，无法处理爬虫请求，返回500错误
 			if h.prerenderManager == nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "Prerender engine not available"})
-				monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
 				c.Abort()
 				return
 			}
@@ -124,25 +211,16 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 			startTime := time.Now()
 
 			// 记录爬虫请求
-			monitor.RecordCrawlerRequest()
+			monitor.RecordCrawlerRequest(site.ID)
 
 			// 构建完整的URL
-			var scheme string
-			if c.Request.TLS != nil {
-				scheme = "https"
-			} else {
-				scheme = "http"
-			}
-			fullURL := fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, c.Request.URL.Path)
-			if c.Request.URL.RawQuery != "" {
-				fullURL += "?" + c.Request.URL.RawQuery
-			}
+			fullURL := requestURL(c.Request)
 
 			// 获取当前站点的渲染预热引擎实例
 			prerenderEngine, exists := h.prerenderManager.GetEngine(site.ID)
 			if !exists {
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "Prerender engine not found"})
-				monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
 				c.Abort()
 				return
 			}
@@ -151,44 +229,75 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 			resultWithCache, err := prerenderEngine.Render(c, fullURL, prerender.RenderOptions{
 				Timeout:   site.Prerender.Timeout,
 				WaitUntil: "networkidle0",
+				Crawler:   prerenderEngine.CrawlerFamily(userAgent),
 			})
+			// 渲染队列已满，立即按普通请求返回原始页面，不占用请求协程等待
+			if errors.Is(err, prerender.ErrRenderQueueFull) {
+				c.Next()
+				return
+			}
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "Prerender failed"})
-				monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
 				c.Abort()
 				return
 			}
 
 			result := resultWithCache.Result
+			// 页面不是HTML，不做预渲染，按普通请求返回源站原始内容
+			if result.Error == prerender.RenderErrorNotHTML {
+				c.Next()
+				return
+			}
 			if !result.Success {
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "Prerender result failed"})
-				monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, 0)
 				c.Abort()
 				return
 			}
 
+			// 页面声明了robots noindex，不返回预渲染结果，按普通请求处理
+			if result.NoIndex {
+				logger.Info("Page %s is marked noindex, serving plain response", fullURL)
+				c.Next()
+				return
+			}
+
 			// 计算渲染时间
 			renderTime := time.Since(startTime).Seconds()
+			if resultWithCache.HitCache {
+				monitor.RecordCacheHit(site.ID)
+			} else {
+				monitor.RecordCacheMiss(site.ID)
+			}
+
+			// 源站返回错误状态码时原样返回给爬虫，避免错误页面以200被收录
+			status := http.StatusOK
+			if result.StatusCode >= http.StatusBadRequest {
+				status = result.StatusCode
+			}
 
 			// 记录爬虫访问日志
 			crawlerLog := logging.CrawlerLog{
 				Site:       site.ID,
-				IP:         logging.GetClientIP(c.Request),
+				IP:         utils.ClientIP(c.Request),
 				Time:       time.Now(),
 				HitCache:   resultWithCache.HitCache, // 使用实际的缓存命中状态
 				Route:      c.Request.URL.Path,
 				UA:         userAgent,
-				Status:     http.StatusOK,
+				Status:     status,
 				Method:     c.Request.Method,
 				CacheTTL:   site.Prerender.CacheTTL,
 				RenderTime: float64(int(renderTime*100)) / 100, // 保留两位小数
+				// 记录实际缓存的规范化URL，便于排查缓存命中问题
+				NormalizedURL: resultWithCache.NormalizedURL,
 			}
 			crawlerLogManager.RecordCrawlerLog(crawlerLog)
 
 			// 返回渲染后的HTML响应
-			c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(result.HTML))
+			c.Data(status, "text/html; charset=utf-8", []byte(result.HTML))
 			// 记录请求
-			monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusOK, time.Duration(renderTime*float64(time.Second)))
+			monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, status, time.Duration(renderTime*float64(time.Second)))
 			// 终止请求处理，避免后续处理器覆盖我们的响应
 			c.Abort()
 			return
@@ -198,15 +307,38 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 		c.Next()
 	})
 
+	// 代理模式的反向代理在创建站点处理器时构建，所有请求共享后端连接池
+	var proxy, streamingProxy *httputil.ReverseProxy
+	var upstreams *upstreamPool
+	var proxyErr error
+	if site.Mode == "proxy" {
+		if upstreams, proxyErr = newUpstreamPool(site.Proxy); proxyErr != nil {
+			logger.Error("Invalid upstream URL for site %s: %v", site.ID, proxyErr)
+		} else {
+			proxy = newReverseProxy(site.Proxy, monitor)
+			streamingProxy = newStreamingProxy(proxy, monitor)
+			upstreams.start()
+		}
+	}
+	h.replaceUpstreams(site.ID, upstreams)
+
 	// 非爬虫请求处理中间件
 	siteRouter.Use(func(c *gin.Context) {
 		startTime := time.Now()
 
-		// 记录正常访问日志
+		// 按站点配置的范围记录正常访问日志
 		defer func() {
+			if !shouldRecordVisit(site.VisitLogScope, h.isCrawlerRequest(site.ID, c.Request.UserAgent())) {
+				return
+			}
+			// 垃圾来源按配置丢弃或标记
+			refererAction := site.RefererFilter.CheckReferer(c.Request.Referer(), site.Domains)
+			if refererAction == config.RefererActionDrop {
+				return
+			}
 			visitLog := logging.VisitLog{
 				Site:     site.ID,
-				IP:       logging.GetClientIP(c.Request),
+				IP:       utils.ClientIP(c.Request),
 				Time:     startTime,
 				Method:   c.Request.Method,
 				URL:      c.Request.URL.String(),
@@ -215,25 +347,47 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 				Duration: time.Since(startTime).Seconds(),
 				Referer:  c.Request.Referer(),
 				Washed:   false,
+				Filtered: refererAction == config.RefererActionFlag,
 			}
 			visitLogManager.RecordVisitLog(visitLog)
 		}()
 
+		// 站点开启向所有访客返回预渲染HTML时，优先返回渲染结果
+		if site.Prerender.ServeRenderedToAll && h.serveRenderedToVisitor(c, site, monitor, startTime) {
+			return
+		}
+
 		// 根据站点模式处理请求
 		switch site.Mode {
 		case "proxy":
 			// 代理已有应用模式：将请求转发到上游服务
-			proxyURL, err := url.Parse(site.Proxy.TargetURL)
-			if err != nil {
+			if proxyErr != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "message": "Invalid upstream URL"})
-				monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, time.Since(startTime))
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, time.Since(startTime))
+				c.Abort()
+				return
+			}
+
+			// 所有后端都不健康时立即返回502，不等待连接超时
+			target := upstreams.next()
+			if target == nil {
+				writeBadGateway(c.Writer)
+				monitor.RecordUpstreamError()
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusBadGateway, time.Since(startTime))
+				c.Abort()
+				return
+			}
+			c.Request = c.Request.WithContext(withUpstream(c.Request.Context(), target))
+
+			// 长连接在后端返回响应头时记录监控，耗时不包含连接的持续时间
+			if isStreamingRequest(c.Request) {
+				serveStreaming(streamingProxy, c, site.ID, monitor, startTime)
 				c.Abort()
 				return
 			}
 
-			proxy := httputil.NewSingleHostReverseProxy(proxyURL)
 			proxy.ServeHTTP(c.Writer, c.Request)
-			monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusOK, time.Since(startTime))
+			monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), time.Since(startTime))
 			c.Abort()
 			return
 
@@ -278,21 +432,36 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 			// 获取实际路径（移除hash部分）
 			actualPath := getActualPath(c.Request.URL.Path)
 
+			// 拒绝通过../访问静态目录之外的文件
+			if _, err := utils.SecurePath(siteStaticDir, actualPath); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"code":    400,
+					"message": "Invalid path",
+				})
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusBadRequest, time.Since(startTime))
+				c.Abort()
+				return
+			}
+
 			// 对于静态资源，尝试直接提供文件
 			if isStaticResource(actualPath) {
 				filePath := filepath.Join(siteStaticDir, actualPath)
-				if _, err := os.Stat(filePath); err == nil {
-					c.File(filePath)
-					monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusOK, time.Since(startTime))
+				if info, err := os.Stat(filePath); err == nil && !info.IsDir() {
+					if err := serveStaticFile(c.Writer, c.Request, filePath, site.Static); err != nil {
+						logger.Warn("Failed to serve static file %s: %v", filePath, err)
+					}
+					monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), time.Since(startTime))
 					return
 				}
 			}
 
 			// 对于非静态资源，返回index.html（SPA路由处理）
 			indexPath := filepath.Join(siteStaticDir, "index.html")
-			if _, err := os.Stat(indexPath); err == nil {
-				c.File(indexPath)
-				monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusOK, time.Since(startTime))
+			if info, err := os.Stat(indexPath); err == nil && !info.IsDir() {
+				if err := serveStaticFile(c.Writer, c.Request, indexPath, site.Static); err != nil {
+					logger.Warn("Failed to serve static file %s: %v", indexPath, err)
+				}
+				monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), time.Since(startTime))
 				return
 			}
 
@@ -307,14 +476,14 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 					"path":    c.Request.URL.Path,
 				},
 			})
-			monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusNotFound, time.Since(startTime))
+			monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusNotFound, time.Since(startTime))
 			c.Abort()
 			return
 
 		case "redirect":
 			// 重定向模式：返回重定向响应
 			c.Redirect(site.Redirect.StatusCode, site.Redirect.TargetURL)
-			monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, site.Redirect.StatusCode, time.Since(startTime))
+			monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, site.Redirect.StatusCode, time.Since(startTime))
 			c.Abort()
 			return
 
@@ -324,7 +493,7 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 				"code":    500,
 				"message": "Invalid site mode",
 			})
-			monitor.RecordRequest(c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, time.Since(startTime))
+			monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusInternalServerError, time.Since(startTime))
 			c.Abort()
 			return
 		}
@@ -333,3 +502,120 @@ func (h *Handler) CreateSiteHandler(site config.SiteConfig, crawlerLogManager *l
 	// 返回站点路由器作为HTTP处理器
 	return siteRouter
 }
+
+// visitorRenderGroup 普通访客渲染请求在公平调度中的分组，避免访客流量占满爬虫的渲染能力
+const visitorRenderGroup = "visitor"
+
+// serveRenderedToVisitor 向普通访客返回预渲染HTML，返回是否已响应
+// 渲染浏览器自身的请求、非HTML页面请求、重定向模式站点，以及渲染失败、noindex或源站报错的页面按站点模式正常处理
+func (h *Handler) serveRenderedToVisitor(c *gin.Context, site config.SiteConfig, monitor *monitoring.Monitor, startTime time.Time) bool {
+	if h.prerenderManager == nil || site.Mode == "redirect" || !wantsRenderedHTML(c.Request) {
+		return false
+	}
+	prerenderEngine, exists := h.prerenderManager.GetEngine(site.ID)
+	if !exists {
+		return false
+	}
+
+	resultWithCache, err := prerenderEngine.Render(c, requestURL(c.Request), prerender.RenderOptions{
+		Timeout:   site.Prerender.Timeout,
+		WaitUntil: "networkidle0",
+		Crawler:   visitorRenderGroup,
+	})
+	if err != nil {
+		return false
+	}
+	result := resultWithCache.Result
+	// 静态资源渲染结果为空，交给站点模式处理；源站错误页面也由站点模式返回原始状态码
+	if !result.Success || result.NoIndex || result.HTML == "" || result.StatusCode >= http.StatusBadRequest {
+		return false
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(result.HTML))
+	monitor.RecordRequest(site.ID, c.Request.Method, c.Request.URL.Path, http.StatusOK, time.Since(startTime))
+	c.Abort()
+	return true
+}
+
+// wantsRenderedHTML 判断请求是否为浏览器的页面导航请求，接口和资源请求不返回预渲染HTML
+// 渲染浏览器发起的请求带有prerender.RenderRequestHeader，始终返回原始页面，避免循环渲染
+func wantsRenderedHTML(r *http.Request) bool {
+	return r.Method == http.MethodGet &&
+		r.Header.Get(prerender.RenderRequestHeader) == "" &&
+		!isStreamingRequest(r) &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// requestURL 根据请求构建完整的页面URL
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	fullURL := fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)
+	if r.URL.RawQuery != "" {
+		fullURL += "?" + r.URL.RawQuery
+	}
+	return fullURL
+}
+
+// isCrawlerRequest 判断请求是否来自爬虫，优先使用站点引擎的爬虫协议头配置
+func (h *Handler) isCrawlerRequest(siteID, userAgent string) bool {
+	if h.prerenderManager != nil {
+		if prerenderEngine, _ := h.prerenderManager.GetEngine(siteID); prerenderEngine != nil {
+			return prerenderEngine.IsCrawlerRequest(userAgent)
+		}
+	}
+
+	// 降级方案：使用默认的爬虫UA检测
+	lowerUA := strings.ToLower(userAgent)
+	return strings.Contains(lowerUA, "baiduspider") ||
+		strings.Contains(lowerUA, "googlebot") ||
+		strings.Contains(lowerUA, "bingbot") ||
+		strings.Contains(lowerUA, "yandexbot") ||
+		strings.Contains(lowerUA, "sogou")
+}
+
+// acmeChallengeMiddleware 对正在进行中的ACME HTTP-01验证返回密钥授权，其他令牌交给站点正常处理
+func acmeChallengeMiddleware(store ACMEChallengeStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := strings.TrimPrefix(c.Request.URL.Path, acme.ChallengePathPrefix); token != c.Request.URL.Path {
+			if keyAuth, exists := store.GetChallenge(token); exists {
+				c.String(http.StatusOK, keyAuth)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// probeMiddleware 对配置的健康检查路径直接返回200
+func probeMiddleware(probePaths []string) gin.HandlerFunc {
+	paths := make(map[string]bool, len(probePaths))
+	for _, p := range probePaths {
+		paths[p] = true
+	}
+	return func(c *gin.Context) {
+		if paths[c.Request.URL.Path] {
+			c.String(http.StatusOK, "OK")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// shouldRecordVisit 根据站点的访问日志记录范围判断是否记录该请求
+func shouldRecordVisit(scope string, isCrawler bool) bool {
+	switch scope {
+	case config.VisitLogScopeNone:
+		return false
+	case config.VisitLogScopeHumans:
+		return !isCrawler
+	case config.VisitLogScopeCrawlers:
+		return isCrawler
+	default:
+		return true
+	}
+}
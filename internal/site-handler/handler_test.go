@@ -1,14 +1,21 @@
 package sitehandler
 
 import (
+	"context"
+	"errors"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
 
 	"prerender-shield/internal/config"
 	"prerender-shield/internal/logging"
 	"prerender-shield/internal/monitoring"
+	"prerender-shield/internal/prerender"
+	"prerender-shield/internal/redis"
 )
 
 func TestCreateSiteHandler_RedirectMode(t *testing.T) {
@@ -48,3 +55,216 @@ func TestCreateSiteHandler_RedirectMode(t *testing.T) {
 	assert.Equal(t, 301, rec.Code)
 	assert.Equal(t, "https://target.example.com", rec.Header().Get("Location"))
 }
+
+func TestVisitLogScope(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil)
+
+	requests := []struct {
+		name      string
+		userAgent string
+	}{
+		{"human", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36"},
+		{"crawler", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+	}
+
+	// 每种记录范围下应记录的请求
+	expected := map[string][]string{
+		"":                           {"human", "crawler"},
+		config.VisitLogScopeAll:      {"human", "crawler"},
+		config.VisitLogScopeHumans:   {"human"},
+		config.VisitLogScopeCrawlers: {"crawler"},
+		config.VisitLogScopeNone:     nil,
+	}
+
+	for scope, want := range expected {
+		var recorded []string
+		for _, r := range requests {
+			if shouldRecordVisit(scope, handler.isCrawlerRequest("test-site", r.userAgent)) {
+				recorded = append(recorded, r.name)
+			}
+		}
+		assert.Equal(t, want, recorded, "scope %q", scope)
+	}
+}
+
+// TestProbePaths 测试健康检查路径直接返回200，不经过WAF和访问日志记录
+func TestProbePaths(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil)
+
+	// 黑名单包含测试请求的IP，普通请求会被WAF拦截
+	testSite := config.SiteConfig{
+		ID:         "test-site",
+		Mode:       "redirect",
+		Redirect:   config.RedirectConfig{StatusCode: 301, TargetURL: "https://target.example.com"},
+		ProbePaths: []string{"/healthz"},
+		Firewall: config.FirewallConfig{
+			Enabled:   true,
+			Blacklist: []string{"192.0.2.1"},
+		},
+	}
+
+	// 访问日志管理器和监控为空，请求若经过访问日志记录会出错
+	siteHandler := handler.CreateSiteHandler(testSite, nil, nil, nil, "/tmp/static")
+
+	rec := httptest.NewRecorder()
+	siteHandler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/healthz", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "OK", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	siteHandler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/page", nil))
+	assert.Equal(t, 403, rec.Code)
+}
+
+// challengeStore 固定令牌的ACME验证响应
+type challengeStore map[string]string
+
+func (s challengeStore) GetChallenge(token string) (string, bool) {
+	keyAuth, exists := s[token]
+	return keyAuth, exists
+}
+
+// TestACMEChallenge 测试HTTP-01验证请求在WAF之前返回密钥授权，未知令牌按站点正常处理
+func TestACMEChallenge(t *testing.T) {
+	handler := NewHandler(nil, nil, nil, nil)
+	handler.SetACMEChallenges(challengeStore{"token1": "token1.thumbprint"})
+
+	testSite := config.SiteConfig{
+		ID:       "test-site",
+		Mode:     "redirect",
+		Redirect: config.RedirectConfig{StatusCode: 301, TargetURL: "https://target.example.com"},
+		Firewall: config.FirewallConfig{
+			Enabled:   true,
+			Blacklist: []string{"192.0.2.1"},
+		},
+	}
+	siteHandler := handler.CreateSiteHandler(testSite, nil, nil, nil, "/tmp/static")
+
+	rec := httptest.NewRecorder()
+	siteHandler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/.well-known/acme-challenge/token1", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "token1.thumbprint", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	siteHandler.ServeHTTP(rec, httptest.NewRequest("GET", "http://example.com/.well-known/acme-challenge/unknown", nil))
+	assert.Equal(t, 403, rec.Code)
+}
+
+// TestServeRenderedToAll 测试开启后普通访客也获得缓存的预渲染HTML，
+// 接口请求和渲染浏览器自身的请求仍按站点模式处理
+func TestServeRenderedToAll(t *testing.T) {
+	staticDir := t.TempDir()
+	os.MkdirAll(filepath.Join(staticDir, "test-site"), 0755)
+	os.WriteFile(filepath.Join(staticDir, "test-site", "index.html"), []byte("<html>app shell</html>"), 0644)
+
+	const renderedHTML = "<html><body>rendered</body></html>"
+	mr := miniredis.RunT(t)
+	mr.Set("prerender:test-site:content:http://example.com/page", renderedHTML)
+	redisClient, err := redis.NewClient(mr.Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	// 浏览器池为空，只从缓存返回渲染结果
+	prerenderManager := prerender.NewEngineManager(t.TempDir())
+	defer prerenderManager.StopAll()
+	if err := prerenderManager.AddSite("test-site", prerender.PrerenderConfig{PoolSize: 0}, redisClient); err != nil {
+		t.Fatalf("failed to add prerender engine: %v", err)
+	}
+
+	testSite := config.SiteConfig{
+		ID:            "test-site",
+		Mode:          "static",
+		VisitLogScope: config.VisitLogScopeNone,
+	}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	handler := NewHandler(prerenderManager, nil, nil, nil)
+
+	request := func(site config.SiteConfig, headers map[string]string) string {
+		req := httptest.NewRequest("GET", "http://example.com/page", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0 Safari/537.36")
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.CreateSiteHandler(site, nil, nil, monitor, staticDir).ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	// 默认只对爬虫返回预渲染HTML
+	assert.Equal(t, "<html>app shell</html>", request(testSite, nil))
+
+	testSite.Prerender.ServeRenderedToAll = true
+	assert.Equal(t, renderedHTML, request(testSite, nil))
+	assert.Equal(t, "<html>app shell</html>", request(testSite, map[string]string{"Accept": "application/json"}))
+	assert.Equal(t, "<html>app shell</html>", request(testSite, map[string]string{prerender.RenderRequestHeader: "1"}))
+}
+
+// crawlerResolver 模拟DNS解析，66.249.66.1反向解析为googlebot.com主机名并正向解析回同一IP
+type crawlerResolver struct{}
+
+func (crawlerResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if addr == "66.249.66.1" {
+		return []string{"crawl-66-249-66-1.googlebot.com."}, nil
+	}
+	return nil, errors.New("no PTR record")
+}
+
+func (crawlerResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if host == "crawl-66-249-66-1.googlebot.com" {
+		return []string{"66.249.66.1"}, nil
+	}
+	return nil, errors.New("no A record")
+}
+
+// TestCrawlerVerification 测试声称是Googlebot但未通过DNS验证的请求按策略处理
+func TestCrawlerVerification(t *testing.T) {
+	staticDir := t.TempDir()
+	os.MkdirAll(filepath.Join(staticDir, "test-site"), 0755)
+	os.WriteFile(filepath.Join(staticDir, "test-site", "index.html"), []byte("<html>app shell</html>"), 0644)
+
+	const renderedHTML = "<html><body>rendered</body></html>"
+	mr := miniredis.RunT(t)
+	mr.Set("prerender:test-site:content:http://example.com/page", renderedHTML)
+	redisClient, err := redis.NewClient(mr.Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	prerenderManager := prerender.NewEngineManager(t.TempDir())
+	defer prerenderManager.StopAll()
+	if err := prerenderManager.AddSite("test-site", prerender.PrerenderConfig{PoolSize: 0}, redisClient); err != nil {
+		t.Fatalf("failed to add prerender engine: %v", err)
+	}
+
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	handler := NewHandler(prerenderManager, nil, nil, nil)
+	handler.dnsResolver = crawlerResolver{}
+	crawlerLogManager := logging.NewCrawlerLogManager("")
+
+	request := func(policy, ip string) (int, string) {
+		site := config.SiteConfig{ID: "test-site", Mode: "static", VisitLogScope: config.VisitLogScopeNone}
+		site.Prerender.CrawlerVerification = config.CrawlerVerificationConfig{Enabled: true, Policy: policy}
+		req := httptest.NewRequest("GET", "http://example.com/page", nil)
+		req.RemoteAddr = ip + ":40000"
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+		rec := httptest.NewRecorder()
+		handler.CreateSiteHandler(site, crawlerLogManager, nil, monitor, staticDir).ServeHTTP(rec, req)
+		return rec.Code, rec.Body.String()
+	}
+
+	// 通过验证的爬虫获得预渲染结果
+	_, body := request(config.CrawlerVerifyPolicySPA, "66.249.66.1")
+	assert.Equal(t, renderedHTML, body)
+
+	// 伪造的爬虫按策略处理
+	_, body = request(config.CrawlerVerifyPolicySPA, "203.0.113.5")
+	assert.Equal(t, "<html>app shell</html>", body)
+	code, _ := request(config.CrawlerVerifyPolicyBlock, "203.0.113.5")
+	assert.Equal(t, 403, code)
+	_, body = request(config.CrawlerVerifyPolicyLog, "203.0.113.5")
+	assert.Equal(t, renderedHTML, body)
+}
@@ -0,0 +1,174 @@
+package sitehandler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/monitoring"
+)
+
+// newReverseProxy 根据站点代理配置创建反向代理，转发到请求上下文中选中的后端（见withUpstream）
+// 转发时追加X-Forwarded-For，客户端或前置代理未设置时补充X-Forwarded-Proto、X-Forwarded-Host和X-Real-IP；
+// 后端不可用时返回502 JSON响应并记录到监控
+func newReverseProxy(cfg config.ProxyConfig, monitor *monitoring.Monitor) *httputil.ReverseProxy {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Timeout > 0 {
+		transport.ResponseHeaderTimeout = time.Duration(cfg.Timeout) * time.Second
+	}
+
+	director := func(req *http.Request) {
+		target := upstreamFromContext(req.Context()).target
+		setForwardedHeaders(req)
+
+		stripPathPrefix(req.URL, cfg.StripPrefix)
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		req.URL.Path = joinURLPath(target.Path, req.URL.Path)
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = joinURLPath(target.EscapedPath(), req.URL.RawPath)
+		}
+		if target.RawQuery == "" || req.URL.RawQuery == "" {
+			req.URL.RawQuery = target.RawQuery + req.URL.RawQuery
+		} else {
+			req.URL.RawQuery = target.RawQuery + "&" + req.URL.RawQuery
+		}
+
+		if !cfg.PreserveHost {
+			req.Host = target.Host
+		}
+		for name, value := range cfg.Headers {
+			req.Header.Set(name, value)
+		}
+		// 与httputil.NewSingleHostReverseProxy一致，避免Go默认User-Agent
+		if _, ok := req.Header["User-Agent"]; !ok {
+			req.Header.Set("User-Agent", "")
+		}
+	}
+
+	return &httputil.ReverseProxy{
+		Director:  director,
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// 客户端断开不是后端故障
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			logger.Warn("Upstream %s request failed for %s: %v", r.URL.Host, r.URL.Path, err)
+			monitor.RecordUpstreamError()
+			writeBadGateway(w)
+		},
+	}
+}
+
+// writeBadGateway 返回后端不可用的502 JSON响应
+func writeBadGateway(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    http.StatusBadGateway,
+		"message": "Upstream unavailable",
+	})
+}
+
+// setForwardedHeaders 设置转发请求头，X-Forwarded-For由ReverseProxy在其后追加客户端地址
+func setForwardedHeaders(req *http.Request) {
+	if req.Header.Get("X-Forwarded-Proto") == "" {
+		proto := "http"
+		if req.TLS != nil {
+			proto = "https"
+		}
+		req.Header.Set("X-Forwarded-Proto", proto)
+	}
+	if req.Header.Get("X-Forwarded-Host") == "" {
+		req.Header.Set("X-Forwarded-Host", req.Host)
+	}
+	if req.Header.Get("X-Real-IP") == "" {
+		if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			req.Header.Set("X-Real-IP", ip)
+		}
+	}
+}
+
+// stripPathPrefix 从请求路径中移除前缀，移除后路径为空时使用"/"
+func stripPathPrefix(u *url.URL, prefix string) {
+	if prefix == "" || !strings.HasPrefix(u.Path, prefix) {
+		return
+	}
+	u.Path = ensureLeadingSlash(strings.TrimPrefix(u.Path, prefix))
+	if u.RawPath != "" {
+		u.RawPath = ensureLeadingSlash(strings.TrimPrefix(u.RawPath, prefix))
+	}
+}
+
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
+
+// joinURLPath 将请求路径拼接到后端地址的路径之后
+func joinURLPath(base, path string) string {
+	if base == "" || base == "/" {
+		return path
+	}
+	return strings.TrimSuffix(base, "/") + ensureLeadingSlash(path)
+}
+
+// isStreamingRequest 判断请求是否为WebSocket升级或SSE长连接请求
+func isStreamingRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// streamStart 长连接请求的开始信息，通过请求上下文传递给ModifyResponse
+type streamStart struct {
+	siteID   string
+	path     string
+	time     time.Time
+	recorded bool
+}
+
+// streamStartKey 请求上下文中长连接开始信息的键
+type streamStartKey struct{}
+
+// newStreamingProxy 基于站点反向代理创建长连接代理，每次写入后立即刷新响应，
+// 并在后端返回响应头时记录监控
+func newStreamingProxy(proxy *httputil.ReverseProxy, monitor *monitoring.Monitor) *httputil.ReverseProxy {
+	streaming := *proxy
+	streaming.FlushInterval = -1
+	streaming.ModifyResponse = func(resp *http.Response) error {
+		if start, ok := resp.Request.Context().Value(streamStartKey{}).(*streamStart); ok {
+			monitor.RecordRequest(start.siteID, resp.Request.Method, start.path, resp.StatusCode, time.Since(start.time))
+			start.recorded = true
+		}
+		return nil
+	}
+	return &streaming
+}
+
+// serveStreaming 通过长连接代理转发请求，后端不可用时按错误响应记录监控
+func serveStreaming(proxy *httputil.ReverseProxy, c *gin.Context, siteID string, monitor *monitoring.Monitor, startTime time.Time) {
+	// 客户端断开长连接时ReverseProxy以http.ErrAbortHandler中止，属于正常结束，不交给gin的Recovery记录
+	defer func() {
+		if err := recover(); err != nil && err != http.ErrAbortHandler {
+			panic(err)
+		}
+	}()
+
+	start := &streamStart{siteID: siteID, path: c.Request.URL.Path, time: startTime}
+	proxy.ServeHTTP(c.Writer, c.Request.WithContext(context.WithValue(c.Request.Context(), streamStartKey{}, start)))
+	if !start.recorded {
+		monitor.RecordRequest(siteID, c.Request.Method, start.path, c.Writer.Status(), time.Since(startTime))
+	}
+}
@@ -0,0 +1,196 @@
+package sitehandler
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/monitoring"
+	"prerender-shield/internal/prerender"
+)
+
+// TestProxyMode 测试代理模式转发Host、X-Forwarded-*请求头和自定义请求头，并移除路径前缀
+func TestProxyMode(t *testing.T) {
+	var received *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		io.WriteString(w, "upstream")
+	}))
+	defer upstream.Close()
+
+	site := config.SiteConfig{
+		ID:            "test-site",
+		Mode:          "proxy",
+		VisitLogScope: config.VisitLogScopeNone,
+		Proxy: config.ProxyConfig{
+			TargetURL:   upstream.URL + "/base",
+			Headers:     map[string]string{"X-Upstream-Token": "secret"},
+			StripPrefix: "/app",
+		},
+	}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+
+	request := func(site config.SiteConfig, path string, headers map[string]string) *http.Response {
+		server := httptest.NewServer(NewHandler(nil, nil, nil, nil).CreateSiteHandler(site, nil, nil, monitor, t.TempDir()))
+		defer server.Close()
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		req.Host = "www.example.com"
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := request(site, "/app/page?a=1", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/base/page", received.URL.Path)
+	assert.Equal(t, "a=1", received.URL.RawQuery)
+	assert.Equal(t, upstream.Listener.Addr().String(), received.Host)
+	assert.Equal(t, "127.0.0.1", received.Header.Get("X-Forwarded-For"))
+	assert.Equal(t, "127.0.0.1", received.Header.Get("X-Real-IP"))
+	assert.Equal(t, "http", received.Header.Get("X-Forwarded-Proto"))
+	assert.Equal(t, "www.example.com", received.Header.Get("X-Forwarded-Host"))
+	assert.Equal(t, "secret", received.Header.Get("X-Upstream-Token"))
+
+	// 前置代理已设置的转发头被保留，X-Forwarded-For追加当前客户端地址
+	site.Proxy.PreserveHost = true
+	request(site, "/other", map[string]string{
+		"X-Forwarded-For":   "203.0.113.7",
+		"X-Forwarded-Proto": "https",
+		"X-Real-IP":         "203.0.113.7",
+	})
+	assert.Equal(t, "/base/other", received.URL.Path)
+	assert.Equal(t, "www.example.com", received.Host)
+	assert.Equal(t, "203.0.113.7, 127.0.0.1", received.Header.Get("X-Forwarded-For"))
+	assert.Equal(t, "https", received.Header.Get("X-Forwarded-Proto"))
+	assert.Equal(t, "203.0.113.7", received.Header.Get("X-Real-IP"))
+}
+
+// TestProxyModeUpstreamError 测试后端不可用时返回502 JSON响应
+func TestProxyModeUpstreamError(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstreamURL := upstream.URL
+	upstream.Close()
+
+	site := config.SiteConfig{
+		ID:            "test-site",
+		Mode:          "proxy",
+		VisitLogScope: config.VisitLogScopeNone,
+		Proxy:         config.ProxyConfig{TargetURL: upstreamURL},
+	}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	before := monitor.GetStats()["upstreamErrors"].(float64)
+
+	server := httptest.NewServer(NewHandler(nil, nil, nil, nil).CreateSiteHandler(site, nil, nil, monitor, t.TempDir()))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/page")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, float64(http.StatusBadGateway), body["code"])
+	assert.Equal(t, before+1, monitor.GetStats()["upstreamErrors"].(float64))
+}
+
+// TestProxyModeWebSocket 测试代理模式转发WebSocket连接，爬虫的升级请求不进行预渲染
+func TestProxyModeWebSocket(t *testing.T) {
+	upstream := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		io.Copy(ws, ws)
+	}))
+	defer upstream.Close()
+
+	// 浏览器池为空，升级请求进入预渲染时会失败
+	prerenderManager := prerender.NewEngineManager(t.TempDir())
+	defer prerenderManager.StopAll()
+	if err := prerenderManager.AddSite("test-site", prerender.PrerenderConfig{PoolSize: 0}, nil); err != nil {
+		t.Fatalf("failed to add prerender engine: %v", err)
+	}
+
+	site := config.SiteConfig{
+		ID:            "test-site",
+		Mode:          "proxy",
+		VisitLogScope: config.VisitLogScopeNone,
+		Proxy:         config.ProxyConfig{TargetURL: upstream.URL},
+	}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	server := httptest.NewServer(NewHandler(prerenderManager, nil, nil, nil).CreateSiteHandler(site, nil, nil, monitor, t.TempDir()))
+	defer server.Close()
+
+	for _, userAgent := range []string{"Mozilla/5.0 Chrome/120.0.0.0", "Googlebot/2.1"} {
+		wsConfig, _ := websocket.NewConfig("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", "http://localhost/")
+		wsConfig.Header.Set("User-Agent", userAgent)
+		ws, err := websocket.DialConfig(wsConfig)
+		if err != nil {
+			t.Fatalf("websocket dial with %q failed: %v", userAgent, err)
+		}
+		for _, message := range []string{"hello", "world"} {
+			if err := websocket.Message.Send(ws, message); err != nil {
+				t.Fatalf("failed to send message: %v", err)
+			}
+			var reply string
+			if err := websocket.Message.Receive(ws, &reply); err != nil {
+				t.Fatalf("failed to receive message: %v", err)
+			}
+			assert.Equal(t, message, reply)
+		}
+		ws.Close()
+	}
+}
+
+// TestProxyModeSSE 测试代理模式立即转发SSE事件，不等待后端响应结束
+func TestProxyModeSSE(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "data: second\n\n")
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	site := config.SiteConfig{
+		ID:            "test-site",
+		Mode:          "proxy",
+		VisitLogScope: config.VisitLogScopeNone,
+		Proxy:         config.ProxyConfig{TargetURL: upstream.URL},
+	}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	server := httptest.NewServer(NewHandler(nil, nil, nil, nil).CreateSiteHandler(site, nil, nil, monitor, t.TempDir()))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 后端仍在保持连接时首个事件已经到达客户端
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read event: %v", err)
+	}
+	assert.Equal(t, "data: first\n", line)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+}
@@ -0,0 +1,234 @@
+package sitehandler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"prerender-shield/internal/config"
+)
+
+// 静态文件默认缓存策略
+const (
+	hashedAssetCacheControl   = "public, max-age=31536000, immutable"
+	htmlCacheControl          = "no-cache"
+	defaultStaticCacheControl = "public, max-age=3600"
+)
+
+// minCompressSize 小于该大小的文件不进行实时压缩
+const minCompressSize = 1024
+
+// precompressedEncodings 按优先级查找的预压缩文件后缀
+var precompressedEncodings = []struct {
+	encoding string
+	suffix   string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// serveStaticFile 提供静态文件，设置Cache-Control、ETag和Last-Modified并处理条件请求；
+// 客户端支持时优先使用预压缩的.br/.gz文件，否则对可压缩类型进行gzip实时压缩
+func serveStaticFile(w http.ResponseWriter, r *http.Request, filePath string, cfg config.StaticConfig) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", filePath)
+	}
+
+	name := filepath.Base(filePath)
+	header := w.Header()
+	header.Set("Cache-Control", staticCacheControl(name, cfg))
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" || !isCompressibleType(contentType) {
+		header.Set("ETag", staticETag(info, ""))
+		http.ServeContent(w, r, name, info.ModTime(), file)
+		return nil
+	}
+
+	// 可压缩类型的响应内容随Accept-Encoding变化
+	header.Add("Vary", "Accept-Encoding")
+	header.Set("Content-Type", contentType)
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+
+	for _, candidate := range precompressedEncodings {
+		if !acceptsEncoding(acceptEncoding, candidate.encoding) {
+			continue
+		}
+		compressed, compressedInfo := openPrecompressed(filePath+candidate.suffix, info.ModTime())
+		if compressed == nil {
+			continue
+		}
+		defer compressed.Close()
+		header.Set("Content-Encoding", candidate.encoding)
+		header.Set("ETag", staticETag(compressedInfo, candidate.encoding))
+		http.ServeContent(w, r, name, info.ModTime(), compressed)
+		return nil
+	}
+
+	if cfg.CompressionLevel < 0 || info.Size() < minCompressSize || !acceptsEncoding(acceptEncoding, "gzip") {
+		header.Set("ETag", staticETag(info, ""))
+		http.ServeContent(w, r, name, info.ModTime(), file)
+		return nil
+	}
+
+	// 实时压缩的内容长度未知，不支持Range请求
+	etag := "W/" + staticETag(info, "gzip")
+	header.Set("ETag", etag)
+	header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	if isNotModified(r, etag, info.ModTime()) {
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	header.Set("Content-Encoding", "gzip")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	level := cfg.CompressionLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(gz, file); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// openPrecompressed 打开预压缩文件，文件不存在或早于原文件（视为过期）时返回nil
+func openPrecompressed(path string, modTime time.Time) (*os.File, os.FileInfo) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+	info, err := file.Stat()
+	if err != nil || info.IsDir() || info.ModTime().Before(modTime) {
+		file.Close()
+		return nil, nil
+	}
+	return file, info
+}
+
+// staticCacheControl 返回文件的Cache-Control：优先使用按扩展名的配置，
+// 否则带内容哈希的文件名长期缓存，.html每次重新验证，其余文件缓存1小时
+func staticCacheControl(name string, cfg config.StaticConfig) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if value, ok := cfg.CacheControl[ext]; ok {
+		return value
+	}
+	switch {
+	case ext == ".html" || ext == ".htm":
+		return htmlCacheControl
+	case isHashedAssetName(name):
+		return hashedAssetCacheControl
+	}
+	return defaultStaticCacheControl
+}
+
+// isHashedAssetName 判断文件名是否包含构建工具生成的内容哈希，如 main.3f2a1b9c.js、index-D4fK9aQx.js
+func isHashedAssetName(name string) bool {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	separator := strings.LastIndexAny(base, ".-")
+	if separator < 0 {
+		return false
+	}
+	hash := base[separator+1:]
+	if len(hash) < 8 {
+		return false
+	}
+	var hasDigit, hasUpper, hasLower bool
+	for _, ch := range hash {
+		switch {
+		case ch >= '0' && ch <= '9':
+			hasDigit = true
+		case ch >= 'A' && ch <= 'Z':
+			hasUpper = true
+		case ch >= 'a' && ch <= 'z':
+			hasLower = true
+		case ch == '_':
+		default:
+			return false
+		}
+	}
+	// 普通单词通常不包含数字，也不会大小写混合
+	return hasDigit || (hasUpper && hasLower)
+}
+
+// isCompressibleType 判断内容类型是否适合压缩
+func isCompressibleType(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.Contains(mediaType, "javascript") ||
+		strings.Contains(mediaType, "json") ||
+		strings.Contains(mediaType, "xml") ||
+		mediaType == "application/wasm"
+}
+
+// acceptsEncoding 判断Accept-Encoding是否接受指定编码，q=0表示不接受
+func acceptsEncoding(acceptEncoding, encoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if quality, err := strconv.ParseFloat(value, 64); err == nil && quality == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// staticETag 根据文件大小和修改时间生成ETag，压缩内容附加编码名称
+func staticETag(info os.FileInfo, encoding string) string {
+	etag := fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+	if encoding != "" {
+		etag += "-" + encoding
+	}
+	return `"` + etag + `"`
+}
+
+// isNotModified 按If-None-Match（优先）或If-Modified-Since判断客户端缓存是否仍然有效
+func isNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,172 @@
+package sitehandler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/monitoring"
+)
+
+// TestStaticModeCompressionAndCaching 测试静态资源站模式的压缩、缓存头和条件请求
+func TestStaticModeCompressionAndCaching(t *testing.T) {
+	staticDir := t.TempDir()
+	siteDir := filepath.Join(staticDir, "test-site")
+	os.MkdirAll(filepath.Join(siteDir, "assets"), 0755)
+	script := strings.Repeat("console.log('prerender shield');\n", 100)
+	os.WriteFile(filepath.Join(siteDir, "assets", "index-D4fK9aQx.js"), []byte(script), 0644)
+	os.WriteFile(filepath.Join(siteDir, "assets", "app.css"), []byte(strings.Repeat("body{margin:0}\n", 100)), 0644)
+	os.WriteFile(filepath.Join(siteDir, "assets", "app.css.br"), []byte("brotli"), 0644)
+	os.WriteFile(filepath.Join(siteDir, "logo.png"), []byte("png"), 0644)
+	os.WriteFile(filepath.Join(siteDir, "index.html"), []byte("<html>app shell</html>"), 0644)
+
+	site := config.SiteConfig{
+		ID:            "test-site",
+		Mode:          "static",
+		VisitLogScope: config.VisitLogScopeNone,
+		Static: config.StaticConfig{
+			CacheControl: map[string]string{".png": "public, max-age=86400"},
+		},
+	}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	server := httptest.NewServer(NewHandler(nil, nil, nil, nil).CreateSiteHandler(site, nil, nil, monitor, staticDir))
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	get := func(path string, headers map[string]string) (*http.Response, string) {
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	// 可压缩类型实时gzip压缩，带哈希的文件名长期缓存
+	resp, body := get("/assets/index-D4fK9aQx.js", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, hashedAssetCacheControl, resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	assert.Contains(t, resp.Header.Get("Content-Type"), "javascript")
+	reader, err := gzip.NewReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("invalid gzip body: %v", err)
+	}
+	decompressed, _ := io.ReadAll(reader)
+	assert.Equal(t, script, string(decompressed))
+
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+	resp, body = get("/assets/index-D4fK9aQx.js", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Empty(t, body)
+
+	// 客户端不支持压缩时返回原始内容
+	resp, body = get("/assets/index-D4fK9aQx.js", nil)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, script, body)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	// 优先使用预压缩文件，q=0的编码不使用
+	resp, body = get("/assets/app.css", map[string]string{"Accept-Encoding": "gzip, br"})
+	assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "brotli", body)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/css")
+	assert.Equal(t, defaultStaticCacheControl, resp.Header.Get("Cache-Control"))
+	resp, _ = get("/assets/app.css", map[string]string{"Accept-Encoding": "gzip, br;q=0"})
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	// 按扩展名配置的Cache-Control，不可压缩类型不压缩
+	resp, body = get("/logo.png", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "png", body)
+	assert.Equal(t, "public, max-age=86400", resp.Header.Get("Cache-Control"))
+
+	// SPA路由返回index.html，每次重新验证
+	resp, body = get("/dashboard", nil)
+	assert.Equal(t, "<html>app shell</html>", body)
+	assert.Equal(t, htmlCacheControl, resp.Header.Get("Cache-Control"))
+	lastModified := resp.Header.Get("Last-Modified")
+	assert.NotEmpty(t, lastModified)
+	resp, _ = get("/dashboard", map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	resp, _ = get("/dashboard", map[string]string{"If-Modified-Since": time.Unix(0, 0).UTC().Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// 关闭实时压缩
+	site.Static.CompressionLevel = -1
+	disabled := httptest.NewServer(NewHandler(nil, nil, nil, nil).CreateSiteHandler(site, nil, nil, monitor, staticDir))
+	defer disabled.Close()
+	req, _ := http.NewRequest("GET", disabled.URL+"/assets/index-D4fK9aQx.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+}
+
+// TestIsHashedAssetName 测试识别带内容哈希的构建产物文件名
+func TestIsHashedAssetName(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"main.3f2a1b9c.js":           true,
+		"index-D4fK9aQx.js":          true,
+		"chunk-vendors.8d4e2f1a.css": true,
+		"app.js":                     false,
+		"jquery.min.js":              false,
+		"my-component.js":            false,
+		"index.html":                 false,
+	} {
+		assert.Equal(t, expected, isHashedAssetName(name), name)
+	}
+}
+
+// TestStaticModeRejectsPathTraversal 测试静态资源站模式拒绝访问静态目录之外的文件
+func TestStaticModeRejectsPathTraversal(t *testing.T) {
+	staticDir := t.TempDir()
+	os.MkdirAll(filepath.Join(staticDir, "test-site"), 0755)
+	os.WriteFile(filepath.Join(staticDir, "test-site", "index.html"), []byte("<html>app shell</html>"), 0644)
+	os.WriteFile(filepath.Join(staticDir, "secret.json"), []byte(`{"secret":true}`), 0644)
+
+	site := config.SiteConfig{ID: "test-site", Mode: "static", VisitLogScope: config.VisitLogScopeNone}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	server := httptest.NewServer(NewHandler(nil, nil, nil, nil).CreateSiteHandler(site, nil, nil, monitor, staticDir))
+	defer server.Close()
+
+	for _, path := range []string{"/../secret.json", "/assets/../../secret.json", "/%2e%2e/secret.json"} {
+		// 使用原始请求行，避免客户端清理路径
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n", path)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("failed to read response for %s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		conn.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+		assert.NotContains(t, string(body), "secret", path)
+	}
+}
@@ -0,0 +1,227 @@
+package sitehandler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"prerender-shield/internal/config"
+)
+
+// 后端健康检查
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	healthCheckTimeout         = 5 * time.Second
+)
+
+// UpstreamStatus 代理后端的健康状态
+type UpstreamStatus struct {
+	URL       string    `json:"url"`
+	Weight    int       `json:"weight"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"last_check"`
+	Error     string    `json:"error,omitempty"` // 最近一次健康检查失败的原因
+}
+
+// upstream 代理后端
+type upstream struct {
+	target        *url.URL
+	weight        int
+	healthPath    string
+	currentWeight int // 平滑加权轮询的当前权重
+	healthy       bool
+	lastCheck     time.Time
+	lastError     string
+}
+
+// upstreamPool 站点的代理后端列表，按权重平滑轮询健康的后端，并定期进行健康检查
+type upstreamPool struct {
+	mu        sync.Mutex
+	upstreams []*upstream
+	interval  time.Duration
+	client    *http.Client
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// newUpstreamPool 根据代理配置创建后端列表，后端在首次健康检查前视为健康
+func newUpstreamPool(cfg config.ProxyConfig) (*upstreamPool, error) {
+	pool := &upstreamPool{
+		interval: time.Duration(cfg.HealthCheckInterval) * time.Second,
+		client: &http.Client{
+			Timeout: healthCheckTimeout,
+			// 重定向响应同样视为健康
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		done: make(chan struct{}),
+	}
+	if pool.interval <= 0 {
+		pool.interval = defaultHealthCheckInterval
+	}
+	for _, upstreamCfg := range cfg.UpstreamList() {
+		target, err := url.Parse(upstreamCfg.URL)
+		if err != nil {
+			return nil, err
+		}
+		if target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("upstream URL %q must include scheme and host", upstreamCfg.URL)
+		}
+		weight := upstreamCfg.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		pool.upstreams = append(pool.upstreams, &upstream{
+			target:     target,
+			weight:     weight,
+			healthPath: upstreamCfg.HealthCheckPath,
+			healthy:    true,
+		})
+	}
+	if len(pool.upstreams) == 0 {
+		return nil, fmt.Errorf("no upstream configured")
+	}
+	return pool, nil
+}
+
+// next 按权重平滑轮询选择健康的后端，所有后端都不健康时返回nil
+func (p *upstreamPool) next() *upstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *upstream
+	total := 0
+	for _, u := range p.upstreams {
+		if !u.healthy {
+			continue
+		}
+		u.currentWeight += u.weight
+		total += u.weight
+		if best == nil || u.currentWeight > best.currentWeight {
+			best = u
+		}
+	}
+	if best != nil {
+		best.currentWeight -= total
+	}
+	return best
+}
+
+// start 启动后台健康检查，立即执行第一次检查
+func (p *upstreamPool) start() {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			p.checkAll()
+			select {
+			case <-ticker.C:
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop 停止健康检查，已停止的后端列表仍按最后的健康状态选择后端
+func (p *upstreamPool) stop() {
+	p.stopOnce.Do(func() { close(p.done) })
+}
+
+// checkAll 并发检查所有后端，健康状态变化时记录日志
+func (p *upstreamPool) checkAll() {
+	var wg sync.WaitGroup
+	for _, u := range p.upstreams {
+		wg.Add(1)
+		go func(u *upstream) {
+			defer wg.Done()
+			err := p.check(u)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			u.lastCheck = time.Now()
+			if err != nil {
+				if u.healthy {
+					logger.Warn("Upstream %s is down: %v", u.target.Host, err)
+				}
+				u.healthy = false
+				u.lastError = err.Error()
+				return
+			}
+			if !u.healthy {
+				logger.Info("Upstream %s is healthy again", u.target.Host)
+			}
+			u.healthy = true
+			u.lastError = ""
+		}(u)
+	}
+	wg.Wait()
+}
+
+// check 检查单个后端：配置了健康检查路径时请求该路径，否则只建立TCP连接
+func (p *upstreamPool) check(u *upstream) error {
+	if u.healthPath == "" {
+		conn, err := net.DialTimeout("tcp", upstreamAddr(u.target), healthCheckTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	checkURL := url.URL{Scheme: u.target.Scheme, Host: u.target.Host, Path: u.healthPath}
+	resp, err := p.client.Get(checkURL.String())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// upstreamAddr 返回后端的host:port，未指定端口时按协议使用默认端口
+func upstreamAddr(target *url.URL) string {
+	if target.Port() != "" {
+		return target.Host
+	}
+	port := "80"
+	if target.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(target.Hostname(), port)
+}
+
+// statuses 返回所有后端的健康状态
+func (p *upstreamPool) statuses() []UpstreamStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]UpstreamStatus, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		statuses = append(statuses, UpstreamStatus{
+			URL:       u.target.String(),
+			Weight:    u.weight,
+			Healthy:   u.healthy,
+			LastCheck: u.lastCheck,
+			Error:     u.lastError,
+		})
+	}
+	return statuses
+}
+
+// upstreamKey 请求上下文中本次转发选中的后端的键
+type upstreamKey struct{}
+
+// withUpstream 将选中的后端写入请求上下文，由反向代理的Director读取
+func withUpstream(ctx context.Context, u *upstream) context.Context {
+	return context.WithValue(ctx, upstreamKey{}, u)
+}
+
+func upstreamFromContext(ctx context.Context) *upstream {
+	u, _ := ctx.Value(upstreamKey{}).(*upstream)
+	return u
+}
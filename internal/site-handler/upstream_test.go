@@ -0,0 +1,156 @@
+package sitehandler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/monitoring"
+)
+
+// TestUpstreamPoolWeightedRoundRobin 测试按权重平滑轮询，跳过不健康的后端
+func TestUpstreamPoolWeightedRoundRobin(t *testing.T) {
+	pool, err := newUpstreamPool(config.ProxyConfig{Upstreams: []config.UpstreamConfig{
+		{URL: "http://a.internal", Weight: 2},
+		{URL: "http://b.internal"},
+	}})
+	if err != nil {
+		t.Fatalf("newUpstreamPool failed: %v", err)
+	}
+
+	var picks []string
+	for i := 0; i < 6; i++ {
+		picks = append(picks, pool.next().target.Host)
+	}
+	assert.Equal(t, []string{"a.internal", "b.internal", "a.internal", "a.internal", "b.internal", "a.internal"}, picks)
+
+	pool.upstreams[0].healthy = false
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "b.internal", pool.next().target.Host)
+	}
+
+	pool.upstreams[1].healthy = false
+	assert.Nil(t, pool.next())
+}
+
+// TestUpstreamPoolSingleTargetURL 测试只配置TargetURL的旧配置作为单个后端
+func TestUpstreamPoolSingleTargetURL(t *testing.T) {
+	pool, err := newUpstreamPool(config.ProxyConfig{TargetURL: "http://backend.internal:3000/base"})
+	if err != nil {
+		t.Fatalf("newUpstreamPool failed: %v", err)
+	}
+	statuses := pool.statuses()
+	if assert.Len(t, statuses, 1) {
+		assert.Equal(t, "http://backend.internal:3000/base", statuses[0].URL)
+		assert.Equal(t, 1, statuses[0].Weight)
+		assert.True(t, statuses[0].Healthy)
+	}
+
+	_, err = newUpstreamPool(config.ProxyConfig{Upstreams: []config.UpstreamConfig{{URL: "backend.internal"}}})
+	assert.Error(t, err)
+}
+
+// TestUpstreamHealthCheck 测试健康检查路径返回错误或无法连接时移出轮询，恢复后重新加入
+func TestUpstreamHealthCheck(t *testing.T) {
+	var healthStatus int32 = http.StatusServiceUnavailable
+	checked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(int(atomic.LoadInt32(&healthStatus)))
+		}
+	}))
+	defer checked.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	pool, err := newUpstreamPool(config.ProxyConfig{Upstreams: []config.UpstreamConfig{
+		{URL: checked.URL, HealthCheckPath: "/healthz"},
+		{URL: closed.URL},
+	}})
+	if err != nil {
+		t.Fatalf("newUpstreamPool failed: %v", err)
+	}
+
+	pool.checkAll()
+	statuses := pool.statuses()
+	assert.False(t, statuses[0].Healthy)
+	assert.Contains(t, statuses[0].Error, "503")
+	assert.False(t, statuses[1].Healthy)
+	assert.NotEmpty(t, statuses[1].Error)
+	assert.False(t, statuses[0].LastCheck.IsZero())
+	assert.Nil(t, pool.next())
+
+	atomic.StoreInt32(&healthStatus, http.StatusOK)
+	pool.checkAll()
+	statuses = pool.statuses()
+	assert.True(t, statuses[0].Healthy)
+	assert.Empty(t, statuses[0].Error)
+	assert.Equal(t, checked.Listener.Addr().String(), pool.next().target.Host)
+}
+
+// TestProxyModeUpstreams 测试代理模式在多个后端之间轮询，所有后端不健康时立即返回502
+func TestProxyModeUpstreams(t *testing.T) {
+	newBackend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		}))
+	}
+	backendA, backendB := newBackend("a"), newBackend("b")
+	defer backendA.Close()
+	defer backendB.Close()
+
+	site := config.SiteConfig{
+		ID:            "test-site",
+		Mode:          "proxy",
+		VisitLogScope: config.VisitLogScopeNone,
+		Proxy: config.ProxyConfig{Upstreams: []config.UpstreamConfig{
+			{URL: backendA.URL},
+			{URL: backendB.URL},
+		}},
+	}
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	handler := NewHandler(nil, nil, nil, nil)
+	server := httptest.NewServer(handler.CreateSiteHandler(site, nil, nil, monitor, t.TempDir()))
+	defer server.Close()
+	defer handler.RemoveSite(site.ID)
+
+	get := func() (int, string) {
+		resp, err := http.Get(server.URL + "/page")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	var bodies []string
+	for i := 0; i < 4; i++ {
+		_, body := get()
+		bodies = append(bodies, body)
+	}
+	assert.Equal(t, "a,b,a,b", strings.Join(bodies, ","))
+	assert.Len(t, handler.UpstreamStatus(site.ID), 2)
+
+	// 所有后端不可用，健康检查后不再尝试连接
+	backendA.Close()
+	backendB.Close()
+	handler.upstreams[site.ID].checkAll()
+	start := time.Now()
+	status, body := get()
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.Contains(t, body, "Upstream unavailable")
+	assert.Less(t, time.Since(start), time.Second)
+	for _, upstream := range handler.UpstreamStatus(site.ID) {
+		assert.False(t, upstream.Healthy)
+	}
+
+	handler.RemoveSite(site.ID)
+	assert.Nil(t, handler.UpstreamStatus(site.ID))
+}
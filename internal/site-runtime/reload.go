@@ -0,0 +1,73 @@
+package siteruntime
+
+import (
+	"sync"
+
+	"prerender-shield/internal/config"
+)
+
+// Reloader 配置文件变化时按站点差异重载运行中的站点
+// 新增的站点启动，删除的站点停止，配置变化的站点热更新服务器处理器和引擎，正在处理的请求和连接不受影响；
+// 服务器地址和目录等全局配置的变化需要重启进程才能生效
+type Reloader struct {
+	mutex   sync.Mutex
+	current *config.Config // 当前运行的配置，通过API修改站点时原地更新
+	runtime *Runtime
+}
+
+// NewReloader 创建站点重载器，current为启动时加载的配置
+func NewReloader(runtime *Runtime, current *config.Config) *Reloader {
+	return &Reloader{current: current, runtime: runtime}
+}
+
+// Reload 比较当前配置和新配置中的站点并只重载有变化的站点
+func (r *Reloader) Reload(newConfig *config.Config) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	diff := config.DiffSites(r.current.Sites, newConfig.Sites)
+	r.current = newConfig
+	if diff.Empty() {
+		logger.Info("Site configuration unchanged, no site reloaded")
+		return
+	}
+
+	for _, site := range diff.Removed {
+		if site.IsEnabled() {
+			r.runtime.Stop(site)
+		}
+		logger.Info("Site %s (ID: %s) removed from configuration, stopped", site.Name, site.ID)
+	}
+	for _, change := range diff.Changed {
+		switch {
+		case change.Old.IsEnabled() && change.New.IsEnabled():
+			// 热更新失败（如新端口无法绑定）时原服务器和引擎按旧配置继续运行
+			if err := r.runtime.Update(change.Old, change.New); err != nil {
+				logger.Error("Failed to apply configuration of site %s, previous configuration keeps running: %v", change.New.ID, err)
+				continue
+			}
+			logger.Info("Site %s (ID: %s) configuration changed, updated", change.New.Name, change.New.ID)
+		case change.New.IsEnabled():
+			r.start(change.New)
+			logger.Info("Site %s (ID: %s) enabled, started", change.New.Name, change.New.ID)
+		case change.Old.IsEnabled():
+			r.runtime.Stop(change.Old)
+			logger.Info("Site %s (ID: %s) disabled, stopped", change.New.Name, change.New.ID)
+		}
+	}
+	for _, site := range diff.Added {
+		if !site.IsEnabled() {
+			continue
+		}
+		r.start(site)
+		logger.Info("Site %s (ID: %s) added to configuration, started", site.Name, site.ID)
+	}
+	logger.Info("Sites reloaded: %d added, %d removed, %d changed", len(diff.Added), len(diff.Removed), len(diff.Changed))
+}
+
+// start 启动站点，端口绑定失败时站点标记为error并在后台重试
+func (r *Reloader) start(site config.SiteConfig) {
+	if err := r.runtime.Start(site); err != nil {
+		logger.Error("Failed to start server for site %s: %v", site.Name, err)
+	}
+}
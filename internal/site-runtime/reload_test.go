@@ -0,0 +1,86 @@
+package siteruntime
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/logging"
+	"prerender-shield/internal/monitoring"
+	sitehandler "prerender-shield/internal/site-handler"
+	siteserver "prerender-shield/internal/site-server"
+)
+
+// freePort 获取一个当前空闲的本地端口
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// TestReloadHotSwapsChangedSite 测试配置文件中端口未变的站点修改后热更新：同一个保持连接上的请求立即使用新配置，
+// 连接不被关闭；从配置中删除的站点停止服务
+func TestReloadHotSwapsChangedSite(t *testing.T) {
+	port := freePort(t)
+	site := config.SiteConfig{
+		ID:       "site1",
+		Name:     "site1",
+		Port:     port,
+		Mode:     "redirect",
+		Redirect: config.RedirectConfig{StatusCode: 301, TargetURL: "https://old.example.com"},
+	}
+
+	serverManager := siteserver.NewManager(nil)
+	defer serverManager.StopAllServers()
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	visitLogManager := logging.NewVisitLogManager(miniredis.RunT(t).Addr())
+	defer visitLogManager.Close()
+	runtime := New(serverManager, sitehandler.NewHandler(nil, nil, nil, nil), nil, nil, nil, nil, visitLogManager, monitor, "127.0.0.1", t.TempDir())
+	if err := runtime.Start(site); err != nil {
+		t.Fatalf("failed to start site: %v", err)
+	}
+	reloader := NewReloader(runtime, &config.Config{Sites: []config.SiteConfig{site}})
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("failed to connect to site: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	// location 在同一个连接上发送请求并返回重定向地址
+	location := func() string {
+		fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatalf("keep-alive connection broken: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.Header.Get("Location")
+	}
+
+	if got := location(); got != "https://old.example.com" {
+		t.Fatalf("unexpected redirect before reload: %q", got)
+	}
+
+	changed := site
+	changed.Redirect.TargetURL = "https://new.example.com"
+	reloader.Reload(&config.Config{Sites: []config.SiteConfig{changed}})
+	if got := location(); got != "https://new.example.com" {
+		t.Errorf("expected reloaded redirect on the same connection, got %q", got)
+	}
+
+	reloader.Reload(&config.Config{})
+	if _, exists := serverManager.GetSiteServer(site.ID); exists {
+		t.Error("removed site should be stopped")
+	}
+}
@@ -0,0 +1,126 @@
+package siteruntime
+
+import (
+	"reflect"
+
+	goredis "github.com/go-redis/redis/v8"
+
+	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/logging"
+	"prerender-shield/internal/monitoring"
+	"prerender-shield/internal/prerender"
+	"prerender-shield/internal/redis"
+	sitehandler "prerender-shield/internal/site-handler"
+	siteserver "prerender-shield/internal/site-server"
+)
+
+// logger 站点模块的日志记录器
+var logger = logging.Module("site")
+
+// Runtime 管理运行中站点的渲染预热引擎、防火墙引擎和站点服务器
+// 站点管理接口和配置文件热重载共用同一套启动、停止和热更新逻辑
+type Runtime struct {
+	siteServerMgr     *siteserver.Manager
+	siteHandler       *sitehandler.Handler
+	prerenderManager  *prerender.EngineManager // 为nil时不创建渲染预热引擎
+	firewallManager   *firewall.EngineManager  // 为nil时不创建防火墙引擎
+	redisClient       *redis.Client
+	crawlerLogManager *logging.CrawlerLogManager
+	visitLogManager   *logging.VisitLogManager
+	monitor           *monitoring.Monitor
+	serverAddress     string
+	staticDir         string
+}
+
+// New 创建站点运行时管理器，服务器地址和静态目录取自启动时的全局配置
+func New(
+	siteServerMgr *siteserver.Manager,
+	siteHandler *sitehandler.Handler,
+	prerenderManager *prerender.EngineManager,
+	firewallManager *firewall.EngineManager,
+	redisClient *redis.Client,
+	crawlerLogManager *logging.CrawlerLogManager,
+	visitLogManager *logging.VisitLogManager,
+	monitor *monitoring.Monitor,
+	serverAddress string,
+	staticDir string,
+) *Runtime {
+	return &Runtime{
+		siteServerMgr:     siteServerMgr,
+		siteHandler:       siteHandler,
+		prerenderManager:  prerenderManager,
+		firewallManager:   firewallManager,
+		redisClient:       redisClient,
+		crawlerLogManager: crawlerLogManager,
+		visitLogManager:   visitLogManager,
+		monitor:           monitor,
+		serverAddress:     serverAddress,
+		staticDir:         staticDir,
+	}
+}
+
+// Start 为站点创建渲染预热引擎和防火墙引擎并启动站点服务器
+// 端口绑定失败时站点标记为error并在后台重试，返回绑定错误
+func (r *Runtime) Start(site config.SiteConfig) error {
+	if r.prerenderManager != nil {
+		if err := r.prerenderManager.ReplaceSite(site.ID, prerender.NewSitePrerenderConfig(site), r.redisClient); err != nil {
+			logger.Error("Failed to start prerender engine for site %s: %v", site.ID, err)
+		}
+	}
+	if r.firewallManager != nil {
+		if err := r.firewallManager.ReplaceSite(site.Name, site.Name, firewall.NewSiteConfig(site, r.staticDir, r.rawRedisClient())); err != nil {
+			logger.Error("Failed to start firewall engine for site %s: %v", site.ID, err)
+		}
+	}
+
+	siteHandler := r.siteHandler.CreateSiteHandler(site, r.crawlerLogManager, r.visitLogManager, r.monitor, r.staticDir)
+	return r.siteServerMgr.StartSiteServer(site, r.serverAddress, r.staticDir, r.crawlerLogManager, siteHandler)
+}
+
+// Stop 停止站点服务器并移除站点的引擎
+func (r *Runtime) Stop(site config.SiteConfig) {
+	if err := r.siteServerMgr.StopSiteServer(site.ID); err != nil {
+		logger.Error("Failed to stop server for site %s: %v", site.ID, err)
+	}
+	r.siteHandler.RemoveSite(site.ID)
+	if r.prerenderManager != nil {
+		r.prerenderManager.RemoveSite(site.ID)
+	}
+	if r.firewallManager != nil {
+		r.firewallManager.RemoveSite(site.Name)
+	}
+}
+
+// Update 将站点配置变更应用到运行中的站点，不中断正在处理的请求
+// 先用新配置创建站点处理器并替换到站点服务器，端口变化时先确认新端口可以绑定；
+// 替换成功后重建配置发生变化的渲染预热引擎和防火墙引擎。替换失败时原服务器和引擎保持不变并返回错误
+func (r *Runtime) Update(oldSite, site config.SiteConfig) error {
+	siteHandler := r.siteHandler.CreateSiteHandler(site, r.crawlerLogManager, r.visitLogManager, r.monitor, r.staticDir)
+	if err := r.siteServerMgr.UpdateSiteServer(site, r.serverAddress, siteHandler); err != nil {
+		return err
+	}
+
+	prerenderConfig := prerender.NewSitePrerenderConfig(site)
+	if r.prerenderManager != nil && !reflect.DeepEqual(prerender.NewSitePrerenderConfig(oldSite), prerenderConfig) {
+		if err := r.prerenderManager.ReplaceSite(site.ID, prerenderConfig, r.redisClient); err != nil {
+			logger.Error("Failed to rebuild prerender engine for site %s: %v", site.ID, err)
+		}
+	}
+
+	if r.firewallManager != nil && (oldSite.Name != site.Name || !reflect.DeepEqual(oldSite.Firewall, site.Firewall)) {
+		firewallConfig := firewall.NewSiteConfig(site, r.staticDir, r.rawRedisClient())
+		if err := r.firewallManager.ReplaceSite(oldSite.Name, site.Name, firewallConfig); err != nil {
+			logger.Error("Failed to rebuild firewall engine for site %s: %v", site.ID, err)
+		}
+	}
+	return nil
+}
+
+// rawRedisClient 返回底层Redis客户端，未配置Redis时返回nil
+func (r *Runtime) rawRedisClient() *goredis.Client {
+	if r.redisClient == nil {
+		return nil
+	}
+	return r.redisClient.GetRawClient()
+}
@@ -2,9 +2,12 @@ package siteserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"prerender-shield/internal/config"
@@ -12,57 +15,344 @@ import (
 	"prerender-shield/internal/monitoring"
 )
 
+// logger 站点模块的日志记录器，日志级别可以单独设置
+var logger = logging.Module("site")
+
+// shutdownTimeout 切换端口后等待旧服务器处理完正在进行的请求的最长时间
+const shutdownTimeout = 30 * time.Second
+
+// 端口绑定失败后的默认重试策略
+const (
+	defaultMaxRetries     = 5
+	defaultRetryBaseDelay = 2 * time.Second
+	maxRetryDelay         = 5 * time.Minute
+)
+
+// 站点服务器运行状态
+const (
+	SiteStateRunning  = "running"
+	SiteStateError    = "error"
+	SiteStateDisabled = "disabled" // 站点已停用，服务器未启动
+)
+
+// SiteStatus 站点服务器运行状态
+type SiteStatus struct {
+	State     string    `json:"state"`
+	Error     string    `json:"error,omitempty"` // 启动失败原因
+	Attempts  int       `json:"attempts"`        // 启动失败后已自动重试的次数
+	Retrying  bool      `json:"retrying"`        // 是否还会自动重试
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // Manager 站点服务器管理器
 
 type Manager struct {
-	siteServers map[string]*http.Server
-	monitor     *monitoring.Monitor
+	mutex          sync.RWMutex
+	siteServers    map[string]*http.Server
+	listeners      map[*http.Server]net.Listener // 站点服务器对应的监听器，关闭服务器时确保端口立即释放
+	statuses       map[string]SiteStatus
+	retryCancels   map[string]chan struct{}
+	maxRetries     int
+	retryBaseDelay time.Duration
+	certsDir       string // 站点证书目录，证书文件的相对路径基于该目录
+	monitor        *monitoring.Monitor
 }
 
 // NewManager 创建站点服务器管理器实例
 func NewManager(monitor *monitoring.Monitor) *Manager {
 	return &Manager{
-		siteServers: make(map[string]*http.Server),
-		monitor:     monitor,
+		siteServers:    make(map[string]*http.Server),
+		listeners:      make(map[*http.Server]net.Listener),
+		statuses:       make(map[string]SiteStatus),
+		retryCancels:   make(map[string]chan struct{}),
+		maxRetries:     defaultMaxRetries,
+		retryBaseDelay: defaultRetryBaseDelay,
+		monitor:        monitor,
+	}
+}
+
+// SetRetryPolicy 设置端口绑定失败后的自动重试次数和首次重试间隔，非正数时保持默认值
+func (m *Manager) SetRetryPolicy(maxRetries int, baseDelay time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if maxRetries > 0 {
+		m.maxRetries = maxRetries
+	}
+	if baseDelay > 0 {
+		m.retryBaseDelay = baseDelay
+	}
+}
+
+// SetCertsDir 设置站点证书目录
+func (m *Manager) SetCertsDir(certsDir string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.certsDir = certsDir
+}
+
+// swappableHandler 可原子替换的HTTP处理器，站点配置变化时替换处理器而不重启服务器
+type swappableHandler struct {
+	handler atomic.Value // handlerHolder
+}
+
+// handlerHolder 包装http.Handler，保证atomic.Value中存储的类型一致
+type handlerHolder struct {
+	http.Handler
+}
+
+func newSwappableHandler(handler http.Handler) *swappableHandler {
+	s := &swappableHandler{}
+	s.Store(handler)
+	return s
+}
+
+// Store 替换处理器，已经开始处理的请求继续使用旧处理器
+func (s *swappableHandler) Store(handler http.Handler) {
+	s.handler.Store(handlerHolder{handler})
+}
+
+func (s *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if redirectToHTTPS(w, r) {
+		return
 	}
+	s.handler.Load().(handlerHolder).ServeHTTP(w, r)
 }
 
 // StartSiteServer 启动站点服务器
-func (m *Manager) StartSiteServer(site config.SiteConfig, serverAddress string, staticDir string, crawlerLogManager *logging.CrawlerLogManager, siteHandler http.Handler) {
-	// 启动站点服务器
+// 端口无法绑定时将站点标记为error并返回错误，随后在后台按指数退避自动重试，不影响其他站点
+func (m *Manager) StartSiteServer(site config.SiteConfig, serverAddress string, staticDir string, crawlerLogManager *logging.CrawlerLogManager, siteHandler http.Handler) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.cancelRetryLocked(site.ID)
+	err := m.startLocked(site, serverAddress, siteHandler)
+	if err != nil {
+		logger.Error("站点 %s(%s) 启动失败: %v", site.Name, site.ID, err)
+		m.statuses[site.ID] = SiteStatus{
+			State:     SiteStateError,
+			Error:     err.Error(),
+			Retrying:  m.maxRetries > 0,
+			UpdatedAt: time.Now(),
+		}
+		m.scheduleRetryLocked(site, serverAddress, siteHandler)
+	}
+	return err
+}
+
+// RestartSiteServer 重启站点服务器，用于排除端口冲突后手动重试
+func (m *Manager) RestartSiteServer(site config.SiteConfig, serverAddress string, siteHandler http.Handler) error {
+	if err := m.StopSiteServer(site.ID); err != nil {
+		return err
+	}
+	return m.StartSiteServer(site, serverAddress, "", nil, siteHandler)
+}
+
+// startLocked 绑定端口并启动站点服务器，调用方需持有写锁
+func (m *Manager) startLocked(site config.SiteConfig, serverAddress string, siteHandler http.Handler) error {
 	siteAddr := fmt.Sprintf("%s:%d", serverAddress, site.Port)
-	siteServer := &http.Server{
-		Addr:    siteAddr,
-		Handler: siteHandler,
+	tlsConfig, err := newTLSConfig(site, m.certsDir)
+	if err != nil {
+		return err
+	}
+	// 先绑定端口，确认可用后再启动服务
+	rawListener, err := net.Listen("tcp", siteAddr)
+	if err != nil {
+		return err
 	}
+	listener := newSiteListener(rawListener, tlsConfig)
 
 	// 保存站点服务器引用，用于后续管理，使用站点ID作为键
+	siteServer := m.serve(site, siteAddr, listener, newSwappableHandler(siteHandler))
 	m.siteServers[site.ID] = siteServer
+	m.listeners[siteServer] = listener
+	m.statuses[site.ID] = SiteStatus{State: SiteStateRunning, UpdatedAt: time.Now()}
+	return nil
+}
+
+// scheduleRetryLocked 在后台按指数退避重试启动站点服务器，调用方需持有写锁
+func (m *Manager) scheduleRetryLocked(site config.SiteConfig, serverAddress string, siteHandler http.Handler) {
+	if m.maxRetries <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	m.retryCancels[site.ID] = stop
+	maxRetries, baseDelay := m.maxRetries, m.retryBaseDelay
+
+	go func() {
+		for attempt := 1; attempt <= maxRetries; attempt++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(retryDelay(baseDelay, attempt)):
+			}
+
+			m.mutex.Lock()
+			// 等待期间站点已被停止、重启或更新
+			select {
+			case <-stop:
+				m.mutex.Unlock()
+				return
+			default:
+			}
+			err := m.startLocked(site, serverAddress, siteHandler)
+			if err == nil {
+				delete(m.retryCancels, site.ID)
+				m.mutex.Unlock()
+				logger.Info("站点 %s(%s) 第%d次重试启动成功", site.Name, site.ID, attempt)
+				return
+			}
+			m.statuses[site.ID] = SiteStatus{
+				State:     SiteStateError,
+				Error:     err.Error(),
+				Attempts:  attempt,
+				Retrying:  attempt < maxRetries,
+				UpdatedAt: time.Now(),
+			}
+			if attempt == maxRetries {
+				delete(m.retryCancels, site.ID)
+			}
+			m.mutex.Unlock()
+			logger.Error("站点 %s(%s) 第%d次重试启动失败: %v", site.Name, site.ID, attempt, err)
+		}
+	}()
+}
+
+// cancelRetryLocked 取消站点待执行的自动重试，调用方需持有写锁
+func (m *Manager) cancelRetryLocked(siteID string) {
+	if stop, exists := m.retryCancels[siteID]; exists {
+		close(stop)
+		delete(m.retryCancels, siteID)
+	}
+}
+
+// retryDelay 计算第attempt次重试前的等待时间
+func retryDelay(baseDelay time.Duration, attempt int) time.Duration {
+	delay := baseDelay << uint(attempt-1)
+	if delay <= 0 || delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// UpdateSiteServer 热更新站点服务器
+// 端口未变时原子替换现有服务器的处理器和TLS配置，不中断正在处理的请求；
+// 端口变化时先绑定新端口，成功后启动新服务器，再平滑关闭旧服务器。新端口无法绑定时保持原服务器不变并返回错误
+func (m *Manager) UpdateSiteServer(site config.SiteConfig, serverAddress string, siteHandler http.Handler) error {
+	siteAddr := fmt.Sprintf("%s:%d", serverAddress, site.Port)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	oldServer, exists := m.siteServers[site.ID]
+	if exists && oldServer.Addr == siteAddr {
+		handler, handlerOK := oldServer.Handler.(*swappableHandler)
+		listener, listenerOK := m.listeners[oldServer].(*siteListener)
+		if handlerOK && listenerOK {
+			tlsConfig, err := newTLSConfig(site, m.certsDir)
+			if err != nil {
+				return err
+			}
+			listener.SetTLSConfig(tlsConfig)
+			handler.Store(siteHandler)
+			logger.Info("站点 %s(%s) 配置已热更新", site.Name, site.ID)
+			return nil
+		}
+	}
+
+	if err := m.startLocked(site, serverAddress, siteHandler); err != nil {
+		return fmt.Errorf("failed to bind %s: %v", siteAddr, err)
+	}
+	m.cancelRetryLocked(site.ID)
+
+	// 旧服务器停止接收新连接，等待正在处理的请求完成
+	if exists {
+		oldListener := m.listeners[oldServer]
+		delete(m.listeners, oldServer)
+		go func() {
+			if err := shutdownServer(oldServer, oldListener, shutdownTimeout); err != nil {
+				logger.Error("关闭站点 %s 旧服务器失败: %v", site.ID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// ReloadTLS 重新加载运行中站点的证书，用于ACME证书签发或续期后生效，站点未运行时忽略
+func (m *Manager) ReloadTLS(site config.SiteConfig) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	// 启动站点服务器
-	go func(siteName, siteID, addr string, server *http.Server) {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("站点 %s(%s) 启动失败: %v", siteName, siteID, err)
+	server, exists := m.siteServers[site.ID]
+	if !exists {
+		return nil
+	}
+	listener, ok := m.listeners[server].(*siteListener)
+	if !ok {
+		return nil
+	}
+	tlsConfig, err := newTLSConfig(site, m.certsDir)
+	if err != nil {
+		return err
+	}
+	listener.SetTLSConfig(tlsConfig)
+	logger.Info("站点 %s(%s) 证书已重新加载", site.Name, site.ID)
+	return nil
+}
+
+// shutdownServer 关闭监听器并等待正在处理的请求完成
+// Serve协程可能尚未开始跟踪监听器，因此主动关闭监听器，保证返回后端口可以立即重新绑定
+func shutdownServer(server *http.Server, listener net.Listener, timeout time.Duration) error {
+	if listener != nil {
+		listener.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+// serve 在已绑定的监听器上启动站点服务器，siteAddr为配置中的监听地址，用于判断端口是否变化
+func (m *Manager) serve(site config.SiteConfig, siteAddr string, listener net.Listener, handler http.Handler) *http.Server {
+	siteServer := &http.Server{
+		Addr:        siteAddr,
+		Handler:     handler,
+		ConnContext: connContext,
+	}
+
+	go func(siteName, siteID string, server *http.Server) {
+		// 关闭服务器时会先关闭监听器，此时Serve可能返回net.ErrClosed
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+			logger.Error("站点 %s(%s) 运行失败: %v", siteName, siteID, err)
 		}
-	}(site.Name, site.ID, siteAddr, siteServer)
+	}(site.Name, site.ID, siteServer)
 
-	log.Printf("站点 %s(%s) 启动在 %s，模式: %s", site.Name, site.ID, siteAddr, site.Mode)
+	logger.Info("站点 %s(%s) 启动在 %s，模式: %s，HTTPS: %v", site.Name, site.ID, listener.Addr().String(), site.Mode, site.TLS.Enabled)
+	return siteServer
 }
 
-// StopSiteServer 停止站点服务器
+// StopSiteServer 停止站点服务器，同时取消待执行的自动重试
 func (m *Manager) StopSiteServer(siteID string) error {
+	m.mutex.Lock()
+	m.cancelRetryLocked(siteID)
+	delete(m.statuses, siteID)
+	server, exists := m.siteServers[siteID]
+	listener := m.listeners[server]
+	m.mutex.Unlock()
+
 	// 检查站点服务器是否存在
-	if server, exists := m.siteServers[siteID]; exists {
+	if exists {
 		// 关闭服务器
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := server.Shutdown(ctx); err != nil {
-			log.Printf("关闭站点 %s 失败: %v", siteID, err)
+		if err := shutdownServer(server, listener, 5*time.Second); err != nil {
+			logger.Error("关闭站点 %s 失败: %v", siteID, err)
 			return err
 		} else {
-			log.Printf("关闭站点 %s 成功", siteID)
+			logger.Info("关闭站点 %s 成功", siteID)
 			// 从映射中删除服务器
-			delete(m.siteServers, siteID)
+			m.mutex.Lock()
+			if m.siteServers[siteID] == server {
+				delete(m.siteServers, siteID)
+			}
+			delete(m.listeners, server)
+			m.mutex.Unlock()
 			return nil
 		}
 	}
@@ -71,20 +361,51 @@ func (m *Manager) StopSiteServer(siteID string) error {
 
 // GetSiteServer 获取站点服务器实例
 func (m *Manager) GetSiteServer(siteID string) (*http.Server, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 	server, exists := m.siteServers[siteID]
 	return server, exists
 }
 
+// GetSiteStatus 获取站点服务器运行状态
+func (m *Manager) GetSiteStatus(siteID string) (SiteStatus, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	status, exists := m.statuses[siteID]
+	return status, exists
+}
+
+// ListSiteStatuses 列出所有站点服务器的运行状态
+func (m *Manager) ListSiteStatuses() map[string]SiteStatus {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	statuses := make(map[string]SiteStatus, len(m.statuses))
+	for id, status := range m.statuses {
+		statuses[id] = status
+	}
+	return statuses
+}
+
 // ListSiteServers 列出所有站点服务器
 func (m *Manager) ListSiteServers() map[string]*http.Server {
-	return m.siteServers
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	servers := make(map[string]*http.Server, len(m.siteServers))
+	for id, server := range m.siteServers {
+		servers[id] = server
+	}
+	return servers
 }
 
-// StopAllServers 停止所有站点服务器
+// StopAllServers 停止所有站点服务器，包括仍在等待自动重试的站点
 func (m *Manager) StopAllServers() {
-	for siteName := range m.siteServers {
+	siteIDs := m.ListSiteStatuses()
+	for siteID := range m.ListSiteServers() {
+		siteIDs[siteID] = SiteStatus{}
+	}
+	for siteName := range siteIDs {
 		if err := m.StopSiteServer(siteName); err != nil {
-			log.Printf("停止站点 %s 失败: %v", siteName, err)
+			logger.Error("停止站点 %s 失败: %v", siteName, err)
 		}
 	}
 }
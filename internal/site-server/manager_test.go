@@ -1,8 +1,14 @@
 package siteserver
 
 import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"testing"
+	"time"
 
+	"prerender-shield/internal/config"
 	"prerender-shield/internal/monitoring"
 )
 
@@ -89,3 +95,155 @@ func TestGetSiteServer(t *testing.T) {
 		t.Error("Expected nil server for non-existent site")
 	}
 }
+
+// freePort 获取一个当前空闲的本地端口
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// textHandler 返回固定内容的处理器
+func textHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	})
+}
+
+// get 请求站点服务器并返回响应内容
+func get(t *testing.T, port int) string {
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/", port))
+	if err != nil {
+		t.Fatalf("request to port %d failed: %v", port, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// TestUpdateSiteServer 测试热更新站点服务器：端口不变时替换处理器，端口变化时切换到新端口，新端口被占用时保持原服务器
+func TestUpdateSiteServer(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.StopAllServers()
+
+	site := config.SiteConfig{ID: "site1", Name: "Site 1", Port: freePort(t)}
+	if err := manager.StartSiteServer(site, "127.0.0.1", "", nil, textHandler("v1")); err != nil {
+		t.Fatalf("StartSiteServer failed: %v", err)
+	}
+	original, _ := manager.GetSiteServer(site.ID)
+	if got := get(t, site.Port); got != "v1" {
+		t.Errorf("expected v1, got %s", got)
+	}
+
+	// 端口不变，原服务器继续运行并使用新处理器
+	if err := manager.UpdateSiteServer(site, "127.0.0.1", textHandler("v2")); err != nil {
+		t.Fatalf("UpdateSiteServer failed: %v", err)
+	}
+	if server, _ := manager.GetSiteServer(site.ID); server != original {
+		t.Error("expected the running server to be kept when the port is unchanged")
+	}
+	if got := get(t, site.Port); got != "v2" {
+		t.Errorf("expected v2, got %s", got)
+	}
+
+	// 新端口被占用，保持原服务器不变
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to occupy port: %v", err)
+	}
+	defer occupied.Close()
+	busySite := site
+	busySite.Port = occupied.Addr().(*net.TCPAddr).Port
+	if err := manager.UpdateSiteServer(busySite, "127.0.0.1", textHandler("v3")); err == nil {
+		t.Error("expected an error when the new port is in use")
+	}
+	if got := get(t, site.Port); got != "v2" {
+		t.Errorf("expected the old server to keep serving v2, got %s", got)
+	}
+
+	// 端口变化，切换到新端口的服务器
+	movedSite := site
+	movedSite.Port = freePort(t)
+	if err := manager.UpdateSiteServer(movedSite, "127.0.0.1", textHandler("v4")); err != nil {
+		t.Fatalf("UpdateSiteServer failed: %v", err)
+	}
+	if got := get(t, movedSite.Port); got != "v4" {
+		t.Errorf("expected v4, got %s", got)
+	}
+	if server, _ := manager.GetSiteServer(site.ID); server.Addr != fmt.Sprintf("127.0.0.1:%d", movedSite.Port) {
+		t.Errorf("expected server address to be updated, got %s", server.Addr)
+	}
+}
+
+// TestStartSiteServerRetry 测试端口被占用时站点标记为error并自动重试，端口释放后恢复运行
+func TestStartSiteServerRetry(t *testing.T) {
+	manager := NewManager(nil)
+	manager.SetRetryPolicy(3, 20*time.Millisecond)
+	defer manager.StopAllServers()
+
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to occupy port: %v", err)
+	}
+	site := config.SiteConfig{ID: "site1", Name: "Site 1", Port: occupied.Addr().(*net.TCPAddr).Port}
+
+	if err := manager.StartSiteServer(site, "127.0.0.1", "", nil, textHandler("ok")); err == nil {
+		t.Fatal("expected an error when the port is in use")
+	}
+	status, _ := manager.GetSiteStatus(site.ID)
+	if status.State != SiteStateError || status.Error == "" || !status.Retrying {
+		t.Errorf("expected error state with retry pending, got %+v", status)
+	}
+
+	// 释放端口后等待自动重试成功
+	occupied.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, _ = manager.GetSiteStatus(site.ID); status.State == SiteStateRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.State != SiteStateRunning {
+		t.Fatalf("expected site to recover after the port is freed, got %+v", status)
+	}
+	if got := get(t, site.Port); got != "ok" {
+		t.Errorf("expected ok, got %s", got)
+	}
+}
+
+// TestRestartSiteServer 测试重试次数用尽后可以手动重启站点
+func TestRestartSiteServer(t *testing.T) {
+	manager := NewManager(nil)
+	manager.SetRetryPolicy(1, time.Millisecond)
+	defer manager.StopAllServers()
+
+	occupied, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to occupy port: %v", err)
+	}
+	site := config.SiteConfig{ID: "site1", Name: "Site 1", Port: occupied.Addr().(*net.TCPAddr).Port}
+	manager.StartSiteServer(site, "127.0.0.1", "", nil, textHandler("ok"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, _ := manager.GetSiteStatus(site.ID); !status.Retrying {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status, _ := manager.GetSiteStatus(site.ID); status.State != SiteStateError || status.Retrying || status.Attempts != 1 {
+		t.Fatalf("expected retries to be exhausted, got %+v", status)
+	}
+
+	occupied.Close()
+	if err := manager.RestartSiteServer(site, "127.0.0.1", textHandler("ok")); err != nil {
+		t.Fatalf("RestartSiteServer failed: %v", err)
+	}
+	if status, _ := manager.GetSiteStatus(site.ID); status.State != SiteStateRunning {
+		t.Errorf("expected running after restart, got %+v", status)
+	}
+}
@@ -0,0 +1,295 @@
+package siteserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"prerender-shield/internal/acme"
+	"prerender-shield/internal/config"
+)
+
+// CertExpiryWarning 证书剩余有效期少于该时间时标记为即将过期
+const CertExpiryWarning = 14 * 24 * time.Hour
+
+// CertificateInfo 站点证书信息
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	DNSNames     []string  `json:"dns_names"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	ExpiringSoon bool      `json:"expiring_soon"`   // 证书已过期或将在14天内过期
+	Error        string    `json:"error,omitempty"` // 证书无法加载的原因
+}
+
+// ReadCertificatePEM 读取站点证书和私钥的PEM内容，优先使用内联PEM，相对路径基于certsDir
+// 启用ACME的站点只读取自动签发的证书文件
+func ReadCertificatePEM(site config.SiteConfig, certsDir string) (certPEM, keyPEM []byte, err error) {
+	certFile, keyFile := site.CertificateFiles()
+	inlineCert, inlineKey := site.TLS.CertPEM, site.TLS.KeyPEM
+	if site.TLS.ACME {
+		inlineCert, inlineKey = "", ""
+	}
+	certPEM, err = readPEM(inlineCert, certFile, certsDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate: %v", err)
+	}
+	keyPEM, err = readPEM(inlineKey, keyFile, certsDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key: %v", err)
+	}
+	return certPEM, keyPEM, nil
+}
+
+// readPEM 返回内联PEM内容，未设置时读取文件
+func readPEM(inline, file, certsDir string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file == "" {
+		return nil, fmt.Errorf("not configured")
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(certsDir, file)
+	}
+	return os.ReadFile(file)
+}
+
+// ValidateCertificate 校验私钥与证书匹配、证书未过期且覆盖站点的所有域名，返回证书信息
+// 本机地址（127.0.0.1、localhost）不要求出现在证书中
+func ValidateCertificate(certPEM, keyPEM []byte, domains []string) (*CertificateInfo, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("certificate and private key do not match: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+
+	info := newCertificateInfo(leaf)
+	if time.Now().After(leaf.NotAfter) {
+		return info, fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+	for _, domain := range domains {
+		if config.IsLocalDomain(domain) {
+			continue
+		}
+		if !certificateCovers(leaf, domain) {
+			return info, fmt.Errorf("certificate does not cover domain %s", domain)
+		}
+	}
+	return info, nil
+}
+
+// certificateCovers 判断证书是否覆盖域名，*.example.com 形式的站点域名要求证书包含对应的通配符名称
+func certificateCovers(cert *x509.Certificate, domain string) bool {
+	if strings.HasPrefix(domain, "*.") {
+		for _, name := range cert.DNSNames {
+			if strings.EqualFold(name, domain) {
+				return true
+			}
+		}
+		return false
+	}
+	return cert.VerifyHostname(domain) == nil
+}
+
+// InspectCertificate 读取并解析站点证书，站点未启用HTTPS或ACME证书尚未签发时返回nil
+// 证书无法读取或解析时返回带有Error的信息
+func InspectCertificate(site config.SiteConfig, certsDir string) *CertificateInfo {
+	if !tlsConfigured(site, certsDir) {
+		return nil
+	}
+	certPEM, keyPEM, err := ReadCertificatePEM(site, certsDir)
+	if err != nil {
+		return &CertificateInfo{Error: err.Error()}
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return &CertificateInfo{Error: err.Error()}
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return &CertificateInfo{Error: err.Error()}
+	}
+	return newCertificateInfo(leaf)
+}
+
+func newCertificateInfo(cert *x509.Certificate) *CertificateInfo {
+	return &CertificateInfo{
+		Subject:      cert.Subject.CommonName,
+		Issuer:       cert.Issuer.CommonName,
+		DNSNames:     cert.DNSNames,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		ExpiringSoon: time.Until(cert.NotAfter) < CertExpiryWarning,
+	}
+}
+
+// tlsConfigured 判断站点是否使用HTTPS：手动配置了证书，或ACME证书已经签发
+func tlsConfigured(site config.SiteConfig, certsDir string) bool {
+	if site.TLS.ACME {
+		certFile, _ := site.CertificateFiles()
+		_, err := os.Stat(filepath.Join(certsDir, certFile))
+		return err == nil
+	}
+	return site.TLS.Enabled
+}
+
+// newTLSConfig 根据站点HTTPS配置加载证书，站点未启用HTTPS时返回nil
+// 启用ACME的站点在证书签发前使用HTTP，以便完成HTTP-01验证
+func newTLSConfig(site config.SiteConfig, certsDir string) (*tls.Config, error) {
+	if !tlsConfigured(site, certsDir) {
+		return nil, nil
+	}
+	certPEM, keyPEM, err := ReadCertificatePEM(site, certsDir)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %v", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// 连接协议识别
+const (
+	tlsHandshakeRecord = 0x16             // TLS握手记录的首字节
+	sniffTimeout       = 10 * time.Second // 等待客户端发送首个字节的最长时间
+)
+
+// siteListener 站点监听器，设置了TLS配置时对新连接进行TLS握手
+// 替换证书或在HTTP与HTTPS之间切换时只需替换TLS配置，不需要重新绑定端口。
+// 启用HTTPS后仍按首字节识别明文HTTP连接，用于ACME HTTP-01验证和重定向到HTTPS
+type siteListener struct {
+	net.Listener
+	tlsConfig atomic.Value // tlsConfigHolder
+	conns     chan net.Conn
+	done      chan struct{}
+	err       error // 底层监听器停止接收连接的原因，done关闭后有效
+}
+
+// tlsConfigHolder 包装*tls.Config，保证atomic.Value中存储的类型一致
+type tlsConfigHolder struct {
+	*tls.Config
+}
+
+func newSiteListener(listener net.Listener, tlsConfig *tls.Config) *siteListener {
+	l := &siteListener{
+		Listener: listener,
+		conns:    make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+	l.SetTLSConfig(tlsConfig)
+	go l.acceptLoop()
+	return l
+}
+
+// SetTLSConfig 替换TLS配置，nil表示使用HTTP，已建立的连接不受影响
+func (l *siteListener) SetTLSConfig(tlsConfig *tls.Config) {
+	l.tlsConfig.Store(tlsConfigHolder{tlsConfig})
+}
+
+// acceptLoop 接收连接并在独立协程中识别协议，避免迟迟不发送数据的客户端阻塞其他连接
+func (l *siteListener) acceptLoop() {
+	defer close(l.done)
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			l.err = err
+			return
+		}
+		go l.dispatch(conn)
+	}
+}
+
+// dispatch 启用HTTPS时按首字节区分TLS握手和明文HTTP，再交给Accept返回
+func (l *siteListener) dispatch(conn net.Conn) {
+	if tlsConfig := l.tlsConfig.Load().(tlsConfigHolder).Config; tlsConfig != nil {
+		reader := bufio.NewReader(conn)
+		conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+		first, err := reader.Peek(1)
+		conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			conn.Close()
+			return
+		}
+		peeked := &peekedConn{Conn: conn, reader: reader}
+		if first[0] == tlsHandshakeRecord {
+			conn = tls.Server(peeked, tlsConfig)
+		} else {
+			conn = &plainConn{peeked}
+		}
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *siteListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+// peekedConn 先读取识别协议时缓冲的数据
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// plainConn HTTPS站点上的明文HTTP连接
+type plainConn struct {
+	net.Conn
+}
+
+// plainConnKey 请求上下文中标记明文连接的键
+type plainConnKey struct{}
+
+// connContext 为HTTPS站点上的明文连接设置标记
+func connContext(ctx context.Context, conn net.Conn) context.Context {
+	if _, ok := conn.(*plainConn); ok {
+		return context.WithValue(ctx, plainConnKey{}, true)
+	}
+	return ctx
+}
+
+// redirectToHTTPS HTTPS站点收到明文请求时重定向到HTTPS，ACME HTTP-01验证请求除外，返回是否已重定向
+func redirectToHTTPS(w http.ResponseWriter, r *http.Request) bool {
+	if plain, _ := r.Context().Value(plainConnKey{}).(bool); !plain || strings.HasPrefix(r.URL.Path, acme.ChallengePathPrefix) {
+		return false
+	}
+	http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	return true
+}
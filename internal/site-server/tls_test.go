@@ -0,0 +1,232 @@
+package siteserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"prerender-shield/internal/config"
+)
+
+// generateCertificate 生成自签名证书和私钥的PEM内容
+func generateCertificate(t *testing.T, dnsNames []string, notAfter time.Time) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// TestValidateCertificate 测试证书校验：私钥匹配、未过期、覆盖站点域名
+func TestValidateCertificate(t *testing.T) {
+	validUntil := time.Now().Add(90 * 24 * time.Hour)
+	certPEM, keyPEM := generateCertificate(t, []string{"example.com", "*.example.com"}, validUntil)
+
+	info, err := ValidateCertificate(certPEM, keyPEM, []string{"example.com", "www.example.com", "*.example.com", "localhost"})
+	if err != nil {
+		t.Fatalf("expected certificate to be valid: %v", err)
+	}
+	if info.Subject != "example.com" || info.ExpiringSoon {
+		t.Errorf("unexpected certificate info: %+v", info)
+	}
+
+	if _, err := ValidateCertificate(certPEM, keyPEM, []string{"other.com"}); err == nil || !strings.Contains(err.Error(), "other.com") {
+		t.Errorf("expected uncovered domain error, got %v", err)
+	}
+
+	_, otherKey := generateCertificate(t, []string{"example.com"}, validUntil)
+	if _, err := ValidateCertificate(certPEM, otherKey, []string{"example.com"}); err == nil {
+		t.Error("expected mismatched key to be rejected")
+	}
+
+	// 通配符站点域名要求证书包含相同的通配符名称
+	singleCert, singleKey := generateCertificate(t, []string{"a.example.com"}, validUntil)
+	if _, err := ValidateCertificate(singleCert, singleKey, []string{"*.example.com"}); err == nil {
+		t.Error("expected wildcard domain to require a wildcard certificate")
+	}
+
+	expiredCert, expiredKey := generateCertificate(t, []string{"example.com"}, time.Now().Add(-time.Minute))
+	if _, err := ValidateCertificate(expiredCert, expiredKey, []string{"example.com"}); err == nil {
+		t.Error("expected expired certificate to be rejected")
+	}
+}
+
+// TestInspectCertificate 测试读取站点证书文件并标记即将过期的证书
+func TestInspectCertificate(t *testing.T) {
+	certsDir := t.TempDir()
+	certPEM, keyPEM := generateCertificate(t, []string{"example.com"}, time.Now().Add(7*24*time.Hour))
+	os.MkdirAll(filepath.Join(certsDir, "site1"), 0700)
+	os.WriteFile(filepath.Join(certsDir, "site1", "cert.pem"), certPEM, 0644)
+	os.WriteFile(filepath.Join(certsDir, "site1", "key.pem"), keyPEM, 0600)
+
+	site := config.SiteConfig{ID: "site1"}
+	if info := InspectCertificate(site, certsDir); info != nil {
+		t.Errorf("expected no certificate info without TLS, got %+v", info)
+	}
+
+	site.TLS = config.SiteTLSConfig{Enabled: true, CertFile: "site1/cert.pem", KeyFile: "site1/key.pem"}
+	info := InspectCertificate(site, certsDir)
+	if info == nil || info.Error != "" || !info.ExpiringSoon {
+		t.Errorf("expected certificate expiring soon, got %+v", info)
+	}
+
+	site.TLS.KeyFile = "missing.pem"
+	if info := InspectCertificate(site, certsDir); info == nil || info.Error == "" {
+		t.Errorf("expected load error, got %+v", info)
+	}
+}
+
+// TestSiteServerTLS 测试站点服务器使用HTTPS，并在同一端口上替换证书和切换回HTTP
+func TestSiteServerTLS(t *testing.T) {
+	manager := NewManager(nil)
+	defer manager.StopAllServers()
+
+	certPEM, keyPEM := generateCertificate(t, []string{"example.com"}, time.Now().Add(90*24*time.Hour))
+	site := config.SiteConfig{
+		ID:   "site1",
+		Name: "Site 1",
+		Port: freePort(t),
+		TLS:  config.SiteTLSConfig{Enabled: true, CertPEM: string(certPEM), KeyPEM: string(keyPEM)},
+	}
+	if err := manager.StartSiteServer(site, "127.0.0.1", "", nil, textHandler("secure")); err != nil {
+		t.Fatalf("failed to start site server: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}}
+	getTLS := func() (string, string) {
+		resp, err := client.Get(fmt.Sprintf("https://127.0.0.1:%d/", site.Port))
+		if err != nil {
+			t.Fatalf("https request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	if body, cn := getTLS(); body != "secure" || cn != "example.com" {
+		t.Errorf("unexpected https response %q from certificate %q", body, cn)
+	}
+
+	// 端口不变时替换证书
+	newCert, newKey := generateCertificate(t, []string{"example.org"}, time.Now().Add(90*24*time.Hour))
+	site.TLS.CertPEM, site.TLS.KeyPEM = string(newCert), string(newKey)
+	if err := manager.UpdateSiteServer(site, "127.0.0.1", textHandler("renewed")); err != nil {
+		t.Fatalf("failed to replace certificate: %v", err)
+	}
+	if body, cn := getTLS(); body != "renewed" || cn != "example.org" {
+		t.Errorf("unexpected https response %q from certificate %q", body, cn)
+	}
+
+	// 无效证书不影响运行中的服务器
+	site.TLS.KeyPEM = "invalid"
+	if err := manager.UpdateSiteServer(site, "127.0.0.1", textHandler("broken")); err == nil {
+		t.Error("expected invalid certificate to be rejected")
+	}
+	if body, _ := getTLS(); body != "renewed" {
+		t.Errorf("expected previous handler to keep serving, got %q", body)
+	}
+
+	// 关闭HTTPS后同一端口使用HTTP
+	site.TLS = config.SiteTLSConfig{}
+	if err := manager.UpdateSiteServer(site, "127.0.0.1", textHandler("plain")); err != nil {
+		t.Fatalf("failed to disable TLS: %v", err)
+	}
+	if got := get(t, site.Port); got != "plain" {
+		t.Errorf("expected plain http response, got %q", got)
+	}
+}
+
+// TestSiteServerACME 测试ACME站点在证书签发前使用HTTP，签发后重新加载证书，
+// 同一端口上的明文请求重定向到HTTPS，HTTP-01验证请求除外
+func TestSiteServerACME(t *testing.T) {
+	certsDir := t.TempDir()
+	manager := NewManager(nil)
+	manager.SetCertsDir(certsDir)
+	defer manager.StopAllServers()
+
+	site := config.SiteConfig{
+		ID:      "site1",
+		Name:    "Site 1",
+		Port:    freePort(t),
+		Domains: []string{"example.com"},
+		TLS:     config.SiteTLSConfig{Enabled: true, ACME: true},
+	}
+	if err := manager.StartSiteServer(site, "127.0.0.1", "", nil, textHandler("content")); err != nil {
+		t.Fatalf("failed to start site server: %v", err)
+	}
+	if got := get(t, site.Port); got != "content" {
+		t.Errorf("expected plain http before certificate is issued, got %q", got)
+	}
+
+	// 模拟证书签发完成
+	certPEM, keyPEM := generateCertificate(t, []string{"example.com"}, time.Now().Add(90*24*time.Hour))
+	os.MkdirAll(filepath.Join(certsDir, "site1"), 0700)
+	os.WriteFile(filepath.Join(certsDir, "site1", config.SiteKeyFileName), keyPEM, 0600)
+	os.WriteFile(filepath.Join(certsDir, "site1", config.SiteCertFileName), certPEM, 0644)
+	if err := manager.ReloadTLS(site); err != nil {
+		t.Fatalf("failed to reload certificate: %v", err)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	request := func(url string) *http.Response {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("request to %s failed: %v", url, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := request(fmt.Sprintf("https://127.0.0.1:%d/", site.Port)); resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Errorf("expected https response, got status %d", resp.StatusCode)
+	}
+
+	resp := request(fmt.Sprintf("http://127.0.0.1:%d/page?a=1", site.Port))
+	if location := resp.Header.Get("Location"); resp.StatusCode != http.StatusMovedPermanently || location != fmt.Sprintf("https://127.0.0.1:%d/page?a=1", site.Port) {
+		t.Errorf("expected redirect to https, got status %d location %q", resp.StatusCode, location)
+	}
+
+	if resp := request(fmt.Sprintf("http://127.0.0.1:%d/.well-known/acme-challenge/token", site.Port)); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected challenge request over plain http, got status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,385 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// DefaultMaxExtractSize 未配置时单次解压允许写入的最大字节数
+const DefaultMaxExtractSize int64 = 1 << 30
+
+// DefaultMaxExtractFiles 未配置时单次解压允许写入的最大文件数
+const DefaultMaxExtractFiles = 100000
+
+// ErrArchiveTooLarge 解压后的内容超过允许的最大大小
+var ErrArchiveTooLarge = errors.New("archive exceeds maximum extracted size")
+
+// ErrArchiveTooManyFiles 归档中的文件数量超过允许的最大数量
+var ErrArchiveTooManyFiles = errors.New("archive exceeds maximum file count")
+
+// ExtractOptions 解压选项，限制字段不大于0时使用默认值
+type ExtractOptions struct {
+	MaxBytes int64 // 写入的最大总字节数
+	MaxFiles int   // 写入的最大文件数，不含目录
+	// 去掉条目路径开头的目录层数（同tar --strip-components），如为1时dist/index.html解压为index.html，
+	// 路径层数不足的条目被跳过
+	StripComponents int
+	// 为true时按CheckUploadExtension检查每个文件条目的文件名，有不允许的条目时停止解压并返回ErrUploadExtensionNotAllowed，
+	// AllowedExtensions或BlockedExtensions为空时使用默认列表
+	CheckExtensions   bool
+	AllowedExtensions []string
+	BlockedExtensions []string
+}
+
+// ErrUnsupportedArchive 不支持的归档格式
+var ErrUnsupportedArchive = errors.New("unsupported archive format")
+
+// ExtractStats 解压结果统计
+type ExtractStats struct {
+	Files int   `json:"files"` // 解压的文件数量，不含目录
+	Bytes int64 `json:"bytes"` // 写入的总字节数
+}
+
+// archiveExtractors 按文件扩展名选择解压方法
+var archiveExtractors = []struct {
+	suffix  string
+	extract func(archivePath string, out *archiveWriter) error
+}{
+	{".zip", extractZIP},
+	{".tar.gz", extractTarGz},
+	{".tgz", extractTarGz},
+	{".tar", extractTar},
+	{".rar", extractRAR},
+}
+
+// IsSupportedArchive 判断文件名是否为支持解压的归档格式
+func IsSupportedArchive(name string) bool {
+	return archiveExtractor(name) != nil
+}
+
+func archiveExtractor(name string) func(string, *archiveWriter) error {
+	lower := strings.ToLower(name)
+	for _, candidate := range archiveExtractors {
+		if strings.HasSuffix(lower, candidate.suffix) {
+			return candidate.extract
+		}
+	}
+	return nil
+}
+
+// ExtractArchive 解压归档文件（支持ZIP、tar.gz/tgz、tar和RAR格式），解压大小使用默认上限
+func ExtractArchive(archivePath, extractPath string) error {
+	_, err := ExtractArchiveWithLimit(archivePath, extractPath, 0)
+	return err
+}
+
+// ExtractArchiveWithLimit 按扩展名解压归档文件到extractPath，写入的总字节数超过maxBytes时返回ErrArchiveTooLarge，
+// maxBytes不大于0时使用默认上限，文件数使用默认上限
+func ExtractArchiveWithLimit(archivePath, extractPath string, maxBytes int64) (ExtractStats, error) {
+	return ExtractArchiveWithOptions(archivePath, extractPath, ExtractOptions{MaxBytes: maxBytes})
+}
+
+// ExtractArchiveWithOptions 按扩展名解压归档文件到extractPath，条目路径超出目标目录时返回ErrUnsafePath；
+// 写入的总字节数或文件数超过限制时停止解压并返回ErrArchiveTooLarge或ErrArchiveTooManyFiles。
+// 启用扩展名检查时，文件条目的扩展名不允许则返回ErrUploadExtensionNotAllowed。
+// 解压失败时删除本次新建的文件和目录（被覆盖的已有文件无法恢复）。符号链接等特殊条目会被跳过
+func ExtractArchiveWithOptions(archivePath, extractPath string, options ExtractOptions) (ExtractStats, error) {
+	extract := archiveExtractor(archivePath)
+	if extract == nil {
+		return ExtractStats{}, ErrUnsupportedArchive
+	}
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = DefaultMaxExtractSize
+	}
+	if options.MaxFiles <= 0 {
+		options.MaxFiles = DefaultMaxExtractFiles
+	}
+	out := &archiveWriter{
+		dest:            extractPath,
+		maxBytes:        options.MaxBytes,
+		maxFiles:        options.MaxFiles,
+		stripComponents: options.StripComponents,
+	}
+	if options.CheckExtensions {
+		out.checkName = func(name string) error {
+			return CheckUploadExtension(name, options.AllowedExtensions, options.BlockedExtensions)
+		}
+	}
+	err := extract(archivePath, out)
+	if err != nil {
+		out.cleanup()
+	}
+	return out.stats, err
+}
+
+// archiveWriter 将归档条目写入目标目录，统一处理路径检查和解压大小、文件数限制，
+// 并记录新建的文件和目录用于解压失败时清理
+type archiveWriter struct {
+	dest            string
+	maxBytes        int64
+	maxFiles        int
+	stripComponents int
+	checkName       func(name string) error // 检查文件条目的文件名，为nil时不检查
+	stats           ExtractStats
+	files           []string // 本次新建的文件
+	dirs            []string // 本次新建的最上层目录
+}
+
+// stripPath 去掉条目路径开头的stripComponents层目录，剩余路径为空时第二个返回值为false
+func (w *archiveWriter) stripPath(name string) (string, bool) {
+	if w.stripComponents <= 0 {
+		return name, true
+	}
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part != "" && part != "." {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) <= w.stripComponents {
+		return "", false
+	}
+	return strings.Join(parts[w.stripComponents:], "/"), true
+}
+
+// checkDeclared 在解压前按归档声明的文件数和大小预先检查限制，声明的大小不可信，写入时仍会计数
+func (w *archiveWriter) checkDeclared(files int, bytes uint64) error {
+	if files > w.maxFiles {
+		return fmt.Errorf("%w (%d files)", ErrArchiveTooManyFiles, w.maxFiles)
+	}
+	if bytes > uint64(w.maxBytes) {
+		return fmt.Errorf("%w (%d bytes)", ErrArchiveTooLarge, w.maxBytes)
+	}
+	return nil
+}
+
+// mkdirAll 创建目录，记录其中不存在的最上层目录
+func (w *archiveWriter) mkdirAll(dir string) error {
+	top := ""
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Lstat(current); err == nil {
+			break
+		}
+		top = current
+		if parent := filepath.Dir(current); parent == current {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if top != "" {
+		w.dirs = append(w.dirs, top)
+	}
+	return nil
+}
+
+// cleanup 删除本次新建的文件和目录
+func (w *archiveWriter) cleanup() {
+	for i := len(w.files) - 1; i >= 0; i-- {
+		os.Remove(w.files[i])
+	}
+	for i := len(w.dirs) - 1; i >= 0; i-- {
+		os.RemoveAll(w.dirs[i])
+	}
+}
+
+// mkdir 创建归档中的目录条目
+func (w *archiveWriter) mkdir(name string) error {
+	name, ok := w.stripPath(name)
+	if !ok {
+		return nil
+	}
+	target, err := SecurePath(w.dest, name)
+	if err != nil {
+		return err
+	}
+	return w.mkdirAll(target)
+}
+
+// writeFile 将归档中的文件条目写入目标目录
+func (w *archiveWriter) writeFile(name string, mode fs.FileMode, r io.Reader) error {
+	name, ok := w.stripPath(name)
+	if !ok {
+		return nil
+	}
+	if w.stats.Files >= w.maxFiles {
+		return fmt.Errorf("%w (%d files)", ErrArchiveTooManyFiles, w.maxFiles)
+	}
+	if w.checkName != nil {
+		if err := w.checkName(name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	target, err := SecurePath(w.dest, name)
+	if err != nil {
+		return err
+	}
+	if err := w.mkdirAll(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	_, statErr := os.Lstat(target)
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if os.IsNotExist(statErr) {
+		w.files = append(w.files, target)
+	}
+
+	// 多读一个字节用于判断是否超过上限，不依赖归档中声明的大小
+	remaining := w.maxBytes - w.stats.Bytes
+	written, err := io.Copy(file, io.LimitReader(r, remaining+1))
+	w.stats.Bytes += written
+	if err != nil {
+		return err
+	}
+	if written > remaining {
+		os.Remove(target)
+		return fmt.Errorf("%w (%d bytes)", ErrArchiveTooLarge, w.maxBytes)
+	}
+	w.stats.Files++
+	return nil
+}
+
+// extractZIP 解压ZIP文件
+func extractZIP(archivePath string, out *archiveWriter) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// 按条目声明的解压后大小预先检查，明显超限的归档不写入任何文件
+	files := 0
+	var declared uint64
+	for _, file := range reader.File {
+		if file.Mode().IsRegular() {
+			files++
+			declared += file.UncompressedSize64
+		}
+	}
+	if err := out.checkDeclared(files, declared); err != nil {
+		return err
+	}
+
+	for _, file := range reader.File {
+		mode := file.Mode()
+		if mode.IsDir() {
+			if err := out.mkdir(file.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+
+		inFile, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = out.writeFile(file.Name, mode, inFile)
+		inFile.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTarGz 解压gzip压缩的tar文件
+func extractTarGz(archivePath string, out *archiveWriter) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	return extractTarStream(gz, out)
+}
+
+// extractTar 解压未压缩的tar文件
+func extractTar(archivePath string, out *archiveWriter) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return extractTarStream(file, out)
+}
+
+// extractTarStream 逐个解压tar流中的条目，只处理普通文件和目录
+func extractTarStream(r io.Reader, out *archiveWriter) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = out.mkdir(header.Name)
+		case tar.TypeReg:
+			err = out.writeFile(header.Name, header.FileInfo().Mode(), reader)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractRAR 解压RAR文件，加密的归档需要密码，无法解压
+func extractRAR(archivePath string, out *archiveWriter) error {
+	reader, err := rardecode.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		mode := header.Mode()
+		switch {
+		case header.IsDir:
+			err = out.mkdir(header.Name)
+		case header.LinkType == 0 && mode.IsRegular():
+			err = out.writeFile(header.Name, mode, reader)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
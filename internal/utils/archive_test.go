@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry 测试归档中的条目
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+// writeTarGz 创建包含指定条目的tar.gz文件
+func writeTarGz(t *testing.T, path string, entries []tarEntry) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: entry.typeflag, Linkname: entry.linkname}
+		if entry.typeflag == tar.TypeReg {
+			header.Size = int64(len(entry.content))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write tar header: %v", err)
+		}
+		tw.Write([]byte(entry.content))
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+}
+
+// rar5VInt 按RAR5格式编码可变长度整数
+func rar5VInt(v uint64) []byte {
+	var out []byte
+	for v >= 0x80 {
+		out = append(out, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(out, byte(v))
+}
+
+// rar5Header 生成带CRC32的RAR5块头
+func rar5Header(body []byte) []byte {
+	sized := append(rar5VInt(uint64(len(body))), body...)
+	header := make([]byte, 4, 4+len(sized))
+	binary.LittleEndian.PutUint32(header, crc32.ChecksumIEEE(sized))
+	return append(header, sized...)
+}
+
+// buildStoredRAR5 生成只使用存储方式（不压缩）的RAR5归档
+func buildStoredRAR5(files []tarEntry) []byte {
+	archive := []byte("Rar!\x1a\x07\x01\x00")
+	// 主归档头：类型1，无标志
+	archive = append(archive, rar5Header([]byte{1, 0, 0})...)
+	for _, file := range files {
+		data := []byte(file.content)
+		var body []byte
+		body = append(body, 2)                              // 文件头
+		body = append(body, 2)                              // 包含数据区
+		body = append(body, rar5VInt(uint64(len(data)))...) // 数据区大小
+		body = append(body, 4)                              // 文件标志：包含CRC32
+		body = append(body, rar5VInt(uint64(len(data)))...) // 解压后大小
+		body = append(body, rar5VInt(0o100644)...)          // 文件属性
+		body = binary.LittleEndian.AppendUint32(body, crc32.ChecksumIEEE(data))
+		body = append(body, 0) // 压缩信息：存储
+		body = append(body, 1) // 主机系统：Unix
+		body = append(body, rar5VInt(uint64(len(file.name)))...)
+		body = append(body, file.name...)
+		archive = append(archive, rar5Header(body)...)
+		archive = append(archive, data...)
+	}
+	// 归档结束头
+	return append(archive, rar5Header([]byte{5, 0, 0})...)
+}
+
+// TestExtractArchiveFormats 测试按扩展名解压ZIP、tar.gz、tgz和RAR，并统计文件数和字节数
+func TestExtractArchiveFormats(t *testing.T) {
+	tempDir := t.TempDir()
+	entries := []tarEntry{
+		{name: "assets/", typeflag: tar.TypeDir},
+		{name: "index.html", typeflag: tar.TypeReg, content: "<html>home</html>"},
+		{name: "assets/app.js", typeflag: tar.TypeReg, content: "console.log(1)"},
+		// 符号链接被跳过，避免指向目标目录之外
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"},
+	}
+	totalBytes := int64(len("<html>home</html>") + len("console.log(1)"))
+
+	zipPath := filepath.Join(tempDir, "site.zip")
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	zw.Create("assets/")
+	for _, entry := range entries[1:3] {
+		w, _ := zw.Create(entry.name)
+		w.Write([]byte(entry.content))
+	}
+	zw.Close()
+	os.WriteFile(zipPath, zipBuf.Bytes(), 0644)
+
+	tarGzPath := filepath.Join(tempDir, "site.tar.gz")
+	writeTarGz(t, tarGzPath, entries)
+	tgzPath := filepath.Join(tempDir, "site.TGZ")
+	writeTarGz(t, tgzPath, entries)
+	rarPath := filepath.Join(tempDir, "site.rar")
+	os.WriteFile(rarPath, buildStoredRAR5(entries[1:3]), 0644)
+
+	for _, archivePath := range []string{zipPath, tarGzPath, tgzPath, rarPath} {
+		extractDir := filepath.Join(tempDir, "extract-"+filepath.Base(archivePath))
+		stats, err := ExtractArchiveWithLimit(archivePath, extractDir, 0)
+		if err != nil {
+			t.Errorf("%s: extraction failed: %v", archivePath, err)
+			continue
+		}
+		if stats.Files != 2 || stats.Bytes != totalBytes {
+			t.Errorf("%s: unexpected stats %+v", archivePath, stats)
+		}
+		content, err := os.ReadFile(filepath.Join(extractDir, "assets", "app.js"))
+		if err != nil || string(content) != "console.log(1)" {
+			t.Errorf("%s: unexpected assets/app.js: %q, %v", archivePath, content, err)
+		}
+		if _, err := os.Lstat(filepath.Join(extractDir, "link")); !os.IsNotExist(err) {
+			t.Errorf("%s: symlink entry should be skipped", archivePath)
+		}
+	}
+
+	if !IsSupportedArchive("build.tgz") || IsSupportedArchive("build.7z") {
+		t.Error("unexpected IsSupportedArchive result")
+	}
+	if _, err := ExtractArchiveWithLimit(filepath.Join(tempDir, "build.7z"), tempDir, 0); !errors.Is(err, ErrUnsupportedArchive) {
+		t.Errorf("expected ErrUnsupportedArchive, got %v", err)
+	}
+}
+
+// TestExtractArchiveStripComponents 测试去掉一层目录后嵌套在dist/下的文件解压到目标目录根
+func TestExtractArchiveStripComponents(t *testing.T) {
+	tempDir := t.TempDir()
+	zipPath := filepath.Join(tempDir, "dist.zip")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("dist/")
+	for name, content := range map[string]string{"dist/index.html": "<html></html>", "dist/assets/app.js": "app", "README.md": "readme"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	os.WriteFile(zipPath, buf.Bytes(), 0644)
+
+	extractDir := filepath.Join(tempDir, "site")
+	stats, err := ExtractArchiveWithOptions(zipPath, extractDir, ExtractOptions{StripComponents: 1})
+	if err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	if stats.Files != 2 {
+		t.Errorf("entries without enough path components should be skipped, got %+v", stats)
+	}
+	for _, name := range []string{"index.html", "assets/app.js"} {
+		if _, err := os.Stat(filepath.Join(extractDir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("expected %s at the destination root: %v", name, err)
+		}
+	}
+	for _, name := range []string{"dist", "README.md"} {
+		if _, err := os.Stat(filepath.Join(extractDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be extracted", name)
+		}
+	}
+}
+
+// TestExtractArchiveLimits 测试tar.gz条目的路径穿越防护和解压大小上限
+func TestExtractArchiveLimits(t *testing.T) {
+	tempDir := t.TempDir()
+
+	slipPath := filepath.Join(tempDir, "evil.tar.gz")
+	writeTarGz(t, slipPath, []tarEntry{{name: "../evil.txt", typeflag: tar.TypeReg, content: "evil"}})
+	if err := ExtractArchive(slipPath, filepath.Join(tempDir, "slip")); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("expected ErrUnsafePath, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("tar entry was extracted outside the target directory")
+	}
+
+	// 高压缩比的内容解压后超过上限
+	bombPath := filepath.Join(tempDir, "bomb.tgz")
+	writeTarGz(t, bombPath, []tarEntry{
+		{name: "a.txt", typeflag: tar.TypeReg, content: strings.Repeat("a", 600)},
+		{name: "b.txt", typeflag: tar.TypeReg, content: strings.Repeat("b", 600)},
+	})
+	stats, err := ExtractArchiveWithLimit(bombPath, filepath.Join(tempDir, "bomb"), 1000)
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("expected ErrArchiveTooLarge, got %v", err)
+	}
+	if stats.Files != 1 || stats.Bytes > 1001 {
+		t.Errorf("extraction should stop at the limit, got %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "bomb")); !os.IsNotExist(err) {
+		t.Error("partial output should be removed when the limit is exceeded")
+	}
+	if _, err := ExtractArchiveWithLimit(bombPath, filepath.Join(tempDir, "ok"), 1200); err != nil {
+		t.Errorf("archive within the limit should extract, got %v", err)
+	}
+
+	// 文件数超过上限时停止解压并清理已写入的文件，已有的文件保留
+	countDir := filepath.Join(tempDir, "count")
+	os.MkdirAll(countDir, 0755)
+	os.WriteFile(filepath.Join(countDir, "existing.txt"), []byte("keep"), 0644)
+	_, err = ExtractArchiveWithOptions(bombPath, countDir, ExtractOptions{MaxFiles: 1})
+	if !errors.Is(err, ErrArchiveTooManyFiles) {
+		t.Fatalf("expected ErrArchiveTooManyFiles, got %v", err)
+	}
+	entries, _ := os.ReadDir(countDir)
+	if len(entries) != 1 || entries[0].Name() != "existing.txt" {
+		t.Errorf("only the existing file should remain, got %v", entries)
+	}
+
+	// ZIP条目声明的解压后大小超过上限时不写入任何文件
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"assets/a.txt", "assets/b.txt"} {
+		w, _ := zw.Create(name)
+		w.Write(bytes.Repeat([]byte("z"), 4096))
+	}
+	zw.Close()
+	zipBomb := filepath.Join(tempDir, "bomb.zip")
+	os.WriteFile(zipBomb, buf.Bytes(), 0644)
+	if len(buf.Bytes()) >= 4096 {
+		t.Fatalf("test archive should be smaller than its output, got %d bytes", len(buf.Bytes()))
+	}
+	stats, err = ExtractArchiveWithOptions(zipBomb, filepath.Join(tempDir, "zipbomb"), ExtractOptions{MaxBytes: 6000})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("expected ErrArchiveTooLarge, got %v", err)
+	}
+	if stats.Files != 0 {
+		t.Errorf("declared size should be rejected before extraction, got %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "zipbomb")); !os.IsNotExist(err) {
+		t.Error("nothing should be written for a rejected ZIP")
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPResolver 按可信代理解析客户端IP。
+// 只有TCP对端是可信代理时才采用X-Forwarded-For和X-Real-IP，其他客户端可以任意伪造这些请求头
+type ClientIPResolver struct {
+	trusted *IPList
+}
+
+// NewClientIPResolver 创建客户端IP解析器，可信代理为单个IP或CIDR网段，为空时只使用TCP对端地址。
+// 无法解析的条目被跳过并返回错误，返回的解析器仍使用其余有效条目
+func NewClientIPResolver(trustedProxies []string) (*ClientIPResolver, error) {
+	trusted, err := ParseIPList(trustedProxies)
+	return &ClientIPResolver{trusted: trusted}, err
+}
+
+// Resolve 解析请求的客户端IP。对端是可信代理时从右向左遍历X-Forwarded-For，跳过可信代理，
+// 返回第一个不是可信代理的地址，全部是可信代理时返回最左侧的地址；没有X-Forwarded-For时使用X-Real-IP。
+// 对端不是可信代理或请求头中的地址无法解析时返回对端地址；r为nil时同样返回对端地址
+func (r *ClientIPResolver) Resolve(req *http.Request) string {
+	peer := remoteIP(req)
+	if r == nil || !r.trusted.Contains(peer) {
+		return peer
+	}
+
+	if xff := req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return peer
+			}
+			if i == 0 || !r.trusted.Contains(ip.String()) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return peer
+}
+
+// clientIPKey 请求上下文中保存解析出的客户端IP的键
+type clientIPKey struct{}
+
+// WithClientIP 在请求上下文中记录解析出的客户端IP，由站点处理器在请求入口设置，
+// 之后防火墙、频率限制、挑战令牌和访问日志通过ClientIP获取同一个地址
+func WithClientIP(req *http.Request, ip string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), clientIPKey{}, ip))
+}
+
+// ClientIP 返回请求的客户端IP：已通过WithClientIP记录时返回记录的地址，否则返回TCP对端地址（不含端口）
+func ClientIP(req *http.Request) string {
+	if ip, ok := req.Context().Value(clientIPKey{}).(string); ok && ip != "" {
+		return ip
+	}
+	return remoteIP(req)
+}
+
+// remoteIP 返回请求的TCP对端IP（不含端口）
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
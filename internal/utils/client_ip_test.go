@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIPResolver 测试只有可信代理转发的X-Forwarded-For和X-Real-IP才被采用
+func TestClientIPResolver(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("NewClientIPResolver failed: %v", err)
+	}
+
+	cases := []struct {
+		name   string
+		peer   string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"untrusted peer ignores headers", "203.0.113.5:1234", []string{"10.1.2.3"}, "10.1.2.4", "203.0.113.5"},
+		{"trusted peer without headers", "10.0.0.1:1234", nil, "", "10.0.0.1"},
+		{"rightmost untrusted hop", "10.0.0.1:1234", []string{"198.51.100.9, 203.0.113.7, 192.0.2.1"}, "", "203.0.113.7"},
+		{"multiple header lines", "10.0.0.1:1234", []string{"198.51.100.9", "203.0.113.7, 10.2.3.4"}, "", "203.0.113.7"},
+		{"all hops trusted", "10.0.0.1:1234", []string{"10.9.9.9, 192.0.2.1"}, "", "10.9.9.9"},
+		{"invalid hop falls back to peer", "10.0.0.1:1234", []string{"198.51.100.9, bogus"}, "", "10.0.0.1"},
+		{"real ip from trusted peer", "192.0.2.1:1234", nil, " 198.51.100.9 ", "198.51.100.9"},
+		{"ipv6 peer", "[2001:db8::1]:1234", []string{"10.1.2.3"}, "", "2001:db8::1"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.peer
+		for _, v := range tc.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if tc.realIP != "" {
+			req.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := resolver.Resolve(req); got != tc.want {
+			t.Errorf("%s: Resolve() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// 未配置可信代理时始终使用对端地址
+	var none *ClientIPResolver
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+	if got := none.Resolve(req); got != "10.0.0.1" {
+		t.Errorf("nil resolver: got %q, want peer address", got)
+	}
+
+	if _, err := NewClientIPResolver([]string{"not-an-ip"}); err == nil {
+		t.Error("expected error for invalid trusted proxy")
+	}
+}
+
+// TestClientIP 测试ClientIP优先返回请求上下文中记录的地址
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.9")
+	if got := ClientIP(req); got != "10.0.0.1" {
+		t.Errorf("ClientIP() = %q, want peer address", got)
+	}
+	if got := ClientIP(WithClientIP(req, "203.0.113.7")); got != "203.0.113.7" {
+		t.Errorf("ClientIP() = %q, want recorded address", got)
+	}
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// DefaultMaxConcurrentExtractions 未配置时允许同时进行的解压任务数量
+const DefaultMaxConcurrentExtractions = 2
+
+// ExtractionLimiter 限制全局同时进行的解压任务数量，超出的任务排队等待空闲名额
+type ExtractionLimiter struct {
+	slots  chan struct{}
+	queued int64
+}
+
+// NewExtractionLimiter 创建解压任务限制器，limit不大于0时使用默认值
+func NewExtractionLimiter(limit int) *ExtractionLimiter {
+	if limit <= 0 {
+		limit = DefaultMaxConcurrentExtractions
+	}
+	return &ExtractionLimiter{slots: make(chan struct{}, limit)}
+}
+
+// Acquire 获取解压名额，没有空闲名额时排队等待，ctx取消时放弃等待并返回错误
+// onQueued在需要排队时调用一次，可用于记录排队状态
+func (l *ExtractionLimiter) Acquire(ctx context.Context, onQueued func()) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	atomic.AddInt64(&l.queued, 1)
+	defer atomic.AddInt64(&l.queued, -1)
+	if onQueued != nil {
+		onQueued()
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release 释放解压名额
+func (l *ExtractionLimiter) Release() {
+	<-l.slots
+}
+
+// Running 正在进行的解压任务数量
+func (l *ExtractionLimiter) Running() int {
+	return len(l.slots)
+}
+
+// Queued 排队等待的解压任务数量
+func (l *ExtractionLimiter) Queued() int {
+	return int(atomic.LoadInt64(&l.queued))
+}
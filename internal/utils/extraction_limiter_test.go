@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestExtractionLimiterQueuesExtraJobs 测试超过上限的解压任务排队等待，直到有任务释放名额
+func TestExtractionLimiterQueuesExtraJobs(t *testing.T) {
+	limiter := NewExtractionLimiter(2)
+	for i := 0; i < 2; i++ {
+		if err := limiter.Acquire(context.Background(), nil); err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+	}
+
+	queued := make(chan struct{})
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire(context.Background(), func() { close(queued) })
+		close(acquired)
+	}()
+
+	<-queued
+	select {
+	case <-acquired:
+		t.Fatal("expected the third extraction to wait for a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if limiter.Queued() != 1 || limiter.Running() != 2 {
+		t.Errorf("expected 2 running and 1 queued, got %d and %d", limiter.Running(), limiter.Queued())
+	}
+
+	limiter.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected the queued extraction to start after a slot was released")
+	}
+	if limiter.Queued() != 0 || limiter.Running() != 2 {
+		t.Errorf("expected 2 running and 0 queued, got %d and %d", limiter.Running(), limiter.Queued())
+	}
+}
+
+// TestExtractionLimiterCancel 测试排队中的任务在请求取消时放弃等待
+func TestExtractionLimiterCancel(t *testing.T) {
+	limiter := NewExtractionLimiter(1)
+	limiter.Acquire(context.Background(), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(ctx, nil); err == nil {
+		t.Error("expected Acquire to fail when the context is canceled")
+	}
+	if limiter.Queued() != 0 {
+		t.Errorf("expected no queued jobs after cancel, got %d", limiter.Queued())
+	}
+}
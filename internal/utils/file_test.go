@@ -2,8 +2,10 @@ package utils
 
 import (
 	"archive/zip"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -164,3 +166,48 @@ func createTestZip(zipPath string) error {
 
 	return nil
 }
+
+// TestSecurePath 测试路径清理后必须位于根目录内，以/开头的路径视为相对于根目录
+func TestSecurePath(t *testing.T) {
+	baseDir := t.TempDir()
+
+	for _, path := range []string{"", "/", "index.html", "/assets/app.js", "assets/../index.html", "/etc/passwd"} {
+		resolved, err := SecurePath(baseDir, path)
+		if err != nil {
+			t.Errorf("SecurePath(%q) failed: %v", path, err)
+			continue
+		}
+		if resolved != baseDir && !strings.HasPrefix(resolved, baseDir+string(filepath.Separator)) {
+			t.Errorf("SecurePath(%q) = %q, expected a path inside %q", path, resolved, baseDir)
+		}
+	}
+
+	for _, path := range []string{"..", "../config.yaml", "/../../config.yaml", "assets/../../other-site/index.html", "../" + filepath.Base(baseDir) + "-evil/x"} {
+		if _, err := SecurePath(baseDir, path); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("SecurePath(%q) expected ErrUnsafePath, got %v", path, err)
+		}
+	}
+}
+
+// TestExtractArchiveRejectsZipSlip 测试ZIP条目包含../时拒绝解压到目录之外
+func TestExtractArchiveRejectsZipSlip(t *testing.T) {
+	tempDir := t.TempDir()
+	zipPath := filepath.Join(tempDir, "evil.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("Failed to create zip file: %v", err)
+	}
+	zipWriter := zip.NewWriter(zipFile)
+	entry, _ := zipWriter.Create("../evil.txt")
+	entry.Write([]byte("evil"))
+	zipWriter.Close()
+	zipFile.Close()
+
+	extractDir := filepath.Join(tempDir, "extract")
+	if err := ExtractArchive(zipPath, extractDir); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("expected ErrUnsafePath, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("zip entry was extracted outside the target directory")
+	}
+}
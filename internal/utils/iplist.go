@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPList 由单个IP和CIDR网段组成的IP列表，单个IP通过map精确匹配，网段逐个比较
+type IPList struct {
+	exact map[string]struct{}
+	nets  []*net.IPNet
+}
+
+// ParseIPList 解析IP列表，每个条目为单个IP（如 1.2.3.4）或CIDR网段（如 10.0.0.0/8）。
+// 无法解析的条目被跳过并返回错误，返回的列表仍包含其余有效条目
+func ParseIPList(entries []string) (*IPList, error) {
+	list := &IPList{exact: make(map[string]struct{})}
+	var invalid []string
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+				list.nets = append(list.nets, ipNet)
+				continue
+			}
+		} else if ip := net.ParseIP(entry); ip != nil {
+			list.exact[ip.String()] = struct{}{}
+			continue
+		}
+		invalid = append(invalid, entry)
+	}
+	if len(invalid) > 0 {
+		return list, fmt.Errorf("invalid IP or CIDR entries: %s", strings.Join(invalid, ", "))
+	}
+	return list, nil
+}
+
+// Contains 判断IP是否在列表中，无法解析的IP不匹配任何条目
+func (l *IPList) Contains(ip string) bool {
+	if l == nil {
+		return false
+	}
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return false
+	}
+	if _, exists := l.exact[parsed.String()]; exists {
+		return true
+	}
+	for _, ipNet := range l.nets {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty 判断列表是否没有任何条目
+func (l *IPList) Empty() bool {
+	return l == nil || (len(l.exact) == 0 && len(l.nets) == 0)
+}
@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+// TestParseIPList 测试单个IP和CIDR网段的解析与匹配
+func TestParseIPList(t *testing.T) {
+	list, err := ParseIPList([]string{"10.0.0.0/8", " 192.0.2.7 ", "2001:db8::/32", "not-an-ip", "300.0.0.0/8"})
+	if err == nil {
+		t.Error("expected error for invalid entries")
+	}
+
+	cases := map[string]bool{
+		"10.255.0.1":  true,
+		"192.0.2.7":   true,
+		"192.0.2.8":   false,
+		"2001:db8::1": true,
+		"2001:db9::1": false,
+		"not-an-ip":   false,
+	}
+	for ip, want := range cases {
+		if got := list.Contains(ip); got != want {
+			t.Errorf("Contains(%q) = %v, want %v", ip, got, want)
+		}
+	}
+
+	var empty *IPList
+	if empty.Contains("10.0.0.1") || !empty.Empty() {
+		t.Error("nil list should be empty and match nothing")
+	}
+}
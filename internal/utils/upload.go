@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUploadExtensionNotAllowed 上传文件的扩展名不在允许列表中或在禁止列表中
+var ErrUploadExtensionNotAllowed = errors.New("file type not allowed")
+
+// DefaultUploadAllowedExtensions 未配置时静态资源上传允许的扩展名：网页资源、媒体文件和可解压发布的归档文件
+var DefaultUploadAllowedExtensions = []string{
+	".html", ".htm", ".css", ".js", ".mjs", ".map", ".json", ".xml", ".txt", ".webmanifest",
+	".jpg", ".jpeg", ".png", ".gif", ".svg", ".ico", ".webp", ".avif", ".bmp",
+	".woff", ".woff2", ".ttf", ".otf", ".eot",
+	".mp4", ".webm", ".mp3", ".ogg", ".wav", ".pdf",
+	".zip", ".tar", ".gz", ".tgz", ".rar",
+}
+
+// DefaultUploadBlockedExtensions 未配置时静态资源上传禁止的扩展名：服务端脚本和可执行文件
+var DefaultUploadBlockedExtensions = []string{
+	".php", ".phtml", ".php3", ".php4", ".php5", ".phar", ".jsp", ".jspx", ".asp", ".aspx",
+	".cgi", ".pl", ".py", ".rb", ".sh", ".bash", ".exe", ".dll", ".bat", ".cmd", ".com", ".msi",
+	".so", ".jar", ".war", ".htaccess",
+}
+
+// CheckUploadExtension 检查上传文件名的扩展名，allowed或blocked为空时使用默认列表
+// 文件名中任意一段扩展名在禁止列表中即拒绝（如shell.php.jpg），最后一段扩展名必须在允许列表中
+func CheckUploadExtension(name string, allowed, blocked []string) error {
+	if len(allowed) == 0 {
+		allowed = DefaultUploadAllowedExtensions
+	}
+	if len(blocked) == 0 {
+		blocked = DefaultUploadBlockedExtensions
+	}
+
+	base := strings.ToLower(filepath.Base(name))
+	segments := strings.Split(base, ".")
+	for _, segment := range segments[1:] {
+		if containsExtension(blocked, "."+segment) {
+			return fmt.Errorf("%w: .%s", ErrUploadExtensionNotAllowed, segment)
+		}
+	}
+
+	ext := filepath.Ext(base)
+	if ext == "" || !containsExtension(allowed, ext) {
+		return fmt.Errorf("%w: %q", ErrUploadExtensionNotAllowed, ext)
+	}
+	return nil
+}
+
+// containsExtension 判断扩展名列表中是否包含ext，列表项不区分大小写，可以省略前导点
+func containsExtension(extensions []string, ext string) bool {
+	for _, candidate := range extensions {
+		candidate = strings.ToLower(strings.TrimSpace(candidate))
+		if !strings.HasPrefix(candidate, ".") {
+			candidate = "." + candidate
+		}
+		if candidate == ext {
+			return true
+		}
+	}
+	return false
+}
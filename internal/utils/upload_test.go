@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestCheckUploadExtension 测试上传扩展名的默认允许和禁止列表，以及自定义列表
+func TestCheckUploadExtension(t *testing.T) {
+	for name, allowed := range map[string]bool{
+		"index.html":    true,
+		"app.JS":        true,
+		"site.tar.gz":   true,
+		"robots.txt":    true,
+		"shell.php":     false,
+		"shell.php.jpg": false,
+		"Index.JSP":     false,
+		"setup.exe":     false,
+		".htaccess":     false,
+		"Makefile":      false,
+		"data.sqlite":   false,
+	} {
+		err := CheckUploadExtension(name, nil, nil)
+		if (err == nil) != allowed {
+			t.Errorf("CheckUploadExtension(%q) = %v, expected allowed=%v", name, err, allowed)
+		}
+		if err != nil && !errors.Is(err, ErrUploadExtensionNotAllowed) {
+			t.Errorf("expected ErrUploadExtensionNotAllowed for %q, got %v", name, err)
+		}
+	}
+
+	// 自定义列表替换默认列表，列表项可以省略前导点
+	if err := CheckUploadExtension("data.sqlite", []string{"sqlite"}, nil); err != nil {
+		t.Errorf("expected configured extension to be allowed, got %v", err)
+	}
+	if err := CheckUploadExtension("index.html", nil, []string{".html"}); err == nil {
+		t.Error("expected configured blocked extension to be rejected")
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/api/routes"
+	"prerender-shield/internal/auth"
+)
+
+func setupAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	jwtManager := auth.NewJWTManager(&auth.JWTConfig{
+		SecretKey:  "test-secret",
+		ExpireTime: time.Hour,
+	}, nil)
+
+	// 控制器为空即可，未认证的请求会在中间件处被拦截
+	routes.RegisterAllRoutes(r, &routes.Controllers{}, jwtManager)
+	return r
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	r := setupAuthRouter()
+
+	protected := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/api/v1/sites"},
+		{http.MethodPost, "/api/v1/sites"},
+		{http.MethodDelete, "/api/v1/sites/site1"},
+		{http.MethodPost, "/api/v1/sites/site1/restart"},
+		{http.MethodPost, "/api/v1/sites/site1/tls"},
+		{http.MethodPost, "/api/v1/sites/site1/enable"},
+		{http.MethodPost, "/api/v1/sites/site1/disable"},
+		{http.MethodPost, "/api/v1/sites/site1/static/mkdir"},
+		{http.MethodPost, "/api/v1/sites/site1/static/rename"},
+		{http.MethodPost, "/api/v1/sites/site1/static/batch-delete"},
+		{http.MethodGet, "/api/v1/sites/site1/static/download?path=/"},
+		{http.MethodGet, "/api/v1/sites/site1/static/usage"},
+		{http.MethodPost, "/api/v1/sites/site1/static/rollback"},
+		{http.MethodGet, "/api/v1/crawler/logs/export"},
+		{http.MethodGet, "/api/v1/config/backups"},
+		{http.MethodPost, "/api/v1/config/rollback"},
+		{http.MethodPost, "/api/v1/config/validate"},
+		{http.MethodGet, "/api/v1/logs/level"},
+		{http.MethodPut, "/api/v1/logs/level"},
+		{http.MethodGet, "/api/v1/logs/audit"},
+		{http.MethodGet, "/api/v1/logs/export"},
+		{http.MethodGet, "/api/v1/firewall/attacks"},
+		{http.MethodGet, "/api/v1/firewall/logs"},
+		{http.MethodGet, "/api/v1/firewall/integrity"},
+		{http.MethodGet, "/api/v1/engines"},
+		{http.MethodGet, "/api/v1/firewall/rules?site=site1"},
+		{http.MethodPost, "/api/v1/firewall/rules?site=site1"},
+		{http.MethodPut, "/api/v1/firewall/rules/rule1?site=site1"},
+		{http.MethodDelete, "/api/v1/firewall/rules/rule1?site=site1"},
+		{http.MethodGet, "/api/v1/firewall/bans?site=site1"},
+		{http.MethodDelete, "/api/v1/firewall/bans?site=site1&ip=10.0.0.1"},
+		{http.MethodGet, "/api/v1/preheat/stats"},
+		{http.MethodPost, "/api/v1/preheat/cancel"},
+		{http.MethodGet, "/api/v1/prerender/crawler-headers?site=site1"},
+		{http.MethodPut, "/api/v1/prerender/crawler-headers?site=site1"},
+		{http.MethodPost, "/api/v1/prerender/detect"},
+		{http.MethodPost, "/api/v1/push/trigger?siteId=site1&dryRun=true"},
+		{http.MethodPost, "/api/v1/push/mark-pushed?siteId=site1"},
+		{http.MethodGet, "/api/v1/monitoring/stats"},
+		{http.MethodGet, "/api/v1/monitoring/sites/site1"},
+		{http.MethodPost, "/api/v1/auth/change-password"},
+		{http.MethodGet, "/api/v1/users"},
+		{http.MethodPost, "/api/v1/users"},
+		{http.MethodDelete, "/api/v1/users/user1"},
+		{http.MethodGet, "/api/v1/logs"},
+		{http.MethodGet, "/api/v1/version"},
+	}
+
+	for _, route := range protected {
+		req, _ := http.NewRequest(route.method, route.path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s should require a token", route.method, route.path)
+	}
+}
+
+func TestProtectedRoutesRejectInvalidToken(t *testing.T) {
+	r := setupAuthRouter()
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/sites", nil)
+	req.Header.Set("Authorization", "Bearer invalid-token")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/api/controllers"
+	"prerender-shield/internal/api/routes"
+	"prerender-shield/internal/auth"
+	"prerender-shield/internal/config"
+)
+
+func setupRenderRouter(renderAPI config.RenderAPIConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	configManager := config.GetInstance()
+	configManager.UpdateConfig(&config.Config{
+		RenderAPI: renderAPI,
+		Sites: []config.SiteConfig{
+			{ID: "site1", Name: "Site 1", Domains: []string{"example.com", "www.example.com"}, Port: 8082},
+		},
+	})
+
+	jwtManager := auth.NewJWTManager(&auth.JWTConfig{
+		SecretKey:  "test-secret",
+		ExpireTime: time.Hour,
+	}, nil)
+
+	// 未创建渲染引擎，匹配到站点的请求会返回503
+	routes.RegisterAllRoutes(r, &routes.Controllers{
+		RenderController: controllers.NewRenderController(nil, configManager),
+	}, jwtManager)
+	return r
+}
+
+func TestRenderAPI(t *testing.T) {
+	r := setupRenderRouter(config.RenderAPIConfig{Enabled: true, Path: "prerender", Token: "secret"})
+
+	cases := []struct {
+		name   string
+		target string
+		token  string
+		status int
+	}{
+		{"missing token", "/prerender?url=https://example.com/page", "", http.StatusUnauthorized},
+		{"wrong token", "/prerender?url=https://example.com/page", "wrong", http.StatusUnauthorized},
+		{"missing url", "/prerender", "secret", http.StatusBadRequest},
+		{"relative url", "/prerender?url=/page", "secret", http.StatusBadRequest},
+		{"malformed url", "/prerender?url=http://%zz", "secret", http.StatusBadRequest},
+		{"unknown host", "/prerender?url=https://unknown.com/page", "secret", http.StatusNotFound},
+		{"known host", "/prerender?url=https://WWW.example.com:443/page", "secret", http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", c.target, nil)
+		if c.token != "" {
+			req.Header.Set("X-Prerender-Token", c.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, c.status, w.Code, c.name)
+	}
+}
+
+func TestRenderAPIDisabled(t *testing.T) {
+	r := setupRenderRouter(config.RenderAPIConfig{Enabled: false, Path: "/render"})
+
+	req, _ := http.NewRequest("GET", "/render?url=https://example.com/page", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,9 +1,12 @@
 package tests
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
@@ -40,6 +43,7 @@ func setupTestEnv(t *testing.T) (*gin.Engine, *controllers.SitesController, stri
 			Address: "127.0.0.1",
 		},
 		Dirs: config.DirsConfig{
+			DataDir:   filepath.Join(tmpDir, "data"),
 			StaticDir: staticDir,
 		},
 		Sites: []config.SiteConfig{},
@@ -79,6 +83,10 @@ func setupTestEnv(t *testing.T) (*gin.Engine, *controllers.SitesController, stri
 		configManager,
 		siteServerMgr,
 		siteHandler,
+		nil, // PrerenderManager
+		nil, // FirewallManager
+		nil, // ACMEManager
+		nil, // IntegrityManager
 		nil, // RedisClient
 		monitor,
 		crawlerLogMgr,
@@ -95,6 +103,18 @@ func setupTestEnv(t *testing.T) (*gin.Engine, *controllers.SitesController, stri
 	r.POST("/api/v1/sites", sitesController.AddSite)
 	r.PUT("/api/v1/sites/:id", sitesController.UpdateSite)
 	r.DELETE("/api/v1/sites/:id", sitesController.DeleteSite)
+	r.POST("/api/v1/sites/:id/enable", sitesController.EnableSite)
+	r.POST("/api/v1/sites/:id/disable", sitesController.DisableSite)
+	r.GET("/api/v1/sites/:id/static", sitesController.GetStaticFiles)
+	r.POST("/api/v1/sites/:id/static", sitesController.UploadStaticFile)
+	r.POST("/api/v1/sites/:id/static/extract", sitesController.ExtractFile)
+	r.DELETE("/api/v1/sites/:id/static", sitesController.DeleteStaticFile)
+	r.POST("/api/v1/sites/:id/static/batch-delete", sitesController.BatchDeleteStaticFiles)
+	r.POST("/api/v1/sites/:id/static/mkdir", sitesController.CreateStaticDir)
+	r.POST("/api/v1/sites/:id/static/rename", sitesController.RenameStaticFile)
+	r.GET("/api/v1/sites/:id/static/download", sitesController.DownloadStaticDir)
+	r.GET("/api/v1/sites/:id/static/usage", sitesController.GetStaticUsage)
+	r.POST("/api/v1/sites/:id/static/rollback", sitesController.RollbackStatic)
 
 	return r, sitesController, tmpDir
 }
@@ -141,6 +161,8 @@ func TestSitesCRUD(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &response)
 	sites := response["data"].([]interface{})
 	assert.Equal(t, 1, len(sites))
+	runtimeStatus := sites[0].(map[string]interface{})["runtime_status"].(map[string]interface{})
+	assert.Equal(t, "running", runtimeStatus["state"])
 
 	// 3. Test Update Site
 	// Use a new port for update
@@ -177,3 +199,598 @@ func TestSitesCRUD(t *testing.T) {
 	sites = response["data"].([]interface{})
 	assert.Equal(t, 0, len(sites))
 }
+
+func TestSitesDomainValidation(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 40000 + (time.Now().UnixNano() % 10000)
+
+	addSite := func(name string, domains []string, port int64) (*httptest.ResponseRecorder, map[string]interface{}) {
+		body, _ := json.Marshal(config.SiteConfig{Name: name, Domains: domains, Port: int(port), Mode: "static"})
+		req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	// 真实域名和通配符域名可以添加，并规范化为小写
+	w, response := addSite("Real Domain Site", []string{"Example.COM", "*.example.com"}, testPort)
+	assert.Equal(t, http.StatusOK, w.Code)
+	siteData := response["data"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"example.com", "*.example.com"}, siteData["domains"])
+
+	// 同一域名不能绑定到两个站点
+	w, _ = addSite("Duplicate Site", []string{"example.com"}, testPort+1)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 非法主机名被拒绝
+	w, _ = addSite("Invalid Site", []string{"bad_domain!.com"}, testPort+2)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ := http.NewRequest("DELETE", "/api/v1/sites/"+siteData["id"].(string), nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestSitesEnableDisable(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 30000 + (time.Now().UnixNano() % 10000)
+	body, _ := json.Marshal(config.SiteConfig{Name: "Toggle Site", Domains: []string{"toggle.example.com"}, Port: int(testPort), Mode: "static"})
+	req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	siteID := response["data"].(map[string]interface{})["id"].(string)
+	defer func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/sites/"+siteID, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	runtimeState := func() string {
+		req, _ := http.NewRequest("GET", "/api/v1/sites", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		for _, item := range response["data"].([]interface{}) {
+			site := item.(map[string]interface{})
+			if site["id"] == siteID {
+				return site["runtime_status"].(map[string]interface{})["state"].(string)
+			}
+		}
+		return ""
+	}
+
+	// 停用后站点仍在列表中，状态为disabled，服务器已停止
+	req, _ = http.NewRequest("POST", "/api/v1/sites/"+siteID+"/disable", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "disabled", runtimeState())
+	assert.False(t, config.GetInstance().GetConfig().Sites[0].IsEnabled())
+
+	// 重新启用后服务器恢复运行
+	req, _ = http.NewRequest("POST", "/api/v1/sites/"+siteID+"/enable", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "running", runtimeState())
+
+	req, _ = http.NewRequest("POST", "/api/v1/sites/unknown/disable", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStaticFilesPathTraversal(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 30000 + (time.Now().UnixNano() % 10000)
+	body, _ := json.Marshal(config.SiteConfig{Name: "Static Site", Domains: []string{"static.example.com"}, Port: int(testPort), Mode: "static"})
+	req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	siteID := response["data"].(map[string]interface{})["id"].(string)
+	defer func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/sites/"+siteID, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	// 静态目录之外的文件
+	secretPath := filepath.Join(tmpDir, "config.yaml")
+	siteStaticDir := filepath.Join(tmpDir, "static", siteID)
+	os.MkdirAll(siteStaticDir, 0755)
+
+	request := func(method, path string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+		if body == nil {
+			body = &bytes.Buffer{}
+		}
+		req, _ := http.NewRequest(method, path, body)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	staticURL := "/api/v1/sites/" + siteID + "/static"
+
+	// 读取和删除静态目录之外的文件
+	for _, path := range []string{"../../config.yaml", "/../../config.yaml", "assets/../../../config.yaml"} {
+		w := request("GET", staticURL+"?path="+url.QueryEscape(path), nil, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		w = request("DELETE", staticURL+"?path="+url.QueryEscape(path), nil, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+	_, err := os.Stat(secretPath)
+	assert.NoError(t, err, "file outside the static directory must not be deleted")
+
+	// 以/开头的路径视为相对于站点静态目录
+	w = request("GET", staticURL+"?path="+url.QueryEscape("/etc/passwd"), nil, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// 上传到静态目录之外
+	upload := func(path, filename string, content []byte) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		writer.WriteField("path", path)
+		part, _ := writer.CreateFormFile("file", filename)
+		part.Write(content)
+		writer.Close()
+		return request("POST", staticURL, &buf, writer.FormDataContentType())
+	}
+	w = upload("../..", "evil.txt", []byte("evil"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	_, err = os.Stat(filepath.Join(tmpDir, "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 解压包含../条目的ZIP
+	var zipBuf bytes.Buffer
+	zipWriter := zip.NewWriter(&zipBuf)
+	entry, _ := zipWriter.Create("../../slip.txt")
+	entry.Write([]byte("slip"))
+	zipWriter.Close()
+	w = upload("/", "evil.zip", zipBuf.Bytes())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	form := url.Values{"filename": {"evil.zip"}, "path": {"/"}}
+	w = request("POST", staticURL+"/extract", bytes.NewBufferString(form.Encode()), "application/x-www-form-urlencoded")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	_, err = os.Stat(filepath.Join(tmpDir, "slip.txt"))
+	assert.True(t, os.IsNotExist(err), "zip entry must not be extracted outside the static directory")
+
+	form = url.Values{"filename": {"../../config.yaml"}, "path": {"/"}}
+	w = request("POST", staticURL+"/extract", bytes.NewBufferString(form.Encode()), "application/x-www-form-urlencoded")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestStaticFileManagement(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 30000 + (time.Now().UnixNano() % 10000)
+	body, _ := json.Marshal(config.SiteConfig{Name: "Files Site", Domains: []string{"files.example.com"}, Port: int(testPort), Mode: "static"})
+	req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	siteID := response["data"].(map[string]interface{})["id"].(string)
+	defer func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/sites/"+siteID, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	siteStaticDir := filepath.Join(tmpDir, "static", siteID)
+	os.MkdirAll(filepath.Join(siteStaticDir, "assets"), 0755)
+	os.WriteFile(filepath.Join(siteStaticDir, "assets", "app.js"), []byte("console.log(1)"), 0644)
+	os.WriteFile(filepath.Join(siteStaticDir, "index.html"), []byte("<html></html>"), 0644)
+
+	staticURL := "/api/v1/sites/" + siteID + "/static"
+	postJSON := func(path string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", staticURL+path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 创建目录
+	w = postJSON("/mkdir", gin.H{"path": "/docs/guide"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	info, err := os.Stat(filepath.Join(siteStaticDir, "docs", "guide"))
+	assert.NoError(t, err)
+	assert.True(t, info != nil && info.IsDir())
+	assert.Equal(t, http.StatusConflict, postJSON("/mkdir", gin.H{"path": "/docs/guide"}).Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON("/mkdir", gin.H{"path": "../../outside"}).Code)
+	_, err = os.Stat(filepath.Join(tmpDir, "outside"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 重命名和移动
+	w = postJSON("/rename", gin.H{"from": "/index.html", "to": "/docs/index.html"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = os.Stat(filepath.Join(siteStaticDir, "docs", "index.html"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, postJSON("/rename", gin.H{"from": "/index.html", "to": "/other.html"}).Code)
+	assert.Equal(t, http.StatusConflict, postJSON("/rename", gin.H{"from": "/docs/index.html", "to": "/assets/app.js"}).Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON("/rename", gin.H{"from": "/docs", "to": "/docs/guide/docs"}).Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON("/rename", gin.H{"from": "/docs/index.html", "to": "../../index.html"}).Code)
+
+	// 打包下载目录
+	req, _ = http.NewRequest("GET", staticURL+"/download?path="+url.QueryEscape("/"), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), siteID+".zip")
+	zipReader, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if assert.NoError(t, err) {
+		var names []string
+		for _, file := range zipReader.File {
+			names = append(names, file.Name)
+		}
+		assert.ElementsMatch(t, []string{"assets/", "assets/app.js", "docs/", "docs/guide/", "docs/index.html"}, names)
+	}
+	req, _ = http.NewRequest("GET", staticURL+"/download?path="+url.QueryEscape("../.."), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// 批量删除逐项返回结果
+	w = postJSON("/batch-delete", gin.H{"paths": []string{"/assets/app.js", "../../config.yaml", "/"}})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	var batch struct {
+		Data struct {
+			Deleted int `json:"deleted"`
+			Results []struct {
+				Path    string `json:"path"`
+				Success bool   `json:"success"`
+				Error   string `json:"error"`
+			} `json:"results"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &batch)
+	assert.Equal(t, 1, batch.Data.Deleted)
+	if assert.Len(t, batch.Data.Results, 3) {
+		assert.True(t, batch.Data.Results[0].Success)
+		assert.False(t, batch.Data.Results[1].Success)
+		assert.NotEmpty(t, batch.Data.Results[1].Error)
+		assert.False(t, batch.Data.Results[2].Success)
+	}
+	_, err = os.Stat(filepath.Join(siteStaticDir, "assets", "app.js"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tmpDir, "config.yaml"))
+	assert.NoError(t, err)
+	_, err = os.Stat(siteStaticDir)
+	assert.NoError(t, err)
+}
+
+// TestStaticDeployAndRollback 测试解压到站点根目录时整站发布、缺少index.html时拒绝发布以及回滚到上一个版本
+func TestStaticDeployAndRollback(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 30000 + (time.Now().UnixNano() % 10000)
+	body, _ := json.Marshal(config.SiteConfig{Name: "Deploy Site", Domains: []string{"deploy.example.com"}, Port: int(testPort), Mode: "static"})
+	req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	siteID := response["data"].(map[string]interface{})["id"].(string)
+	defer func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/sites/"+siteID, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	staticDir := filepath.Join(tmpDir, "static")
+	siteStaticDir := filepath.Join(staticDir, siteID)
+	os.MkdirAll(siteStaticDir, 0755)
+	os.WriteFile(filepath.Join(siteStaticDir, "index.html"), []byte("v1"), 0644)
+
+	writeZip := func(name string, files map[string]string) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for fileName, content := range files {
+			fw, _ := zw.Create(fileName)
+			fw.Write([]byte(content))
+		}
+		zw.Close()
+		os.WriteFile(filepath.Join(siteStaticDir, name), buf.Bytes(), 0644)
+	}
+	extract := func(name string) *httptest.ResponseRecorder {
+		form := url.Values{"filename": {name}, "path": {"/"}}
+		req, _ := http.NewRequest("POST", "/api/v1/sites/"+siteID+"/static/extract", bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	rollback := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/v1/sites/"+siteID+"/static/rollback", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	readIndex := func() string {
+		content, _ := os.ReadFile(filepath.Join(siteStaticDir, "index.html"))
+		return string(content)
+	}
+	globCount := func(pattern string) int {
+		matches, _ := filepath.Glob(filepath.Join(staticDir, siteID+pattern))
+		return len(matches)
+	}
+
+	// 没有历史版本时无法回滚
+	assert.Equal(t, http.StatusNotFound, rollback().Code)
+
+	// 缺少index.html的归档不会替换站点目录，也不会残留临时目录
+	writeZip("broken.zip", map[string]string{"about.html": "about"})
+	assert.Equal(t, http.StatusBadRequest, extract("broken.zip").Code)
+	assert.Equal(t, "v1", readIndex())
+	assert.Equal(t, 0, globCount(".staging-*"))
+
+	// 整站发布后旧目录保留为历史版本
+	writeZip("v2.zip", map[string]string{"index.html": "v2", "assets/app.js": "app"})
+	w = extract("v2.zip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deployed":true`)
+	assert.Equal(t, "v2", readIndex())
+	_, err := os.Stat(filepath.Join(siteStaticDir, "broken.zip"))
+	assert.True(t, os.IsNotExist(err), "new release should only contain the archive contents")
+	assert.Equal(t, 1, globCount(".release-*"))
+
+	// 回滚切换回上一个版本，再次回滚撤销
+	assert.Equal(t, http.StatusOK, rollback().Code)
+	assert.Equal(t, "v1", readIndex())
+	assert.Equal(t, http.StatusOK, rollback().Code)
+	assert.Equal(t, "v2", readIndex())
+
+	// 超出保留数量的旧版本被清理
+	for i := 0; i < 4; i++ {
+		writeZip("next.zip", map[string]string{"index.html": "next"})
+		assert.Equal(t, http.StatusOK, extract("next.zip").Code)
+	}
+	assert.Equal(t, 3, globCount(".release-*"))
+}
+
+func TestStaticUploadFileTypes(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 30000 + (time.Now().UnixNano() % 10000)
+	body, _ := json.Marshal(config.SiteConfig{Name: "Upload Site", Domains: []string{"upload.example.com"}, Port: int(testPort), Mode: "static"})
+	req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	siteID := response["data"].(map[string]interface{})["id"].(string)
+	defer func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/sites/"+siteID, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	siteStaticDir := filepath.Join(tmpDir, "static", siteID)
+
+	upload := func(filename string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		writer := multipart.NewWriter(&buf)
+		writer.WriteField("path", "/")
+		part, _ := writer.CreateFormFile("file", filename)
+		part.Write([]byte("content"))
+		writer.Close()
+		req, _ := http.NewRequest("POST", "/api/v1/sites/"+siteID+"/static", &buf)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 服务端脚本不允许上传，也不会写入站点目录
+	for _, filename := range []string{"shell.php", "shell.php.jpg"} {
+		assert.Equal(t, http.StatusUnsupportedMediaType, upload(filename).Code, filename)
+		_, err := os.Stat(filepath.Join(siteStaticDir, filename))
+		assert.True(t, os.IsNotExist(err), filename)
+	}
+
+	// 网页资源正常上传
+	assert.Equal(t, http.StatusOK, upload("index.html").Code)
+	_, err := os.Stat(filepath.Join(siteStaticDir, "index.html"))
+	assert.NoError(t, err)
+
+	postForm := func(path string, form url.Values) int {
+		req, _ := http.NewRequest("POST", "/api/v1/sites/"+siteID+"/static"+path, bytes.NewBufferString(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	rename := func(from, to string) int {
+		body, _ := json.Marshal(gin.H{"from": from, "to": to})
+		req, _ := http.NewRequest("POST", "/api/v1/sites/"+siteID+"/static/rename", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 不能把已上传的文件重命名为服务端脚本，目录和允许的文件类型可以重命名
+	assert.Equal(t, http.StatusUnsupportedMediaType, rename("/index.html", "/shell.php"))
+	_, err = os.Stat(filepath.Join(siteStaticDir, "index.html"))
+	assert.NoError(t, err, "rejected rename should keep the source file")
+	assert.Equal(t, http.StatusOK, rename("/index.html", "/home.html"))
+	os.MkdirAll(filepath.Join(siteStaticDir, "assets"), 0755)
+	assert.Equal(t, http.StatusOK, rename("/assets", "/static"))
+
+	// 归档中有服务端脚本时拒绝解压，整站发布和解压到子目录时已解压的条目都被清理
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"docs/guide.html", "docs/shell.php"} {
+		fw, _ := zw.Create(name)
+		fw.Write([]byte("content"))
+	}
+	zw.Close()
+	os.WriteFile(filepath.Join(siteStaticDir, "bundle.zip"), buf.Bytes(), 0644)
+	os.MkdirAll(filepath.Join(siteStaticDir, "sub"), 0755)
+	os.WriteFile(filepath.Join(siteStaticDir, "sub", "bundle.zip"), buf.Bytes(), 0644)
+	assert.Equal(t, http.StatusUnsupportedMediaType, postForm("/extract", url.Values{"filename": {"bundle.zip"}, "path": {"/"}}))
+	assert.Equal(t, http.StatusUnsupportedMediaType, postForm("/extract", url.Values{"filename": {"bundle.zip"}, "path": {"/sub"}}))
+	for _, name := range []string{"docs/shell.php", "docs/guide.html", "sub/docs/guide.html", "sub/docs/shell.php"} {
+		_, err := os.Stat(filepath.Join(siteStaticDir, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+}
+
+// TestStaticUsage 测试站点静态目录磁盘占用统计与实际文件一致，结果在缓存期内不重复遍历
+func TestStaticUsage(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 30000 + (time.Now().UnixNano() % 10000)
+	body, _ := json.Marshal(config.SiteConfig{Name: "Usage Site", Domains: []string{"usage.example.com"}, Port: int(testPort), Mode: "static"})
+	req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	siteID := response["data"].(map[string]interface{})["id"].(string)
+	defer func() {
+		req, _ := http.NewRequest("DELETE", "/api/v1/sites/"+siteID, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	siteStaticDir := filepath.Join(tmpDir, "static", siteID)
+	os.MkdirAll(filepath.Join(siteStaticDir, "assets", "img"), 0755)
+	os.WriteFile(filepath.Join(siteStaticDir, "index.html"), bytes.Repeat([]byte("a"), 100), 0644)
+	os.WriteFile(filepath.Join(siteStaticDir, "assets", "app.js"), bytes.Repeat([]byte("b"), 3000), 0644)
+	os.WriteFile(filepath.Join(siteStaticDir, "assets", "img", "logo.png"), bytes.Repeat([]byte("c"), 2000), 0644)
+
+	getUsage := func() (int, map[string]interface{}) {
+		req, _ := http.NewRequest("GET", "/api/v1/sites/"+siteID+"/static/usage", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		data, _ := response["data"].(map[string]interface{})
+		return w.Code, data
+	}
+
+	code, usage := getUsage()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(5100), usage["total_bytes"])
+	assert.Equal(t, float64(3), usage["file_count"])
+	largest := usage["largest_files"].([]interface{})
+	if assert.Len(t, largest, 3) {
+		assert.Equal(t, map[string]interface{}{"path": "/assets/app.js", "size": float64(3000)}, largest[0])
+		assert.Equal(t, "/assets/img/logo.png", largest[1].(map[string]interface{})["path"])
+		assert.Equal(t, "/index.html", largest[2].(map[string]interface{})["path"])
+	}
+
+	// 缓存期内返回相同结果
+	os.WriteFile(filepath.Join(siteStaticDir, "new.html"), []byte("new"), 0644)
+	_, usage = getUsage()
+	assert.Equal(t, float64(3), usage["file_count"])
+
+	req, _ = http.NewRequest("GET", "/api/v1/sites/missing/static/usage", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestStaticReadOnly 测试只读站点拒绝所有修改静态目录的接口，文件列表和磁盘占用等读取接口正常
+func TestStaticReadOnly(t *testing.T) {
+	router, _, tmpDir := setupTestEnv(t)
+	defer os.RemoveAll(tmpDir)
+
+	testPort := 30000 + (time.Now().UnixNano() % 10000)
+	body, _ := json.Marshal(config.SiteConfig{Name: "Mounted Site", Domains: []string{"mounted.example.com"}, Port: int(testPort), Mode: "static", StaticReadOnly: true})
+	req, _ := http.NewRequest("POST", "/api/v1/sites", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	siteID := response["data"].(map[string]interface{})["id"].(string)
+
+	siteStaticDir := filepath.Join(tmpDir, "static", siteID)
+	os.MkdirAll(siteStaticDir, 0755)
+	os.WriteFile(filepath.Join(siteStaticDir, "index.html"), []byte("<html></html>"), 0644)
+
+	staticURL := "/api/v1/sites/" + siteID + "/static"
+	send := func(method, path string, payload interface{}) int {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, staticURL+path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("path", "/")
+	part, _ := writer.CreateFormFile("file", "new.html")
+	part.Write([]byte("<html>new</html>"))
+	writer.Close()
+	req, _ = http.NewRequest("POST", staticURL, &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code, "upload")
+
+	assert.Equal(t, http.StatusForbidden, send("POST", "/extract", gin.H{}), "extract")
+	assert.Equal(t, http.StatusForbidden, send("DELETE", "?path="+url.QueryEscape("/index.html"), nil), "delete")
+	assert.Equal(t, http.StatusForbidden, send("POST", "/batch-delete", gin.H{"paths": []string{"/index.html"}}), "batch delete")
+	assert.Equal(t, http.StatusForbidden, send("POST", "/mkdir", gin.H{"path": "/docs"}), "mkdir")
+	assert.Equal(t, http.StatusForbidden, send("POST", "/rename", gin.H{"from": "/index.html", "to": "/home.html"}), "rename")
+	assert.Equal(t, http.StatusForbidden, send("POST", "/rollback", nil), "rollback")
+
+	_, err := os.Stat(filepath.Join(siteStaticDir, "new.html"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(siteStaticDir, "docs"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 读取接口不受影响
+	assert.Equal(t, http.StatusOK, send("GET", "?path=/", nil))
+	assert.Equal(t, http.StatusOK, send("GET", "/usage", nil))
+	assert.Equal(t, http.StatusOK, send("GET", "/download?path=/", nil))
+
+	// 删除站点时保留外部提供的静态目录
+	req, _ = http.NewRequest("DELETE", "/api/v1/sites/"+siteID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	_, err = os.Stat(filepath.Join(siteStaticDir, "index.html"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"prerender-shield/internal/api/controllers"
+	"prerender-shield/internal/api/routes"
+	"prerender-shield/internal/auth"
+	"prerender-shield/internal/redis"
+)
+
+// setupUserRouter 使用miniredis创建用户管理路由，删除用户的Lua脚本在miniredis中执行，返回路由、用户管理器和JWT管理器
+func setupUserRouter(t *testing.T) (*gin.Engine, *auth.UserManager, *auth.JWTManager) {
+	gin.SetMode(gin.TestMode)
+	client, err := redis.NewClient(miniredis.RunT(t).Addr())
+	if err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	userManager := auth.NewUserManager("", client)
+	jwtManager := auth.NewJWTManager(&auth.JWTConfig{SecretKey: "test-secret", ExpireTime: time.Hour}, client)
+
+	r := gin.New()
+	routes.RegisterAllRoutes(r, &routes.Controllers{
+		UserController: controllers.NewUserController(userManager),
+	}, jwtManager)
+	return r, userManager, jwtManager
+}
+
+// userRequest 使用令牌发送用户管理请求
+func userRequest(r *gin.Engine, token, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUserManagement(t *testing.T) {
+	r, userManager, jwtManager := setupUserRouter(t)
+
+	admin, err := userManager.CreateUser("admin", "admin-password")
+	assert.NoError(t, err)
+	adminToken, err := jwtManager.GenerateToken(admin.ID, admin.Username)
+	assert.NoError(t, err)
+
+	// 密码太短时拒绝创建
+	w := userRequest(r, adminToken, http.MethodPost, "/api/v1/users", gin.H{"username": "bob", "password": "x"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = userRequest(r, adminToken, http.MethodPost, "/api/v1/users", gin.H{"username": "bob", "password": "bob-password"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var created struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// 用户名重复
+	w = userRequest(r, adminToken, http.MethodPost, "/api/v1/users", gin.H{"username": "bob", "password": "another-password"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = userRequest(r, adminToken, http.MethodGet, "/api/v1/users", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"bob"`)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	// 删除用户后其令牌立即失效
+	bobToken, err := jwtManager.GenerateToken(created.Data.ID, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, userRequest(r, bobToken, http.MethodGet, "/api/v1/users", nil).Code)
+	w = userRequest(r, adminToken, http.MethodDelete, "/api/v1/users/"+created.Data.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, userRequest(r, bobToken, http.MethodGet, "/api/v1/users", nil).Code)
+	assert.Equal(t, http.StatusNotFound, userRequest(r, adminToken, http.MethodDelete, "/api/v1/users/"+created.Data.ID, nil).Code)
+
+	// 不能删除最后一个用户
+	w = userRequest(r, adminToken, http.MethodDelete, "/api/v1/users/"+admin.ID, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// 删除脚本同时删除用户名映射，用户名可以重新使用
+	w = userRequest(r, adminToken, http.MethodPost, "/api/v1/users", gin.H{"username": "bob", "password": "bob-password"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestDeleteUserConcurrentKeepsLastUser(t *testing.T) {
+	_, userManager, _ := setupUserRouter(t)
+
+	first, err := userManager.CreateUser("first", "first-password")
+	assert.NoError(t, err)
+	second, err := userManager.AddUser("second", "second-password")
+	assert.NoError(t, err)
+
+	// 同时删除仅有的两个用户，只有一个能成功
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, id := range []string{first.ID, second.ID} {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			errs[i] = userManager.DeleteUser(id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, auth.ErrLastUser)
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed)
+	users, err := userManager.ListUsers()
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+}
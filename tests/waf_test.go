@@ -4,13 +4,20 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 
 	"prerender-shield/internal/config"
+	"prerender-shield/internal/firewall"
+	"prerender-shield/internal/logging"
 	"prerender-shield/internal/middleware"
+	"prerender-shield/internal/monitoring"
+	sitehandler "prerender-shield/internal/site-handler"
 )
 
 // MockGeoIPResolver implements services.GeoIPResolver for testing
@@ -51,7 +58,6 @@ func TestWafMiddleware_IPAccessControl(t *testing.T) {
 
 	t.Run("Blacklisted IP should be blocked", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/test", nil)
-		req.Header.Set("X-Forwarded-For", "127.0.0.1") // Gin's ClientIP() uses this
 		req.RemoteAddr = "127.0.0.1:12345"
 
 		w := httptest.NewRecorder()
@@ -72,6 +78,17 @@ func TestWafMiddleware_IPAccessControl(t *testing.T) {
 		assert.Equal(t, "OK", w.Body.String())
 	})
 
+	t.Run("Spoofed X-Forwarded-For should not bypass the blacklist", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		req.RemoteAddr = "127.0.0.1:12345"
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
 	t.Run("Normal IP should be allowed", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/test", nil)
 		req.RemoteAddr = "8.8.8.8:12345"
@@ -169,3 +186,46 @@ func TestWafMiddleware_GeoIPAccessControl(t *testing.T) {
 		assert.Equal(t, http.StatusForbidden, w2.Code)
 	})
 }
+
+// TestSiteHandlerFirewallBlocksSQLi 测试站点处理器对每个请求执行防火墙引擎检测，白名单IP跳过检测
+func TestSiteHandlerFirewallBlocksSQLi(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	site := config.SiteConfig{
+		ID:   "sqli-site",
+		Name: "sqli-site",
+		Mode: "static",
+		Firewall: config.FirewallConfig{
+			Enabled:      true,
+			Whitelist:    []string{"10.0.0.0/8"},
+			ActionConfig: config.ActionConfig{DefaultAction: "block", BlockMessage: "Blocked by firewall"},
+		},
+	}
+	staticDir := t.TempDir()
+	os.MkdirAll(filepath.Join(staticDir, site.ID), 0755)
+	os.WriteFile(filepath.Join(staticDir, site.ID, "index.html"), []byte("<html>home</html>"), 0644)
+
+	manager := firewall.NewEngineManager()
+	assert.NoError(t, manager.AddSite(site.Name, firewall.NewSiteConfig(site, staticDir, nil)))
+	handler := sitehandler.NewHandler(nil, nil, nil, nil)
+	handler.SetFirewallManager(manager)
+	monitor := monitoring.NewMonitor(monitoring.Config{Enabled: false})
+	siteHandler := handler.CreateSiteHandler(site, logging.NewCrawlerLogManager(""), logging.NewVisitLogManager(""), monitor, staticDir)
+
+	request := func(target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		siteHandler.ServeHTTP(w, req)
+		return w
+	}
+
+	sqli := "http://example.com/index.html?id=" + url.QueryEscape("1' OR '1'='1' UNION SELECT username, password FROM users--")
+	w := request(sqli, "203.0.113.7:4321")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Blocked by firewall")
+
+	// 正常请求和白名单IP的请求不被拦截
+	assert.Equal(t, http.StatusOK, request("http://example.com/index.html?id=42", "203.0.113.7:4321").Code)
+	assert.Equal(t, http.StatusOK, request(sqli, "10.1.2.3:4321").Code)
+}
@@ -15,13 +15,13 @@ const formatBytes = (bytes: number, decimals = 2): string => {
 
 const Monitoring: React.FC = () => {
   const [stats, setStats] = useState({
-    requestsPerSecond: 12.5,
-    cpuUsage: 25.3,
-    memoryUsage: 67.8,
+    requestsPerSecond: 0,
+    cpuUsage: 0,
+    memoryUsage: 0,
     memoryTotal: 0,
     memoryUsed: 0,
     memoryFree: 0,
-    diskUsage: 45.2,
+    diskUsage: 0,
     diskTotal: 0,
     diskUsed: 0,
     diskFree: 0,
@@ -29,6 +29,8 @@ const Monitoring: React.FC = () => {
     networkRecv: 0,
     networkPacketsSent: 0,
     networkPacketsRecv: 0,
+    process: { rss: 0, heapAlloc: 0, sys: 0, goroutines: 0 },
+    browsers: { count: 0, rss: 0 },
   })
 
   // 图表配置
@@ -191,6 +193,24 @@ const Monitoring: React.FC = () => {
         </Row>
       </Card>
 
+      {/* 进程内存，无头浏览器通常是内存占用的主要来源 */}
+      <Card className="card" title="进程内存" style={{ marginTop: 16 }}>
+        <Row gutter={[16, 16]}>
+          <Col span={6}>
+            <Statistic title="服务进程内存" value={formatBytes(stats.process?.rss || 0)} />
+          </Col>
+          <Col span={6}>
+            <Statistic title="协程数" value={stats.process?.goroutines || 0} />
+          </Col>
+          <Col span={6}>
+            <Statistic title="浏览器进程数" value={stats.browsers?.count || 0} />
+          </Col>
+          <Col span={6}>
+            <Statistic title="浏览器内存" value={formatBytes(stats.browsers?.rss || 0)} />
+          </Col>
+        </Row>
+      </Card>
+
       {/* 详细资源使用情况 */}
       <Row gutter={[16, 16]} style={{ marginTop: 16 }}>
         {/* 内存详情 */}
@@ -13,6 +13,7 @@ const Push: React.FC = () => {
     success: 0,
     failed: 0,
   })
+  const [engineStats, setEngineStats] = useState<any[]>([])
   const [loading, setLoading] = useState(false)
   const [logList, setLogList] = useState<any[]>([])
   const [logLoading, setLogLoading] = useState(false)
@@ -20,6 +21,23 @@ const Push: React.FC = () => {
   const [pageSize, setPageSize] = useState(20)
   const [total, setTotal] = useState(0)
 
+  // 搜索引擎显示名称
+  const engineNames: { [key: string]: string } = {
+    'baidu': '百度',
+    'bing': '必应',
+    'google': 'Google',
+    'indexnow': 'IndexNow',
+  }
+
+  // 按搜索引擎统计表格列配置
+  const engineColumns = [
+    { title: '搜索引擎', dataIndex: 'engine', key: 'engine', render: (engine: string) => engineNames[engine] || engine },
+    { title: '今日成功', dataIndex: 'todaySuccess', key: 'todaySuccess' },
+    { title: '今日失败', dataIndex: 'todayFailed', key: 'todayFailed' },
+    { title: '累计成功', dataIndex: 'success', key: 'success' },
+    { title: '累计失败', dataIndex: 'failed', key: 'failed' },
+  ]
+
   // 日志表格列配置
   const columns = [
     {
@@ -49,11 +67,7 @@ const Push: React.FC = () => {
       dataIndex: 'searchEngine',
       key: 'searchEngine',
       render: (engine: string) => {
-        const engineMap: { [key: string]: string } = {
-          'baidu': '百度',
-          'bing': '必应',
-        }
-        return engineMap[engine] || engine
+        return engineNames[engine] || engine
       }
     },
     {
@@ -120,6 +134,16 @@ const Push: React.FC = () => {
           success: res.data.stats.success || 0,
           failed: res.data.stats.failed || 0,
         })
+        // 按搜索引擎区分的今日和累计推送结果
+        const overall = res.data.stats.engines || {}
+        const today = res.data.stats.today?.engines || {}
+        setEngineStats(Array.from(new Set([...Object.keys(overall), ...Object.keys(today)])).map((engine) => ({
+          engine,
+          todaySuccess: today[engine]?.success || 0,
+          todayFailed: today[engine]?.failed || 0,
+          success: overall[engine]?.success || 0,
+          failed: overall[engine]?.failed || 0,
+        })))
       }
     } catch (error) {
       console.error('Failed to fetch stats:', error)
@@ -236,6 +260,14 @@ const Push: React.FC = () => {
             />
           </Col>
         </Row>
+        <Table
+          style={{ marginTop: 16 }}
+          columns={engineColumns}
+          dataSource={engineStats}
+          rowKey="engine"
+          pagination={false}
+          size="small"
+        />
       </Card>
       
       {/* 推送日志列表 */}
@@ -5,7 +5,7 @@ import {
   UnorderedListOutlined, CloudUploadOutlined, FolderOpenOutlined, 
   FolderOutlined, FileOutlined, FolderOutlined as NewFolderOutlined, FileAddOutlined, UpOutlined, 
   DownloadOutlined, UnorderedListOutlined as ExtractOutlined, ReloadOutlined,
-  SecurityScanOutlined, SearchOutlined
+  SecurityScanOutlined, SearchOutlined, RollbackOutlined
 } from '@ant-design/icons'
 import { sitesApi } from '../../services/api'
 import type { UploadProps } from 'antd'
@@ -735,6 +735,30 @@ const Sites: React.FC = () => {
     }
   }
 
+  // 回滚到上一个发布版本
+  const handleRollback = () => {
+    if (!currentSite) return
+
+    Modal.confirm({
+      title: '回滚确认',
+      content: '确定要将站点切换回上一个发布版本吗？再次回滚可以撤销。',
+      onOk: async () => {
+        try {
+          const response = await sitesApi.rollbackStatic(currentSite.id)
+          if (response.code === 200) {
+            messageApi.success('已回滚到上一个版本')
+            loadFileList(currentPath)
+          } else {
+            messageApi.error(`回滚失败: ${response.message || '未知错误'}`)
+          }
+        } catch (error) {
+          console.error('回滚失败:', error)
+          messageApi.error('回滚失败')
+        }
+      }
+    })
+  }
+
 
 
   // 批量删除
@@ -1830,6 +1854,7 @@ const Sites: React.FC = () => {
             <Select>
               <Option value="allow">允许</Option>
               <Option value="block">拦截</Option>
+              <Option value="challenge">JS挑战</Option>
             </Select>
           </Form.Item>
           <Form.Item name={['firewall', 'action', 'blockMessage']} label="拦截消息">
@@ -1934,6 +1959,7 @@ const Sites: React.FC = () => {
           <Space>
             <Button icon={<NewFolderOutlined />} onClick={handleNewFolder}>新建目录</Button>
             <Button icon={<FileAddOutlined />} onClick={handleNewFile}>新建文件</Button>
+            <Button icon={<RollbackOutlined />} onClick={handleRollback}>回滚</Button>
             <Upload 
               customRequest={customRequest} 
               beforeUpload={beforeUpload} 
@@ -1988,7 +2014,7 @@ const Sites: React.FC = () => {
                   {record.type === 'file' && (
                     <>
                       <Button type="link" size="small" icon={<DownloadOutlined />} onClick={() => handleDownload(record)}>下载</Button>
-                      {record.name.endsWith('.zip') && (
+                      {/\.(zip|tar\.gz|tgz|tar|rar)$/i.test(record.name) && (
                         <Button type="link" size="small" icon={<ExtractOutlined />} onClick={() => handleExtract(record)}>解压</Button>
                       )}
                     </>
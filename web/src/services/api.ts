@@ -126,10 +126,21 @@ export const firewallApi = {
   getAccessLogs: (params: { site_id?: string; page?: number; limit?: number }) => api.get('/logs', { params }),
   // 为了兼容Firewall.tsx增加的方法
   getAttackLogs: (params: { site_id: string; page: number; limit: number }) => api.get('/firewall/attacks', { params }),
+  // 拦截日志，包含命中的检测器或规则及威胁详情
+  getBlockedLogs: (params: { site?: string; startTime?: string; endTime?: string; page?: number; pageSize?: number }) => api.get('/firewall/logs', { params }),
+  // 网页防篡改基线状态和文件篡改事件
+  getIntegrity: (params: { site?: string; page?: number; pageSize?: number }) => api.get('/firewall/integrity', { params }),
   addToWhitelist: (siteId: string, ip: string) => api.post(`/firewall/whitelist`, { site_id: siteId, ip }),
   addToBlacklist: (siteId: string, ip: string) => api.post(`/firewall/blacklist`, { site_id: siteId, ip }),
   getStatus: (siteId: string) => api.get(`/sites/${siteId}/waf`),
   getRules: (siteId: string) => api.get(`/sites/${siteId}/waf`), // 规则包含在配置中
+  // 自定义规则按站点保存在规则目录中
+  listCustomRules: (siteId: string, category?: string) => api.get('/firewall/rules', { params: { site: siteId, category } }),
+  addCustomRule: (siteId: string, rule: any) => api.post('/firewall/rules', rule, { params: { site: siteId } }),
+  updateCustomRule: (siteId: string, ruleId: string, rule: any) => api.put(`/firewall/rules/${ruleId}`, rule, { params: { site: siteId } }),
+  deleteCustomRule: (siteId: string, ruleId: string) => api.delete(`/firewall/rules/${ruleId}`, { params: { site: siteId } }),
+  listBans: (siteId: string) => api.get('/firewall/bans', { params: { site: siteId } }),
+  liftBan: (siteId: string, ip: string) => api.delete('/firewall/bans', { params: { site: siteId, ip } }),
   scan: (data: { site: string; url: string }) => api.post(`/sites/${data.site}/scan`, data),
 }
 
@@ -157,7 +168,10 @@ export const routingApi = {
 // 监控API
 export const monitoringApi = {
   getStats: () => api.get('/monitoring/stats'),
+  // 单个站点的请求、延迟分位数和缓存命中率
+  getSiteStats: (siteId: string) => api.get(`/monitoring/sites/${siteId}`),
   getLogs: () => api.get('/monitoring/logs'),
+  getEngines: () => api.get('/engines'),
 }
 
 // 站点管理API
@@ -179,19 +193,26 @@ export const sitesApi = {
     formData.append('path', path)
     return api.post(`/sites/${siteId}/static`, formData, { onUploadProgress })
   },
-  extractFile: (siteId: string, filename: string, path: string) => {
+  // stripComponents: 去掉归档条目开头的目录层数，如内容都在dist/下时传1
+  extractFile: (siteId: string, filename: string, path: string, stripComponents = 0) => {
     const formData = new FormData()
     formData.append('filename', filename)
     formData.append('path', path)
+    formData.append('strip_components', String(stripComponents))
     return api.post(`/sites/${siteId}/static/extract`, formData)
   },
   deleteStaticResources: (siteId: string, path: string) => api.delete(`/sites/${siteId}/static`, { params: { path } }),
   batchDeleteStaticResources: (siteId: string, paths: string[]) => api.post(`/sites/${siteId}/static/batch-delete`, { paths }),
+  createStaticDir: (siteId: string, path: string) => api.post(`/sites/${siteId}/static/mkdir`, { path }),
+  renameStaticResource: (siteId: string, from: string, to: string) => api.post(`/sites/${siteId}/static/rename`, { from, to }),
+  downloadStaticDir: (siteId: string, path: string) => api.get(`/sites/${siteId}/static/download`, { params: { path }, responseType: 'blob' }),
+  getStaticUsage: (siteId: string) => api.get(`/sites/${siteId}/static/usage`),
+  rollbackStatic: (siteId: string) => api.post(`/sites/${siteId}/static/rollback`),
 }
 
 // 爬虫日志API
 export const crawlerApi = {
-  getLogs: (params: { site?: string; startTime: string; endTime: string; page: number; pageSize: number }) => api.get('/crawler/logs', { params }),
+  getLogs: (params: { site?: string; startTime: string; endTime: string; page: number; pageSize: number; ua?: string; hitCache?: boolean }) => api.get('/crawler/logs', { params }),
   getStats: (params: { site?: string; startTime: string; endTime: string; granularity: string }) => api.get('/crawler/stats', { params }),
 }
 
@@ -200,6 +221,7 @@ export const pushApi = {
   getStats: (siteId?: string) => api.get('/push/stats', { params: siteId ? { siteId } : {} }),
   getLogs: (siteId?: string, page: number = 1, pageSize: number = 20) => api.get('/push/logs', { params: { siteId, page, pageSize } }),
   triggerPush: (siteId: string) => api.post('/push/trigger', { siteId }),
+  markAllPushed: (siteId: string) => api.post('/push/mark-pushed', null, { params: { siteId } }),
   getConfig: (siteId: string) => api.get('/push/config', { params: { siteId } }),
   updateConfig: (siteId: string, config: any) => api.post('/push/config', { siteId, config }),
   getSites: () => api.get('/push/sites'),
@@ -211,6 +233,13 @@ export const systemApi = {
   version: () => api.get('/version'),
   getConfig: () => api.get('/system/config'),
   updateConfig: (config: any) => api.post('/system/config', config),
+  getConfigBackups: () => api.get('/config/backups'),
+  rollbackConfig: (id: string) => api.post('/config/rollback', { id }),
+  validateConfig: (config: any) => api.post('/config/validate', config),
+  getLogLevel: () => api.get('/logs/level'),
+  setLogLevel: (level: string, module?: string) => api.put('/logs/level', { level, module }),
+  getAuditLogs: (params: { action?: string; resource?: string; actor?: string; result?: string; keyword?: string; startTime?: string; endTime?: string; page?: number; pageSize?: number }) => api.get('/logs/audit', { params }),
+  exportAuditLogs: (params: { action?: string; resource?: string; actor?: string; result?: string; keyword?: string; startTime?: string; endTime?: string; format?: 'csv' | 'json' }) => api.get('/logs/export', { params, responseType: 'blob' }),
 }
 
 export default api
\ No newline at end of file